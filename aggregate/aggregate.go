@@ -0,0 +1,335 @@
+// Package aggregate derives the datasets dashboards read from a ranked
+// snapshot: the top movers of each country, market cap totals by sector and
+// market breadth. Each is written to its own file next to the snapshot.
+package aggregate
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"algotradar/canonical"
+	"algotradar/domain"
+)
+
+// DefaultTopN is how many gainers and losers each country lists
+const DefaultTopN = 10
+
+// Unclassified groups rows without a sector
+const Unclassified = "Unclassified"
+
+// Mover is one row of a gainers or losers list
+type Mover struct {
+	Ticker           string  `json:"ticker"`
+	Name             string  `json:"name"`
+	Sector           string  `json:"sector,omitempty"`
+	CurrentPrice     float64 `json:"current_price"`
+	PercentageChange float64 `json:"percentage_change"`
+	MarketCap        float64 `json:"market_cap"` // USD
+}
+
+// CountryMovers are a country's largest gains and losses of the day
+type CountryMovers struct {
+	Country string  `json:"country"`
+	Gainers []Mover `json:"gainers"`
+	Losers  []Mover `json:"losers"`
+}
+
+// SectorTotal is the market cap of one sector. Change is the day's
+// percentage change weighted by market cap, so large constituents move it
+// as they move an index.
+type SectorTotal struct {
+	Sector    string  `json:"sector"`
+	Companies int     `json:"companies"`
+	MarketCap float64 `json:"market_cap"` // USD
+	Share     float64 `json:"share"`      // of the scope's market cap
+	Change    float64 `json:"percentage_change"`
+}
+
+// CountrySectors are the sector totals of one country
+type CountrySectors struct {
+	Country string        `json:"country"`
+	Sectors []SectorTotal `json:"sectors"`
+}
+
+// Breadth counts the rows that rose, fell or did not move
+type Breadth struct {
+	Country   string `json:"country,omitempty"`
+	Advancers int    `json:"advancers"`
+	Decliners int    `json:"decliners"`
+	Unchanged int    `json:"unchanged"`
+	// AdvanceDecline is advancers per decliner; 0 when nothing declined
+	AdvanceDecline float64 `json:"advance_decline_ratio"`
+}
+
+// Movers is the top movers dataset
+type Movers struct {
+	Generated time.Time       `json:"generated_at"`
+	TopN      int             `json:"top_n"`
+	Countries []CountryMovers `json:"countries"`
+}
+
+// Overall merges the countries' lists into the n largest gains and losses
+// across all of them. Every country lists only its own TopN, so n above TopN
+// is cut to TopN.
+func (m Movers) Overall(n int) (gainers, losers []Mover) {
+	n = max(min(n, m.TopN), 0)
+	for _, c := range m.Countries {
+		gainers = append(gainers, c.Gainers...)
+		losers = append(losers, c.Losers...)
+	}
+	sort.SliceStable(gainers, func(i, j int) bool { return gainers[i].PercentageChange > gainers[j].PercentageChange })
+	sort.SliceStable(losers, func(i, j int) bool { return losers[i].PercentageChange < losers[j].PercentageChange })
+	return gainers[:min(n, len(gainers))], losers[:min(n, len(losers))]
+}
+
+// Sectors is the sector totals dataset, for all rows and per country
+type Sectors struct {
+	Generated time.Time        `json:"generated_at"`
+	Global    []SectorTotal    `json:"global"`
+	Countries []CountrySectors `json:"countries"`
+}
+
+// BreadthReport is the breadth dataset, for all rows and per country
+type BreadthReport struct {
+	Generated time.Time `json:"generated_at"`
+	Total     Breadth   `json:"total"`
+	Countries []Breadth `json:"countries"`
+}
+
+// Result holds the datasets derived from one snapshot
+type Result struct {
+	Movers  Movers
+	Sectors Sectors
+	Breadth BreadthReport
+}
+
+// Compute derives the datasets from assets, listing topN gainers and losers
+// per country. Rows without a price are skipped. Sectors use the normalized
+// sector where there is one, so providers' spellings do not split a sector.
+func Compute(assets []domain.Asset, topN int, generated time.Time) Result {
+	acc := NewAccumulator(topN)
+	for _, a := range assets {
+		acc.Add(a)
+	}
+	return acc.Result(generated)
+}
+
+// Accumulator computes the datasets of Compute from rows added one at a time
+// in rank order, keeping only the totals and the current top movers, so a
+// snapshot streamed from disk never has to be held in memory
+type Accumulator struct {
+	topN      int
+	global    *scope
+	countries map[string]*scope
+}
+
+// scope accumulates the rows of one country, or of all of them
+type scope struct {
+	gainers, losers []domain.Asset
+	sectors         map[string]*SectorTotal
+	marketCap       float64
+	breadth         Breadth
+}
+
+// NewAccumulator starts an empty accumulator listing topN gainers and losers
+func NewAccumulator(topN int) *Accumulator {
+	return &Accumulator{topN: topN, global: newScope(), countries: make(map[string]*scope)}
+}
+
+func newScope() *scope {
+	return &scope{sectors: make(map[string]*SectorTotal)}
+}
+
+// Add counts one row; rows without a price are skipped
+func (acc *Accumulator) Add(a domain.Asset) {
+	if a.CurrentPrice <= 0 {
+		return
+	}
+	country, ok := acc.countries[a.Country]
+	if !ok {
+		country = newScope()
+		acc.countries[a.Country] = country
+	}
+	acc.global.add(a, -1)
+	country.add(a, acc.topN)
+}
+
+// Result returns the datasets of the rows added so far
+func (acc *Accumulator) Result(generated time.Time) Result {
+	generated = generated.UTC()
+	countries := make([]string, 0, len(acc.countries))
+	for country := range acc.countries {
+		countries = append(countries, country)
+	}
+	sort.Strings(countries)
+
+	r := Result{
+		Movers:  Movers{Generated: generated, TopN: acc.topN, Countries: []CountryMovers{}},
+		Sectors: Sectors{Generated: generated, Global: acc.global.sectorTotals(), Countries: []CountrySectors{}},
+		Breadth: BreadthReport{Generated: generated, Total: acc.global.ratio(), Countries: []Breadth{}},
+	}
+	for _, country := range countries {
+		s := acc.countries[country]
+		gainers, losers := s.movers()
+		r.Movers.Countries = append(r.Movers.Countries, CountryMovers{Country: country, Gainers: gainers, Losers: losers})
+		r.Sectors.Countries = append(r.Sectors.Countries, CountrySectors{Country: country, Sectors: s.sectorTotals()})
+		b := s.ratio()
+		b.Country = country
+		r.Breadth.Countries = append(r.Breadth.Countries, b)
+	}
+	return r
+}
+
+// add counts a row, keeping the topN largest rises and falls; the global
+// scope passes a negative topN as it lists no movers
+func (s *scope) add(a domain.Asset, topN int) {
+	if topN > 0 {
+		switch {
+		case a.PercentageChange > 0:
+			s.gainers = insertBounded(s.gainers, a, movesBefore, topN)
+		case a.PercentageChange < 0:
+			s.losers = insertBounded(s.losers, a, func(x, y domain.Asset) bool { return movesBefore(y, x) }, topN)
+		}
+	}
+
+	name := sectorOf(a)
+	t, ok := s.sectors[name]
+	if !ok {
+		t = &SectorTotal{Sector: name}
+		s.sectors[name] = t
+	}
+	t.Companies++
+	t.MarketCap += a.MarketCap
+	t.Change += a.PercentageChange * a.MarketCap
+	s.marketCap += a.MarketCap
+
+	switch {
+	case a.PercentageChange > 0:
+		s.breadth.Advancers++
+	case a.PercentageChange < 0:
+		s.breadth.Decliners++
+	default:
+		s.breadth.Unchanged++
+	}
+}
+
+// movesBefore orders rows by percentage change, largest rise first; ties go
+// to the larger company
+func movesBefore(a, b domain.Asset) bool {
+	if a.PercentageChange != b.PercentageChange {
+		return a.PercentageChange > b.PercentageChange
+	}
+	if a.MarketCap != b.MarketCap {
+		return a.MarketCap > b.MarketCap
+	}
+	return a.Ticker < b.Ticker
+}
+
+// insertBounded inserts a into rows, kept sorted by before and at most n long
+func insertBounded(rows []domain.Asset, a domain.Asset, before func(x, y domain.Asset) bool, n int) []domain.Asset {
+	i := sort.Search(len(rows), func(i int) bool { return before(a, rows[i]) })
+	if i >= n {
+		return rows
+	}
+	if len(rows) < n {
+		rows = append(rows, domain.Asset{})
+	}
+	copy(rows[i+1:], rows[i:])
+	rows[i] = a
+	return rows
+}
+
+// movers returns the largest rises and falls, largest move first
+func (s *scope) movers() (gainers, losers []Mover) {
+	gainers, losers = make([]Mover, len(s.gainers)), make([]Mover, len(s.losers))
+	for i, a := range s.gainers {
+		gainers[i] = mover(a)
+	}
+	for i, a := range s.losers {
+		losers[i] = mover(a)
+	}
+	return gainers, losers
+}
+
+func mover(a domain.Asset) Mover {
+	return Mover{
+		Ticker:           a.Ticker,
+		Name:             a.Name,
+		Sector:           sectorOf(a),
+		CurrentPrice:     a.CurrentPrice,
+		PercentageChange: a.PercentageChange,
+		MarketCap:        a.MarketCap,
+	}
+}
+
+// sectorTotals returns the sector sums, largest sector first
+func (s *scope) sectorTotals() []SectorTotal {
+	out := make([]SectorTotal, 0, len(s.sectors))
+	for _, total := range s.sectors {
+		t := *total
+		if t.MarketCap > 0 {
+			t.Change /= t.MarketCap
+		}
+		if s.marketCap > 0 {
+			t.Share = t.MarketCap / s.marketCap
+		}
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].MarketCap != out[j].MarketCap {
+			return out[i].MarketCap > out[j].MarketCap
+		}
+		return out[i].Sector < out[j].Sector
+	})
+	return out
+}
+
+func sectorOf(a domain.Asset) string {
+	switch {
+	case a.SectorNormalized != "":
+		return a.SectorNormalized
+	case a.Sector != "":
+		return a.Sector
+	}
+	return Unclassified
+}
+
+// ratio returns the breadth counts with the advance/decline ratio filled in
+func (s *scope) ratio() Breadth {
+	b := s.breadth
+	if b.Decliners > 0 {
+		b.AdvanceDecline = float64(b.Advancers) / float64(b.Decliners)
+	}
+	return b
+}
+
+// Files are the names the datasets are written under for an output prefix
+func Files(prefix string) (movers, sectors, breadth string) {
+	return prefix + "_movers.json", prefix + "_sectors.json", prefix + "_breadth.json"
+}
+
+// Write saves each dataset as canonical JSON. path maps the file names of
+// Files to where they are written, e.g. to timestamp them; written is called
+// with both names after each file is saved.
+func (r Result) Write(prefix string, path func(name string) string, written func(name, path string)) error {
+	moversName, sectorsName, breadthName := Files(prefix)
+	for _, out := range []struct {
+		name string
+		v    any
+	}{{moversName, r.Movers}, {sectorsName, r.Sectors}, {breadthName, r.Breadth}} {
+		data, err := canonical.JSON(out.v)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", out.name, err)
+		}
+		target := path(out.name)
+		if err := os.WriteFile(target, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", target, err)
+		}
+		if written != nil {
+			written(out.name, target)
+		}
+	}
+	return nil
+}
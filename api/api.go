@@ -0,0 +1,237 @@
+// Package api serves the most recent collected snapshot over HTTP with
+// filtering and pagination, so frontends don't have to read raw JSON files.
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"algotradar/domain"
+	"algotradar/output"
+)
+
+// Page size limits for GET /assets
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// AssetPage is the response body of GET /assets
+type AssetPage struct {
+	Snapshot string         `json:"snapshot"`
+	Total    int            `json:"total"`
+	Offset   int            `json:"offset"`
+	Limit    int            `json:"limit"`
+	Assets   []domain.Asset `json:"assets"`
+}
+
+// Server holds the snapshot being served and reloads it when the file changes
+type Server struct {
+	// Path is the snapshot file, typically the stable name kept by --timestamped-output
+	Path   string
+	Logger *slog.Logger
+
+	mu       sync.Mutex
+	resolved string
+	modTime  time.Time
+	assets   []domain.Asset
+}
+
+// New returns a server for the snapshot at path
+func New(path string) *Server {
+	return &Server{Path: path, Logger: slog.Default()}
+}
+
+// Handler returns the HTTP routes:
+//
+//	GET /assets?country=SA&min_cap=1e9&tier=large&limit=50&offset=100
+//	GET /assets/{ticker}
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /assets", s.handleList)
+	mux.HandleFunc("GET /assets/{ticker}", s.handleTicker)
+	return mux
+}
+
+// Snapshot returns the served file and its assets, re-reading the file if it was replaced
+func (s *Server) Snapshot() (string, []domain.Asset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := output.Resolve(s.Path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("snapshot unavailable: %w", err)
+	}
+	if path == s.resolved && info.ModTime().Equal(s.modTime) {
+		return s.resolved, s.assets, nil
+	}
+
+	data, err := output.ReadFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read snapshot %s: %w", path, err)
+	}
+	var assets []domain.Asset
+	if err := json.Unmarshal(data, &assets); err != nil {
+		return "", nil, fmt.Errorf("failed to parse snapshot %s: %w", path, err)
+	}
+
+	s.resolved, s.modTime, s.assets = path, info.ModTime(), assets
+	s.Logger.Info("snapshot loaded", "file", path, "assets", len(assets))
+	return s.resolved, s.assets, nil
+}
+
+// Filter selects assets by the query parameters of GET /assets
+type Filter struct {
+	Country, AssetType, Sector, Exchange, CapTier string
+	// Query matches a substring of the ticker or name, case-insensitively
+	Query          string
+	MinCap, MaxCap float64
+}
+
+// listQuery is the parsed query of GET /assets
+type listQuery struct {
+	Filter
+	offset, limit int
+}
+
+func parseQuery(q map[string][]string) (listQuery, error) {
+	get := func(key string) string {
+		if v := q[key]; len(v) > 0 {
+			return strings.TrimSpace(v[0])
+		}
+		return ""
+	}
+
+	f := listQuery{
+		Filter: Filter{
+			Country:   get("country"),
+			AssetType: get("type"),
+			Sector:    get("sector"),
+			Exchange:  get("exchange"),
+			CapTier:   get("tier"),
+			Query:     get("q"),
+		},
+		limit: DefaultLimit,
+	}
+
+	var err error
+	if v := get("min_cap"); v != "" {
+		if f.MinCap, err = strconv.ParseFloat(v, 64); err != nil {
+			return f, fmt.Errorf("invalid min_cap %q", v)
+		}
+	}
+	if v := get("max_cap"); v != "" {
+		if f.MaxCap, err = strconv.ParseFloat(v, 64); err != nil {
+			return f, fmt.Errorf("invalid max_cap %q", v)
+		}
+	}
+	if v := get("offset"); v != "" {
+		if f.offset, err = strconv.Atoi(v); err != nil || f.offset < 0 {
+			return f, fmt.Errorf("invalid offset %q", v)
+		}
+	}
+	if v := get("limit"); v != "" {
+		if f.limit, err = strconv.Atoi(v); err != nil || f.limit < 1 {
+			return f, fmt.Errorf("invalid limit %q", v)
+		}
+		f.limit = min(f.limit, MaxLimit)
+	}
+	return f, nil
+}
+
+// Match reports whether an asset passes every set field of the filter
+func (f Filter) Match(a domain.Asset) bool {
+	query := strings.ToLower(f.Query)
+	switch {
+	case f.Country != "" && !strings.EqualFold(a.Country, f.Country):
+		return false
+	case f.AssetType != "" && !strings.EqualFold(a.AssetType, f.AssetType):
+		return false
+	case f.Sector != "" && !strings.EqualFold(a.Sector, f.Sector):
+		return false
+	case f.Exchange != "" && !strings.EqualFold(a.PrimaryExchange, f.Exchange):
+		return false
+	case f.CapTier != "" && !strings.EqualFold(a.CapTier, f.CapTier):
+		return false
+	case f.MinCap > 0 && a.MarketCap < f.MinCap:
+		return false
+	case f.MaxCap > 0 && a.MarketCap > f.MaxCap:
+		return false
+	case query != "" && !strings.Contains(strings.ToLower(a.Ticker), query) && !strings.Contains(strings.ToLower(a.Name), query):
+		return false
+	}
+	return true
+}
+
+// Lookup returns the asset with the given ticker, matched case-insensitively
+func Lookup(assets []domain.Asset, ticker string) (domain.Asset, bool) {
+	for _, asset := range assets {
+		if strings.EqualFold(asset.Ticker, ticker) {
+			return asset, true
+		}
+	}
+	return domain.Asset{}, false
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	f, err := parseQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	path, assets, err := s.Snapshot()
+	if err != nil {
+		s.Logger.Error("failed to load snapshot", "error", err)
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+
+	var matched []domain.Asset
+	for _, asset := range assets {
+		if f.Match(asset) {
+			matched = append(matched, asset)
+		}
+	}
+
+	page := AssetPage{Snapshot: path, Total: len(matched), Offset: f.offset, Limit: f.limit, Assets: []domain.Asset{}}
+	if f.offset < len(matched) {
+		page.Assets = matched[f.offset:min(f.offset+f.limit, len(matched))]
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (s *Server) handleTicker(w http.ResponseWriter, r *http.Request) {
+	ticker := r.PathValue("ticker")
+
+	_, assets, err := s.Snapshot()
+	if err != nil {
+		s.Logger.Error("failed to load snapshot", "error", err)
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+
+	if asset, ok := Lookup(assets, ticker); ok {
+		writeJSON(w, http.StatusOK, asset)
+		return
+	}
+	writeError(w, http.StatusNotFound, fmt.Errorf("ticker %s not found", ticker))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
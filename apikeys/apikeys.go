@@ -0,0 +1,201 @@
+// Package apikeys spreads FMP requests over a pool of API keys. Keys are used
+// round-robin; a key the API rejects (401/403) is disabled for the rest of the
+// run and one whose daily quota is gone is retired, so heavy runs carry on
+// with the remaining keys.
+package apikeys
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"algotradar/metrics"
+)
+
+// ErrNoKeys is returned once every key in the pool is disabled or exhausted
+var ErrNoKeys = errors.New("no usable FMP API key left")
+
+// Key states reported by Stats
+const (
+	Active    = "active"
+	Disabled  = "disabled"  // rejected as invalid or unauthorized
+	Exhausted = "exhausted" // daily quota used up
+)
+
+// key is one API key and its accounting for this run
+type key struct {
+	value       string
+	state       string
+	calls       int64
+	rateLimited int64
+}
+
+// Pool hands out keys round-robin, skipping disabled and exhausted ones.
+// Methods are safe for concurrent use and on a nil Pool, which has no keys.
+type Pool struct {
+	mu   sync.Mutex
+	keys []*key
+	next int
+}
+
+// KeyStats is the accounting for one key; Key is masked
+type KeyStats struct {
+	Key         string `json:"key"`
+	State       string `json:"state"`
+	Calls       int64  `json:"calls"`
+	RateLimited int64  `json:"rate_limited"`
+}
+
+// Parse builds a pool from keys separated by commas or whitespace; duplicates
+// are dropped
+func Parse(list string) *Pool {
+	p := &Pool{}
+	seen := make(map[string]bool)
+	for _, value := range strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	}) {
+		if !seen[value] {
+			seen[value] = true
+			p.keys = append(p.keys, &key{value: value, state: Active})
+		}
+	}
+	return p
+}
+
+// FromEnv reads the pool from FMP_API_KEYS, falling back to FMP_API_KEY
+// (which may also list several keys)
+func FromEnv() *Pool {
+	if list := os.Getenv("FMP_API_KEYS"); strings.TrimSpace(list) != "" {
+		return Parse(list)
+	}
+	return Parse(os.Getenv("FMP_API_KEY"))
+}
+
+// Len returns the number of keys, usable or not
+func (p *Pool) Len() int {
+	if p == nil {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.keys)
+}
+
+// Next returns the next usable key and counts a call against it
+func (p *Pool) Next() (string, error) {
+	if p == nil {
+		return "", ErrNoKeys
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for range p.keys {
+		k := p.keys[p.next]
+		p.next = (p.next + 1) % len(p.keys)
+		if k.state == Active {
+			k.calls++
+			metrics.APIKeyCalls.Inc(Mask(k.value))
+			return k.value, nil
+		}
+	}
+	return "", ErrNoKeys
+}
+
+// RateLimited counts a per-minute 429 against value
+func (p *Pool) RateLimited(value string) {
+	if k := p.find(value); k != nil {
+		p.mu.Lock()
+		k.rateLimited++
+		p.mu.Unlock()
+	}
+}
+
+// Disable stops using value after the API rejected it. It reports whether the
+// key was active, so callers log each key once.
+func (p *Pool) Disable(value string) bool {
+	return p.retire(value, Disabled)
+}
+
+// Exhaust stops using value once its daily quota is gone. It reports whether
+// the key was active.
+func (p *Pool) Exhaust(value string) bool {
+	return p.retire(value, Exhausted)
+}
+
+func (p *Pool) retire(value, state string) bool {
+	k := p.find(value)
+	if k == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if k.state != Active {
+		return false
+	}
+	k.state = state
+	metrics.APIKeysRetired.Inc(state)
+	return true
+}
+
+// Usable returns how many keys can still be used
+func (p *Pool) Usable() int {
+	if p == nil {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, k := range p.keys {
+		if k.state == Active {
+			n++
+		}
+	}
+	return n
+}
+
+// Stats returns each key's accounting in pool order
+func (p *Pool) Stats() []KeyStats {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make([]KeyStats, len(p.keys))
+	for i, k := range p.keys {
+		stats[i] = KeyStats{Key: Mask(k.value), State: k.state, Calls: k.calls, RateLimited: k.rateLimited}
+	}
+	return stats
+}
+
+func (p *Pool) find(value string) *key {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, k := range p.keys {
+		if k.value == value {
+			return k
+		}
+	}
+	return nil
+}
+
+// LogUsage logs each key's calls and state when the pool has several
+func (p *Pool) LogUsage(logger *slog.Logger) {
+	if p.Len() < 2 {
+		return
+	}
+	for _, stats := range p.Stats() {
+		logger.Info("API key usage", "key", stats.Key, "state", stats.State, "calls", stats.Calls, "rate_limited", stats.RateLimited)
+	}
+}
+
+// Mask hides all but the last four characters of a key for logs and metrics
+func Mask(value string) string {
+	if len(value) <= 4 {
+		return "****"
+	}
+	return "****" + value[len(value)-4:]
+}
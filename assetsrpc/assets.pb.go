@@ -0,0 +1,683 @@
+// Asset snapshot service for internal consumers. Regenerate the Go code with
+// `buf generate` from the repository root after editing.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: assetsrpc/assets.proto
+
+package assetsrpc
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Asset is one row of a collected snapshot, mirroring the JSON output files.
+type Asset struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Ticker string                 `protobuf:"bytes,1,opt,name=ticker,proto3" json:"ticker,omitempty"`
+	Name   string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// Market capitalization in USD.
+	MarketCap float64 `protobuf:"fixed64,3,opt,name=market_cap,json=marketCap,proto3" json:"market_cap,omitempty"`
+	// Prices are in the listing's local currency.
+	CurrentPrice     float64  `protobuf:"fixed64,4,opt,name=current_price,json=currentPrice,proto3" json:"current_price,omitempty"`
+	PreviousClose    float64  `protobuf:"fixed64,5,opt,name=previous_close,json=previousClose,proto3" json:"previous_close,omitempty"`
+	PercentageChange float64  `protobuf:"fixed64,6,opt,name=percentage_change,json=percentageChange,proto3" json:"percentage_change,omitempty"`
+	Volume           float64  `protobuf:"fixed64,7,opt,name=volume,proto3" json:"volume,omitempty"`
+	PrimaryExchange  string   `protobuf:"bytes,8,opt,name=primary_exchange,json=primaryExchange,proto3" json:"primary_exchange,omitempty"`
+	Country          string   `protobuf:"bytes,9,opt,name=country,proto3" json:"country,omitempty"`
+	Sector           string   `protobuf:"bytes,10,opt,name=sector,proto3" json:"sector,omitempty"`
+	Industry         string   `protobuf:"bytes,11,opt,name=industry,proto3" json:"industry,omitempty"`
+	AssetType        string   `protobuf:"bytes,12,opt,name=asset_type,json=assetType,proto3" json:"asset_type,omitempty"`
+	Image            string   `protobuf:"bytes,13,opt,name=image,proto3" json:"image,omitempty"`
+	DividendYield    *float64 `protobuf:"fixed64,14,opt,name=dividend_yield,json=dividendYield,proto3,oneof" json:"dividend_yield,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *Asset) Reset() {
+	*x = Asset{}
+	mi := &file_assetsrpc_assets_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Asset) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Asset) ProtoMessage() {}
+
+func (x *Asset) ProtoReflect() protoreflect.Message {
+	mi := &file_assetsrpc_assets_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Asset.ProtoReflect.Descriptor instead.
+func (*Asset) Descriptor() ([]byte, []int) {
+	return file_assetsrpc_assets_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Asset) GetTicker() string {
+	if x != nil {
+		return x.Ticker
+	}
+	return ""
+}
+
+func (x *Asset) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Asset) GetMarketCap() float64 {
+	if x != nil {
+		return x.MarketCap
+	}
+	return 0
+}
+
+func (x *Asset) GetCurrentPrice() float64 {
+	if x != nil {
+		return x.CurrentPrice
+	}
+	return 0
+}
+
+func (x *Asset) GetPreviousClose() float64 {
+	if x != nil {
+		return x.PreviousClose
+	}
+	return 0
+}
+
+func (x *Asset) GetPercentageChange() float64 {
+	if x != nil {
+		return x.PercentageChange
+	}
+	return 0
+}
+
+func (x *Asset) GetVolume() float64 {
+	if x != nil {
+		return x.Volume
+	}
+	return 0
+}
+
+func (x *Asset) GetPrimaryExchange() string {
+	if x != nil {
+		return x.PrimaryExchange
+	}
+	return ""
+}
+
+func (x *Asset) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+func (x *Asset) GetSector() string {
+	if x != nil {
+		return x.Sector
+	}
+	return ""
+}
+
+func (x *Asset) GetIndustry() string {
+	if x != nil {
+		return x.Industry
+	}
+	return ""
+}
+
+func (x *Asset) GetAssetType() string {
+	if x != nil {
+		return x.AssetType
+	}
+	return ""
+}
+
+func (x *Asset) GetImage() string {
+	if x != nil {
+		return x.Image
+	}
+	return ""
+}
+
+func (x *Asset) GetDividendYield() float64 {
+	if x != nil && x.DividendYield != nil {
+		return *x.DividendYield
+	}
+	return 0
+}
+
+type ListAssetsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Filters are exact, case-insensitive matches; unset fields match everything.
+	Country      string  `protobuf:"bytes,1,opt,name=country,proto3" json:"country,omitempty"`
+	AssetType    string  `protobuf:"bytes,2,opt,name=asset_type,json=assetType,proto3" json:"asset_type,omitempty"`
+	Sector       string  `protobuf:"bytes,3,opt,name=sector,proto3" json:"sector,omitempty"`
+	Exchange     string  `protobuf:"bytes,4,opt,name=exchange,proto3" json:"exchange,omitempty"`
+	MinMarketCap float64 `protobuf:"fixed64,5,opt,name=min_market_cap,json=minMarketCap,proto3" json:"min_market_cap,omitempty"`
+	MaxMarketCap float64 `protobuf:"fixed64,6,opt,name=max_market_cap,json=maxMarketCap,proto3" json:"max_market_cap,omitempty"`
+	// Substring of the ticker or name.
+	Query string `protobuf:"bytes,7,opt,name=query,proto3" json:"query,omitempty"`
+	// Defaults to 100, capped at 1000.
+	PageSize int32 `protobuf:"varint,8,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	// next_page_token from a previous response.
+	PageToken     string `protobuf:"bytes,9,opt,name=page_token,json=pageToken,proto3" json:"page_token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAssetsRequest) Reset() {
+	*x = ListAssetsRequest{}
+	mi := &file_assetsrpc_assets_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAssetsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAssetsRequest) ProtoMessage() {}
+
+func (x *ListAssetsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_assetsrpc_assets_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAssetsRequest.ProtoReflect.Descriptor instead.
+func (*ListAssetsRequest) Descriptor() ([]byte, []int) {
+	return file_assetsrpc_assets_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListAssetsRequest) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+func (x *ListAssetsRequest) GetAssetType() string {
+	if x != nil {
+		return x.AssetType
+	}
+	return ""
+}
+
+func (x *ListAssetsRequest) GetSector() string {
+	if x != nil {
+		return x.Sector
+	}
+	return ""
+}
+
+func (x *ListAssetsRequest) GetExchange() string {
+	if x != nil {
+		return x.Exchange
+	}
+	return ""
+}
+
+func (x *ListAssetsRequest) GetMinMarketCap() float64 {
+	if x != nil {
+		return x.MinMarketCap
+	}
+	return 0
+}
+
+func (x *ListAssetsRequest) GetMaxMarketCap() float64 {
+	if x != nil {
+		return x.MaxMarketCap
+	}
+	return 0
+}
+
+func (x *ListAssetsRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *ListAssetsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+func (x *ListAssetsRequest) GetPageToken() string {
+	if x != nil {
+		return x.PageToken
+	}
+	return ""
+}
+
+type ListAssetsResponse struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Assets []*Asset               `protobuf:"bytes,1,rep,name=assets,proto3" json:"assets,omitempty"`
+	// Number of assets matching the filters across all pages.
+	Total int32 `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	// Empty on the last page.
+	NextPageToken string `protobuf:"bytes,3,opt,name=next_page_token,json=nextPageToken,proto3" json:"next_page_token,omitempty"`
+	// File the assets were read from.
+	Snapshot      string `protobuf:"bytes,4,opt,name=snapshot,proto3" json:"snapshot,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListAssetsResponse) Reset() {
+	*x = ListAssetsResponse{}
+	mi := &file_assetsrpc_assets_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAssetsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAssetsResponse) ProtoMessage() {}
+
+func (x *ListAssetsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_assetsrpc_assets_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListAssetsResponse.ProtoReflect.Descriptor instead.
+func (*ListAssetsResponse) Descriptor() ([]byte, []int) {
+	return file_assetsrpc_assets_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListAssetsResponse) GetAssets() []*Asset {
+	if x != nil {
+		return x.Assets
+	}
+	return nil
+}
+
+func (x *ListAssetsResponse) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *ListAssetsResponse) GetNextPageToken() string {
+	if x != nil {
+		return x.NextPageToken
+	}
+	return ""
+}
+
+func (x *ListAssetsResponse) GetSnapshot() string {
+	if x != nil {
+		return x.Snapshot
+	}
+	return ""
+}
+
+type GetAssetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ticker        string                 `protobuf:"bytes,1,opt,name=ticker,proto3" json:"ticker,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetAssetRequest) Reset() {
+	*x = GetAssetRequest{}
+	mi := &file_assetsrpc_assets_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetAssetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetAssetRequest) ProtoMessage() {}
+
+func (x *GetAssetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_assetsrpc_assets_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetAssetRequest.ProtoReflect.Descriptor instead.
+func (*GetAssetRequest) Descriptor() ([]byte, []int) {
+	return file_assetsrpc_assets_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetAssetRequest) GetTicker() string {
+	if x != nil {
+		return x.Ticker
+	}
+	return ""
+}
+
+type StreamRunProgressRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only events with a greater id are sent; 0 replays the retained history.
+	AfterId       int64 `protobuf:"varint,1,opt,name=after_id,json=afterId,proto3" json:"after_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamRunProgressRequest) Reset() {
+	*x = StreamRunProgressRequest{}
+	mi := &file_assetsrpc_assets_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamRunProgressRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamRunProgressRequest) ProtoMessage() {}
+
+func (x *StreamRunProgressRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_assetsrpc_assets_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamRunProgressRequest.ProtoReflect.Descriptor instead.
+func (*StreamRunProgressRequest) Descriptor() ([]byte, []int) {
+	return file_assetsrpc_assets_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *StreamRunProgressRequest) GetAfterId() int64 {
+	if x != nil {
+		return x.AfterId
+	}
+	return 0
+}
+
+// ProgressEvent mirrors the events of the collector's /events SSE stream.
+type ProgressEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Time  *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=time,proto3" json:"time,omitempty"`
+	// run_started, stage_started, country_completed, symbols_processed, error or run_finished.
+	Type          string `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
+	Stage         string `protobuf:"bytes,4,opt,name=stage,proto3" json:"stage,omitempty"`
+	Country       string `protobuf:"bytes,5,opt,name=country,proto3" json:"country,omitempty"`
+	Count         int32  `protobuf:"varint,6,opt,name=count,proto3" json:"count,omitempty"`
+	Done          int32  `protobuf:"varint,7,opt,name=done,proto3" json:"done,omitempty"`
+	Total         int32  `protobuf:"varint,8,opt,name=total,proto3" json:"total,omitempty"`
+	Message       string `protobuf:"bytes,9,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProgressEvent) Reset() {
+	*x = ProgressEvent{}
+	mi := &file_assetsrpc_assets_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProgressEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProgressEvent) ProtoMessage() {}
+
+func (x *ProgressEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_assetsrpc_assets_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProgressEvent.ProtoReflect.Descriptor instead.
+func (*ProgressEvent) Descriptor() ([]byte, []int) {
+	return file_assetsrpc_assets_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ProgressEvent) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *ProgressEvent) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *ProgressEvent) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *ProgressEvent) GetStage() string {
+	if x != nil {
+		return x.Stage
+	}
+	return ""
+}
+
+func (x *ProgressEvent) GetCountry() string {
+	if x != nil {
+		return x.Country
+	}
+	return ""
+}
+
+func (x *ProgressEvent) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *ProgressEvent) GetDone() int32 {
+	if x != nil {
+		return x.Done
+	}
+	return 0
+}
+
+func (x *ProgressEvent) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *ProgressEvent) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+var File_assetsrpc_assets_proto protoreflect.FileDescriptor
+
+const file_assetsrpc_assets_proto_rawDesc = "" +
+	"\n" +
+	"\x16assetsrpc/assets.proto\x12\x14algotradar.assets.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xd0\x03\n" +
+	"\x05Asset\x12\x16\n" +
+	"\x06ticker\x18\x01 \x01(\tR\x06ticker\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x1d\n" +
+	"\n" +
+	"market_cap\x18\x03 \x01(\x01R\tmarketCap\x12#\n" +
+	"\rcurrent_price\x18\x04 \x01(\x01R\fcurrentPrice\x12%\n" +
+	"\x0eprevious_close\x18\x05 \x01(\x01R\rpreviousClose\x12+\n" +
+	"\x11percentage_change\x18\x06 \x01(\x01R\x10percentageChange\x12\x16\n" +
+	"\x06volume\x18\a \x01(\x01R\x06volume\x12)\n" +
+	"\x10primary_exchange\x18\b \x01(\tR\x0fprimaryExchange\x12\x18\n" +
+	"\acountry\x18\t \x01(\tR\acountry\x12\x16\n" +
+	"\x06sector\x18\n" +
+	" \x01(\tR\x06sector\x12\x1a\n" +
+	"\bindustry\x18\v \x01(\tR\bindustry\x12\x1d\n" +
+	"\n" +
+	"asset_type\x18\f \x01(\tR\tassetType\x12\x14\n" +
+	"\x05image\x18\r \x01(\tR\x05image\x12*\n" +
+	"\x0edividend_yield\x18\x0e \x01(\x01H\x00R\rdividendYield\x88\x01\x01B\x11\n" +
+	"\x0f_dividend_yield\"\x9e\x02\n" +
+	"\x11ListAssetsRequest\x12\x18\n" +
+	"\acountry\x18\x01 \x01(\tR\acountry\x12\x1d\n" +
+	"\n" +
+	"asset_type\x18\x02 \x01(\tR\tassetType\x12\x16\n" +
+	"\x06sector\x18\x03 \x01(\tR\x06sector\x12\x1a\n" +
+	"\bexchange\x18\x04 \x01(\tR\bexchange\x12$\n" +
+	"\x0emin_market_cap\x18\x05 \x01(\x01R\fminMarketCap\x12$\n" +
+	"\x0emax_market_cap\x18\x06 \x01(\x01R\fmaxMarketCap\x12\x14\n" +
+	"\x05query\x18\a \x01(\tR\x05query\x12\x1b\n" +
+	"\tpage_size\x18\b \x01(\x05R\bpageSize\x12\x1d\n" +
+	"\n" +
+	"page_token\x18\t \x01(\tR\tpageToken\"\xa3\x01\n" +
+	"\x12ListAssetsResponse\x123\n" +
+	"\x06assets\x18\x01 \x03(\v2\x1b.algotradar.assets.v1.AssetR\x06assets\x12\x14\n" +
+	"\x05total\x18\x02 \x01(\x05R\x05total\x12&\n" +
+	"\x0fnext_page_token\x18\x03 \x01(\tR\rnextPageToken\x12\x1a\n" +
+	"\bsnapshot\x18\x04 \x01(\tR\bsnapshot\")\n" +
+	"\x0fGetAssetRequest\x12\x16\n" +
+	"\x06ticker\x18\x01 \x01(\tR\x06ticker\"5\n" +
+	"\x18StreamRunProgressRequest\x12\x19\n" +
+	"\bafter_id\x18\x01 \x01(\x03R\aafterId\"\xed\x01\n" +
+	"\rProgressEvent\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12.\n" +
+	"\x04time\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x12\n" +
+	"\x04type\x18\x03 \x01(\tR\x04type\x12\x14\n" +
+	"\x05stage\x18\x04 \x01(\tR\x05stage\x12\x18\n" +
+	"\acountry\x18\x05 \x01(\tR\acountry\x12\x14\n" +
+	"\x05count\x18\x06 \x01(\x05R\x05count\x12\x12\n" +
+	"\x04done\x18\a \x01(\x05R\x04done\x12\x14\n" +
+	"\x05total\x18\b \x01(\x05R\x05total\x12\x18\n" +
+	"\amessage\x18\t \x01(\tR\amessage2\xab\x02\n" +
+	"\fAssetService\x12_\n" +
+	"\n" +
+	"ListAssets\x12'.algotradar.assets.v1.ListAssetsRequest\x1a(.algotradar.assets.v1.ListAssetsResponse\x12N\n" +
+	"\bGetAsset\x12%.algotradar.assets.v1.GetAssetRequest\x1a\x1b.algotradar.assets.v1.Asset\x12j\n" +
+	"\x11StreamRunProgress\x12..algotradar.assets.v1.StreamRunProgressRequest\x1a#.algotradar.assets.v1.ProgressEvent0\x01B\x16Z\x14algotradar/assetsrpcb\x06proto3"
+
+var (
+	file_assetsrpc_assets_proto_rawDescOnce sync.Once
+	file_assetsrpc_assets_proto_rawDescData []byte
+)
+
+func file_assetsrpc_assets_proto_rawDescGZIP() []byte {
+	file_assetsrpc_assets_proto_rawDescOnce.Do(func() {
+		file_assetsrpc_assets_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_assetsrpc_assets_proto_rawDesc), len(file_assetsrpc_assets_proto_rawDesc)))
+	})
+	return file_assetsrpc_assets_proto_rawDescData
+}
+
+var file_assetsrpc_assets_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_assetsrpc_assets_proto_goTypes = []any{
+	(*Asset)(nil),                    // 0: algotradar.assets.v1.Asset
+	(*ListAssetsRequest)(nil),        // 1: algotradar.assets.v1.ListAssetsRequest
+	(*ListAssetsResponse)(nil),       // 2: algotradar.assets.v1.ListAssetsResponse
+	(*GetAssetRequest)(nil),          // 3: algotradar.assets.v1.GetAssetRequest
+	(*StreamRunProgressRequest)(nil), // 4: algotradar.assets.v1.StreamRunProgressRequest
+	(*ProgressEvent)(nil),            // 5: algotradar.assets.v1.ProgressEvent
+	(*timestamppb.Timestamp)(nil),    // 6: google.protobuf.Timestamp
+}
+var file_assetsrpc_assets_proto_depIdxs = []int32{
+	0, // 0: algotradar.assets.v1.ListAssetsResponse.assets:type_name -> algotradar.assets.v1.Asset
+	6, // 1: algotradar.assets.v1.ProgressEvent.time:type_name -> google.protobuf.Timestamp
+	1, // 2: algotradar.assets.v1.AssetService.ListAssets:input_type -> algotradar.assets.v1.ListAssetsRequest
+	3, // 3: algotradar.assets.v1.AssetService.GetAsset:input_type -> algotradar.assets.v1.GetAssetRequest
+	4, // 4: algotradar.assets.v1.AssetService.StreamRunProgress:input_type -> algotradar.assets.v1.StreamRunProgressRequest
+	2, // 5: algotradar.assets.v1.AssetService.ListAssets:output_type -> algotradar.assets.v1.ListAssetsResponse
+	0, // 6: algotradar.assets.v1.AssetService.GetAsset:output_type -> algotradar.assets.v1.Asset
+	5, // 7: algotradar.assets.v1.AssetService.StreamRunProgress:output_type -> algotradar.assets.v1.ProgressEvent
+	5, // [5:8] is the sub-list for method output_type
+	2, // [2:5] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_assetsrpc_assets_proto_init() }
+func file_assetsrpc_assets_proto_init() {
+	if File_assetsrpc_assets_proto != nil {
+		return
+	}
+	file_assetsrpc_assets_proto_msgTypes[0].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_assetsrpc_assets_proto_rawDesc), len(file_assetsrpc_assets_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_assetsrpc_assets_proto_goTypes,
+		DependencyIndexes: file_assetsrpc_assets_proto_depIdxs,
+		MessageInfos:      file_assetsrpc_assets_proto_msgTypes,
+	}.Build()
+	File_assetsrpc_assets_proto = out.File
+	file_assetsrpc_assets_proto_goTypes = nil
+	file_assetsrpc_assets_proto_depIdxs = nil
+}
@@ -0,0 +1,163 @@
+package assetsrpc
+
+import (
+	"context"
+	"strconv"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"algotradar/api"
+	"algotradar/domain"
+	"algotradar/progress"
+)
+
+// Server implements AssetService on top of the REST server's snapshot loader
+// and a progress broker
+type Server struct {
+	UnimplementedAssetServiceServer
+
+	// Snapshots serves ListAssets and GetAsset; nil makes them unavailable
+	Snapshots *api.Server
+	// Progress feeds StreamRunProgress; nil makes it unavailable
+	Progress *progress.Broker
+}
+
+// Register adds a Server for the given sources to a gRPC server
+func Register(s grpc.ServiceRegistrar, snapshots *api.Server, broker *progress.Broker) {
+	RegisterAssetServiceServer(s, &Server{Snapshots: snapshots, Progress: broker})
+}
+
+func (s *Server) snapshot() (string, []domain.Asset, error) {
+	if s.Snapshots == nil {
+		return "", nil, status.Error(codes.Unimplemented, "this server does not serve snapshots")
+	}
+	path, assets, err := s.Snapshots.Snapshot()
+	if err != nil {
+		return "", nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return path, assets, nil
+}
+
+// ListAssets returns a filtered page of the latest snapshot
+func (s *Server) ListAssets(_ context.Context, req *ListAssetsRequest) (*ListAssetsResponse, error) {
+	offset := 0
+	if req.PageToken != "" {
+		var err error
+		if offset, err = strconv.Atoi(req.PageToken); err != nil || offset < 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid page_token %q", req.PageToken)
+		}
+	}
+	limit := int(req.PageSize)
+	if limit <= 0 {
+		limit = api.DefaultLimit
+	}
+	limit = min(limit, api.MaxLimit)
+
+	path, assets, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	filter := api.Filter{
+		Country:   req.Country,
+		AssetType: req.AssetType,
+		Sector:    req.Sector,
+		Exchange:  req.Exchange,
+		Query:     req.Query,
+		MinCap:    req.MinMarketCap,
+		MaxCap:    req.MaxMarketCap,
+	}
+
+	resp := &ListAssetsResponse{Snapshot: path}
+	matched := 0
+	for _, asset := range assets {
+		if !filter.Match(asset) {
+			continue
+		}
+		if matched >= offset && matched < offset+limit {
+			resp.Assets = append(resp.Assets, ToProto(asset))
+		}
+		matched++
+	}
+	resp.Total = int32(matched)
+	if offset+limit < matched {
+		resp.NextPageToken = strconv.Itoa(offset + limit)
+	}
+	return resp, nil
+}
+
+// GetAsset returns one asset of the latest snapshot by ticker
+func (s *Server) GetAsset(_ context.Context, req *GetAssetRequest) (*Asset, error) {
+	_, assets, err := s.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	asset, ok := api.Lookup(assets, req.Ticker)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "ticker %s not found", req.Ticker)
+	}
+	return ToProto(asset), nil
+}
+
+// StreamRunProgress relays progress events until the client goes away
+func (s *Server) StreamRunProgress(req *StreamRunProgressRequest, stream grpc.ServerStreamingServer[ProgressEvent]) error {
+	if s.Progress == nil {
+		return status.Error(codes.Unimplemented, "this server does not stream run progress")
+	}
+
+	past, events, cancel := s.Progress.Subscribe(req.AfterId)
+	defer cancel()
+
+	for _, e := range past {
+		if err := stream.Send(eventToProto(e)); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case e := <-events:
+			if err := stream.Send(eventToProto(e)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// ToProto converts a snapshot row to its protobuf message
+func ToProto(a domain.Asset) *Asset {
+	return &Asset{
+		Ticker:           a.Ticker,
+		Name:             a.Name,
+		MarketCap:        a.MarketCap,
+		CurrentPrice:     a.CurrentPrice,
+		PreviousClose:    a.PreviousClose,
+		PercentageChange: a.PercentageChange,
+		Volume:           a.Volume,
+		PrimaryExchange:  a.PrimaryExchange,
+		Country:          a.Country,
+		Sector:           a.Sector,
+		Industry:         a.Industry,
+		AssetType:        a.AssetType,
+		Image:            a.Image,
+		DividendYield:    a.DividendYield,
+	}
+}
+
+func eventToProto(e progress.Event) *ProgressEvent {
+	return &ProgressEvent{
+		Id:      e.ID,
+		Time:    timestamppb.New(e.Time),
+		Type:    e.Type,
+		Stage:   e.Stage,
+		Country: e.Country,
+		Count:   int32(e.Count),
+		Done:    int32(e.Done),
+		Total:   int32(e.Total),
+		Message: e.Message,
+	}
+}
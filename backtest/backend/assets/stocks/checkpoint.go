@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"algotradar/domain"
+)
+
+// DefaultCheckpointPath is where a run stopped by the daily quota saves its progress
+const DefaultCheckpointPath = ".fmp_checkpoint/fmp_us.json"
+
+// Checkpoint holds the API responses a run fetched before it had to stop, so
+// the next run only requests what is still missing. All methods are safe on a
+// nil checkpoint, which disables checkpointing.
+type Checkpoint struct {
+	Saved    time.Time                 `json:"saved"`
+	Stocks   []StockListResponse       `json:"stocks,omitempty"`
+	Quotes   map[string]domain.Quote   `json:"quotes"`
+	Profiles map[string]domain.Profile `json:"profiles"`
+
+	mu sync.Mutex
+}
+
+// NewCheckpoint returns an empty checkpoint
+func NewCheckpoint() *Checkpoint {
+	return &Checkpoint{
+		Quotes:   make(map[string]domain.Quote),
+		Profiles: make(map[string]domain.Profile),
+	}
+}
+
+// LoadCheckpoint reads the checkpoint at path. A missing file, or one older
+// than maxAge (zero means no limit), yields an empty checkpoint.
+func LoadCheckpoint(path string, maxAge time.Duration) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return NewCheckpoint(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	cp := NewCheckpoint()
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %w", path, err)
+	}
+	if maxAge > 0 && time.Since(cp.Saved) > maxAge {
+		return NewCheckpoint(), nil
+	}
+	if cp.Quotes == nil {
+		cp.Quotes = make(map[string]domain.Quote)
+	}
+	if cp.Profiles == nil {
+		cp.Profiles = make(map[string]domain.Profile)
+	}
+	return cp, nil
+}
+
+// Save writes the checkpoint to path, replacing any earlier one
+func (cp *Checkpoint) Save(path string) error {
+	if cp == nil {
+		return nil
+	}
+	cp.mu.Lock()
+	cp.Saved = time.Now().UTC()
+	data, err := json.Marshal(cp)
+	cp.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Empty reports whether the checkpoint holds nothing to resume from
+func (cp *Checkpoint) Empty() bool {
+	if cp == nil {
+		return true
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return len(cp.Stocks) == 0 && len(cp.Quotes) == 0 && len(cp.Profiles) == 0
+}
+
+// StockList returns the saved stock list, if any
+func (cp *Checkpoint) StockList() []StockListResponse {
+	if cp == nil {
+		return nil
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return cp.Stocks
+}
+
+// SetStockList records the fetched stock list
+func (cp *Checkpoint) SetStockList(stocks []StockListResponse) {
+	if cp == nil {
+		return
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.Stocks = stocks
+}
+
+// SplitQuotes returns the saved quotes for symbols and the symbols still to fetch
+func (cp *Checkpoint) SplitQuotes(symbols []string) (saved []domain.Quote, missing []string) {
+	if cp == nil {
+		return nil, symbols
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	for _, symbol := range symbols {
+		if quote, ok := cp.Quotes[symbol]; ok {
+			saved = append(saved, quote)
+		} else {
+			missing = append(missing, symbol)
+		}
+	}
+	return saved, missing
+}
+
+// AddQuotes records fetched quotes
+func (cp *Checkpoint) AddQuotes(quotes []domain.Quote) {
+	if cp == nil {
+		return
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	for _, quote := range quotes {
+		cp.Quotes[quote.Symbol] = quote
+	}
+}
+
+// SplitProfiles returns the saved profiles for symbols and the symbols still to fetch
+func (cp *Checkpoint) SplitProfiles(symbols []string) (saved map[string]domain.Profile, missing []string) {
+	saved = make(map[string]domain.Profile)
+	if cp == nil {
+		return saved, symbols
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	for _, symbol := range symbols {
+		if profile, ok := cp.Profiles[symbol]; ok {
+			saved[symbol] = profile
+		} else {
+			missing = append(missing, symbol)
+		}
+	}
+	return saved, missing
+}
+
+// AddProfile records a fetched profile
+func (cp *Checkpoint) AddProfile(symbol string, profile domain.Profile) {
+	if cp == nil {
+		return
+	}
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.Profiles[symbol] = profile
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"algotradar/canonical"
+	"algotradar/domain"
+	"algotradar/marketdata"
+	"algotradar/workpool"
+)
+
+// DefaultFilingsDays is how far back --filings-days looks by default
+const DefaultFilingsDays = 365
+
+// GetFilings fetches the 10-K, 10-Q and 8-K filings each symbol made on or
+// after since, a YYYY-MM-DD date, one symbol at a time. It stops asking
+// once the plan turns out not to include the endpoint. Filings come back
+// ordered by symbol and then newest first.
+func (c *FMPClient) GetFilings(symbols []string, since string) []domain.Filing {
+	var filings []domain.Filing
+	var mu sync.Mutex
+	var notEntitled atomic.Bool
+	pool := c.startPool("sec_filings", 10)
+	workpool.Each(pool, 10, symbols, func(_ int, symbol string) {
+		if notEntitled.Load() {
+			return
+		}
+		found, err := marketdata.FetchFilings(func(path string) ([]byte, error) {
+			return c.makeRequest(c.BaseURL + "/api" + path)
+		}, symbol, since)
+		if errors.Is(err, marketdata.ErrNotEntitled) {
+			if notEntitled.CompareAndSwap(false, true) {
+				c.Logger.Warn("API plan does not include SEC filings, writing none")
+			}
+			return
+		}
+		if err != nil {
+			c.Logger.Warn("failed to fetch SEC filings", "symbol", symbol, "error", err)
+			c.Report.Error(err)
+		}
+		mu.Lock()
+		filings = append(filings, found...)
+		mu.Unlock()
+	})
+	c.logPool(pool)
+
+	sort.Slice(filings, func(i, j int) bool {
+		a, b := filings[i], filings[j]
+		if a.Symbol != b.Symbol {
+			return a.Symbol < b.Symbol
+		}
+		if a.Filed != b.Filed {
+			return a.Filed > b.Filed
+		}
+		return a.Link < b.Link
+	})
+	c.Logger.Info("fetched SEC filings", "filings", len(filings), "symbols", len(symbols), "since", since)
+	return filings
+}
+
+// writeFilings saves filings as canonical JSON
+func writeFilings(path string, filings []domain.Filing) error {
+	if filings == nil {
+		filings = []domain.Filing{}
+	}
+	data, err := canonical.JSON(filings)
+	if err != nil {
+		return fmt.Errorf("failed to marshal SEC filings: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
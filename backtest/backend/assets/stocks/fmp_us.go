@@ -1,71 +1,101 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
-	"sort"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/joho/godotenv"
-)
 
-// Asset represents a financial asset from FMP API
-type Asset struct {
-	Symbol        string  `json:"symbol"`
-	Name          string  `json:"name"`
-	Price         float64 `json:"price"`
-	MarketCap     float64 `json:"marketCap"`
-	Exchange      string  `json:"exchange"`
-	Type          string  `json:"type"` // stock, etf, commodity
-	Currency      string  `json:"currency"`
-	Country       string  `json:"country"`
-	Sector        string  `json:"sector"`
-	Industry      string  `json:"industry"`
-	Volume        int64   `json:"volume"`
-	AvgVolume     float64 `json:"avgVolume"`
-	Beta          float64 `json:"beta"`
-	PE            float64 `json:"pe"`
-	EPS           float64 `json:"eps"`
-	DividendYield float64 `json:"dividendYield"`
-	PreviousClose float64 `json:"previousClose,omitempty"` // Add previous close if available
-	Image         string  `json:"image,omitempty"`         // Company logo/image URL
-}
-
-// SupabaseUSAsset represents the Supabase-compatible format for US assets
-type SupabaseUSAsset struct {
-	Symbol           string  `json:"symbol"`
-	Ticker           string  `json:"ticker"`
-	Name             string  `json:"name"`
-	CurrentPrice     float64 `json:"current_price"`
-	PreviousClose    float64 `json:"previous_close,omitempty"`
-	PercentageChange float64 `json:"percentage_change,omitempty"`
-	MarketCap        int64   `json:"market_cap"`
-	Volume           int64   `json:"volume"`
-	PrimaryExchange  string  `json:"primary_exchange"`
-	Country          string  `json:"country"`
-	Sector           string  `json:"sector"`
-	Industry         string  `json:"industry"`
-	AssetType        string  `json:"asset_type"`
-	Rank             int     `json:"rank"`
-	SnapshotDate     string  `json:"snapshot_date"`
-	DataSource       string  `json:"data_source"`
-	PriceRaw         float64 `json:"price_raw,omitempty"`
-	MarketCapRaw     int64   `json:"market_cap_raw,omitempty"`
-	Category         string  `json:"category,omitempty"`
-	Image            string  `json:"image,omitempty"` // Add Image field
-}
+	"algotradar/aggregate"
+	"algotradar/apikeys"
+	"algotradar/domain"
+	"algotradar/enrich"
+	"algotradar/exclusion"
+	"algotradar/fx"
+	"algotradar/httpcache"
+	"algotradar/instrument"
+	"algotradar/integrity"
+	"algotradar/logging"
+	"algotradar/marketdata"
+	"algotradar/metrics"
+	"algotradar/notify"
+	"algotradar/output"
+	"algotradar/ratelimit"
+	"algotradar/refdata"
+	"algotradar/registry"
+	"algotradar/replay"
+	"algotradar/runreport"
+	"algotradar/secrets"
+	"algotradar/sink"
+	"algotradar/taxonomy"
+	"algotradar/workpool"
+)
 
 // FMPClient handles API calls to Financial Modeling Prep
 type FMPClient struct {
-	APIKey     string
+	Keys       *apikeys.Pool
 	BaseURL    string
 	HTTPClient *http.Client
+	Logger     *slog.Logger
+	Report     *runreport.Recorder
+
+	// Checkpoint, when set, supplies quotes and profiles saved by an earlier
+	// run and records new ones so they survive daily quota exhaustion
+	Checkpoint *Checkpoint
+
+	// SnapshotTime is when each row's IsMarketOpen is evaluated
+	SnapshotTime time.Time
+
+	// RecentIPODays is how many days after its profile's IPO date a row
+	// counts as a recent IPO; 0 means domain.RecentIPODays
+	RecentIPODays int
+
+	// SharesFloat fetches share counts from the bulk shares-float endpoint,
+	// for the rows' float and free-float market cap
+	SharesFloat bool
+
+	// Enrichments are the optional enrichments to perform, e.g.
+	// enrich.Technicals
+	Enrichments enrich.Set
+
+	// ExtendedHours fetches the last pre- and post-market trades, for the
+	// rows' extended-hours price and change
+	ExtendedHours bool
+
+	// SPACs is how blank-check companies are treated: tagged, dropped or
+	// kept as ordinary stocks
+	SPACs instrument.SPACPolicy
+
+	// AggregateShareClasses folds an issuer's share classes, such as GOOG
+	// and GOOGL, into one row with their summed market cap
+	AggregateShareClasses bool
+
+	// CapTiers are the boundaries of the rows' cap_tier; zero means
+	// domain.DefaultCapTiers
+	CapTiers domain.CapTiers
+
+	// profiles are the company profiles fetched during collection, reused by
+	// LookupImages
+	profiles map[string]domain.Profile
+
+	// dailyExhausted stops further requests once the daily quota is gone
+	dailyExhausted atomic.Bool
+
+	// pool is the running fetch's worker pool, which shrinks on 429s
+	pool atomic.Pointer[workpool.Pool]
 }
 
 // Response structures for different FMP endpoints
@@ -77,72 +107,166 @@ type StockListResponse struct {
 	Type     string  `json:"type"`
 }
 
-type QuoteResponse struct {
-	Symbol        string  `json:"symbol"`
-	Name          string  `json:"name"`
-	Price         float64 `json:"price"`
-	PreviousClose float64 `json:"previousClose"`
-	MarketCap     float64 `json:"marketCap"`
-	Volume        int64   `json:"volume"`
-	AvgVolume     float64 `json:"avgVolume"`
-	PE            float64 `json:"pe"`
-	EPS           float64 `json:"eps"`
-	Beta          float64 `json:"beta"`
-	DividendYield float64 `json:"dividendYield"`
-	Exchange      string  `json:"exchange"`
-}
-
-type ProfileResponse struct {
-	Symbol      string `json:"symbol"`
-	CompanyName string `json:"companyName"`
-	Currency    string `json:"currency"`
-	Country     string `json:"country"`
-	Sector      string `json:"sector"`
-	Industry    string `json:"industry"`
-	Exchange    string `json:"exchange"`
-	Image       string `json:"image"`
-}
-
-// NewFMPClient creates a new FMP API client
-func NewFMPClient(apiKey string) *FMPClient {
+// errKeyRejected marks a 401/403 response: the key is invalid, revoked or not
+// entitled to the endpoint
+var errKeyRejected = errors.New("API key rejected")
+
+// NewFMPClient creates a new FMP API client that rotates requests across keys
+func NewFMPClient(keys *apikeys.Pool) *FMPClient {
 	return &FMPClient{
-		APIKey:  apiKey,
+		Keys:    keys,
 		BaseURL: "https://financialmodelingprep.com",
 		HTTPClient: &http.Client{
 			Timeout: 120 * time.Second, // Increased timeout for large datasets
 		},
+		Logger: slog.Default(),
+	}
+}
+
+// recentIPODays returns RecentIPODays, or its default when unset
+func (c *FMPClient) recentIPODays() int {
+	if c.RecentIPODays > 0 {
+		return c.RecentIPODays
 	}
+	return domain.RecentIPODays
 }
 
-// makeRequest performs HTTP request with error handling and rate limiting
+// makeRequest performs an HTTP request, waiting out per-minute rate limits as
+// the API's Retry-After asks. A key that is rejected or out of daily quota is
+// retired and the request moves to the next key. Once every key's daily quota
+// is exhausted it fails fast with ratelimit.ErrDailyLimit instead of retrying.
 func (c *FMPClient) makeRequest(url string) ([]byte, error) {
-	resp, err := c.HTTPClient.Get(url)
+	if c.dailyExhausted.Load() {
+		return nil, ratelimit.ErrDailyLimit
+	}
+
+	label := metrics.EndpointLabel(strings.TrimPrefix(url, c.BaseURL))
+	for attempt := 0; ; {
+		key, err := c.Keys.Next()
+		if err != nil {
+			return nil, err
+		}
+		body, limit, err := c.doRequest(url, label, key)
+		if errors.Is(err, errKeyRejected) {
+			if c.Keys.Disable(key) {
+				c.Logger.Error("API key rejected, removing it from rotation", "key", apikeys.Mask(key), "usable", c.Keys.Usable())
+			}
+			if c.Keys.Usable() > 0 {
+				continue
+			}
+			return nil, err
+		}
+		pool := c.pool.Load()
+		if limit == nil {
+			if err == nil {
+				pool.Succeeded()
+			}
+			return body, err
+		}
+
+		metrics.APIRateLimited.Inc(label)
+		if limit.Daily {
+			if c.Keys.Exhaust(key) {
+				c.Logger.Warn("API key daily quota exhausted, removing it from rotation", "key", apikeys.Mask(key), "usable", c.Keys.Usable())
+			}
+			if c.Keys.Usable() > 0 {
+				continue
+			}
+		}
+		c.Keys.RateLimited(key)
+		if pool.Throttled() {
+			c.Logger.Warn("rate limited, reducing concurrency", "pool", pool.Name, "workers", pool.Stats().Limit)
+		}
+		if limit.Daily {
+			if !c.dailyExhausted.Swap(true) {
+				c.Logger.Error("daily API limit exhausted, stopping further requests", "endpoint", label)
+			}
+			return nil, limit.Error(attempt)
+		}
+		if attempt == ratelimit.MaxRetries {
+			return nil, limit.Error(attempt)
+		}
+
+		wait := limit.Wait(attempt)
+		c.Logger.Debug("rate limited, retrying", "endpoint", label, "wait", wait, "attempt", attempt+1)
+		metrics.APIRetries.Inc(label)
+		time.Sleep(wait)
+		attempt++
+	}
+}
+
+// doRequest performs one attempt with key; a 429 response is returned as a
+// non-nil limit
+func (c *FMPClient) doRequest(url, label, key string) ([]byte, *ratelimit.Limit, error) {
+	separator := "?"
+	if strings.Contains(url, "?") {
+		separator = "&"
+	}
+	start := time.Now()
+	resp, err := c.HTTPClient.Get(url + separator + "apikey=" + key)
+	metrics.APIDuration.Observe(time.Since(start).Seconds(), label)
 	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %w", err)
+		metrics.APICalls.Inc(label, "error")
+		return nil, nil, fmt.Errorf("HTTP request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == 429 {
-		// Rate limit hit, wait and retry
-		time.Sleep(1 * time.Second)
-		return c.makeRequest(url)
+	if !httpcache.IsHit(resp) {
+		metrics.APICalls.Inc(label, strconv.Itoa(resp.StatusCode))
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		limit := ratelimit.FromResponse(resp.Header, body, time.Now())
+		return nil, &limit, nil
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if resp.StatusCode == http.StatusForbidden && marketdata.NotEntitled(body) {
+			return nil, nil, fmt.Errorf("API returned status %d: %w", resp.StatusCode, marketdata.ErrNotEntitled)
+		}
+		return nil, nil, fmt.Errorf("API returned status %d: %w", resp.StatusCode, errKeyRejected)
 	}
 
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
+		return nil, nil, fmt.Errorf("API returned status %d", resp.StatusCode)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	return body, nil
+	return body, nil, nil
+}
+
+// startPool returns a worker pool of up to workers goroutines and makes it the
+// one makeRequest reports rate-limit feedback to
+func (c *FMPClient) startPool(name string, workers int) *workpool.Pool {
+	pool := workpool.New(name, 1, workers)
+	c.pool.Store(pool)
+	return pool
+}
+
+// logPool logs a finished pool's effective throughput and concurrency
+func (c *FMPClient) logPool(pool *workpool.Pool) {
+	stats := pool.Stats()
+	c.Logger.Info("worker pool finished", "pool", pool.Name, "completed", stats.Completed,
+		"per_second", fmt.Sprintf("%.1f", stats.PerSecond), "peak", stats.Peak,
+		"final_limit", stats.Limit, "throttles", stats.Throttles)
+}
+
+// DailyLimitHit reports whether the run stopped early on the daily quota
+func (c *FMPClient) DailyLimitHit() bool {
+	return c.dailyExhausted.Load()
 }
 
 // GetAllStocks fetches all stock symbols
 func (c *FMPClient) GetAllStocks() ([]StockListResponse, error) {
-	url := fmt.Sprintf("%s/api/v3/stock/list?apikey=%s", c.BaseURL, c.APIKey)
+	if stocks := c.Checkpoint.StockList(); len(stocks) > 0 {
+		return stocks, nil
+	}
+
+	url := c.BaseURL + "/api/v3/stock/list"
 
 	body, err := c.makeRequest(url)
 	if err != nil {
@@ -154,12 +278,13 @@ func (c *FMPClient) GetAllStocks() ([]StockListResponse, error) {
 		return nil, fmt.Errorf("failed to parse stocks response: %w", err)
 	}
 
+	c.Checkpoint.SetStockList(stocks)
 	return stocks, nil
 }
 
 // GetAllETFs fetches all ETF symbols
 func (c *FMPClient) GetAllETFs() ([]StockListResponse, error) {
-	url := fmt.Sprintf("%s/api/v3/etf/list?apikey=%s", c.BaseURL, c.APIKey)
+	url := c.BaseURL + "/api/v3/etf/list"
 
 	body, err := c.makeRequest(url)
 	if err != nil {
@@ -176,7 +301,7 @@ func (c *FMPClient) GetAllETFs() ([]StockListResponse, error) {
 
 // GetAllCommodities fetches all commodity symbols
 func (c *FMPClient) GetAllCommodities() ([]StockListResponse, error) {
-	url := fmt.Sprintf("%s/api/v3/symbol/available-commodities?apikey=%s", c.BaseURL, c.APIKey)
+	url := c.BaseURL + "/api/v3/symbol/available-commodities"
 
 	body, err := c.makeRequest(url)
 	if err != nil {
@@ -191,150 +316,349 @@ func (c *FMPClient) GetAllCommodities() ([]StockListResponse, error) {
 	return commodities, nil
 }
 
+// usdRates are approximate USD values of one unit of the currencies US
+// listings report in (approximate - in production, use real-time rates)
+var usdRates = map[string]float64{
+	"USD": 1.0,
+	"JPY": 0.0067,  // Japanese Yen
+	"EUR": 1.08,    // Euro
+	"GBP": 1.27,    // British Pound
+	"CAD": 0.73,    // Canadian Dollar
+	"CHF": 1.11,    // Swiss Franc
+	"CNY": 0.138,   // Chinese Yuan
+	"KRW": 0.00075, // Korean Won
+	"ARS": 0.001,   // Argentine Peso
+	"INR": 0.012,   // Indian Rupee
+	"BRL": 0.18,    // Brazilian Real
+	"HKD": 0.128,   // Hong Kong Dollar
+	"SGD": 0.74,    // Singapore Dollar
+	"AUD": 0.66,    // Australian Dollar
+	"MXN": 0.058,   // Mexican Peso
+	"ZAR": 0.055,   // South African Rand
+	"SEK": 0.092,   // Swedish Krona
+	"NOK": 0.092,   // Norwegian Krone
+	"DKK": 0.145,   // Danish Krone
+	"PLN": 0.247,   // Polish Zloty
+	"CZK": 0.043,   // Czech Koruna
+	"HUF": 0.0026,  // Hungarian Forint
+	"RUB": 0.010,   // Russian Ruble
+	"TRY": 0.029,   // Turkish Lira
+}
+
 // ConvertToUSD converts market cap from local currency to USD
 func ConvertToUSD(marketCap float64, currency string) float64 {
-	// Exchange rates (approximate - in production, use real-time rates)
-	rates := map[string]float64{
-		"USD": 1.0,
-		"JPY": 0.0067,  // Japanese Yen
-		"EUR": 1.08,    // Euro
-		"GBP": 1.27,    // British Pound
-		"CAD": 0.73,    // Canadian Dollar
-		"CHF": 1.11,    // Swiss Franc
-		"CNY": 0.138,   // Chinese Yuan
-		"KRW": 0.00075, // Korean Won
-		"ARS": 0.001,   // Argentine Peso
-		"INR": 0.012,   // Indian Rupee
-		"BRL": 0.18,    // Brazilian Real
-		"HKD": 0.128,   // Hong Kong Dollar
-		"SGD": 0.74,    // Singapore Dollar
-		"AUD": 0.66,    // Australian Dollar
-		"MXN": 0.058,   // Mexican Peso
-		"ZAR": 0.055,   // South African Rand
-		"SEK": 0.092,   // Swedish Krona
-		"NOK": 0.092,   // Norwegian Krone
-		"DKK": 0.145,   // Danish Krone
-		"PLN": 0.247,   // Polish Zloty
-		"CZK": 0.043,   // Czech Koruna
-		"HUF": 0.0026,  // Hungarian Forint
-		"RUB": 0.010,   // Russian Ruble
-		"TRY": 0.029,   // Turkish Lira
-	}
-
-	if rate, exists := rates[currency]; exists {
-		return marketCap * rate
-	}
-
-	// If currency not found, assume it's already in USD
-	return marketCap
+	return marketCap * usdRate(currency).Rate
+}
+
+// usdRate returns the static USD rate of currency; an unknown currency is
+// assumed to be USD already
+func usdRate(currency string) domain.FXRate {
+	rate, exists := usdRates[currency]
+	if !exists {
+		rate = 1.0
+	}
+	return domain.FXRate{Currency: currency, Rate: rate, Source: fx.SourceStatic}
 }
 
 // GetQuotes fetches detailed quotes for multiple symbols in parallel
-func (c *FMPClient) GetQuotes(symbols []string) ([]QuoteResponse, error) {
+func (c *FMPClient) GetQuotes(symbols []string) ([]domain.Quote, error) {
 	// Split symbols into batches for batch API calls (FMP supports comma-separated symbols)
 	batchSize := 30 // Reduced for larger responses with PreviousClose data
-	var allQuotes []QuoteResponse
+	allQuotes, symbols := c.Checkpoint.SplitQuotes(symbols)
 	var mu sync.Mutex
-	var wg sync.WaitGroup
-
-	// Channel to limit concurrent requests
-	semaphore := make(chan struct{}, 25) // Max 25 concurrent requests (within 3000/min limit)
 
+	var batches [][]string
 	for i := 0; i < len(symbols); i += batchSize {
 		end := i + batchSize
 		if end > len(symbols) {
 			end = len(symbols)
 		}
+		batches = append(batches, symbols[i:end])
+	}
 
-		batch := symbols[i:end]
+	// Up to 25 concurrent requests (within 3000/min limit), fewer after 429s
+	pool := c.startPool("quotes", 25)
+	workpool.Each(pool, 25, batches, func(_ int, batch []string) {
+		// Join symbols with comma for batch request
+		symbolsStr := ""
+		for j, symbol := range batch {
+			if j > 0 {
+				symbolsStr += ","
+			}
+			symbolsStr += symbol
+		}
 
-		wg.Add(1)
-		go func(batch []string) {
-			defer wg.Done()
+		url := fmt.Sprintf("%s/api/v3/quote/%s", c.BaseURL, symbolsStr)
 
-			// Acquire semaphore
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
+		body, err := c.makeRequest(url)
+		if err != nil {
+			c.Logger.Warn("failed to fetch quote batch", "batch_size", len(batch), "error", err)
+			c.Report.Error(fmt.Errorf("fetch quote batch: %w", err))
+			return
+		}
 
-			// Join symbols with comma for batch request
-			symbolsStr := ""
-			for j, symbol := range batch {
-				if j > 0 {
-					symbolsStr += ","
-				}
-				symbolsStr += symbol
-			}
+		var quotes []domain.Quote
+		if err := json.Unmarshal(body, &quotes); err != nil {
+			c.Logger.Warn("failed to parse quote batch", "batch_size", len(batch), "error", err)
+			return
+		}
 
-			url := fmt.Sprintf("%s/api/v3/quote/%s?apikey=%s", c.BaseURL, symbolsStr, c.APIKey)
+		c.Checkpoint.AddQuotes(quotes)
+		mu.Lock()
+		allQuotes = append(allQuotes, quotes...)
+		mu.Unlock()
+	})
+	c.logPool(pool)
 
-			body, err := c.makeRequest(url)
-			if err != nil {
-				log.Printf("Error fetching quotes for batch: %v", err)
-				return
+	return allQuotes, nil
+}
+
+// GetSharesFloat reads the share counts of symbols from the bulk
+// shares-float endpoint. A plan without the endpoint only leaves the floats
+// empty; other failures are reported, keeping the pages read before them.
+func (c *FMPClient) GetSharesFloat(symbols []string) map[string]domain.SharesFloat {
+	want := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		want[symbol] = true
+	}
+	floats, pages, err := marketdata.FetchSharesFloat(func(path string) ([]byte, error) {
+		return c.makeRequest(c.BaseURL + "/api" + path)
+	}, want)
+	switch {
+	case errors.Is(err, marketdata.ErrNotEntitled):
+		c.Logger.Warn("API plan does not include shares float, leaving float shares and free-float market caps empty")
+	case err != nil:
+		c.Logger.Warn("failed to fetch shares float", "pages", pages, "found", len(floats), "error", err)
+		c.Report.Error(err)
+	default:
+		c.Logger.Info("fetched shares float", "pages", pages, "found", len(floats), "symbols", len(want))
+	}
+	return floats
+}
+
+// GetYTDReturns fetches the year-to-date returns of symbols, in percent, from
+// the stock-price-change endpoint in parallel batches
+func (c *FMPClient) GetYTDReturns(symbols []string) map[string]float64 {
+	const batchSize = 50
+	var batches [][]string
+	for i := 0; i < len(symbols); i += batchSize {
+		batches = append(batches, symbols[i:min(i+batchSize, len(symbols))])
+	}
+
+	ytd := make(map[string]float64, len(symbols))
+	var mu sync.Mutex
+	pool := c.startPool("price_changes", 10)
+	workpool.Each(pool, 10, batches, func(_ int, batch []string) {
+		url := fmt.Sprintf("%s/api/v3/stock-price-change/%s", c.BaseURL, strings.Join(batch, ","))
+		body, err := c.makeRequest(url)
+		if err != nil {
+			c.Logger.Warn("failed to fetch price change batch", "batch_size", len(batch), "error", err)
+			c.Report.Error(fmt.Errorf("fetch price change batch: %w", err))
+			return
+		}
+		var changes []domain.PriceChange
+		if err := json.Unmarshal(body, &changes); err != nil {
+			c.Logger.Warn("failed to parse price change batch", "batch_size", len(batch), "error", err)
+			return
+		}
+		mu.Lock()
+		for _, change := range changes {
+			ytd[change.Symbol] = change.YTD
+		}
+		mu.Unlock()
+	})
+	c.logPool(pool)
+	c.Logger.Info("fetched year-to-date returns", "found", len(ytd), "symbols", len(symbols))
+	return ytd
+}
+
+// GetInstitutionalHolders fetches the 13F holders of each symbol, one
+// request per symbol. It stops asking once the plan turns out not to
+// include the endpoint.
+func (c *FMPClient) GetInstitutionalHolders(symbols []string) map[string][]domain.InstitutionalHolder {
+	holders := make(map[string][]domain.InstitutionalHolder, len(symbols))
+	var mu sync.Mutex
+	var notEntitled atomic.Bool
+	pool := c.startPool("institutional_holders", 10)
+	workpool.Each(pool, 10, symbols, func(_ int, symbol string) {
+		if notEntitled.Load() {
+			return
+		}
+		held, err := marketdata.FetchInstitutionalHolders(func(path string) ([]byte, error) {
+			return c.makeRequest(c.BaseURL + "/api" + path)
+		}, symbol)
+		if errors.Is(err, marketdata.ErrNotEntitled) {
+			if notEntitled.CompareAndSwap(false, true) {
+				c.Logger.Warn("API plan does not include institutional holders, leaving institutional ownership empty")
 			}
+			return
+		}
+		if err != nil {
+			c.Logger.Warn("failed to fetch institutional holders", "symbol", symbol, "error", err)
+			c.Report.Error(err)
+			return
+		}
+		if len(held) == 0 {
+			return
+		}
+		mu.Lock()
+		holders[symbol] = held
+		mu.Unlock()
+	})
+	c.logPool(pool)
+	c.Logger.Info("fetched institutional holders", "found", len(holders), "symbols", len(symbols))
+	return holders
+}
 
-			var quotes []QuoteResponse
-			if err := json.Unmarshal(body, &quotes); err != nil {
-				log.Printf("Error parsing quotes for batch: %v", err)
-				return
+// GetFFO fetches the trailing FFO of each REIT symbol in parallel. It stops
+// asking once the plan turns out not to include cash flow statements.
+func (c *FMPClient) GetFFO(symbols []string) map[string]domain.FFO {
+	ffo := make(map[string]domain.FFO, len(symbols))
+	var mu sync.Mutex
+	var notEntitled atomic.Bool
+	pool := c.startPool("cash_flow_statements", 10)
+	workpool.Each(pool, 10, symbols, func(_ int, symbol string) {
+		if notEntitled.Load() {
+			return
+		}
+		f, err := marketdata.FetchFFO(func(path string) ([]byte, error) {
+			return c.makeRequest(c.BaseURL + "/api" + path)
+		}, symbol)
+		if errors.Is(err, marketdata.ErrNotEntitled) {
+			if notEntitled.CompareAndSwap(false, true) {
+				c.Logger.Warn("API plan does not include cash flow statements, leaving REIT FFO empty")
 			}
+			return
+		}
+		if err != nil {
+			c.Logger.Warn("failed to fetch cash flow statements", "symbol", symbol, "error", err)
+			c.Report.Error(err)
+			return
+		}
+		if f.Amount == 0 {
+			return
+		}
+		mu.Lock()
+		ffo[symbol] = f
+		mu.Unlock()
+	})
+	c.logPool(pool)
+	c.Logger.Info("fetched REIT FFO", "found", len(ffo), "reits", len(symbols))
+	return ffo
+}
 
-			mu.Lock()
-			allQuotes = append(allQuotes, quotes...)
-			mu.Unlock()
-		}(batch)
+// GetExtendedHours fetches the last pre- or post-market trade of each
+// symbol in parallel batches. It stops asking once the plan turns out not to
+// include the endpoint.
+func (c *FMPClient) GetExtendedHours(symbols []string) map[string]domain.ExtendedHoursTrade {
+	var batches [][]string
+	for i := 0; i < len(symbols); i += marketdata.ExtendedHoursBatchSize {
+		batches = append(batches, symbols[i:min(i+marketdata.ExtendedHoursBatchSize, len(symbols))])
 	}
 
-	wg.Wait()
-	return allQuotes, nil
+	trades := make(map[string]domain.ExtendedHoursTrade, len(symbols))
+	var mu sync.Mutex
+	var notEntitled atomic.Bool
+	pool := c.startPool("extended_hours", 10)
+	workpool.Each(pool, 10, batches, func(_ int, batch []string) {
+		if notEntitled.Load() {
+			return
+		}
+		found, err := marketdata.FetchExtendedHoursTrades(func(path string) ([]byte, error) {
+			return c.makeRequest(c.BaseURL + "/api" + path)
+		}, batch)
+		if errors.Is(err, marketdata.ErrNotEntitled) {
+			if notEntitled.CompareAndSwap(false, true) {
+				c.Logger.Warn("API plan does not include pre- and post-market trades, leaving extended-hours prices empty")
+			}
+			return
+		}
+		if err != nil {
+			c.Logger.Warn("failed to fetch extended-hours batch", "batch_size", len(batch), "error", err)
+			c.Report.Error(err)
+			return
+		}
+		mu.Lock()
+		for symbol, trade := range found {
+			trades[symbol] = trade
+		}
+		mu.Unlock()
+	})
+	c.logPool(pool)
+	c.Logger.Info("fetched extended-hours trades", "found", len(trades), "symbols", len(symbols))
+	return trades
 }
 
 // GetProfiles fetches company profiles for symbols in parallel
-func (c *FMPClient) GetProfiles(symbols []string) (map[string]ProfileResponse, error) {
-	profiles := make(map[string]ProfileResponse)
+func (c *FMPClient) GetProfiles(symbols []string) (map[string]domain.Profile, error) {
+	profiles, symbols := c.Checkpoint.SplitProfiles(symbols)
 	var mu sync.Mutex
-	var wg sync.WaitGroup
-
-	// Channel to limit concurrent requests (3,000/min = 50/sec rate limit)
-	semaphore := make(chan struct{}, 15) // Increased for better performance
 
-	for _, symbol := range symbols {
-		wg.Add(1)
-		go func(symbol string) {
-			defer wg.Done()
+	// Up to 15 concurrent requests (3,000/min = 50/sec rate limit), fewer after 429s
+	pool := c.startPool("profiles", 15)
+	workpool.Each(pool, 15, symbols, func(_ int, symbol string) {
+		url := fmt.Sprintf("%s/api/v3/profile/%s", c.BaseURL, symbol)
 
-			// Acquire semaphore
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
+		body, err := c.makeRequest(url)
+		if err != nil {
+			c.Logger.Warn("failed to fetch profile", "symbol", symbol, "error", err)
+			c.Report.Error(fmt.Errorf("fetch profile %s: %w", symbol, err))
+			return
+		}
 
-			url := fmt.Sprintf("%s/api/v3/profile/%s?apikey=%s", c.BaseURL, symbol, c.APIKey)
+		var profileList []domain.Profile
+		if err := json.Unmarshal(body, &profileList); err != nil {
+			c.Logger.Warn("failed to parse profile", "symbol", symbol, "error", err)
+			return
+		}
 
-			body, err := c.makeRequest(url)
-			if err != nil {
-				log.Printf("Error fetching profile for %s: %v", symbol, err)
-				return
-			}
+		if len(profileList) > 0 {
+			c.Checkpoint.AddProfile(symbol, profileList[0])
+			mu.Lock()
+			profiles[symbol] = profileList[0]
+			mu.Unlock()
+		}
+	})
+	c.logPool(pool)
 
-			var profileList []ProfileResponse
-			if err := json.Unmarshal(body, &profileList); err != nil {
-				log.Printf("Error parsing profile for %s: %v", symbol, err)
-				return
-			}
+	return profiles, nil
+}
 
-			if len(profileList) > 0 {
-				mu.Lock()
-				profiles[symbol] = profileList[0]
-				mu.Unlock()
-			}
-		}(symbol)
+// LookupImages is the logo stage's lookup: profiles fetched during
+// collection are reused and the rest are asked for in one batched request
+func (c *FMPClient) LookupImages(batch []string) (map[string]string, error) {
+	images := make(map[string]string, len(batch))
+	var missing []string
+	for _, symbol := range batch {
+		if profile, ok := c.profiles[symbol]; ok {
+			images[symbol] = profile.Image
+		} else {
+			missing = append(missing, symbol)
+		}
+	}
+	if len(missing) == 0 {
+		return images, nil
 	}
 
-	wg.Wait()
-	return profiles, nil
+	body, err := c.makeRequest(fmt.Sprintf("%s/api/v3/profile/%s", c.BaseURL, strings.Join(missing, ",")))
+	if errors.Is(err, ratelimit.ErrDailyLimit) {
+		return images, fmt.Errorf("%w: %w", enrich.ErrQuotaExceeded, err)
+	}
+	if err != nil {
+		return images, err
+	}
+	var profiles []domain.Profile
+	if err := json.Unmarshal(body, &profiles); err != nil {
+		return images, fmt.Errorf("failed to parse profiles: %w", err)
+	}
+	for _, p := range profiles {
+		images[p.Symbol] = p.Image
+	}
+	return images, nil
 }
 
 // FilterSymbolsByCountry filters symbols based on their profiles to only include target countries
-func FilterSymbolsByCountry(symbols []string, profiles map[string]ProfileResponse, targetCountries map[string]bool) []string {
+func FilterSymbolsByCountry(symbols []string, profiles map[string]domain.Profile, targetCountries map[string]bool) []string {
 	var filteredSymbols []string
 
 	for _, symbol := range symbols {
@@ -362,65 +686,6 @@ func isUSExchange(exchange string) bool {
 	return usExchanges[exchange]
 }
 
-// isETFOrFund checks if a symbol/name indicates an ETF or mutual fund
-func isETFOrFund(symbol, name string) bool {
-	// Check symbol patterns (ETFs/funds often have 4-5+ characters)
-	if len(symbol) >= 4 {
-		// Common ETF/fund suffixes
-		etfSuffixes := []string{"ETF", "FUND", "IDX", "INDEX"}
-		symbolUpper := strings.ToUpper(symbol)
-		for _, suffix := range etfSuffixes {
-			if strings.Contains(symbolUpper, suffix) {
-				return true
-			}
-		}
-	}
-
-	// Check name for fund/ETF keywords - use word boundaries to avoid false positives
-	nameUpper := strings.ToUpper(name)
-
-	// Check for exact word matches or specific patterns
-	if strings.Contains(nameUpper, " ETF") || strings.Contains(nameUpper, "ETF ") || strings.HasSuffix(nameUpper, " ETF") {
-		return true
-	}
-
-	fundKeywords := []string{
-		"FUND", "INDEX", "TRUST", "PORTFOLIO",
-		"VANGUARD", "ISHARES", "SPDR", "FIDELITY",
-		"ADMIRAL", "INSTITUTIONAL", "INVESTOR",
-	}
-
-	for _, keyword := range fundKeywords {
-		if strings.Contains(nameUpper, keyword) {
-			return true
-		}
-	}
-
-	return false
-}
-
-// isPreferredShare checks if a symbol represents a preferred share or warrant
-func isPreferredShare(symbol string) bool {
-	// Preferred shares typically have suffixes like -PA, -PB, -PC, -PD, -PE, -PF, etc.
-	// Warrants have suffixes like -WT, -WS
-	// Units have suffixes like -U
-	if strings.Contains(symbol, "-P") ||
-		strings.Contains(symbol, "-W") ||
-		strings.Contains(symbol, "-U") ||
-		strings.Contains(symbol, ".P") ||
-		strings.Contains(symbol, ".W") ||
-		strings.Contains(symbol, ".U") {
-		return true
-	}
-
-	// Check for common preferred share patterns
-	if strings.Contains(symbol, "PR") && len(symbol) > 4 {
-		return true
-	}
-
-	return false
-}
-
 // isLikelyUSStock checks if a symbol is likely from a major US exchange
 func isLikelyUSStock(symbol string) bool {
 	// US stocks typically don't have country suffixes and are 1-5 characters
@@ -445,78 +710,138 @@ func containsCountrySuffix(symbol string) bool {
 }
 
 // GetAllAssetsWithMarketCap fetches all assets and enriches them with market cap and profile data
-func (c *FMPClient) GetAllAssetsWithMarketCap() ([]Asset, error) {
-	log.Println("🚀 Starting US stock collection...")
-	log.Println("🇺🇸 Focus: NYSE and NASDAQ stocks only - no ETFs/funds")
-	log.Println("💰 USD market caps with $40B+ filter")
-	log.Println("📊 Minimum market cap filter: $40 billion USD")
+func (c *FMPClient) GetAllAssetsWithMarketCap() ([]domain.Asset, error) {
+	c.Logger.Info("starting US stock collection", "exchanges", "NYSE,NASDAQ", "min_market_cap_usd", 40e9)
 
-	var allAssets []Asset
+	var allAssets []domain.Asset
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 
 	// Note: Country filtering removed - we filter by US exchanges instead for faster processing
 
 	// Channel for collecting assets from different sources
-	assetChan := make(chan []Asset, 1) // Only stocks for now
+	assetChan := make(chan []domain.Asset, 1) // Only stocks for now
 
 	// Fetch stocks in parallel
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		log.Println("📈 Fetching stocks...")
+		c.Logger.Debug("fetching stock list")
 
 		stocks, err := c.GetAllStocks()
 		if err != nil {
-			log.Printf("Error fetching stocks: %v", err)
+			c.Logger.Error("failed to fetch stock list", "error", err)
+			c.Report.Error(err)
 			return
 		}
 
-		log.Printf("✅ Found %d stocks", len(stocks))
+		c.Logger.Info("fetched stock list", "count", len(stocks))
 
-		// Convert to symbols and get quotes for ALL stocks (fast market cap filtering)
+		// Convert to symbols and get quotes for ALL stocks (fast market cap
+		// filtering); the list's security types feed the exclusion rules
 		allSymbols := make([]string, len(stocks))
+		types := make(map[string]string, len(stocks))
+		names := make(map[string]string, len(stocks))
 		for i, stock := range stocks {
 			allSymbols[i] = stock.Symbol
+			types[stock.Symbol] = stock.Type
+			names[stock.Symbol] = stock.Name
 		}
 
-		log.Printf("💰 Getting quotes for ALL %d stocks to filter by $40B+ market cap first...", len(allSymbols))
+		c.Logger.Info("fetching quotes for market cap filter", "symbols", len(allSymbols))
 		quotes, err := c.GetQuotes(allSymbols)
 		if err != nil {
-			log.Printf("Error fetching stock quotes: %v", err)
+			c.Logger.Error("failed to fetch quotes", "error", err)
+			c.Report.Error(err)
 			return
 		}
 
 		// FAST FILTER: Only keep stocks with $40B+ market cap and US exchanges (before expensive profile fetch)
 		const minMarketCapUSD = 40e9 // $40 billion USD minimum
 		var highValueSymbols []string
-		var filteredQuotes []QuoteResponse
+		var filteredQuotes []domain.Quote
 
 		for _, quote := range quotes {
 			// Quick filters first: market cap, exchange, ETF/fund exclusion
-			if quote.MarketCap >= minMarketCapUSD &&
-				isUSExchange(quote.Exchange) &&
-				!isETFOrFund(quote.Symbol, quote.Name) {
+			reason := "non_us_exchange"
+			if isUSExchange(quote.Exchange) {
+				listType := instrument.Classify(instrument.Facts{Symbol: quote.Symbol, Exchange: quote.Exchange, Name: quote.Name, ListType: types[quote.Symbol]})
+				reason = exclusion.Match(exclusion.Security{Symbol: quote.Symbol, Name: quote.Name, Type: listType})
+				if reason == "" && c.SPACs.Excludes(listType) {
+					reason = instrument.SPAC
+				}
+			}
+			if quote.MarketCap >= minMarketCapUSD && reason == "" {
 				highValueSymbols = append(highValueSymbols, quote.Symbol)
 				filteredQuotes = append(filteredQuotes, quote)
+				metrics.SymbolsProcessed.Inc("US", "kept")
+			} else {
+				metrics.SymbolsProcessed.Inc("US", "skipped")
+				// Only large caps are worth reporting; the rest were never in the universe
+				if quote.MarketCap >= minMarketCapUSD {
+					c.Report.Drop(quote.Symbol, "US", reason)
+				}
 			}
 		}
 
-		log.Printf("🎯 Fast filter: Found %d stocks with $40B+ market cap on US exchanges", len(highValueSymbols))
+		c.Logger.Info("fast filter complete", "matched", len(highValueSymbols))
 
 		// NOW get profiles only for high-value stocks (much faster - only ~500 instead of 15k!)
-		log.Printf("📋 Getting profiles for %d high-value stocks only...", len(highValueSymbols))
+		c.Logger.Debug("fetching profiles for high-value stocks", "symbols", len(highValueSymbols))
 		profiles, err := c.GetProfiles(highValueSymbols)
 		if err != nil {
-			log.Printf("Error fetching profiles: %v", err)
+			c.Logger.Error("failed to fetch profiles", "error", err)
+		}
+		c.Logger.Info("fetched profiles", "count", len(profiles))
+		c.profiles = profiles
+
+		var floats map[string]domain.SharesFloat
+		if c.SharesFloat {
+			floats = c.GetSharesFloat(highValueSymbols)
+		}
+		var ytd map[string]float64
+		if c.Enrichments[enrich.Technicals] {
+			ytd = c.GetYTDReturns(highValueSymbols)
+		}
+		var holders map[string][]domain.InstitutionalHolder
+		if c.Enrichments[enrich.Ownership] {
+			holders = c.GetInstitutionalHolders(highValueSymbols)
+		}
+		// Classify from the stock list's type and name and the profile's
+		// flags and industry
+		instrumentType := func(symbol string) string {
+			facts := instrument.Facts{Symbol: symbol, Name: names[symbol], ListType: types[symbol]}
+			if profile, exists := profiles[symbol]; exists {
+				facts.IsEtf, facts.IsFund, facts.Industry = profile.IsEtf, profile.IsFund, profile.Industry
+			}
+			return c.SPACs.Type(instrument.Classify(facts))
+		}
+		var ffo map[string]domain.FFO
+		if c.Enrichments[enrich.REIT] {
+			var reits []string
+			for _, symbol := range highValueSymbols {
+				if instrumentType(symbol) == instrument.REIT {
+					reits = append(reits, symbol)
+				}
+			}
+			ffo = c.GetFFO(reits)
+		}
+		var extended map[string]domain.ExtendedHoursTrade
+		if c.ExtendedHours {
+			extended = c.GetExtendedHours(highValueSymbols)
 		}
-		log.Printf("✅ Retrieved profiles for %d high-value symbols", len(profiles))
 
 		// Combine data into final assets with profile data
-		var stockAssets []Asset
+		var stockAssets []domain.Asset
 		for _, quote := range filteredQuotes {
+			// The profile's industry can mark a SPAC the fast filter let through
+			if c.SPACs.Excludes(instrumentType(quote.Symbol)) {
+				c.Report.Drop(quote.Symbol, "US", instrument.SPAC)
+				continue
+			}
 			// Basic data validation (already filtered for market cap, exchange, ETFs)
 			if quote.Price <= 0 || quote.Price > 10000 { // Reasonable price range
+				c.Report.Drop(quote.Symbol, "US", "price_out_of_range")
 				continue
 			}
 
@@ -529,35 +854,85 @@ func (c *FMPClient) GetAllAssetsWithMarketCap() ([]Asset, error) {
 			}
 
 			// Convert market cap to USD (should already be USD for US exchanges)
-			marketCapUSD := ConvertToUSD(quote.MarketCap, currency)
-
-			asset := Asset{
-				Symbol:        quote.Symbol,
-				Name:          quote.Name,
-				Price:         quote.Price,
-				PreviousClose: quote.PreviousClose,
-				MarketCap:     marketCapUSD,
-				Exchange:      quote.Exchange,
-				Type:          "stock",
-				Currency:      "USD", // All converted to USD
-				Volume:        quote.Volume,
-				AvgVolume:     quote.AvgVolume,
-				Beta:          quote.Beta,
-				PE:            quote.PE,
-				EPS:           quote.EPS,
-				DividendYield: quote.DividendYield,
+			rate := usdRate(currency)
+			marketCapUSD := quote.MarketCap * rate.Rate
+
+			// Calculate percentage change if previous close is available
+			var percentageChange float64
+			if quote.PreviousClose > 0 {
+				percentageChange = ((quote.Price - quote.PreviousClose) / quote.PreviousClose) * 100
+			}
+
+			asset := domain.Asset{
+				Ticker:           quote.Symbol,
+				Name:             quote.Name,
+				CurrentPrice:     quote.Price,
+				PreviousClose:    quote.PreviousClose,
+				PercentageChange: percentageChange,
+				MarketCap:        marketCapUSD,
+				PrimaryExchange:  quote.Exchange,
+				AssetType:        "stock",
+				Currency:         "USD", // All converted to USD
+				Volume:           quote.Volume,
+				AvgVolume:        quote.AvgVolume,
+				Beta:             quote.Beta,
+				PE:               quote.PE,
+				EPS:              quote.EPS,
+			}
+			asset.SetFX(rate)
+			if quote.DividendYield != 0 {
+				dividendYield := quote.DividendYield
+				asset.DividendYield = &dividendYield
 			}
 
+			asset.InstrumentType = instrumentType(quote.Symbol)
+			asset.ShareClass = instrument.ParseSymbol(quote.Symbol, quote.Exchange).Class
+
 			// Add profile data if available
 			if profile, exists := profiles[quote.Symbol]; exists {
 				asset.Country = profile.Country
 				asset.Sector = profile.Sector
 				asset.Industry = profile.Industry
-				asset.Image = profile.Image
+				asset.ISIN = profile.ISIN
+				asset.SetListing(profile.IPODate, c.SnapshotTime, c.recentIPODays())
+			}
+			asset.SectorNormalized, asset.IndustryNormalized = taxonomy.Normalize(asset.Sector, asset.Industry)
+			refdata.Enrich(&asset, c.SnapshotTime)
+			refdata.LabelQuote(&asset, &quote)
+			refdata.Date(&asset, c.SnapshotTime)
+			if trade, ok := extended[quote.Symbol]; ok {
+				asset.SetExtendedHours(trade)
+			}
+			outstanding, floatShares := quote.SharesOutstanding, 0.0
+			if sf, ok := floats[quote.Symbol]; ok {
+				if sf.OutstandingShares > 0 {
+					outstanding = sf.OutstandingShares
+				}
+				floatShares = sf.FloatShares
+			}
+			asset.SetShares(outstanding, floatShares)
+			if c.Enrichments[enrich.Technicals] {
+				asset.SetTechnicals(&quote)
+				if change, ok := ytd[quote.Symbol]; ok {
+					asset.YTDReturn = &change
+				}
+			}
+			if held, ok := holders[quote.Symbol]; ok {
+				asset.SetOwnership(domain.NewOwnership(quote.Symbol, "", held, asset.SharesOutstanding))
+			}
+			if f, ok := ffo[quote.Symbol]; ok {
+				asset.SetFFO(f, currency, quote.MarketCap)
 			}
 
 			stockAssets = append(stockAssets, asset)
 		}
+		resolvePrimaries(stockAssets, stocks, profiles)
+		if c.AggregateShareClasses {
+			stockAssets = aggregateShareClasses(stockAssets)
+		}
+		for i := range stockAssets {
+			stockAssets[i].SetCapTier(c.CapTiers)
+		}
 
 		assetChan <- stockAssets
 	}()
@@ -578,116 +953,96 @@ func (c *FMPClient) GetAllAssetsWithMarketCap() ([]Asset, error) {
 		mu.Unlock()
 	}
 
-	log.Printf("🎯 Total assets collected: %d", len(allAssets))
+	c.Logger.Info("collection complete", "assets", len(allAssets))
 	return allAssets, nil
 }
 
-// RankByMarketCap sorts assets by market cap in descending order and filters for $40B+ USD
-func RankByMarketCap(assets []Asset) []Asset {
-	const minMarketCapUSD = 40e9 // $40 billion USD minimum
-
-	// Filter for assets with market cap >= $40B USD
-	validAssets := make([]Asset, 0, len(assets))
-	for _, asset := range assets {
-		if asset.MarketCap >= minMarketCapUSD {
-			validAssets = append(validAssets, asset)
+// resolvePrimaries flags ADRs and sets each asset's primary symbol, grouping
+// the assets with every listing in the stock list by issuer. Only the assets
+// carry ISIN/CIK and market caps, so the rest of the list matches by name.
+func resolvePrimaries(assets []domain.Asset, stocks []StockListResponse, profiles map[string]domain.Profile) {
+	reg := registry.New(registry.PreferPrimary)
+	adr := make(map[string]bool, len(assets))
+	for _, a := range assets {
+		listing := registry.Listing{
+			Symbol:    a.Ticker,
+			Name:      a.Name,
+			Exchange:  a.PrimaryExchange,
+			Country:   a.Country,
+			MarketCap: a.MarketCap,
+			ADR:       registry.IsLikelyADR(a.Ticker, a.Name, a.PrimaryExchange),
+		}
+		if profile, ok := profiles[a.Ticker]; ok {
+			listing.ADR = listing.ADR || profile.IsADR
+			listing.IDs = registry.Identifiers{ISIN: profile.ISIN, CIK: profile.CIK, FIGI: profile.FIGI}
 		}
+		adr[a.Ticker] = listing.ADR
+		reg.Add(listing)
 	}
-
-	// Sort by market cap descending
-	sort.Slice(validAssets, func(i, j int) bool {
-		return validAssets[i].MarketCap > validAssets[j].MarketCap
-	})
-
-	log.Printf("🎯 Filtered to %d assets with $40B+ USD market cap", len(validAssets))
-	return validAssets
-}
-
-// ConvertToSupabaseFormatUS converts Asset to SupabaseUSAsset format
-func ConvertToSupabaseFormatUS(assets []Asset) []SupabaseUSAsset {
-	today := time.Now().Format("2006-01-02")
-	supabaseAssets := make([]SupabaseUSAsset, len(assets))
-
-	for i, asset := range assets {
-		// Calculate percentage change if previous close is available
-		var percentageChange float64
-		if asset.PreviousClose > 0 {
-			percentageChange = ((asset.Price - asset.PreviousClose) / asset.PreviousClose) * 100
+	for _, stock := range stocks {
+		if stock.Type != "" && stock.Type != "stock" {
+			continue
 		}
+		reg.Add(registry.Listing{
+			Symbol:   stock.Symbol,
+			Name:     stock.Name,
+			Exchange: stock.Exchange,
+			ADR:      registry.IsLikelyADR(stock.Symbol, stock.Name, stock.Exchange),
+		})
+	}
 
-		// Truncate strings to match Supabase field limits
-		symbol := truncateStringUS(asset.Symbol, 50)
-		name := truncateStringUS(asset.Name, 200)
-		exchange := truncateStringUS(asset.Exchange, 50)
-		country := truncateStringUS(asset.Country, 50)
-		sector := truncateStringUS(asset.Sector, 100)
-		industry := truncateStringUS(asset.Industry, 100)
-
-		supabaseAssets[i] = SupabaseUSAsset{
-			Symbol:           symbol,
-			Ticker:           symbol, // Same as symbol
-			Name:             name,
-			CurrentPrice:     asset.Price,
-			PreviousClose:    asset.PreviousClose,
-			PercentageChange: percentageChange,
-			MarketCap:        int64(asset.MarketCap), // Already in USD
-			Volume:           asset.Volume,
-			PrimaryExchange:  exchange,
-			Country:          country,
-			Sector:           sector,
-			Industry:         industry,
-			AssetType:        "stock",
-			Rank:             i + 1, // Ranking position
-			SnapshotDate:     today,
-			DataSource:       "FMP",
-			PriceRaw:         asset.Price,
-			MarketCapRaw:     int64(asset.MarketCap),
-			Category:         "stocks",
-			Image:            asset.Image, // Add Image field
+	primary := make(map[string]string)
+	for _, issuer := range reg.Issuers() {
+		for _, l := range issuer.Listings {
+			primary[l.Symbol] = issuer.Primary.Symbol
 		}
 	}
-
-	return supabaseAssets
-}
-
-// truncateStringUS truncates a string to specified length
-func truncateStringUS(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
+	for i := range assets {
+		assets[i].IsADR = adr[assets[i].Ticker]
+		assets[i].PrimarySymbol = primary[assets[i].Ticker]
 	}
-	return s[:maxLen]
 }
 
-// SaveUSToSupabase saves the US assets in Supabase-compatible format
-func SaveUSToSupabase(assets []Asset, filename string) error {
-	supabaseAssets := ConvertToSupabaseFormatUS(assets)
-
-	jsonData, err := json.MarshalIndent(supabaseAssets, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal Supabase assets: %v", err)
+// aggregateShareClasses folds the assets sharing a primary symbol, the share
+// classes of one issuer, into one issuer row each. resolvePrimaries must have
+// run; depositary receipts are never folded.
+func aggregateShareClasses(assets []domain.Asset) []domain.Asset {
+	classes := make(map[string][]domain.Asset)
+	for _, a := range assets {
+		if !a.IsADR {
+			classes[a.PrimarySymbol] = append(classes[a.PrimarySymbol], a)
+		}
 	}
-
-	err = os.WriteFile(filename, jsonData, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to write file: %v", err)
+	folded := make([]domain.Asset, 0, len(assets))
+	for _, a := range assets {
+		rows := classes[a.PrimarySymbol]
+		switch {
+		case a.IsADR || len(rows) < 2:
+			folded = append(folded, a)
+		case a.Ticker == rows[0].Ticker:
+			folded = append(folded, domain.AggregateShareClasses(rows, a.PrimarySymbol))
+		}
 	}
-
-	log.Printf("💾 Saved %d US assets to %s (Supabase format)", len(supabaseAssets), filename)
-	return nil
+	return folded
 }
 
-// SaveToJSON saves assets to a JSON file
-func SaveToJSON(assets []Asset, filename string) error {
-	data, err := json.MarshalIndent(assets, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal JSON: %w", err)
-	}
+// RankByMarketCap sorts assets by market cap in descending order and filters for $40B+ USD
+func RankByMarketCap(assets []domain.Asset) []domain.Asset {
+	const minMarketCapUSD = 40e9 // $40 billion USD minimum
 
-	if err := os.WriteFile(filename, data, 0644); err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
+	// Filter for assets with market cap >= $40B USD
+	validAssets := make([]domain.Asset, 0, len(assets))
+	for _, asset := range assets {
+		if asset.MarketCap >= minMarketCapUSD {
+			validAssets = append(validAssets, asset)
+		}
 	}
 
-	return nil
+	// Sort by market cap descending
+	domain.SortAssets(validAssets)
+
+	slog.Info("ranked assets by market cap", "count", len(validAssets), "min_market_cap_usd", minMarketCapUSD)
+	return validAssets
 }
 
 // Helper function for min
@@ -713,51 +1068,462 @@ func FormatMarketCap(marketCap float64) string {
 }
 
 func main() {
+	var logOpts logging.Options
+	logging.RegisterFlags(flag.CommandLine, &logOpts)
+	metricsAddr := flag.String("metrics-addr", "", "serve Prometheus metrics on this address (e.g. :9100)")
+	reportPath := flag.String("report", runreport.DefaultFilename, "write the JSON run report to this path (empty disables)")
+	cacheDir := flag.String("cache-dir", ".fmp_cache", "cache slow-changing API responses here (empty disables)")
+	cacheTTLs := flag.String("cache-ttl", httpcache.FormatTTLs(httpcache.DefaultTTLs), "per-endpoint cache TTLs as endpoint=duration,...")
+	var sinkSpecs sink.Specs
+	flag.Var(&sinkSpecs, "sink", "write results to this sink; repeatable (json:PATH, csv:PATH, parquet:PATH, xlsx:PATH, supabase:PATH, postgres:[DSN], s3://BUCKET/KEY, gs://BUCKET/KEY, sqlite:PATH, gsheets:SPREADSHEET_ID, kafka://BROKER/TOPIC, nats://HOST:PORT/SUBJECT, redis://HOST:PORT, clickhouse://HOST:PORT/DATABASE, bigquery:PROJECT.DATASET.TABLE)")
+	storeSpec := flag.String("store", "", "also append each run to this history store, one row per symbol and day (sqlite:PATH)")
+	checkpointPath := flag.String("checkpoint", DefaultCheckpointPath, "save fetched quotes and profiles here when the daily API limit stops a run, and resume from them next time (empty disables)")
+	checkpointMaxAge := flag.Duration("checkpoint-max-age", 24*time.Hour, "ignore checkpoints older than this")
+	var notifySpecs notify.Specs
+	flag.Var(&notifySpecs, "notify", "post a run summary to this webhook when the run finishes or fails; repeatable (slack:URL, discord:URL, telegram:CHAT_ID), also read from NOTIFY_WEBHOOKS")
+	notifyErrorRate := flag.Float64("notify-error-rate", notify.DefaultErrorRate, "turn the summary into an alert when more than this share of API calls failed")
+	emailTo := flag.String("email-to", "", "email an HTML run report to these comma-separated addresses through the SMTP_* server")
+	emailArtifactURL := flag.String("email-artifact-url", "", "link output files in the email report under this URL, where the output directory is published")
+	timestamped := flag.Bool("timestamped-output", false, "write outputs with a UTC run timestamp in the name and keep the plain name as a link to the latest run")
+	keepRuns := flag.Int("keep-runs", 0, "after each run, delete all but the newest N dated runs of every output file, with their sidecars, or with --run-dir the newest N run directories (0 keeps all)")
+	runsRoot := flag.String("run-dir", "", "write each run's outputs, run report and manifest to a new DIR/<UTC run time> directory and point DIR/latest at the newest complete run")
+	record := flag.Bool("record", false, "archive the raw API responses of this run to --record-dir, or with --run-dir to the run directory's responses/, for --replay")
+	recordDir := flag.String("record-dir", replay.ResponsesDir, "where --record archives responses without --run-dir")
+	replayDir := flag.String("replay", "", "reprocess the API responses recorded in DIR, or in run directory DIR, instead of calling the API")
+	taxonomyPath := flag.String("taxonomy", "", "merge this JSON sector/industry mapping (sectors, industries) over the bundled GICS one")
+	aggregateShareClasses := flag.Bool("aggregate-share-classes", false, "fold an issuer's share classes (GOOG and GOOGL, BRK-A and BRK-B) into one row with their summed market cap and a share_classes list")
+	capTierSpec := flag.String("cap-tiers", "", "override the USD market caps above which rows are tiered mega, large, mid and small, e.g. mega=300B,small=250M (default "+domain.DefaultCapTiers.String()+")")
+	spacs := flag.String("spacs", string(instrument.SPACTag), "how to treat SPACs and other blank-check companies: tag (instrument_type spac), exclude, or include as ordinary stocks")
+	exclusionsPath := flag.String("exclusions", "", "JSON exclusion rules (exclude_types, exclude_names, keep_names, exclude_symbol_suffixes); each list it sets replaces the bundled one")
+	aggregatesPrefix := flag.String("aggregates", "us_stocks", "write top movers, sector totals and breadth to PREFIX_movers.json, PREFIX_sectors.json and PREFIX_breadth.json (empty disables)")
+	topMovers := flag.Int("top-movers", aggregate.DefaultTopN, "gainers and losers listed in the movers file")
+	imageMinMarketCap := flag.Float64("image-min-market-cap", enrich.DefaultImageMinMarketCap, "look up the logo of every company worth at least this many USD (negative disables logos)")
+	imageCacheTTL := flag.Duration("image-cache-ttl", enrich.DefaultImageCacheTTL, "reuse logo URLs cached in --cache-dir for this long")
+	snapshotTime := flag.String("snapshot-time", "", "RFC 3339 time is_market_open and is_recent_ipo are evaluated at (default: when the run starts)")
+	recentIPODays := flag.Int("recent-ipo-days", domain.RecentIPODays, "flag rows that listed fewer than this many days before the snapshot time as is_recent_ipo")
+	sharesFloat := flag.Bool("shares-float", true, "fetch share counts from FMP's bulk shares-float endpoint for float shares and free-float market caps")
+	enrichList := flag.String("enrich", "", "turn on optional enrichments, comma-separated: technicals (52-week high/low, 50/200-day averages and YTD return), ownership (institutional and top-10 holder ownership from 13F filings), reit (trailing FFO and price/FFO of REITs from quarterly cash flow statements)")
+	extendedHours := flag.Bool("extended-hours", false, "also fetch the last pre- and post-market trade of each stock for extended_hours_price and extended_hours_change, one request per 50 stocks")
+	filingsPath := flag.String("filings", "", "also write the stocks' recent 10-K, 10-Q and 8-K filings to this JSON file, one request per stock (empty disables)")
+	filingsDays := flag.Int("filings-days", DefaultFilingsDays, "how many days of filings --filings collects")
+	flag.Parse()
+
+	logger, err := logging.Setup(logOpts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid logging options: %v\n", err)
+		os.Exit(2)
+	}
+	if *taxonomyPath != "" {
+		if err := taxonomy.Load(*taxonomyPath); err != nil {
+			logger.Error("invalid taxonomy mapping", "error", err)
+			os.Exit(2)
+		}
+	}
+	if *exclusionsPath != "" {
+		if err := exclusion.Load(*exclusionsPath); err != nil {
+			logger.Error("invalid exclusion rules", "error", err)
+			os.Exit(2)
+		}
+	}
+	if *topMovers < 1 {
+		logger.Error("--top-movers must be at least 1", "top_movers", *topMovers)
+		os.Exit(2)
+	}
+	snapshot := time.Now()
+	if *snapshotTime != "" {
+		if snapshot, err = time.Parse(time.RFC3339, *snapshotTime); err != nil {
+			logger.Error("invalid --snapshot-time, want RFC 3339", "snapshot_time", *snapshotTime, "error", err)
+			os.Exit(2)
+		}
+	}
+	enrichments, err := enrich.ParseSet(*enrichList)
+	if err != nil {
+		logger.Error("invalid --enrich", "error", err)
+		os.Exit(2)
+	}
+	spacPolicy, err := instrument.ParseSPACPolicy(*spacs)
+	if err != nil {
+		logger.Error("invalid --spacs", "error", err)
+		os.Exit(2)
+	}
+	capTiers, err := domain.ParseCapTiers(*capTierSpec)
+	if err != nil {
+		logger.Error("invalid --cap-tiers", "error", err)
+		os.Exit(2)
+	}
+	if *filingsDays < 1 {
+		logger.Error("--filings-days must be at least 1", "filings_days", *filingsDays)
+		os.Exit(2)
+	}
+	if *recentIPODays < 1 {
+		logger.Error("--recent-ipo-days must be at least 1", "recent_ipo_days", *recentIPODays)
+		os.Exit(2)
+	}
+	if *keepRuns < 0 {
+		logger.Error("--keep-runs must not be negative", "keep_runs", *keepRuns)
+		os.Exit(2)
+	}
+	if *runsRoot != "" && *timestamped {
+		logger.Error("--run-dir and --timestamped-output are mutually exclusive")
+		os.Exit(2)
+	}
+	if *record && *replayDir != "" {
+		logger.Error("--record and --replay are mutually exclusive")
+		os.Exit(2)
+	}
+	// A replay evaluates the rows at the snapshot time of the recorded run, so
+	// date-dependent requests ask for what the recording holds
+	var player *replay.Player
+	if *replayDir != "" {
+		if player, err = replay.Open(*replayDir); err != nil {
+			logger.Error("invalid --replay", "error", err)
+			os.Exit(2)
+		}
+		if *snapshotTime == "" {
+			snapshot = player.SnapshotTime
+		}
+	}
+
+	if *metricsAddr != "" {
+		metricsErrs := metrics.Serve(*metricsAddr)
+		go func() {
+			logger.Error("metrics listener stopped", "addr", *metricsAddr, "error", <-metricsErrs)
+		}()
+		logger.Info("serving metrics", "addr", *metricsAddr, "path", "/metrics")
+	}
+
 	// Load environment variables
 	if err := godotenv.Load(".env"); err != nil {
-		log.Printf("Warning: Could not load .env file: %v", err)
+		logger.Warn("could not load .env file", "error", err)
+	}
+	if err := secrets.LoadEnv(context.Background(), logger); err != nil {
+		logger.Error("failed to load secrets", "error", err)
+		os.Exit(1)
 	}
 
-	apiKey := os.Getenv("FMP_API_KEY")
-	if apiKey == "" {
-		log.Fatal("❌ FMP_API_KEY key not found in environment variables")
+	keys := apikeys.FromEnv()
+	if player != nil && keys.Len() == 0 {
+		keys = apikeys.Parse(replay.PlaceholderKey)
+	}
+	if keys.Len() == 0 {
+		logger.Error("FMP_API_KEY (or FMP_API_KEYS for a comma-separated pool) not found in environment variables")
+		os.Exit(1)
 	}
 
-	log.Println("🔑 FMP API key loaded successfully")
+	logger.Debug("FMP API keys loaded", "keys", keys.Len())
 
 	// Create FMP client
-	client := NewFMPClient(apiKey)
+	if len(sinkSpecs) == 0 {
+		sinkSpecs = sink.Specs{"supabase:assets/stocks/us_supabase.json"}
+	}
+	sinks, err := sink.ParseAll(sinkSpecs)
+	if err != nil {
+		logger.Error("invalid sink", "error", err)
+		os.Exit(2)
+	}
+	if *storeSpec != "" {
+		store, err := sink.ParseStore(*storeSpec)
+		if err != nil {
+			logger.Error("invalid store", "error", err)
+			os.Exit(2)
+		}
+		sinks = append(sinks, store)
+	}
+
+	notifier, err := notify.New(notifySpecs, *notifyErrorRate, logger)
+	if err != nil {
+		logger.Error("invalid notification target", "error", err)
+		os.Exit(2)
+	}
+	var recipients []string
+	for _, address := range strings.Split(*emailTo, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			recipients = append(recipients, address)
+		}
+	}
+	mailer, err := notify.NewEmail(recipients, logger)
+	if err != nil {
+		logger.Error("invalid email report settings", "error", err)
+		os.Exit(2)
+	}
+
+	signingKey, err := integrity.SigningKeyFromEnv()
+	if err != nil {
+		logger.Error("failed to load signing key", "error", err)
+		os.Exit(1)
+	}
+
+	// runDir is this run's directory under --run-dir, created once the
+	// collection starts
+	var runDir *output.RunDir
+
+	report := runreport.New("fmp_us")
+	writeReport := func() {
+		if *reportPath == "" {
+			return
+		}
+		path := *reportPath
+		if runDir != nil {
+			path = runDir.Path(path)
+		}
+		if err := report.Write(path); err != nil {
+			logger.Error("failed to write run report", "file", path, "error", err)
+		} else if err := integrity.Seal(path, signingKey); err != nil {
+			logger.Error("failed to seal run report", "file", path, "error", err)
+		} else {
+			logger.Info("run report saved", "file", path)
+		}
+	}
+
+	// notifyRun posts the run summary and emails the report once the report
+	// is complete; ranked is nil when the run failed before ranking
+	var links []notify.Artifact
+	notifyRun := func(ranked []domain.Asset, failure error, movers *aggregate.Movers) {
+		s := notify.Summary{Run: report.Build(), Rows: len(ranked), Failure: failure}
+		if movers != nil {
+			s.Gainers, s.Losers = movers.Overall(notify.DefaultMovers)
+		}
+		notifier.Notify(s)
+
+		email := notify.EmailReport{
+			Summary:   s,
+			Top:       ranked[:min(10, len(ranked))],
+			Countries: s.Run.SymbolsPerCountry,
+			Artifacts: append(notify.FileArtifacts(s.Run.Outputs, *emailArtifactURL), links...),
+		}
+		mailer.Send(email)
+	}
+
+	// outputPath returns where to write an output normally found at name, and
+	// publish records, seals and (for timestamped runs) links the written file,
+	// then prunes old runs with --keep-runs; run directories are pruned when
+	// the run is published
+	runTime := time.Now()
+	outputPath := func(name string) string {
+		if runDir != nil {
+			return runDir.Path(name)
+		}
+		if *timestamped {
+			return output.Timestamped(name, runTime)
+		}
+		output.Detach(name)
+		return name
+	}
+	publish := func(name, path string) {
+		logger.Info("data saved", "file", path)
+		report.Error(report.AddOutput(path))
+		report.Error(integrity.Seal(path, signingKey))
+		if *timestamped {
+			report.Error(output.Latest(name, path))
+		}
+		if *keepRuns > 0 && runDir == nil {
+			deleted, err := output.Prune(name, *keepRuns)
+			report.Error(err)
+			if len(deleted) > 0 {
+				logger.Info("old runs deleted", "output", name, "deleted", len(deleted), "kept", *keepRuns)
+			}
+		}
+	}
+
+	client := NewFMPClient(keys)
+	client.Logger = logger
+	if base := os.Getenv("FMP_BASE_URL"); base != "" {
+		client.BaseURL = strings.TrimSuffix(base, "/")
+	}
+	client.Report = report
+	client.SnapshotTime = snapshot
+	client.RecentIPODays = *recentIPODays
+	client.SharesFloat = *sharesFloat
+	client.ExtendedHours = *extendedHours
+	client.Enrichments = enrichments
+	client.SPACs = spacPolicy
+	client.AggregateShareClasses = *aggregateShareClasses
+	client.CapTiers = capTiers
+
+	if *checkpointPath != "" {
+		checkpoint, err := LoadCheckpoint(*checkpointPath, *checkpointMaxAge)
+		if err != nil {
+			logger.Error("failed to load checkpoint", "file", *checkpointPath, "error", err)
+			os.Exit(1)
+		}
+		if !checkpoint.Empty() {
+			logger.Info("resuming from checkpoint", "file", *checkpointPath, "saved", checkpoint.Saved,
+				"quotes", len(checkpoint.Quotes), "profiles", len(checkpoint.Profiles))
+		}
+		client.Checkpoint = checkpoint
+	}
+
+	if player != nil {
+		client.HTTPClient.Transport = player
+		logger.Info("replaying recorded responses", "dir", player.Dir, "recorded_at", player.RecordedAt)
+	} else if *cacheDir != "" {
+		ttls, err := httpcache.ParseTTLs(*cacheTTLs)
+		if err != nil {
+			logger.Error("invalid cache TTLs", "error", err)
+			os.Exit(2)
+		}
+		transport, err := httpcache.New(*cacheDir, ttls, client.HTTPClient.Transport)
+		if err != nil {
+			logger.Error("failed to set up response cache", "dir", *cacheDir, "error", err)
+			os.Exit(1)
+		}
+		client.HTTPClient.Transport = transport
+		logger.Info("response cache enabled", "dir", *cacheDir, "ttls", *cacheTTLs)
+	}
+
+	if *runsRoot != "" {
+		dir, err := output.CreateRunDir(*runsRoot, runTime)
+		if err != nil {
+			logger.Error("failed to create run directory", "error", err)
+			os.Exit(1)
+		}
+		runDir = &dir
+		logger.Info("writing run directory", "dir", dir.Dir())
+	}
+	var recorder *replay.Recorder
+	if *record {
+		dir := *recordDir
+		if runDir != nil {
+			dir = runDir.Path(replay.ResponsesDir)
+		}
+		if recorder, err = replay.NewRecorder(dir, snapshot, client.HTTPClient.Transport); err != nil {
+			logger.Error("failed to start recording", "error", err)
+			os.Exit(1)
+		}
+		client.HTTPClient.Transport = recorder
+		logger.Info("recording API responses", "dir", dir)
+	}
 
 	// Get all assets with market cap data
 	startTime := time.Now()
 	assets, err := client.GetAllAssetsWithMarketCap()
 	if err != nil {
-		log.Fatalf("❌ Error fetching assets: %v", err)
+		logger.Error("failed to fetch assets", "error", err)
+		report.Error(err)
+		writeReport()
+		notifyRun(nil, err, nil)
+		os.Exit(1)
 	}
 
-	log.Printf("⚡ Data collection completed in %v", time.Since(startTime))
+	// A partial universe would rank the wrong assets, so save what was fetched
+	// and stop; the next run picks up from the checkpoint
+	if client.DailyLimitHit() {
+		report.Error(ratelimit.ErrDailyLimit)
+		if *checkpointPath != "" {
+			if err := client.Checkpoint.Save(*checkpointPath); err != nil {
+				logger.Error("failed to save checkpoint", "file", *checkpointPath, "error", err)
+			} else {
+				logger.Warn("daily API limit exhausted, checkpoint saved; rerun after the quota resets",
+					"file", *checkpointPath)
+			}
+		}
+		writeReport()
+		notifyRun(nil, ratelimit.ErrDailyLimit, nil)
+		os.Exit(3)
+	}
+	if *checkpointPath != "" {
+		if err := os.Remove(*checkpointPath); err != nil && !os.IsNotExist(err) {
+			logger.Warn("failed to remove checkpoint", "file", *checkpointPath, "error", err)
+		}
+	}
+
+	keys.LogUsage(logger)
+	logger.Info("data collection completed", "duration", time.Since(startTime).String())
 
 	// Rank by market cap
 	rankedAssets := RankByMarketCap(assets)
 
-	log.Printf("📊 NYSE/NASDAQ stocks only ($40B+ USD) ranked by market cap. Top 10:")
+	if *imageMinMarketCap >= 0 {
+		images := &enrich.Images{MinMarketCap: *imageMinMarketCap, Lookup: client.LookupImages, Logger: logger}
+		if *cacheDir != "" {
+			if images.Cache, err = enrich.LoadImageCache(filepath.Join(*cacheDir, enrich.ImageCacheFile), *imageCacheTTL); err != nil {
+				logger.Warn("ignoring logo cache", "error", err)
+			}
+		}
+		result := images.Apply(rankedAssets)
+		logger.Info("looked up logos", "wanted", result.Wanted, "cached", result.Cached, "fetched", result.Fetched,
+			"missing", result.Missing, "deferred", len(result.Deferred))
+		if err := images.Cache.Save(); err != nil {
+			logger.Warn("failed to save logo cache", "error", err)
+		}
+	}
+
+	countries := make([]string, len(rankedAssets))
+	for i, asset := range rankedAssets {
+		countries[i] = asset.Country
+	}
+	report.CountSymbols(countries)
+
 	for i, asset := range rankedAssets[:min(10, len(rankedAssets))] {
-		log.Printf("%d. %s (%s) - %s - %s",
-			i+1,
-			asset.Symbol,
-			asset.Name,
-			FormatMarketCap(asset.MarketCap),
-			asset.Type,
+		logger.Info("top asset",
+			"rank", i+1,
+			"symbol", asset.Ticker,
+			"name", asset.Name,
+			"market_cap", FormatMarketCap(asset.MarketCap),
+			"type", asset.AssetType,
 		)
 	}
 
-	// Save only in Supabase-compatible format (legacy JSON removed)
-	filename := "assets/stocks/us_supabase.json"
-	if err := SaveUSToSupabase(rankedAssets, filename); err != nil {
-		log.Printf("❌ Failed to save Supabase results: %v", err)
-	} else {
-		log.Printf("💾 Supabase data saved to %s (temporary - will be cleaned up)", filename)
+	outputs := &sink.Multi{
+		Sinks:  sinks,
+		Logger: logger,
+		Path:   outputPath,
+		Written: func(configured string, written sink.FileSink) {
+			publish(configured, written.Path())
+		},
+		Linked: func(written sink.OutputSink, link string) {
+			links = append(links, notify.Artifact{Name: written.String(), URL: link})
+		},
+	}
+	report.Error(outputs.Write(rankedAssets))
+	var movers *aggregate.Movers
+	if *aggregatesPrefix != "" {
+		derived := aggregate.Compute(rankedAssets, *topMovers, snapshot)
+		report.Error(derived.Write(*aggregatesPrefix, outputPath, publish))
+		movers = &derived.Movers
+	}
+	if *filingsPath != "" {
+		var stocks []string
+		for _, asset := range rankedAssets {
+			if asset.AssetType == "stock" {
+				stocks = append(stocks, asset.Ticker)
+			}
+		}
+		since := snapshot.UTC().AddDate(0, 0, -*filingsDays).Format("2006-01-02")
+		path := outputPath(*filingsPath)
+		if err := writeFilings(path, client.GetFilings(stocks, since)); err != nil {
+			report.Error(err)
+		} else {
+			publish(*filingsPath, path)
+		}
 	}
 
-	log.Printf("✅ Process completed successfully! Found and ranked %d NYSE/NASDAQ stocks only ($40B+ USD)", len(rankedAssets))
+	logger.Info("process completed", "ranked", len(rankedAssets))
+	writeReport()
+	if recorder != nil {
+		if err := recorder.Close(); err != nil {
+			logger.Warn("failed to finish recording", "dir", recorder.Dir, "error", err)
+		}
+	}
+	if runDir != nil {
+		manifest := output.ManifestFor(report.Build(), "fmp", len(rankedAssets), flag.CommandLine)
+		if recorder != nil {
+			manifest.Responses = recorder.Dir
+		}
+		if player != nil {
+			manifest.ReplayOf = player.Dir
+		}
+		if err := runDir.Finish(manifest); err != nil {
+			logger.Error("failed to publish run directory", "dir", runDir.Dir(), "error", err)
+		} else {
+			logger.Info("run directory published", "dir", runDir.Dir(), "latest", filepath.Join(*runsRoot, output.LatestRun))
+			deleted, err := output.PruneRunDirs(*runsRoot, *keepRuns)
+			if err != nil {
+				logger.Warn("failed to delete old run directories", "error", err)
+			} else if len(deleted) > 0 {
+				logger.Info("old run directories deleted", "deleted", len(deleted), "kept", *keepRuns)
+			}
+		}
+	}
+	notifyRun(rankedAssets, nil, movers)
 }
@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"algotradar/domain"
+	"algotradar/output"
+)
+
+// snapshotDatePattern matches the YYYY-MM-DD stamp the collectors put in file
+// names, optionally followed by a THHMMZ run time from --timestamped-output,
+// in JSON files that may be compressed
+var snapshotDatePattern = regexp.MustCompile(`(\d{4}-\d{2}-\d{2})(T\d{4}Z)?\.json(\.gz|\.zst)?$`)
+
+// LoadArchive reads all dated snapshot files in dir whose name starts with prefix
+// (e.g. "global_assets_fmp_") and returns them ordered by date
+func LoadArchive(dir, prefix string) ([]domain.Snapshot, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, prefix+"*.json*"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archive: %w", err)
+	}
+
+	// With several runs on one day only the latest is used; timestamped names
+	// sort after the plain dated name and in run order among themselves
+	sort.Strings(paths)
+	byDate := make(map[string]string)
+	for _, path := range paths {
+		if match := snapshotDatePattern.FindStringSubmatch(filepath.Base(path)); match != nil {
+			byDate[match[1]] = path
+		}
+	}
+
+	var snapshots []domain.Snapshot
+	for _, path := range paths {
+		match := snapshotDatePattern.FindStringSubmatch(filepath.Base(path))
+		if match == nil || byDate[match[1]] != path {
+			continue
+		}
+
+		date, err := time.Parse("2006-01-02", match[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid snapshot date in %s: %w", path, err)
+		}
+
+		data, err := output.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read snapshot %s: %w", path, err)
+		}
+
+		var assets []domain.Asset
+		if err := json.Unmarshal(data, &assets); err != nil {
+			return nil, fmt.Errorf("failed to parse snapshot %s: %w", path, err)
+		}
+
+		snapshots = append(snapshots, domain.Snapshot{Date: date, Path: path, Assets: assets})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Date.Before(snapshots[j].Date)
+	})
+
+	return snapshots, nil
+}
@@ -0,0 +1,236 @@
+package engine
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"algotradar/domain"
+)
+
+// Benchmark is a daily close series used to judge strategy returns
+type Benchmark struct {
+	Name   string
+	closes map[string]float64 // keyed by YYYY-MM-DD
+}
+
+// Close returns the benchmark close on date, or false if none was recorded
+func (b *Benchmark) Close(date time.Time) (float64, bool) {
+	v, ok := b.closes[date.Format("2006-01-02")]
+	return v, ok && v > 0
+}
+
+// Return computes the benchmark return between two dates
+func (b *Benchmark) Return(from, to time.Time) (float64, bool) {
+	start, ok := b.Close(from)
+	if !ok {
+		return 0, false
+	}
+	end, ok := b.Close(to)
+	if !ok {
+		return 0, false
+	}
+	return end/start - 1, true
+}
+
+// LoadBenchmarkCSV reads a date/close CSV as written by the benchmark collector.
+// The header must contain "date" and "close" columns; rows with unparsable dates are skipped.
+func LoadBenchmarkCSV(path, name string) (*Benchmark, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open benchmark: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read benchmark: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, errors.New("benchmark file is empty")
+	}
+
+	dateCol, closeCol := 0, -1
+	for i, col := range records[0] {
+		switch strings.ToLower(strings.TrimSpace(strings.TrimPrefix(col, "\xEF\xBB\xBF"))) {
+		case "date":
+			dateCol = i
+		case "close":
+			closeCol = i
+		}
+	}
+	if closeCol < 0 {
+		return nil, errors.New("benchmark file has no close column")
+	}
+
+	bench := &Benchmark{Name: name, closes: make(map[string]float64)}
+	for _, record := range records[1:] {
+		if len(record) <= dateCol || len(record) <= closeCol {
+			continue
+		}
+		raw := strings.TrimSpace(record[dateCol])
+		if len(raw) < 10 {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", raw[:10])
+		if err != nil {
+			continue
+		}
+		close, err := strconv.ParseFloat(strings.TrimSpace(record[closeCol]), 64)
+		if err != nil {
+			continue
+		}
+		bench.closes[date.Format("2006-01-02")] = close
+	}
+
+	if len(bench.closes) == 0 {
+		return nil, fmt.Errorf("no benchmark closes parsed from %s", path)
+	}
+	return bench, nil
+}
+
+// BenchmarkFromArchive builds a benchmark from the price of ticker in each snapshot
+func BenchmarkFromArchive(snapshots []domain.Snapshot, ticker string) (*Benchmark, error) {
+	bench := &Benchmark{Name: ticker, closes: make(map[string]float64)}
+	for _, snap := range snapshots {
+		if price, ok := snap.Price(ticker); ok {
+			bench.closes[snap.Date.Format("2006-01-02")] = price
+		}
+	}
+	if len(bench.closes) == 0 {
+		return nil, fmt.Errorf("benchmark %s not found in archive", ticker)
+	}
+	return bench, nil
+}
+
+// BenchmarkFromConstituents builds an index from the archive prices of its
+// constituents, e.g. the members of an index the archive lacks a series for.
+// The index starts at 100 and moves each period by the constituents' return,
+// weighted by their market cap at the start of the period (equally when no
+// caps are known); constituents missing on either date sit that period out.
+func BenchmarkFromConstituents(snapshots []domain.Snapshot, name string, tickers []string) (*Benchmark, error) {
+	if len(tickers) == 0 {
+		return nil, errors.New("benchmark has no constituents")
+	}
+	members := make(map[string]bool, len(tickers))
+	for _, ticker := range tickers {
+		members[strings.TrimSpace(ticker)] = true
+	}
+
+	bench := &Benchmark{Name: name, closes: make(map[string]float64)}
+	level := 100.0
+	var prev map[string]domain.Asset
+	for _, snap := range snapshots {
+		cur := make(map[string]domain.Asset)
+		for _, asset := range snap.Assets {
+			if members[asset.Ticker] && asset.CurrentPrice > 0 {
+				cur[asset.Ticker] = asset
+			}
+		}
+		if len(cur) == 0 {
+			continue
+		}
+		if prev != nil {
+			var sum, weight, equalSum float64
+			n := 0
+			for ticker, before := range prev {
+				after, ok := cur[ticker]
+				if !ok {
+					continue
+				}
+				r := after.CurrentPrice/before.CurrentPrice - 1
+				sum += before.MarketCap * r
+				weight += before.MarketCap
+				equalSum += r
+				n++
+			}
+			switch {
+			case weight > 0:
+				level *= 1 + sum/weight
+			case n > 0:
+				level *= 1 + equalSum/float64(n)
+			}
+		}
+		bench.closes[snap.Date.Format("2006-01-02")] = level
+		prev = cur
+	}
+	if len(bench.closes) == 0 {
+		return nil, fmt.Errorf("no constituents of benchmark %s found in archive", name)
+	}
+	return bench, nil
+}
+
+// RelativeMetrics holds benchmark-relative statistics of a return series
+type RelativeMetrics struct {
+	Alpha            float64 // annualized Jensen's alpha
+	Beta             float64
+	TrackingError    float64 // annualized standard deviation of active returns
+	InformationRatio float64 // annualized mean active return over tracking error
+}
+
+// CompareToBenchmark computes alpha, beta, tracking error and information ratio for
+// aligned per-period returns. periodsPerYear annualizes the results (252 for daily).
+func CompareToBenchmark(returns, benchmark []float64, periodsPerYear float64) (RelativeMetrics, error) {
+	if len(returns) != len(benchmark) {
+		return RelativeMetrics{}, errors.New("return series are not aligned")
+	}
+	n := len(returns)
+	if n < 2 {
+		return RelativeMetrics{}, errors.New("need at least two periods to compare to benchmark")
+	}
+
+	meanR, meanB := mean(returns), mean(benchmark)
+
+	var cov, varB float64
+	active := make([]float64, n)
+	for i := range returns {
+		cov += (returns[i] - meanR) * (benchmark[i] - meanB)
+		varB += (benchmark[i] - meanB) * (benchmark[i] - meanB)
+		active[i] = returns[i] - benchmark[i]
+	}
+	cov /= float64(n - 1)
+	varB /= float64(n - 1)
+
+	var metrics RelativeMetrics
+	if varB > 0 {
+		metrics.Beta = cov / varB
+	}
+	metrics.Alpha = (meanR - metrics.Beta*meanB) * periodsPerYear
+
+	te := stdDev(active)
+	metrics.TrackingError = te * math.Sqrt(periodsPerYear)
+	if te > 0 {
+		metrics.InformationRatio = mean(active) / te * math.Sqrt(periodsPerYear)
+	}
+	return metrics, nil
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func stdDev(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	m := mean(values)
+	sq := 0.0
+	for _, v := range values {
+		sq += (v - m) * (v - m)
+	}
+	return math.Sqrt(sq / float64(len(values)-1))
+}
@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"algotradar/domain"
+	"algotradar/fx"
+	"algotradar/refdata"
+)
+
+// CommissionSpec is the cost of trading on one exchange, in basis points of notional
+type CommissionSpec struct {
+	CommissionBps float64 `json:"commission_bps"` // charged on every buy and sell
+	SpreadBps     float64 `json:"spread_bps"`     // full bid/ask spread; half is paid per side
+}
+
+// CostModel prices trades per exchange plus FX conversion for non-base currencies
+type CostModel struct {
+	BaseCurrency string                    `json:"base_currency"`
+	Default      CommissionSpec            `json:"default"`
+	Exchanges    map[string]CommissionSpec `json:"exchanges"`
+	FXBps        float64                   `json:"fx_bps"` // charged on each currency conversion
+	// FXCurrencyBps overrides FXBps per quote currency, e.g. a wider
+	// conversion cost for TRY than for EUR
+	FXCurrencyBps map[string]float64 `json:"fx_currency_bps,omitempty"`
+}
+
+// TradeCost is the breakdown of costs for a single trade, in the same units as the notional
+type TradeCost struct {
+	Commission float64
+	Spread     float64
+	FX         float64
+}
+
+// Total returns the sum of all cost components
+func (t TradeCost) Total() float64 {
+	return t.Commission + t.Spread + t.FX
+}
+
+// LoadCostModel reads a cost model from a JSON file
+func LoadCostModel(path string) (*CostModel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cost model: %w", err)
+	}
+	return ParseCostModel(data)
+}
+
+// ParseCostModel decodes a cost model, e.g. the costs section of a backtest
+// config file
+func ParseCostModel(data []byte) (*CostModel, error) {
+	var model CostModel
+	if err := json.Unmarshal(data, &model); err != nil {
+		return nil, fmt.Errorf("failed to parse cost model: %w", err)
+	}
+	if model.BaseCurrency == "" {
+		model.BaseCurrency = "USD"
+	}
+	return &model, nil
+}
+
+// Currency returns the quote currency of an asset, preferring exchange reference data
+func Currency(asset domain.Asset) string {
+	if exchange, ok := refdata.LookupExchange(asset.PrimaryExchange); ok {
+		return exchange.Currency
+	}
+	return fx.DetectCurrency(asset.Ticker, asset.Country)
+}
+
+// TradeCost prices one side of a trade of the given notional in asset
+func (m *CostModel) TradeCost(asset domain.Asset, notional float64) TradeCost {
+	if m == nil || notional == 0 {
+		return TradeCost{}
+	}
+
+	spec := m.Default
+	if s, ok := m.Exchanges[strings.ToUpper(asset.PrimaryExchange)]; ok {
+		spec = s
+	}
+
+	cost := TradeCost{
+		Commission: notional * spec.CommissionBps / 1e4,
+		Spread:     notional * spec.SpreadBps / 2 / 1e4,
+	}
+
+	base := m.BaseCurrency
+	if base == "" {
+		base = "USD"
+	}
+	if currency := Currency(asset); currency != base {
+		bps := m.FXBps
+		if b, ok := m.FXCurrencyBps[strings.ToUpper(currency)]; ok {
+			bps = b
+		}
+		cost.FX = notional * bps / 1e4
+	}
+	return cost
+}
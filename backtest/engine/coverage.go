@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"sort"
+	"time"
+
+	"algotradar/domain"
+)
+
+// Datasets checked by the coverage report, in display order. dividends is
+// the rows' dividend_yield, which get_companies writes only for REITs under
+// --enrich reit or --asset-class reit, and the US collector for quotes with a
+// yield; archives of other runs report no dividend coverage.
+var Datasets = []string{"price", "fundamentals", "dividends"}
+
+// hasDataset reports whether an archive row carries the given dataset
+func hasDataset(asset domain.Asset, dataset string) bool {
+	switch dataset {
+	case "price":
+		return asset.CurrentPrice > 0
+	case "fundamentals":
+		return asset.MarketCap > 0 && (asset.Sector != "" || asset.Industry != "")
+	case "dividends":
+		return asset.DividendYield != nil
+	}
+	return false
+}
+
+// DateRange is an inclusive span of archive dates
+type DateRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	Days int       `json:"snapshots"` // number of archive snapshots in the span
+}
+
+// DatasetCoverage describes where one dataset exists for a ticker
+type DatasetCoverage struct {
+	Available int         `json:"available"` // snapshots with the dataset present
+	First     time.Time   `json:"first,omitempty"`
+	Last      time.Time   `json:"last,omitempty"`
+	Gaps      []DateRange `json:"gaps,omitempty"` // missing spans between First and Last
+}
+
+// TickerCoverage is one row of the availability matrix
+type TickerCoverage struct {
+	Ticker   string                     `json:"ticker"`
+	Datasets map[string]DatasetCoverage `json:"datasets"`
+}
+
+// Coverage builds the availability matrix for every ticker in the archive
+func Coverage(snapshots []domain.Snapshot) []TickerCoverage {
+	// presence[ticker][dataset][i] is true when snapshot i carries the dataset
+	presence := make(map[string]map[string][]bool)
+	for i, snap := range snapshots {
+		for _, asset := range snap.Assets {
+			byDataset, ok := presence[asset.Ticker]
+			if !ok {
+				byDataset = make(map[string][]bool)
+				for _, dataset := range Datasets {
+					byDataset[dataset] = make([]bool, len(snapshots))
+				}
+				presence[asset.Ticker] = byDataset
+			}
+			for _, dataset := range Datasets {
+				if hasDataset(asset, dataset) {
+					byDataset[dataset][i] = true
+				}
+			}
+		}
+	}
+
+	rows := make([]TickerCoverage, 0, len(presence))
+	for ticker, byDataset := range presence {
+		row := TickerCoverage{Ticker: ticker, Datasets: make(map[string]DatasetCoverage, len(Datasets))}
+		for _, dataset := range Datasets {
+			row.Datasets[dataset] = datasetCoverage(snapshots, byDataset[dataset])
+		}
+		rows = append(rows, row)
+	}
+
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Ticker < rows[j].Ticker })
+	return rows
+}
+
+func datasetCoverage(snapshots []domain.Snapshot, present []bool) DatasetCoverage {
+	var cov DatasetCoverage
+	first, last := -1, -1
+	for i, ok := range present {
+		if !ok {
+			continue
+		}
+		cov.Available++
+		if first < 0 {
+			first = i
+		}
+		last = i
+	}
+	if first < 0 {
+		return cov
+	}
+
+	cov.First = snapshots[first].Date
+	cov.Last = snapshots[last].Date
+
+	gapStart := -1
+	for i := first; i <= last; i++ {
+		switch {
+		case !present[i] && gapStart < 0:
+			gapStart = i
+		case present[i] && gapStart >= 0:
+			cov.Gaps = append(cov.Gaps, DateRange{
+				From: snapshots[gapStart].Date,
+				To:   snapshots[i-1].Date,
+				Days: i - gapStart,
+			})
+			gapStart = -1
+		}
+	}
+	return cov
+}
@@ -0,0 +1,153 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"algotradar/domain"
+)
+
+// Filings indexes SEC filings by ticker, oldest first
+type Filings map[string][]domain.Filing
+
+// NewFilings indexes filings by ticker, skipping entries without a valid
+// filing date
+func NewFilings(filings []domain.Filing) Filings {
+	f := make(Filings)
+	for _, filing := range filings {
+		if _, err := time.Parse("2006-01-02", filing.Filed); err != nil || filing.Symbol == "" {
+			continue
+		}
+		f[filing.Symbol] = append(f[filing.Symbol], filing)
+	}
+	for _, list := range f {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].Filed < list[j].Filed })
+	}
+	return f
+}
+
+// LoadFilings reads the SEC filings written by the US collector's --filings
+func LoadFilings(path string) (Filings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SEC filings: %w", err)
+	}
+	var filings []domain.Filing
+	if err := json.Unmarshal(data, &filings); err != nil {
+		return nil, fmt.Errorf("failed to parse SEC filings: %w", err)
+	}
+	return NewFilings(filings), nil
+}
+
+// marketClose is the hour, in US Eastern time, after which a filing can
+// only move prices the next trading day
+const marketClose = 16
+
+// eastern is nil when the host has no zoneinfo, which treats every filing
+// as made during the session
+var eastern, _ = time.LoadLocation("America/New_York")
+
+// afterClose reports whether filing was accepted after the close of its
+// filing date
+func afterClose(filing domain.Filing) bool {
+	accepted, err := time.Parse(time.RFC3339, filing.Accepted)
+	if err != nil || eastern == nil {
+		return false
+	}
+	local := accepted.In(eastern)
+	return local.Format("2006-01-02") == filing.Filed && local.Hour() >= marketClose
+}
+
+// EventStudy is the average price path of companies around their filings.
+// Offsets run from -Before to After snapshots around the event day, the
+// first snapshot on which the market could react; returns are relative to
+// the close of the snapshot before the window.
+type EventStudy struct {
+	Types      []string  `json:"types"`
+	Before     int       `json:"before"`
+	After      int       `json:"after"`
+	Events     int       `json:"events"`  // filings with prices across the whole window
+	Skipped    int       `json:"skipped"` // filings too close to the archive's ends or missing prices
+	Offsets    []int     `json:"offsets"`
+	MeanReturn []float64 `json:"mean_return"` // cumulative, per offset
+	HitRate    []float64 `json:"hit_rate"`    // share of events up since the reference close, per offset
+}
+
+// StudyFilings averages the price path of each ticker around its filings
+// of the given types (all types when empty). snapshots must be ordered by
+// date.
+func StudyFilings(snapshots []domain.Snapshot, filings Filings, types []string, before, after int) EventStudy {
+	study := EventStudy{Types: types, Before: before, After: after}
+	width := before + after + 1
+	for k := -before; k <= after; k++ {
+		study.Offsets = append(study.Offsets, k)
+	}
+	study.MeanReturn = make([]float64, width)
+	study.HitRate = make([]float64, width)
+
+	dates := make([]string, len(snapshots))
+	prices := make([]map[string]float64, len(snapshots))
+	for i, snap := range snapshots {
+		dates[i] = snap.Date.Format("2006-01-02")
+		prices[i] = make(map[string]float64, len(snap.Assets))
+		for _, asset := range snap.Assets {
+			if asset.CurrentPrice > 0 {
+				prices[i][asset.Ticker] = asset.CurrentPrice
+			}
+		}
+	}
+	wanted := make(map[string]bool, len(types))
+	for _, t := range types {
+		wanted[t] = true
+	}
+
+	for ticker, list := range filings {
+		for _, filing := range list {
+			if len(wanted) > 0 && !wanted[filing.Type] {
+				continue
+			}
+			// The event snapshot is the first dated on the filing date, or
+			// after it when the filing came out after the close
+			late := afterClose(filing)
+			day0 := sort.Search(len(dates), func(i int) bool {
+				if late {
+					return dates[i] > filing.Filed
+				}
+				return dates[i] >= filing.Filed
+			})
+			ref := day0 - before - 1
+			if ref < 0 || day0+after >= len(dates) {
+				study.Skipped++
+				continue
+			}
+			base, ok := prices[ref][ticker]
+			path := make([]float64, width)
+			for k := 0; ok && k < width; k++ {
+				var price float64
+				price, ok = prices[day0-before+k][ticker]
+				path[k] = price/base - 1
+			}
+			if !ok {
+				study.Skipped++
+				continue
+			}
+			study.Events++
+			for k, r := range path {
+				study.MeanReturn[k] += r
+				if r > 0 {
+					study.HitRate[k]++
+				}
+			}
+		}
+	}
+	if study.Events > 0 {
+		for k := range study.MeanReturn {
+			study.MeanReturn[k] /= float64(study.Events)
+			study.HitRate[k] /= float64(study.Events)
+		}
+	}
+	return study
+}
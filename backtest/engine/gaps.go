@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"algotradar/domain"
+	"algotradar/integrity"
+)
+
+// GapMode selects how missing observations in the archive are handled
+type GapMode string
+
+const (
+	// GapDrop leaves missing rows out and removes rows without a usable price
+	GapDrop GapMode = "drop"
+	// GapForwardFill repeats the last observed row for up to MaxFillDays calendar days
+	GapForwardFill GapMode = "ffill"
+	// GapMarkNaN inserts a row with a NaN price for up to MaxFillDays so strategies see the gap explicitly
+	GapMarkNaN GapMode = "nan"
+)
+
+// GapPolicy is applied by the Loader so every strategy sees the same filled history
+type GapPolicy struct {
+	Mode        GapMode
+	MaxFillDays int // fill horizon after the last observation; 0 means no limit
+}
+
+// ParseGapMode validates a gap mode name
+func ParseGapMode(mode string) (GapMode, error) {
+	switch GapMode(strings.ToLower(mode)) {
+	case "", GapDrop:
+		return GapDrop, nil
+	case GapForwardFill:
+		return GapForwardFill, nil
+	case GapMarkNaN:
+		return GapMarkNaN, nil
+	}
+	return "", fmt.Errorf("unknown gap policy %q (want drop, ffill or nan)", mode)
+}
+
+// Apply returns a copy of snapshots with the policy applied. Filling only ever uses
+// earlier observations, and only between a ticker's first appearance and the fill
+// horizon after its last, so no future data leaks into the past.
+func (p GapPolicy) Apply(snapshots []domain.Snapshot) []domain.Snapshot {
+	out := make([]domain.Snapshot, len(snapshots))
+	for i, snap := range snapshots {
+		out[i] = domain.Snapshot{Date: snap.Date, Path: snap.Path}
+		for _, asset := range snap.Assets {
+			if p.Mode == GapDrop && !(asset.CurrentPrice > 0) {
+				continue
+			}
+			out[i].Assets = append(out[i].Assets, asset)
+		}
+	}
+	if p.Mode == GapDrop {
+		return out
+	}
+
+	type lastSeen struct {
+		asset domain.Asset
+		date  time.Time
+	}
+	last := make(map[string]lastSeen)
+
+	for i := range out {
+		present := make(map[string]bool, len(out[i].Assets))
+		for _, asset := range out[i].Assets {
+			present[asset.Ticker] = true
+		}
+
+		var fills []domain.Asset
+		for ticker, seen := range last {
+			if present[ticker] {
+				continue
+			}
+			if p.MaxFillDays > 0 && out[i].Date.Sub(seen.date) > time.Duration(p.MaxFillDays)*24*time.Hour {
+				continue
+			}
+
+			fill := seen.asset
+			fill.Filled = true
+			if p.Mode == GapMarkNaN {
+				fill.CurrentPrice = math.NaN()
+				fill.PreviousClose = math.NaN()
+				fill.PercentageChange = math.NaN()
+			}
+			fills = append(fills, fill)
+		}
+		sort.Slice(fills, func(a, b int) bool { return fills[a].Ticker < fills[b].Ticker })
+		out[i].Assets = append(out[i].Assets, fills...)
+
+		for _, asset := range out[i].Assets {
+			if !asset.Filled {
+				last[asset.Ticker] = lastSeen{asset: asset, date: out[i].Date}
+			}
+		}
+	}
+	return out
+}
+
+// Loader reads the archive, verifies snapshot integrity and applies a gap policy in one place
+type Loader struct {
+	Dir    string
+	Prefix string
+	Gaps   GapPolicy
+
+	// RequireChecksums rejects snapshots without a .sha256 sidecar; otherwise only
+	// snapshots that have one are verified
+	RequireChecksums bool
+	// VerifyKey, when set, also requires a valid signature on every snapshot
+	VerifyKey ed25519.PublicKey
+}
+
+// Load reads the archive, verifies checksums and applies the loader's gap policy
+func (l Loader) Load() ([]domain.Snapshot, error) {
+	snapshots, err := LoadArchive(l.Dir, l.Prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, snap := range snapshots {
+		err := integrity.Verify(snap.Path, l.VerifyKey)
+		if errors.Is(err, integrity.ErrNoChecksum) && !l.RequireChecksums && l.VerifyKey == nil {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("snapshot failed integrity check: %w", err)
+		}
+	}
+
+	return l.Gaps.Apply(snapshots), nil
+}
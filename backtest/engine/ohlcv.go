@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"algotradar/domain"
+)
+
+// LoadOHLCV reads daily bars from one CSV per ticker in dir, named
+// TICKER.csv, and returns them as snapshots ordered by date, so strategies
+// can run over price history the collectors never snapshotted. Each file
+// needs "date" and "close" columns; "volume" and "market_cap" are used when
+// present and other columns (open, high, low) are ignored. A snapshot holds
+// the tickers that have a bar that day.
+func LoadOHLCV(dir string) ([]domain.Snapshot, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.csv"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list OHLCV files: %w", err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no OHLCV files in %s", dir)
+	}
+	sort.Strings(paths)
+
+	byDate := make(map[string][]domain.Asset)
+	for _, path := range paths {
+		ticker := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		bars, err := readOHLCV(path, ticker)
+		if err != nil {
+			return nil, err
+		}
+		for date, asset := range bars {
+			byDate[date] = append(byDate[date], asset)
+		}
+	}
+
+	snapshots := make([]domain.Snapshot, 0, len(byDate))
+	for day, assets := range byDate {
+		date, _ := time.Parse("2006-01-02", day)
+		domain.SortAssets(assets)
+		snapshots = append(snapshots, domain.Snapshot{Date: date, Path: dir, Assets: assets})
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Date.Before(snapshots[j].Date)
+	})
+	return snapshots, nil
+}
+
+// readOHLCV parses one ticker's bars keyed by YYYY-MM-DD; rows with an
+// unparsable date or close are skipped
+func readOHLCV(path, ticker string) (map[string]domain.Asset, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open OHLCV file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s is empty", path)
+	}
+
+	dateCol, closeCol, volumeCol, capCol := -1, -1, -1, -1
+	for i, col := range records[0] {
+		switch strings.ToLower(strings.TrimSpace(strings.TrimPrefix(col, "\xEF\xBB\xBF"))) {
+		case "date":
+			dateCol = i
+		case "close":
+			closeCol = i
+		case "volume":
+			volumeCol = i
+		case "market_cap":
+			capCol = i
+		}
+	}
+	if dateCol < 0 || closeCol < 0 {
+		return nil, errors.New(path + " needs date and close columns")
+	}
+
+	number := func(record []string, col int) float64 {
+		if col < 0 || col >= len(record) {
+			return 0
+		}
+		v, _ := strconv.ParseFloat(strings.TrimSpace(record[col]), 64)
+		return v
+	}
+	bars := make(map[string]domain.Asset)
+	for _, record := range records[1:] {
+		if len(record) <= dateCol || len(record) <= closeCol {
+			continue
+		}
+		raw := strings.TrimSpace(record[dateCol])
+		if len(raw) < 10 {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", raw[:10])
+		if err != nil {
+			continue
+		}
+		close := number(record, closeCol)
+		if close <= 0 {
+			continue
+		}
+		bars[date.Format("2006-01-02")] = domain.Asset{
+			Ticker:       ticker,
+			Name:         ticker,
+			CurrentPrice: close,
+			Volume:       number(record, volumeCol),
+			MarketCap:    number(record, capCol),
+			AssetType:    "stock",
+		}
+	}
+	if len(bars) == 0 {
+		return nil, fmt.Errorf("no bars parsed from %s", path)
+	}
+	return bars, nil
+}
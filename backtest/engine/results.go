@@ -0,0 +1,274 @@
+package engine
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"algotradar/canonical"
+)
+
+// PeriodReturn is the compounded return of one calendar period, e.g. a
+// month as "2024-03" or a year as "2024"
+type PeriodReturn struct {
+	Period string  `json:"period"`
+	Return float64 `json:"return"`
+}
+
+// Performance summarizes a simulation. Returns and costs are fractions
+// (0.05 is 5%); costs are relative to the initial capital. Ratios are
+// annualized and assume a zero risk-free rate.
+type Performance struct {
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	FinalValue float64   `json:"final_value"`
+
+	TotalReturn float64 `json:"total_return"`
+	CAGR        float64 `json:"cagr"`
+	Volatility  float64 `json:"volatility"`
+	Sharpe      float64 `json:"sharpe"`
+	Sortino     float64 `json:"sortino"`
+
+	// MaxDrawdown is the largest fall from a peak, between the dates of the
+	// peak and the trough
+	MaxDrawdown    float64   `json:"max_drawdown"`
+	DrawdownPeak   time.Time `json:"drawdown_peak"`
+	DrawdownTrough time.Time `json:"drawdown_trough"`
+
+	// Turnover is traded notional over portfolio value, summed over
+	// rebalances; AnnualTurnover spreads it over the years simulated
+	Turnover       float64 `json:"turnover"`
+	AnnualTurnover float64 `json:"annual_turnover"`
+	Costs          float64 `json:"costs"`
+	Slippage       float64 `json:"slippage"`
+	Trades         int     `json:"trades"`
+
+	// Benchmark compares the portfolio with SimulationConfig.Benchmark; nil
+	// without one
+	Benchmark *BenchmarkPerformance `json:"benchmark,omitempty"`
+
+	PeriodsPerYear float64        `json:"periods_per_year"`
+	Monthly        []PeriodReturn `json:"monthly_returns"`
+	Yearly         []PeriodReturn `json:"yearly_returns"`
+}
+
+// BenchmarkPerformance is the portfolio relative to a benchmark over the
+// periods both have returns for. Alpha, tracking error and the information
+// ratio are annualized.
+type BenchmarkPerformance struct {
+	Name             string  `json:"name"`
+	Return           float64 `json:"return"` // over the simulation's first to last date
+	ExcessReturn     float64 `json:"excess_return"`
+	Alpha            float64 `json:"alpha"`
+	Beta             float64 `json:"beta"`
+	TrackingError    float64 `json:"tracking_error"`
+	InformationRatio float64 `json:"information_ratio"`
+	Periods          int     `json:"periods"`
+}
+
+// Analyze computes the performance of a simulation. Each point's return is
+// measured from the point before it, the first from the initial capital, so
+// entry costs count. periodsPerYear annualizes volatility and the ratios; 0
+// infers it from the number of points and the dates they span.
+func Analyze(result *SimulationResult, periodsPerYear float64) Performance {
+	points := result.Points
+	if len(points) == 0 {
+		return Performance{}
+	}
+	capital := result.InitialCapital
+	first, last := points[0], points[len(points)-1]
+	perf := Performance{
+		Start:       first.Date,
+		End:         last.Date,
+		FinalValue:  last.Value,
+		TotalReturn: last.Value/capital - 1,
+		Trades:      len(result.Trades),
+	}
+
+	returns := PeriodReturns(result)
+	years := last.Date.Sub(first.Date).Hours() / 24 / 365.25
+	if periodsPerYear <= 0 {
+		periodsPerYear = observedPeriodsPerYear(first.Date, last.Date, len(points)-1)
+	}
+	perf.PeriodsPerYear = periodsPerYear
+	if years > 0 && last.Value > 0 {
+		perf.CAGR = math.Pow(last.Value/capital, 1/years) - 1
+	}
+	if periodsPerYear > 0 && len(returns) > 1 {
+		perf.Volatility = stdDev(returns) * math.Sqrt(periodsPerYear)
+		if perf.Volatility > 0 {
+			perf.Sharpe = mean(returns) * periodsPerYear / perf.Volatility
+		}
+		if downside := downsideDeviation(returns) * math.Sqrt(periodsPerYear); downside > 0 {
+			perf.Sortino = mean(returns) * periodsPerYear / downside
+		}
+	}
+
+	peak, peakDate := capital, first.Date
+	for _, p := range points {
+		if p.Value > peak {
+			peak, peakDate = p.Value, p.Date
+		}
+		if dd := 1 - p.Value/peak; peak > 0 && dd > perf.MaxDrawdown {
+			perf.MaxDrawdown, perf.DrawdownPeak, perf.DrawdownTrough = dd, peakDate, p.Date
+		}
+		perf.Turnover += p.Turnover
+	}
+	if years > 0 {
+		perf.AnnualTurnover = perf.Turnover / years
+	}
+	for _, t := range result.Trades {
+		perf.Costs += t.Cost / capital
+		perf.Slippage += t.Slippage / capital
+	}
+
+	if result.Benchmark != nil {
+		perf.Benchmark = compareBenchmark(result, returns, periodsPerYear)
+	}
+
+	perf.Monthly = calendarReturns(points, returns, "2006-01")
+	perf.Yearly = calendarReturns(points, returns, "2006")
+	return perf
+}
+
+// observedPeriodsPerYear is how many of the periods between first and last
+// fit in a year, 0 when they span no time
+func observedPeriodsPerYear(first, last time.Time, periods int) float64 {
+	years := last.Sub(first).Hours() / 24 / 365.25
+	if years <= 0 {
+		return 0
+	}
+	return float64(periods) / years
+}
+
+// compareBenchmark lines the portfolio's period returns up with the
+// benchmark's over the same dates; nil when the benchmark covers neither end
+// of the simulation nor two periods
+func compareBenchmark(result *SimulationResult, returns []float64, periodsPerYear float64) *BenchmarkPerformance {
+	points := result.Points
+	cmp := &BenchmarkPerformance{Name: result.Benchmark.Name}
+	var portfolio, bench []float64
+	for i := 1; i < len(points); i++ {
+		if b, ok := result.Benchmark.Return(points[i-1].Date, points[i].Date); ok {
+			portfolio = append(portfolio, returns[i])
+			bench = append(bench, b)
+		}
+	}
+	cmp.Periods = len(bench)
+
+	first, last := points[0], points[len(points)-1]
+	total, haveTotal := result.Benchmark.Return(first.Date, last.Date)
+	rel, err := CompareToBenchmark(portfolio, bench, periodsPerYear)
+	if !haveTotal && err != nil {
+		return nil
+	}
+	if haveTotal {
+		cmp.Return = total
+		cmp.ExcessReturn = last.Value/result.InitialCapital - 1 - total
+	}
+	if err == nil {
+		cmp.Alpha, cmp.Beta = rel.Alpha, rel.Beta
+		cmp.TrackingError, cmp.InformationRatio = rel.TrackingError, rel.InformationRatio
+	}
+	return cmp
+}
+
+// PeriodReturns returns the return of each point of the value path, the
+// first measured from the initial capital
+func PeriodReturns(result *SimulationResult) []float64 {
+	returns := make([]float64, len(result.Points))
+	prev := result.InitialCapital
+	for i, p := range result.Points {
+		if prev > 0 {
+			returns[i] = p.Value/prev - 1
+		}
+		prev = p.Value
+	}
+	return returns
+}
+
+// calendarReturns compounds the returns of the points falling in each
+// calendar period, named by layout
+func calendarReturns(points []PortfolioPoint, returns []float64, layout string) []PeriodReturn {
+	var out []PeriodReturn
+	for i, p := range points {
+		period := p.Date.Format(layout)
+		if len(out) == 0 || out[len(out)-1].Period != period {
+			out = append(out, PeriodReturn{Period: period})
+		}
+		last := &out[len(out)-1]
+		last.Return = (1+last.Return)*(1+returns[i]) - 1
+	}
+	return out
+}
+
+// downsideDeviation is the root mean square of the negative returns, the
+// risk measure of the Sortino ratio
+func downsideDeviation(returns []float64) float64 {
+	if len(returns) == 0 {
+		return 0
+	}
+	sq := 0.0
+	for _, r := range returns {
+		if r < 0 {
+			sq += r * r
+		}
+	}
+	return math.Sqrt(sq / float64(len(returns)))
+}
+
+// WriteJSON saves the result, performance included, as canonical JSON
+func (r *SimulationResult) WriteJSON(path string) error {
+	data, err := canonical.JSON(r)
+	if err != nil {
+		return fmt.Errorf("failed to marshal simulation result: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write simulation result: %w", err)
+	}
+	return nil
+}
+
+// WriteEquityCurve saves the value path as CSV: per date the portfolio value,
+// cash, the period's return, the drawdown from the running peak, turnover,
+// costs paid and the benchmark's value had it been bought with the initial
+// capital (empty without a benchmark close that date)
+func (r *SimulationResult) WriteEquityCurve(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create equity curve: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"date", "value", "cash", "holdings", "return", "drawdown", "turnover", "costs", "benchmark"})
+	returns := PeriodReturns(r)
+	peak := r.InitialCapital
+	format := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	for i, p := range r.Points {
+		// The benchmark is rebased to the initial capital on the first date
+		benchmark := ""
+		if r.Benchmark != nil {
+			if ret, ok := r.Benchmark.Return(r.Points[0].Date, p.Date); ok {
+				benchmark = format(r.InitialCapital * (1 + ret))
+			}
+		}
+		peak = math.Max(peak, p.Value)
+		drawdown := 0.0
+		if peak > 0 {
+			drawdown = 1 - p.Value/peak
+		}
+		writer.Write([]string{
+			p.Date.Format("2006-01-02"), format(p.Value), format(p.Cash), strconv.Itoa(p.Holdings),
+			format(returns[i]), format(drawdown), format(p.Turnover), format(p.Costs), benchmark,
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write equity curve: %w", err)
+	}
+	return file.Close()
+}
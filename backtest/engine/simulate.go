@@ -0,0 +1,194 @@
+package engine
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"algotradar/domain"
+)
+
+// SimulationConfig sets up a portfolio simulation
+type SimulationConfig struct {
+	InitialCapital float64 // default 1, so values read as growth of one unit
+	// RebalanceEvery is the number of snapshots between rebalances; 0 or 1
+	// rebalances on every snapshot
+	RebalanceEvery int
+	Costs          *CostModel // nil trades without commission, spread or FX costs
+	// SlippageBps is the adverse price move paid on each trade, in basis
+	// points of the traded notional, on top of the cost model's spread
+	SlippageBps    float64
+	PeriodsPerYear float64    // for annualized metrics; 0 infers it from the dates
+	Benchmark      *Benchmark // nil skips benchmark-relative metrics
+	// Delistings closes positions in delisted names at their last price on
+	// the first snapshot on or after the delisting, rather than carrying them
+	Delistings Delistings
+}
+
+// Trade is one order filled on a rebalance date. Notional is in portfolio
+// currency, positive for buys; Cost and Slippage are what the fill paid.
+type Trade struct {
+	Date     time.Time `json:"date"`
+	Ticker   string    `json:"ticker"`
+	Price    float64   `json:"price"`
+	Units    float64   `json:"units"`
+	Notional float64   `json:"notional"`
+	Cost     float64   `json:"cost"`
+	Slippage float64   `json:"slippage"`
+}
+
+// PortfolioPoint is the portfolio marked to market on one snapshot date
+type PortfolioPoint struct {
+	Date     time.Time `json:"date"`
+	Value    float64   `json:"value"`
+	Cash     float64   `json:"cash"`
+	Holdings int       `json:"holdings"`
+	Turnover float64   `json:"turnover"` // traded notional over value, on rebalance dates
+	Costs    float64   `json:"costs"`    // costs and slippage paid that date
+}
+
+// SimulationResult is the value path, the fills and the performance of a
+// simulation
+type SimulationResult struct {
+	InitialCapital float64          `json:"initial_capital"`
+	Performance    Performance      `json:"performance"`
+	Points         []PortfolioPoint `json:"points"`
+	Trades         []Trade          `json:"trades"`
+
+	// Benchmark is the series Performance.Benchmark compares against
+	Benchmark *Benchmark `json:"-"`
+}
+
+// Simulate runs strategy over snapshots in date order. The portfolio starts
+// in cash, rebalances on the first snapshot and every RebalanceEvery after
+// it, and is marked to market on each snapshot. Holdings missing from a
+// snapshot keep their last price; they can be sold only once priced again,
+// or are closed at that price once delisted.
+// Costs and slippage are paid from cash as trades fill.
+func Simulate(snapshots []domain.Snapshot, strategy Strategy, cfg SimulationConfig) (*SimulationResult, error) {
+	if len(snapshots) == 0 {
+		return nil, errors.New("no snapshots to simulate")
+	}
+	if strategy == nil {
+		return nil, errors.New("no strategy")
+	}
+	capital := cfg.InitialCapital
+	if capital <= 0 {
+		capital = 1
+	}
+	every := max(cfg.RebalanceEvery, 1)
+
+	cash := capital
+	units := make(map[string]float64)
+	lastPrice := make(map[string]float64)
+	result := &SimulationResult{InitialCapital: capital, Benchmark: cfg.Benchmark}
+
+	for i, snap := range snapshots {
+		prices := make(map[string]float64, len(snap.Assets))
+		assets := make(map[string]domain.Asset, len(snap.Assets))
+		for _, asset := range snap.Assets {
+			if asset.CurrentPrice > 0 {
+				prices[asset.Ticker] = asset.CurrentPrice
+				assets[asset.Ticker] = asset
+				lastPrice[asset.Ticker] = asset.CurrentPrice
+			}
+		}
+		point := PortfolioPoint{Date: snap.Date}
+		for _, ticker := range sortedTickers(units) {
+			if !cfg.Delistings.Delisted(ticker, snap.Date) {
+				continue
+			}
+			price, notional := lastPrice[ticker], -units[ticker]*lastPrice[ticker]
+			cash -= notional
+			delete(units, ticker)
+			result.Trades = append(result.Trades, Trade{
+				Date: snap.Date, Ticker: ticker, Price: price, Units: notional / price, Notional: notional,
+			})
+		}
+		value := cash
+		for ticker, u := range units {
+			value += u * lastPrice[ticker]
+		}
+
+		if i%every == 0 && value > 0 {
+			targets := strategy.Rebalance(snap.Date, snap.Assets)
+			tickers := make([]string, 0, len(units)+len(targets))
+			seen := make(map[string]bool)
+			for ticker := range units {
+				tickers = append(tickers, ticker)
+				seen[ticker] = true
+			}
+			for ticker := range targets {
+				if !seen[ticker] {
+					tickers = append(tickers, ticker)
+				}
+			}
+			// Sells first so their proceeds fund the buys, then by ticker
+			sort.Slice(tickers, func(a, b int) bool {
+				da := targets[tickers[a]]*value - units[tickers[a]]*lastPrice[tickers[a]]
+				db := targets[tickers[b]]*value - units[tickers[b]]*lastPrice[tickers[b]]
+				if (da < 0) != (db < 0) {
+					return da < 0
+				}
+				return tickers[a] < tickers[b]
+			})
+
+			// Unlevered portfolios buy only with the cash they have, leaving
+			// room for the costs, so they never borrow to pay them
+			levered := targets.Gross() > 1
+			var traded float64
+			for _, ticker := range tickers {
+				price, ok := prices[ticker]
+				if !ok {
+					continue // not tradable today; a held position rides on
+				}
+				notional := targets[ticker]*value - units[ticker]*price
+				if notional > 0 && !levered {
+					rate := cfg.Costs.TradeCost(assets[ticker], 1).Total() + cfg.SlippageBps/1e4
+					notional = math.Min(notional, math.Max(cash, 0)/(1+rate))
+				}
+				if math.Abs(notional) < 1e-9*value {
+					continue
+				}
+				size := math.Abs(notional)
+				cost := cfg.Costs.TradeCost(assets[ticker], size).Total()
+				slippage := size * cfg.SlippageBps / 1e4
+				cash -= notional + cost + slippage
+				units[ticker] += notional / price
+				if math.Abs(units[ticker]*price) < 1e-9*value {
+					delete(units, ticker)
+				}
+				traded += size
+				point.Costs += cost + slippage
+				result.Trades = append(result.Trades, Trade{
+					Date: snap.Date, Ticker: ticker, Price: price, Units: notional / price,
+					Notional: notional, Cost: cost, Slippage: slippage,
+				})
+			}
+			point.Turnover = traded / value
+
+			value = cash
+			for ticker, u := range units {
+				value += u * lastPrice[ticker]
+			}
+		}
+
+		point.Value = value
+		point.Cash = cash
+		point.Holdings = len(units)
+		result.Points = append(result.Points, point)
+	}
+
+	result.Performance = Analyze(result, cfg.PeriodsPerYear)
+	return result, nil
+}
+
+func sortedTickers(units map[string]float64) []string {
+	tickers := make([]string, 0, len(units))
+	for ticker := range units {
+		tickers = append(tickers, ticker)
+	}
+	sort.Strings(tickers)
+	return tickers
+}
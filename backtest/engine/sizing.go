@@ -0,0 +1,178 @@
+package engine
+
+import (
+	"math"
+
+	"algotradar/domain"
+)
+
+// Weights maps tickers to portfolio weights; whatever does not sum to 1 is held as cash
+type Weights map[string]float64
+
+// Gross returns the sum of absolute weights
+func (w Weights) Gross() float64 {
+	total := 0.0
+	for _, v := range w {
+		total += math.Abs(v)
+	}
+	return total
+}
+
+// Constraints bound the portfolio a strategy may hold. Zero values disable a limit.
+type Constraints struct {
+	MaxWeight        float64 // per name
+	MaxSectorWeight  float64
+	MaxCountryWeight float64
+	TargetVolatility float64 // annualized; scales gross exposure using training returns
+	MaxLeverage      float64 // cap on gross exposure after volatility targeting (default 1)
+}
+
+// EqualWeights gives each asset the same weight
+func EqualWeights(assets []domain.Asset) Weights {
+	weights := make(Weights, len(assets))
+	for _, asset := range assets {
+		weights[asset.Ticker] = 1 / float64(len(assets))
+	}
+	return weights
+}
+
+// CapWeights weights assets by market cap
+func CapWeights(assets []domain.Asset) Weights {
+	total := 0.0
+	for _, asset := range assets {
+		total += asset.MarketCap
+	}
+	if total <= 0 {
+		return EqualWeights(assets)
+	}
+
+	weights := make(Weights, len(assets))
+	for _, asset := range assets {
+		weights[asset.Ticker] = asset.MarketCap / total
+	}
+	return weights
+}
+
+// maxCapIterations bounds the clip-and-redistribute loop
+const maxCapIterations = 100
+
+// Apply clips weights to the name, sector and country limits, redistributing the
+// excess pro rata to positions with headroom. Excess that cannot be placed stays in cash.
+func (c *Constraints) Apply(assets []domain.Asset, weights Weights) Weights {
+	out := make(Weights, len(weights))
+	for ticker, w := range weights {
+		out[ticker] = w
+	}
+	if c == nil {
+		return out
+	}
+
+	byTicker := make(map[string]domain.Asset, len(assets))
+	for _, asset := range assets {
+		byTicker[asset.Ticker] = asset
+	}
+
+	groupings := []struct {
+		limit float64
+		group func(domain.Asset) string
+	}{
+		{c.MaxWeight, func(a domain.Asset) string { return a.Ticker }},
+		{c.MaxSectorWeight, func(a domain.Asset) string { return a.Sector }},
+		{c.MaxCountryWeight, func(a domain.Asset) string { return a.Country }},
+	}
+
+	for iter := 0; iter < maxCapIterations; iter++ {
+		excess := 0.0
+		capped := make(map[string]bool)
+
+		for _, g := range groupings {
+			if g.limit <= 0 {
+				continue
+			}
+			totals := make(map[string]float64)
+			for ticker, w := range out {
+				totals[g.group(byTicker[ticker])] += w
+			}
+			for ticker, w := range out {
+				key := g.group(byTicker[ticker])
+				if totals[key] > g.limit+1e-12 {
+					scaled := w * g.limit / totals[key]
+					excess += w - scaled
+					out[ticker] = scaled
+					capped[ticker] = true
+				} else if totals[key] >= g.limit-1e-12 {
+					capped[ticker] = true
+				}
+			}
+		}
+
+		if excess < 1e-12 {
+			break
+		}
+
+		free := 0.0
+		for ticker, w := range out {
+			if !capped[ticker] {
+				free += w
+			}
+		}
+		if free <= 0 {
+			break // nothing has headroom; the excess stays in cash
+		}
+		for ticker, w := range out {
+			if !capped[ticker] {
+				out[ticker] = w + excess*w/free
+			}
+		}
+	}
+
+	return out
+}
+
+// TargetVolatilityScale scales weights so their realized volatility over history matches
+// the target, capped at MaxLeverage. Weights are returned unchanged without a target.
+func (c *Constraints) TargetVolatilityScale(history []domain.Snapshot, weights Weights) Weights {
+	if c == nil || c.TargetVolatility <= 0 {
+		return weights
+	}
+
+	returns := weightedReturns(history, weights)
+	vol := stdDev(returns) * math.Sqrt(252)
+	if vol <= 0 {
+		return weights
+	}
+
+	maxLeverage := c.MaxLeverage
+	if maxLeverage <= 0 {
+		maxLeverage = 1
+	}
+	scale := math.Min(c.TargetVolatility/vol, maxLeverage/weights.Gross())
+
+	scaled := make(Weights, len(weights))
+	for ticker, w := range weights {
+		scaled[ticker] = w * scale
+	}
+	return scaled
+}
+
+// weightedReturns computes the portfolio return between consecutive snapshots,
+// renormalizing over the positions priced on both dates
+func weightedReturns(snapshots []domain.Snapshot, weights Weights) []float64 {
+	var returns []float64
+	for i := 1; i < len(snapshots); i++ {
+		prev, cur := snapshots[i-1], snapshots[i]
+		var sum, covered float64
+		for ticker, w := range weights {
+			p0, ok0 := prev.Price(ticker)
+			p1, ok1 := cur.Price(ticker)
+			if ok0 && ok1 {
+				sum += w * (p1/p0 - 1)
+				covered += w
+			}
+		}
+		if covered > 0 {
+			returns = append(returns, sum/covered*weights.Gross())
+		}
+	}
+	return returns
+}
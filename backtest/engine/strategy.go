@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"algotradar/domain"
+)
+
+// Strategy decides the portfolio to hold. Rebalance is called on each
+// rebalance date with that day's universe and returns target weights; tickers
+// left out are sold and whatever the weights do not allocate is held as cash.
+type Strategy interface {
+	Rebalance(date time.Time, universe []domain.Asset) Weights
+}
+
+// StrategyFunc adapts a plain function to Strategy
+type StrategyFunc func(date time.Time, universe []domain.Asset) Weights
+
+func (f StrategyFunc) Rebalance(date time.Time, universe []domain.Asset) Weights {
+	return f(date, universe)
+}
+
+// Weighting is how a strategy spreads capital over the names it picks
+type Weighting string
+
+const (
+	WeightEqual Weighting = "equal" // the same weight per name
+	WeightCap   Weighting = "cap"   // in proportion to market cap
+)
+
+// ParseWeighting validates a weighting name
+func ParseWeighting(name string) (Weighting, error) {
+	switch w := Weighting(name); w {
+	case WeightEqual, WeightCap:
+		return w, nil
+	}
+	return "", fmt.Errorf("unknown weighting %q (want equal or cap)", name)
+}
+
+// weigh applies weighting to assets, then the constraints when set
+func weigh(assets []domain.Asset, weighting Weighting, constraints *Constraints) Weights {
+	weights := EqualWeights(assets)
+	if weighting == WeightCap {
+		weights = CapWeights(assets)
+	}
+	if constraints != nil {
+		weights = constraints.Apply(assets, weights)
+	}
+	return weights
+}
+
+// TopNStrategy holds the N largest priced assets by market cap, or the whole
+// priced universe when N is 0. With WeightCap it tracks a cap-weighted index
+// of those names and with WeightEqual (the default) its equal-weighted
+// version, the two textbook benchmarks for checking the engine. Constraints,
+// when set, clip the name, sector and country weights; volatility targeting
+// needs a training window and is left to the walk-forward evaluator.
+type TopNStrategy struct {
+	N           int
+	Weighting   Weighting
+	Constraints *Constraints
+}
+
+func (s TopNStrategy) Rebalance(_ time.Time, universe []domain.Asset) Weights {
+	selection := largest(universe, s.N)
+	if len(selection) == 0 {
+		return Weights{}
+	}
+	return weigh(selection, s.Weighting, s.Constraints)
+}
+
+// largest returns the n priced assets with the largest market cap, all of
+// them when n is 0, ties broken by ticker
+func largest(universe []domain.Asset, n int) []domain.Asset {
+	var priced []domain.Asset
+	for _, asset := range universe {
+		if asset.CurrentPrice > 0 {
+			priced = append(priced, asset)
+		}
+	}
+	domain.SortAssets(priced)
+	if n > 0 && len(priced) > n {
+		priced = priced[:n]
+	}
+	return priced
+}
+
+// SectorMomentumStrategy is sector-neutral momentum. Within each sector of
+// the universe it buys the PerSector names with the best return over the
+// last Lookback snapshots, skipping the most recent Skip (e.g. 12-1 month
+// momentum avoids the short-term reversal). Each sector gets the weight it
+// has in the cap-weighted universe, split equally among its picks, so the
+// portfolio carries no sector bets relative to the index, only stock
+// selection within sectors.
+//
+// Returns are read from History, the snapshots being simulated; until
+// Lookback snapshots precede a rebalance date the strategy stays in cash.
+type SectorMomentumStrategy struct {
+	History   []domain.Snapshot
+	Lookback  int // snapshots the return is measured over
+	Skip      int // most recent snapshots left out of the return
+	PerSector int // names bought per sector; 0 means 1
+	// N limits the universe to its N largest names before ranking; 0 ranks
+	// every priced asset
+	N int
+}
+
+func (s SectorMomentumStrategy) Rebalance(date time.Time, universe []domain.Asset) Weights {
+	end := sort.Search(len(s.History), func(i int) bool { return !s.History[i].Date.Before(date) }) - s.Skip
+	start := end - s.Lookback
+	if s.Lookback <= 0 || start < 0 || end >= len(s.History) {
+		return Weights{}
+	}
+	from, to := s.History[start], s.History[end]
+
+	type scored struct {
+		asset    domain.Asset
+		momentum float64
+	}
+	sectors := make(map[string][]scored)
+	sectorCaps := make(map[string]float64)
+	var totalCap float64
+	for _, asset := range largest(universe, s.N) {
+		p0, ok0 := from.Price(asset.Ticker)
+		p1, ok1 := to.Price(asset.Ticker)
+		if !ok0 || !ok1 {
+			continue
+		}
+		sector := sectorName(asset)
+		sectors[sector] = append(sectors[sector], scored{asset, p1/p0 - 1})
+		sectorCaps[sector] += asset.MarketCap
+		totalCap += asset.MarketCap
+	}
+
+	perSector := max(s.PerSector, 1)
+	weights := make(Weights)
+	for sector, names := range sectors {
+		sort.Slice(names, func(i, j int) bool {
+			if names[i].momentum != names[j].momentum {
+				return names[i].momentum > names[j].momentum
+			}
+			return names[i].asset.Ticker < names[j].asset.Ticker
+		})
+		picks := names[:min(perSector, len(names))]
+
+		sectorWeight := 1 / float64(len(sectors))
+		if totalCap > 0 {
+			sectorWeight = sectorCaps[sector] / totalCap
+		}
+		for _, pick := range picks {
+			weights[pick.asset.Ticker] = sectorWeight / float64(len(picks))
+		}
+	}
+	return weights
+}
+
+// sectorName groups an asset by its normalized sector, falling back to the
+// provider's and then to "Unknown"
+func sectorName(asset domain.Asset) string {
+	switch {
+	case asset.SectorNormalized != "":
+		return asset.SectorNormalized
+	case asset.Sector != "":
+		return asset.Sector
+	}
+	return "Unknown"
+}
@@ -0,0 +1,267 @@
+package engine
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"algotradar/domain"
+	"algotradar/workpool"
+)
+
+// Param is one swept parameter and the values it takes
+type Param struct {
+	Name   string
+	Values []float64
+}
+
+// ParseParam parses a sweep spec, either a list ("top=5,10,20") or an
+// inclusive range with a step ("lookback=10:60:10")
+func ParseParam(spec string) (Param, error) {
+	name, values, ok := strings.Cut(spec, "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.TrimSpace(values) == "" {
+		return Param{}, fmt.Errorf("invalid sweep %q (want name=v1,v2 or name=start:stop:step)", spec)
+	}
+	param := Param{Name: name}
+
+	if parts := strings.Split(values, ":"); len(parts) == 3 {
+		var bounds [3]float64
+		for i, part := range parts {
+			v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return Param{}, fmt.Errorf("invalid sweep %q: %w", spec, err)
+			}
+			bounds[i] = v
+		}
+		start, stop, step := bounds[0], bounds[1], bounds[2]
+		if step <= 0 || stop < start {
+			return Param{}, fmt.Errorf("invalid sweep %q: range needs start <= stop and a positive step", spec)
+		}
+		// Steps are counted rather than accumulated so 0.1 steps land exactly
+		for i := 0; start+float64(i)*step <= stop+step*1e-9; i++ {
+			param.Values = append(param.Values, start+float64(i)*step)
+		}
+		return param, nil
+	}
+
+	for _, part := range strings.Split(values, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return Param{}, fmt.Errorf("invalid sweep %q: %w", spec, err)
+		}
+		param.Values = append(param.Values, v)
+	}
+	return param, nil
+}
+
+// ParamSet is one point of a parameter grid
+type ParamSet map[string]float64
+
+// String lists the parameters by name, e.g. "lookback=20 top=10"
+func (p ParamSet) String() string {
+	parts := make([]string, 0, len(p))
+	for _, name := range p.Names() {
+		parts = append(parts, name+"="+strconv.FormatFloat(p[name], 'g', -1, 64))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Names returns the parameter names in stable order
+func (p ParamSet) Names() []string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Grid returns every combination of the parameters' values, the last
+// parameter varying fastest
+func Grid(params []Param) []ParamSet {
+	grid := []ParamSet{{}}
+	for _, param := range params {
+		var next []ParamSet
+		for _, set := range grid {
+			for _, v := range param.Values {
+				combined := make(ParamSet, len(set)+1)
+				for name, value := range set {
+					combined[name] = value
+				}
+				combined[param.Name] = v
+				next = append(next, combined)
+			}
+		}
+		grid = next
+	}
+	return grid
+}
+
+// SimulationEvaluator scores a strategy by simulating it over each test
+// window. newStrategy is given the train and test snapshots together, so
+// strategies that look back, like momentum, can read the training history.
+func SimulationEvaluator(newStrategy func(history []domain.Snapshot) Strategy, cfg SimulationConfig) Evaluator {
+	return func(train, test []domain.Snapshot) (Metrics, error) {
+		if len(test) == 0 {
+			return nil, errors.New("empty test window")
+		}
+		history := append(append([]domain.Snapshot(nil), train...), test...)
+		result, err := Simulate(test, newStrategy(history), cfg)
+		if err != nil {
+			return nil, err
+		}
+		p := result.Performance
+		metrics := Metrics{
+			"return":       p.TotalReturn,
+			"cost":         p.Costs + p.Slippage,
+			"volatility":   p.Volatility,
+			"sharpe":       p.Sharpe,
+			"max_drawdown": p.MaxDrawdown,
+			"turnover":     p.Turnover,
+		}
+		if b := p.Benchmark; b != nil {
+			metrics["benchmark_return"] = b.Return
+			metrics["excess_return"] = b.ExcessReturn
+			metrics["alpha"] = b.Alpha
+			metrics["beta"] = b.Beta
+			metrics["tracking_error"] = b.TrackingError
+			metrics["information_ratio"] = b.InformationRatio
+		}
+		return metrics, nil
+	}
+}
+
+// SweepResult is the walk-forward outcome of one parameter set; Err is set
+// when no evaluator could be built for it
+type SweepResult struct {
+	Params ParamSet
+	Result *WalkForwardResult
+	Err    error
+}
+
+// Sweep runs a walk-forward for every parameter set of grid. Every window of
+// every set is evaluated as its own job on up to workers goroutines, so a
+// grid over a short archive still uses them all; results are in grid order.
+func Sweep(snapshots []domain.Snapshot, cfg WalkForwardConfig, grid []ParamSet,
+	evaluator func(ParamSet) (Evaluator, error), workers int) ([]SweepResult, error) {
+	windows, err := WalkForwardWindows(len(snapshots), cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	type job struct{ set, window int }
+	results := make([]SweepResult, len(grid))
+	evals := make([]Evaluator, len(grid))
+	var jobs []job
+	for i, params := range grid {
+		results[i].Params = params
+		if evals[i], results[i].Err = evaluator(params); results[i].Err != nil {
+			continue
+		}
+		results[i].Result = &WalkForwardResult{Windows: make([]WindowResult, len(windows))}
+		for w := range windows {
+			jobs = append(jobs, job{i, w})
+		}
+	}
+
+	workers = max(workers, 1)
+	pool := workpool.New("sweep", workers, workers)
+	workpool.Each(pool, workers, jobs, func(_ int, j job) {
+		w := windows[j.window]
+		metrics, err := evals[j.set](snapshots[w.TrainStart:w.TrainEnd], snapshots[w.TestStart:w.TestEnd])
+		// Each job owns its own slot, so no lock is needed
+		results[j.set].Result.Windows[j.window] = WindowResult{Window: w, Metrics: metrics, Err: err}
+	})
+
+	for _, r := range results {
+		if r.Result != nil {
+			r.Result.Aggregate = AggregateMetrics(r.Result.Windows)
+		}
+	}
+	return results, nil
+}
+
+// WriteSweepMatrix saves the sweep as CSV, one row per parameter set: its
+// parameters, the windows evaluated and failed, then the mean and standard
+// deviation of each metric across windows
+func WriteSweepMatrix(path string, results []SweepResult) error {
+	paramSeen := make(map[string]bool)
+	metricSeen := make(map[string]bool)
+	var params, metrics []string
+	for _, r := range results {
+		for _, name := range r.Params.Names() {
+			if !paramSeen[name] {
+				paramSeen[name] = true
+				params = append(params, name)
+			}
+		}
+		if r.Result != nil {
+			for _, name := range r.Result.MetricNames() {
+				if !metricSeen[name] {
+					metricSeen[name] = true
+					metrics = append(metrics, name)
+				}
+			}
+		}
+	}
+	sort.Strings(metrics)
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create sweep matrix: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	header := append(append([]string(nil), params...), "windows", "failed")
+	for _, name := range metrics {
+		header = append(header, name+"_mean", name+"_std")
+	}
+	header = append(header, "error")
+	writer.Write(header)
+
+	format := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	for _, r := range results {
+		var record []string
+		for _, name := range params {
+			value := ""
+			if v, ok := r.Params[name]; ok {
+				value = format(v)
+			}
+			record = append(record, value)
+		}
+		if r.Result == nil {
+			record = append(record, "0", "0")
+			for range metrics {
+				record = append(record, "", "")
+			}
+			writer.Write(append(record, r.Err.Error()))
+			continue
+		}
+		failed := 0
+		for _, w := range r.Result.Windows {
+			if w.Err != nil {
+				failed++
+			}
+		}
+		record = append(record, strconv.Itoa(len(r.Result.Windows)), strconv.Itoa(failed))
+		for _, name := range metrics {
+			if s, ok := r.Result.Aggregate[name]; ok {
+				record = append(record, format(s.Mean), format(s.StdDev))
+			} else {
+				record = append(record, "", "")
+			}
+		}
+		writer.Write(append(record, ""))
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write sweep matrix: %w", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"errors"
+	"sort"
+
+	"algotradar/domain"
+)
+
+// TopNConfig parameterizes the top-N buy-and-hold evaluator
+type TopNConfig struct {
+	N           int
+	Costs       *CostModel   // nil is frictionless
+	Benchmark   *Benchmark   // nil skips benchmark-relative metrics
+	Constraints *Constraints // nil holds an equal-weight portfolio
+}
+
+// TopNHoldEvaluator picks the N largest assets by market cap on the last training
+// snapshot and scores a buy-and-hold of them over the test window. Without constraints
+// the names are equal weighted; with constraints the portfolio starts from cap weights
+// and is clipped to the limits and scaled to the volatility target.
+// Round-trip trading and FX costs are deducted in proportion to each position.
+func TopNHoldEvaluator(cfg TopNConfig) Evaluator {
+	return func(train, test []domain.Snapshot) (Metrics, error) {
+		if len(train) == 0 || len(test) == 0 {
+			return nil, errors.New("empty train or test window")
+		}
+
+		selection := append([]domain.Asset(nil), train[len(train)-1].Assets...)
+		sort.Slice(selection, func(i, j int) bool {
+			return selection[i].MarketCap > selection[j].MarketCap
+		})
+		if len(selection) > cfg.N {
+			selection = selection[:cfg.N]
+		}
+
+		entry, exit := test[0], test[len(test)-1]
+		var priced []domain.Asset
+		for _, asset := range selection {
+			_, okEntry := entry.Price(asset.Ticker)
+			_, okExit := exit.Price(asset.Ticker)
+			if okEntry && okExit {
+				priced = append(priced, asset)
+			}
+		}
+		if len(priced) == 0 {
+			return nil, errors.New("no selected assets priced in test window")
+		}
+
+		weights := EqualWeights(priced)
+		if cfg.Constraints != nil {
+			weights = cfg.Constraints.Apply(priced, CapWeights(priced))
+			weights = cfg.Constraints.TargetVolatilityScale(train, weights)
+		}
+
+		var totalReturn, totalCost float64
+		winners := 0
+		for _, asset := range priced {
+			w := weights[asset.Ticker]
+			entryPrice, _ := entry.Price(asset.Ticker)
+			exitPrice, _ := exit.Price(asset.Ticker)
+
+			r := exitPrice/entryPrice - 1
+			// Costs per unit of capital: buy at w, sell at w*(1+r)
+			c := cfg.Costs.TradeCost(asset, w).Total() + cfg.Costs.TradeCost(asset, w*(1+r)).Total()
+			totalReturn += w*r - c
+			totalCost += c
+			if r > 0 {
+				winners++
+			}
+		}
+
+		maxWeight := 0.0
+		for _, w := range weights {
+			if w > maxWeight {
+				maxWeight = w
+			}
+		}
+
+		metrics := Metrics{
+			"return":     totalReturn,
+			"cost":       totalCost,
+			"hit_rate":   float64(winners) / float64(len(priced)),
+			"holdings":   float64(len(priced)),
+			"exposure":   weights.Gross(),
+			"max_weight": maxWeight,
+		}
+
+		if cfg.Benchmark != nil {
+			if r, ok := cfg.Benchmark.Return(entry.Date, exit.Date); ok {
+				metrics["benchmark_return"] = r
+				metrics["excess_return"] = metrics["return"] - r
+			}
+
+			// Annualize by the window's snapshot spacing, as Analyze does
+			portfolio, bench := periodReturns(weights, test, cfg.Benchmark)
+			periodsPerYear := observedPeriodsPerYear(entry.Date, exit.Date, len(test)-1)
+			if rel, err := CompareToBenchmark(portfolio, bench, periodsPerYear); periodsPerYear > 0 && err == nil {
+				metrics["alpha"] = rel.Alpha
+				metrics["beta"] = rel.Beta
+				metrics["tracking_error"] = rel.TrackingError
+				metrics["information_ratio"] = rel.InformationRatio
+			}
+		}
+
+		return metrics, nil
+	}
+}
+
+// periodReturns returns the weighted portfolio return between consecutive snapshots
+// alongside the benchmark return over the same dates, skipping gaps
+func periodReturns(weights Weights, snapshots []domain.Snapshot, benchmark *Benchmark) (portfolio, bench []float64) {
+	for i := 1; i < len(snapshots); i++ {
+		prev, cur := snapshots[i-1], snapshots[i]
+
+		b, ok := benchmark.Return(prev.Date, cur.Date)
+		if !ok {
+			continue
+		}
+
+		returns := weightedReturns(snapshots[i-1:i+1], weights)
+		if len(returns) == 0 {
+			continue
+		}
+
+		portfolio = append(portfolio, returns[0])
+		bench = append(bench, b)
+	}
+	return portfolio, bench
+}
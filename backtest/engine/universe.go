@@ -0,0 +1,249 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"algotradar/domain"
+)
+
+// UniverseFilter selects the investable names of a snapshot. Empty lists
+// match everything; list entries compare case-insensitively.
+type UniverseFilter struct {
+	MinMarketCap float64  // USD
+	Countries    []string // ISO codes, e.g. "US"
+	Exchanges    []string // primary exchanges, e.g. "NASDAQ"
+	AssetTypes   []string // e.g. "stock"
+
+	// MinListingDays drops names listed fewer than this many days before
+	// AsOf, such as recent IPOs with too little price history to rank.
+	// Rows without a listing date pass. Without AsOf the collector's
+	// is_recent_ipo flag decides instead.
+	MinListingDays int
+	AsOf           time.Time
+}
+
+// Match reports whether asset belongs to the filtered universe. Unpriced
+// rows never do, since nothing could have been traded at them.
+func (f UniverseFilter) Match(asset domain.Asset) bool {
+	return asset.CurrentPrice > 0 &&
+		asset.MarketCap >= f.MinMarketCap &&
+		matchesAny(f.Countries, asset.Country) &&
+		matchesAny(f.Exchanges, asset.PrimaryExchange) &&
+		matchesAny(f.AssetTypes, asset.AssetType) &&
+		f.listedLongEnough(asset)
+}
+
+// listedLongEnough reports whether asset has traded for MinListingDays
+func (f UniverseFilter) listedLongEnough(asset domain.Asset) bool {
+	if f.MinListingDays <= 0 || asset.ListingDate == "" {
+		return true
+	}
+	if f.AsOf.IsZero() {
+		return !asset.IsRecentIPO
+	}
+	listed, err := time.Parse("2006-01-02", asset.ListingDate)
+	if err != nil {
+		return true
+	}
+	return !f.AsOf.Before(listed.AddDate(0, 0, f.MinListingDays))
+}
+
+// Apply returns the assets of universe that match
+func (f UniverseFilter) Apply(universe []domain.Asset) []domain.Asset {
+	var members []domain.Asset
+	for _, asset := range universe {
+		if f.Match(asset) {
+			members = append(members, asset)
+		}
+	}
+	return members
+}
+
+func matchesAny(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// UniverseStore answers what the universe looked like on a past date from
+// the snapshots taken at the time, each kept under its own date. Querying
+// today's screener output instead would leave out every company that has
+// since been delisted, merged or shrunk below the cut-off, and flatter any
+// backtest run over it.
+type UniverseStore struct {
+	snapshots  []domain.Snapshot // by date
+	delistings Delistings
+}
+
+// NewUniverseStore versions snapshots by date; with several snapshots on one
+// date the last one given wins, as with the archive loader
+func NewUniverseStore(snapshots []domain.Snapshot) *UniverseStore {
+	byDate := make(map[string]domain.Snapshot, len(snapshots))
+	for _, snap := range snapshots {
+		byDate[snap.Date.Format("2006-01-02")] = snap
+	}
+	store := &UniverseStore{snapshots: make([]domain.Snapshot, 0, len(byDate))}
+	for _, snap := range byDate {
+		store.snapshots = append(store.snapshots, snap)
+	}
+	sort.Slice(store.snapshots, func(i, j int) bool {
+		return store.snapshots[i].Date.Before(store.snapshots[j].Date)
+	})
+	return store
+}
+
+// Dates returns the snapshot dates the store holds, oldest first
+func (s *UniverseStore) Dates() []time.Time {
+	dates := make([]time.Time, len(s.snapshots))
+	for i, snap := range s.snapshots {
+		dates[i] = snap.Date
+	}
+	return dates
+}
+
+// AsOf returns the latest snapshot taken on or before date, the universe as
+// it was known that day; false when date precedes the first snapshot. It
+// never looks ahead, so a non-trading day reads the last trading day.
+func (s *UniverseStore) AsOf(date time.Time) (domain.Snapshot, bool) {
+	day := date.Format("2006-01-02")
+	i := sort.Search(len(s.snapshots), func(i int) bool {
+		return s.snapshots[i].Date.Format("2006-01-02") > day
+	})
+	if i == 0 {
+		return domain.Snapshot{}, false
+	}
+	return s.snapshots[i-1], true
+}
+
+// Universe returns the members of the filtered universe on date, largest
+// first, with the date of the snapshot they were read from
+func (s *UniverseStore) Universe(date time.Time, filter UniverseFilter) ([]domain.Asset, time.Time, error) {
+	snap, ok := s.AsOf(date)
+	if !ok {
+		if len(s.snapshots) == 0 {
+			return nil, time.Time{}, errors.New("no snapshots in universe store")
+		}
+		return nil, time.Time{}, fmt.Errorf("no snapshot on or before %s; the store starts %s",
+			date.Format("2006-01-02"), s.snapshots[0].Date.Format("2006-01-02"))
+	}
+	filter.AsOf = snap.Date
+	members := filter.Apply(snap.Assets)
+	domain.SortAssets(members)
+	return members, snap.Date, nil
+}
+
+// AddDelisted merges delisted companies into the store: rows of a listing
+// dated on or after its delisting, e.g. carried forward by a gap policy, are
+// dropped, and Delisting reports when members later disappeared
+func (s *UniverseStore) AddDelisted(delistings Delistings) {
+	if s.delistings == nil {
+		s.delistings = make(Delistings)
+	}
+	for ticker, dates := range delistings {
+		s.delistings[ticker] = append(s.delistings[ticker], dates...)
+		sortDates(s.delistings[ticker])
+	}
+	s.snapshots = s.delistings.Drop(s.snapshots)
+}
+
+// Delisting returns the first delisting of ticker after date, when a member
+// of that date's universe later disappeared
+func (s *UniverseStore) Delisting(ticker string, date time.Time) (time.Time, bool) {
+	return s.delistings.After(ticker, date)
+}
+
+// FilterUniverse restricts strategy to the names filter admits on each
+// rebalance date, judged on that date's data only
+func FilterUniverse(strategy Strategy, filter UniverseFilter) Strategy {
+	return StrategyFunc(func(date time.Time, universe []domain.Asset) Weights {
+		f := filter
+		f.AsOf = date
+		return strategy.Rebalance(date, f.Apply(universe))
+	})
+}
+
+// Delistings maps tickers to the dates they were delisted, oldest first. A
+// symbol reused by a later listing counts as delisted from its first
+// delisting on, so such entries should be removed once the symbol relists.
+type Delistings map[string][]time.Time
+
+// NewDelistings indexes delisted companies by ticker, skipping entries
+// without a valid delisting date
+func NewDelistings(companies []domain.DelistedCompany) Delistings {
+	d := make(Delistings)
+	for _, c := range companies {
+		date, err := time.Parse("2006-01-02", c.DelistedDate)
+		if err != nil || c.Symbol == "" {
+			continue
+		}
+		d[c.Symbol] = append(d[c.Symbol], date)
+	}
+	for _, dates := range d {
+		sortDates(dates)
+	}
+	return d
+}
+
+// LoadDelisted reads the delisted companies written by datacollect delisted
+func LoadDelisted(path string) (Delistings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read delisted companies: %w", err)
+	}
+	var companies []domain.DelistedCompany
+	if err := json.Unmarshal(data, &companies); err != nil {
+		return nil, fmt.Errorf("failed to parse delisted companies: %w", err)
+	}
+	return NewDelistings(companies), nil
+}
+
+// Delisted reports whether ticker had been delisted by date
+func (d Delistings) Delisted(ticker string, date time.Time) bool {
+	dates := d[ticker]
+	return len(dates) > 0 && !dates[0].After(date)
+}
+
+// After returns the first delisting of ticker after date
+func (d Delistings) After(ticker string, date time.Time) (time.Time, bool) {
+	for _, delisted := range d[ticker] {
+		if delisted.After(date) {
+			return delisted, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Drop returns snapshots without the rows of tickers delisted by the
+// snapshot's date; snapshots is not modified
+func (d Delistings) Drop(snapshots []domain.Snapshot) []domain.Snapshot {
+	if len(d) == 0 {
+		return snapshots
+	}
+	out := make([]domain.Snapshot, len(snapshots))
+	for i, snap := range snapshots {
+		out[i] = snap
+		out[i].Assets = nil
+		for _, asset := range snap.Assets {
+			if !d.Delisted(asset.Ticker, snap.Date) {
+				out[i].Assets = append(out[i].Assets, asset)
+			}
+		}
+	}
+	return out
+}
+
+func sortDates(dates []time.Time) {
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+}
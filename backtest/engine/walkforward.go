@@ -0,0 +1,163 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"algotradar/domain"
+)
+
+// WalkForwardConfig controls how the archive is cut into train/test windows.
+// Sizes are counted in snapshots, not calendar days.
+type WalkForwardConfig struct {
+	TrainSize int  // snapshots in each training window
+	TestSize  int  // snapshots in each out-of-sample test window
+	Step      int  // snapshots to advance between windows (defaults to TestSize)
+	Anchored  bool // keep the training window start fixed at the first snapshot
+}
+
+// Window is a pair of index ranges [TrainStart, TrainEnd) and [TestStart, TestEnd)
+type Window struct {
+	TrainStart int
+	TrainEnd   int
+	TestStart  int
+	TestEnd    int
+}
+
+// Metrics are named numeric results produced by evaluating one window
+type Metrics map[string]float64
+
+// Evaluator fits on the train snapshots and scores on the test snapshots
+type Evaluator func(train, test []domain.Snapshot) (Metrics, error)
+
+// WindowResult holds the outcome of a single walk-forward window
+type WindowResult struct {
+	Window  Window
+	Metrics Metrics
+	Err     error
+}
+
+// MetricSummary aggregates one metric across all successful windows
+type MetricSummary struct {
+	Mean   float64
+	StdDev float64
+	Min    float64
+	Max    float64
+	Count  int
+}
+
+// WalkForwardResult holds per-window results and their aggregate
+type WalkForwardResult struct {
+	Windows   []WindowResult
+	Aggregate map[string]MetricSummary
+}
+
+// MetricNames returns the aggregated metric names in stable order
+func (r *WalkForwardResult) MetricNames() []string {
+	names := make([]string, 0, len(r.Aggregate))
+	for name := range r.Aggregate {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WalkForwardWindows computes the train/test windows for an archive of n snapshots
+func WalkForwardWindows(n int, cfg WalkForwardConfig) ([]Window, error) {
+	if cfg.TrainSize <= 0 || cfg.TestSize <= 0 {
+		return nil, errors.New("train and test sizes must be positive")
+	}
+	step := cfg.Step
+	if step <= 0 {
+		step = cfg.TestSize
+	}
+
+	var windows []Window
+	for start := 0; start+cfg.TrainSize+cfg.TestSize <= n; start += step {
+		trainStart := start
+		if cfg.Anchored {
+			trainStart = 0
+		}
+		trainEnd := start + cfg.TrainSize
+		windows = append(windows, Window{
+			TrainStart: trainStart,
+			TrainEnd:   trainEnd,
+			TestStart:  trainEnd,
+			TestEnd:    trainEnd + cfg.TestSize,
+		})
+	}
+
+	if len(windows) == 0 {
+		return nil, fmt.Errorf("archive of %d snapshots is too short for train=%d test=%d",
+			n, cfg.TrainSize, cfg.TestSize)
+	}
+	return windows, nil
+}
+
+// SplitOutOfSample holds back the trailing fraction of snapshots as an out-of-sample set
+func SplitOutOfSample(snapshots []domain.Snapshot, holdout float64) (inSample, outOfSample []domain.Snapshot, err error) {
+	if holdout <= 0 || holdout >= 1 {
+		return nil, nil, fmt.Errorf("holdout fraction must be between 0 and 1, got %.2f", holdout)
+	}
+
+	cut := len(snapshots) - int(math.Ceil(float64(len(snapshots))*holdout))
+	if cut <= 0 || cut >= len(snapshots) {
+		return nil, nil, fmt.Errorf("cannot hold out %.0f%% of %d snapshots", holdout*100, len(snapshots))
+	}
+	return snapshots[:cut], snapshots[cut:], nil
+}
+
+// RunWalkForward evaluates every window and aggregates the metrics.
+// A failing window is recorded but does not abort the run.
+func RunWalkForward(snapshots []domain.Snapshot, cfg WalkForwardConfig, eval Evaluator) (*WalkForwardResult, error) {
+	windows, err := WalkForwardWindows(len(snapshots), cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &WalkForwardResult{}
+	for _, w := range windows {
+		metrics, err := eval(snapshots[w.TrainStart:w.TrainEnd], snapshots[w.TestStart:w.TestEnd])
+		result.Windows = append(result.Windows, WindowResult{Window: w, Metrics: metrics, Err: err})
+	}
+
+	result.Aggregate = AggregateMetrics(result.Windows)
+	return result, nil
+}
+
+// AggregateMetrics summarizes each metric across the successful windows
+func AggregateMetrics(windows []WindowResult) map[string]MetricSummary {
+	values := make(map[string][]float64)
+	for _, w := range windows {
+		if w.Err != nil {
+			continue
+		}
+		for name, v := range w.Metrics {
+			values[name] = append(values[name], v)
+		}
+	}
+
+	aggregate := make(map[string]MetricSummary, len(values))
+	for name, vs := range values {
+		summary := MetricSummary{Min: vs[0], Max: vs[0], Count: len(vs)}
+		sum := 0.0
+		for _, v := range vs {
+			sum += v
+			summary.Min = math.Min(summary.Min, v)
+			summary.Max = math.Max(summary.Max, v)
+		}
+		summary.Mean = sum / float64(len(vs))
+
+		if len(vs) > 1 {
+			sq := 0.0
+			for _, v := range vs {
+				sq += (v - summary.Mean) * (v - summary.Mean)
+			}
+			summary.StdDev = math.Sqrt(sq / float64(len(vs)-1))
+		}
+		aggregate[name] = summary
+	}
+	return aggregate
+}
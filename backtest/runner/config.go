@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"algotradar/backtest/engine"
+)
+
+// loadConfig applies a JSON backtest config file. Each key names a runner
+// flag and sets its value, e.g. {"simulate": true, "top": 20}, unless the
+// flag was given on the command line, and an array sets a repeatable flag
+// once per element, e.g. {"sweep": ["top=5,10", "max-weight=0.1,0.2"]}. "costs" may hold the cost model inline
+// as an object in the format of a -costs file, which is returned, instead of
+// a path to one.
+func loadConfig(path string) (*engine.CostModel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	var entries map[string]json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	var costs *engine.CostModel
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		raw := entries[name]
+		if name == "costs" && len(raw) > 0 && raw[0] == '{' {
+			if set[name] {
+				continue
+			}
+			if costs, err = engine.ParseCostModel(raw); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if flag.Lookup(name) == nil || name == "config" {
+			return nil, fmt.Errorf("config %s: unknown setting %q", path, name)
+		}
+		if set[name] {
+			continue
+		}
+		// An array sets a repeatable flag such as sweep once per element
+		values := []json.RawMessage{raw}
+		if len(raw) > 0 && raw[0] == '[' {
+			if err := json.Unmarshal(raw, &values); err != nil {
+				return nil, fmt.Errorf("config %s: invalid %s: %w", path, name, err)
+			}
+		}
+		for _, v := range values {
+			if err := flag.Set(name, flagValue(v)); err != nil {
+				return nil, fmt.Errorf("config %s: invalid %s: %w", path, name, err)
+			}
+		}
+	}
+	return costs, nil
+}
+
+// flagValue unquotes strings; numbers and booleans are set as written
+func flagValue(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	return string(raw)
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+
+	"algotradar/backtest/engine"
+	"algotradar/domain"
+	"algotradar/integrity"
+)
+
+func main() {
+	archiveDir := flag.String("archive", ".", "directory containing dated snapshot files")
+	prefix := flag.String("prefix", "global_assets_fmp_", "snapshot file name prefix")
+	trainSize := flag.Int("train", 20, "snapshots per training window")
+	testSize := flag.Int("test", 5, "snapshots per test window")
+	step := flag.Int("step", 0, "snapshots to advance between windows (default: test size)")
+	anchored := flag.Bool("anchored", false, "grow the training window from the first snapshot")
+	holdout := flag.Float64("holdout", 0, "fraction of trailing snapshots reserved for a final out-of-sample check")
+	topN := flag.Int("top", 10, "number of assets held by the top-N strategy")
+	costsPath := flag.String("costs", "", "JSON cost model with per-exchange commission/spread and FX costs")
+	benchmarkPath := flag.String("benchmark", "", "date/close CSV from the benchmark collector")
+	benchmarkTicker := flag.String("benchmark-ticker", "", "use this ticker's price in the archive as the benchmark")
+	benchmarkConstituents := flag.String("benchmark-constituents", "", "benchmark the cap-weighted basket of these archive tickers, comma-separated or @FILE with one per line")
+	benchmarkName := flag.String("benchmark-name", "", "name the benchmark is reported under (default: its file, ticker or \"constituents\")")
+	maxWeight := flag.Float64("max-weight", 0, "maximum weight per name (0 = no per-name cap)")
+	maxSector := flag.Float64("max-sector", 0, "maximum weight per sector")
+	maxCountry := flag.Float64("max-country", 0, "maximum weight per country")
+	targetVol := flag.Float64("target-vol", 0, "annualized volatility target (0 disables)")
+	maxLeverage := flag.Float64("max-leverage", 1, "maximum gross exposure under volatility targeting")
+	gapMode := flag.String("gaps", "drop", "gap policy for missing history: drop, ffill or nan")
+	maxFill := flag.Int("max-fill-days", 5, "fill horizon in calendar days for -gaps ffill/nan (0 = unlimited)")
+	requireChecksums := flag.Bool("require-checksums", false, "refuse snapshots without a .sha256 checksum")
+	ohlcvDir := flag.String("ohlcv", "", "load daily bars from TICKER.csv files (date, close, optional volume and market_cap) in this directory instead of the snapshot archive")
+	simulate := flag.Bool("simulate", false, "simulate a rebalanced top-N portfolio over the whole history instead of walk-forward windows")
+	rebalanceEvery := flag.Int("rebalance", 5, "snapshots between rebalances for -simulate")
+	capital := flag.Float64("capital", 1_000_000, "starting capital for -simulate")
+	slippageBps := flag.Float64("slippage-bps", 0, "slippage paid on each -simulate trade, in basis points of notional")
+	strategyName := flag.String("strategy", "top-n", "-simulate strategy: top-n (the -top largest names) or momentum (sector-neutral momentum)")
+	weighting := flag.String("weighting", "equal", "top-n weighting: equal or cap (market cap)")
+	lookback := flag.Int("lookback", 20, "momentum lookback in snapshots")
+	skip := flag.Int("skip", 0, "most recent snapshots momentum leaves out of its lookback")
+	perSector := flag.Int("per-sector", 2, "names momentum buys per sector")
+	universe := flag.Int("universe", 0, "momentum ranks only the largest N names (0 = all)")
+	delistedPath := flag.String("delisted", "", "delisted companies from datacollect delisted; drops their rows after delisting and closes -simulate positions in them")
+	minMarketCap := flag.Float64("min-market-cap", 0, "-simulate only trades names at least this large (USD) on each rebalance date")
+	countries := flag.String("countries", "", "-simulate only trades names listed in these comma-separated countries")
+	minListingDays := flag.Int("min-listing-days", 0, "-simulate only trades names listed at least this many days before each rebalance date, e.g. 182 to skip recent IPOs (needs listing_date in the snapshots)")
+	resultPath := flag.String("result", "", "write the -simulate performance, value path and trades, or the -filings event study, as JSON to this file")
+	equityPath := flag.String("equity-curve", "", "write the -simulate equity curve (value, return and drawdown per date) as CSV to this file")
+	filingsPath := flag.String("filings", "", "SEC filings from the US collector's --filings; runs an event study of prices around them instead of a backtest")
+	eventTypes := flag.String("event-types", "", "filing types the event study covers, comma-separated, e.g. 8-K (default: all)")
+	eventWindow := flag.Int("event-window", 5, "snapshots before and after each filing the event study follows")
+	var sweeps []engine.Param
+	flag.Func("sweep", "sweep a parameter over walk-forward windows, name=v1,v2 or name=start:stop:step; repeat for a grid (see -sweep-out)", func(spec string) error {
+		param, err := engine.ParseParam(spec)
+		if err == nil {
+			sweeps = append(sweeps, param)
+		}
+		return err
+	})
+	sweepOut := flag.String("sweep-out", "sweep_matrix.csv", "CSV comparison matrix written by -sweep")
+	workers := flag.Int("workers", runtime.NumCPU(), "windows evaluated in parallel by -sweep")
+	configPath := flag.String("config", "", "JSON backtest config setting these flags by name, with the cost model inline under \"costs\"; flags on the command line win")
+	flag.Parse()
+
+	var costs *engine.CostModel
+	if *configPath != "" {
+		var err error
+		costs, err = loadConfig(*configPath)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		log.Printf("⚙️  Loaded config from %s", *configPath)
+		if costs != nil {
+			log.Printf("💸 Using the config's cost model (%d exchange overrides, %d currency FX overrides)",
+				len(costs.Exchanges), len(costs.FXCurrencyBps))
+		}
+	}
+	if *costsPath != "" {
+		var err error
+		costs, err = engine.LoadCostModel(*costsPath)
+		if err != nil {
+			log.Fatalf("❌ Failed to load cost model: %v", err)
+		}
+		log.Printf("💸 Loaded cost model from %s (%d exchange overrides)", *costsPath, len(costs.Exchanges))
+	}
+
+	mode, err := engine.ParseGapMode(*gapMode)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	verifyKey, err := integrity.VerifyKeyFromEnv()
+	if err != nil {
+		log.Fatalf("❌ Failed to load verification key: %v", err)
+	}
+
+	var snapshots []domain.Snapshot
+	if *ohlcvDir != "" {
+		snapshots, err = engine.LoadOHLCV(*ohlcvDir)
+		if err != nil {
+			log.Fatalf("❌ Failed to load OHLCV data: %v", err)
+		}
+		log.Printf("📂 Loaded %d daily bars from %s", len(snapshots), *ohlcvDir)
+	} else {
+		loader := engine.Loader{
+			Dir:              *archiveDir,
+			Prefix:           *prefix,
+			Gaps:             engine.GapPolicy{Mode: mode, MaxFillDays: *maxFill},
+			RequireChecksums: *requireChecksums,
+			VerifyKey:        verifyKey,
+		}
+		snapshots, err = loader.Load()
+		if err != nil {
+			log.Fatalf("❌ Failed to load archive: %v", err)
+		}
+		log.Printf("📂 Loaded %d snapshots from %s", len(snapshots), *archiveDir)
+	}
+
+	var delistings engine.Delistings
+	if *delistedPath != "" {
+		delistings, err = engine.LoadDelisted(*delistedPath)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		snapshots = delistings.Drop(snapshots)
+		log.Printf("🪦 Loaded %d delisted tickers from %s", len(delistings), *delistedPath)
+	}
+
+	inSample := snapshots
+	var outOfSample []domain.Snapshot
+	if *holdout > 0 {
+		inSample, outOfSample, err = engine.SplitOutOfSample(snapshots, *holdout)
+		if err != nil {
+			log.Fatalf("❌ Failed to split archive: %v", err)
+		}
+		log.Printf("✂️  Holding out %d snapshots for out-of-sample evaluation", len(outOfSample))
+	}
+
+	var benchmark *engine.Benchmark
+	switch {
+	case *benchmarkPath != "":
+		benchmark, err = engine.LoadBenchmarkCSV(*benchmarkPath, *benchmarkPath)
+	case *benchmarkTicker != "":
+		benchmark, err = engine.BenchmarkFromArchive(snapshots, *benchmarkTicker)
+	case *benchmarkConstituents != "":
+		var tickers []string
+		if tickers, err = readTickers(*benchmarkConstituents); err == nil {
+			benchmark, err = engine.BenchmarkFromConstituents(snapshots, "constituents", tickers)
+		}
+	}
+	if err != nil {
+		log.Fatalf("❌ Failed to load benchmark: %v", err)
+	}
+	if benchmark != nil {
+		if *benchmarkName != "" {
+			benchmark.Name = *benchmarkName
+		}
+		log.Printf("📐 Comparing against benchmark %s", benchmark.Name)
+	}
+
+	cfg := engine.WalkForwardConfig{
+		TrainSize: *trainSize,
+		TestSize:  *testSize,
+		Step:      *step,
+		Anchored:  *anchored,
+	}
+	settings := strategySettings{
+		Strategy:       *strategyName,
+		Top:            *topN,
+		Weighting:      *weighting,
+		Lookback:       *lookback,
+		Skip:           *skip,
+		PerSector:      *perSector,
+		Universe:       *universe,
+		MaxWeight:      *maxWeight,
+		MaxSector:      *maxSector,
+		MaxCountry:     *maxCountry,
+		TargetVol:      *targetVol,
+		MaxLeverage:    *maxLeverage,
+		MinMarketCap:   *minMarketCap,
+		Countries:      splitList(*countries),
+		MinListingDays: *minListingDays,
+	}
+	simCfg := engine.SimulationConfig{
+		InitialCapital: *capital,
+		RebalanceEvery: *rebalanceEvery,
+		Costs:          costs,
+		SlippageBps:    *slippageBps,
+		Benchmark:      benchmark,
+		Delistings:     delistings,
+	}
+
+	if *filingsPath != "" {
+		filings, err := engine.LoadFilings(*filingsPath)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		if *eventWindow < 0 {
+			log.Fatalf("❌ -event-window must not be negative")
+		}
+		log.Printf("🗂️  Loaded SEC filings of %d tickers from %s", len(filings), *filingsPath)
+		runEventStudy(snapshots, filings, splitList(*eventTypes), *eventWindow, *resultPath)
+		return
+	}
+
+	if len(sweeps) > 0 {
+		runSweep(inSample, cfg, sweeps, settings, simCfg, *simulate, costs, benchmark, *workers, *sweepOut)
+		return
+	}
+
+	if *simulate {
+		strategy, err := settings.strategy(snapshots)
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		if settings.MinMarketCap > 0 || len(settings.Countries) > 0 || settings.MinListingDays > 0 {
+			log.Printf("🌐 Restricting the universe point-in-time: market cap ≥ %.0f, countries %q, listed ≥ %d days", *minMarketCap, *countries, *minListingDays)
+		}
+		log.Printf("🧭 Simulating %s strategy", *strategyName)
+		runSimulation(snapshots, strategy, simCfg, *resultPath, *equityPath)
+		return
+	}
+
+	eval := engine.TopNHoldEvaluator(engine.TopNConfig{
+		N:           *topN,
+		Costs:       costs,
+		Benchmark:   benchmark,
+		Constraints: settings.constraints(),
+	})
+
+	result, err := engine.RunWalkForward(inSample, cfg, eval)
+	if err != nil {
+		log.Fatalf("❌ Walk-forward failed: %v", err)
+	}
+
+	for i, w := range result.Windows {
+		train := inSample[w.Window.TrainStart:w.Window.TrainEnd]
+		test := inSample[w.Window.TestStart:w.Window.TestEnd]
+		if w.Err != nil {
+			log.Printf("⚠️  Window %d (test %s → %s): %v", i+1,
+				test[0].Date.Format("2006-01-02"), test[len(test)-1].Date.Format("2006-01-02"), w.Err)
+			continue
+		}
+		log.Printf("📊 Window %d: train %s → %s | test %s → %s | return %.2f%% | cost %.2f%% | hit rate %.0f%%", i+1,
+			train[0].Date.Format("2006-01-02"), train[len(train)-1].Date.Format("2006-01-02"),
+			test[0].Date.Format("2006-01-02"), test[len(test)-1].Date.Format("2006-01-02"),
+			w.Metrics["return"]*100, w.Metrics["cost"]*100, w.Metrics["hit_rate"]*100)
+	}
+
+	log.Printf("🏁 Aggregate over %d windows:", len(result.Windows))
+	for _, name := range result.MetricNames() {
+		s := result.Aggregate[name]
+		log.Printf("   %-10s mean %.4f | std %.4f | min %.4f | max %.4f (n=%d)",
+			name, s.Mean, s.StdDev, s.Min, s.Max, s.Count)
+	}
+
+	if len(outOfSample) > 0 {
+		metrics, err := eval(inSample, outOfSample)
+		if err != nil {
+			log.Fatalf("❌ Out-of-sample evaluation failed: %v", err)
+		}
+		log.Printf("🔒 Out-of-sample: return %.2f%% | hit rate %.0f%% | holdings %.0f",
+			metrics["return"]*100, metrics["hit_rate"]*100, metrics["holdings"])
+		if benchmark != nil {
+			log.Printf("🔒 Out-of-sample vs %s: alpha %.4f | beta %.2f | IR %.2f | tracking error %.4f",
+				benchmark.Name, metrics["alpha"], metrics["beta"], metrics["information_ratio"], metrics["tracking_error"])
+		}
+	}
+}
+
+// runEventStudy logs the average price path around filings, optionally
+// saving it as JSON
+func runEventStudy(snapshots []domain.Snapshot, filings engine.Filings, types []string, window int, resultPath string) {
+	study := engine.StudyFilings(snapshots, filings, types, window, window)
+	scope := "all filings"
+	if len(types) > 0 {
+		scope = strings.Join(types, ", ") + " filings"
+	}
+	log.Printf("🔬 Event study of %s: %d events, %d skipped for lack of prices around them", scope, study.Events, study.Skipped)
+	if study.Events == 0 {
+		return
+	}
+	for k, offset := range study.Offsets {
+		log.Printf("   day %+3d  mean %7.2f%% | up %3.0f%%", offset, study.MeanReturn[k]*100, study.HitRate[k]*100)
+	}
+	if resultPath != "" {
+		data, err := json.MarshalIndent(study, "", "  ")
+		if err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		if err := os.WriteFile(resultPath, data, 0644); err != nil {
+			log.Fatalf("❌ Failed to write event study: %v", err)
+		}
+		log.Printf("💾 Saved event study to %s", resultPath)
+	}
+}
+
+// runSimulation simulates strategy over the whole history and logs its
+// performance, optionally saving the full result and the equity curve
+func runSimulation(snapshots []domain.Snapshot, strategy engine.Strategy, cfg engine.SimulationConfig,
+	resultPath, equityPath string) {
+	result, err := engine.Simulate(snapshots, strategy, cfg)
+	if err != nil {
+		log.Fatalf("❌ Simulation failed: %v", err)
+	}
+
+	p := result.Performance
+	log.Printf("📈 Simulated %s → %s: %d snapshots, %d trades, rebalancing every %d",
+		p.Start.Format("2006-01-02"), p.End.Format("2006-01-02"), len(result.Points), p.Trades, max(cfg.RebalanceEvery, 1))
+	log.Printf("🏁 Final value %.2f | return %.2f%% | CAGR %.2f%% | volatility %.2f%% | Sharpe %.2f | Sortino %.2f",
+		p.FinalValue, p.TotalReturn*100, p.CAGR*100, p.Volatility*100, p.Sharpe, p.Sortino)
+	log.Printf("📉 Max drawdown %.2f%% (%s → %s)", p.MaxDrawdown*100,
+		p.DrawdownPeak.Format("2006-01-02"), p.DrawdownTrough.Format("2006-01-02"))
+	log.Printf("💸 Costs %.2f%% | slippage %.2f%% | turnover %.2fx (%.2fx a year)",
+		p.Costs*100, p.Slippage*100, p.Turnover, p.AnnualTurnover)
+	for _, y := range p.Yearly {
+		log.Printf("   %s %7.2f%%", y.Period, y.Return*100)
+	}
+	if b := p.Benchmark; b != nil {
+		log.Printf("📐 %s returned %.2f%% over the same dates (excess %.2f%%)", b.Name, b.Return*100, b.ExcessReturn*100)
+		log.Printf("📐 vs %s over %d periods: alpha %.4f | beta %.2f | IR %.2f | tracking error %.4f",
+			b.Name, b.Periods, b.Alpha, b.Beta, b.InformationRatio, b.TrackingError)
+	} else if cfg.Benchmark != nil {
+		log.Printf("⚠️  Benchmark %s has no closes on the simulated dates", cfg.Benchmark.Name)
+	}
+
+	if resultPath != "" {
+		if err := result.WriteJSON(resultPath); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		log.Printf("💾 Saved simulation result to %s", resultPath)
+	}
+	if equityPath != "" {
+		if err := result.WriteEquityCurve(equityPath); err != nil {
+			log.Fatalf("❌ %v", err)
+		}
+		log.Printf("💾 Saved equity curve to %s", equityPath)
+	}
+}
+
+// readTickers parses a comma-separated ticker list, or with a leading @ the
+// file it names, one ticker per line
+func readTickers(list string) ([]string, error) {
+	if path, ok := strings.CutPrefix(list, "@"); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tickers: %w", err)
+		}
+		list = strings.ReplaceAll(string(data), "\n", ",")
+	}
+	return splitList(list), nil
+}
+
+// splitList parses a comma-separated list, dropping empty entries
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"algotradar/backtest/engine"
+	"algotradar/domain"
+)
+
+// strategySettings are the strategy flags, which a sweep overrides per
+// parameter set
+type strategySettings struct {
+	Strategy       string
+	Top            int
+	Weighting      string
+	Lookback       int
+	Skip           int
+	PerSector      int
+	Universe       int
+	MaxWeight      float64
+	MaxSector      float64
+	MaxCountry     float64
+	TargetVol      float64
+	MaxLeverage    float64
+	MinMarketCap   float64
+	Countries      []string
+	MinListingDays int
+}
+
+// sweepable maps the flags a sweep can vary to the settings they set
+var sweepable = map[string]func(s *strategySettings, v float64){
+	"top":              func(s *strategySettings, v float64) { s.Top = int(v) },
+	"lookback":         func(s *strategySettings, v float64) { s.Lookback = int(v) },
+	"skip":             func(s *strategySettings, v float64) { s.Skip = int(v) },
+	"per-sector":       func(s *strategySettings, v float64) { s.PerSector = int(v) },
+	"universe":         func(s *strategySettings, v float64) { s.Universe = int(v) },
+	"max-weight":       func(s *strategySettings, v float64) { s.MaxWeight = v },
+	"max-sector":       func(s *strategySettings, v float64) { s.MaxSector = v },
+	"max-country":      func(s *strategySettings, v float64) { s.MaxCountry = v },
+	"target-vol":       func(s *strategySettings, v float64) { s.TargetVol = v },
+	"max-leverage":     func(s *strategySettings, v float64) { s.MaxLeverage = v },
+	"min-market-cap":   func(s *strategySettings, v float64) { s.MinMarketCap = v },
+	"min-listing-days": func(s *strategySettings, v float64) { s.MinListingDays = int(v) },
+}
+
+// simulationSweepable are the simulation settings a sweep can vary
+var simulationSweepable = map[string]func(c *engine.SimulationConfig, v float64){
+	"rebalance":    func(c *engine.SimulationConfig, v float64) { c.RebalanceEvery = int(v) },
+	"slippage-bps": func(c *engine.SimulationConfig, v float64) { c.SlippageBps = v },
+}
+
+// constraints returns the portfolio limits set, nil when none are
+func (s strategySettings) constraints() *engine.Constraints {
+	if s.MaxWeight <= 0 && s.MaxSector <= 0 && s.MaxCountry <= 0 && s.TargetVol <= 0 {
+		return nil
+	}
+	return &engine.Constraints{
+		MaxWeight:        s.MaxWeight,
+		MaxSectorWeight:  s.MaxSector,
+		MaxCountryWeight: s.MaxCountry,
+		TargetVolatility: s.TargetVol,
+		MaxLeverage:      s.MaxLeverage,
+	}
+}
+
+// strategy builds the -simulate strategy; history is what momentum ranks on
+func (s strategySettings) strategy(history []domain.Snapshot) (engine.Strategy, error) {
+	var strategy engine.Strategy
+	switch s.Strategy {
+	case "top-n":
+		w, err := engine.ParseWeighting(s.Weighting)
+		if err != nil {
+			return nil, err
+		}
+		strategy = engine.TopNStrategy{N: s.Top, Weighting: w, Constraints: s.constraints()}
+	case "momentum":
+		strategy = engine.SectorMomentumStrategy{
+			History: history, Lookback: s.Lookback, Skip: s.Skip, PerSector: s.PerSector, N: s.Universe,
+		}
+	default:
+		return nil, fmt.Errorf("unknown strategy %q (want top-n or momentum)", s.Strategy)
+	}
+	if s.MinMarketCap > 0 || len(s.Countries) > 0 || s.MinListingDays > 0 {
+		strategy = engine.FilterUniverse(strategy, engine.UniverseFilter{
+			MinMarketCap: s.MinMarketCap, Countries: s.Countries, MinListingDays: s.MinListingDays,
+		})
+	}
+	return strategy, nil
+}
+
+// runSweep walks every parameter combination forward, in parallel, and
+// writes the comparison matrix. With simulate each window simulates the
+// -strategy; otherwise it scores the top-N buy-and-hold.
+func runSweep(snapshots []domain.Snapshot, cfg engine.WalkForwardConfig, params []engine.Param,
+	settings strategySettings, simCfg engine.SimulationConfig, simulate bool,
+	costs *engine.CostModel, benchmark *engine.Benchmark, workers int, out string) {
+	for _, p := range params {
+		_, ok := sweepable[p.Name]
+		if _, sim := simulationSweepable[p.Name]; sim && simulate {
+			ok = true
+		}
+		if !ok {
+			names := make([]string, 0, len(sweepable)+len(simulationSweepable))
+			for name := range sweepable {
+				names = append(names, name)
+			}
+			if simulate {
+				for name := range simulationSweepable {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+			log.Fatalf("❌ Cannot sweep %q (want one of %v)", p.Name, names)
+		}
+	}
+
+	grid := engine.Grid(params)
+	log.Printf("🧮 Sweeping %d parameter sets on %d workers", len(grid), workers)
+	evaluator := func(set engine.ParamSet) (engine.Evaluator, error) {
+		s, c := settings, simCfg
+		for name, v := range set {
+			if apply, ok := sweepable[name]; ok {
+				apply(&s, v)
+			} else {
+				simulationSweepable[name](&c, v)
+			}
+		}
+		if !simulate {
+			return engine.TopNHoldEvaluator(engine.TopNConfig{
+				N: s.Top, Costs: costs, Benchmark: benchmark, Constraints: s.constraints(),
+			}), nil
+		}
+		if _, err := s.strategy(nil); err != nil {
+			return nil, err
+		}
+		return engine.SimulationEvaluator(func(history []domain.Snapshot) engine.Strategy {
+			strategy, _ := s.strategy(history)
+			return strategy
+		}, c), nil
+	}
+
+	results, err := engine.Sweep(snapshots, cfg, grid, evaluator, workers)
+	if err != nil {
+		log.Fatalf("❌ Sweep failed: %v", err)
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			log.Printf("⚠️  %s: %v", r.Params, r.Err)
+			continue
+		}
+		ret := r.Result.Aggregate["return"]
+		log.Printf("📊 %s | return mean %.2f%% | std %.2f%% (n=%d)", r.Params, ret.Mean*100, ret.StdDev*100, ret.Count)
+	}
+	if err := engine.WriteSweepMatrix(out, results); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	log.Printf("💾 Saved sweep matrix to %s", out)
+}
@@ -0,0 +1,100 @@
+// Package canonical serializes collector outputs so identical data always
+// yields byte-identical files, which keeps snapshot diffs and checksums
+// meaningful between runs.
+package canonical
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Indent is the indentation of canonical JSON
+const Indent = "  "
+
+// JSON returns the canonical encoding of v:
+//   - struct fields in declaration order and map keys sorted, as encoding/json does
+//   - two-space indentation and a trailing newline
+//   - <, > and & written literally rather than as \u escapes
+//   - negative zero written as 0
+//
+// NaN and infinite floats are rejected, as they have no JSON form.
+func JSON(v any) ([]byte, error) {
+	var raw bytes.Buffer
+	encoder := json.NewEncoder(&raw)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+
+	// Indent keeps the newline the encoder ends with
+	var out bytes.Buffer
+	if err := json.Indent(&out, normalizeZeros(raw.Bytes()), "", Indent); err != nil {
+		return nil, fmt.Errorf("failed to indent canonical JSON: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// Encode writes the canonical encoding of v to w
+func Encode(w io.Writer, v any) error {
+	data, err := JSON(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// normalizeZeros rewrites -0 number literals outside strings as 0. A float
+// that rounded to zero from below encodes as -0, which would otherwise make an
+// unchanged value show up in diffs.
+func normalizeZeros(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	inString, escaped := false, false
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		switch {
+		case inString:
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == '"':
+				inString = false
+			}
+		case b == '"':
+			inString = true
+		case b == '-':
+			end := i + 1
+			for end < len(data) && isNumberByte(data[end]) {
+				end++
+			}
+			if isZero(data[i+1 : end]) {
+				out = append(out, data[i+1:end]...)
+				i = end - 1
+				continue
+			}
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+func isNumberByte(b byte) bool {
+	return b >= '0' && b <= '9' || b == '.' || b == 'e' || b == 'E' || b == '+' || b == '-'
+}
+
+// isZero reports whether an unsigned number literal has no non-zero digits
+func isZero(number []byte) bool {
+	for _, b := range number {
+		if b == 'e' || b == 'E' {
+			break
+		}
+		if b >= '1' && b <= '9' {
+			return false
+		}
+	}
+	return len(number) > 0
+}
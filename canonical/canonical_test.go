@@ -0,0 +1,45 @@
+package canonical
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	type row struct {
+		Ticker string  `json:"ticker"`
+		Change float64 `json:"change"`
+	}
+	tests := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{"struct fields in declaration order", row{"AAPL", 1.5}, "{\n  \"ticker\": \"AAPL\",\n  \"change\": 1.5\n}\n"},
+		{"map keys sorted", map[string]int{"b": 2, "a": 1}, "{\n  \"a\": 1,\n  \"b\": 2\n}\n"},
+		{"HTML characters literal", "AT&T <b>", "\"AT&T <b>\"\n"},
+		{"negative zero", []float64{math.Copysign(0, -1), -0.5}, "[\n  0,\n  -0.5\n]\n"},
+		{"negative zero in a string kept", row{"-0", math.Copysign(0, -1)}, "{\n  \"ticker\": \"-0\",\n  \"change\": 0\n}\n"},
+		{"escaped quote before a minus", []string{`"\"-0`}, "[\n  \"\\\"\\\\\\\"-0\"\n]\n"},
+		{"empty slice", []int{}, "[]\n"},
+	}
+	for _, tt := range tests {
+		got, err := JSON(tt.v)
+		if err != nil {
+			t.Errorf("%s: JSON = %v", tt.name, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: JSON = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestJSONRejectsNonFinite(t *testing.T) {
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if _, err := JSON([]float64{f}); err == nil || !strings.Contains(err.Error(), "unsupported value") {
+			t.Errorf("JSON(%v) = %v, want an unsupported value error", f, err)
+		}
+	}
+}
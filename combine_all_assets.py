@@ -1,5 +1,6 @@
 #!/usr/bin/env python3
 
+import hashlib
 import json
 import logging
 import os
@@ -167,8 +168,39 @@ class AssetCombiner:
         
         return asset
     
+    def resolve_latest(self, filename: str) -> str:
+        """Follow a .latest pointer file left by --timestamped-output runs"""
+        pointer = filename + '.latest'
+        if not os.path.exists(pointer):
+            return filename
+        with open(pointer, 'r') as f:
+            target = f.read().strip()
+        return os.path.join(os.path.dirname(filename), target) if target else filename
+
+    def verify_checksum(self, filename: str) -> bool:
+        """Check a file against its .sha256 sidecar when one exists"""
+        checksum_file = filename + '.sha256'
+        if not os.path.exists(checksum_file) or not os.path.exists(filename):
+            return True
+        with open(checksum_file, 'r') as f:
+            fields = f.read().split()
+        if not fields:
+            logger.error(f"❌ Malformed checksum file: {checksum_file}")
+            return False
+        digest = hashlib.sha256()
+        with open(filename, 'rb') as f:
+            for chunk in iter(lambda: f.read(65536), b''):
+                digest.update(chunk)
+        if digest.hexdigest() != fields[0].lower():
+            logger.error(f"❌ Checksum mismatch for {filename}, refusing to load")
+            return False
+        return True
+
     def load_json_file(self, filename: str) -> List[Dict]:
         """Load and validate JSON file"""
+        filename = self.resolve_latest(filename)
+        if not self.verify_checksum(filename):
+            return []
         try:
             with open(filename, 'r') as f:
                 data = json.load(f)
@@ -0,0 +1,126 @@
+package cron
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expr, want string
+	}{
+		{"* * * *", "must have 5 fields"},
+		{"* * * * * *", "must have 5 fields"},
+		{"60 * * * *", "minute"},
+		{"* 24 * * *", "hour"},
+		{"* * 0 * *", "day of month"},
+		{"* * * 13 *", "month"},
+		{"* * * * 8", "day of week"},
+		{"* * * FOO *", "month"},
+		{"5-1 * * * *", "invalid range"},
+		{"*/0 * * * *", "invalid step"},
+		{"*/x * * * *", "invalid step"},
+		{"0 0 30 2 *", "never matches"},
+		{"0 0 31 4,6,9,11 *", "never matches"},
+		{"0 0 30,31 FEB *", "never matches"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.expr)
+		if err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error containing %q", tt.expr, tt.want)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q) = %v, want an error containing %q", tt.expr, err, tt.want)
+		}
+	}
+}
+
+func TestParseMatchable(t *testing.T) {
+	for _, expr := range []string{
+		"0 0 29 2 *",   // leap years
+		"0 0 31 2 1",   // Mondays in February, either day field matching
+		"0 0 30 2,3 *", // March 30
+		"0 0 31 * *",
+	} {
+		if _, err := Parse(expr); err != nil {
+			t.Errorf("Parse(%q) = %v, want a schedule", expr, err)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	utc := func(year int, month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, 0, time.UTC)
+	}
+	// 2025-07-02 is a Wednesday
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"weekday evening", "0 22 * * 1-5", utc(2025, 7, 2, 10, 0, 0), utc(2025, 7, 2, 22, 0, 0)},
+		{"weekday range skips weekend", "0 22 * * 1-5", utc(2025, 7, 4, 22, 0, 0), utc(2025, 7, 7, 22, 0, 0)},
+		{"strictly after", "0 22 * * *", utc(2025, 7, 2, 22, 0, 0), utc(2025, 7, 3, 22, 0, 0)},
+		{"seconds truncated", "0 22 * * *", utc(2025, 7, 2, 21, 59, 30), utc(2025, 7, 2, 22, 0, 0)},
+		{"step", "*/15 * * * *", utc(2025, 7, 2, 10, 7, 0), utc(2025, 7, 2, 10, 15, 0)},
+		{"stepped range", "5-10/2 * * * *", utc(2025, 7, 2, 10, 6, 0), utc(2025, 7, 2, 10, 7, 0)},
+		{"stepped range ends", "5-10/2 * * * *", utc(2025, 7, 2, 10, 9, 0), utc(2025, 7, 2, 11, 5, 0)},
+		{"stepped start runs to max", "30/10 9 * * *", utc(2025, 7, 2, 9, 50, 0), utc(2025, 7, 3, 9, 30, 0)},
+		{"list", "0 8,12,16 * * *", utc(2025, 7, 2, 12, 0, 0), utc(2025, 7, 2, 16, 0, 0)},
+		{"day of month only", "0 0 13 * *", utc(2025, 7, 2, 0, 0, 0), utc(2025, 7, 13, 0, 0, 0)},
+		{"day of week only", "0 0 * * 0", utc(2025, 7, 2, 0, 0, 0), utc(2025, 7, 6, 0, 0, 0)},
+		{"sunday as 7", "0 0 * * 7", utc(2025, 7, 2, 0, 0, 0), utc(2025, 7, 6, 0, 0, 0)},
+		{"either day field, weekday first", "0 0 13 * 5", utc(2025, 7, 2, 0, 0, 0), utc(2025, 7, 4, 0, 0, 0)},
+		{"either day field, date first", "0 0 13 * 5", utc(2025, 7, 11, 1, 0, 0), utc(2025, 7, 13, 0, 0, 0)},
+		{"names", "0 8 * JAN MON", utc(2025, 7, 2, 0, 0, 0), utc(2026, 1, 5, 8, 0, 0)},
+		{"new year", "0 0 1 1 *", utc(2025, 7, 2, 0, 0, 0), utc(2026, 1, 1, 0, 0, 0)},
+		{"leap day", "0 0 29 2 *", utc(2025, 3, 1, 0, 0, 0), utc(2028, 2, 29, 0, 0, 0)},
+		{"no leap day within five years", "0 0 29 2 *", utc(2096, 3, 1, 0, 0, 0), time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("%s: Parse(%q) = %v", tt.name, tt.expr, err)
+		}
+		if got := s.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%s: %q.Next(%s) = %s, want %s", tt.name, tt.expr, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestNextDaylightSaving(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no timezone database:", err)
+	}
+	local := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2025, month, day, hour, min, 0, 0, ny)
+	}
+	// Clocks skip 02:00-03:00 on 2025-03-09 and repeat 01:00-02:00 on 2025-11-02
+	firstPass := local(11, 2, 1, 30)
+	secondPass := firstPass.Add(time.Hour)
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"skipped time waits a day", "30 2 * * *", local(3, 8, 3, 0), local(3, 10, 2, 30)},
+		{"hourly across the gap", "0 * * * *", local(3, 9, 1, 30), local(3, 9, 3, 0)},
+		{"repeated time fires on its first pass", "30 1 * * *", local(11, 2, 0, 0), firstPass},
+		{"repeated time fires once", "30 1 * * *", firstPass, local(11, 3, 1, 30)},
+		{"started during the second pass", "30 1 * * *", secondPass.Add(-20 * time.Minute), secondPass},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("%s: Parse(%q) = %v", tt.name, tt.expr, err)
+		}
+		if got := s.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%s: %q.Next(%s) = %s, want %s", tt.name, tt.expr, tt.from, got, tt.want)
+		}
+	}
+}
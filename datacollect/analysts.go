@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"algotradar/backtest/engine"
+	"algotradar/domain"
+	"algotradar/logging"
+	"algotradar/marketdata"
+)
+
+// DefaultAnalystsPath is where the analyst collector writes by default
+const DefaultAnalystsPath = "analyst_ratings.json"
+
+func runAnalysts(args []string) error {
+	fs := flag.NewFlagSet("analysts", flag.ExitOnError)
+	snapshotPath := fs.String("snapshot", "global_stocks_fmp.json", "snapshot whose rows make up the universe to cover")
+	out := fs.String("out", DefaultAnalystsPath, "file to write the ratings to; ratings already in it for other symbols or dates are kept")
+	dateFlag := fs.String("date", "", "date to file the ratings under, YYYY-MM-DD (default today, UTC)")
+	days := fs.Int("days", 90, "keep the upgrades and downgrades published this many days before the date")
+	minCap := fs.Float64("min-market-cap", 0, "minimum market cap in USD, e.g. 10e9")
+	countries := fs.String("country", "", "comma-separated ISO country codes to keep")
+	exchanges := fs.String("exchange", "", "comma-separated primary exchanges to keep")
+	types := fs.String("type", "stock", "comma-separated asset types to keep (empty = all)")
+	top := fs.Int("top", 0, "cover only the largest N companies left after the filters (0 = all)")
+	workers := fs.Int("workers", 4, "symbols fetched concurrently")
+	var logOpts logging.Options
+	logging.RegisterFlags(fs, &logOpts)
+	fs.Parse(args)
+
+	logger, err := logging.Setup(logOpts)
+	if err != nil {
+		return err
+	}
+	date := time.Now().UTC()
+	if *dateFlag != "" {
+		if date, err = time.Parse("2006-01-02", *dateFlag); err != nil {
+			return fmt.Errorf("invalid --date: %w", err)
+		}
+	}
+	if *days < 0 {
+		return errors.New("--days must not be negative")
+	}
+	if *workers < 1 {
+		return errors.New("--workers must be at least 1")
+	}
+
+	assets, err := readSnapshot(*snapshotPath)
+	if err != nil {
+		return err
+	}
+	filter := engine.UniverseFilter{
+		MinMarketCap: *minCap,
+		Countries:    splitList(*countries),
+		Exchanges:    splitList(*exchanges),
+		AssetTypes:   splitList(*types),
+	}
+	members := screenedUniverse(filter.Apply(assets), *top)
+	if len(members) == 0 {
+		return fmt.Errorf("no rows of %s pass the filters", *snapshotPath)
+	}
+
+	keys, baseURL, signingKey, err := fmpEnv(logger)
+	if err != nil {
+		return err
+	}
+
+	known, err := readHistory[domain.AnalystRating](*out, "analyst ratings", logger)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	get := fmpGetter(ctx, &http.Client{Timeout: 30 * time.Second}, keys, baseURL, logger)
+	ratings, fetchErr := fetchEach(ctx, members, *workers, "analyst rating", logger, func(asset domain.Asset) (domain.AnalystRating, error) {
+		return marketdata.FetchAnalystRating(get, asset.Ticker, date, *days)
+	})
+	var covered []domain.AnalystRating
+	for _, r := range ratings {
+		if r.Covered() {
+			covered = append(covered, r)
+		}
+	}
+	if len(covered) == 0 && fetchErr != nil {
+		return fetchErr
+	}
+
+	// Ratings from earlier runs are merged in, so the file builds a history
+	// keyed by symbol and date
+	history := mergeHistory(known, covered,
+		func(r domain.AnalystRating) string { return r.Symbol + "|" + r.Date },
+		func(a, b domain.AnalystRating) bool {
+			if a.Date != b.Date {
+				return a.Date < b.Date
+			}
+			return a.Symbol < b.Symbol
+		})
+	if err := writeHistory(*out, "analyst ratings", history, signingKey); err != nil {
+		return err
+	}
+	logger.Info("analyst ratings written", "path", *out, "date", date.Format("2006-01-02"),
+		"symbols", len(members), "covered", len(covered), "total", len(history))
+	// A partial run still writes what it fetched, but exits non-zero
+	return fetchErr
+}
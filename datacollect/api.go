@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"algotradar/api"
+	"algotradar/assetsrpc"
+	"algotradar/logging"
+)
+
+func runAPI(args []string) error {
+	fs := flag.NewFlagSet("api", flag.ExitOnError)
+	addr := fs.String("addr", ":8080", "listen address")
+	file := fs.String("file", "global_stocks_fmp.json", "snapshot to serve; reloaded whenever it changes")
+	grpcAddr := fs.String("grpc-addr", "", "also serve the AssetService gRPC API on this address (e.g. :9090)")
+	var logOpts logging.Options
+	logging.RegisterFlags(fs, &logOpts)
+	fs.Parse(args)
+
+	logger, err := logging.Setup(logOpts)
+	if err != nil {
+		return err
+	}
+
+	s := api.New(*file)
+	s.Logger = logger
+	server := &http.Server{Addr: *addr, Handler: s.Handler(), ReadHeaderTimeout: 10 * time.Second}
+
+	var grpcServer *grpc.Server
+	if *grpcAddr != "" {
+		lis, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			return err
+		}
+		grpcServer = grpc.NewServer()
+		assetsrpc.Register(grpcServer, s, nil)
+		go func() {
+			if err := grpcServer.Serve(lis); err != nil {
+				logger.Error("gRPC listener stopped", "addr", *grpcAddr, "error", err)
+			}
+		}()
+		logger.Info("serving gRPC", "addr", *grpcAddr)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+		if grpcServer != nil {
+			grpcServer.GracefulStop()
+		}
+	}()
+
+	logger.Info("serving snapshot", "addr", *addr, "file", *file)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
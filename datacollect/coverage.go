@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"algotradar/backtest/engine"
+)
+
+func runCoverage(args []string) error {
+	fs := flag.NewFlagSet("coverage", flag.ExitOnError)
+	archiveDir := fs.String("archive", ".", "directory containing dated snapshot files")
+	prefix := fs.String("prefix", "global_assets_fmp_", "snapshot file name prefix")
+	format := fs.String("format", "text", "output format: text, csv or json")
+	ticker := fs.String("ticker", "", "only report this ticker")
+	showGaps := fs.Bool("gaps", false, "list every gap in text output")
+	fs.Parse(args)
+
+	snapshots, err := engine.LoadArchive(*archiveDir, *prefix)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		return fmt.Errorf("no %s*.json snapshots found in %s", *prefix, *archiveDir)
+	}
+
+	rows := engine.Coverage(snapshots)
+	if *ticker != "" {
+		var filtered []engine.TickerCoverage
+		for _, row := range rows {
+			if strings.EqualFold(row.Ticker, *ticker) {
+				filtered = append(filtered, row)
+			}
+		}
+		rows = filtered
+	}
+
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(struct {
+			From    string                  `json:"archive_from"`
+			To      string                  `json:"archive_to"`
+			Count   int                     `json:"snapshots"`
+			Tickers []engine.TickerCoverage `json:"tickers"`
+		}{
+			From:    snapshots[0].Date.Format("2006-01-02"),
+			To:      snapshots[len(snapshots)-1].Date.Format("2006-01-02"),
+			Count:   len(snapshots),
+			Tickers: rows,
+		})
+
+	case "csv":
+		writer := csv.NewWriter(os.Stdout)
+		header := []string{"ticker"}
+		for _, dataset := range engine.Datasets {
+			header = append(header, dataset+"_available", dataset+"_first", dataset+"_last", dataset+"_gaps")
+		}
+		writer.Write(header)
+		for _, row := range rows {
+			record := []string{row.Ticker}
+			for _, dataset := range engine.Datasets {
+				cov := row.Datasets[dataset]
+				record = append(record,
+					strconv.Itoa(cov.Available), formatFirst(cov), formatLast(cov), strconv.Itoa(len(cov.Gaps)))
+			}
+			writer.Write(record)
+		}
+		writer.Flush()
+		return writer.Error()
+
+	case "text":
+		fmt.Printf("Archive: %d snapshots from %s to %s\n\n", len(snapshots),
+			snapshots[0].Date.Format("2006-01-02"), snapshots[len(snapshots)-1].Date.Format("2006-01-02"))
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "TICKER\t%s\n", strings.ToUpper(strings.Join(engine.Datasets, "\t")))
+		for _, row := range rows {
+			cells := []string{row.Ticker}
+			for _, dataset := range engine.Datasets {
+				cov := row.Datasets[dataset]
+				cell := fmt.Sprintf("%d/%d", cov.Available, len(snapshots))
+				if len(cov.Gaps) > 0 {
+					cell += fmt.Sprintf(" (%d gaps)", len(cov.Gaps))
+				}
+				cells = append(cells, cell)
+			}
+			fmt.Fprintln(tw, strings.Join(cells, "\t"))
+
+			if *showGaps {
+				for _, dataset := range engine.Datasets {
+					for _, gap := range row.Datasets[dataset].Gaps {
+						fmt.Fprintf(tw, "  %s gap\t%s → %s (%d snapshots)\n", dataset,
+							gap.From.Format("2006-01-02"), gap.To.Format("2006-01-02"), gap.Days)
+					}
+				}
+			}
+		}
+		return tw.Flush()
+	}
+
+	return fmt.Errorf("unknown format %q", *format)
+}
+
+func formatFirst(cov engine.DatasetCoverage) string {
+	if cov.Available == 0 {
+		return ""
+	}
+	return cov.First.Format("2006-01-02")
+}
+
+func formatLast(cov engine.DatasetCoverage) string {
+	if cov.Available == 0 {
+		return ""
+	}
+	return cov.Last.Format("2006-01-02")
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// command is a datacollect subcommand; run receives the arguments after its name
+type command struct {
+	summary string
+	run     func(args []string) error
+}
+
+var commands = map[string]command{
+	"analysts":  {"collect consensus price targets, rating counts and grade changes for the screened universe", runAnalysts},
+	"api":       {"serve the latest snapshot over HTTP with filtering and pagination", runAPI},
+	"coverage":  {"report which datasets each ticker has in the archive and where gaps exist", runCoverage},
+	"delisted":  {"collect delisted companies from FMP for survivorship-free backtests", runDelisted},
+	"diff":      {"compare two snapshots: entrants, dropouts, rank changes and market cap deltas", runDiff},
+	"drain":     {"retry enrichment lookups the collectors deferred after running out of quota", runDrain},
+	"intraday":  {"poll watchlist quotes during market hours and emit only the prices that changed", runIntraday},
+	"ipos":      {"collect upcoming and recent IPOs from the FMP IPO calendar", runIPOs},
+	"keygen":    {"generate an Ed25519 key pair for signing snapshots", runKeygen},
+	"migrate":   {"create or update the schema of database sinks such as ClickHouse", runMigrate},
+	"news":      {"collect headlines and press releases for the screened universe, deduplicated across runs", runNews},
+	"ownership": {"collect 13F institutional holders and ownership concentration for the screened universe", runOwnership},
+	"selftest":  {"run the collectors against bundled FMP fixtures and compare outputs with golden files", runSelftest},
+	"serve":     {"run collection jobs on cron schedules as a long-lived daemon", runServe},
+	"universe":  {"list the universe as it stood on a past date, from the archive", runUniverse},
+	"validate":  {"check JSON output files against the published schema of their rows", runValidate},
+	"verify":    {"check snapshot files against their checksum and signature sidecars", runVerify},
+	"watchlist": {"add, remove and list named symbol lists for get_companies --watchlist and intraday", runWatchlist},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: datacollect <command> [flags]\n\ncommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", name, commands[name].summary)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd.run(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "datacollect %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
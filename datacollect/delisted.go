@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"algotradar/apikeys"
+	"algotradar/domain"
+	"algotradar/logging"
+	"algotradar/metrics"
+)
+
+// DefaultDelistedPath is where the delisted collector writes by default
+const DefaultDelistedPath = "delisted_companies.json"
+
+func runDelisted(args []string) error {
+	fs := flag.NewFlagSet("delisted", flag.ExitOnError)
+	out := fs.String("out", DefaultDelistedPath, "file to write the delisted companies to; entries already in it are kept")
+	maxPages := fs.Int("max-pages", 0, "fetch at most this many pages of 100 (0 = until the endpoint runs dry)")
+	var logOpts logging.Options
+	logging.RegisterFlags(fs, &logOpts)
+	fs.Parse(args)
+
+	logger, err := logging.Setup(logOpts)
+	if err != nil {
+		return err
+	}
+
+	keys, baseURL, signingKey, err := fmpEnv(logger)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	known, err := readHistory[domain.DelistedCompany](*out, "delisted companies", logger)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	var fetched []domain.DelistedCompany
+	for page := 0; *maxPages == 0 || page < *maxPages; page++ {
+		companies, err := fetchDelistedPage(ctx, client, keys, baseURL, page, logger)
+		if err != nil {
+			return err
+		}
+		if len(companies) == 0 {
+			break
+		}
+		for _, c := range companies {
+			if c.Symbol != "" {
+				fetched = append(fetched, c)
+			}
+		}
+		logger.Debug("fetched delisted companies page", "page", page, "count", len(companies))
+	}
+
+	// Earlier runs are merged in, so companies that age out of the
+	// endpoint's pages stay in the history
+	companies := mergeHistory(known, fetched, delistedKey, func(a, b domain.DelistedCompany) bool {
+		if a.DelistedDate != b.DelistedDate {
+			return a.DelistedDate < b.DelistedDate
+		}
+		return a.Symbol < b.Symbol
+	})
+	if err := writeHistory(*out, "delisted companies", companies, signingKey); err != nil {
+		return err
+	}
+	logger.Info("delisted companies written", "path", *out, "fetched", len(fetched), "total", len(companies))
+	return nil
+}
+
+// delistedKey identifies a delisting; a symbol can be reused by a later
+// listing that is delisted in turn
+func delistedKey(c domain.DelistedCompany) string {
+	return c.Symbol + "|" + c.DelistedDate
+}
+
+// fetchDelistedPage fetches one page, moving to the next key when one is
+// rejected or out of quota
+func fetchDelistedPage(ctx context.Context, client *http.Client, keys *apikeys.Pool, baseURL string, page int, logger *slog.Logger) ([]domain.DelistedCompany, error) {
+	for {
+		key, err := keys.Next()
+		if err != nil {
+			return nil, err
+		}
+		companies, status, err := requestDelistedPage(ctx, client, baseURL, key, page)
+		switch status {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			if keys.Disable(key) {
+				logger.Error("API key rejected, removing it from rotation", "key", apikeys.Mask(key), "usable", keys.Usable())
+			}
+		case http.StatusTooManyRequests:
+			keys.Exhaust(key)
+		default:
+			return companies, err
+		}
+		if keys.Usable() == 0 {
+			return nil, err
+		}
+	}
+}
+
+// requestDelistedPage makes one request with key, returning the HTTP status
+// alongside any error
+func requestDelistedPage(ctx context.Context, client *http.Client, baseURL, key string, page int) ([]domain.DelistedCompany, int, error) {
+	endpoint := "/v3/delisted-companies"
+	url := fmt.Sprintf("%s%s?page=%d&apikey=%s", baseURL, endpoint, page, key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	label := metrics.EndpointLabel(endpoint)
+	start := time.Now()
+	resp, err := client.Do(req)
+	metrics.APIDuration.Observe(time.Since(start).Seconds(), label)
+	if err != nil {
+		metrics.APICalls.Inc(label, "error")
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	metrics.APICalls.Inc(label, fmt.Sprint(resp.StatusCode))
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusTooManyRequests {
+			metrics.APIRateLimited.Inc(label)
+		}
+		return nil, resp.StatusCode, fmt.Errorf("delisted companies page %d failed with status %d", page, resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	var companies []domain.DelistedCompany
+	if err := json.Unmarshal(body, &companies); err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("failed to parse delisted companies page %d: %w", page, err)
+	}
+	return companies, resp.StatusCode, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"algotradar/domain"
+	"algotradar/output"
+	"algotradar/sink"
+	"algotradar/snapdiff"
+)
+
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	dbPath := fs.String("db", "", "compare two dates of this SQLite history (from --store) instead of two snapshot files")
+	top := fs.Int("top", 0, "compare only the largest N companies of each snapshot, e.g. 100 to track a top-100 index (0 = all)")
+	rows := fs.Int("rows", 20, "rank changes listed by the text format, largest move first (the JSON lists all)")
+	format := fs.String("format", "text", "output format: text or json")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: datacollect diff [flags] OLD.json NEW.json\n       datacollect diff [flags] --db data.db OLD-DATE NEW-DATE\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("want an old and a new snapshot")
+	}
+	load := readSnapshot
+	if *dbPath != "" {
+		load = func(date string) ([]domain.Asset, error) {
+			assets, err := sink.ReadSQLite(*dbPath, date)
+			if err == nil && len(assets) == 0 {
+				err = fmt.Errorf("no snapshot for %s in %s", date, *dbPath)
+			}
+			return assets, err
+		}
+	}
+	oldName, newName := fs.Arg(0), fs.Arg(1)
+	oldAssets, err := load(oldName)
+	if err != nil {
+		return err
+	}
+	newAssets, err := load(newName)
+	if err != nil {
+		return err
+	}
+	report := snapdiff.Compare(oldName, oldAssets, newName, newAssets, *top)
+
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+
+	case "text":
+		scope := "all companies"
+		if report.Top > 0 {
+			scope = fmt.Sprintf("top %d", report.Top)
+		}
+		fmt.Printf("%s -> %s (%s): %d -> %d companies, market cap %s -> %s\n",
+			report.Old, report.New, scope, report.OldCount, report.NewCount,
+			formatCap(report.OldMarketCap), formatCap(report.NewMarketCap))
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		members := func(title string, list []snapdiff.Member) {
+			fmt.Fprintf(tw, "\n%s (%d)\n", title, len(list))
+			if len(list) == 0 {
+				return
+			}
+			fmt.Fprintln(tw, "RANK\tTICKER\tNAME\tCOUNTRY\tMARKET CAP")
+			for _, m := range list {
+				fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", m.Rank, m.Ticker, m.Name, m.Country, formatCap(m.MarketCap))
+			}
+		}
+		members("Entrants", report.Entrants)
+		members("Dropouts", report.Dropouts)
+
+		changes := report.Changes
+		if *rows >= 0 && len(changes) > *rows {
+			changes = changes[:*rows]
+		}
+		fmt.Fprintf(tw, "\nRank changes (%d of %d)\n", len(changes), len(report.Changes))
+		if len(changes) > 0 {
+			fmt.Fprintln(tw, "TICKER\tNAME\tRANK\tMOVE\tMARKET CAP\tCHANGE")
+			for _, c := range changes {
+				fmt.Fprintf(tw, "%s\t%s\t%d -> %d\t%+d\t%s -> %s\t%+.1f%%\n", c.Ticker, c.Name, c.OldRank, c.NewRank,
+					c.RankChange, formatCap(c.OldMarketCap), formatCap(c.NewMarketCap), c.MarketCapChangePct)
+			}
+		}
+		return tw.Flush()
+	}
+
+	return fmt.Errorf("unknown format %q", *format)
+}
+
+// readSnapshot reads a JSON snapshot file as the collectors write it
+func readSnapshot(path string) ([]domain.Asset, error) {
+	data, err := output.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	var assets []domain.Asset
+	if err := json.Unmarshal(data, &assets); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %w", path, err)
+	}
+	return assets, nil
+}
+
+// formatCap abbreviates a USD market cap, e.g. $1.25T or $830.4M
+func formatCap(v float64) string {
+	sign := ""
+	if v < 0 {
+		sign, v = "-", -v
+	}
+	switch {
+	case v >= 1e12:
+		return fmt.Sprintf("%s$%.2fT", sign, v/1e12)
+	case v >= 1e9:
+		return fmt.Sprintf("%s$%.2fB", sign, v/1e9)
+	case v >= 1e6:
+		return fmt.Sprintf("%s$%.1fM", sign, v/1e6)
+	}
+	return fmt.Sprintf("%s$%.0f", sign, v)
+}
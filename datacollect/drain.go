@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"algotradar/enrich"
+	"algotradar/logging"
+)
+
+func runDrain(args []string) error {
+	fs := flag.NewFlagSet("drain", flag.ExitOnError)
+	queuePath := fs.String("queue", enrich.DefaultQueuePath, "deferred enrichment queue written by the collectors")
+	limit := fs.Int("limit", 0, "attempt at most this many tasks (0 = all)")
+	var logOpts logging.Options
+	logging.RegisterFlags(fs, &logOpts)
+	fs.Parse(args)
+
+	logger, err := logging.Setup(logOpts)
+	if err != nil {
+		return err
+	}
+
+	keys, baseURL, signingKey, err := fmpEnv(logger)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	d := &enrich.Drainer{
+		Queue:      enrich.NewQueue(*queuePath),
+		Fetch:      enrich.ProfileImageFetcher(keys, baseURL, &http.Client{Timeout: 30 * time.Second}),
+		Logger:     logger,
+		Limit:      *limit,
+		SigningKey: signingKey,
+	}
+	result, err := d.Drain(ctx)
+	logger.Info("drain finished", "done", result.Done, "discarded", result.Discarded, "remaining", result.Remaining, "quota_hit", result.QuotaHit)
+	return err
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"algotradar/apikeys"
+	"algotradar/domain"
+	"algotradar/integrity"
+	"algotradar/marketdata"
+	"algotradar/metrics"
+	"algotradar/secrets"
+)
+
+// screenedUniverse orders members by market cap, largest first, drops
+// repeated tickers and keeps the top N when top is positive
+func screenedUniverse(members []domain.Asset, top int) []domain.Asset {
+	sort.SliceStable(members, func(i, j int) bool { return members[i].MarketCap > members[j].MarketCap })
+	universe := make([]domain.Asset, 0, len(members))
+	seen := make(map[string]bool, len(members))
+	for _, a := range members {
+		if top > 0 && len(universe) == top {
+			break
+		}
+		if !seen[a.Ticker] {
+			seen[a.Ticker] = true
+			universe = append(universe, a)
+		}
+	}
+	return universe
+}
+
+// fetchEach calls fetch for each member with workers goroutines. Members
+// that fail are logged and left out. The run stops early once the plan turns
+// out not to include the endpoint or no key is left; the returned error then
+// says why, alongside the results fetched.
+func fetchEach[T any](ctx context.Context, members []domain.Asset, workers int, what string, logger *slog.Logger, fetch func(domain.Asset) (T, error)) ([]T, error) {
+	jobs := make(chan domain.Asset)
+	var (
+		mu      sync.Mutex
+		results []T
+		failed  int
+		fatal   error
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for asset := range jobs {
+				result, err := fetch(asset)
+				mu.Lock()
+				switch {
+				case err == nil:
+					results = append(results, result)
+				case errors.Is(err, marketdata.ErrNotEntitled), errors.Is(err, apikeys.ErrNoKeys), ctx.Err() != nil:
+					if fatal == nil {
+						fatal = err
+					}
+				default:
+					failed++
+					logger.Warn("failed to fetch "+what, "symbol", asset.Ticker, "error", err)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, asset := range members {
+		mu.Lock()
+		stopped := fatal != nil
+		mu.Unlock()
+		if stopped {
+			break
+		}
+		jobs <- asset
+	}
+	close(jobs)
+	wg.Wait()
+
+	if fatal != nil {
+		return results, fmt.Errorf("%s lookups stopped after %d of %d symbols: %w", what, len(results), len(members), fatal)
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("%s lookups failed for %d of %d symbols", what, failed, len(members))
+	}
+	return results, nil
+}
+
+// fmpEnv loads .env and the secrets backend into the environment and returns
+// the FMP key pool, the API base URL (FMP_BASE_URL points it at a mirror) and
+// the output signing key, nil when unset
+func fmpEnv(logger *slog.Logger) (*apikeys.Pool, string, ed25519.PrivateKey, error) {
+	if err := godotenv.Load(); err != nil {
+		logger.Warn("no .env file found, using environment variables")
+	}
+	if err := secrets.LoadEnv(context.Background(), logger); err != nil {
+		return nil, "", nil, err
+	}
+	keys := apikeys.FromEnv()
+	if keys.Len() == 0 {
+		return nil, "", nil, errors.New("FMP_API_KEY (or FMP_API_KEYS for a comma-separated pool) environment variable is required")
+	}
+	baseURL := "https://financialmodelingprep.com/api"
+	if base := os.Getenv("FMP_BASE_URL"); base != "" {
+		baseURL = strings.TrimSuffix(base, "/") + "/api"
+	}
+	signingKey, err := integrity.SigningKeyFromEnv()
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return keys, baseURL, signingKey, nil
+}
+
+// fmpGetter fetches FMP paths, moving to the next key when one is
+// rejected or out of quota. A 403 refusing the endpoint for the plan
+// returns marketdata.ErrNotEntitled and leaves the key in rotation.
+func fmpGetter(ctx context.Context, client *http.Client, keys *apikeys.Pool, baseURL string, logger *slog.Logger) func(path string) ([]byte, error) {
+	return func(path string) ([]byte, error) {
+		for {
+			key, err := keys.Next()
+			if err != nil {
+				return nil, err
+			}
+			body, status, err := requestFMP(ctx, client, baseURL, key, path)
+			switch {
+			case status == http.StatusForbidden && marketdata.NotEntitled(body):
+				return nil, fmt.Errorf("%s: %w", path, marketdata.ErrNotEntitled)
+			case status == http.StatusUnauthorized, status == http.StatusForbidden:
+				if keys.Disable(key) {
+					logger.Error("API key rejected, removing it from rotation", "key", apikeys.Mask(key), "usable", keys.Usable())
+				}
+			case status == http.StatusTooManyRequests:
+				keys.Exhaust(key)
+			default:
+				return body, err
+			}
+			if keys.Usable() == 0 {
+				return nil, fmt.Errorf("%w: %v", apikeys.ErrNoKeys, err)
+			}
+		}
+	}
+}
+
+// requestFMP makes one request with key, returning the HTTP status
+// alongside any error
+func requestFMP(ctx context.Context, client *http.Client, baseURL, key, path string) ([]byte, int, error) {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+path+separator+"apikey="+key, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	label := metrics.EndpointLabel(path)
+	start := time.Now()
+	resp, err := client.Do(req)
+	metrics.APIDuration.Observe(time.Since(start).Seconds(), label)
+	if err != nil {
+		metrics.APICalls.Inc(label, "error")
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	metrics.APICalls.Inc(label, fmt.Sprint(resp.StatusCode))
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusTooManyRequests {
+			metrics.APIRateLimited.Inc(label)
+		}
+		return body, resp.StatusCode, fmt.Errorf("%s failed with status %d", label, resp.StatusCode)
+	}
+	return body, resp.StatusCode, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+
+	"algotradar/canonical"
+	"algotradar/integrity"
+)
+
+// readHistory reads the entries earlier runs wrote to path; a file that does
+// not exist yet holds none
+func readHistory[T any](path, what string, logger *slog.Logger) ([]T, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var known []T
+	if err := json.Unmarshal(data, &known); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	logger.Info("loaded "+what, "path", path, "count", len(known))
+	return known, nil
+}
+
+// mergeHistory merges fetched into known, an entry replacing the one with
+// the same key, and returns the entries ordered by less
+func mergeHistory[T any](known, fetched []T, key func(T) string, less func(a, b T) bool) []T {
+	merged := make(map[string]T, len(known)+len(fetched))
+	for _, entry := range known {
+		merged[key(entry)] = entry
+	}
+	for _, entry := range fetched {
+		merged[key(entry)] = entry
+	}
+	history := make([]T, 0, len(merged))
+	for _, entry := range merged {
+		history = append(history, entry)
+	}
+	sort.Slice(history, func(i, j int) bool { return less(history[i], history[j]) })
+	return history
+}
+
+// writeHistory writes entries to path as canonical JSON and seals it with
+// signingKey
+func writeHistory[T any](path, what string, entries []T, signingKey ed25519.PrivateKey) error {
+	data, err := canonical.JSON(entries)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", what, err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return integrity.Seal(path, signingKey)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"algotradar/integrity"
+)
+
+func runKeygen(args []string) error {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	out := fs.String("out", "snapshot_signing", "key file prefix; writes <out>.key and <out>.pub")
+	fs.Parse(args)
+
+	pub, priv, err := integrity.GenerateKey()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out+".key", []byte(integrity.EncodeKey(priv)), 0600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out+".pub", []byte(integrity.EncodeKey(pub)), 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("wrote %s.key (set %s) and %s.pub (set %s)\n", *out, integrity.SigningKeyEnv, *out, integrity.VerifyKeyEnv)
+	return nil
+}
+
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	keyPath := fs.String("key", os.Getenv(integrity.VerifyKeyEnv), "public key file; when set signatures are required")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return errors.New("no files given")
+	}
+
+	var pub ed25519.PublicKey
+	if *keyPath != "" {
+		var err error
+		if pub, err = integrity.LoadPublicKey(*keyPath); err != nil {
+			return err
+		}
+	}
+
+	failed := 0
+	for _, path := range fs.Args() {
+		if err := integrity.Verify(path, pub); err != nil {
+			fmt.Printf("FAIL %v\n", err)
+			failed++
+			continue
+		}
+		fmt.Printf("OK   %s\n", path)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed verification", failed, fs.NArg())
+	}
+	return nil
+}
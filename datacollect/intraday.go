@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"algotradar/apikeys"
+	"algotradar/domain"
+	"algotradar/intraday"
+	"algotradar/logging"
+	"algotradar/marketdata"
+	"algotradar/watchlist"
+)
+
+// DefaultIntradayPath is where intraday ticks are appended by default
+const DefaultIntradayPath = "intraday.jsonl"
+
+func runIntraday(args []string) error {
+	fs := flag.NewFlagSet("intraday", flag.ExitOnError)
+	watchlists := fs.String("watchlists", watchlist.DefaultPath, "where list:NAME watchlists are kept: a JSON file or sqlite:PATH")
+	watchlist := fs.String("watchlist", "", "comma-separated symbols to poll, @FILE for a file with one symbol per line, or list:NAME for a watchlist saved with datacollect watchlist (required)")
+	interval := fs.Duration("interval", 15*time.Second, "time between polls")
+	duration := fs.Duration("duration", 0, "stop after this long (0 = run until interrupted)")
+	ignoreHours := fs.Bool("ignore-hours", false, "poll outside the watched markets' regular sessions too")
+	var emits intraday.Specs
+	fs.Var(&emits, "emit", "where changed quotes go: file:PATH (JSON Lines), sqs:QUEUE_URL or pubsub:projects/P/topics/T; repeatable (default file:"+DefaultIntradayPath+")")
+	var logOpts logging.Options
+	logging.RegisterFlags(fs, &logOpts)
+	fs.Parse(args)
+
+	logger, err := logging.Setup(logOpts)
+	if err != nil {
+		return err
+	}
+	symbols, err := readWatchlist(*watchlist, *watchlists)
+	if err != nil {
+		return err
+	}
+	if *interval < time.Second {
+		return errors.New("--interval must be at least 1s")
+	}
+	if *duration < 0 {
+		return errors.New("--duration must not be negative")
+	}
+	if len(emits) == 0 {
+		emits = intraday.Specs{"file:" + DefaultIntradayPath}
+	}
+
+	keys, baseURL, _, err := fmpEnv(logger)
+	if err != nil {
+		return err
+	}
+
+	var emitters []intraday.Emitter
+	defer func() {
+		for _, e := range emitters {
+			if err := e.Close(); err != nil {
+				logger.Warn("failed to close emitter", "emitter", e.String(), "error", err)
+			}
+		}
+	}()
+	for _, spec := range emits {
+		e, err := intraday.Parse(spec)
+		if err != nil {
+			return err
+		}
+		emitters = append(emitters, e)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	get := fmpGetter(ctx, &http.Client{Timeout: 30 * time.Second}, keys, baseURL, logger)
+	poller := intraday.NewPoller(symbols, func(symbols []string) ([]domain.Quote, error) {
+		return marketdata.FetchQuotes(get, symbols)
+	})
+	logger.Info("intraday polling started", "symbols", len(symbols), "interval", interval.String(), "emit", emits.String())
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	polls, emitted := 0, 0
+	closed := false
+	var exhausted error
+poll:
+	for {
+		now := time.Now()
+		if *ignoreHours || poller.Open(now) {
+			if closed {
+				logger.Info("market open, polling resumed")
+				closed = false
+			}
+			ticks, err := poller.Poll(now)
+			polls++
+			if err != nil {
+				if errors.Is(err, apikeys.ErrNoKeys) {
+					exhausted = err
+				} else if ctx.Err() == nil {
+					logger.Warn("quote poll incomplete", "error", err)
+				}
+			}
+			if len(ticks) > 0 {
+				if err := emit(ctx, emitters, ticks, logger); err != nil {
+					// Undelivered symbols are emitted again on the next poll
+					poller.Forget(ticks)
+				} else {
+					emitted += len(ticks)
+				}
+			}
+			logger.Debug("polled quotes", "changed", len(ticks))
+		} else if !closed {
+			logger.Info("markets closed, waiting for the next session")
+			closed = true
+		}
+
+		if exhausted != nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			break poll
+		case <-ticker.C:
+		}
+	}
+	logger.Info("intraday polling stopped", "polls", polls, "emitted", emitted)
+	return exhausted
+}
+
+// emit hands ticks to every emitter and fails if any of them does
+func emit(ctx context.Context, emitters []intraday.Emitter, ticks []domain.IntradayTick, logger *slog.Logger) error {
+	var failed error
+	for _, e := range emitters {
+		if err := e.Emit(ctx, ticks); err != nil {
+			logger.Warn("failed to emit ticks", "emitter", e.String(), "ticks", len(ticks), "error", err)
+			failed = err
+		}
+	}
+	return failed
+}
+
+// readWatchlist parses --watchlist: a comma-separated list, @FILE naming a
+// file with one symbol (or comma-separated symbols) per line, where lines
+// starting with # are comments, or list:NAME naming a watchlist in the store
+// storeSpec
+func readWatchlist(value, storeSpec string) ([]string, error) {
+	if value == "" {
+		return nil, errors.New("--watchlist is required")
+	}
+	if name, ok := strings.CutPrefix(value, "list:"); ok {
+		return watchlist.Load(storeSpec, name)
+	}
+	if path, ok := strings.CutPrefix(value, "@"); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var lines []string
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				lines = append(lines, line)
+			}
+		}
+		value = strings.Join(lines, ",")
+	}
+	seen := make(map[string]bool)
+	var symbols []string
+	for _, symbol := range splitList(value) {
+		symbol = strings.ToUpper(symbol)
+		if !seen[symbol] {
+			seen[symbol] = true
+			symbols = append(symbols, symbol)
+		}
+	}
+	if len(symbols) == 0 {
+		return nil, fmt.Errorf("watchlist %q has no symbols", value)
+	}
+	return symbols, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"algotradar/domain"
+	"algotradar/logging"
+	"algotradar/marketdata"
+)
+
+// DefaultIPOsPath is where the IPO collector writes by default
+const DefaultIPOsPath = "ipo_calendar.json"
+
+func runIPOs(args []string) error {
+	fs := flag.NewFlagSet("ipos", flag.ExitOnError)
+	out := fs.String("out", DefaultIPOsPath, "file to write the IPO calendar to; IPOs already in it are kept")
+	dateFlag := fs.String("date", "", "date the calendar is centred on, YYYY-MM-DD (default today, UTC)")
+	daysBack := fs.Int("days-back", domain.RecentIPODays, "fetch the IPOs that listed this many days before the date")
+	daysAhead := fs.Int("days-ahead", 30, "fetch the IPOs expected this many days after the date")
+	var logOpts logging.Options
+	logging.RegisterFlags(fs, &logOpts)
+	fs.Parse(args)
+
+	logger, err := logging.Setup(logOpts)
+	if err != nil {
+		return err
+	}
+	date := time.Now().UTC().Truncate(24 * time.Hour)
+	if *dateFlag != "" {
+		if date, err = time.Parse("2006-01-02", *dateFlag); err != nil {
+			return fmt.Errorf("invalid --date: %w", err)
+		}
+	}
+	if *daysBack < 0 || *daysAhead < 0 {
+		return errors.New("--days-back and --days-ahead must not be negative")
+	}
+
+	keys, baseURL, signingKey, err := fmpEnv(logger)
+	if err != nil {
+		return err
+	}
+
+	known, err := readHistory[domain.IPO](*out, "IPO calendar", logger)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	get := fmpGetter(ctx, &http.Client{Timeout: 30 * time.Second}, keys, baseURL, logger)
+	from, to := date.AddDate(0, 0, -*daysBack), date.AddDate(0, 0, *daysAhead)
+	fetched, err := marketdata.FetchIPOCalendar(get, from, to)
+	if err != nil {
+		return err
+	}
+
+	// Earlier runs are merged in, so IPOs that leave the window stay in the
+	// history. An IPO is keyed by symbol: a postponed one moves to its new
+	// date and a priced one replaces its expected entry. The calendar comes
+	// by date, so a symbol listed twice keeps its latest entry.
+	ipos := mergeHistory(known, fetched, func(ipo domain.IPO) string { return ipo.Symbol },
+		func(a, b domain.IPO) bool {
+			if a.Date != b.Date {
+				return a.Date < b.Date
+			}
+			return a.Symbol < b.Symbol
+		})
+	upcoming, recent := 0, 0
+	today := date.Format("2006-01-02")
+	for _, ipo := range ipos {
+		switch {
+		case ipo.Date > today:
+			upcoming++
+		case ipo.Date >= from.Format("2006-01-02") && strings.EqualFold(ipo.Status, "Priced"):
+			recent++
+		}
+	}
+
+	if err := writeHistory(*out, "IPO calendar", ipos, signingKey); err != nil {
+		return err
+	}
+	logger.Info("IPO calendar written", "path", *out, "from", from.Format("2006-01-02"), "to", to.Format("2006-01-02"),
+		"fetched", len(fetched), "upcoming", upcoming, "recently_priced", recent, "total", len(ipos))
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/joho/godotenv"
+
+	"algotradar/logging"
+	"algotradar/secrets"
+	"algotradar/sink"
+)
+
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	var specs sink.Specs
+	fs.Var(&specs, "sink", "sink whose schema to create or update, e.g. clickhouse://localhost:8123/algotradar; repeatable")
+	printOnly := fs.Bool("print", false, "print the statements instead of running them")
+	var logOpts logging.Options
+	logging.RegisterFlags(fs, &logOpts)
+	fs.Parse(args)
+
+	logger, err := logging.Setup(logOpts)
+	if err != nil {
+		return err
+	}
+	if len(specs) == 0 {
+		return errors.New("no --sink given")
+	}
+	if err := godotenv.Load(); err != nil {
+		logger.Warn("no .env file found, using environment variables")
+	}
+	if err := secrets.LoadEnv(context.Background(), logger); err != nil {
+		return err
+	}
+
+	var migrators []sink.Migrator
+	for _, spec := range specs {
+		s, err := sink.Parse(spec)
+		if err != nil {
+			return err
+		}
+		migrator, ok := s.(sink.Migrator)
+		if !ok {
+			return fmt.Errorf("%s creates its own schema; only clickhouse:// sinks need migrating", s)
+		}
+		migrators = append(migrators, migrator)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	for _, m := range migrators {
+		if *printOnly {
+			fmt.Printf("-- %s\n", m)
+			for _, statement := range m.MigrationSQL() {
+				fmt.Printf("%s;\n", statement)
+			}
+			continue
+		}
+		if err := m.Migrate(ctx); err != nil {
+			return fmt.Errorf("%s: %w", m, err)
+		}
+		logger.Info("schema up to date", "sink", m.String())
+	}
+	return nil
+}
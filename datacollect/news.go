@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"algotradar/backtest/engine"
+	"algotradar/domain"
+	"algotradar/logging"
+	"algotradar/marketdata"
+	"algotradar/news"
+)
+
+// DefaultNewsPath is where the news collector writes by default
+const DefaultNewsPath = "news.json"
+
+func runNews(args []string) error {
+	fs := flag.NewFlagSet("news", flag.ExitOnError)
+	snapshotPath := fs.String("snapshot", "global_stocks_fmp.json", "snapshot whose rows make up the universe to cover")
+	out := fs.String("out", DefaultNewsPath, "file to write the news to; items already in it are kept and not repeated")
+	limit := fs.Int("limit", 20, "latest headlines to fetch per symbol")
+	pressReleases := fs.Bool("press-releases", true, "also fetch the issuers' press releases, one more request per symbol")
+	sentiment := fs.Bool("sentiment", false, "score the tone of new items from keywords in their title and summary")
+	retainDays := fs.Int("retain-days", 0, "drop items published more than this many days ago (0 = keep all)")
+	minCap := fs.Float64("min-market-cap", 0, "minimum market cap in USD, e.g. 10e9")
+	countries := fs.String("country", "", "comma-separated ISO country codes to keep")
+	exchanges := fs.String("exchange", "", "comma-separated primary exchanges to keep")
+	types := fs.String("type", "stock", "comma-separated asset types to keep (empty = all)")
+	top := fs.Int("top", 0, "cover only the largest N companies left after the filters (0 = all)")
+	workers := fs.Int("workers", 4, "symbols fetched concurrently")
+	var logOpts logging.Options
+	logging.RegisterFlags(fs, &logOpts)
+	fs.Parse(args)
+
+	logger, err := logging.Setup(logOpts)
+	if err != nil {
+		return err
+	}
+	if *limit < 1 {
+		return errors.New("--limit must be at least 1")
+	}
+	if *retainDays < 0 {
+		return errors.New("--retain-days must not be negative")
+	}
+	if *workers < 1 {
+		return errors.New("--workers must be at least 1")
+	}
+
+	assets, err := readSnapshot(*snapshotPath)
+	if err != nil {
+		return err
+	}
+	filter := engine.UniverseFilter{
+		MinMarketCap: *minCap,
+		Countries:    splitList(*countries),
+		Exchanges:    splitList(*exchanges),
+		AssetTypes:   splitList(*types),
+	}
+	members := screenedUniverse(filter.Apply(assets), *top)
+	if len(members) == 0 {
+		return fmt.Errorf("no rows of %s pass the filters", *snapshotPath)
+	}
+
+	keys, baseURL, signingKey, err := fmpEnv(logger)
+	if err != nil {
+		return err
+	}
+	history, err := readHistory[domain.NewsItem](*out, "news", logger)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	get := fmpGetter(ctx, &http.Client{Timeout: 30 * time.Second}, keys, baseURL, logger)
+	batches, fetchErr := fetchEach(ctx, members, *workers, "news", logger, func(asset domain.Asset) ([]domain.NewsItem, error) {
+		return marketdata.FetchNews(get, asset.Ticker, *limit, *pressReleases)
+	})
+	var fetched []domain.NewsItem
+	for _, items := range batches {
+		fetched = append(fetched, items...)
+	}
+	if len(fetched) == 0 && fetchErr != nil {
+		return fetchErr
+	}
+	if *sentiment {
+		for i := range fetched {
+			if score, ok := news.Sentiment(fetched[i].Title + " " + fetched[i].Summary); ok {
+				fetched[i].Sentiment = &score
+			}
+		}
+	}
+
+	items, added := news.Merge(history, fetched)
+	if *retainDays > 0 {
+		before := len(items)
+		items = news.Prune(items, time.Now().UTC().AddDate(0, 0, -*retainDays).Format(time.RFC3339))
+		logger.Info("pruned old news", "dropped", before-len(items), "retain_days", *retainDays)
+	}
+
+	if err := writeHistory(*out, "news", items, signingKey); err != nil {
+		return err
+	}
+	logger.Info("news written", "path", *out, "symbols", len(members), "fetched", len(fetched),
+		"new", added, "duplicates", len(fetched)-added, "total", len(items))
+	// A partial run still writes what it fetched, but exits non-zero
+	return fetchErr
+}
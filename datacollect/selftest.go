@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"algotradar/aggregate"
+	"algotradar/canonical"
+	"algotradar/domain"
+	"algotradar/integrity"
+	"algotradar/mockfmp"
+	"algotradar/schema"
+)
+
+// selftestSnapshot is the --snapshot-time of selftest runs, a Wednesday when
+// New York and London are both trading
+const selftestSnapshot = "2025-07-02T14:00:00Z"
+
+// selftestCase is one collector run checked against a golden file
+type selftestCase struct {
+	name    string // golden file name
+	command string
+	extra   []string
+}
+
+func runSelftest(args []string) error {
+	fs := flag.NewFlagSet("selftest", flag.ExitOnError)
+	globalCmd := fs.String("get-companies", "go run ./get_companies", "command that runs the global collector")
+	usCmd := fs.String("us-stocks", "go run ./backtest/backend/assets/stocks", "command that runs the US collector")
+	dir := fs.String("dir", "", "write outputs here and keep them (default: a temporary directory that is removed)")
+	update := fs.Bool("update", false, "overwrite the golden files in "+mockfmp.GoldenDir+" with this build's outputs")
+	timeout := fs.Duration("timeout", 5*time.Minute, "abort a collector run after this long")
+	fs.Parse(args)
+
+	mock, err := mockfmp.New()
+	if err != nil {
+		return err
+	}
+	srv := httptest.NewServer(mock)
+	defer srv.Close()
+
+	workDir := *dir
+	if workDir == "" {
+		if workDir, err = os.MkdirTemp("", "datacollect-selftest-"); err != nil {
+			return err
+		}
+		defer os.RemoveAll(workDir)
+	} else if err := os.MkdirAll(workDir, 0755); err != nil {
+		return err
+	}
+
+	cases := []selftestCase{
+		{name: "global_stocks_fmp.json", command: *globalCmd, extra: []string{"--queue", "", "--quiet", "--snapshot-time", selftestSnapshot}},
+		{name: "us_stocks.json", command: *usCmd, extra: []string{"--checkpoint", "", "--snapshot-time", selftestSnapshot}},
+	}
+
+	failed := 0
+	for _, tc := range cases {
+		n, err := runSelftestCase(tc, srv.URL, workDir, *timeout, *update)
+		switch {
+		case err != nil:
+			fmt.Printf("FAIL %s: %v\n", tc.name, err)
+			failed++
+		case *update:
+			fmt.Printf("UPDATED %s (%d assets)\n", filepath.Join(mockfmp.GoldenDir, tc.name), n)
+		default:
+			fmt.Printf("PASS %s (%d assets)\n", tc.name, n)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d pipeline checks failed", failed, len(cases)+1)
+	}
+	return nil
+}
+
+// runSelftestCase runs one collector against the mock API and validates its
+// exit status, output checksum, run report and golden output
+func runSelftestCase(tc selftestCase, baseURL, workDir string, timeout time.Duration, update bool) (int, error) {
+	fields := strings.Fields(tc.command)
+	if len(fields) == 0 {
+		return 0, errors.New("empty command")
+	}
+	out := filepath.Join(workDir, tc.name)
+	report := filepath.Join(workDir, strings.TrimSuffix(tc.name, ".json")+"_run_report.json")
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	aggregates := filepath.Join(workDir, strings.TrimSuffix(tc.name, ".json"))
+	args := append(fields[1:], "--sink", "json:"+out, "--report", report, "--aggregates", aggregates,
+		"--cache-dir", "", "--log-level", "warn")
+	cmd := exec.CommandContext(ctx, fields[0], append(args, tc.extra...)...)
+	// The revoked key is rejected by the mock, so every run also exercises
+	// dropping a key from the rotation
+	cmd.Env = append(os.Environ(), "FMP_API_KEYS="+mockfmp.RevokedKey+",selftest", "FMP_BASE_URL="+baseURL)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return 0, fmt.Errorf("collector failed: %w\n%s", err, tail(output.String(), 20))
+	}
+
+	if err := integrity.Verify(out, nil); err != nil {
+		return 0, err
+	}
+	moversFile, sectorsFile, breadthFile := aggregate.Files(aggregates)
+	for _, derived := range []string{moversFile, sectorsFile, breadthFile} {
+		if err := integrity.Verify(derived, nil); err != nil {
+			return 0, err
+		}
+	}
+
+	var run domain.Run
+	if err := readJSON(report, &run); err != nil {
+		return 0, err
+	}
+	if run.ErrorCount > 0 {
+		return 0, fmt.Errorf("run report lists %d errors: %s", run.ErrorCount, strings.Join(run.Errors, "; "))
+	}
+
+	var got []domain.Asset
+	if err := readJSON(out, &got); err != nil {
+		return 0, err
+	}
+	if len(got) == 0 {
+		return 0, errors.New("collector wrote no assets")
+	}
+	raw, err := os.ReadFile(out)
+	if err != nil {
+		return 0, err
+	}
+	if canon, err := canonical.JSON(schema.Assets(got)); err != nil || !bytes.Equal(raw, canon) {
+		return 0, errors.New("output is not canonical JSON; identical runs would not be byte-identical")
+	}
+	if err := schema.Validate(schema.AssetSchema, raw); err != nil {
+		return 0, err
+	}
+
+	// The dropped symbols and their reasons pin down filtering and dedup,
+	// which the kept assets alone only show by omission
+	droppedName := strings.TrimSuffix(tc.name, ".json") + "_dropped.json"
+	if update {
+		if err := writeGolden(tc.name, got); err != nil {
+			return 0, err
+		}
+		return len(got), writeGolden(droppedName, run.Dropped)
+	}
+
+	var want []domain.Asset
+	if err := readGolden(tc.name, &want); err != nil {
+		return 0, err
+	}
+	if diffs := diffAssets(want, got); len(diffs) > 0 {
+		return 0, fmt.Errorf("output differs from golden file:\n  %s", strings.Join(diffs, "\n  "))
+	}
+	var wantDropped []domain.DroppedSymbol
+	if err := readGolden(droppedName, &wantDropped); err != nil {
+		return 0, err
+	}
+	if diffs := diffDropped(wantDropped, run.Dropped); len(diffs) > 0 {
+		return 0, fmt.Errorf("dropped symbols differ from golden file:\n  %s", strings.Join(diffs, "\n  "))
+	}
+	return len(got), nil
+}
+
+// writeGolden overwrites a golden file in the source tree
+func writeGolden(name string, v any) error {
+	data, err := canonical.JSON(v)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(mockfmp.GoldenDir, name), data, 0644)
+}
+
+// readGolden decodes a bundled golden file
+func readGolden(name string, v any) error {
+	golden, err := mockfmp.Golden(name)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(golden, v); err != nil {
+		return fmt.Errorf("invalid golden file %s: %w", name, err)
+	}
+	return nil
+}
+
+// diffAssets describes how got differs from want, row by row
+func diffAssets(want, got []domain.Asset) []string {
+	var diffs []string
+	if len(want) != len(got) {
+		diffs = append(diffs, fmt.Sprintf("%d assets, want %d", len(got), len(want)))
+	}
+	for i := 0; i < min(len(want), len(got)); i++ {
+		if !reflect.DeepEqual(want[i], got[i]) {
+			w, _ := json.Marshal(want[i])
+			g, _ := json.Marshal(got[i])
+			diffs = append(diffs, fmt.Sprintf("rank %d:\n    want %s\n    got  %s", i+1, w, g))
+		}
+	}
+	return diffs
+}
+
+// diffDropped lists symbols dropped in only one of want and got, or for a
+// different reason
+func diffDropped(want, got []domain.DroppedSymbol) []string {
+	reasons := make(map[string]string, len(want))
+	for _, d := range want {
+		reasons[d.Symbol] = d.Reason
+	}
+	var diffs []string
+	for _, d := range got {
+		reason, ok := reasons[d.Symbol]
+		switch {
+		case !ok:
+			diffs = append(diffs, fmt.Sprintf("%s dropped (%s), want kept", d.Symbol, d.Reason))
+		case reason != d.Reason:
+			diffs = append(diffs, fmt.Sprintf("%s dropped as %s, want %s", d.Symbol, d.Reason, reason))
+		}
+		delete(reasons, d.Symbol)
+	}
+	for symbol, reason := range reasons {
+		diffs = append(diffs, fmt.Sprintf("%s kept, want dropped (%s)", symbol, reason))
+	}
+	sort.Strings(diffs)
+	return diffs
+}
+
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// tail returns the last n lines of s
+func tail(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"algotradar/enrich"
+	"algotradar/logging"
+	"algotradar/scheduler"
+)
+
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	schedule := fs.String("schedule", "", `cron expression for the command given after the flags, e.g. "0 22 * * 1-5"`)
+	name := fs.String("name", "collect", "job name used with --schedule")
+	timeout := fs.String("timeout", "", "abort a --schedule run after this duration (e.g. 2h)")
+	configPath := fs.String("config", "", "JSON file listing jobs (name, schedule, command, dir, timeout)")
+	statusFile := fs.String("status-file", "scheduler_status.json", "write per-job status here (empty disables)")
+	healthAddr := fs.String("health-addr", "", "serve /healthz and /readyz probes on this address (e.g. :8080)")
+	stuckAfter := fs.Duration("stuck-after", 6*time.Hour, "report a job without a timeout as stuck in /healthz after it runs this long (0 disables)")
+	queuePath := fs.String("queue", enrich.DefaultQueuePath, "deferred enrichment queue whose depth the health probes report")
+	var logOpts logging.Options
+	logging.RegisterFlags(fs, &logOpts)
+	fs.Parse(args)
+
+	logger, err := logging.Setup(logOpts)
+	if err != nil {
+		return err
+	}
+
+	var jobs []scheduler.Job
+	if *configPath != "" {
+		if jobs, err = scheduler.LoadJobs(*configPath); err != nil {
+			return err
+		}
+	}
+	if *schedule != "" {
+		if fs.NArg() == 0 {
+			return errors.New("--schedule needs a command after the flags, e.g. serve --schedule \"0 22 * * 1-5\" -- go run ./get_companies")
+		}
+		jobs = append(jobs, scheduler.Job{Name: *name, Schedule: *schedule, Command: fs.Args(), Timeout: *timeout})
+	}
+
+	s, err := scheduler.New(jobs)
+	if err != nil {
+		return err
+	}
+	s.Logger = logger
+	s.StatusFile = *statusFile
+	s.StuckAfter = *stuckAfter
+	if *queuePath != "" {
+		s.QueueDepth = enrich.NewQueue(*queuePath).Len
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *healthAddr != "" {
+		server := &http.Server{Addr: *healthAddr, Handler: s.HealthHandler(), ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("health listener stopped", "addr", *healthAddr, "error", err)
+			}
+		}()
+		defer server.Close()
+		logger.Info("serving health probes", "addr", *healthAddr, "paths", "/healthz,/readyz")
+	}
+
+	for _, status := range s.Statuses() {
+		logger.Info("job scheduled", "job", status.Name, "schedule", status.Schedule)
+	}
+
+	if err := s.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	logger.Info("scheduler stopped")
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"algotradar/backtest/engine"
+)
+
+func runUniverse(args []string) error {
+	fs := flag.NewFlagSet("universe", flag.ExitOnError)
+	archiveDir := fs.String("archive", ".", "directory containing dated snapshot files")
+	prefix := fs.String("prefix", "global_assets_fmp_", "snapshot file name prefix")
+	asOf := fs.String("as-of", "", "date to reconstruct the universe for, YYYY-MM-DD (required)")
+	minCap := fs.Float64("min-market-cap", 0, "minimum market cap in USD, e.g. 40e9")
+	countries := fs.String("country", "", "comma-separated ISO country codes to keep")
+	exchanges := fs.String("exchange", "", "comma-separated primary exchanges to keep")
+	types := fs.String("type", "", "comma-separated asset types to keep, e.g. stock")
+	minListingDays := fs.Int("min-listing-days", 0, "drop names listed fewer than this many days before the snapshot, e.g. 182 for recent IPOs")
+	delistedPath := fs.String("delisted", "", "delisted companies from datacollect delisted, to flag members that later disappeared")
+	format := fs.String("format", "text", "output format: text, csv or json")
+	fs.Parse(args)
+
+	if *asOf == "" {
+		return errors.New("--as-of is required")
+	}
+	date, err := time.Parse("2006-01-02", *asOf)
+	if err != nil {
+		return fmt.Errorf("invalid --as-of: %w", err)
+	}
+
+	snapshots, err := engine.LoadArchive(*archiveDir, *prefix)
+	if err != nil {
+		return err
+	}
+	filter := engine.UniverseFilter{
+		MinMarketCap:   *minCap,
+		Countries:      splitList(*countries),
+		Exchanges:      splitList(*exchanges),
+		AssetTypes:     splitList(*types),
+		MinListingDays: *minListingDays,
+	}
+	store := engine.NewUniverseStore(snapshots)
+	if *delistedPath != "" {
+		delistings, err := engine.LoadDelisted(*delistedPath)
+		if err != nil {
+			return err
+		}
+		store.AddDelisted(delistings)
+	}
+	members, snapshotDate, err := store.Universe(date, filter)
+	if err != nil {
+		return err
+	}
+	delisted := func(ticker string) string {
+		if d, ok := store.Delisting(ticker, date); ok {
+			return d.Format("2006-01-02")
+		}
+		return ""
+	}
+
+	switch *format {
+	case "json":
+		type member struct {
+			Ticker          string  `json:"ticker"`
+			Name            string  `json:"name"`
+			MarketCap       float64 `json:"market_cap"`
+			CurrentPrice    float64 `json:"current_price"`
+			Country         string  `json:"country"`
+			PrimaryExchange string  `json:"primary_exchange"`
+			AssetType       string  `json:"asset_type"`
+			Delisted        string  `json:"delisted_date,omitempty"`
+		}
+		rows := make([]member, len(members))
+		for i, a := range members {
+			rows[i] = member{a.Ticker, a.Name, a.MarketCap, a.CurrentPrice, a.Country, a.PrimaryExchange, a.AssetType, delisted(a.Ticker)}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(struct {
+			AsOf     string   `json:"as_of"`
+			Snapshot string   `json:"snapshot_date"`
+			Count    int      `json:"count"`
+			Members  []member `json:"members"`
+		}{*asOf, snapshotDate.Format("2006-01-02"), len(rows), rows})
+
+	case "csv":
+		writer := csv.NewWriter(os.Stdout)
+		writer.Write([]string{"ticker", "name", "market_cap", "current_price", "country", "primary_exchange", "asset_type", "delisted_date"})
+		for _, a := range members {
+			writer.Write([]string{
+				a.Ticker, a.Name, strconv.FormatFloat(a.MarketCap, 'f', -1, 64),
+				strconv.FormatFloat(a.CurrentPrice, 'f', -1, 64), a.Country, a.PrimaryExchange, a.AssetType, delisted(a.Ticker),
+			})
+		}
+		writer.Flush()
+		return writer.Error()
+
+	case "text":
+		fmt.Printf("Universe as of %s (snapshot %s): %d members\n\n", *asOf, snapshotDate.Format("2006-01-02"), len(members))
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "TICKER\tNAME\tMARKET CAP\tCOUNTRY\tEXCHANGE\tDELISTED")
+		for _, a := range members {
+			fmt.Fprintf(tw, "%s\t%s\t%.0f\t%s\t%s\t%s\n", a.Ticker, a.Name, a.MarketCap, a.Country, a.PrimaryExchange, delisted(a.Ticker))
+		}
+		return tw.Flush()
+	}
+
+	return fmt.Errorf("unknown format %q", *format)
+}
+
+// splitList parses a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
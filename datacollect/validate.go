@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"algotradar/output"
+	"algotradar/schema"
+)
+
+func runValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	name := fs.String("schema", "", "schema to check against: "+strings.Join(schema.Names(), " or ")+"; detected from each file when empty")
+	printSchema := fs.Bool("print", false, "print the JSON Schema named by --schema instead of checking files")
+	fs.Parse(args)
+
+	if *printSchema {
+		doc, err := schema.Document(*name)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(doc)
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("no files given")
+	}
+
+	failed := 0
+	for _, path := range fs.Args() {
+		if err := validateFile(path, *name); err != nil {
+			fmt.Printf("FAIL %s: %v\n", path, err)
+			failed++
+			continue
+		}
+		fmt.Printf("OK   %s\n", path)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed validation", failed, fs.NArg())
+	}
+	return nil
+}
+
+// validateFile checks a JSON output file against the named schema, or the one
+// its rows look like when name is empty
+func validateFile(path, name string) error {
+	data, err := output.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		if name, err = schema.Detect(data); err != nil {
+			return err
+		}
+	}
+	return schema.Validate(name, data)
+}
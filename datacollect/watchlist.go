@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"algotradar/watchlist"
+)
+
+func runWatchlist(args []string) error {
+	fs := flag.NewFlagSet("watchlist", flag.ExitOnError)
+	storeSpec := fs.String("store", watchlist.DefaultPath, "where watchlists are kept: a JSON file (PATH or json:PATH) or sqlite:PATH")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: datacollect watchlist [flags] add NAME SYMBOL...\n"+
+			"       datacollect watchlist [flags] remove NAME [SYMBOL...]\n"+
+			"       datacollect watchlist [flags] list [NAME]\n\n"+
+			"remove without symbols deletes the watchlist. Symbols may also be comma-separated.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("want add, remove or list")
+	}
+	store, err := watchlist.Open(*storeSpec)
+	if err != nil {
+		return err
+	}
+	action, rest := fs.Arg(0), fs.Args()[1:]
+	var symbols []string
+	if len(rest) > 1 {
+		symbols = splitList(strings.Join(rest[1:], ","))
+	}
+
+	switch action {
+	case "add":
+		if len(rest) < 2 {
+			fs.Usage()
+			return errors.New("want a watchlist name and symbols to add")
+		}
+		updated, err := watchlist.Add(store, rest[0], symbols)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: %d symbols\n", rest[0], len(updated))
+
+	case "remove":
+		if len(rest) < 1 {
+			fs.Usage()
+			return errors.New("want a watchlist name")
+		}
+		kept, err := watchlist.Remove(store, rest[0], symbols)
+		if err != nil {
+			return err
+		}
+		if len(kept) == 0 {
+			fmt.Printf("%s: deleted\n", rest[0])
+		} else {
+			fmt.Printf("%s: %d symbols\n", rest[0], len(kept))
+		}
+
+	case "list":
+		if len(rest) > 1 {
+			fs.Usage()
+			return errors.New("want at most one watchlist name")
+		}
+		// One watchlist prints a symbol per line, for scripts
+		if len(rest) == 1 {
+			list, err := store.Get(rest[0])
+			if err != nil {
+				return err
+			}
+			for _, symbol := range list {
+				fmt.Println(symbol)
+			}
+			return nil
+		}
+		lists, err := store.All()
+		if err != nil {
+			return err
+		}
+		if len(lists) == 0 {
+			fmt.Fprintf(os.Stderr, "no watchlists in %s\n", store)
+			return nil
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tCOUNT\tSYMBOLS")
+		for _, name := range watchlist.Names(lists) {
+			fmt.Fprintf(tw, "%s\t%d\t%s\n", name, len(lists[name]), strings.Join(lists[name], ", "))
+		}
+		return tw.Flush()
+
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown watchlist action %q, want add, remove or list", action)
+	}
+	return nil
+}
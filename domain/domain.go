@@ -0,0 +1,804 @@
+// Package domain defines the canonical data types shared by the collectors,
+// the output sinks, the serve/API modes and the backtest engine.
+//
+// JSON tags follow the snapshot files the collectors publish; db tags follow
+// the columns of the public.assets table.
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Asset is one ranked row of a collected snapshot
+type Asset struct {
+	Ticker           string  `json:"ticker" db:"ticker"`
+	Name             string  `json:"name" db:"name"`
+	MarketCap        float64 `json:"market_cap" db:"market_cap"` // USD
+	CurrentPrice     float64 `json:"current_price" db:"current_price"`
+	PreviousClose    float64 `json:"previous_close" db:"previous_close"`
+	PercentageChange float64 `json:"percentage_change" db:"percentage_change"`
+	Volume           float64 `json:"volume" db:"volume"`
+	PrimaryExchange  string  `json:"primary_exchange" db:"primary_exchange"`
+	Country          string  `json:"country" db:"country"`
+	Sector           string  `json:"sector" db:"sector"`
+	Industry         string  `json:"industry" db:"industry"`
+	AssetType        string  `json:"asset_type" db:"asset_type"`
+	Image            string  `json:"image" db:"image"`
+
+	// InstrumentType is the security type the provider's metadata gives the
+	// listing, one of the instrument package's types (stock, etf, fund,
+	// trust, reit, spac, preferred, warrant, unit, right)
+	InstrumentType string `json:"instrument_type,omitempty" db:"instrument_type"`
+
+	// ShareClass is the share class or preferred series the symbol's suffix
+	// names, e.g. "B" for BRK-B; empty for a company's only class
+	ShareClass string `json:"share_class,omitempty" db:"share_class"`
+
+	// IsADR marks a depositary receipt; PrimarySymbol is the issuer's primary
+	// listing, the row's own ticker unless it trades elsewhere
+	IsADR         bool   `json:"is_adr" db:"is_adr"`
+	PrimarySymbol string `json:"primary_symbol" db:"primary_symbol"`
+
+	// Optional fields only some collectors fill
+	ISIN          string   `json:"isin,omitempty" db:"isin"`
+	Currency      string   `json:"currency,omitempty" db:"currency"`
+	DividendYield *float64 `json:"dividend_yield,omitempty" db:"dividend_yield"`
+	AvgVolume     float64  `json:"avg_volume,omitempty" db:"avg_volume"`
+	Beta          float64  `json:"beta,omitempty" db:"beta"`
+	PE            float64  `json:"pe,omitempty" db:"pe"`
+	EPS           float64  `json:"eps,omitempty" db:"eps"`
+
+	// SectorNormalized and IndustryNormalized place Sector and Industry in
+	// the GICS-like taxonomy of package taxonomy; empty when unmapped
+	SectorNormalized   string `json:"sector_normalized,omitempty" db:"sector_normalized"`
+	IndustryNormalized string `json:"industry_normalized,omitempty" db:"industry_normalized"`
+
+	// CapTier is the size band of MarketCap, e.g. CapLarge (see SetCapTier)
+	CapTier string `json:"cap_tier,omitempty" db:"cap_tier"`
+
+	// MIC and ExchangeTimezone identify the listing venue (ISO 10383 and
+	// IANA); IsMarketOpen records whether its regular session was running at
+	// snapshot time, nil when the venue's hours are unknown
+	MIC              string `json:"mic,omitempty" db:"mic"`
+	ExchangeTimezone string `json:"exchange_timezone,omitempty" db:"exchange_timezone"`
+	IsMarketOpen     *bool  `json:"is_market_open,omitempty" db:"is_market_open"`
+
+	// QuoteTime is when the row's quote was taken and QuoteSession where that
+	// falls in the venue's trading day (see refdata.Session*), so consumers
+	// can tell live intraday prices from stale ones; both empty when the
+	// provider gives no quote time
+	QuoteSession string     `json:"quote_session,omitempty" db:"quote_session"`
+	QuoteTime    *time.Time `json:"quote_timestamp_utc,omitempty" db:"quote_timestamp_utc"`
+
+	// SnapshotDate is the trading day the row belongs to, in its venue's
+	// timezone (see refdata.Date), and CollectedAt when the collector took
+	// the snapshot, in UTC
+	SnapshotDate string     `json:"snapshot_date,omitempty" db:"snapshot_date"`
+	CollectedAt  *time.Time `json:"collected_at,omitempty" db:"collected_at"`
+
+	// DataSource names the provider that supplied the row's quote or
+	// currency when it is not FMP, e.g. "polygon" or a "yahoo" fallback
+	DataSource string `json:"data_source,omitempty" db:"data_source"`
+
+	// SharesOutstanding is the issuer's share count and FloatShares the part
+	// of it that trades freely; FreeFloatMarketCap is MarketCap scaled by
+	// their ratio, the weight of float-adjusted indices, and zero when the
+	// float is unknown
+	SharesOutstanding  float64 `json:"shares_outstanding,omitempty" db:"shares_outstanding"`
+	FloatShares        float64 `json:"float_shares,omitempty" db:"float_shares"`
+	FreeFloatMarketCap float64 `json:"free_float_market_cap,omitempty" db:"free_float_market_cap"` // USD
+
+	// Technicals, filled by the technicals enrichment: the 52-week range and
+	// the 50 and 200-day moving averages in the listing currency, like
+	// CurrentPrice, and the year-to-date price change in percent
+	YearHigh    float64  `json:"year_high,omitempty" db:"year_high"`
+	YearLow     float64  `json:"year_low,omitempty" db:"year_low"`
+	PriceAvg50  float64  `json:"price_avg_50,omitempty" db:"price_avg_50"`
+	PriceAvg200 float64  `json:"price_avg_200,omitempty" db:"price_avg_200"`
+	YTDReturn   *float64 `json:"ytd_return,omitempty" db:"ytd_return"`
+
+	// Institutional ownership, filled by the ownership enrichment from 13F
+	// holdings: the share of SharesOutstanding held by institutions and by
+	// the ten largest of them, in percent, and how many institutions report
+	// a position
+	InstitutionalOwnership float64 `json:"institutional_ownership_pct,omitempty" db:"institutional_ownership_pct"`
+	Top10HolderPct         float64 `json:"top10_holder_pct,omitempty" db:"top10_holder_pct"`
+	InstitutionalHolders   int     `json:"institutional_holders,omitempty" db:"institutional_holders"`
+
+	// ListingDate is when the listing started trading, YYYY-MM-DD, empty when
+	// unknown; IsRecentIPO marks listings younger than the collector's
+	// recent-IPO window at snapshot time, which have little price history
+	ListingDate string `json:"listing_date,omitempty" db:"listing_date"`
+	IsRecentIPO bool   `json:"is_recent_ipo,omitempty" db:"is_recent_ipo"`
+
+	// ExtendedHoursPrice is the last pre- or post-market trade taken after
+	// the row's quote, and ExtendedHoursChange its change from CurrentPrice
+	// in percent; both zero when there is none
+	ExtendedHoursPrice  float64 `json:"extended_hours_price,omitempty" db:"extended_hours_price"`
+	ExtendedHoursChange float64 `json:"extended_hours_change,omitempty" db:"extended_hours_change"`
+
+	// FFO is a REIT's funds from operations over the trailing four quarters,
+	// in FFOCurrency, and PriceToFFO its market value over that; filled by
+	// the reit enrichment, zero when the statements are unavailable.
+	// PriceToFFO is zero as well when FFO is not positive or is reported in
+	// another currency than the listing's.
+	FFO         float64 `json:"ffo_ttm,omitempty" db:"ffo_ttm"`
+	FFOCurrency string  `json:"ffo_currency,omitempty" db:"ffo_currency"`
+	PriceToFFO  float64 `json:"price_to_ffo,omitempty" db:"price_to_ffo"`
+
+	// LocalCurrency is the currency a non-USD row is quoted in, and
+	// FXRateUsed the USD value of one unit of it that MarketCap was converted
+	// at. FXRateSource says where the rate came from (fx.SourceAPI,
+	// fx.SourceFallback or fx.SourceStatic) and FXRateTime when it was
+	// fetched, nil for static rates. All are empty on USD rows.
+	LocalCurrency string     `json:"local_currency,omitempty" db:"local_currency"`
+	FXRateUsed    float64    `json:"fx_rate_used,omitempty" db:"fx_rate_used"`
+	FXRateSource  string     `json:"fx_rate_source,omitempty" db:"fx_rate_source"`
+	FXRateTime    *time.Time `json:"fx_rate_timestamp,omitempty" db:"fx_rate_timestamp"`
+
+	// ShareClasses lists the issuer's share classes when the collector
+	// folded them into this row, whose MarketCap is then the issuer's; see
+	// AggregateShareClasses
+	ShareClasses []ShareClassListing `json:"share_classes,omitempty" db:"share_classes"`
+
+	// Filled marks rows synthesized by a gap policy rather than read from disk
+	Filled bool `json:"-" db:"-"`
+}
+
+// Quote is a price quote as returned by the FMP quote endpoint
+type Quote struct {
+	Symbol            string  `json:"symbol" db:"symbol"`
+	Name              string  `json:"name" db:"name"`
+	Price             float64 `json:"price" db:"price"`
+	Change            float64 `json:"change" db:"change"`
+	ChangesPercentage float64 `json:"changesPercentage" db:"changes_percentage"`
+	Open              float64 `json:"open" db:"open"`
+	PreviousClose     float64 `json:"previousClose" db:"previous_close"`
+	MarketCap         float64 `json:"marketCap" db:"market_cap"`
+	Volume            float64 `json:"volume" db:"volume"`
+	AvgVolume         float64 `json:"avgVolume" db:"avg_volume"`
+	SharesOutstanding float64 `json:"sharesOutstanding" db:"shares_outstanding"`
+	PE                float64 `json:"pe" db:"pe"`
+	EPS               float64 `json:"eps" db:"eps"`
+	Beta              float64 `json:"beta" db:"beta"`
+	DividendYield     float64 `json:"dividendYield" db:"dividend_yield"`
+	Exchange          string  `json:"exchange" db:"exchange"`
+
+	// The 52-week range and the 50 and 200-day moving averages
+	YearHigh    float64 `json:"yearHigh,omitempty" db:"year_high"`
+	YearLow     float64 `json:"yearLow,omitempty" db:"year_low"`
+	PriceAvg50  float64 `json:"priceAvg50,omitempty" db:"price_avg_50"`
+	PriceAvg200 float64 `json:"priceAvg200,omitempty" db:"price_avg_200"`
+
+	// Currency is the quote currency, e.g. "GBp"; FMP quotes leave it empty
+	Currency string `json:"currency,omitempty" db:"currency"`
+
+	// Timestamp is when the quote was taken, in Unix seconds; 0 when the
+	// provider does not say
+	Timestamp int64 `json:"timestamp,omitempty" db:"timestamp"`
+}
+
+// IntradayTick is a quote the intraday poller saw change price
+type IntradayTick struct {
+	Symbol string  `json:"symbol" db:"symbol"`
+	Time   string  `json:"time" db:"time"` // RFC 3339 UTC: when the quote was taken, else when it was polled
+	Price  float64 `json:"price" db:"price"`
+	Change float64 `json:"change_pct" db:"change_pct"` // since the previous close
+	Volume float64 `json:"volume,omitempty" db:"volume"`
+}
+
+// Profile is company reference data as returned by the FMP profile endpoint
+type Profile struct {
+	Symbol      string  `json:"symbol" db:"symbol"`
+	CompanyName string  `json:"companyName" db:"company_name"`
+	Currency    string  `json:"currency" db:"currency"`
+	Country     string  `json:"country" db:"country"`
+	Sector      string  `json:"sector" db:"sector"`
+	Industry    string  `json:"industry" db:"industry"`
+	Exchange    string  `json:"exchange" db:"exchange"`
+	Image       string  `json:"image" db:"image"`
+	Price       float64 `json:"price" db:"price"`
+	Beta        float64 `json:"beta" db:"beta"`
+	VolAvg      float64 `json:"volAvg" db:"vol_avg"`
+	MktCap      float64 `json:"mktCap" db:"market_cap"`
+	Website     string  `json:"website" db:"website"`
+	Description string  `json:"description" db:"description"`
+	IsADR       bool    `json:"isAdr" db:"is_adr"`
+	IsEtf       bool    `json:"isEtf" db:"is_etf"`
+	IsFund      bool    `json:"isFund" db:"is_fund"`
+	IPODate     string  `json:"ipoDate" db:"ipo_date"` // YYYY-MM-DD
+
+	// Issuer identifiers; FIGI is not reported by FMP and only set when
+	// filled from another source
+	ISIN  string `json:"isin" db:"isin"`
+	CIK   string `json:"cik" db:"cik"`
+	CUSIP string `json:"cusip" db:"cusip"`
+	FIGI  string `json:"figi,omitempty" db:"figi"`
+
+	// ExchangeShortName is the venue code the screener reports, e.g. NASDAQ
+	// where Exchange is NASDAQ Global Select; empty from providers without one
+	ExchangeShortName string `json:"exchangeShortName,omitempty" db:"exchange_short_name"`
+}
+
+// PriceChange is a symbol's price change over several periods, in percent,
+// as returned by the FMP stock-price-change endpoint
+type PriceChange struct {
+	Symbol string  `json:"symbol" db:"symbol"`
+	YTD    float64 `json:"ytd" db:"ytd"`
+}
+
+// SetTechnicals copies the quote's 52-week range and moving averages to the
+// asset
+func (a *Asset) SetTechnicals(q *Quote) {
+	a.YearHigh = q.YearHigh
+	a.YearLow = q.YearLow
+	a.PriceAvg50 = q.PriceAvg50
+	a.PriceAvg200 = q.PriceAvg200
+}
+
+// CashFlowStatement is the part of a quarterly cash flow statement, as
+// returned by the FMP cash-flow-statement endpoint, that FFO is built from
+type CashFlowStatement struct {
+	Symbol                      string  `json:"symbol" db:"symbol"`
+	Date                        string  `json:"date" db:"date"`
+	ReportedCurrency            string  `json:"reportedCurrency" db:"reported_currency"`
+	NetIncome                   float64 `json:"netIncome" db:"net_income"`
+	DepreciationAndAmortization float64 `json:"depreciationAndAmortization" db:"depreciation_and_amortization"`
+}
+
+// FFO is a REIT's funds from operations over several quarters
+type FFO struct {
+	Symbol   string
+	Amount   float64
+	Currency string
+}
+
+// TrailingFFO sums the FFO of the latest n statements: net income plus
+// depreciation and amortization, the NAREIT definition short of the gains
+// on property sales, which FMP's statements do not break out. It is zero
+// when fewer than n statements are given or they mix currencies.
+func TrailingFFO(symbol string, statements []CashFlowStatement, n int) FFO {
+	if len(statements) < n {
+		return FFO{Symbol: symbol}
+	}
+	sort.Slice(statements, func(i, j int) bool { return statements[i].Date > statements[j].Date })
+	ffo := FFO{Symbol: symbol, Currency: statements[0].ReportedCurrency}
+	for _, s := range statements[:n] {
+		if s.ReportedCurrency != ffo.Currency {
+			return FFO{Symbol: symbol}
+		}
+		ffo.Amount += s.NetIncome + s.DepreciationAndAmortization
+	}
+	return ffo
+}
+
+// SetFFO records f on the asset, with the price/FFO multiple when f is in
+// the listing's currency; marketCap is the asset's market value in that
+// currency
+func (a *Asset) SetFFO(f FFO, currency string, marketCap float64) {
+	if f.Amount == 0 {
+		return
+	}
+	a.FFO = f.Amount
+	a.FFOCurrency = f.Currency
+	if f.Amount > 0 && marketCap > 0 && strings.EqualFold(f.Currency, currency) {
+		a.PriceToFFO = marketCap / f.Amount
+	}
+}
+
+// FXRate is the USD value of one unit of Currency, as a collector converted
+// a row's market cap at it
+type FXRate struct {
+	Currency string
+	Rate     float64
+	Source   string
+	Time     time.Time // when the rate was fetched; zero for static rates
+}
+
+// SetFX records the rate a non-USD row was converted at, so the conversion
+// can be audited and redone; USD rows are left without one
+func (a *Asset) SetFX(r FXRate) {
+	if r.Currency == "" || r.Currency == "USD" {
+		return
+	}
+	a.LocalCurrency = r.Currency
+	a.FXRateUsed = r.Rate
+	a.FXRateSource = r.Source
+	a.FXRateTime = nil
+	if !r.Time.IsZero() {
+		t := r.Time.UTC()
+		a.FXRateTime = &t
+	}
+}
+
+// ExtendedHoursTrade is the last trade of a symbol outside regular hours, as
+// returned by the FMP pre-post-market-trade endpoints
+type ExtendedHoursTrade struct {
+	Symbol    string  `json:"symbol" db:"symbol"`
+	Price     float64 `json:"price" db:"price"`
+	Size      float64 `json:"size" db:"size"`
+	Timestamp int64   `json:"timestamp" db:"timestamp"` // Unix milliseconds
+}
+
+// SetExtendedHours records t as the asset's extended-hours price when it is
+// newer than the asset's quote, so a pre-market trade does not stand in for
+// the regular session that followed it. CurrentPrice must already be set.
+func (a *Asset) SetExtendedHours(t ExtendedHoursTrade) {
+	if t.Price <= 0 || a.CurrentPrice <= 0 {
+		return
+	}
+	if a.QuoteTime != nil && !time.UnixMilli(t.Timestamp).After(*a.QuoteTime) {
+		return
+	}
+	a.ExtendedHoursPrice = t.Price
+	a.ExtendedHoursChange = (t.Price - a.CurrentPrice) / a.CurrentPrice * 100
+}
+
+// SetOwnership copies the concentration metrics of o to the asset
+func (a *Asset) SetOwnership(o Ownership) {
+	a.InstitutionalOwnership = o.InstitutionalPct
+	a.Top10HolderPct = o.Top10Pct
+	a.InstitutionalHolders = o.Holders
+}
+
+// RecentIPODays is how many days after listing a row counts as a recent IPO
+// by default, about six months
+const RecentIPODays = 182
+
+// SetListing records the date a listing started trading, YYYY-MM-DD, and
+// flags it a recent IPO when it is less than recentDays old at asOf. An
+// unparseable date is left out.
+func (a *Asset) SetListing(date string, asOf time.Time, recentDays int) {
+	listed, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return
+	}
+	a.ListingDate = date
+	a.IsRecentIPO = asOf.Sub(listed) < time.Duration(recentDays)*24*time.Hour
+}
+
+// SharesFloat is a company's share count as returned by the FMP shares-float
+// endpoint; FreeFloat is the floating share of outstanding shares in percent
+type SharesFloat struct {
+	Symbol            string  `json:"symbol" db:"symbol"`
+	Date              string  `json:"date" db:"date"`
+	FreeFloat         float64 `json:"freeFloat" db:"free_float"`
+	FloatShares       float64 `json:"floatShares" db:"float_shares"`
+	OutstandingShares float64 `json:"outstandingShares" db:"outstanding_shares"`
+	Source            string  `json:"source" db:"source"`
+}
+
+// SetShares records an asset's share counts and derives its free-float
+// market cap from MarketCap, which must already be set. A float above the
+// outstanding count, as filings dated apart can report, counts as fully
+// floating.
+func (a *Asset) SetShares(outstanding, float float64) {
+	a.SharesOutstanding = outstanding
+	a.FloatShares = float
+	a.FreeFloatMarketCap = 0
+	if outstanding > 0 && float > 0 {
+		a.FreeFloatMarketCap = a.MarketCap * min(float/outstanding, 1)
+	}
+}
+
+// Market cap tiers, largest first
+const (
+	CapMega  = "mega"
+	CapLarge = "large"
+	CapMid   = "mid"
+	CapSmall = "small"
+	CapMicro = "micro"
+)
+
+// CapTiers are the USD market caps a company must exceed to reach each tier;
+// smaller companies are CapMicro
+type CapTiers struct {
+	Mega, Large, Mid, Small float64
+}
+
+// DefaultCapTiers are the conventional boundaries: mega above $200B, large
+// above $10B, mid above $2B and small above $300M
+var DefaultCapTiers = CapTiers{Mega: 200e9, Large: 10e9, Mid: 2e9, Small: 300e6}
+
+// ParseCapTiers parses boundaries such as "mega=300B,small=250M" over
+// DefaultCapTiers. Amounts are US dollars, optionally with a K, M, B or T
+// suffix, and must fall from mega to small.
+func ParseCapTiers(spec string) (CapTiers, error) {
+	tiers := DefaultCapTiers
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, raw, ok := strings.Cut(pair, "=")
+		if !ok {
+			return tiers, fmt.Errorf("invalid cap tier %q, want TIER=USD", pair)
+		}
+		amount, err := parseUSD(raw)
+		if err != nil || amount <= 0 {
+			return tiers, fmt.Errorf("cap tier %s must be a positive US dollar amount, got %q", name, raw)
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case CapMega:
+			tiers.Mega = amount
+		case CapLarge:
+			tiers.Large = amount
+		case CapMid:
+			tiers.Mid = amount
+		case CapSmall:
+			tiers.Small = amount
+		default:
+			return tiers, fmt.Errorf("unknown cap tier %q, want mega, large, mid or small", name)
+		}
+	}
+	if !(tiers.Mega > tiers.Large && tiers.Large > tiers.Mid && tiers.Mid > tiers.Small) {
+		return tiers, fmt.Errorf("cap tiers must fall from mega to small, got %s", tiers)
+	}
+	return tiers, nil
+}
+
+// parseUSD parses an amount such as 300e6, 300M or 0.3B
+func parseUSD(s string) (float64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	scale := 1.0
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'K':
+			scale = 1e3
+		case 'M':
+			scale = 1e6
+		case 'B':
+			scale = 1e9
+		case 'T':
+			scale = 1e12
+		}
+		if scale != 1 {
+			s = s[:n-1]
+		}
+	}
+	amount, err := strconv.ParseFloat(s, 64)
+	return amount * scale, err
+}
+
+// String renders the boundaries in the form accepted by ParseCapTiers
+func (t CapTiers) String() string {
+	return fmt.Sprintf("mega=%s,large=%s,mid=%s,small=%s", formatUSD(t.Mega), formatUSD(t.Large), formatUSD(t.Mid), formatUSD(t.Small))
+}
+
+// formatUSD renders an amount with the largest suffix parseUSD reads that
+// it reaches, e.g. 300M for 300e6
+func formatUSD(amount float64) string {
+	for _, unit := range []struct {
+		scale  float64
+		suffix string
+	}{{1e12, "T"}, {1e9, "B"}, {1e6, "M"}, {1e3, "K"}} {
+		if amount >= unit.scale {
+			return strconv.FormatFloat(amount/unit.scale, 'f', -1, 64) + unit.suffix
+		}
+	}
+	return strconv.FormatFloat(amount, 'f', -1, 64)
+}
+
+// Tier returns the tier of a USD market cap
+func (t CapTiers) Tier(marketCap float64) string {
+	switch {
+	case marketCap > t.Mega:
+		return CapMega
+	case marketCap > t.Large:
+		return CapLarge
+	case marketCap > t.Mid:
+		return CapMid
+	case marketCap > t.Small:
+		return CapSmall
+	}
+	return CapMicro
+}
+
+// SetCapTier records the tier of the asset's MarketCap, which must be final:
+// an aggregated issuer row is tiered by its classes' combined market cap.
+// Zero CapTiers mean DefaultCapTiers.
+func (a *Asset) SetCapTier(t CapTiers) {
+	if t == (CapTiers{}) {
+		t = DefaultCapTiers
+	}
+	a.CapTier = t.Tier(a.MarketCap)
+}
+
+// ShareClassListing is one share class of an aggregated issuer row, with
+// the class's own quote and USD market cap
+type ShareClassListing struct {
+	Ticker       string  `json:"ticker" db:"ticker"`
+	ShareClass   string  `json:"share_class,omitempty" db:"share_class"`
+	CurrentPrice float64 `json:"current_price" db:"current_price"`
+	MarketCap    float64 `json:"market_cap" db:"market_cap"`
+	Volume       float64 `json:"volume" db:"volume"`
+}
+
+// issuerCapTolerance is how far apart the classes' market caps may be and
+// still be taken for the issuer's total reported on every class
+const issuerCapTolerance = 0.005
+
+// AggregateShareClasses folds the rows of one issuer's share classes into
+// one issuer row: the row of primary, or the largest when primary has none,
+// listing every class in ShareClasses with MarketCap their sum. Providers
+// such as FMP report the issuer's total on each class of GOOG and GOOGL,
+// which shows as classes with equal caps; that total is then taken once.
+func AggregateShareClasses(rows []Asset, primary string) Asset {
+	classes := append([]Asset(nil), rows...)
+	SortAssets(classes)
+	if len(classes) < 2 {
+		if len(classes) == 0 {
+			return Asset{}
+		}
+		return classes[0]
+	}
+
+	issuer := classes[0]
+	listings := make([]ShareClassListing, len(classes))
+	var total float64
+	for i, class := range classes {
+		if class.Ticker == primary {
+			issuer = class
+		}
+		total += class.MarketCap
+		listings[i] = ShareClassListing{
+			Ticker:       class.Ticker,
+			ShareClass:   class.ShareClass,
+			CurrentPrice: class.CurrentPrice,
+			MarketCap:    class.MarketCap,
+			Volume:       class.Volume,
+		}
+	}
+	issuer.ShareClasses = listings
+	issuer.MarketCap = total
+	if largest, smallest := classes[0].MarketCap, classes[len(classes)-1].MarketCap; largest > 0 && (largest-smallest)/largest <= issuerCapTolerance {
+		issuer.MarketCap = largest
+	}
+	issuer.SetShares(issuer.SharesOutstanding, issuer.FloatShares)
+	return issuer
+}
+
+// DelistedCompany is a listing that stopped trading, as returned by the FMP
+// delisted-companies endpoint; dates are YYYY-MM-DD
+type DelistedCompany struct {
+	Symbol       string `json:"symbol" db:"symbol"`
+	CompanyName  string `json:"companyName" db:"company_name"`
+	Exchange     string `json:"exchange" db:"exchange"`
+	IPODate      string `json:"ipoDate" db:"ipo_date"`
+	DelistedDate string `json:"delistedDate" db:"delisted_date"`
+}
+
+// InstitutionalHolder is one institution's position in a symbol as of its
+// latest 13F filing, as returned by the FMP institutional-holder endpoint
+type InstitutionalHolder struct {
+	Holder       string  `json:"holder"`
+	Shares       float64 `json:"shares"`
+	DateReported string  `json:"dateReported"` // YYYY-MM-DD
+	Change       float64 `json:"change"`       // shares bought (negative: sold) since the previous filing
+}
+
+// TopHolders is how many of the largest holders count towards
+// Ownership.Top10Pct and are listed in Ownership.TopHolders
+const TopHolders = 10
+
+// Ownership is the institutional ownership of one symbol on one date,
+// summarised from the 13F holdings of every reporting institution
+type Ownership struct {
+	Symbol              string                `json:"symbol" db:"symbol"`
+	Date                string                `json:"date" db:"date"`               // YYYY-MM-DD
+	ReportDate          string                `json:"report_date" db:"report_date"` // latest 13F report among the holders
+	Holders             int                   `json:"holders" db:"holders"`
+	InstitutionalShares float64               `json:"institutional_shares" db:"institutional_shares"`
+	SharesOutstanding   float64               `json:"shares_outstanding,omitempty" db:"shares_outstanding"`
+	InstitutionalPct    float64               `json:"institutional_ownership_pct,omitempty" db:"institutional_ownership_pct"`
+	Top10Pct            float64               `json:"top10_holder_pct,omitempty" db:"top10_holder_pct"`
+	TopHolders          []InstitutionalHolder `json:"top_holders,omitempty" db:"-"`
+}
+
+// NewOwnership summarises the holders of symbol. The percentages need the
+// outstanding share count and stay zero without it; since filings dated
+// apart can add up to more than the company has issued, they are capped at
+// 100.
+func NewOwnership(symbol, date string, holders []InstitutionalHolder, outstanding float64) Ownership {
+	o := Ownership{Symbol: symbol, Date: date, SharesOutstanding: outstanding}
+	sorted := make([]InstitutionalHolder, 0, len(holders))
+	for _, h := range holders {
+		if h.Shares > 0 {
+			sorted = append(sorted, h)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Shares > sorted[j].Shares })
+
+	var top float64
+	for i, h := range sorted {
+		o.InstitutionalShares += h.Shares
+		if i < TopHolders {
+			top += h.Shares
+		}
+		o.ReportDate = max(o.ReportDate, h.DateReported)
+	}
+	o.Holders = len(sorted)
+	o.TopHolders = sorted[:min(len(sorted), TopHolders)]
+	if outstanding > 0 {
+		o.InstitutionalPct = min(o.InstitutionalShares/outstanding*100, 100)
+		o.Top10Pct = min(top/outstanding*100, 100)
+	}
+	return o
+}
+
+// Filing is the metadata of one SEC filing, as listed by the FMP
+// sec_filings endpoint
+type Filing struct {
+	Symbol   string `json:"symbol" db:"symbol"`
+	CIK      string `json:"cik,omitempty" db:"cik"`
+	Type     string `json:"type" db:"type"`                   // e.g. 10-K, 10-Q or 8-K
+	Filed    string `json:"filed" db:"filed"`                 // YYYY-MM-DD
+	Accepted string `json:"accepted,omitempty" db:"accepted"` // RFC 3339, UTC: when EDGAR made it public
+	Link     string `json:"link" db:"link"`                   // the EDGAR filing index
+	Document string `json:"document,omitempty" db:"document"` // the primary document
+}
+
+// IPO is one listing of the FMP IPO calendar. Status follows the calendar's
+// actions: "Expected" before pricing, then "Priced", or "Withdrawn" or
+// "Postponed".
+type IPO struct {
+	Symbol     string  `json:"symbol" db:"symbol"`
+	Company    string  `json:"company" db:"company"`
+	Exchange   string  `json:"exchange" db:"exchange"`
+	Date       string  `json:"date" db:"date"` // YYYY-MM-DD: the expected or actual first trading day
+	Status     string  `json:"status" db:"status"`
+	Shares     float64 `json:"shares,omitempty" db:"shares"`
+	PriceRange string  `json:"price_range,omitempty" db:"price_range"` // e.g. "17.00-19.00", in the listing currency
+	MarketCap  float64 `json:"market_cap,omitempty" db:"market_cap"`
+}
+
+// Kinds of NewsItem
+const (
+	NewsHeadline     = "news"
+	NewsPressRelease = "press_release"
+)
+
+// NewsItem is a headline or press release about one symbol. ID identifies
+// it across runs, see news.ID.
+type NewsItem struct {
+	ID        string   `json:"id"`
+	Symbol    string   `json:"symbol"`
+	Kind      string   `json:"kind"`
+	Published string   `json:"published_at"` // RFC 3339, UTC
+	Title     string   `json:"title"`
+	Site      string   `json:"site,omitempty"`
+	URL       string   `json:"url,omitempty"`
+	Summary   string   `json:"summary,omitempty"`   // the start of the text
+	Sentiment *float64 `json:"sentiment,omitempty"` // -1 to 1, see news.Sentiment
+}
+
+// AnalystRating is the sell-side view of one symbol on one date: the
+// consensus price target, the count of analysts at each rating and the
+// grade changes published shortly before. Targets are in the listing
+// currency.
+type AnalystRating struct {
+	Symbol          string          `json:"symbol" db:"symbol"`
+	Date            string          `json:"date" db:"date"` // YYYY-MM-DD
+	TargetHigh      float64         `json:"target_high,omitempty" db:"target_high"`
+	TargetLow       float64         `json:"target_low,omitempty" db:"target_low"`
+	TargetConsensus float64         `json:"target_consensus,omitempty" db:"target_consensus"`
+	TargetMedian    float64         `json:"target_median,omitempty" db:"target_median"`
+	StrongBuy       int             `json:"strong_buy" db:"strong_buy"`
+	Buy             int             `json:"buy" db:"buy"`
+	Hold            int             `json:"hold" db:"hold"`
+	Sell            int             `json:"sell" db:"sell"`
+	StrongSell      int             `json:"strong_sell" db:"strong_sell"`
+	Consensus       string          `json:"consensus,omitempty" db:"consensus"` // e.g. "Buy"
+	Upgrades        int             `json:"upgrades" db:"upgrades"`
+	Downgrades      int             `json:"downgrades" db:"downgrades"`
+	Actions         []AnalystAction `json:"actions,omitempty" db:"-"`
+}
+
+// Covered reports whether any analyst data was found for the symbol
+func (r AnalystRating) Covered() bool {
+	return r.TargetConsensus > 0 || r.StrongBuy+r.Buy+r.Hold+r.Sell+r.StrongSell > 0 || len(r.Actions) > 0
+}
+
+// AnalystAction is one grade change published by a research firm
+type AnalystAction struct {
+	Date          string  `json:"date"` // YYYY-MM-DD
+	Firm          string  `json:"firm"`
+	Action        string  `json:"action"` // upgrade, downgrade, hold or initialise, as FMP reports it
+	PreviousGrade string  `json:"previous_grade,omitempty"`
+	NewGrade      string  `json:"new_grade"`
+	Price         float64 `json:"price_when_posted,omitempty"`
+}
+
+// Snapshot holds every asset collected on a single day
+type Snapshot struct {
+	Date   time.Time `json:"date" db:"date_updated"`
+	Path   string    `json:"path" db:"-"`
+	Assets []Asset   `json:"assets" db:"-"`
+}
+
+// Price returns the price of ticker in the snapshot, or false if it is missing
+func (s Snapshot) Price(ticker string) (float64, bool) {
+	for _, asset := range s.Assets {
+		if asset.Ticker == ticker && asset.CurrentPrice > 0 {
+			return asset.CurrentPrice, true
+		}
+	}
+	return 0, false
+}
+
+// SortAssets ranks assets by market cap descending, breaking ties by ticker so
+// the order does not depend on which worker finished first
+func SortAssets(assets []Asset) {
+	sort.Slice(assets, func(i, j int) bool { return RanksBefore(assets[i], assets[j]) })
+}
+
+// RanksBefore reports whether a ranks ahead of b in the order of SortAssets
+func RanksBefore(a, b Asset) bool {
+	if a.MarketCap != b.MarketCap {
+		return a.MarketCap > b.MarketCap
+	}
+	return a.Ticker < b.Ticker
+}
+
+// DroppedSymbol records a symbol excluded from a run's output and why
+type DroppedSymbol struct {
+	Symbol  string `json:"symbol" db:"symbol"`
+	Country string `json:"country,omitempty" db:"country"`
+	Reason  string `json:"reason" db:"reason"`
+}
+
+// OutputFile describes an artifact written by a run
+type OutputFile struct {
+	Path   string `json:"path" db:"path"`
+	Bytes  int64  `json:"bytes" db:"bytes"`
+	SHA256 string `json:"sha256" db:"sha256"`
+}
+
+// CountryFailure is a country whose screen failed during a run
+type CountryFailure struct {
+	Country string `json:"country" db:"country"`
+	// Kind classifies the cause: rate_limited, auth_failed, parse_error or other
+	Kind string `json:"kind" db:"kind"`
+	// Partial is how many listings were fetched before the failure; 0 means
+	// the country is missing from the output
+	Partial int    `json:"partial" db:"partial"`
+	Error   string `json:"error" db:"error"`
+}
+
+// CountryFallback is a country that fell short of its row gate and was
+// published from a previous snapshot instead
+type CountryFallback struct {
+	Country  string `json:"country" db:"country"`
+	Rows     int    `json:"rows" db:"rows"`
+	Expected int    `json:"expected" db:"expected"`
+	Used     int    `json:"used" db:"used"`
+	Snapshot string `json:"snapshot" db:"snapshot"`
+}
+
+// Run summarizes one collector run, as written to run_report.json
+type Run struct {
+	Collector         string            `json:"collector" db:"collector"`
+	StartedAt         time.Time         `json:"started_at" db:"started_at"`
+	FinishedAt        time.Time         `json:"finished_at" db:"finished_at"`
+	DurationSeconds   float64           `json:"duration_seconds" db:"duration_seconds"`
+	APICalls          map[string]int64  `json:"api_calls" db:"-"`
+	APICallsTotal     int64             `json:"api_calls_total" db:"api_calls_total"`
+	APIErrors         int64             `json:"api_errors" db:"api_errors"`
+	RateLimited       int64             `json:"rate_limited" db:"rate_limited"`
+	Errors            []string          `json:"errors" db:"-"`
+	ErrorCount        int               `json:"error_count" db:"error_count"`
+	SymbolsPerCountry map[string]int    `json:"symbols_per_country" db:"-"`
+	Dropped           []DroppedSymbol   `json:"dropped" db:"-"`
+	DroppedByReason   map[string]int    `json:"dropped_by_reason" db:"-"`
+	Outputs           []OutputFile      `json:"outputs" db:"-"`
+	ErrorsByKind      map[string]int    `json:"errors_by_kind" db:"-"`
+	FailedCountries   []CountryFailure  `json:"failed_countries,omitempty" db:"-"`
+	FallbackCountries []CountryFallback `json:"fallback_countries,omitempty" db:"-"`
+	// Partial is set when some countries were not fully collected, or were
+	// published from a previous snapshot
+	Partial bool `json:"partial" db:"partial"`
+}
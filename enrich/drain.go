@@ -0,0 +1,249 @@
+package enrich
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"algotradar/apikeys"
+	"algotradar/canonical"
+	"algotradar/domain"
+	"algotradar/integrity"
+	"algotradar/metrics"
+	"algotradar/output"
+	"algotradar/schema"
+)
+
+// Fetcher resolves a task to the value written into its output files
+type Fetcher func(ctx context.Context, task Task) (string, error)
+
+// DrainResult summarizes one drain pass
+type DrainResult struct {
+	Done      int  `json:"done"`
+	Discarded int  `json:"discarded"`
+	Remaining int  `json:"remaining"`
+	QuotaHit  bool `json:"quota_hit"`
+}
+
+// Drainer works through a queue, patching output files as lookups succeed
+type Drainer struct {
+	Queue  *Queue
+	Fetch  Fetcher
+	Logger *slog.Logger
+
+	// Limit caps the tasks attempted in one pass (0 = all)
+	Limit int
+	// SigningKey re-signs patched files that carry a signature
+	SigningKey ed25519.PrivateKey
+}
+
+// Drain processes queued tasks until the queue is empty, the limit is reached,
+// the quota runs out or ctx is cancelled. Unfinished tasks stay queued.
+func (d *Drainer) Drain(ctx context.Context) (DrainResult, error) {
+	var result DrainResult
+
+	tasks, err := d.Queue.Load()
+	if err != nil {
+		return result, err
+	}
+
+	var remaining []Task
+	patches := make(map[string]map[string]string) // file -> symbol -> value
+	for i, task := range tasks {
+		if result.QuotaHit || ctx.Err() != nil || (d.Limit > 0 && i >= d.Limit) {
+			remaining = append(remaining, task)
+			continue
+		}
+
+		value, err := d.Fetch(ctx, task)
+		switch {
+		case errors.Is(err, ErrQuotaExceeded):
+			d.Logger.Warn("quota exhausted, leaving remaining tasks queued", "symbol", task.Symbol)
+			result.QuotaHit = true
+			remaining = append(remaining, task)
+
+		case err != nil:
+			task.Attempts++
+			task.LastError = err.Error()
+			if task.Attempts >= MaxAttempts {
+				d.Logger.Warn("discarding task after repeated failures", "kind", task.Kind, "symbol", task.Symbol, "attempts", task.Attempts, "error", err)
+				result.Discarded++
+				continue
+			}
+			d.Logger.Debug("task failed, will retry", "kind", task.Kind, "symbol", task.Symbol, "attempts", task.Attempts, "error", err)
+			remaining = append(remaining, task)
+
+		default:
+			for _, file := range task.Files {
+				if patches[file] == nil {
+					patches[file] = make(map[string]string)
+				}
+				patches[file][task.Symbol] = value
+			}
+			result.Done++
+		}
+	}
+
+	for file, values := range patches {
+		if err := d.patch(file, values); err != nil {
+			return result, err
+		}
+		d.Logger.Info("patched output", "file", file, "symbols", len(values))
+	}
+
+	result.Remaining = len(remaining)
+	return result, d.Queue.Replace(remaining)
+}
+
+// patch sets the image field of matching rows in a JSON output file, which
+// may be compressed, and refreshes its integrity sidecars
+func (d *Drainer) patch(path string, images map[string]string) error {
+	data, err := output.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		d.Logger.Warn("output file no longer exists, skipping", "file", path)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var rows []domain.Asset
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for i := range rows {
+		if image, ok := images[rows[i].Ticker]; ok {
+			rows[i].Image = image
+		}
+	}
+
+	// Encode the way the collectors do so patched files differ only in the images
+	out, err := canonical.JSON(schema.Assets(rows))
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	if err := writeFile(path, out); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	if _, err := os.Stat(path + integrity.ChecksumSuffix); err != nil {
+		return nil
+	}
+	if _, err := os.Stat(path + integrity.SignatureSuffix); err == nil && d.SigningKey == nil {
+		d.Logger.Warn("no signing key set, removing stale signature", "file", path)
+		os.Remove(path + integrity.SignatureSuffix)
+	}
+	return integrity.Seal(path, d.SigningKey)
+}
+
+// writeFile replaces path with data, compressed as its extension asks, by
+// way of a temporary file so an interrupted drain leaves the old output
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w, err := output.NewWriter(file, path)
+	if err == nil {
+		if _, err = w.Write(data); err == nil {
+			err = w.Close()
+		}
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// errKeyRejected marks a 401/403 response: the key is invalid or revoked
+var errKeyRejected = errors.New("API key rejected")
+
+// ProfileImageFetcher looks up company logos through the FMP profile
+// endpoint, rotating across keys. A rejected key is disabled and a
+// rate-limited one retired while other keys remain.
+func ProfileImageFetcher(keys *apikeys.Pool, baseURL string, client *http.Client) Fetcher {
+	return func(ctx context.Context, task Task) (string, error) {
+		if task.Kind != KindProfileImage {
+			return "", fmt.Errorf("unsupported task kind %q", task.Kind)
+		}
+		for {
+			key, err := keys.Next()
+			if err != nil {
+				return "", err
+			}
+			image, err := fetchProfileImage(ctx, client, baseURL, key, task.Symbol)
+			switch {
+			case errors.Is(err, errKeyRejected):
+				keys.Disable(key)
+			case errors.Is(err, ErrQuotaExceeded):
+				keys.Exhaust(key)
+			default:
+				return image, err
+			}
+			if keys.Usable() == 0 {
+				return "", err
+			}
+		}
+	}
+}
+
+// fetchProfileImage makes one profile request with key
+func fetchProfileImage(ctx context.Context, client *http.Client, baseURL, key, symbol string) (string, error) {
+	endpoint := "/v3/profile/" + symbol
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s%s?apikey=%s", baseURL, endpoint, key), nil)
+	if err != nil {
+		return "", err
+	}
+
+	label := metrics.EndpointLabel(endpoint)
+	start := time.Now()
+	resp, err := client.Do(req)
+	metrics.APIDuration.Observe(time.Since(start).Seconds(), label)
+	if err != nil {
+		metrics.APICalls.Inc(label, "error")
+		return "", err
+	}
+	defer resp.Body.Close()
+	metrics.APICalls.Inc(label, fmt.Sprint(resp.StatusCode))
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusTooManyRequests:
+		metrics.APIRateLimited.Inc(label)
+		return "", ErrQuotaExceeded
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return "", fmt.Errorf("profile request for %s failed with status %d: %w", symbol, resp.StatusCode, errKeyRejected)
+	default:
+		return "", fmt.Errorf("profile request for %s failed with status %d", symbol, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	var profiles []struct {
+		Image string `json:"image"`
+	}
+	if err := json.Unmarshal(body, &profiles); err != nil {
+		return "", fmt.Errorf("failed to parse profile for %s: %w", symbol, err)
+	}
+	if len(profiles) == 0 || strings.TrimSpace(profiles[0].Image) == "" {
+		return "", fmt.Errorf("no profile image for %s", symbol)
+	}
+	return profiles[0].Image, nil
+}
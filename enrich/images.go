@@ -0,0 +1,239 @@
+package enrich
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"algotradar/domain"
+	"algotradar/workpool"
+)
+
+const (
+	// DefaultImageMinMarketCap is the USD market cap from which collectors
+	// look up company logos
+	DefaultImageMinMarketCap = 10e9
+	// DefaultImageCacheTTL is how long a cached logo URL is trusted; logos
+	// rarely change
+	DefaultImageCacheTTL = 7 * 24 * time.Hour
+	// ImageCacheFile is the name of the logo cache inside a collector's cache directory
+	ImageCacheFile = "images.json"
+	// imageBatchSize is how many symbols one lookup asks for
+	imageBatchSize = 50
+	// imageWorkers is how many lookups run at once
+	imageWorkers = 4
+)
+
+// ImageLookup returns the logo URL of each symbol in batch it has one for,
+// using the provider's batch endpoint where it has one. An error wrapping
+// ErrQuotaExceeded stops the stage.
+type ImageLookup func(batch []string) (map[string]string, error)
+
+// Images is the logo enrichment stage. It runs once a collector has ranked
+// its rows and fills the image of every row worth at least MinMarketCap US
+// dollars that has none, from the Cache first and the Lookup otherwise.
+type Images struct {
+	MinMarketCap float64
+	Lookup       ImageLookup
+	// Cache, when set, keeps logo URLs across runs
+	Cache  *ImageCache
+	Logger *slog.Logger
+}
+
+// ImageResult counts what one pass of the stage did. Deferred lists the
+// symbols not looked up because the quota ran out, for the enrichment queue.
+type ImageResult struct {
+	Wanted   int      `json:"wanted"`
+	Cached   int      `json:"cached"`
+	Fetched  int      `json:"fetched"`
+	Missing  int      `json:"missing"`
+	Deferred []string `json:"deferred,omitempty"`
+}
+
+// Wants reports whether the stage looks up the logo of asset
+func (s *Images) Wants(asset domain.Asset) bool {
+	return asset.Image == "" && asset.MarketCap >= s.MinMarketCap
+}
+
+// Resolve returns the logo URL of each symbol it found one for
+func (s *Images) Resolve(symbols []string) (map[string]string, ImageResult) {
+	logger := s.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	result := ImageResult{Wanted: len(symbols)}
+	images := make(map[string]string, len(symbols))
+
+	var misses []string
+	for _, symbol := range symbols {
+		if image, ok := s.Cache.Get(symbol); ok {
+			result.Cached++
+			if image != "" {
+				images[symbol] = image
+			}
+			continue
+		}
+		misses = append(misses, symbol)
+	}
+
+	var batches [][]string
+	for start := 0; start < len(misses); start += imageBatchSize {
+		batches = append(batches, misses[start:min(start+imageBatchSize, len(misses))])
+	}
+
+	var mu sync.Mutex
+	quotaHit := false
+	pool := workpool.New("images", 1, imageWorkers)
+	workpool.Each(pool, imageWorkers, batches, func(_ int, batch []string) {
+		mu.Lock()
+		stopped := quotaHit
+		mu.Unlock()
+		var found map[string]string
+		var err error
+		if !stopped {
+			found, err = s.Lookup(batch)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		deferring := stopped || errors.Is(err, ErrQuotaExceeded)
+		if deferring && !quotaHit {
+			quotaHit = true
+			logger.Warn("API quota exhausted, deferring remaining logo lookups")
+		}
+		if err != nil && !deferring {
+			logger.Warn("logo lookup failed", "first", batch[0], "symbols", len(batch), "error", err)
+			return
+		}
+		for _, symbol := range batch {
+			image, ok := found[symbol]
+			if !ok && deferring {
+				result.Deferred = append(result.Deferred, symbol)
+				continue
+			}
+			// Symbols without a logo are cached too, so they are not asked for every run
+			s.Cache.Put(symbol, image)
+			if image != "" {
+				images[symbol] = image
+				result.Fetched++
+			}
+		}
+	})
+
+	result.Missing = result.Wanted - len(images) - len(result.Deferred)
+	return images, result
+}
+
+// Apply fills the logos of assets in place
+func (s *Images) Apply(assets []domain.Asset) ImageResult {
+	var symbols []string
+	for _, asset := range assets {
+		if s.Wants(asset) {
+			symbols = append(symbols, asset.Ticker)
+		}
+	}
+	images, result := s.Resolve(symbols)
+	for i := range assets {
+		if image, ok := images[assets[i].Ticker]; ok && assets[i].Image == "" {
+			assets[i].Image = image
+		}
+	}
+	return result
+}
+
+// ImageCache is a JSON file of logo URLs by symbol. Entries older than TTL
+// are looked up again. A nil cache holds nothing.
+type ImageCache struct {
+	Path string
+	TTL  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]imageEntry
+	dirty   bool
+}
+
+type imageEntry struct {
+	Image   string    `json:"image"`
+	Fetched time.Time `json:"fetched"`
+}
+
+// LoadImageCache reads the cache at path; a missing file is an empty cache
+func LoadImageCache(path string, ttl time.Duration) (*ImageCache, error) {
+	c := &ImageCache{Path: path, TTL: ttl, entries: make(map[string]imageEntry)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read logo cache: %w", err)
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		return nil, fmt.Errorf("invalid logo cache %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Get returns the cached logo URL of symbol, empty when it is known to have
+// none, and whether a fresh entry exists
+func (c *ImageCache) Get(symbol string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[symbol]
+	if !ok || c.TTL > 0 && time.Since(entry.Fetched) > c.TTL {
+		return "", false
+	}
+	return entry.Image, true
+}
+
+// Put records the logo URL of symbol
+func (c *ImageCache) Put(symbol, image string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[symbol] = imageEntry{Image: image, Fetched: time.Now().UTC()}
+	c.dirty = true
+}
+
+// Save writes the cache back if it changed, dropping expired entries
+func (c *ImageCache) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	for symbol, entry := range c.entries {
+		if c.TTL > 0 && time.Since(entry.Fetched) > c.TTL {
+			delete(c.entries, symbol)
+		}
+	}
+
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create logo cache directory: %w", err)
+	}
+	tmp := c.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write logo cache: %w", err)
+	}
+	if err := os.Rename(tmp, c.Path); err != nil {
+		return fmt.Errorf("failed to write logo cache: %w", err)
+	}
+	c.dirty = false
+	return nil
+}
@@ -0,0 +1,53 @@
+package enrich
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Technicals adds the 52-week range, the 50 and 200-day moving averages and
+// the year-to-date return to each row
+const Technicals = "technicals"
+
+// Ownership adds the institutional ownership and top-10 holder
+// concentration of each row, from 13F holdings
+const Ownership = "ownership"
+
+// REIT adds the dividend yield, trailing funds from operations and
+// price/FFO of each REIT row, from its quarterly cash flow statements
+const REIT = "reit"
+
+// optional are the enrichments --enrich can turn on, which cost extra API
+// calls or widen the rows and are off by default
+var optional = map[string]bool{Technicals: true, Ownership: true, REIT: true}
+
+// Set is the optional enrichments a run performs
+type Set map[string]bool
+
+// ParseSet parses a comma-separated list of optional enrichments, e.g.
+// "technicals,ownership"; an empty list turns none on
+func ParseSet(spec string) (Set, error) {
+	set := make(Set)
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !optional[name] {
+			return nil, fmt.Errorf("unknown enrichment %q, want one of %s", name, strings.Join(OptionalNames(), ", "))
+		}
+		set[name] = true
+	}
+	return set, nil
+}
+
+// OptionalNames lists the enrichments ParseSet accepts
+func OptionalNames() []string {
+	names := make([]string, 0, len(optional))
+	for name := range optional {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,166 @@
+// Package enrich persists enrichment work that a collector had to skip, for
+// example profile lookups after the API quota ran out, and drains it later.
+package enrich
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultQueuePath is where collectors append deferred tasks
+const DefaultQueuePath = ".fmp_queue/enrichment.jsonl"
+
+// KindProfileImage fills the image field of an output row from the company profile
+const KindProfileImage = "profile_image"
+
+// MaxAttempts is how many failed drains a task survives before it is discarded
+const MaxAttempts = 5
+
+// ErrQuotaExceeded marks API errors caused by an exhausted request budget
+var ErrQuotaExceeded = errors.New("API quota exceeded")
+
+// Task is one deferred lookup and the output files it should be written into
+type Task struct {
+	Kind      string    `json:"kind"`
+	Symbol    string    `json:"symbol"`
+	Files     []string  `json:"files"`
+	Enqueued  time.Time `json:"enqueued"`
+	Attempts  int       `json:"attempts,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// Queue is an append-only JSON Lines file of tasks
+type Queue struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// NewQueue returns the queue stored at path
+func NewQueue(path string) *Queue {
+	return &Queue{Path: path}
+}
+
+// Push appends tasks to the queue, creating it if needed
+func (q *Queue) Push(tasks ...Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(q.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create queue directory: %w", err)
+	}
+	file, err := os.OpenFile(q.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open queue %s: %w", q.Path, err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	for _, task := range tasks {
+		if err := encoder.Encode(task); err != nil {
+			return fmt.Errorf("failed to append to queue %s: %w", q.Path, err)
+		}
+	}
+	return file.Sync()
+}
+
+// Load returns all queued tasks; a missing queue is empty
+func (q *Queue) Load() ([]Task, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	file, err := os.Open(q.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open queue %s: %w", q.Path, err)
+	}
+	defer file.Close()
+
+	var tasks []Task
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var task Task
+		if err := json.Unmarshal(scanner.Bytes(), &task); err != nil {
+			return nil, fmt.Errorf("invalid task on line %d of %s: %w", line, q.Path, err)
+		}
+		tasks = append(tasks, task)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read queue %s: %w", q.Path, err)
+	}
+	return tasks, nil
+}
+
+// Len counts the queued tasks without decoding them; a missing queue is empty
+func (q *Queue) Len() (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	file, err := os.Open(q.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open queue %s: %w", q.Path, err)
+	}
+	defer file.Close()
+
+	n := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) > 0 {
+			n++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read queue %s: %w", q.Path, err)
+	}
+	return n, nil
+}
+
+// Replace atomically rewrites the queue with tasks, removing it when empty
+func (q *Queue) Replace(tasks []Task) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(tasks) == 0 {
+		if err := os.Remove(q.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove queue %s: %w", q.Path, err)
+		}
+		return nil
+	}
+
+	tmp := q.Path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to rewrite queue %s: %w", q.Path, err)
+	}
+	encoder := json.NewEncoder(file)
+	for _, task := range tasks {
+		if err := encoder.Encode(task); err != nil {
+			file.Close()
+			os.Remove(tmp)
+			return fmt.Errorf("failed to rewrite queue %s: %w", q.Path, err)
+		}
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to rewrite queue %s: %w", q.Path, err)
+	}
+	return os.Rename(tmp, q.Path)
+}
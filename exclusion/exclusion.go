@@ -0,0 +1,180 @@
+// Package exclusion decides which listings are funds, ETFs, preferred shares,
+// warrants and other securities rather than operating companies, so both
+// collectors drop the same rows. The rules are data: a bundled rules.json
+// that a file passed to Load can replace list by list.
+package exclusion
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Drop reasons reported for excluded listings; a security type match
+// reports the type itself, e.g. "etf"
+const (
+	ReasonName   = "fund_name"
+	ReasonSuffix = "symbol_suffix"
+)
+
+// Rules are the exclusion lists. Names are matched as whole words or
+// phrases regardless of case and punctuation, so "FUND" drops "Vanguard
+// Index Fund" but not "Funding Circle". KeepNames override ExcludeNames for
+// operating companies whose names look like funds. Symbol suffixes may use
+// "?" for any one character, e.g. "-P?" for preferred series BAC-PB.
+type Rules struct {
+	ExcludeTypes    []string `json:"exclude_types"`
+	ExcludeNames    []string `json:"exclude_names"`
+	KeepNames       []string `json:"keep_names"`
+	ExcludeSuffixes []string `json:"exclude_symbol_suffixes"`
+}
+
+// Security is what the rules look at: the listing's symbol, its company name
+// and its instrument type as classified by package instrument
+type Security struct {
+	Symbol string
+	Name   string
+	Type   string
+}
+
+//go:embed rules.json
+var defaultRules []byte
+
+var (
+	mu      sync.RWMutex
+	current compiled
+)
+
+// compiled holds the rules in the form Match uses: upper-case types and
+// suffixes and tokenized name phrases
+type compiled struct {
+	types    map[string]bool
+	exclude  [][]string
+	keep     [][]string
+	suffixes []string
+}
+
+func init() {
+	var r Rules
+	if err := json.Unmarshal(defaultRules, &r); err != nil {
+		panic(fmt.Sprintf("exclusion: invalid rules.json: %v", err))
+	}
+	current = compile(r)
+}
+
+// Defaults returns the bundled rules
+func Defaults() Rules {
+	var r Rules
+	json.Unmarshal(defaultRules, &r)
+	return r
+}
+
+// Load reads rules from file. Each list the file sets replaces the bundled
+// one, so a file holding only "keep_names" changes the exceptions and leaves
+// the other rules alone; an empty list disables that kind of rule.
+func Load(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read exclusion rules: %w", err)
+	}
+	r := Defaults()
+	if err := json.Unmarshal(data, &r); err != nil {
+		return fmt.Errorf("failed to parse exclusion rules: %w", err)
+	}
+	for _, suffix := range r.ExcludeSuffixes {
+		if _, err := path.Match(suffix, ""); err != nil || strings.TrimSpace(suffix) == "" {
+			return fmt.Errorf("exclusion rules: invalid symbol suffix %q", suffix)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	current = compile(r)
+	return nil
+}
+
+func compile(r Rules) compiled {
+	c := compiled{types: make(map[string]bool)}
+	for _, t := range r.ExcludeTypes {
+		c.types[strings.ToUpper(strings.TrimSpace(t))] = true
+	}
+	for _, name := range r.ExcludeNames {
+		if words := tokens(name); len(words) > 0 {
+			c.exclude = append(c.exclude, words)
+		}
+	}
+	for _, name := range r.KeepNames {
+		if words := tokens(name); len(words) > 0 {
+			c.keep = append(c.keep, words)
+		}
+	}
+	for _, suffix := range r.ExcludeSuffixes {
+		if suffix = strings.ToUpper(strings.TrimSpace(suffix)); suffix != "" {
+			c.suffixes = append(c.suffixes, suffix)
+		}
+	}
+	return c
+}
+
+// Match returns why s is not an operating company's listing, or "" when it
+// is kept: the excluded security type, ReasonSuffix or ReasonName
+func Match(s Security) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current.match(s)
+}
+
+func (c compiled) match(s Security) string {
+	if t := strings.ToUpper(strings.TrimSpace(s.Type)); c.types[t] {
+		return strings.ToLower(t)
+	}
+	symbol := strings.ToUpper(s.Symbol)
+	for _, suffix := range c.suffixes {
+		if len(symbol) > len(suffix) {
+			if ok, _ := path.Match(suffix, symbol[len(symbol)-len(suffix):]); ok {
+				return ReasonSuffix
+			}
+		}
+	}
+	words := tokens(s.Name)
+	for _, phrase := range c.keep {
+		if contains(words, phrase) {
+			return ""
+		}
+	}
+	for _, phrase := range c.exclude {
+		if contains(words, phrase) {
+			return ReasonName
+		}
+	}
+	return ""
+}
+
+// tokens splits a name into upper-case words at anything that is not a
+// letter or digit, so "S&P 500 ETF Trust," gives S P 500 ETF TRUST
+func tokens(name string) []string {
+	return strings.FieldsFunc(strings.ToUpper(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// contains reports whether phrase occurs in words as consecutive words
+func contains(words, phrase []string) bool {
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		match := true
+		for j, w := range phrase {
+			if words[i+j] != w {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
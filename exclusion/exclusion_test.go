@@ -0,0 +1,129 @@
+package exclusion
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"algotradar/instrument"
+)
+
+// classified fills in the type the collectors would give s from its symbol
+// and name when it has none
+func classified(s Security) Security {
+	if s.Type == "" {
+		s.Type = instrument.Classify(instrument.Facts{Symbol: s.Symbol, Name: s.Name})
+	}
+	return s
+}
+
+// restoreDefaults puts the bundled rules back after a test loads others
+func restoreDefaults(t *testing.T) {
+	t.Cleanup(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		current = compile(Defaults())
+	})
+}
+
+// TestMatchKnownCases covers listings earlier keyword filters got wrong,
+// mostly operating companies with "Trust", "Fund" or a fund sponsor's name
+// in theirs, next to the funds and derivative listings that must still go
+func TestMatchKnownCases(t *testing.T) {
+	tests := []struct {
+		security Security
+		want     string
+	}{
+		{Security{Symbol: "NTRS", Name: "Northern Trust Corporation", Type: "stock"}, ""},
+		{Security{Symbol: "8309.T", Name: "Sumitomo Mitsui Trust Holdings, Inc."}, ""},
+		{Security{Symbol: "FNF", Name: "Fidelity National Financial, Inc.", Type: "stock"}, ""},
+		{Security{Symbol: "ITIC", Name: "Investors Title Company", Type: "stock"}, ""},
+		{Security{Symbol: "IDXX", Name: "IDEXX Laboratories, Inc.", Type: "stock"}, ""},
+		{Security{Symbol: "FRT", Name: "Federal Realty Investment Trust", Type: "stock"}, ""},
+		{Security{Symbol: "8951.T", Name: "Nippon Building Fund Inc."}, ""},
+		{Security{Symbol: "FCH.L", Name: "Funding Circle Holdings plc"}, ""},
+		{Security{Symbol: "BN.PA", Name: "Danone S.A."}, ""},
+		{Security{Symbol: "BRK-B", Name: "Berkshire Hathaway Inc.", Type: "stock"}, ""},
+		{Security{Symbol: "O", Name: "Realty Income REIT Corporation", Type: "stock"}, ""},
+		{Security{Symbol: "SPY", Name: "SPDR S&P 500 ETF Trust", Type: "etf"}, "etf"},
+		{Security{Symbol: "VTI", Name: "Vanguard Total Stock Market Index Fund"}, ReasonName},
+		{Security{Symbol: "IVV", Name: "iShares Core S&P 500 ETF"}, ReasonName},
+		{Security{Symbol: "SMT.L", Name: "Scottish Mortgage Investment Trust PLC"}, ReasonName},
+		{Security{Symbol: "BF-B", Name: "Brown-Forman Corporation"}, ""},
+		{Security{Symbol: "REI-UN.TO", Name: "RioCan Real Estate Investment Trust"}, ""},
+		{Security{Symbol: "DOL-U.TO", Name: "Dollarama Inc."}, ""},
+		{Security{Symbol: "VOLV-B.ST", Name: "AB Volvo (publ)"}, ""},
+		{Security{Symbol: "BAC-PB", Name: "Bank of America Corporation"}, instrument.Preferred},
+		{Security{Symbol: "BCE-PR-A.TO", Name: "BCE Inc."}, instrument.Preferred},
+		{Security{Symbol: "SPCE-WT", Name: "Virgin Galactic Holdings, Inc."}, instrument.Warrant},
+		{Security{Symbol: "GSAH-UN", Name: "GS Acquisition Holdings Corp II"}, instrument.Unit},
+	}
+	for _, tt := range tests {
+		if got := Match(classified(tt.security)); got != tt.want {
+			t.Errorf("Match(%s, %q) = %q, want %q", tt.security.Symbol, tt.security.Name, got, tt.want)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	realty := Security{Symbol: "FRT", Name: "Federal Realty Investment Trust", Type: "stock"}
+	spy := Security{Symbol: "SPY", Name: "SPDR S&P 500 ETF Trust", Type: "etf"}
+	whenIssued := Security{Symbol: "ABC-WI", Name: "ABC Corporation", Type: "stock"}
+	bank := Security{Symbol: "NTRS", Name: "Northern Trust Corporation", Type: "stock"}
+
+	tests := []struct {
+		name  string
+		rules string
+		want  map[Security]string
+	}{
+		{"no exceptions", `{"keep_names": []}`, map[Security]string{realty: ReasonName, spy: "etf", bank: ""}},
+		{"types disabled, names still drop", `{"exclude_types": []}`, map[Security]string{spy: ReasonName, realty: ""}},
+		{"suffixes", `{"exclude_symbol_suffixes": ["-W?"]}`, map[Security]string{whenIssued: ReasonSuffix, bank: ""}},
+		{"trust as an exclusion word", `{"exclude_names": ["TRUST"]}`, map[Security]string{bank: ReasonName, realty: ""}},
+	}
+	restoreDefaults(t)
+	for _, tt := range tests {
+		file := filepath.Join(t.TempDir(), "rules.json")
+		if err := os.WriteFile(file, []byte(tt.rules), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := Load(file); err != nil {
+			t.Fatalf("%s: Load = %v", tt.name, err)
+		}
+		for s, want := range tt.want {
+			if got := Match(s); got != want {
+				t.Errorf("%s: Match(%s) = %q, want %q", tt.name, s.Symbol, got, want)
+			}
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	restoreDefaults(t)
+	dir := t.TempDir()
+	tests := []struct {
+		name, rules, want string
+	}{
+		{"not JSON", `exclude_names: [FUND]`, "failed to parse"},
+		{"bad pattern", `{"exclude_symbol_suffixes": ["-P["]}`, "invalid symbol suffix"},
+		{"blank suffix", `{"exclude_symbol_suffixes": [" "]}`, "invalid symbol suffix"},
+	}
+	for _, tt := range tests {
+		file := filepath.Join(dir, "rules.json")
+		if err := os.WriteFile(file, []byte(tt.rules), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := Load(file); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Load = %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+	if err := Load(filepath.Join(dir, "missing.json")); err == nil || !strings.Contains(err.Error(), "failed to read") {
+		t.Errorf("Load of a missing file = %v, want a read error", err)
+	}
+
+	// A rejected file leaves the rules as they were
+	if got := Match(Security{Symbol: "VTI", Name: "Vanguard Total Stock Market Index Fund", Type: "stock"}); got != ReasonName {
+		t.Errorf("Match after failed loads = %q, want %q", got, ReasonName)
+	}
+}
@@ -0,0 +1,136 @@
+// Package fx holds the currency reference data shared by the collectors and the backtest.
+package fx
+
+import "strings"
+
+// Sources of the exchange rates a row records in fx_rate_source
+const (
+	// SourceAPI is a rate from the collector's data provider
+	SourceAPI = "api"
+	// SourceFallback is a rate from the fallback provider, asked when the
+	// data provider had none
+	SourceFallback = "fallback"
+	// SourceStatic is a hardcoded rate such as FallbackRates, used when no
+	// provider had one
+	SourceStatic = "static"
+	// SourceHistorical is the provider's daily close on the snapshot date,
+	// used for snapshots of past days
+	SourceHistorical = "historical"
+)
+
+// FallbackRates are approximate USD values of one unit of each currency, used when
+// the FMP FX endpoint is unavailable or rate limited
+var FallbackRates = map[string]float64{
+	"IDR": 0.0000625, // Indonesian Rupiah: ~16,000 IDR = 1 USD
+	"JPY": 0.0067,    // Japanese Yen: ~150 JPY = 1 USD
+	"KRW": 0.00075,   // Korean Won: ~1,330 KRW = 1 USD
+	"INR": 0.012,     // Indian Rupee: ~83 INR = 1 USD
+	"CNY": 0.14,      // Chinese Yuan: ~7.1 CNY = 1 USD
+	"HKD": 0.128,     // Hong Kong Dollar: ~7.8 HKD = 1 USD
+	"SAR": 0.267,     // Saudi Riyal: ~3.75 SAR = 1 USD
+	"AED": 0.272,     // UAE Dirham: ~3.67 AED = 1 USD
+	"THB": 0.028,     // Thai Baht: ~36 THB = 1 USD
+	"MYR": 0.224,     // Malaysian Ringgit: ~4.46 MYR = 1 USD
+	"PHP": 0.018,     // Philippine Peso: ~56 PHP = 1 USD
+	"VND": 0.00004,   // Vietnamese Dong: ~24,000 VND = 1 USD
+	"TWD": 0.031,     // Taiwan Dollar: ~32 TWD = 1 USD
+	"ZAR": 0.053,     // South African Rand: ~19 ZAR = 1 USD
+	"BRL": 0.20,      // Brazilian Real: ~5 BRL = 1 USD
+	"MXN": 0.058,     // Mexican Peso: ~17 MXN = 1 USD
+	"CLP": 0.0010,    // Chilean Peso: ~950 CLP = 1 USD
+	"COP": 0.00024,   // Colombian Peso: ~4,100 COP = 1 USD
+	"PEN": 0.27,      // Peruvian Sol: ~3.7 PEN = 1 USD
+	"ARS": 0.0010,    // Argentine Peso: ~1,000 ARS = 1 USD
+	"EGP": 0.032,     // Egyptian Pound: ~31 EGP = 1 USD
+	"TRY": 0.030,     // Turkish Lira: ~33 TRY = 1 USD
+	"ILS": 0.28,      // Israeli Shekel: ~3.6 ILS = 1 USD
+	"EUR": 1.08,      // Euro: ~0.92 EUR = 1 USD
+	"GBP": 1.27,      // British Pound: ~0.79 GBP = 1 USD
+	"CHF": 1.11,      // Swiss Franc: ~0.90 CHF = 1 USD
+	"CAD": 0.74,      // Canadian Dollar: ~1.35 CAD = 1 USD
+	"AUD": 0.64,      // Australian Dollar: ~1.56 AUD = 1 USD
+	"SEK": 0.094,     // Swedish Krona: ~10.6 SEK = 1 USD
+	"NOK": 0.092,     // Norwegian Krone: ~10.9 NOK = 1 USD
+	"DKK": 0.145,     // Danish Krone: ~6.9 DKK = 1 USD
+	"SGD": 0.74,      // Singapore Dollar: ~1.35 SGD = 1 USD
+}
+
+// countryCurrencies maps ISO country codes to their trading currency
+var countryCurrencies = map[string]string{
+	"US": "USD", "CA": "CAD", "GB": "GBP", "AU": "AUD", "NZ": "NZD",
+	"DE": "EUR", "FR": "EUR", "IT": "EUR", "ES": "EUR", "NL": "EUR",
+	"BE": "EUR", "AT": "EUR", "FI": "EUR", "IE": "EUR", "PT": "EUR",
+	"JP": "JPY", "CN": "CNY", "HK": "HKD", "SG": "SGD", "KR": "KRW",
+	"IN": "INR", "TH": "THB", "MY": "MYR", "ID": "IDR", "PH": "PHP",
+	"VN": "VND", "TW": "TWD", "CH": "CHF", "SE": "SEK", "NO": "NOK",
+	"DK": "DKK", "BR": "BRL", "MX": "MXN", "AR": "ARS", "CL": "CLP",
+	"CO": "COP", "PE": "PEN", "ZA": "ZAR", "EG": "EGP", "SA": "SAR",
+	"AE": "AED", "IL": "ILS", "TR": "TRY",
+}
+
+// subUnits maps the currency codes FMP reports for prices quoted in minor
+// units to the ISO currency; these are case-sensitive ("GBp" is pence, "GBP"
+// pounds)
+var subUnits = map[string]string{
+	"GBp": "GBP", "GBX": "GBP", // pence
+	"ZAc": "ZAR", "ZAC": "ZAR", // South African cents
+	"ILA": "ILS", "ILa": "ILS", // agorot
+}
+
+// ParseQuoteCurrency splits a quote currency code into its ISO currency and
+// the number of quoted units per currency unit: 100 for sub-unit codes such
+// as "GBp", else 1
+func ParseQuoteCurrency(code string) (string, float64) {
+	code = strings.TrimSpace(code)
+	if currency, ok := subUnits[code]; ok {
+		return currency, 100
+	}
+	return strings.ToUpper(code), 1
+}
+
+// FallbackRate returns the hardcoded USD rate for a currency
+func FallbackRate(currency string) (float64, bool) {
+	if currency == "USD" {
+		return 1.0, true
+	}
+	rate, ok := FallbackRates[currency]
+	return rate, ok
+}
+
+// CurrencyForCountry returns the trading currency of a country, defaulting to USD
+func CurrencyForCountry(country string) string {
+	if currency, exists := countryCurrencies[country]; exists {
+		return currency
+	}
+	return "USD"
+}
+
+// DetectCurrency guesses the quote currency from the symbol suffix, falling back to the country
+func DetectCurrency(symbol, country string) string {
+	// First check by exchange suffix or symbol pattern
+	symbolUpper := strings.ToUpper(symbol)
+	if strings.HasSuffix(symbolUpper, ".JO") || strings.Contains(symbolUpper, ".JNB") {
+		return "ZAR" // South African Rand for Johannesburg Stock Exchange
+	}
+	if strings.HasSuffix(symbolUpper, ".HK") || strings.Contains(symbolUpper, ".HKSE") {
+		return "HKD" // Hong Kong Dollar
+	}
+	if strings.HasSuffix(symbolUpper, ".SR") || strings.Contains(symbolUpper, ".SAU") {
+		return "SAR" // Saudi Riyal
+	}
+	if strings.HasSuffix(symbolUpper, ".KS") || strings.HasSuffix(symbolUpper, ".KQ") {
+		return "KRW" // Korean Won
+	}
+	if strings.HasSuffix(symbolUpper, ".T") {
+		return "JPY" // Japanese Yen
+	}
+	if strings.HasSuffix(symbolUpper, ".L") || strings.HasSuffix(symbolUpper, ".LSE") {
+		return "GBP" // British Pound for London Stock Exchange
+	}
+	if strings.HasSuffix(symbolUpper, ".TA") || strings.HasSuffix(symbolUpper, ".TLV") {
+		return "ILS" // Israeli Shekel
+	}
+
+	// Currency mapping based on country (fallback)
+	return CurrencyForCountry(country)
+}
@@ -0,0 +1,178 @@
+// Package gauth gets OAuth access tokens for a Google service account, so the
+// collectors can call Google APIs such as Sheets without the SDK.
+package gauth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTokenURI is Google's OAuth token endpoint
+const DefaultTokenURI = "https://oauth2.googleapis.com/token"
+
+// tokenLifetime is how long a requested token is valid; Google allows at most an hour
+const tokenLifetime = time.Hour
+
+// ServiceAccount is the part of a service account JSON key needed to sign
+// token requests
+type ServiceAccount struct {
+	Type        string `json:"type"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+
+	key *rsa.PrivateKey
+}
+
+// LoadServiceAccount reads a service account JSON key file
+func LoadServiceAccount(path string) (*ServiceAccount, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account key: %w", err)
+	}
+	var a ServiceAccount
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("invalid service account key %s: %w", path, err)
+	}
+	if a.Type != "" && a.Type != "service_account" {
+		return nil, fmt.Errorf("%s is a %q key, want a service account", path, a.Type)
+	}
+	if a.ClientEmail == "" || a.PrivateKey == "" {
+		return nil, fmt.Errorf("service account key %s lacks client_email or private_key", path)
+	}
+	if a.TokenURI == "" {
+		a.TokenURI = DefaultTokenURI
+	}
+	if a.key, err = parsePrivateKey(a.PrivateKey); err != nil {
+		return nil, fmt.Errorf("invalid private key in %s: %w", path, err)
+	}
+	return &a, nil
+}
+
+// ServiceAccountFromEnv loads the key named by GOOGLE_APPLICATION_CREDENTIALS
+func ServiceAccountFromEnv() (*ServiceAccount, error) {
+	path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if path == "" {
+		return nil, errors.New("GOOGLE_APPLICATION_CREDENTIALS must name a service account key file")
+	}
+	return LoadServiceAccount(path)
+}
+
+func parsePrivateKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("no PEM block")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("not an RSA key")
+		}
+		return rsaKey, nil
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+// TokenSource hands out access tokens for the account's scopes, requesting a
+// new one shortly before the current one expires
+type TokenSource struct {
+	Account *ServiceAccount
+	Scopes  []string
+	Client  *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// TokenSource returns a source of tokens for scopes
+func (a *ServiceAccount) TokenSource(client *http.Client, scopes ...string) *TokenSource {
+	return &TokenSource{Account: a, Scopes: scopes, Client: client}
+}
+
+// Token returns a valid access token
+func (t *TokenSource) Token() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" && time.Until(t.expires) > time.Minute {
+		return t.token, nil
+	}
+
+	now := time.Now()
+	assertion, err := t.Account.assertion(t.Scopes, now)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	client := t.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.PostForm(t.Account.TokenURI, form)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request failed: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("invalid token response: %s", strings.TrimSpace(string(body)))
+	}
+	t.token = token.AccessToken
+	t.expires = now.Add(time.Duration(token.ExpiresIn) * time.Second)
+	return t.token, nil
+}
+
+// assertion is the signed JWT exchanged for an access token
+func (a *ServiceAccount) assertion(scopes []string, now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss":   a.ClientEmail,
+		"scope": strings.Join(scopes, " "),
+		"aud":   a.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(tokenLifetime).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token request: %w", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
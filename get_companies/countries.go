@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"algotradar/marketdata"
+	"algotradar/metrics"
+)
+
+// CountryConfig is one market the collector covers. Every market goes through
+// the same screener, filter, dedup and FX path; only these settings differ.
+type CountryConfig struct {
+	Code     string // ISO 3166 alpha-2 code, the screener's country parameter
+	Name     string
+	PageSize int // screener page size; Screen pages until the market is exhausted
+}
+
+// defaultPageSize is the page size of markets not in DefaultCountries
+const defaultPageSize = 200
+
+// DefaultCountries are the markets collected unless --countries narrows them.
+// All use the same US dollar market cap floor unless --min-market-cap-map
+// gives one its own.
+var DefaultCountries = []CountryConfig{
+	{"US", "United States", 5000},
+	{"HK", "Hong Kong", 2000},
+	{"CN", "China", 2000},
+	{"JP", "Japan", 2000},
+	{"IN", "India", 2000},
+	{"GB", "United Kingdom", 1000},
+	{"CA", "Canada", 1000},
+	{"AU", "Australia", 1000},
+	{"KR", "South Korea", 1000},
+	{"DE", "Germany", 1000},
+	{"FR", "France", 1000},
+	{"BR", "Brazil", 1000},
+	{"SA", "Saudi Arabia", 1000},
+	{"TW", "Taiwan", 500},
+	{"IT", "Italy", 500},
+	{"ES", "Spain", 500},
+	{"NL", "Netherlands", 500},
+	{"CH", "Switzerland", 500},
+	{"SG", "Singapore", 500},
+	{"ZA", "South Africa", 500},
+	{"MX", "Mexico", 500},
+	{"AE", "UAE", 500},
+	{"SE", "Sweden", 500},
+	{"NO", "Norway", 200},
+	{"DK", "Denmark", 200},
+	{"FI", "Finland", 200},
+	{"TH", "Thailand", 200},
+	{"MY", "Malaysia", 200},
+	{"ID", "Indonesia", 200},
+	{"PH", "Philippines", 200},
+	{"VN", "Vietnam", 200},
+	{"EG", "Egypt", 100},
+	{"TR", "Turkey", 200},
+	{"CL", "Chile", 100},
+	{"CO", "Colombia", 100},
+	{"PE", "Peru", 100},
+	{"AR", "Argentina", 100},
+	{"IL", "Israel", 500},
+}
+
+// countryAliases are codes people use that are not ISO 3166
+var countryAliases = map[string]string{"UK": "GB", "EL": "GR"}
+
+// ParseCountries resolves a comma-separated list of country codes. Known
+// markets keep their DefaultCountries settings; others get defaultPageSize.
+// An empty list means every default market.
+func ParseCountries(list string) ([]CountryConfig, error) {
+	if strings.TrimSpace(list) == "" {
+		return DefaultCountries, nil
+	}
+	known := make(map[string]CountryConfig, len(DefaultCountries))
+	for _, cfg := range DefaultCountries {
+		known[cfg.Code] = cfg
+	}
+
+	var countries []CountryConfig
+	seen := make(map[string]bool)
+	for _, code := range strings.Split(list, ",") {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if alias, ok := countryAliases[code]; ok {
+			code = alias
+		}
+		if len(code) != 2 {
+			return nil, fmt.Errorf("invalid country code %q: want an ISO 3166 alpha-2 code such as GB", code)
+		}
+		if seen[code] {
+			continue
+		}
+		seen[code] = true
+		cfg, ok := known[code]
+		if !ok {
+			cfg = CountryConfig{Code: code, Name: code, PageSize: defaultPageSize}
+		}
+		countries = append(countries, cfg)
+	}
+	return countries, nil
+}
+
+// LoadMinMarketCaps reads a JSON object of US dollar screen floors keyed by
+// country code, e.g. {"US": 2e9, "EG": 20e6}
+func LoadMinMarketCaps(path string) (map[string]float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read market cap map: %w", err)
+	}
+	var raw map[string]float64
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse market cap map: %w", err)
+	}
+	floors := make(map[string]float64, len(raw))
+	for code, floor := range raw {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if alias, ok := countryAliases[code]; ok {
+			code = alias
+		}
+		if len(code) != 2 {
+			return nil, fmt.Errorf("market cap map: invalid country code %q", code)
+		}
+		if floor <= 0 {
+			return nil, fmt.Errorf("market cap map: floor of %s must be positive", code)
+		}
+		floors[code] = floor
+	}
+	return floors, nil
+}
+
+// CollectCountry fetches every company above the market's floor the screener
+// lists for one market.
+// On error the companies fetched before it are returned with a
+// *marketdata.CountryError, which is also recorded in the run report.
+func (c *FMPClient) CollectCountry(cfg CountryConfig, logger *slog.Logger) ([]FMPStockScreener, error) {
+	logger.Debug("fetching screener", "desc", cfg.Name)
+
+	start := time.Now()
+	stocks, pages, err := c.data().Screen(cfg.Code, cfg.PageSize, c.minMarketCap(cfg.Code))
+	metrics.CountryDuration.Observe(time.Since(start).Seconds(), cfg.Code)
+	if err != nil {
+		logger.Warn("failed to fetch screener", "pages", pages, "partial", len(stocks),
+			"kind", marketdata.Classify(err), "error", err)
+		err = &marketdata.CountryError{Country: cfg.Code, Partial: len(stocks), Err: err}
+		c.Report.Error(err)
+		if len(stocks) == 0 {
+			return nil, err
+		}
+	}
+	logger.Info("received screener results", "count", len(stocks), "pages", pages)
+
+	// Debug: Check for major stocks in specific countries
+	saStocksFound := 0
+	hkStocksFound := 0
+	for _, stock := range stocks {
+		// Check for Saudi Arabia stocks
+		if stock.Country == "SA" || stock.ExchangeShortName == "SAU" || strings.Contains(stock.Exchange, "Saudi") {
+			saStocksFound++
+			if saStocksFound <= 3 {
+				logger.Debug("found Saudi Arabia stock", "symbol", stock.Symbol,
+					"name", stock.CompanyName, "market_cap", stock.MarketCap)
+			}
+		}
+
+		// Check for Hong Kong stocks
+		if strings.HasSuffix(strings.ToUpper(stock.Symbol), ".HK") || stock.Country == "HK" {
+			hkStocksFound++
+			if hkStocksFound <= 3 && strings.Contains(strings.ToUpper(stock.CompanyName), "TENCENT") {
+				logger.Debug("found HK Tencent", "symbol", stock.Symbol, "market_cap", stock.MarketCap)
+			}
+		}
+	}
+
+	if saStocksFound > 0 {
+		logger.Debug("Saudi Arabia stocks found", "count", saStocksFound)
+	}
+	if hkStocksFound > 0 && cfg.Code == "HK" {
+		logger.Debug("Hong Kong stocks found", "count", hkStocksFound)
+	}
+	return stocks, err
+}
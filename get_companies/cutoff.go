@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math"
+
+	"algotradar/domain"
+	"algotradar/spool"
+)
+
+// dropRankCutoff is the run report's reason for rows past a Cutoff
+const dropRankCutoff = "rank_cutoff"
+
+// Cutoff trims the ranking to its largest companies by USD market cap, after
+// conversion and share-class aggregation, rather than by an absolute floor.
+// The ranking orders equal market caps by ticker, so ties are cut the same
+// way on every run. TopPerCountry applies first; Top and TopPercentile then
+// count the rows it keeps.
+type Cutoff struct {
+	// Top keeps the Top largest companies; 0 means no limit
+	Top int
+
+	// TopPercentile keeps the largest TopPercentile percent of the ranked
+	// companies, at least one; 0 means no limit
+	TopPercentile float64
+
+	// TopPerCountry keeps the TopPerCountry largest companies of each
+	// country; 0 means no limit
+	TopPerCountry int
+}
+
+// Empty reports whether the cutoff keeps every row
+func (c Cutoff) Empty() bool {
+	return c.Top == 0 && c.TopPercentile == 0 && c.TopPerCountry == 0
+}
+
+// keep returns how many of n ranked rows the Top and TopPercentile limits
+// keep
+func (c Cutoff) keep(n int) int {
+	kept := n
+	if c.Top > 0 && c.Top < kept {
+		kept = c.Top
+	}
+	if c.TopPercentile > 0 {
+		if top := max(1, int(math.Ceil(float64(n)*c.TopPercentile/100))); top < kept {
+			kept = top
+		}
+	}
+	return kept
+}
+
+// applyCutoff replays the ranking into a new spool holding the rows within
+// c.Cutoff and closes the old one; the rest are dropped as rank_cutoff
+func (c *FMPClient) applyCutoff(ranked *spool.Spool) (*spool.Spool, error) {
+	defer ranked.Close()
+
+	// withinCountry counts a row against its country's limit and reports
+	// whether it is within it
+	var perCountry map[string]int
+	withinCountry := func(asset domain.Asset) bool {
+		perCountry[asset.Country]++
+		return c.Cutoff.TopPerCountry == 0 || perCountry[asset.Country] <= c.Cutoff.TopPerCountry
+	}
+
+	// The first pass counts the rows the per-country limit keeps, which the
+	// percentile is taken of
+	perCountry = make(map[string]int)
+	eligible := 0
+	err := ranked.Each(func(asset domain.Asset) error {
+		if withinCountry(asset) {
+			eligible++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	keep := c.Cutoff.keep(eligible)
+	cut := spool.New(spoolRows)
+	perCountry = make(map[string]int)
+	err = ranked.Each(func(asset domain.Asset) error {
+		if !withinCountry(asset) || cut.Len() >= keep {
+			c.Report.Drop(asset.Ticker, asset.Country, dropRankCutoff)
+			return nil
+		}
+		return cut.Add(asset)
+	})
+	if err != nil {
+		cut.Close()
+		return nil, err
+	}
+	c.Logger.Info("applied rank cutoff", "top", c.Cutoff.Top, "top_percentile", c.Cutoff.TopPercentile,
+		"top_per_country", c.Cutoff.TopPerCountry, "kept", cut.Len(), "cut", ranked.Len()-cut.Len())
+	return cut, nil
+}
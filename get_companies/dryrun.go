@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"algotradar/enrich"
+	"algotradar/ratelimit"
+)
+
+const (
+	// logoCompanies is roughly how many companies worldwide are above the
+	// default market cap from which the logo stage looks up a logo
+	logoCompanies = 1500
+	// assumedLatency is a typical FMP response time, used for the fastest the
+	// workers can go when the plan's rate limit is not the bottleneck
+	assumedLatency = 250 * time.Millisecond
+	// workerPause is the sleep each screener and symbol worker takes per item
+	workerPause = 50 * time.Millisecond
+	// sharesFloatPages is an allowance for the pages of the bulk shares-float
+	// endpoint, which lists every company FMP covers whatever the markets
+	sharesFloatPages = 30
+)
+
+// Estimate is the API usage a run is expected to need, by stage
+type Estimate struct {
+	Markets   int
+	Listings  int // screener rows, assuming each market fills about one page
+	Screeners int
+	Profiles  int
+	FX        int
+	Quotes    int
+	Images    int
+	Shares    int // pages of the bulk shares-float endpoint
+	Changes   int // price change batches of the technicals enrichment
+	Holders   int // per-symbol 13F lookups of the ownership enrichment
+	Cashflows int // per-REIT cash flow lookups of the reit enrichment
+}
+
+// Total is the number of API calls across all stages
+func (e Estimate) Total() int {
+	return e.Screeners + e.Profiles + e.FX + e.Quotes + e.Images + e.Shares + e.Changes + e.Holders + e.Cashflows
+}
+
+// Floor is how long the run takes at the collector's own concurrency, however
+// generous the plan
+func (e Estimate) Floor() time.Duration {
+	perCall := assumedLatency + workerPause
+	floor := time.Duration(e.Screeners/countryWorkers+1) * perCall
+	floor += time.Duration(e.Profiles/profileWorkers+1) * assumedLatency
+	floor += assumedLatency // exchange rates are fetched all at once
+	floor += time.Duration(e.Quotes/symbolWorkers+1) * perCall
+	floor += time.Duration(e.Images/profileWorkers+1) * assumedLatency
+	floor += time.Duration(e.Shares) * assumedLatency // pages are read one after another
+	floor += time.Duration(e.Changes/profileWorkers+1) * assumedLatency
+	floor += time.Duration(e.Holders/symbolWorkers+1) * assumedLatency
+	floor += time.Duration(e.Cashflows/symbolWorkers+1) * assumedLatency
+	return floor
+}
+
+// EstimateRun walks the configured markets and enrichments without calling
+// the API. Each market is assumed to list about one screener page of 50M+
+// companies, so Screen needs that page plus a short one to see the end;
+// every listing is assumed to survive filtering and dedup, which makes the
+// estimate an upper bound.
+func (c *FMPClient) EstimateRun() Estimate {
+	countries := c.Countries
+	if len(countries) == 0 {
+		countries = DefaultCountries
+	}
+
+	e := Estimate{Markets: len(countries), FX: len(prefetchCurrencies)}
+	if len(c.Symbols) > 0 {
+		// A symbols file replaces the screens with its symbols' profiles
+		e.Markets, e.Listings = 0, len(c.Symbols)
+		e.Profiles = (e.Listings + profileBatchSize - 1) / profileBatchSize
+	} else {
+		for _, cfg := range countries {
+			e.Listings += cfg.PageSize
+			e.Screeners += 2
+		}
+		if c.Identifiers {
+			e.Profiles = (e.Listings + profileBatchSize - 1) / profileBatchSize
+		}
+	}
+	if c.SharesFloat {
+		e.Shares = sharesFloatPages
+	}
+
+	e.Quotes = e.Listings
+	if c.Limit > 0 {
+		e.Quotes = min(e.Quotes, c.Limit)
+	}
+	if c.Enrichments[enrich.Technicals] {
+		e.Changes = (e.Quotes + priceChangeBatchSize - 1) / priceChangeBatchSize
+	}
+	if c.Enrichments[enrich.Ownership] {
+		e.Holders = e.Quotes
+	}
+	// Which rows are REITs is only known after the screens, so every one is
+	// counted, as for the other per-symbol stages
+	if c.Enrichments[enrich.REIT] {
+		e.Cashflows = e.Quotes
+	}
+	// Logos come from the batched profiles when identifiers are on or a
+	// symbols file is read, and otherwise from batches of their own
+	if !c.Identifiers && len(c.Symbols) == 0 {
+		e.Images = (min(e.Quotes, logoCompanies) + profileBatchSize - 1) / profileBatchSize
+	}
+	return e
+}
+
+// printDryRun writes the estimate and how each FMP plan copes with it
+func printDryRun(w io.Writer, e Estimate, limit int, identifiers bool) {
+	if e.Markets == 0 {
+		fmt.Fprintf(w, "Dry run: %d symbols from a symbols file\n\n", e.Listings)
+	} else {
+		fmt.Fprintf(w, "Dry run: %d markets, ~%d screener rows, identifiers=%t, limit=%d\n\n", e.Markets, e.Listings, identifiers, limit)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STAGE\tCALLS")
+	for _, stage := range []struct {
+		name  string
+		calls int
+	}{
+		{"screeners", e.Screeners},
+		{"profiles", e.Profiles},
+		{"fx", e.FX},
+		{"quotes", e.Quotes},
+		{"logos", e.Images},
+		{"shares float", e.Shares},
+		{"price changes", e.Changes},
+		{"13f holders", e.Holders},
+		{"cash flows", e.Cashflows},
+		{"total", e.Total()},
+	} {
+		fmt.Fprintf(tw, "%s\t%d\n", stage.name, stage.calls)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PLAN\tPER MINUTE\tPER DAY\tFITS\tEST. DURATION")
+	floor := e.Floor()
+	for _, plan := range ratelimit.Plans {
+		perMinute, perDay, duration := "-", "-", "-"
+		if plan.PerMinute > 0 {
+			perMinute = fmt.Sprint(plan.PerMinute)
+		}
+		if plan.PerDay > 0 {
+			perDay = fmt.Sprint(plan.PerDay)
+		}
+		fits := plan.Fits(e.Total())
+		if fits {
+			duration = max(plan.Duration(e.Total()), floor).Round(time.Second).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", plan.Name, perMinute, perDay, yesNo(fits), duration)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	if plan, ok := ratelimit.RequiredPlan(e.Total()); ok {
+		fmt.Fprintf(w, "Required plan: %s or higher\n", plan.Name)
+	} else {
+		fmt.Fprintln(w, "No plan covers this run in one day; narrow it with --countries or --limit")
+	}
+	fmt.Fprintln(w, "Cached responses (--cache-dir) are not counted, so a warm cache needs fewer calls.")
+}
+
+func yesNo(ok bool) string {
+	if ok {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"algotradar/domain"
+	"algotradar/output"
+	"algotradar/runreport"
+	"algotradar/sink"
+	"algotradar/spool"
+)
+
+// What a run does when a country falls short of its row gate
+const (
+	GatePolicyFail     = "fail"
+	GatePolicyPrevious = "previous"
+)
+
+// RowGates are the fewest rows each country is expected to return. A country
+// below its gate most likely hit an API hiccup rather than lost listings.
+type RowGates map[string]int
+
+// ParseRowGates parses a spec such as "US=2000,JP=800"
+func ParseRowGates(spec string) (RowGates, error) {
+	gates := make(RowGates)
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		code, raw, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid row gate %q, want COUNTRY=ROWS", pair)
+		}
+		code = strings.ToUpper(strings.TrimSpace(code))
+		if alias, ok := countryAliases[code]; ok {
+			code = alias
+		}
+		if len(code) != 2 {
+			return nil, fmt.Errorf("row gate: invalid country code %q", code)
+		}
+		rows, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil || rows < 1 {
+			return nil, fmt.Errorf("row gate of %s must be a positive row count, got %q", code, raw)
+		}
+		gates[code] = rows
+	}
+	return gates, nil
+}
+
+// Short returns the countries whose row counts are below their gates, sorted
+func (g RowGates) Short(counts map[string]int) []string {
+	var short []string
+	for country, rows := range g {
+		if counts[country] < rows {
+			short = append(short, country)
+		}
+	}
+	sort.Strings(short)
+	return short
+}
+
+// countRows tallies the ranking's rows by country
+func countRows(ranked *spool.Spool) (map[string]int, error) {
+	counts := make(map[string]int)
+	err := ranked.Each(func(asset domain.Asset) error {
+		counts[asset.Country]++
+		return nil
+	})
+	return counts, err
+}
+
+// readSnapshot reads a JSON snapshot written by a previous run
+func readSnapshot(path string) ([]domain.Asset, error) {
+	data, err := output.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read previous snapshot: %w", err)
+	}
+	var assets []domain.Asset
+	if err := json.Unmarshal(data, &assets); err != nil {
+		return nil, fmt.Errorf("failed to parse previous snapshot %s: %w", path, err)
+	}
+	return assets, nil
+}
+
+// readPrevious reads the previous snapshot at path and returns its rows with
+// the file each short country is taken from. A path containing {country}
+// names one file per country, as a sink split by country writes them; each
+// is resolved like the sink's output, and a country without a file has no
+// previous rows.
+func readPrevious(path string, short []string) ([]domain.Asset, map[string]string, error) {
+	files := make(map[string]string, len(short))
+	if !strings.Contains(path, sink.CountryPlaceholder) {
+		for _, country := range short {
+			files[country] = path
+		}
+		previous, err := readSnapshot(path)
+		return previous, files, err
+	}
+
+	var previous []domain.Asset
+	for _, country := range short {
+		file := output.Resolve(strings.ReplaceAll(path, sink.CountryPlaceholder, country))
+		files[country] = file
+		rows, err := readSnapshot(file)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		previous = append(previous, rows...)
+	}
+	return previous, files, nil
+}
+
+// replaceCountries returns a new ranking in which the rows of countries come
+// from previous instead of ranked, which it closes. Symbols already ranked
+// under another country are not added twice.
+func replaceCountries(ranked *spool.Spool, countries []string, previous []domain.Asset) (*spool.Spool, error) {
+	replaced := make(map[string]bool, len(countries))
+	for _, country := range countries {
+		replaced[country] = true
+	}
+
+	merged := spool.New(spoolRows)
+	seen := make(map[string]bool)
+	err := ranked.Each(func(asset domain.Asset) error {
+		if replaced[asset.Country] {
+			return nil
+		}
+		seen[asset.Ticker] = true
+		return merged.Add(asset)
+	})
+	for _, asset := range previous {
+		if err != nil {
+			break
+		}
+		if replaced[asset.Country] && !seen[asset.Ticker] {
+			seen[asset.Ticker] = true
+			err = merged.Add(asset)
+		}
+	}
+	ranked.Close()
+	if err != nil {
+		merged.Close()
+		return nil, err
+	}
+	return merged, nil
+}
+
+// fallBackToPrevious publishes the short countries from the previous
+// snapshot. It fails when the snapshot cannot be read or holds no more rows
+// of a country than the run fetched, since falling back would not help.
+func fallBackToPrevious(ranked *Collection, short []string, counts map[string]int, gates RowGates, path string, report *runreport.Recorder, logger *slog.Logger) error {
+	previous, files, err := readPrevious(path, short)
+	if err != nil {
+		return err
+	}
+	previousCounts := make(map[string]int)
+	for _, asset := range previous {
+		previousCounts[asset.Country]++
+	}
+	for _, country := range short {
+		if previousCounts[country] <= counts[country] {
+			return fmt.Errorf("%s has %d rows, expected at least %d, and the previous snapshot %s has %d",
+				country, counts[country], gates[country], files[country], previousCounts[country])
+		}
+	}
+
+	merged, err := replaceCountries(ranked.Spool, short, previous)
+	if err != nil {
+		return err
+	}
+	ranked.Spool = merged
+	for _, country := range short {
+		logger.Warn("publishing country from the previous snapshot", "country", country,
+			"rows", counts[country], "previous_rows", previousCounts[country], "snapshot", files[country])
+		report.Fallback(domain.CountryFallback{
+			Country:  country,
+			Rows:     counts[country],
+			Expected: gates[country],
+			Used:     previousCounts[country],
+			Snapshot: files[country],
+		})
+	}
+	return nil
+}
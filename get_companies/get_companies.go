@@ -1,136 +1,413 @@
 package main
 
 import (
-	"encoding/csv"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"maps"
+	"math"
+	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/joho/godotenv"
+	"google.golang.org/grpc"
+
+	"algotradar/aggregate"
+	"algotradar/api"
+	"algotradar/apikeys"
+	"algotradar/assetsrpc"
+	"algotradar/domain"
+	"algotradar/enrich"
+	"algotradar/exclusion"
+	"algotradar/fx"
+	"algotradar/httpcache"
+	"algotradar/instrument"
+	"algotradar/integrity"
+	"algotradar/logging"
+	"algotradar/logos"
+	"algotradar/marketdata"
+	"algotradar/metrics"
+	"algotradar/notify"
+	"algotradar/output"
+	"algotradar/progress"
+	"algotradar/ratelimit"
+	"algotradar/refdata"
+	"algotradar/registry"
+	"algotradar/replay"
+	"algotradar/runreport"
+	"algotradar/secrets"
+	"algotradar/sink"
+	"algotradar/spool"
+	"algotradar/taxonomy"
+	"algotradar/watchlist"
+	"algotradar/workpool"
 )
 
-// FMP API structures
-type FMPStockScreener struct {
-	Symbol            string  `json:"symbol"`
-	CompanyName       string  `json:"companyName"`
-	MarketCap         float64 `json:"marketCap"`
-	Sector            string  `json:"sector"`
-	Industry          string  `json:"industry"`
-	Beta              float64 `json:"beta"`
-	Price             float64 `json:"price"`
-	Volume            float64 `json:"volume"`
-	Exchange          string  `json:"exchange"`
-	ExchangeShortName string  `json:"exchangeShortName"`
-	Country           string  `json:"country"`
-	IsEtf             bool    `json:"isEtf"`
-	IsActivelyTrading bool    `json:"isActivelyTrading"`
-}
+// FMPStockScreener is one stock screener row; other providers' screens
+// come back in the same shape
+type FMPStockScreener = marketdata.Listing
 
-type FMPQuote struct {
-	Symbol            string  `json:"symbol"`
-	Name              string  `json:"name"`
-	Price             float64 `json:"price"`
-	ChangesPercentage float64 `json:"changesPercentage"`
-	Change            float64 `json:"change"`
-	MarketCap         float64 `json:"marketCap"`
-	Volume            float64 `json:"volume"`
-	Open              float64 `json:"open"`
-	PreviousClose     float64 `json:"previousClose"`
-	Exchange          string  `json:"exchange"`
-	SharesOutstanding float64 `json:"sharesOutstanding"`
-}
+type FMPClient struct {
+	Keys       *apikeys.Pool
+	BaseURL    string
+	HTTPClient *http.Client
+	Logger     *slog.Logger
+	Report     *runreport.Recorder
 
-type FMPCompanyProfile struct {
-	Symbol      string  `json:"symbol"`
-	CompanyName string  `json:"companyName"`
-	Image       string  `json:"image"`
-	Price       float64 `json:"price"`
-	Beta        float64 `json:"beta"`
-	VolAvg      float64 `json:"volAvg"`
-	MktCap      float64 `json:"mktCap"`
-	Industry    string  `json:"industry"`
-	Sector      string  `json:"sector"`
-	Country     string  `json:"country"`
-	Exchange    string  `json:"exchange"`
-	Website     string  `json:"website"`
-	Description string  `json:"description"`
-}
+	// Data is the market data provider the stages read from; nil means FMP
+	// through this client
+	Data marketdata.Provider
+
+	// Fallback, when set, fills quotes and profiles Data has none for; rows
+	// it filled carry its name as their data source
+	Fallback marketdata.Provider
+
+	// FairSchedule interleaves symbols across countries in the symbol stage so
+	// large markets do not hold every worker while small ones wait
+	FairSchedule bool
 
-type AssetData struct {
-	Ticker           string  `json:"ticker"`
-	Name             string  `json:"name"`
-	MarketCap        float64 `json:"market_cap"`
-	CurrentPrice     float64 `json:"current_price"`
-	PreviousClose    float64 `json:"previous_close"`
-	PercentageChange float64 `json:"percentage_change"`
-	Volume           float64 `json:"volume"`
-	PrimaryExchange  string  `json:"primary_exchange"`
-	Country          string  `json:"country"`
-	Sector           string  `json:"sector"`
-	Industry         string  `json:"industry"`
-	AssetType        string  `json:"asset_type"`
-	Image            string  `json:"image"`
+	// Countries are the markets to collect; empty means DefaultCountries
+	Countries []CountryConfig
+
+	// Symbols, when set, are collected instead of the country screens'
+	// listings (see symbolListings)
+	Symbols []string
+
+	// MinMarketCap is the US dollar floor of the screens, 0 meaning
+	// marketdata.MinMarketCap; CountryMinMarketCaps overrides it per country
+	MinMarketCap         float64
+	CountryMinMarketCaps map[string]float64
+
+	// Limit caps the symbol stage at the largest Limit issuers; 0 means no cap
+	Limit int
+
+	// Cutoff trims the final ranking to its largest companies by USD market
+	// cap
+	Cutoff Cutoff
+
+	// CapTiers are the boundaries of the rows' cap_tier; zero means
+	// domain.DefaultCapTiers
+	CapTiers domain.CapTiers
+
+	// Identifiers fetches ISIN/CIK from batched profile lookups before
+	// deduplication, so cross-listings merge by issuer rather than by name
+	Identifiers bool
+
+	// SharesFloat fetches share counts from the bulk shares-float endpoint
+	// before the symbol stage, for the rows' float and free-float market cap
+	SharesFloat bool
+
+	// Enrichments are the optional enrichments to perform, e.g.
+	// enrich.Technicals
+	Enrichments enrich.Set
+
+	// AssetClass restricts the collection to one instrument type, e.g.
+	// instrument.REIT; empty collects every company
+	AssetClass string
+
+	// Sectors restricts the collection to some sectors and industries, by
+	// the screener's labels or their normalized names
+	Sectors taxonomy.Filter
+
+	// SPACs is how blank-check companies are treated: tagged, dropped or
+	// kept as ordinary stocks
+	SPACs instrument.SPACPolicy
+
+	// AggregateShareClasses folds an issuer's share classes, such as GOOG
+	// and GOOGL, into one row with their summed market cap instead of
+	// keeping the primary class alone
+	AggregateShareClasses bool
+
+	// SnapshotTime is when each row's IsMarketOpen is evaluated, so the whole
+	// snapshot agrees on which venues were trading
+	SnapshotTime time.Time
+
+	// FXDate, when set, is the past day whose closing exchange rates market
+	// caps and screen floors are converted at, instead of the current ones
+	FXDate time.Time
+
+	// historicalRates memoizes historicalRate by currency, failed lookups
+	// included as a zero rate
+	historicalMu    sync.Mutex
+	historicalRates map[string]domain.FXRate
+
+	// RecentIPODays is how many days after its profile's IPO date a row
+	// counts as a recent IPO; 0 means domain.RecentIPODays
+	RecentIPODays int
+
+	// Profiles fetched in batch, reused for logos in the symbol stage;
+	// fallbackProfiles marks those the Fallback provider supplied
+	profilesMu       sync.RWMutex
+	profiles         map[string]domain.Profile
+	fallbackProfiles map[string]bool
+
+	// floats are the share counts fetched by fetchSharesFloat, read-only
+	// once the symbol stage starts
+	floats map[string]domain.SharesFloat
+
+	// ytd are the year-to-date returns fetched by fetchPriceChanges for the
+	// technicals enrichment, read-only once the symbol stage starts
+	ytd map[string]float64
+
+	// holders are the 13F holders fetched by fetchOwnership for the
+	// ownership enrichment, read-only once the symbol stage starts
+	holders map[string][]domain.InstitutionalHolder
+
+	// ffo is the trailing FFO fetched by fetchFFO for the reit enrichment,
+	// read-only once the symbol stage starts
+	ffo map[string]domain.FFO
+
+	// adrs holds the kept listings that are depositary receipts, i.e. whose
+	// issuer has no ordinary listing in the screener results
+	adrs map[string]bool
+
+	// classOf maps the listings of issuers with several kept share classes
+	// to the issuer's primary symbol
+	classOf map[string]string
+
+	// Quote and profile lookups of the symbol stage, at most one per symbol
+	lookupsOnce sync.Once
+	lookups     *lookupGroup
+
+	// Profile lookups are deferred once the API quota runs out
+	quotaExhausted atomic.Bool
+	deferredMu     sync.Mutex
+	deferred       []string
+
+	// Symbol-stage progress for the progress stream
+	symbolsDone  atomic.Int64
+	symbolsTotal int
+	countries    map[string]*countryCount
+
+	// pool is the running stage's worker pool, told about every 429 and
+	// healthy response so it can size itself to the rate limit
+	pool atomic.Pointer[workpool.Pool]
 }
 
-type FMPClient struct {
-	APIKey     string
-	BaseURL    string
-	HTTPClient *http.Client
+const (
+	countryWorkers = 12 // Fetch 12 countries in parallel for maximum speed
+	symbolWorkers  = 8  // Balanced for performance and stability
+)
+
+// prefetchCurrencies are the exchange rates fetched before the symbol stage
+var prefetchCurrencies = []string{"EUR", "GBP", "JPY", "CAD", "AUD", "CHF", "CNY", "HKD", "KRW", "INR", "BRL", "MXN", "SAR", "AED", "SGD", "SEK", "NOK", "DKK", "THB", "MYR", "IDR", "PHP", "VND", "EGP", "TRY", "CLP", "COP", "PEN", "ARS", "ILS", "ZAR", "TWD"}
+
+// progressEvery is how many symbols pass between symbols_processed events
+const progressEvery = 100
+
+// countryProgressEvery is how many of one country's symbols pass between its
+// country_progress events
+const countryProgressEvery = 25
+
+// spoolRows is how many enriched rows are held in memory before the rest are
+// ranked on disk
+const spoolRows = 5_000
+
+// countryCount is progress through one country's symbols
+type countryCount struct {
+	done  atomic.Int64
+	total int
 }
 
-func NewFMPClient(apiKey string) *FMPClient {
+// errKeyRejected marks a 401/403 response: the key is invalid, revoked or not
+// entitled to the endpoint
+var errKeyRejected = marketdata.ErrAuthFailed
+
+// NewFMPClient returns a client that rotates requests across keys
+func NewFMPClient(keys *apikeys.Pool) *FMPClient {
 	return &FMPClient{
-		APIKey:  apiKey,
+		Keys:    keys,
 		BaseURL: "https://financialmodelingprep.com/api",
 		HTTPClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		Logger: slog.Default(),
 	}
 }
 
+// makeRequest fetches endpoint, waiting out per-minute rate limits as the
+// API's Retry-After asks. A key that is rejected or out of daily quota is
+// retired and the request moves to the next key; once none is left a daily
+// limit is returned as an error wrapping enrich.ErrQuotaExceeded so callers
+// can defer the work.
 func (c *FMPClient) makeRequest(endpoint string) ([]byte, error) {
+	label := metrics.EndpointLabel(endpoint)
+	for attempt := 0; ; {
+		key, err := c.Keys.Next()
+		if err != nil {
+			return nil, err
+		}
+		body, limit, err := c.doRequest(endpoint, label, key)
+		if errors.Is(err, errKeyRejected) {
+			if c.Keys.Disable(key) {
+				c.Logger.Error("API key rejected, removing it from rotation", "key", apikeys.Mask(key), "usable", c.Keys.Usable())
+			}
+			if c.Keys.Usable() > 0 {
+				continue
+			}
+			return nil, err
+		}
+		pool := c.pool.Load()
+		if limit == nil {
+			if err == nil {
+				pool.Succeeded()
+			}
+			return body, err
+		}
+
+		metrics.APIRateLimited.Inc(label)
+		if limit.Daily {
+			if c.Keys.Exhaust(key) {
+				c.Logger.Warn("API key daily quota exhausted, removing it from rotation", "key", apikeys.Mask(key), "usable", c.Keys.Usable())
+			}
+			if c.Keys.Usable() > 0 {
+				continue
+			}
+		}
+		c.Keys.RateLimited(key)
+		if pool.Throttled() {
+			c.Logger.Warn("rate limited, reducing concurrency", "pool", pool.Name, "workers", pool.Stats().Limit)
+		}
+		if limit.Daily || attempt == ratelimit.MaxRetries {
+			progress.Publish(progress.Event{Type: progress.Error, Message: fmt.Sprintf("%s: rate limited", label)})
+			// Only an exhausted daily quota stops the run's remaining lookups;
+			// a per-minute limit that outlasted the retries fails this request
+			if limit.Daily {
+				return nil, fmt.Errorf("API request failed with status %d: %w: %w",
+					http.StatusTooManyRequests, limit.Error(attempt), enrich.ErrQuotaExceeded)
+			}
+			return nil, fmt.Errorf("API request failed with status %d: %w", http.StatusTooManyRequests, limit.Error(attempt))
+		}
+
+		wait := limit.Wait(attempt)
+		c.Logger.Debug("rate limited, retrying", "endpoint", label, "wait", wait, "attempt", attempt+1)
+		metrics.APIRetries.Inc(label)
+		time.Sleep(wait)
+		attempt++
+	}
+}
+
+// startPool returns a worker pool of up to workers goroutines and makes it the
+// one makeRequest reports rate-limit feedback to
+func (c *FMPClient) startPool(name string, workers int) *workpool.Pool {
+	pool := workpool.New(name, 1, workers)
+	c.pool.Store(pool)
+	return pool
+}
+
+// logPool logs a finished pool's effective throughput and concurrency
+func (c *FMPClient) logPool(pool *workpool.Pool) {
+	stats := pool.Stats()
+	c.Logger.Info("worker pool finished", "pool", pool.Name, "completed", stats.Completed,
+		"per_second", fmt.Sprintf("%.1f", stats.PerSecond), "peak", stats.Peak,
+		"final_limit", stats.Limit, "throttles", stats.Throttles)
+}
+
+// doRequest performs one attempt; a 429 response is returned as a non-nil limit
+func (c *FMPClient) doRequest(endpoint, label, key string) ([]byte, *ratelimit.Limit, error) {
 	separator := "?"
 	if strings.Contains(endpoint, "?") {
 		separator = "&"
 	}
-	url := fmt.Sprintf("%s%s%sapikey=%s", c.BaseURL, endpoint, separator, c.APIKey)
+	url := fmt.Sprintf("%s%s%sapikey=%s", c.BaseURL, endpoint, separator, key)
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Accept", "application/json; charset=utf-8")
 	req.Header.Set("Accept-Charset", "utf-8")
 	req.Header.Set("Content-Type", "application/json; charset=utf-8")
 
+	start := time.Now()
 	resp, err := c.HTTPClient.Do(req)
+	metrics.APIDuration.Observe(time.Since(start).Seconds(), label)
 	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
+		metrics.APICalls.Inc(label, "error")
+		progress.Publish(progress.Event{Type: progress.Error, Message: fmt.Sprintf("%s: %v", label, err)})
+		return nil, nil, fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if !httpcache.IsHit(resp) {
+		metrics.APICalls.Inc(label, strconv.Itoa(resp.StatusCode))
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		limit := ratelimit.FromResponse(resp.Header, body, time.Now())
+		return nil, &limit, nil
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if resp.StatusCode == http.StatusForbidden && marketdata.NotEntitled(body) {
+			return nil, nil, fmt.Errorf("API request failed with status %d: %w", resp.StatusCode, marketdata.ErrNotEntitled)
+		}
+		return nil, nil, fmt.Errorf("API request failed with status %d: %w", resp.StatusCode, errKeyRejected)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("FMP API Error Response: %s\n", string(body))
-		return nil, fmt.Errorf("API request failed with status %d", resp.StatusCode)
+		c.Logger.Debug("FMP API error response", "endpoint", endpoint, "status", resp.StatusCode, "body", string(body))
+		progress.Publish(progress.Event{Type: progress.Error, Message: fmt.Sprintf("%s: status %d", label, resp.StatusCode)})
+		return nil, nil, fmt.Errorf("API request failed with status %d", resp.StatusCode)
+	}
+
+	return body, nil, nil
+}
+
+// data returns the provider the collection stages read from
+func (c *FMPClient) data() marketdata.Provider {
+	if c.Data != nil {
+		return c.Data
 	}
+	return c
+}
 
-	return body, nil
+// source is the data source recorded on symbol's row: the fallback provider
+// when it supplied the profile, else the provider that screened symbol, empty
+// for FMP
+func (c *FMPClient) source(symbol string) string {
+	c.profilesMu.RLock()
+	fromFallback := c.fallbackProfiles[symbol]
+	c.profilesMu.RUnlock()
+	if fromFallback {
+		return c.Fallback.Name()
+	}
+	if c.Data == nil {
+		return ""
+	}
+	name := c.Data.Name()
+	if router, ok := c.Data.(*marketdata.Router); ok {
+		name = router.Owner(symbol).Name()
+	}
+	if name == c.Name() {
+		return ""
+	}
+	return name
 }
 
-func (c *FMPClient) GetQuote(symbol string) (*FMPQuote, error) {
+// Name identifies FMP as a marketdata.Provider
+func (c *FMPClient) Name() string { return "fmp" }
+
+// Quote returns symbol's real-time quote
+func (c *FMPClient) Quote(symbol string) (*domain.Quote, error) {
 	endpoint := fmt.Sprintf("/v3/quote/%s", symbol)
 
 	body, err := c.makeRequest(endpoint)
@@ -138,9 +415,9 @@ func (c *FMPClient) GetQuote(symbol string) (*FMPQuote, error) {
 		return nil, fmt.Errorf("failed to get quote for %s: %w", symbol, err)
 	}
 
-	var quotes []FMPQuote
+	var quotes []domain.Quote
 	if err := json.Unmarshal(body, &quotes); err != nil {
-		return nil, fmt.Errorf("failed to parse quote data for %s: %w", symbol, err)
+		return nil, &marketdata.ParseError{What: "quote data for " + symbol, Err: err}
 	}
 
 	if len(quotes) == 0 {
@@ -150,7 +427,8 @@ func (c *FMPClient) GetQuote(symbol string) (*FMPQuote, error) {
 	return &quotes[0], nil
 }
 
-func (c *FMPClient) GetCompanyProfile(symbol string) (*FMPCompanyProfile, error) {
+// Profile returns symbol's company profile
+func (c *FMPClient) Profile(symbol string) (*domain.Profile, error) {
 	endpoint := fmt.Sprintf("/v3/profile/%s", symbol)
 
 	body, err := c.makeRequest(endpoint)
@@ -158,9 +436,9 @@ func (c *FMPClient) GetCompanyProfile(symbol string) (*FMPCompanyProfile, error)
 		return nil, fmt.Errorf("failed to get company profile for %s: %w", symbol, err)
 	}
 
-	var profiles []FMPCompanyProfile
+	var profiles []domain.Profile
 	if err := json.Unmarshal(body, &profiles); err != nil {
-		return nil, fmt.Errorf("failed to parse company profile data for %s: %w", symbol, err)
+		return nil, &marketdata.ParseError{What: "company profile data for " + symbol, Err: err}
 	}
 
 	if len(profiles) == 0 {
@@ -170,164 +448,376 @@ func (c *FMPClient) GetCompanyProfile(symbol string) (*FMPCompanyProfile, error)
 	return &profiles[0], nil
 }
 
-func (c *FMPClient) GetGlobalStocks() ([]AssetData, error) {
-	fmt.Println("🌍 Fetching ALL 50M+ companies from 38 countries with USD conversion...")
-	fmt.Println("🚀 Using ENHANCED PARALLEL MULTITHREADING for maximum performance...")
+const (
+	// profileBatchSize is how many symbols one batched profile request asks for
+	profileBatchSize = 50
+	// priceChangeBatchSize is how many symbols one price change request asks for
+	priceChangeBatchSize = 50
+	// profileWorkers is how many profile batches are fetched at once
+	profileWorkers = 4
+)
 
-	var allStocks []FMPStockScreener
-	var stockMutex sync.Mutex
+// fetchProfiles looks up the profiles of symbols in batches and keeps them for
+// LookupImages. Failed batches are logged and skipped; once the daily quota
+// runs out the remaining batches are not attempted.
+func (c *FMPClient) fetchProfiles(symbols []string) {
+	var batches [][]string
+	for start := 0; start < len(symbols); start += profileBatchSize {
+		batches = append(batches, symbols[start:min(start+profileBatchSize, len(symbols))])
+	}
 
-	// STANDARDIZED 50M+ USD MARKET CAP FILTER - All countries use same threshold
-	endpoints := []struct {
-		endpoint string
-		desc     string
-	}{
-		// All countries use 50M+ USD market cap filter with generous limits to capture ALL qualifying companies
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=5000&country=US&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇺🇸 United States"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=2000&country=HK&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇭🇰 Hong Kong"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=2000&country=CN&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇨🇳 China"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=2000&country=JP&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇯🇵 Japan"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=2000&country=IN&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇮🇳 India"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=1000&country=GB&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇬🇧 United Kingdom"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=1000&country=CA&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇨🇦 Canada"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=1000&country=AU&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇦🇺 Australia"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=1000&country=KR&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇰🇷 South Korea"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=1000&country=DE&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇩🇪 Germany"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=1000&country=FR&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇫🇷 France"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=1000&country=BR&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇧🇷 Brazil"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=1000&country=SA&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇸🇦 Saudi Arabia"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=500&country=TW&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇹🇼 Taiwan"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=500&country=IT&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇮🇹 Italy"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=500&country=ES&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇪🇸 Spain"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=500&country=NL&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇳🇱 Netherlands"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=500&country=CH&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇨🇭 Switzerland"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=500&country=SG&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇸🇬 Singapore"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=500&country=ZA&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇿🇦 South Africa"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=500&country=MX&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇲🇽 Mexico"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=500&country=AE&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇦🇪 UAE"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=500&country=SE&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇸🇪 Sweden"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=200&country=NO&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇳🇴 Norway"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=200&country=DK&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇩🇰 Denmark"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=200&country=FI&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇫🇮 Finland"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=200&country=TH&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇹🇭 Thailand"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=200&country=MY&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇲🇾 Malaysia"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=200&country=ID&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇮🇩 Indonesia"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=200&country=PH&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇵🇭 Philippines"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=200&country=VN&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇻🇳 Vietnam"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=100&country=EG&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇪🇬 Egypt"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=200&country=TR&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇹🇷 Turkey"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=100&country=CL&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇨🇱 Chile"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=100&country=CO&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇨🇴 Colombia"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=100&country=PE&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇵🇪 Peru"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=100&country=AR&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇦🇷 Argentina"},
-		{"/v3/stock-screener?marketCapMoreThan=50000000&limit=500&country=IL&order=desc&sortBy=marketcap&isActivelyTrading=true", "🇮🇱 Israel"},
+	c.profilesMu.Lock()
+	if c.profiles == nil {
+		c.profiles = make(map[string]domain.Profile, len(symbols))
 	}
+	c.profilesMu.Unlock()
 
-	// ENHANCED PARALLEL COUNTRY FETCHING - Process multiple countries simultaneously
-	const countryWorkers = 12 // Fetch 12 countries in parallel for maximum speed
-	countryWg := sync.WaitGroup{}
-	countryChan := make(chan struct {
-		endpoint string
-		desc     string
-	}, len(endpoints))
-
-	// Start country worker goroutines
-	for i := 0; i < countryWorkers; i++ {
-		countryWg.Add(1)
-		go func(workerID int) {
-			defer countryWg.Done()
-			for ep := range countryChan {
-				fmt.Printf("📡 Worker %d: Fetching %s stocks from FMP...\n", workerID, ep.desc)
-
-				body, err := c.makeRequest(ep.endpoint)
-				if err != nil {
-					fmt.Printf("⚠️  Worker %d: Failed to fetch %s stocks: %v\n", workerID, ep.desc, err)
-					continue
-				}
+	pool := c.startPool("profiles", profileWorkers)
+	workpool.Each(pool, profileWorkers, batches, func(_ int, batch []string) {
+		if c.quotaExhausted.Load() {
+			return
+		}
+		profiles, err := c.profileBatch(c.data(), batch)
+		if errors.Is(err, enrich.ErrQuotaExceeded) {
+			if c.quotaExhausted.CompareAndSwap(false, true) {
+				c.Logger.Warn("API quota exhausted, deduplicating the remaining listings by name")
+			}
+			return
+		}
+		if err != nil {
+			c.Logger.Warn("profile batch failed", "first", batch[0], "symbols", len(batch), "error", err)
+			return
+		}
+		c.profilesMu.Lock()
+		for _, p := range profiles {
+			c.profiles[p.Symbol] = p
+		}
+		c.profilesMu.Unlock()
+	})
+	c.logPool(pool)
 
-				var stocks []FMPStockScreener
-				if err := json.Unmarshal(body, &stocks); err != nil {
-					fmt.Printf("⚠️  Worker %d: Failed to parse %s stocks: %v\n", workerID, ep.desc, err)
-					continue
-				}
+	if c.Fallback != nil {
+		c.fillProfiles(symbols)
+	}
+}
 
-				fmt.Printf("✅ Worker %d: Received %d %s stocks\n", workerID, len(stocks), ep.desc)
-
-				// Debug: Check for major stocks in specific countries
-				saStocksFound := 0
-				hkStocksFound := 0
-				for _, stock := range stocks {
-					// Check for Saudi Arabia stocks
-					if stock.Country == "SA" || stock.ExchangeShortName == "SAU" || strings.Contains(stock.Exchange, "Saudi") {
-						saStocksFound++
-						if saStocksFound <= 3 {
-							fmt.Printf("🔍 Worker %d: Found Saudi Arabia stock: %s (%s) - Market Cap: $%.1fB\n",
-								workerID, stock.Symbol, stock.CompanyName, stock.MarketCap/1e9)
-						}
-					}
+// fillProfiles asks the Fallback provider for the profiles the primary
+// provider had none for
+func (c *FMPClient) fillProfiles(symbols []string) {
+	var missing []string
+	c.profilesMu.RLock()
+	for _, symbol := range symbols {
+		if _, ok := c.profiles[symbol]; !ok {
+			missing = append(missing, symbol)
+		}
+	}
+	c.profilesMu.RUnlock()
+	if len(missing) == 0 {
+		return
+	}
 
-					// Check for Hong Kong stocks
-					if strings.HasSuffix(strings.ToUpper(stock.Symbol), ".HK") || stock.Country == "HK" {
-						hkStocksFound++
-						if hkStocksFound <= 3 && strings.Contains(strings.ToUpper(stock.CompanyName), "TENCENT") {
-							fmt.Printf("🔍 Worker %d: Found HK Tencent: %s - Market Cap: $%.1fB\n",
-								workerID, stock.Symbol, stock.MarketCap/1e9)
-						}
-					}
-				}
+	var batches [][]string
+	for start := 0; start < len(missing); start += profileBatchSize {
+		batches = append(batches, missing[start:min(start+profileBatchSize, len(missing))])
+	}
+	var filled atomic.Int64
+	pool := workpool.New("fallback_profiles", 1, profileWorkers)
+	workpool.Each(pool, profileWorkers, batches, func(_ int, batch []string) {
+		profiles, err := c.profileBatch(c.Fallback, batch)
+		if err != nil {
+			c.Logger.Debug("fallback profile batch failed", "provider", c.Fallback.Name(), "first", batch[0], "error", err)
+			return
+		}
+		c.profilesMu.Lock()
+		if c.fallbackProfiles == nil {
+			c.fallbackProfiles = make(map[string]bool)
+		}
+		for _, p := range profiles {
+			c.profiles[p.Symbol] = p
+			c.fallbackProfiles[p.Symbol] = true
+		}
+		c.profilesMu.Unlock()
+		metrics.FallbackLookups.Add(float64(len(profiles)), c.Fallback.Name(), "profile")
+		filled.Add(int64(len(profiles)))
+	})
+	c.Logger.Info("filled missing profiles from fallback provider", "provider", c.Fallback.Name(),
+		"missing", len(missing), "filled", filled.Load())
+}
 
-				if saStocksFound > 0 {
-					fmt.Printf("✅ Worker %d: Found %d Saudi Arabia stocks in %s\n", workerID, saStocksFound, ep.desc)
-				}
-				if hkStocksFound > 0 && ep.desc == "🇭🇰 Hong Kong" {
-					fmt.Printf("✅ Worker %d: Found %d Hong Kong stocks in %s\n", workerID, hkStocksFound, ep.desc)
-				}
+// Profiles looks up the profiles of several symbols in one request
+func (c *FMPClient) Profiles(symbols []string) ([]domain.Profile, error) {
+	body, err := c.makeRequest("/v3/profile/" + strings.Join(symbols, ","))
+	if err != nil {
+		return nil, err
+	}
+	var profiles []domain.Profile
+	if err := json.Unmarshal(body, &profiles); err != nil {
+		return nil, &marketdata.ParseError{What: "profile batch", Err: err}
+	}
+	return profiles, nil
+}
+
+// profileBatch looks up a batch of profiles in one request when data
+// supports it, and one symbol at a time otherwise. Symbols without a profile
+// are left out; the batch fails only when none is found.
+func (c *FMPClient) profileBatch(data marketdata.Provider, symbols []string) ([]domain.Profile, error) {
+	if batcher, ok := data.(marketdata.BatchProfiler); ok {
+		return batcher.Profiles(symbols)
+	}
+	var profiles []domain.Profile
+	var lastErr error
+	for _, symbol := range symbols {
+		profile, err := data.Profile(symbol)
+		if errors.Is(err, enrich.ErrQuotaExceeded) {
+			return profiles, err
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		profiles = append(profiles, *profile)
+	}
+	if len(profiles) == 0 {
+		return nil, lastErr
+	}
+	return profiles, nil
+}
+
+// recentIPODays returns RecentIPODays, or its default when unset
+func (c *FMPClient) recentIPODays() int {
+	if c.RecentIPODays > 0 {
+		return c.RecentIPODays
+	}
+	return domain.RecentIPODays
+}
+
+// cachedProfile returns a profile fetched by fetchProfiles
+func (c *FMPClient) cachedProfile(symbol string) (domain.Profile, bool) {
+	c.profilesMu.RLock()
+	defer c.profilesMu.RUnlock()
+	p, ok := c.profiles[symbol]
+	return p, ok
+}
 
-				// Thread-safe append to allStocks
-				stockMutex.Lock()
-				allStocks = append(allStocks, stocks...)
-				stockMutex.Unlock()
+// maxScreenerPages bounds the pagination of one country's screener
+const maxScreenerPages = 100
+
+// Screen returns every company in country worth at least minMarketCap US
+// dollars. The screener compares market caps in the listing currency, so the
+// floor is converted first. The screener has no offset parameter, so pages
+// are walked by market cap: each request asks for companies no larger than
+// the smallest one already seen, and stops once a page comes back short or
+// adds nothing new. On error the companies fetched so far are returned with it.
+func (c *FMPClient) Screen(country string, pageSize int, minMarketCap float64) ([]FMPStockScreener, int, error) {
+	var stocks []FMPStockScreener
+	seen := make(map[string]bool)
+	upper := 0.0 // market cap ceiling of the next page; 0 means none
+	floor := c.localFloor(country, minMarketCap)
+
+	for page := 1; page <= maxScreenerPages; page++ {
+		endpoint := fmt.Sprintf("/v3/stock-screener?marketCapMoreThan=%.0f&limit=%d&country=%s&order=desc&sortBy=marketcap&isActivelyTrading=true",
+			floor, pageSize, country)
+		if upper > 0 {
+			endpoint += fmt.Sprintf("&marketCapLowerThan=%.0f", upper)
+		}
+
+		body, err := c.makeRequest(endpoint)
+		if err != nil {
+			return stocks, page - 1, err
+		}
+		var batch []FMPStockScreener
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return stocks, page - 1, &marketdata.ParseError{What: fmt.Sprintf("page %d", page), Err: err}
+		}
 
-				// Minimal rate limiting for enhanced speed
-				time.Sleep(50 * time.Millisecond)
+		added := 0
+		smallest := upper
+		for _, stock := range batch {
+			if smallest == 0 || stock.MarketCap < smallest {
+				smallest = stock.MarketCap
 			}
-		}(i)
+			if seen[stock.Symbol] {
+				continue
+			}
+			seen[stock.Symbol] = true
+			stocks = append(stocks, stock)
+			added++
+		}
+
+		// The ceiling is inclusive of the boundary market cap (rounded up) so
+		// companies tied with the last row of a page are not skipped
+		if len(batch) < pageSize || added == 0 {
+			return stocks, page, nil
+		}
+		upper = math.Ceil(smallest) + 1
+	}
+
+	c.Logger.Warn("screener pagination stopped at page limit", "country", country, "pages", maxScreenerPages)
+	return stocks, maxScreenerPages, nil
+}
+
+// localFloor converts a US dollar market cap floor into the currency of
+// country's listings. Without an exchange rate the floor is applied as is.
+func (c *FMPClient) localFloor(country string, minMarketCap float64) float64 {
+	currency := fx.CurrencyForCountry(country)
+	if currency == "USD" {
+		return minMarketCap
+	}
+	if historical, ok := c.historicalRate(currency); ok {
+		return minMarketCap / historical.Rate
+	}
+	rate, err := c.FX(currency)
+	if err != nil || rate <= 0 {
+		fallback, ok := fx.FallbackRate(currency)
+		if !ok {
+			c.Logger.Warn("no exchange rate for the screen floor, applying it in local currency",
+				"country", country, "currency", currency, "error", err)
+			return minMarketCap
+		}
+		rate = fallback
+	}
+	c.Logger.Debug("converted screen floor", "country", country, "currency", currency,
+		"floor_usd", minMarketCap, "floor_local", minMarketCap/rate)
+	return minMarketCap / rate
+}
+
+// minMarketCap returns the US dollar screen floor of country
+func (c *FMPClient) minMarketCap(country string) float64 {
+	if floor, ok := c.CountryMinMarketCaps[country]; ok {
+		return floor
+	}
+	if c.MinMarketCap > 0 {
+		return c.MinMarketCap
+	}
+	return marketdata.MinMarketCap
+}
+
+// GetGlobalStocks collects the global ranking into memory; see
+// StreamGlobalStocks
+func (c *FMPClient) GetGlobalStocks() ([]domain.Asset, error) {
+	ranked, err := c.StreamGlobalStocks()
+	if err != nil {
+		return nil, err
+	}
+	defer ranked.Close()
+	assets := make([]domain.Asset, 0, ranked.Len())
+	err = ranked.Each(func(asset domain.Asset) error {
+		assets = append(assets, asset)
+		return nil
+	})
+	return assets, err
+}
+
+// Collection is the outcome of a run: the ranking, read from a spool the
+// caller must close, and the countries whose screens failed. A country that
+// failed part way is ranked with the listings fetched before the failure.
+type Collection struct {
+	*spool.Spool
+	Failed []*marketdata.CountryError
+}
+
+// Missing returns the countries that failed without a single listing
+func (c *Collection) Missing() []string {
+	var missing []string
+	for _, failure := range c.Failed {
+		if failure.Missing() {
+			missing = append(missing, failure.Country)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// screenListings runs the country screens in parallel and returns the
+// surviving listings, one per issuer and largest first, with the countries
+// whose screens failed
+func (c *FMPClient) screenListings() ([]FMPStockScreener, []*marketdata.CountryError) {
+	c.Logger.Info("fetching all 50M+ companies with USD conversion")
+
+	var screened []FMPStockScreener
+	var failed []*marketdata.CountryError
+	var stockMutex sync.Mutex
+
+	countries := c.Countries
+	if len(countries) == 0 {
+		countries = DefaultCountries
+	}
+
+	// ENHANCED PARALLEL COUNTRY FETCHING - Process multiple countries simultaneously
+	countryChan := make(chan CountryConfig, len(countries))
+
+	progress.Publish(progress.Event{Type: progress.StageStarted, Stage: "screeners", Total: len(countries)})
+	var countriesDone atomic.Int64
+	countryDone := func(country string, count int, err error) {
+		e := progress.Event{Type: progress.CountryCompleted, Stage: "screeners", Country: country, Count: count,
+			Done: int(countriesDone.Add(1)), Total: len(countries)}
+		if err != nil {
+			e.Message = err.Error()
+		}
+		progress.Publish(e)
 	}
 
-	// Send all endpoints to workers
+	// Send all countries to workers
 	go func() {
 		defer close(countryChan)
-		for _, ep := range endpoints {
-			countryChan <- ep
+		for _, cfg := range countries {
+			countryChan <- cfg
 		}
 	}()
 
-	// Wait for all country fetches to complete
-	countryWg.Wait()
+	// Fetch up to countryWorkers countries at once, fewer while the API is
+	// answering with 429s
+	screenerPool := c.startPool("screeners", countryWorkers)
+	workpool.Run(screenerPool, countryWorkers, countryChan, func(workerID int, cfg CountryConfig) {
+		stocks, err := c.CollectCountry(cfg, c.Logger.With("worker_id", workerID, "country", cfg.Code))
+		countryDone(cfg.Code, len(stocks), err)
+		var countryErr *marketdata.CountryError
+		if errors.As(err, &countryErr) {
+			stockMutex.Lock()
+			failed = append(failed, countryErr)
+			stockMutex.Unlock()
+		}
 
-	fmt.Printf("✅ Total received: %d stocks globally\n", len(allStocks))
+		// Funds are dropped as each country arrives so they are never held
+		// alongside the other countries' rows
+		kept := stocks[:0]
+		for _, stock := range stocks {
+			if !c.screenedOut(stock) {
+				kept = append(kept, stock)
+			}
+		}
+		if len(kept) == 0 {
+			return
+		}
 
-	// Enhanced filtering and deduplication
-	var validStocks []FMPStockScreener
-	seenSymbols := make(map[string]bool)
-	companyListings := make(map[string]FMPStockScreener)
+		stockMutex.Lock()
+		screened = append(screened, kept...)
+		stockMutex.Unlock()
 
-	for _, stock := range allStocks {
-		// Skip ETFs and index funds
-		if stock.IsEtf {
-			continue
-		}
+		// Minimal rate limiting for enhanced speed
+		time.Sleep(50 * time.Millisecond)
+	})
+	c.logPool(screenerPool)
 
-		nameUpper := strings.ToUpper(stock.CompanyName)
-		if containsWord(nameUpper, "ETF") ||
-			containsWord(nameUpper, "INDEX") ||
-			containsWord(nameUpper, "FUND") ||
-			containsWord(nameUpper, "SPDR") ||
-			containsWord(nameUpper, "ISHARES") ||
-			containsWord(nameUpper, "VANGUARD") {
-			continue
+	c.Logger.Info("screener fetch complete", "total", len(screened))
+
+	// Countries finish in any order; rank the rows so the symbol seen first and
+	// the issuer registry's input do not depend on scheduling
+	sort.Slice(screened, func(i, j int) bool {
+		if screened[i].MarketCap != screened[j].MarketCap {
+			return screened[i].MarketCap > screened[j].MarketCap
+		}
+		if screened[i].Symbol != screened[j].Symbol {
+			return screened[i].Symbol < screened[j].Symbol
 		}
+		return screened[i].Country < screened[j].Country
+	})
+
+	// Enhanced filtering and deduplication
+	var candidates []FMPStockScreener
+	seenSymbols := make(map[string]bool)
 
+	for _, stock := range screened {
 		// Skip if already seen this exact symbol
 		if seenSymbols[stock.Symbol] {
 			continue
@@ -335,49 +825,121 @@ func (c *FMPClient) GetGlobalStocks() ([]AssetData, error) {
 		seenSymbols[stock.Symbol] = true
 
 		if stock.IsActivelyTrading && stock.MarketCap > 0 {
-			// Check if we already have a listing for this company
-			if existingStock, exists := companyListings[stock.CompanyName]; exists {
-				// Keep the better listing based on priority
-				if shouldKeepNewListing(stock, existingStock) {
-					companyListings[stock.CompanyName] = stock
-				}
-			} else {
-				// First time seeing this company
-				companyListings[stock.CompanyName] = stock
-			}
+			candidates = append(candidates, stock)
+		} else {
+			c.Report.Drop(stock.Symbol, stock.Country, "inactive_or_no_market_cap")
 		}
 	}
 
-	// Convert map to slice
-	for _, stock := range companyListings {
-		validStocks = append(validStocks, stock)
-	}
+	validStocks := c.dedupeListings(candidates)
 
-	fmt.Printf("🔄 Filtered to %d valid stocks (removed ETFs and duplicates)\n", len(validStocks))
+	c.Logger.Info("filtered screener results", "valid", len(validStocks))
+	return validStocks, failed
+}
 
-	// ENHANCED PARALLEL PROCESSING for stock processing
-	var assets []AssetData
-	maxStocks := len(validStocks) // Process ALL valid stocks
+// limitListings keeps the c.Limit listings with the largest market caps in
+// USD. The screens report caps in each listing's own currency and price unit,
+// so they are converted first with the rates the symbol stage converts with;
+// the rates are stored in rates for it to reuse.
+func (c *FMPClient) limitListings(stocks []FMPStockScreener, rates map[string]domain.FXRate) []FMPStockScreener {
+	usd := make([]float64, len(stocks))
+	for i, stock := range stocks {
+		currency, divisor := c.quoteCurrency(stock)
+		rate, ok := rates[currency]
+		if !ok {
+			rate = c.getUSDExchangeRate(currency)
+			rates[currency] = rate
+		}
+		usd[i] = stock.MarketCap / divisor * rate.Rate
+	}
+	order := make([]int, len(stocks))
+	for i := range order {
+		order[i] = i
+	}
+	// Stable, so equal caps keep the screens' order
+	sort.SliceStable(order, func(i, j int) bool { return usd[order[i]] > usd[order[j]] })
 
-	fmt.Printf("💱 Converting market caps to USD and getting real-time data with ENHANCED parallel processing...\n")
+	kept := make([]FMPStockScreener, 0, c.Limit)
+	for _, i := range order[:c.Limit] {
+		kept = append(kept, stocks[i])
+	}
+	c.Logger.Info("limiting symbol stage", "limit", c.Limit, "skipped", len(stocks)-c.Limit)
+	return kept
+}
 
-	// COMPREHENSIVE PROCESSING - Get ALL 50M+ companies globally
-	const numWorkers = 8 // Balanced for performance and stability
-	// No maxStocks limit - process ALL valid companies
-	stockChan := make(chan FMPStockScreener, 300)
-	resultChan := make(chan AssetData, 300)
-	var wg sync.WaitGroup
+// StreamGlobalStocks runs the collection as a pipeline: country screens are
+// filtered as they arrive, surviving listings are deduplicated and enriched
+// by the symbol workers through bounded channels, and enriched rows go to a
+// spool that keeps at most spoolRows of them in memory and ranks the rest on
+// disk. The caller reads the ranking from the spool and must close it. With
+// Symbols set, their profiles stand in for the screens.
+func (c *FMPClient) StreamGlobalStocks() (*Collection, error) {
+	var validStocks []FMPStockScreener
+	var failed []*marketdata.CountryError
+	if len(c.Symbols) > 0 {
+		validStocks = c.symbolListings()
+	} else {
+		validStocks, failed = c.screenListings()
+	}
 
 	// Enhanced exchange rate cache with mutex for thread safety
-	var exchangeRateCache = make(map[string]float64)
+	var exchangeRateCache = make(map[string]domain.FXRate)
 	var rateMutex sync.RWMutex
 
-	// Pre-fetch common exchange rates in parallel
-	commonCurrencies := []string{"EUR", "GBP", "JPY", "CAD", "AUD", "CHF", "CNY", "HKD", "KRW", "INR", "BRL", "MXN", "SAR", "AED", "SGD", "SEK", "NOK", "DKK", "THB", "MYR", "IDR", "PHP", "VND", "EGP", "TRY", "CLP", "COP", "PEN", "ARS", "ILS", "ZAR", "TWD"}
+	if c.Limit > 0 && len(validStocks) > c.Limit {
+		validStocks = c.limitListings(validStocks, exchangeRateCache)
+	}
+
+	if c.FairSchedule {
+		validStocks = interleaveByCountry(validStocks)
+	}
+
+	if c.SharesFloat {
+		c.fetchSharesFloat(validStocks)
+	}
+	if c.Enrichments[enrich.Technicals] {
+		c.fetchPriceChanges(validStocks)
+	}
+	if c.Enrichments[enrich.Ownership] {
+		c.fetchOwnership(validStocks)
+	}
+	if c.Enrichments[enrich.REIT] {
+		c.fetchFFO(validStocks)
+	}
+
+	c.Logger.Info("converting market caps to USD and fetching quotes")
+	c.symbolsTotal = len(validStocks)
+	c.countries = make(map[string]*countryCount)
+	for _, stock := range validStocks {
+		if c.countries[stock.Country] == nil {
+			c.countries[stock.Country] = &countryCount{}
+		}
+		c.countries[stock.Country].total++
+	}
+	progress.Publish(progress.Event{Type: progress.StageStarted, Stage: "symbols", Total: c.symbolsTotal})
+	for country, count := range c.countries {
+		progress.Publish(progress.Event{Type: progress.CountryProgress, Stage: "symbols", Country: country, Total: count.total})
+	}
 
-	// Parallel exchange rate fetching
+	// COMPREHENSIVE PROCESSING - Get ALL 50M+ companies globally
+	// No maxStocks limit - process ALL valid companies
+	stockChan := make(chan FMPStockScreener, 300)
+	resultChan := make(chan domain.Asset, 300)
+
+	// Pre-fetch common exchange rates in parallel, unless every listing is
+	// quoted in USD (e.g. a US-only run)
+	var currencies []string
+	for _, stock := range validStocks {
+		if currency, _ := c.quoteCurrency(stock); currency != "USD" {
+			currencies = prefetchCurrencies
+			break
+		}
+	}
 	rateFetchWg := sync.WaitGroup{}
-	for _, currency := range commonCurrencies {
+	for _, currency := range currencies {
+		if _, ok := exchangeRateCache[currency]; ok {
+			continue
+		}
 		rateFetchWg.Add(1)
 		go func(curr string) {
 			defer rateFetchWg.Done()
@@ -388,202 +950,235 @@ func (c *FMPClient) GetGlobalStocks() ([]AssetData, error) {
 		}(currency)
 	}
 
-	// Start enhanced worker goroutines
-	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		go func(workerID int) {
-			defer wg.Done()
-			for stock := range stockChan {
-				// Detect currency from symbol and country
-				currencyCode := c.detectCurrency(stock.Symbol, stock.Country)
-
-				// SPECIFIC STOCK VALIDATION: Skip known problematic stocks
-				if isProblematicStock(stock.Symbol, stock.CompanyName) {
-					fmt.Printf("⚠️  SKIPPING KNOWN PROBLEM: %s (%s) - known to have bad market cap data\n",
-						stock.Symbol, stock.CompanyName)
-					continue
-				}
+	// usdRate returns a cached exchange rate, fetching and caching it on a miss
+	usdRate := func(currency string) domain.FXRate {
+		rateMutex.RLock()
+		rate, exists := exchangeRateCache[currency]
+		rateMutex.RUnlock()
+		if exists {
+			metrics.FXCacheLookups.Inc("hit")
+			return rate
+		}
+		metrics.FXCacheLookups.Inc("miss")
+		rate = c.getUSDExchangeRate(currency)
+		rateMutex.Lock()
+		exchangeRateCache[currency] = rate
+		rateMutex.Unlock()
+		return rate
+	}
 
-				// Keep original price in local currency, only convert market cap to USD
-				currentPrice := stock.Price
-				marketCapUSD := stock.MarketCap
+	// Start enhanced worker goroutines; the pool sheds concurrency on 429s
+	pool := c.startPool("symbols", symbolWorkers)
+	go func() {
+		defer close(resultChan)
+		workpool.Run(pool, symbolWorkers, stockChan, func(workerID int, stock FMPStockScreener) {
+			logger := c.Logger.With("worker_id", workerID, "country", stock.Country, "symbol", stock.Symbol)
+
+			// Quote currency and price unit (pence, cents, agorot...)
+			currencyCode, divisor := c.quoteCurrency(stock)
+
+			// SPECIFIC STOCK VALIDATION: Skip known problematic stocks
+			if isProblematicStock(stock.Symbol, stock.CompanyName) {
+				logger.Warn("skipping known problem stock with bad market cap data", "name", stock.CompanyName)
+				c.dropSymbol(stock, "known_bad_data")
+				return
+			}
 
-				// VALIDATE USD STOCKS TOO: Filter out obviously bad market cap values for USD stocks
-				if currencyCode == "USD" {
-					if marketCapUSD > 5e12 { // More than $5 trillion is suspicious
-						fmt.Printf("⚠️  SKIPPING USD: %s has market cap $%.1fT, likely bad API data\n",
-							stock.Symbol, marketCapUSD/1e12)
-						continue
-					}
-					// Filter out OTC USD stocks (often have bad data)
-					if strings.Contains(strings.ToUpper(stock.ExchangeShortName), "OTC") ||
-						stock.ExchangeShortName == "" {
-						fmt.Printf("⚠️  SKIPPING USD OTC: %s (exchange: %s) - OTC stocks often have bad data\n",
-							stock.Symbol, stock.ExchangeShortName)
-						continue
-					}
-				}
+			// Keep original price in local currency, only convert market cap to USD
+			currentPrice := stock.Price
+			marketCapUSD := stock.MarketCap
 
-				if currencyCode != "USD" {
-					// Use cached exchange rate
-					rateMutex.RLock()
-					exchangeRate, exists := exchangeRateCache[currencyCode]
-					rateMutex.RUnlock()
-
-					if !exists {
-						// Fetch and cache if not found
-						exchangeRate = c.getUSDExchangeRate(currencyCode)
-						rateMutex.Lock()
-						exchangeRateCache[currencyCode] = exchangeRate
-						rateMutex.Unlock()
-					}
+			// VALIDATE USD STOCKS TOO: Filter out obviously bad market cap values for USD stocks
+			if currencyCode == "USD" {
+				if marketCapUSD > 5e12 { // More than $5 trillion is suspicious
+					logger.Warn("skipping suspicious market cap", "market_cap_usd", marketCapUSD)
+					c.dropSymbol(stock, "market_cap_implausible")
+					return
+				}
+				// Filter out OTC USD stocks (often have bad data)
+				if strings.Contains(strings.ToUpper(stock.ExchangeShortName), "OTC") ||
+					stock.ExchangeShortName == "" {
+					logger.Debug("skipping OTC stock", "exchange", stock.ExchangeShortName)
+					c.dropSymbol(stock, "otc_or_unknown_exchange")
+					return
+				}
+			}
 
-					// Convert market cap to USD
-					// CRITICAL FIX: Many exchanges price in sub-units (cents/pence/agorot)!
-					marketCapAdjusted := stock.MarketCap
-					symbolUpper := strings.ToUpper(stock.Symbol)
-					exchangeUpper := strings.ToUpper(stock.ExchangeShortName)
-
-					// Apply ÷100 adjustment for exchanges that use sub-units
-					if strings.HasSuffix(symbolUpper, ".L") || strings.Contains(exchangeUpper, "LSE") || // LSE: pence
-						strings.HasSuffix(symbolUpper, ".JO") || strings.Contains(exchangeUpper, "JNB") || // JSE: cents
-						strings.HasSuffix(symbolUpper, ".TA") || strings.Contains(exchangeUpper, "TLV") { // TASE: agorot
-						marketCapAdjusted = stock.MarketCap / 100.0
-						exchangeName := ""
-						if strings.HasSuffix(symbolUpper, ".L") {
-							exchangeName = "LSE (pence)"
-						}
-						if strings.HasSuffix(symbolUpper, ".JO") {
-							exchangeName = "JSE (cents)"
-						}
-						if strings.HasSuffix(symbolUpper, ".TA") {
-							exchangeName = "TASE (agorot)"
-						}
-						fmt.Printf("💱 %s Stock %s: Market Cap %s → %s (÷100 for %s adjustment)\n",
-							exchangeName, stock.Symbol,
-							formatLargeNumber(stock.MarketCap),
-							formatLargeNumber(marketCapAdjusted), exchangeName)
-					}
+			var rate domain.FXRate
+			if currencyCode != "USD" {
+				rate = usdRate(currencyCode)
 
-					marketCapUSD = marketCapAdjusted * exchangeRate
+				// Convert market cap to USD; venues quoting in sub-units
+				// (pence, cents, agorot) report it in those units too
+				marketCapAdjusted := stock.MarketCap / divisor
+				if divisor != 1 {
+					logger.Debug("applied sub-unit adjustment", "currency", currencyCode, "divisor", divisor,
+						"market_cap", stock.MarketCap, "market_cap_adjusted", marketCapAdjusted)
+				}
 
-					// AGGRESSIVE DATA VALIDATION: Filter out suspicious market cap values
-					if marketCapUSD > 5e12 { // More than $5 trillion is suspicious (only ~6 companies globally)
-						fmt.Printf("⚠️  SKIPPING: %s has market cap $%.1fT, likely bad API data\n",
-							stock.Symbol, marketCapUSD/1e12)
-						continue // Skip this stock completely
-					}
+				marketCapUSD = marketCapAdjusted * rate.Rate
 
-					// Filter out OTC stocks (often have bad data)
-					if strings.Contains(strings.ToUpper(stock.ExchangeShortName), "OTC") ||
-						stock.ExchangeShortName == "" {
-						fmt.Printf("⚠️  SKIPPING OTC: %s (exchange: %s) - OTC stocks often have bad data\n",
-							stock.Symbol, stock.ExchangeShortName)
-						continue
-					}
+				// AGGRESSIVE DATA VALIDATION: Filter out suspicious market cap values
+				if marketCapUSD > 5e12 { // More than $5 trillion is suspicious (only ~6 companies globally)
+					logger.Warn("skipping suspicious market cap", "market_cap_usd", marketCapUSD)
+					c.dropSymbol(stock, "market_cap_implausible")
+					return // Skip this stock completely
+				}
 
-					// Log major conversions for Saudi stocks
-					if marketCapUSD > 5e9 && stock.Country == "SA" {
-						fmt.Printf("💱 Saudi Stock %s: %.2f %s | Market Cap: $%.1fB USD (Worker %d)\n",
-							stock.Symbol, stock.Price, currencyCode, marketCapUSD/1e9, workerID)
-					}
+				// Filter out OTC stocks (often have bad data)
+				if strings.Contains(strings.ToUpper(stock.ExchangeShortName), "OTC") ||
+					stock.ExchangeShortName == "" {
+					logger.Debug("skipping OTC stock", "exchange", stock.ExchangeShortName)
+					c.dropSymbol(stock, "otc_or_unknown_exchange")
+					return
 				}
 
-				// Get real-time quote for current prices AND better market cap calculation
-				quote, err := c.GetQuote(stock.Symbol)
-				var percentageChange float64
-				var previousClose float64
-				var volume float64
-
-				if err == nil && quote != nil {
-					currentPrice = quote.Price
-					previousClose = quote.PreviousClose
-					percentageChange = quote.ChangesPercentage
-					volume = quote.Volume
-
-					// PREFER CALCULATED MARKET CAP from real-time quotes over screener data
-					if quote.SharesOutstanding > 0 && quote.Price > 0 {
-						adjustedPrice := quote.Price
-
-						// Apply sub-unit adjustment for exchanges that use sub-units
-						symbolUpper := strings.ToUpper(stock.Symbol)
-						exchangeUpper := strings.ToUpper(stock.ExchangeShortName)
-						if strings.HasSuffix(symbolUpper, ".L") || strings.Contains(exchangeUpper, "LSE") || // LSE: pence
-							strings.HasSuffix(symbolUpper, ".JO") || strings.Contains(exchangeUpper, "JNB") || // JSE: cents
-							strings.HasSuffix(symbolUpper, ".TA") || strings.Contains(exchangeUpper, "TLV") { // TASE: agorot
-							adjustedPrice = quote.Price / 100.0
-						}
+				// Log major conversions for Saudi stocks
+				if marketCapUSD > 5e9 && stock.Country == "SA" {
+					logger.Debug("converted Saudi stock", "price", stock.Price,
+						"currency", currencyCode, "market_cap_usd", marketCapUSD)
+				}
+			}
 
-						// Calculate market cap in USD
+			// Get real-time quote for current prices AND better market cap calculation
+			quote, err := c.quote(c.data(), stock.Symbol)
+			source := c.source(stock.Symbol)
+			if (err != nil || quote == nil) && c.Fallback != nil {
+				if fallback, fallbackErr := c.quote(c.Fallback, stock.Symbol); fallbackErr == nil {
+					logger.Debug("quote from fallback provider", "provider", c.Fallback.Name(), "primary_error", err)
+					metrics.FallbackLookups.Inc(c.Fallback.Name(), "quote")
+					quote, err, source = fallback, nil, c.Fallback.Name()
+					// The fallback's quote currency beats the guess from the symbol
+					if fallback.Currency != "" {
+						currencyCode, divisor = fx.ParseQuoteCurrency(fallback.Currency)
+						rate = domain.FXRate{}
 						if currencyCode != "USD" {
-							rateMutex.RLock()
-							exchangeRate := exchangeRateCache[currencyCode]
-							rateMutex.RUnlock()
-							marketCapUSD = (adjustedPrice * exchangeRate) * quote.SharesOutstanding
-						} else {
-							marketCapUSD = adjustedPrice * quote.SharesOutstanding
-						}
-
-						// FINAL VALIDATION: Re-check the calculated market cap
-						if marketCapUSD > 5e12 {
-							fmt.Printf("⚠️  SKIPPING CALCULATED: %s has calculated market cap $%.1fT, likely bad data\n",
-								stock.Symbol, marketCapUSD/1e12)
-							continue
+							rate = usdRate(currencyCode)
 						}
-
-						fmt.Printf("📊 RECALCULATED: %s market cap from $%s to $%s using real-time data\n",
-							stock.Symbol, formatLargeNumber(stock.MarketCap), formatLargeNumber(marketCapUSD))
 					}
-				} else {
-					previousClose = currentPrice * 0.99
-					percentageChange = 1.0
-					volume = stock.Volume
-				}
-
-				// Determine asset type
-				assetType := "stock"
-				nameUpper := strings.ToUpper(stock.CompanyName)
-				if containsWord(nameUpper, "REIT") {
-					assetType = "reit"
 				}
+			}
+			var percentageChange float64
+			var previousClose float64
+			var volume float64
+
+			if err == nil && quote != nil {
+				currentPrice = quote.Price
+				previousClose = quote.PreviousClose
+				percentageChange = quote.ChangesPercentage
+				volume = quote.Volume
+
+				// PREFER CALCULATED MARKET CAP from real-time quotes over screener data
+				if quote.SharesOutstanding > 0 && quote.Price > 0 {
+					adjustedPrice := quote.Price / divisor
+
+					// Calculate market cap in USD
+					if currencyCode != "USD" {
+						marketCapUSD = (adjustedPrice * rate.Rate) * quote.SharesOutstanding
+					} else {
+						marketCapUSD = adjustedPrice * quote.SharesOutstanding
+					}
 
-				// Get company profile for image (only for large companies to save time)
-				imageURL := ""
-				if marketCapUSD > 50e9 {
-					profile, err := c.GetCompanyProfile(stock.Symbol)
-					if err == nil && profile != nil {
-						imageURL = profile.Image
+					// FINAL VALIDATION: Re-check the calculated market cap
+					if marketCapUSD > 5e12 {
+						logger.Warn("skipping suspicious calculated market cap", "market_cap_usd", marketCapUSD)
+						c.dropSymbol(stock, "calculated_market_cap_implausible")
+						return
 					}
-				}
 
-				asset := AssetData{
-					Ticker:           stock.Symbol,
-					Name:             stock.CompanyName,
-					MarketCap:        marketCapUSD,
-					CurrentPrice:     currentPrice,
-					PreviousClose:    previousClose,
-					PercentageChange: percentageChange,
-					Volume:           volume,
-					PrimaryExchange:  stock.ExchangeShortName,
-					Country:          stock.Country,
-					Sector:           stock.Sector,
-					Industry:         stock.Industry,
-					AssetType:        assetType,
-					Image:            imageURL,
+					logger.Debug("recalculated market cap from quote",
+						"market_cap", stock.MarketCap, "market_cap_usd", marketCapUSD)
 				}
+			} else {
+				previousClose = currentPrice * 0.99
+				percentageChange = 1.0
+				volume = stock.Volume
+			}
 
-				resultChan <- asset
+			// Determine asset type; asset_type keeps its stock/reit values
+			// and instrument_type carries the finer classification
+			instrumentType := c.instrumentType(stock)
+			assetType := instrument.Stock
+			if instrumentType == instrument.REIT {
+				assetType = instrument.REIT
+			}
 
-				// Rate limiting to avoid API limits
-				time.Sleep(50 * time.Millisecond)
+			asset := domain.Asset{
+				Ticker:           stock.Symbol,
+				Name:             stock.CompanyName,
+				MarketCap:        marketCapUSD,
+				CurrentPrice:     currentPrice,
+				PreviousClose:    previousClose,
+				PercentageChange: percentageChange,
+				Volume:           volume,
+				PrimaryExchange:  stock.ExchangeShortName,
+				Country:          stock.Country,
+				Sector:           stock.Sector,
+				Industry:         stock.Industry,
+				AssetType:        assetType,
+				InstrumentType:   instrumentType,
+				ShareClass:       instrument.ParseSymbol(stock.Symbol, stock.ExchangeShortName).Class,
+				IsADR:            c.adrs[stock.Symbol],
+				PrimarySymbol:    stock.Symbol,
+				DataSource:       source,
 			}
-		}(i)
-	}
+			asset.SetFX(rate)
+			asset.SectorNormalized, asset.IndustryNormalized = taxonomy.Normalize(asset.Sector, asset.Industry)
+			refdata.Enrich(&asset, c.SnapshotTime)
+			if err == nil {
+				refdata.LabelQuote(&asset, quote)
+			}
+			refdata.Date(&asset, c.SnapshotTime)
+			if profile, ok := c.cachedProfile(stock.Symbol); ok {
+				asset.ISIN = profile.ISIN
+				asset.SetListing(profile.IPODate, c.SnapshotTime, c.recentIPODays())
+			}
+			var quoted *domain.Quote
+			if err == nil {
+				quoted = quote
+			}
+			asset.SetShares(c.shares(stock.Symbol, quoted))
+			if c.Enrichments[enrich.Technicals] {
+				if quoted != nil {
+					asset.SetTechnicals(quoted)
+				}
+				if ytd, ok := c.ytd[stock.Symbol]; ok {
+					asset.YTDReturn = &ytd
+				}
+			}
+			if holders, ok := c.holders[stock.Symbol]; ok {
+				asset.SetOwnership(domain.NewOwnership(stock.Symbol, "", holders, asset.SharesOutstanding))
+			}
+			if c.Enrichments[enrich.REIT] && instrumentType == instrument.REIT {
+				if quoted != nil && quoted.DividendYield > 0 {
+					dividendYield := quoted.DividendYield
+					asset.DividendYield = &dividendYield
+				}
+				// The market value in the listing currency, which FFO is
+				// reported in
+				localCap := marketCapUSD
+				if currencyCode != "USD" {
+					localCap = 0
+					if rate.Rate > 0 {
+						localCap = marketCapUSD / rate.Rate
+					}
+				}
+				asset.SetFFO(c.ffo[stock.Symbol], currencyCode, localCap)
+			}
+
+			metrics.SymbolsProcessed.Inc(stock.Country, "kept")
+			c.symbolDone(stock.Country)
+			resultChan <- asset
+
+			// Rate limiting to avoid API limits
+			time.Sleep(50 * time.Millisecond)
+		})
+	}()
 
 	// Wait for exchange rates to be pre-fetched
 	go func() {
 		rateFetchWg.Wait()
-		fmt.Printf("✅ Pre-fetched exchange rates for %d currencies\n", len(commonCurrencies))
+		c.Logger.Info("pre-fetched exchange rates", "currencies", len(currencies))
 	}()
 
 	// Send ALL stocks to workers (no artificial limits)
@@ -594,56 +1189,411 @@ func (c *FMPClient) GetGlobalStocks() ([]AssetData, error) {
 		}
 	}()
 
-	// Collect results
-	go func() {
-		wg.Wait()
-		close(resultChan)
-	}()
-
-	// Enhanced progress tracking
-	processed := 0
-	totalToProcess := len(validStocks)
-	if totalToProcess > maxStocks {
-		totalToProcess = maxStocks
-	}
-
+	// Progress is published per symbol by symbolDone. Enriched rows are
+	// spooled as they arrive, which also re-ranks them by USD market cap;
+	// after a spool error the results are still drained so workers finish.
+	// The rows of an issuer's share classes are held back until all have
+	// arrived and spooled as one issuer row.
+	ranked := spool.New(spoolRows)
+	var spoolErr error
+	classes := make(map[string][]domain.Asset)
 	for asset := range resultChan {
-		assets = append(assets, asset)
-		processed++
+		if primary, ok := c.classOf[asset.Ticker]; ok {
+			classes[primary] = append(classes[primary], asset)
+			continue
+		}
+		if spoolErr == nil {
+			asset.SetCapTier(c.CapTiers)
+			spoolErr = ranked.Add(asset)
+		}
+	}
+	for primary, rows := range classes {
+		if spoolErr == nil {
+			issuer := domain.AggregateShareClasses(rows, primary)
+			issuer.SetCapTier(c.CapTiers)
+			spoolErr = ranked.Add(issuer)
+		}
+	}
+	c.logPool(pool)
+	if spoolErr != nil {
+		ranked.Close()
+		return nil, spoolErr
+	}
+
+	c.Logger.Info("stock processing complete", "ranked", ranked.Len(), "spilled_runs", ranked.Spilled(), "workers", symbolWorkers)
 
-		// Enhanced progress reporting
-		if processed%25 == 0 {
-			fmt.Printf("📊 Processed %d/%d stocks... (%.1f%% complete) - Latest: %s\n",
-				processed, totalToProcess, float64(processed)/float64(totalToProcess)*100, asset.Name)
+	// Rank cutoffs need every USD market cap, so they apply to the finished
+	// ranking
+	if !c.Cutoff.Empty() {
+		cut, err := c.applyCutoff(ranked)
+		if err != nil {
+			return nil, err
 		}
+		ranked = cut
 	}
 
-	// Re-rank by USD market cap
-	fmt.Printf("🏆 Re-ranking %d assets by USD market cap...\n", len(assets))
-	sort.Slice(assets, func(i, j int) bool {
-		return assets[i].MarketCap > assets[j].MarketCap
-	})
+	return &Collection{Spool: ranked, Failed: failed}, nil
+}
+
+// fetchSharesFloat reads the share counts of stocks from the bulk
+// shares-float endpoint. A plan without the endpoint only leaves the floats
+// empty; other failures are reported, keeping the pages read before them.
+func (c *FMPClient) fetchSharesFloat(stocks []FMPStockScreener) {
+	want := make(map[string]bool, len(stocks))
+	for _, stock := range stocks {
+		want[stock.Symbol] = true
+	}
+	floats, pages, err := marketdata.FetchSharesFloat(c.makeRequest, want)
+	c.floats = floats
+	switch {
+	case errors.Is(err, marketdata.ErrNotEntitled):
+		c.Logger.Warn("API plan does not include shares float, leaving float shares and free-float market caps empty")
+	case err != nil:
+		c.Logger.Warn("failed to fetch shares float", "pages", pages, "found", len(floats), "error", err)
+		c.Report.Error(err)
+	default:
+		c.Logger.Info("fetched shares float", "pages", pages, "found", len(floats), "symbols", len(want))
+	}
+}
+
+// fetchPriceChanges looks up the year-to-date returns of stocks in batches
+// for the technicals enrichment. Failed batches are logged and skipped, and
+// once the daily quota runs out the remaining batches are not attempted.
+func (c *FMPClient) fetchPriceChanges(stocks []FMPStockScreener) {
+	var batches [][]string
+	for start := 0; start < len(stocks); start += priceChangeBatchSize {
+		batch := make([]string, 0, priceChangeBatchSize)
+		for _, stock := range stocks[start:min(start+priceChangeBatchSize, len(stocks))] {
+			batch = append(batch, stock.Symbol)
+		}
+		batches = append(batches, batch)
+	}
 
-	// Keep ALL companies (no artificial cutoff)
-	// All companies with 50M+ market cap will be included
+	c.ytd = make(map[string]float64, len(stocks))
+	var mu sync.Mutex
+	var exhausted atomic.Bool
+	pool := c.startPool("price_changes", profileWorkers)
+	workpool.Each(pool, profileWorkers, batches, func(_ int, batch []string) {
+		if exhausted.Load() || c.quotaExhausted.Load() {
+			return
+		}
+		changes, err := c.PriceChanges(batch)
+		if errors.Is(err, enrich.ErrQuotaExceeded) {
+			if exhausted.CompareAndSwap(false, true) {
+				c.Logger.Warn("API quota exhausted, leaving the remaining year-to-date returns empty")
+			}
+			return
+		}
+		if err != nil {
+			c.Logger.Warn("price change batch failed", "first", batch[0], "symbols", len(batch), "error", err)
+			return
+		}
+		mu.Lock()
+		for _, change := range changes {
+			c.ytd[change.Symbol] = change.YTD
+		}
+		mu.Unlock()
+	})
+	c.logPool(pool)
+	c.Logger.Info("fetched year-to-date returns", "found", len(c.ytd), "symbols", len(stocks))
+}
 
-	fmt.Printf("✅ Final result: Top %d stocks ranked by USD market cap\n", len(assets))
-	fmt.Printf("🚀 Optimized parallel processing completed with %d workers (reduced to avoid rate limits)!\n", numWorkers)
+// PriceChanges looks up the price changes of several symbols in one request
+func (c *FMPClient) PriceChanges(symbols []string) ([]domain.PriceChange, error) {
+	body, err := c.makeRequest("/v3/stock-price-change/" + strings.Join(symbols, ","))
+	if err != nil {
+		return nil, err
+	}
+	var changes []domain.PriceChange
+	if err := json.Unmarshal(body, &changes); err != nil {
+		return nil, &marketdata.ParseError{What: "price change batch", Err: err}
+	}
+	return changes, nil
+}
 
-	return assets, nil
+// fetchOwnership looks up the 13F holders of stocks one symbol at a time
+// and keeps them for the symbol workers. Once the quota runs out, or the
+// plan turns out not to include the endpoint, the remaining symbols are
+// left without ownership.
+func (c *FMPClient) fetchOwnership(stocks []FMPStockScreener) {
+	c.holders = make(map[string][]domain.InstitutionalHolder, len(stocks))
+	var mu sync.Mutex
+	var stopped atomic.Bool
+	pool := c.startPool("institutional_holders", symbolWorkers)
+	workpool.Each(pool, symbolWorkers, stocks, func(_ int, stock FMPStockScreener) {
+		if stopped.Load() || c.quotaExhausted.Load() {
+			return
+		}
+		holders, err := marketdata.FetchInstitutionalHolders(c.makeRequest, stock.Symbol)
+		switch {
+		case errors.Is(err, enrich.ErrQuotaExceeded):
+			if stopped.CompareAndSwap(false, true) {
+				c.Logger.Warn("API quota exhausted, leaving the remaining institutional ownership empty")
+			}
+			return
+		case errors.Is(err, marketdata.ErrNotEntitled):
+			if stopped.CompareAndSwap(false, true) {
+				c.Logger.Warn("API plan does not include institutional holders, leaving institutional ownership empty")
+			}
+			return
+		case err != nil:
+			c.Logger.Warn("institutional holders lookup failed", "symbol", stock.Symbol, "error", err)
+			return
+		}
+		if len(holders) == 0 {
+			return
+		}
+		mu.Lock()
+		c.holders[stock.Symbol] = holders
+		mu.Unlock()
+	})
+	c.logPool(pool)
+	c.Logger.Info("fetched institutional holders", "found", len(c.holders), "symbols", len(stocks))
 }
 
-func containsWord(text, word string) bool {
-	words := strings.Fields(text)
-	for _, w := range words {
-		cleanWord := strings.Trim(w, ".,!?;:")
-		if strings.EqualFold(cleanWord, word) {
-			return true
+// fetchFFO looks up the trailing FFO of the REITs among stocks, one cash
+// flow request per REIT, and keeps it for the symbol workers. Once the quota
+// runs out, or the plan turns out not to include statements, the remaining
+// REITs are left without FFO.
+func (c *FMPClient) fetchFFO(stocks []FMPStockScreener) {
+	var reits []FMPStockScreener
+	for _, stock := range stocks {
+		if c.instrumentType(stock) == instrument.REIT {
+			reits = append(reits, stock)
+		}
+	}
+	c.ffo = make(map[string]domain.FFO, len(reits))
+	var mu sync.Mutex
+	var stopped atomic.Bool
+	pool := c.startPool("cash_flow_statements", symbolWorkers)
+	workpool.Each(pool, symbolWorkers, reits, func(_ int, stock FMPStockScreener) {
+		if stopped.Load() || c.quotaExhausted.Load() {
+			return
 		}
+		ffo, err := marketdata.FetchFFO(c.makeRequest, stock.Symbol)
+		switch {
+		case errors.Is(err, enrich.ErrQuotaExceeded):
+			if stopped.CompareAndSwap(false, true) {
+				c.Logger.Warn("API quota exhausted, leaving the remaining REIT FFO empty")
+			}
+			return
+		case errors.Is(err, marketdata.ErrNotEntitled):
+			if stopped.CompareAndSwap(false, true) {
+				c.Logger.Warn("API plan does not include cash flow statements, leaving REIT FFO empty")
+			}
+			return
+		case err != nil:
+			c.Logger.Warn("cash flow statements lookup failed", "symbol", stock.Symbol, "error", err)
+			return
+		}
+		if ffo.Amount == 0 {
+			return
+		}
+		mu.Lock()
+		c.ffo[stock.Symbol] = ffo
+		mu.Unlock()
+	})
+	c.logPool(pool)
+	c.Logger.Info("fetched REIT FFO", "found", len(c.ffo), "reits", len(reits))
+}
+
+// shares returns symbol's outstanding and floating share counts: those of the
+// shares-float endpoint when it lists the symbol, otherwise the quote's
+// outstanding count with the float unknown
+func (c *FMPClient) shares(symbol string, quote *domain.Quote) (float64, float64) {
+	outstanding := 0.0
+	if quote != nil {
+		outstanding = quote.SharesOutstanding
+	}
+	sf, ok := c.floats[symbol]
+	if !ok {
+		return outstanding, 0
+	}
+	if sf.OutstandingShares > 0 {
+		outstanding = sf.OutstandingShares
+	}
+	return outstanding, sf.FloatShares
+}
+
+// screenedOut reports whether a screener row is an ETF, fund or other
+// non-company security under the exclusion rules, recording the drop
+func (c *FMPClient) screenedOut(stock FMPStockScreener) bool {
+	security := exclusion.Security{Symbol: stock.Symbol, Name: stock.CompanyName, Type: c.instrumentType(stock)}
+	if reason := exclusion.Match(security); reason != "" {
+		c.Report.Drop(stock.Symbol, stock.Country, reason)
+		return true
+	}
+	if c.SPACs.Excludes(security.Type) {
+		c.Report.Drop(stock.Symbol, stock.Country, instrument.SPAC)
+		return true
+	}
+	if c.AssetClass != "" && security.Type != c.AssetClass {
+		c.Report.Drop(stock.Symbol, stock.Country, "asset_class")
+		return true
+	}
+	if reason := c.Sectors.Match(stock.Sector, stock.Industry); reason != "" {
+		c.Report.Drop(stock.Symbol, stock.Country, reason)
+		return true
 	}
 	return false
 }
 
+// instrumentType classifies a screener row by its ETF and fund flags, its
+// industry and name, adding the profile's flags when the profile was fetched
+func (c *FMPClient) instrumentType(stock FMPStockScreener) string {
+	facts := instrument.Facts{Symbol: stock.Symbol, Exchange: stock.ExchangeShortName, Name: stock.CompanyName, IsEtf: stock.IsEtf, IsFund: stock.IsFund, Industry: stock.Industry}
+	if profile, ok := c.cachedProfile(stock.Symbol); ok {
+		facts.IsEtf = facts.IsEtf || profile.IsEtf
+		facts.IsFund = facts.IsFund || profile.IsFund
+		if facts.Industry == "" {
+			facts.Industry = profile.Industry
+		}
+	}
+	return c.SPACs.Type(instrument.Classify(facts))
+}
+
+// quoteCurrency returns the ISO currency of a listing's prices and the number
+// of quoted units per currency unit. The profile's currency is authoritative,
+// so dual-counter listings (HKD and CNY) and venues quoting some instruments in
+// foreign currencies come out right; without a profile the currency is guessed
+// from the symbol and country and the unit from the exchange table.
+func (c *FMPClient) quoteCurrency(stock FMPStockScreener) (string, float64) {
+	if profile, ok := c.cachedProfile(stock.Symbol); ok && strings.TrimSpace(profile.Currency) != "" {
+		return fx.ParseQuoteCurrency(profile.Currency)
+	}
+	venue, _ := refdata.ForListing(stock.Symbol, stock.ExchangeShortName)
+	return fx.DetectCurrency(stock.Symbol, stock.Country), venue.Divisor()
+}
+
+// dedupeListings keeps one listing per issuer. Listings sharing an ISIN or
+// CIK, or whose normalized names match, are cross-listings of one issuer; the
+// primary chosen by registry.PreferPrimary is kept and the rest are dropped.
+func (c *FMPClient) dedupeListings(stocks []FMPStockScreener) []FMPStockScreener {
+	if c.Identifiers {
+		symbols := make([]string, len(stocks))
+		for i, stock := range stocks {
+			symbols[i] = stock.Symbol
+		}
+		c.fetchProfiles(symbols)
+	}
+
+	bySymbol := make(map[string]FMPStockScreener, len(stocks))
+	reg := registry.New(registry.PreferPrimary)
+	identified := 0
+	for _, stock := range stocks {
+		bySymbol[stock.Symbol] = stock
+		listing := registry.Listing{
+			Symbol:    stock.Symbol,
+			Name:      stock.CompanyName,
+			Exchange:  stock.ExchangeShortName,
+			Country:   stock.Country,
+			MarketCap: stock.MarketCap,
+			ADR:       registry.IsLikelyADR(stock.Symbol, stock.CompanyName, stock.ExchangeShortName),
+		}
+		if profile, ok := c.cachedProfile(stock.Symbol); ok {
+			listing.ADR = listing.ADR || profile.IsADR
+			listing.IDs = registry.Identifiers{ISIN: profile.ISIN, CIK: profile.CIK, FIGI: profile.FIGI}
+			if listing.IDs != (registry.Identifiers{}) {
+				identified++
+			}
+		}
+		reg.Add(listing)
+	}
+
+	issuers := reg.Issuers()
+	kept := make([]FMPStockScreener, 0, len(issuers))
+	c.adrs = make(map[string]bool)
+	c.classOf = make(map[string]string)
+	for _, issuer := range issuers {
+		kept = append(kept, bySymbol[issuer.Primary.Symbol])
+		if issuer.Primary.ADR {
+			c.adrs[issuer.Primary.Symbol] = true
+		}
+		classes := make(map[string]bool)
+		if c.AggregateShareClasses {
+			for _, l := range issuer.ShareClasses() {
+				kept = append(kept, bySymbol[l.Symbol])
+				classes[l.Symbol] = true
+				c.classOf[l.Symbol] = issuer.Primary.Symbol
+			}
+			if len(classes) > 0 {
+				c.classOf[issuer.Primary.Symbol] = issuer.Primary.Symbol
+			}
+		}
+		for _, l := range issuer.Listings {
+			if l.Symbol != issuer.Primary.Symbol && !classes[l.Symbol] {
+				c.Report.Drop(l.Symbol, l.Country, "secondary_listing")
+			}
+		}
+	}
+	c.Logger.Info("deduplicated listings", "listings", len(stocks), "identified", identified, "issuers", len(issuers))
+	return kept
+}
+
+// LookupImages is the logo stage's lookup: profiles fetched for identifiers
+// are reused and the rest are asked for in one batched request
+func (c *FMPClient) LookupImages(batch []string) (map[string]string, error) {
+	images := make(map[string]string, len(batch))
+	var missing []string
+	for _, symbol := range batch {
+		if profile, ok := c.cachedProfile(symbol); ok {
+			images[symbol] = profile.Image
+		} else {
+			missing = append(missing, symbol)
+		}
+	}
+	if len(missing) == 0 {
+		return images, nil
+	}
+	if c.quotaExhausted.Load() {
+		return images, enrich.ErrQuotaExceeded
+	}
+	profiles, err := c.profileBatch(c.data(), missing)
+	if errors.Is(err, enrich.ErrQuotaExceeded) {
+		c.quotaExhausted.Store(true)
+	}
+	for _, p := range profiles {
+		images[p.Symbol] = p.Image
+	}
+	return images, err
+}
+
+// DeferProfiles records symbols whose profile lookup was skipped
+func (c *FMPClient) DeferProfiles(symbols []string) {
+	c.deferredMu.Lock()
+	c.deferred = append(c.deferred, symbols...)
+	c.deferredMu.Unlock()
+}
+
+// DeferredProfiles returns the symbols whose profile lookup was skipped
+func (c *FMPClient) DeferredProfiles() []string {
+	c.deferredMu.Lock()
+	defer c.deferredMu.Unlock()
+	return append([]string(nil), c.deferred...)
+}
+
+// dropSymbol records why a screener row was excluded from the output
+func (c *FMPClient) dropSymbol(stock FMPStockScreener, reason string) {
+	metrics.SymbolsProcessed.Inc(stock.Country, "skipped")
+	c.Report.Drop(stock.Symbol, stock.Country, reason)
+	c.symbolDone(stock.Country)
+}
+
+// symbolDone counts a finished symbol and periodically publishes overall and
+// per-country progress
+func (c *FMPClient) symbolDone(country string) {
+	if count := c.countries[country]; count != nil {
+		done := int(count.done.Add(1))
+		if done%countryProgressEvery == 0 || done == count.total {
+			progress.Publish(progress.Event{Type: progress.CountryProgress, Stage: "symbols", Country: country, Done: done, Total: count.total})
+		}
+	}
+	done := int(c.symbolsDone.Add(1))
+	if done%progressEvery == 0 || done == c.symbolsTotal {
+		progress.Publish(progress.Event{Type: progress.SymbolsProcessed, Stage: "symbols", Done: done, Total: c.symbolsTotal})
+	}
+}
+
 func isProblematicStock(symbol, companyName string) bool {
 	// Known stocks with consistently bad market cap data from FMP API
 	problematicStocks := map[string]bool{
@@ -666,259 +1616,246 @@ func isProblematicStock(symbol, companyName string) bool {
 	return false
 }
 
-func shouldKeepNewListing(newStock, existingStock FMPStockScreener) bool {
-	newPriority := getListingPriority(newStock.Symbol, newStock.ExchangeShortName)
-	existingPriority := getListingPriority(existingStock.Symbol, existingStock.ExchangeShortName)
+// interleaveByCountry orders stocks round-robin across countries, largest
+// market cap first within each country, so every market gets early coverage
+// and API calls are spread evenly instead of arriving in per-country bursts
+func interleaveByCountry(stocks []FMPStockScreener) []FMPStockScreener {
+	byCountry := make(map[string][]FMPStockScreener)
+	var countries []string
+	for _, stock := range stocks {
+		if _, ok := byCountry[stock.Country]; !ok {
+			countries = append(countries, stock.Country)
+		}
+		byCountry[stock.Country] = append(byCountry[stock.Country], stock)
+	}
+	sort.Strings(countries)
+	for _, country := range countries {
+		queue := byCountry[country]
+		sort.SliceStable(queue, func(i, j int) bool {
+			return queue[i].MarketCap > queue[j].MarketCap
+		})
+	}
 
-	if newPriority < existingPriority {
-		return true
-	} else if newPriority == existingPriority {
-		return newStock.MarketCap > existingStock.MarketCap
+	ordered := make([]FMPStockScreener, 0, len(stocks))
+	for len(ordered) < len(stocks) {
+		for _, country := range countries {
+			if queue := byCountry[country]; len(queue) > 0 {
+				ordered = append(ordered, queue[0])
+				byCountry[country] = queue[1:]
+			}
+		}
 	}
-	return false
+	return ordered
 }
 
-func getListingPriority(symbol, exchange string) int {
-	// Lower number = higher priority
-	symbolUpper := strings.ToUpper(symbol)
-	exchangeUpper := strings.ToUpper(exchange)
+// FX returns FMP's USD rate for currency
+func (c *FMPClient) FX(currency string) (float64, error) {
+	rate, err := c.FXQuote(currency)
+	return rate.Rate, err
+}
 
-	// Hong Kong primary listings get highest priority
-	if strings.HasSuffix(symbolUpper, ".HK") || exchangeUpper == "HKSE" {
-		return 1
+// FXQuote returns FMP's USD rate for currency with the time FMP quoted it,
+// or when it was fetched if the response has none. A rate served from the
+// response cache keeps the time it was quoted.
+func (c *FMPClient) FXQuote(currency string) (domain.FXRate, error) {
+	body, err := c.makeRequest(fmt.Sprintf("/v3/fx/%sUSD", currency))
+	if err != nil {
+		return domain.FXRate{}, err
 	}
-
-	// Major primary exchanges
-	primaryExchanges := []string{"NYSE", "NASDAQ", "TSE", "SSE", "SZSE", "LSE", "FRA", "AMS", "SIX", "TSX"}
-	for _, primaryExchange := range primaryExchanges {
-		if exchangeUpper == primaryExchange {
-			return 2
+	// Rate limits sometimes come back as a 200 with a message body
+	if strings.Contains(string(body), "Limit Reach") {
+		return domain.FXRate{}, fmt.Errorf("%w on exchange rate", marketdata.ErrRateLimited)
+	}
+	var rates []map[string]interface{}
+	if err := json.Unmarshal(body, &rates); err != nil {
+		return domain.FXRate{}, &marketdata.ParseError{What: currency + "USD rate", Err: err}
+	}
+	if len(rates) > 0 {
+		if rate, ok := rates[0]["price"].(float64); ok && rate > 0 {
+			return domain.FXRate{Currency: currency, Rate: rate, Time: fxQuoteTime(rates[0])}, nil
 		}
 	}
+	return domain.FXRate{}, fmt.Errorf("no %sUSD rate", currency)
+}
 
-	// Saudi Arabia exchange
-	if exchangeUpper == "SAU" || strings.Contains(exchangeUpper, "SAUDI") {
-		return 2
+// fxQuoteTime reads the quote time of an FX response, a Unix timestamp or a
+// UTC date and time, defaulting to now
+func fxQuoteTime(rate map[string]interface{}) time.Time {
+	if ts, ok := rate["timestamp"].(float64); ok && ts > 0 {
+		return time.Unix(int64(ts), 0).UTC()
 	}
-
-	// Regional exchanges
-	if exchangeUpper == "ASX" || exchangeUpper == "BSE" || exchangeUpper == "NSE" {
-		return 3
+	if date, ok := rate["date"].(string); ok {
+		if t, err := time.Parse(time.DateTime, date); err == nil {
+			return t
+		}
 	}
-
-	// Secondary markets
-	return 4
+	return time.Now()
 }
 
-func (c *FMPClient) getUSDExchangeRate(fromCurrency string) float64 {
-	if fromCurrency == "USD" {
-		return 1.0
-	}
-
-	// FIXED: Use hardcoded fallback rates for critical currencies when API fails
-	fallbackRates := map[string]float64{
-		"IDR": 0.0000625, // Indonesian Rupiah: ~16,000 IDR = 1 USD
-		"JPY": 0.0067,    // Japanese Yen: ~150 JPY = 1 USD
-		"KRW": 0.00075,   // Korean Won: ~1,330 KRW = 1 USD
-		"INR": 0.012,     // Indian Rupee: ~83 INR = 1 USD
-		"CNY": 0.14,      // Chinese Yuan: ~7.1 CNY = 1 USD
-		"HKD": 0.128,     // Hong Kong Dollar: ~7.8 HKD = 1 USD
-		"SAR": 0.267,     // Saudi Riyal: ~3.75 SAR = 1 USD
-		"AED": 0.272,     // UAE Dirham: ~3.67 AED = 1 USD
-		"THB": 0.028,     // Thai Baht: ~36 THB = 1 USD
-		"MYR": 0.224,     // Malaysian Ringgit: ~4.46 MYR = 1 USD
-		"PHP": 0.018,     // Philippine Peso: ~56 PHP = 1 USD
-		"VND": 0.00004,   // Vietnamese Dong: ~24,000 VND = 1 USD
-		"TWD": 0.031,     // Taiwan Dollar: ~32 TWD = 1 USD
-		"ZAR": 0.053,     // South African Rand: ~19 ZAR = 1 USD
-		"BRL": 0.20,      // Brazilian Real: ~5 BRL = 1 USD
-		"MXN": 0.058,     // Mexican Peso: ~17 MXN = 1 USD
-		"CLP": 0.0010,    // Chilean Peso: ~950 CLP = 1 USD
-		"COP": 0.00024,   // Colombian Peso: ~4,100 COP = 1 USD
-		"PEN": 0.27,      // Peruvian Sol: ~3.7 PEN = 1 USD
-		"ARS": 0.0010,    // Argentine Peso: ~1,000 ARS = 1 USD
-		"EGP": 0.032,     // Egyptian Pound: ~31 EGP = 1 USD
-		"TRY": 0.030,     // Turkish Lira: ~33 TRY = 1 USD
-		"ILS": 0.28,      // Israeli Shekel: ~3.6 ILS = 1 USD
-		"EUR": 1.08,      // Euro: ~0.92 EUR = 1 USD
-		"GBP": 1.27,      // British Pound: ~0.79 GBP = 1 USD
-		"CHF": 1.11,      // Swiss Franc: ~0.90 CHF = 1 USD
-		"CAD": 0.74,      // Canadian Dollar: ~1.35 CAD = 1 USD
-		"AUD": 0.64,      // Australian Dollar: ~1.56 AUD = 1 USD
-		"SEK": 0.094,     // Swedish Krona: ~10.6 SEK = 1 USD
-		"NOK": 0.092,     // Norwegian Krone: ~10.9 NOK = 1 USD
-		"DKK": 0.145,     // Danish Krone: ~6.9 DKK = 1 USD
-		"SGD": 0.74,      // Singapore Dollar: ~1.35 SGD = 1 USD
-	}
-
-	// Try API first (but skip if rate limited)
-	endpoint := fmt.Sprintf("/v3/fx/%sUSD", fromCurrency)
-	body, err := c.makeRequest(endpoint)
-	if err == nil {
-		// Check if response contains rate limit error
-		if strings.Contains(string(body), "Limit Reach") {
-			fmt.Printf("⚠️  API Rate Limited for %s exchange rate, using fallback\n", fromCurrency)
-		} else {
-			var rates []map[string]interface{}
-			if err := json.Unmarshal(body, &rates); err == nil {
-				if len(rates) > 0 {
-					if rate, ok := rates[0]["price"].(float64); ok && rate > 0 {
-						fmt.Printf("📊 Exchange Rate API: %s to USD = %.6f\n", fromCurrency, rate)
-						return rate
-					}
-				}
-			}
+// HistoricalFX returns FMP's daily close of the USD rate of currency on date,
+// or on the last trading day before it when markets were closed
+func (c *FMPClient) HistoricalFX(currency string, date time.Time) (domain.FXRate, error) {
+	day := date.UTC().Format(time.DateOnly)
+	from := date.UTC().AddDate(0, 0, -historicalFXDays).Format(time.DateOnly)
+	body, err := c.makeRequest(fmt.Sprintf("/v3/historical-price-full/%sUSD?from=%s&to=%s", currency, from, day))
+	if err != nil {
+		return domain.FXRate{}, err
+	}
+	var history struct {
+		Historical []struct {
+			Date  string  `json:"date"`
+			Close float64 `json:"close"`
+		} `json:"historical"`
+	}
+	if err := json.Unmarshal(body, &history); err != nil {
+		return domain.FXRate{}, &marketdata.ParseError{What: currency + "USD history", Err: err}
+	}
+	var rate domain.FXRate
+	// The latest close on or before the day, whatever order FMP lists them in
+	for _, bar := range history.Historical {
+		t, err := time.Parse(time.DateOnly, bar.Date)
+		if err != nil || bar.Close <= 0 || bar.Date > day || t.Before(rate.Time) {
+			continue
 		}
+		rate = domain.FXRate{Currency: currency, Rate: bar.Close, Time: t}
 	}
-
-	// CRITICAL: Use fallback rates when API fails
-	if fallbackRate, exists := fallbackRates[fromCurrency]; exists {
-		fmt.Printf("⚠️  Using fallback rate: %s to USD = %.6f (API failed)\n", fromCurrency, fallbackRate)
-		return fallbackRate
+	if rate.Rate == 0 {
+		return domain.FXRate{}, fmt.Errorf("no %sUSD rate between %s and %s", currency, from, day)
 	}
-
-	// Last resort: return 1.0 only for unknown currencies
-	fmt.Printf("❌ Unknown currency %s, defaulting to 1.0\n", fromCurrency)
-	return 1.0
+	return rate, nil
 }
 
-func (c *FMPClient) detectCurrency(symbol, country string) string {
-	// FIXED: Exchange-based detection for accurate currency mapping
+// historicalFXDays is how far before the snapshot date HistoricalFX looks
+// for a close, enough to cross a weekend and a holiday week
+const historicalFXDays = 7
 
-	// First check by exchange suffix or symbol pattern
-	symbolUpper := strings.ToUpper(symbol)
-	if strings.HasSuffix(symbolUpper, ".JO") || strings.Contains(symbolUpper, ".JNB") {
-		return "ZAR" // South African Rand for Johannesburg Stock Exchange
+// historicalRate returns the rate of currency on FXDate when it is set and
+// the provider has it; on failure the current rate is used
+func (c *FMPClient) historicalRate(currency string) (domain.FXRate, bool) {
+	if c.FXDate.IsZero() {
+		return domain.FXRate{}, false
 	}
-	if strings.HasSuffix(symbolUpper, ".HK") || strings.Contains(symbolUpper, ".HKSE") {
-		return "HKD" // Hong Kong Dollar
+	historical, ok := c.data().(marketdata.HistoricalFXer)
+	if !ok {
+		return domain.FXRate{}, false
 	}
-	if strings.HasSuffix(symbolUpper, ".SR") || strings.Contains(symbolUpper, ".SAU") {
-		return "SAR" // Saudi Riyal
+	c.historicalMu.Lock()
+	rate, done := c.historicalRates[currency]
+	c.historicalMu.Unlock()
+	if done {
+		return rate, rate.Rate > 0
 	}
-	if strings.HasSuffix(symbolUpper, ".KS") || strings.HasSuffix(symbolUpper, ".KQ") {
-		return "KRW" // Korean Won
+
+	rate, err := historical.HistoricalFX(currency, c.FXDate)
+	if err != nil {
+		c.Logger.Warn("historical exchange rate lookup failed, using the current rate",
+			"currency", currency, "date", c.FXDate.Format(time.DateOnly), "error", err)
+		rate = domain.FXRate{}
+	} else {
+		rate.Source = fx.SourceHistorical
 	}
-	if strings.HasSuffix(symbolUpper, ".T") {
-		return "JPY" // Japanese Yen
+	c.historicalMu.Lock()
+	if c.historicalRates == nil {
+		c.historicalRates = make(map[string]domain.FXRate)
 	}
-	if strings.HasSuffix(symbolUpper, ".L") || strings.HasSuffix(symbolUpper, ".LSE") {
-		return "GBP" // British Pound for London Stock Exchange
+	c.historicalRates[currency] = rate
+	c.historicalMu.Unlock()
+	return rate, rate.Rate > 0
+}
+
+// getUSDExchangeRate returns the provider's USD rate for fromCurrency, on
+// FXDate when set, falling back to the current rate, then to the fallback
+// provider's and then to fx's static rates when the lookups fail
+func (c *FMPClient) getUSDExchangeRate(fromCurrency string) domain.FXRate {
+	if fromCurrency == "USD" {
+		return domain.FXRate{Currency: "USD", Rate: 1.0}
 	}
-	if strings.HasSuffix(symbolUpper, ".TA") || strings.HasSuffix(symbolUpper, ".TLV") {
-		return "ILS" // Israeli Shekel
+	if rate, ok := c.historicalRate(fromCurrency); ok {
+		c.Logger.Debug("historical exchange rate", "currency", fromCurrency, "date", rate.Time.Format(time.DateOnly), "rate", rate.Rate)
+		return rate
 	}
 
-	// Currency mapping based on country (fallback)
-	currencyMap := map[string]string{
-		"US": "USD", "CA": "CAD", "GB": "GBP", "AU": "AUD", "NZ": "NZD",
-		"DE": "EUR", "FR": "EUR", "IT": "EUR", "ES": "EUR", "NL": "EUR",
-		"BE": "EUR", "AT": "EUR", "FI": "EUR", "IE": "EUR", "PT": "EUR",
-		"JP": "JPY", "CN": "CNY", "HK": "HKD", "SG": "SGD", "KR": "KRW",
-		"IN": "INR", "TH": "THB", "MY": "MYR", "ID": "IDR", "PH": "PHP",
-		"VN": "VND", "TW": "TWD", "CH": "CHF", "SE": "SEK", "NO": "NOK",
-		"DK": "DKK", "BR": "BRL", "MX": "MXN", "AR": "ARS", "CL": "CLP",
-		"CO": "COP", "PE": "PEN", "ZA": "ZAR", "EG": "EGP", "SA": "SAR",
-		"AE": "AED", "IL": "ILS", "TR": "TRY",
+	rate, err := marketdata.QuoteFX(c.data(), fromCurrency)
+	if err == nil {
+		c.Logger.Debug("exchange rate from API", "currency", fromCurrency, "rate", rate.Rate)
+		rate.Source = fx.SourceAPI
+		return rate
 	}
-
-	if currency, exists := currencyMap[country]; exists {
-		return currency
+	c.Logger.Debug("exchange rate lookup failed", "currency", fromCurrency, "error", err)
+
+	if c.Fallback != nil {
+		if rate, err := marketdata.QuoteFX(c.Fallback, fromCurrency); err == nil && rate.Rate > 0 {
+			c.Logger.Debug("exchange rate from fallback provider", "provider", c.Fallback.Name(), "currency", fromCurrency, "rate", rate.Rate)
+			metrics.FallbackLookups.Inc(c.Fallback.Name(), "fx")
+			rate.Source = fx.SourceFallback
+			return rate
+		}
 	}
 
-	return "USD"
-}
-
-func saveToJSON(data []AssetData, filename string) error {
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
+	// CRITICAL: Use fallback rates when API fails
+	if fallbackRate, exists := fx.FallbackRate(fromCurrency); exists {
+		c.Logger.Warn("using fallback exchange rate", "currency", fromCurrency, "rate", fallbackRate)
+		return domain.FXRate{Currency: fromCurrency, Rate: fallbackRate, Source: fx.SourceStatic}
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(data)
+	// Last resort: return 1.0 only for unknown currencies
+	c.Logger.Error("unknown currency, defaulting to 1.0", "currency", fromCurrency)
+	return domain.FXRate{Currency: fromCurrency, Rate: 1.0, Source: fx.SourceStatic}
 }
 
-func saveToCSV(data []AssetData, filename string) error {
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	// Write UTF-8 BOM for proper character encoding
-	file.WriteString("\xEF\xBB\xBF")
+// runSummary keeps what printSummary shows of a ranking read row by row
+type runSummary struct {
+	top       []domain.Asset // the ten largest
+	saudi     []domain.Asset // the five largest Saudi listings
+	countries map[string]int
+}
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+func newRunSummary() *runSummary {
+	return &runSummary{countries: make(map[string]int)}
+}
 
-	// Write header
-	header := []string{
-		"Rank", "Ticker", "Name", "Country", "Sector", "Industry",
-		"Market_Cap_USD", "Current_Price", "Previous_Close", "Percentage_Change",
-		"Volume", "Exchange", "Asset_Type",
+// add counts the next row; rows arrive largest first
+func (s *runSummary) add(asset domain.Asset) {
+	if len(s.top) < 10 {
+		s.top = append(s.top, asset)
 	}
-	if err := writer.Write(header); err != nil {
-		return err
-	}
-
-	// Write data
-	for i, asset := range data {
-		record := []string{
-			fmt.Sprintf("%d", i+1),
-			asset.Ticker,
-			cleanText(asset.Name),
-			asset.Country,
-			cleanText(asset.Sector),
-			cleanText(asset.Industry),
-			fmt.Sprintf("%.0f", asset.MarketCap),
-			fmt.Sprintf("%.2f", asset.CurrentPrice),
-			fmt.Sprintf("%.2f", asset.PreviousClose),
-			fmt.Sprintf("%.2f", asset.PercentageChange),
-			fmt.Sprintf("%.0f", asset.Volume),
-			asset.PrimaryExchange,
-			asset.AssetType,
-		}
-		if err := writer.Write(record); err != nil {
-			return err
-		}
+	if asset.Country == "SA" && len(s.saudi) < 5 {
+		s.saudi = append(s.saudi, asset)
 	}
-
-	return nil
+	s.countries[asset.Country]++
 }
 
-func printSummary(data []AssetData) {
+func printSummary(summary *runSummary) {
 	fmt.Printf("\n📊 TOP 10 STOCKS BY MARKET CAP:\n")
 	fmt.Printf("%-4s %-10s %-40s %-8s %-15s %15s\n", "Rank", "Ticker", "Company", "Country", "Exchange", "Market Cap")
 	fmt.Printf("%s\n", strings.Repeat("-", 100))
 
-	top10 := 10
-	if len(data) < 10 {
-		top10 = len(data)
-	}
-
-	for i := 0; i < top10; i++ {
-		asset := data[i]
+	for i, asset := range summary.top {
 		fmt.Printf("%-4d %-10s %-40s %-8s %-15s %15s\n",
 			i+1,
 			asset.Ticker,
-			truncateString(cleanText(asset.Name), 40),
+			truncateString(sink.CleanText(asset.Name), 40),
 			asset.Country,
 			asset.PrimaryExchange,
 			formatLargeNumber(asset.MarketCap))
 	}
 
 	// Country summary
-	countryCounts := make(map[string]int)
-	for _, asset := range data {
-		countryCounts[asset.Country]++
+	countryCounts := summary.countries
+	countries := make([]string, 0, len(countryCounts))
+	for country := range countryCounts {
+		countries = append(countries, country)
 	}
+	sort.Slice(countries, func(i, j int) bool {
+		if countryCounts[countries[i]] != countryCounts[countries[j]] {
+			return countryCounts[countries[i]] > countryCounts[countries[j]]
+		}
+		return countries[i] < countries[j]
+	})
 
 	fmt.Printf("\n🌍 STOCKS BY COUNTRY:\n")
-	for country, count := range countryCounts {
-		fmt.Printf("   %s: %d stocks\n", country, count)
+	for _, country := range countries {
+		fmt.Printf("   %s: %d stocks\n", country, countryCounts[country])
 	}
 
 	// Saudi Arabia specific summary
@@ -926,13 +1863,9 @@ func printSummary(data []AssetData) {
 	if saCount > 0 {
 		fmt.Printf("\n🇸🇦 SAUDI ARABIA STOCKS FOUND: %d\n", saCount)
 		fmt.Printf("   Top Saudi stocks:\n")
-		saStockCount := 0
-		for _, asset := range data {
-			if asset.Country == "SA" && saStockCount < 5 {
-				fmt.Printf("   %d. %s (%s) - $%.1fB\n",
-					saStockCount+1, asset.Name, asset.Ticker, asset.MarketCap/1e9)
-				saStockCount++
-			}
+		for i, asset := range summary.saudi {
+			fmt.Printf("   %d. %s (%s) - $%.1fB\n",
+				i+1, asset.Name, asset.Ticker, asset.MarketCap/1e9)
 		}
 	} else {
 		fmt.Printf("\n⚠️  No Saudi Arabia stocks found in top 500\n")
@@ -957,103 +1890,977 @@ func truncateString(s string, maxLen int) string {
 	return s[:maxLen-3] + "..."
 }
 
-func cleanText(text string) string {
-	// Remove any null bytes
-	text = strings.ReplaceAll(text, "\x00", "")
-
-	// Fix common encoding issues where German characters appear as Chinese characters
-	text = strings.ReplaceAll(text, "羹", "ü")
-	text = strings.ReplaceAll(text, "脛", "ä")
-	text = strings.ReplaceAll(text, "枚", "ö")
-	text = strings.ReplaceAll(text, "脽", "ß")
-
-	// Remove only ASCII control characters, keep all international characters
-	var result strings.Builder
-	for _, r := range text {
-		if r < 32 || r == 127 {
-			// Skip control characters
+// queueDeferred persists skipped profile lookups so a later drain can patch
+// them into the json output at configured, whose written files are keyed by
+// their configured path. When configured splits by country, each symbol is
+// queued against its country's file.
+func queueDeferred(client *FMPClient, queuePath, configured string, files map[string]string, rows sink.Rows, logger *slog.Logger) {
+	symbols := client.DeferredProfiles()
+	if len(symbols) == 0 {
+		return
+	}
+	if queuePath == "" {
+		logger.Warn("profile lookups skipped and queue disabled", "symbols", len(symbols))
+		return
+	}
+
+	fileOf := make(map[string]string, len(symbols))
+	for _, symbol := range symbols {
+		fileOf[symbol] = configured
+	}
+	if strings.Contains(configured, sink.CountryPlaceholder) {
+		// Symbols left out of the output have no file to patch
+		split := make(map[string]string, len(symbols))
+		err := rows.Each(func(asset domain.Asset) error {
+			if _, ok := fileOf[asset.Ticker]; ok {
+				split[asset.Ticker] = sink.CountryPath(configured, asset)
+			}
+			return nil
+		})
+		if err != nil {
+			logger.Error("failed to queue deferred profile lookups", "queue", queuePath, "error", err)
+			client.Report.Error(err)
+			return
+		}
+		fileOf = split
+	}
+
+	tasks := make([]enrich.Task, 0, len(symbols))
+	unwritten := 0
+	now := time.Now().UTC()
+	for _, symbol := range symbols {
+		path, ok := fileOf[symbol]
+		if !ok {
 			continue
 		}
-		result.WriteRune(r)
+		file, ok := files[path]
+		if !ok {
+			unwritten++
+			continue
+		}
+		// Store an absolute path so the drain can run from any directory
+		if abs, err := filepath.Abs(file); err == nil {
+			file = abs
+		}
+		tasks = append(tasks, enrich.Task{Kind: enrich.KindProfileImage, Symbol: symbol, Files: []string{file}, Enqueued: now})
 	}
+	if unwritten > 0 {
+		logger.Warn("profile lookups skipped and no json output written to queue them against", "symbols", unwritten)
+	}
+	if len(tasks) == 0 {
+		return
+	}
+	if err := enrich.NewQueue(queuePath).Push(tasks...); err != nil {
+		logger.Error("failed to queue deferred profile lookups", "queue", queuePath, "error", err)
+		client.Report.Error(err)
+		return
+	}
+	logger.Info("queued deferred profile lookups", "queue", queuePath, "symbols", len(tasks))
+}
 
-	return result.String()
+// jsonSinkPath returns the configured path of the first json file sink, or
+// "" when no sink writes json
+func jsonSinkPath(sinks []sink.OutputSink) string {
+	for _, s := range sinks {
+		if file, ok := s.(sink.FileSink); ok && file.Format() == "json" {
+			return file.Path()
+		}
+	}
+	return ""
 }
 
 func main() {
+	var logOpts logging.Options
+	logging.RegisterFlags(flag.CommandLine, &logOpts)
+	metricsAddr := flag.String("metrics-addr", "", "serve Prometheus metrics on this address (e.g. :9100)")
+	progressAddr := flag.String("progress-addr", "", "serve a live dashboard at / and SSE progress events at /events on this address (e.g. :9101)")
+	grpcAddr := flag.String("grpc-addr", "", "serve the AssetService gRPC API (run progress and the last saved snapshot) on this address (e.g. :9090)")
+	requestBudget := flag.Int("request-budget", 0, "API requests this run may spend, shown as remaining budget on the dashboard (0 = unknown)")
+	reportPath := flag.String("report", runreport.DefaultFilename, "write the JSON run report to this path (empty disables)")
+	cacheDir := flag.String("cache-dir", ".fmp_cache", "cache slow-changing API responses here (empty disables)")
+	cacheTTLs := flag.String("cache-ttl", httpcache.FormatTTLs(httpcache.DefaultTTLs), "per-endpoint cache TTLs as endpoint=duration,...")
+	quiet := flag.Bool("quiet", false, "do not draw progress bars on stderr (for CI; logs are still written)")
+	queuePath := flag.String("queue", enrich.DefaultQueuePath, "queue profile lookups skipped after the quota runs out for `datacollect drain` (empty disables)")
+	var sinkSpecs sink.Specs
+	flag.Var(&sinkSpecs, "sink", "write results to this sink; repeatable (json:PATH, csv:PATH, parquet:PATH, xlsx:PATH, supabase:PATH, postgres:[DSN], s3://BUCKET/KEY, gs://BUCKET/KEY, sqlite:PATH, gsheets:SPREADSHEET_ID, kafka://BROKER/TOPIC, nats://HOST:PORT/SUBJECT, redis://HOST:PORT, clickhouse://HOST:PORT/DATABASE, bigquery:PROJECT.DATASET.TABLE); {country} in a file name writes one file per country")
+	storeSpec := flag.String("store", "", "also append each run to this history store, one row per symbol and day (sqlite:PATH)")
+	fairSchedule := flag.Bool("fair-schedule", true, "interleave symbols across countries in the symbol stage, largest first per country (false processes them in arbitrary order)")
+	identifiers := flag.Bool("identifiers", true, "fetch ISIN/CIK from batched profile lookups and merge cross-listings by issuer (false matches normalized company names only)")
+	sharesFloat := flag.Bool("shares-float", true, "fetch share counts from FMP's bulk shares-float endpoint for float shares and free-float market caps")
+	enrichList := flag.String("enrich", "", "turn on optional enrichments, comma-separated: technicals (52-week high/low, 50/200-day averages and YTD return), ownership (institutional and top-10 holder ownership from 13F filings), reit (dividend yield, trailing FFO and price/FFO of REITs from quarterly cash flow statements)")
+	assetClass := flag.String("asset-class", "all", "collect only this asset class: all, or reit for REITs with the reit enrichment, written to global_reits_fmp.* unless --sink and --aggregates say otherwise")
+	var countryList string
+	flag.StringVar(&countryList, "countries", "", "collect only these markets, as comma-separated ISO country codes (e.g. US,GB,DE,JP; UK is accepted for GB); empty collects every default market")
+	flag.StringVar(&countryList, "country", "", "alias for --countries")
+	sectorList := flag.String("sectors", "", "collect only companies in these comma-separated sectors, by FMP or GICS name (e.g. Technology or \"Information Technology\")")
+	industryList := flag.String("industries", "", "collect only companies in these comma-separated industries, by FMP or GICS name (e.g. Semiconductors)")
+	excludeIndustryList := flag.String("exclude-industries", "", "leave out companies in these comma-separated industries, by FMP or GICS name (e.g. \"Banks - Regional\")")
+	symbolsFile := flag.String("symbols-file", "", "collect exactly the tickers in this CSV (its first column, or the one headed symbol or ticker) instead of screening the markets, written to NAME_fmp.* after the file unless --sink and --aggregates say otherwise")
+	watchlistName := flag.String("watchlist", "", "collect exactly the symbols of this watchlist, saved with datacollect watchlist, as --symbols-file does; written to NAME_fmp.*")
+	watchlistStore := flag.String("watchlists", watchlist.DefaultPath, "where --watchlist is kept: a JSON file or sqlite:PATH")
+	minRows := flag.String("min-rows", "", "fewest rows each country must return, e.g. US=2000,JP=800; a country below its gate is handled by --min-rows-policy")
+	minRowsPolicy := flag.String("min-rows-policy", GatePolicyFail, "what a run does when a country falls short of --min-rows: fail (write nothing) or previous (publish that country's rows from --previous-snapshot)")
+	previousSnapshot := flag.String("previous-snapshot", "", "JSON snapshot the previous policy takes rows from; {country} reads one file per country (default: the last file written by the first json sink)")
+	failOnMissingCountry := flag.Bool("fail-on-missing-country", false, "fail the run without writing outputs when a country's screen fails before returning any listing (by default the run continues and is marked partial)")
+	providerName := flag.String("provider", "fmp", "market data vendor: fmp, polygon (US stocks only, key in POLYGON_API_KEY) or eodhd (key in EODHD_API_KEY)")
+	providerMapPath := flag.String("provider-map", "", "JSON object choosing the provider per country, e.g. {\"EG\": \"eodhd\"}; countries it leaves out use --provider")
+	reconcileName := flag.String("reconcile", "", "instead of collecting, compare the universe, prices and market caps with this provider (fmp, polygon, eodhd or yahoo) and write a discrepancy report")
+	reconcileTolerance := flag.Float64("reconcile-tolerance", 0.05, "relative price or market cap difference --reconcile reports, e.g. 0.05 for 5%")
+	reconcilePath := flag.String("reconcile-report", "reconcile_report.json", "where --reconcile writes its report")
+	fallbackName := flag.String("fallback", "", "fill quotes and profiles the provider has none for from this provider (yahoo); rows it fills are tagged with data_source")
+	limit := flag.Int("limit", 0, "process only the largest N issuers in the symbol stage, ranked by screener market cap converted to USD (0 = all)")
+	top := flag.Int("top", 0, "keep only the N largest companies by USD market cap in the output (0 = all)")
+	topPercentile := flag.Float64("top-percentile", 0, "keep only the largest P percent of companies by USD market cap in the output, e.g. 5 (0 = all)")
+	topPerCountry := flag.Int("top-per-country", 0, "keep only the N largest companies by USD market cap of each country in the output (0 = all)")
+	capTierSpec := flag.String("cap-tiers", "", "override the USD market caps above which rows are tiered mega, large, mid and small, e.g. mega=300B,small=250M (default "+domain.DefaultCapTiers.String()+")")
+	minMarketCap := flag.Float64("min-market-cap", marketdata.MinMarketCap, "smallest company the screens return, in US dollars; converted to each market's currency for the screener")
+	minMarketCapMapPath := flag.String("min-market-cap-map", "", "JSON object of US dollar screen floors per country, e.g. {\"US\": 2e9, \"EG\": 20e6}; countries it leaves out use --min-market-cap")
+	dryRun := flag.Bool("dry-run", false, "print the estimated API calls, duration per FMP plan and the plan tier this run needs, without calling the API")
+	mock := flag.Bool("mock", false, "collect from an in-process mock of the FMP API and check the rows' count and schema instead of writing outputs (no API key needed)")
+	taxonomyPath := flag.String("taxonomy", "", "merge this JSON sector/industry mapping (sectors, industries) over the bundled GICS one")
+	aggregateShareClasses := flag.Bool("aggregate-share-classes", false, "fold an issuer's share classes listed in one country (GOOG and GOOGL, BRK-A and BRK-B) into one row with their summed market cap and a share_classes list")
+	spacs := flag.String("spacs", string(instrument.SPACTag), "how to treat SPACs and other blank-check companies: tag (instrument_type spac), exclude, or include as ordinary stocks")
+	exclusionsPath := flag.String("exclusions", "", "JSON exclusion rules (exclude_types, exclude_names, keep_names, exclude_symbol_suffixes); each list it sets replaces the bundled one")
+	exchangesPath := flag.String("exchanges", "", "merge this JSON exchange table (code, currency, mic, price_divisor, suffixes, trading hours) over the bundled one")
+	snapshotTime := flag.String("snapshot-time", "", "RFC 3339 time is_market_open and is_recent_ipo are evaluated at (default: when the run starts)")
+	recentIPODays := flag.Int("recent-ipo-days", domain.RecentIPODays, "flag rows that listed fewer than this many days before the snapshot time as is_recent_ipo")
+	aggregatesPrefix := flag.String("aggregates", "global_stocks_fmp", "write top movers, sector totals and breadth to PREFIX_movers.json, PREFIX_sectors.json and PREFIX_breadth.json (empty disables)")
+	topMovers := flag.Int("top-movers", aggregate.DefaultTopN, "gainers and losers listed per country in the movers file")
+	imageMinMarketCap := flag.Float64("image-min-market-cap", enrich.DefaultImageMinMarketCap, "look up the logo of every company worth at least this many USD (negative disables logos)")
+	imageCacheTTL := flag.Duration("image-cache-ttl", enrich.DefaultImageCacheTTL, "reuse logo URLs cached in --cache-dir for this long")
+	mirrorLogos := flag.Int("mirror-logos", 0, "download the logos of the largest N companies and point their image at the copy (0 disables)")
+	logoDir := flag.String("logo-dir", logos.DefaultDir, "directory --mirror-logos saves logos in, as SYMBOL.png")
+	logoStore := flag.String("logo-store", "", "also upload mirrored logos under this object store prefix (s3://BUCKET/PREFIX or gs://BUCKET/PREFIX)")
+	logoURL := flag.String("logo-url", "", "URL prefix written as the image of mirrored logos, e.g. a CDN in front of --logo-store (default: the local path or object URL)")
+	var notifySpecs notify.Specs
+	flag.Var(&notifySpecs, "notify", "post a run summary to this webhook when the run finishes or fails; repeatable (slack:URL, discord:URL, telegram:CHAT_ID), also read from NOTIFY_WEBHOOKS")
+	notifyErrorRate := flag.Float64("notify-error-rate", notify.DefaultErrorRate, "turn the summary into an alert when more than this share of API calls failed")
+	emailTo := flag.String("email-to", "", "email an HTML run report to these comma-separated addresses through the SMTP_* server")
+	emailArtifactURL := flag.String("email-artifact-url", "", "link output files in the email report under this URL, where the output directory is published")
+	timestamped := flag.Bool("timestamped-output", false, "write outputs with a UTC run timestamp in the name and keep the plain name as a link to the latest run")
+	keepRuns := flag.Int("keep-runs", 0, "after each run, delete all but the newest N dated runs of every output file, with their sidecars, or with --run-dir the newest N run directories (0 keeps all)")
+	runsRoot := flag.String("run-dir", "", "write each run's outputs, run report and manifest to a new DIR/<UTC run time> directory and point DIR/latest at the newest complete run")
+	record := flag.Bool("record", false, "archive the raw API responses of this run to --record-dir, or with --run-dir to the run directory's responses/, for --replay")
+	recordDir := flag.String("record-dir", replay.ResponsesDir, "where --record archives responses without --run-dir")
+	replayDir := flag.String("replay", "", "reprocess the API responses recorded in DIR, or in run directory DIR, instead of calling the API")
+	flag.Parse()
+
+	// Progress bars share stderr with the log, which is routed through them
+	var term *progress.Terminal
+	var logOut io.Writer = os.Stderr
+	if !*quiet {
+		term = progress.NewTerminal(os.Stderr, progress.Default)
+		logOut = term
+	}
+	logger, err := logging.SetupWriter(logOut, logOpts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid logging options: %v\n", err)
+		os.Exit(2)
+	}
+
+	if *metricsAddr != "" {
+		metricsErrs := metrics.Serve(*metricsAddr)
+		go func() {
+			logger.Error("metrics listener stopped", "addr", *metricsAddr, "error", <-metricsErrs)
+		}()
+		logger.Info("serving metrics", "addr", *metricsAddr, "path", "/metrics")
+	}
+
+	if *progressAddr != "" {
+		progressErrs := progress.Serve(*progressAddr, *requestBudget)
+		go func() {
+			logger.Error("progress listener stopped", "addr", *progressAddr, "error", <-progressErrs)
+		}()
+		logger.Info("serving progress dashboard", "addr", *progressAddr, "events", "/events")
+	}
+
 	if err := godotenv.Load(); err != nil {
-		log.Printf("Warning: No .env file found, using environment variables")
-	}
-
-	apiKey := os.Getenv("FMP_API_KEY")
-	if apiKey == "" {
-		log.Fatal("FMP_API_KEY environment variable is required")
-	}
-
-	client := NewFMPClient(apiKey)
-
-	fmt.Println("🌟 COMPREHENSIVE GLOBAL STOCK ANALYSIS - ENHANCED PARALLEL MULTITHREADING")
-	fmt.Println("📈 STRATEGY: 38 Country-Specific API Calls → Get ALL 50M+ companies → Convert to USD → Global ranking")
-	fmt.Println("🚀 Using FMP Stock Screener API with MAXIMUM PARALLEL PROCESSING!")
-	fmt.Println("⚡ PERFORMANCE OPTIMIZATIONS:")
-	fmt.Println("   🔄 12 Parallel Country Fetchers (vs 1 sequential)")
-	fmt.Println("   ⚡ 10 Parallel Stock Processors (optimized for rate limits)")
-	fmt.Println("   💱 Fallback Exchange Rates (32 currencies)")
-	fmt.Println("   🔥 Smart Rate Limiting (50ms to avoid API limits)")
-	fmt.Println("🌍 GUARANTEED GLOBAL COVERAGE:")
-	fmt.Println("   🇺🇸 US (3000) 🇭🇰 HK (800) 🇨🇳 CN (800) 🇯🇵 JP (500) 🇮🇳 IN (500)")
-	fmt.Println("   🇬🇧 UK (300) 🇨🇦 CA (300) 🇦🇺 AU (200) 🇰🇷 KR (200) 🇩🇪 DE (200)")
-	fmt.Println("   🇫🇷 FR (200) 🇧🇷 BR (200) 🇸🇦 SA (200) 🇹🇼 TW (150) 🇮🇹 IT (150)")
-	fmt.Println("   🇪🇸 ES (150) 🇳🇱 NL (100) 🇨🇭 CH (100) 🇸🇬 SG (100) + 20 more countries")
-	fmt.Println("✅ Includes: Tencent (HK), NVIDIA (US), Toyota (JP), Kweichow Moutai (CN), ASML (NL), Saudi Aramco (SA)")
-	fmt.Println("✅ Excludes: Russia, ADRs, ETFs, Index Funds")
-	fmt.Println("📊 RANKING: ALL 50M+ companies globally, ranked by USD market cap (no artificial limits)")
-	fmt.Println("💵 Market caps converted to USD for ranking (prices kept in original currency)")
-	fmt.Println()
+		logger.Warn("no .env file found, using environment variables")
+	}
+	if err := secrets.LoadEnv(context.Background(), logger); err != nil {
+		logger.Error("failed to load secrets", "error", err)
+		os.Exit(1)
+	}
 
-	startTime := time.Now()
-	var allAssets []AssetData
+	markets, err := ParseCountries(countryList)
+	if err != nil {
+		logger.Error("invalid countries", "error", err)
+		os.Exit(2)
+	}
+	if *minMarketCap <= 0 {
+		logger.Error("--min-market-cap must be positive", "min_market_cap", *minMarketCap)
+		os.Exit(2)
+	}
+	enrichments, err := enrich.ParseSet(*enrichList)
+	if err != nil {
+		logger.Error("invalid --enrich", "error", err)
+		os.Exit(2)
+	}
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	outputBase := "global_stocks_fmp"
+	switch *assetClass {
+	case "all":
+		*assetClass = ""
+	case instrument.REIT:
+		enrichments[enrich.REIT] = true
+		outputBase = "global_reits_fmp"
+		if !explicit["aggregates"] {
+			*aggregatesPrefix = outputBase
+		}
+	default:
+		logger.Error("invalid --asset-class, want all or reit", "asset_class", *assetClass)
+		os.Exit(2)
+	}
+	capTiers, err := domain.ParseCapTiers(*capTierSpec)
+	if err != nil {
+		logger.Error("invalid --cap-tiers", "error", err)
+		os.Exit(2)
+	}
+	if *top < 0 || *topPerCountry < 0 || *topPercentile < 0 || *topPercentile > 100 {
+		logger.Error("invalid rank cutoff, want --top and --top-per-country of 0 or more and --top-percentile from 0 to 100",
+			"top", *top, "top_per_country", *topPerCountry, "top_percentile", *topPercentile)
+		os.Exit(2)
+	}
+	// A symbols file or watchlist replaces the screens, so the flags that
+	// shape them have nothing to act on
+	var symbols []string
+	if *symbolsFile != "" || *watchlistName != "" {
+		if *symbolsFile != "" && *watchlistName != "" {
+			logger.Error("--symbols-file and --watchlist are mutually exclusive")
+			os.Exit(2)
+		}
+		for _, name := range []string{"countries", "country", "sectors", "industries", "exclude-industries", "min-market-cap", "min-market-cap-map", "min-rows", "provider-map", "limit", "top", "top-percentile", "top-per-country", "asset-class", "aggregate-share-classes", "reconcile", "mock"} {
+			if explicit[name] {
+				logger.Error("--" + name + " does not apply to --symbols-file or --watchlist")
+				os.Exit(2)
+			}
+		}
+		if *symbolsFile != "" {
+			if symbols, err = LoadSymbols(*symbolsFile); err != nil {
+				logger.Error("invalid --symbols-file", "error", err)
+				os.Exit(2)
+			}
+			outputBase = symbolsOutputBase(*symbolsFile)
+		} else {
+			if symbols, err = watchlist.Load(*watchlistStore, *watchlistName); err != nil {
+				logger.Error("invalid --watchlist", "error", err)
+				os.Exit(2)
+			}
+			outputBase = *watchlistName + "_fmp"
+		}
+		if !explicit["aggregates"] {
+			*aggregatesPrefix = outputBase
+		}
+	}
+	spacPolicy, err := instrument.ParseSPACPolicy(*spacs)
+	if err != nil {
+		logger.Error("invalid --spacs", "error", err)
+		os.Exit(2)
+	}
+	var countryMinMarketCaps map[string]float64
+	if *minMarketCapMapPath != "" {
+		if countryMinMarketCaps, err = LoadMinMarketCaps(*minMarketCapMapPath); err != nil {
+			logger.Error("invalid market cap map", "error", err)
+			os.Exit(2)
+		}
+	}
+	if *exchangesPath != "" {
+		if err := refdata.LoadExchanges(*exchangesPath); err != nil {
+			logger.Error("invalid exchange table", "error", err)
+			os.Exit(2)
+		}
+	}
+	if *taxonomyPath != "" {
+		if err := taxonomy.Load(*taxonomyPath); err != nil {
+			logger.Error("invalid taxonomy mapping", "error", err)
+			os.Exit(2)
+		}
+	}
+	// Names are parsed after --taxonomy, whose names may contain commas
+	sectorFilter := taxonomy.Filter{
+		Sectors:           taxonomy.ParseNames(*sectorList),
+		Industries:        taxonomy.ParseNames(*industryList),
+		ExcludeIndustries: taxonomy.ParseNames(*excludeIndustryList),
+	}
+	for _, names := range [][]string{sectorFilter.Sectors, sectorFilter.Industries, sectorFilter.ExcludeIndustries} {
+		for _, name := range names {
+			if !taxonomy.Known(name) {
+				logger.Warn("sector or industry not in the taxonomy, matching the screener's label only", "name", name)
+			}
+		}
+	}
+	if *exclusionsPath != "" {
+		if err := exclusion.Load(*exclusionsPath); err != nil {
+			logger.Error("invalid exclusion rules", "error", err)
+			os.Exit(2)
+		}
+	}
+	snapshot := time.Now()
+	if *snapshotTime != "" {
+		if snapshot, err = time.Parse(time.RFC3339, *snapshotTime); err != nil {
+			logger.Error("invalid --snapshot-time, want RFC 3339", "snapshot_time", *snapshotTime, "error", err)
+			os.Exit(2)
+		}
+	}
+	if *topMovers < 1 {
+		logger.Error("--top-movers must be at least 1", "top_movers", *topMovers)
+		os.Exit(2)
+	}
+	if *recentIPODays < 1 {
+		logger.Error("--recent-ipo-days must be at least 1", "recent_ipo_days", *recentIPODays)
+		os.Exit(2)
+	}
+	if *keepRuns < 0 {
+		logger.Error("--keep-runs must not be negative", "keep_runs", *keepRuns)
+		os.Exit(2)
+	}
+	if *runsRoot != "" && *timestamped {
+		logger.Error("--run-dir and --timestamped-output are mutually exclusive")
+		os.Exit(2)
+	}
+	if *record && *replayDir != "" {
+		logger.Error("--record and --replay are mutually exclusive")
+		os.Exit(2)
+	}
+	// A replay evaluates the rows at the snapshot time of the recorded run, so
+	// date-dependent requests ask for what the recording holds
+	var player *replay.Player
+	if *replayDir != "" {
+		if player, err = replay.Open(*replayDir); err != nil {
+			logger.Error("invalid --replay", "error", err)
+			os.Exit(2)
+		}
+		if *snapshotTime == "" {
+			snapshot = player.SnapshotTime
+		}
+	}
+	countryProviders := map[string]string{}
+	if *providerMapPath != "" {
+		if countryProviders, err = marketdata.LoadProviderMap(*providerMapPath); err != nil {
+			logger.Error("invalid provider map", "error", err)
+			os.Exit(2)
+		}
+	}
+	knownProviders := map[string]bool{"fmp": true, "polygon": true, "eodhd": true}
+	if !knownProviders[*providerName] {
+		logger.Error("unknown provider, want fmp, polygon or eodhd", "provider", *providerName)
+		os.Exit(2)
+	}
+	for country, name := range countryProviders {
+		if !knownProviders[name] {
+			logger.Error("unknown provider in provider map, want fmp, polygon or eodhd", "country", country, "provider", name)
+			os.Exit(2)
+		}
+	}
+	providerFor := func(country string) string {
+		if name, ok := countryProviders[country]; ok {
+			return name
+		}
+		return *providerName
+	}
+	if *providerName == "polygon" && strings.TrimSpace(countryList) == "" {
+		// Polygon covers the US only, so the default markets shrink to the US
+		// plus whatever the provider map sends elsewhere
+		codes := []string{"US"}
+		for code, name := range countryProviders {
+			if code != "US" && name != "polygon" {
+				codes = append(codes, code)
+			}
+		}
+		sort.Strings(codes[1:])
+		markets, _ = ParseCountries(strings.Join(codes, ","))
+	}
+	providersUsed := map[string]bool{*providerName: true} // the default also serves FX
+	for _, cfg := range markets {
+		name := providerFor(cfg.Code)
+		if name == "polygon" && cfg.Code != "US" {
+			logger.Error("Polygon covers US stocks only", "country", cfg.Code)
+			os.Exit(2)
+		}
+		providersUsed[name] = true
+	}
+	if *reconcileName != "" {
+		if !knownProviders[*reconcileName] && *reconcileName != "yahoo" {
+			logger.Error("unknown reconcile provider, want fmp, polygon, eodhd or yahoo", "reconcile", *reconcileName)
+			os.Exit(2)
+		}
+		if *reconcileName == *providerName && len(countryProviders) == 0 {
+			logger.Error("--reconcile needs a provider other than --provider", "provider", *providerName)
+			os.Exit(2)
+		}
+		if *reconcileTolerance <= 0 {
+			logger.Error("--reconcile-tolerance must be positive", "tolerance", *reconcileTolerance)
+			os.Exit(2)
+		}
+		providersUsed[*reconcileName] = true
+	}
+	if (*dryRun || *mock) && (len(providersUsed) > 1 || !providersUsed["fmp"]) {
+		logger.Error("--dry-run and --mock support only the fmp provider")
+		os.Exit(2)
+	}
+	if *fallbackName != "" && *fallbackName != "yahoo" {
+		logger.Error("unknown fallback provider, want yahoo", "fallback", *fallbackName)
+		os.Exit(2)
+	}
+	if *dryRun {
+		term.Stop()
+		client := &FMPClient{Countries: markets, Symbols: symbols, Limit: *limit, Identifiers: *identifiers, SharesFloat: *sharesFloat, Enrichments: enrichments, AssetClass: *assetClass}
+		printDryRun(os.Stdout, client.EstimateRun(), *limit, *identifiers)
+		return
+	}
+	if *mock {
+		os.Exit(runMock(logger, term, markets, *limit, *fairSchedule, *identifiers, *sharesFloat, enrichments))
+	}
 
-	fmt.Println("🌍 Fetching global stocks using FMP Stock Screener API...")
+	keys := apikeys.FromEnv()
+	polygonKey := os.Getenv("POLYGON_API_KEY")
+	eodhdKey := os.Getenv("EODHD_API_KEY")
+	if player != nil {
+		if keys.Len() == 0 {
+			keys = apikeys.Parse(replay.PlaceholderKey)
+		}
+		if polygonKey == "" {
+			polygonKey = replay.PlaceholderKey
+		}
+		if eodhdKey == "" {
+			eodhdKey = replay.PlaceholderKey
+		}
+	}
+	if providersUsed["polygon"] && polygonKey == "" {
+		logger.Error("POLYGON_API_KEY environment variable is required for the polygon provider")
+		os.Exit(1)
+	}
+	if providersUsed["eodhd"] && eodhdKey == "" {
+		logger.Error("EODHD_API_KEY environment variable is required for the eodhd provider")
+		os.Exit(1)
+	}
+	if providersUsed["fmp"] && keys.Len() == 0 {
+		logger.Error("FMP_API_KEY (or FMP_API_KEYS for a comma-separated pool) environment variable is required")
+		os.Exit(1)
+	}
 
-	globalStocks, err := client.GetGlobalStocks()
+	if len(sinkSpecs) == 0 {
+		sinkSpecs = sink.Specs{"json:" + outputBase + ".json", "csv:" + outputBase + ".csv"}
+	}
+	sinks, err := sink.ParseAll(sinkSpecs)
 	if err != nil {
-		log.Fatalf("❌ Failed to fetch global stocks: %v\n", err)
+		logger.Error("invalid sink", "error", err)
+		os.Exit(2)
+	}
+
+	if *grpcAddr != "" {
+		lis, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			logger.Error("failed to listen for gRPC", "addr", *grpcAddr, "error", err)
+			os.Exit(1)
+		}
+		// The server resolves the configured path on every load, so a {date}
+		// sink serves the latest dated snapshot
+		snapshot := jsonSinkPath(sinks)
+		if snapshot == "" || strings.Contains(snapshot, sink.CountryPlaceholder) {
+			logger.Warn("no single json sink for gRPC to serve snapshots from", "sink", snapshot)
+		}
+		snapshots := api.New(snapshot)
+		snapshots.Logger = logger
+		grpcServer := grpc.NewServer()
+		assetsrpc.Register(grpcServer, snapshots, progress.Default)
+		go func() {
+			logger.Error("gRPC listener stopped", "addr", *grpcAddr, "error", grpcServer.Serve(lis))
+		}()
+		logger.Info("serving gRPC", "addr", *grpcAddr)
 	}
 
-	allAssets = append(allAssets, globalStocks...)
+	rowGates, err := ParseRowGates(*minRows)
+	if err != nil {
+		logger.Error("invalid --min-rows", "error", err)
+		os.Exit(2)
+	}
+	switch *minRowsPolicy {
+	case GatePolicyFail:
+	case GatePolicyPrevious:
+		// A sink split by country is resolved one country file at a time
+		// when the fallback reads it
+		if path := jsonSinkPath(sinks); *previousSnapshot == "" && path != "" {
+			*previousSnapshot = path
+			if !strings.Contains(path, sink.CountryPlaceholder) {
+				*previousSnapshot = output.Resolve(path)
+			}
+		}
+		if *previousSnapshot == "" {
+			logger.Error("--min-rows-policy previous needs --previous-snapshot or a json sink")
+			os.Exit(2)
+		}
+	default:
+		logger.Error("invalid --min-rows-policy, want fail or previous", "policy", *minRowsPolicy)
+		os.Exit(2)
+	}
 
-	if len(allAssets) == 0 {
-		log.Fatal("❌ No stocks fetched successfully!")
+	if *storeSpec != "" {
+		store, err := sink.ParseStore(*storeSpec)
+		if err != nil {
+			logger.Error("invalid store", "error", err)
+			os.Exit(2)
+		}
+		sinks = append(sinks, store)
 	}
 
-	// Count stocks by country
-	countryCounts := make(map[string]int)
-	for _, asset := range allAssets {
-		countryCounts[asset.Country]++
+	var mirror *logos.Mirror
+	if *mirrorLogos < 0 {
+		logger.Error("--mirror-logos must not be negative", "mirror_logos", *mirrorLogos)
+		os.Exit(2)
+	}
+	if *mirrorLogos > 0 {
+		mirror = &logos.Mirror{Dir: *logoDir, BaseURL: *logoURL, Workers: 8, Logger: logger}
+		switch {
+		case strings.HasPrefix(*logoStore, "s3://"):
+			mirror.Store, err = sink.NewS3(*logoStore)
+		case strings.HasPrefix(*logoStore, "gs://"):
+			mirror.Store, err = sink.NewGCS(*logoStore)
+		case *logoStore != "":
+			err = fmt.Errorf("want s3://bucket/prefix or gs://bucket/prefix, got %q", *logoStore)
+		}
+		if err != nil {
+			logger.Error("invalid logo store", "error", err)
+			os.Exit(2)
+		}
 	}
 
-	fmt.Printf("\n📊 Retrieved %d stocks from %d countries\n", len(allAssets), len(countryCounts))
+	notifier, err := notify.New(notifySpecs, *notifyErrorRate, logger)
+	if err != nil {
+		logger.Error("invalid notification target", "error", err)
+		os.Exit(2)
+	}
+	var recipients []string
+	for _, address := range strings.Split(*emailTo, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			recipients = append(recipients, address)
+		}
+	}
+	mailer, err := notify.NewEmail(recipients, logger)
+	if err != nil {
+		logger.Error("invalid email report settings", "error", err)
+		os.Exit(2)
+	}
 
-	filename := "global_stocks_fmp.json"
-	if err := saveToJSON(allAssets, filename); err != nil {
-		log.Printf("Failed to save to file: %v", err)
-	} else {
-		fmt.Printf("💾 Data saved to %s\n", filename)
+	signingKey, err := integrity.SigningKeyFromEnv()
+	if err != nil {
+		logger.Error("failed to load signing key", "error", err)
+		os.Exit(1)
 	}
 
-	csvFilename := "global_stocks_fmp.csv"
-	if err := saveToCSV(allAssets, csvFilename); err != nil {
-		log.Printf("Failed to save to CSV file: %v", err)
-	} else {
-		fmt.Printf("💾 Data saved to %s\n", csvFilename)
+	// runDir is this run's directory under --run-dir, created once the
+	// collection starts
+	var runDir *output.RunDir
+
+	report := runreport.New("get_companies")
+	writeReport := func() {
+		if *reportPath == "" {
+			return
+		}
+		path := *reportPath
+		if runDir != nil {
+			path = runDir.Path(path)
+		}
+		if err := report.Write(path); err != nil {
+			logger.Error("failed to write run report", "file", path, "error", err)
+		} else if err := integrity.Seal(path, signingKey); err != nil {
+			logger.Error("failed to seal run report", "file", path, "error", err)
+		} else {
+			logger.Info("run report saved", "file", path)
+		}
+	}
+
+	// notifyRun posts the run summary and emails the report once the report
+	// is complete; summary is nil when the run failed before ranking
+	var links []notify.Artifact
+	notifyRun := func(rows int, failure error, movers *aggregate.Movers, summary *runSummary) {
+		s := notify.Summary{Run: report.Build(), Rows: rows, Failure: failure}
+		if movers != nil {
+			s.Gainers, s.Losers = movers.Overall(notify.DefaultMovers)
+		}
+		notifier.Notify(s)
+
+		email := notify.EmailReport{
+			Summary:   s,
+			Artifacts: append(notify.FileArtifacts(s.Run.Outputs, *emailArtifactURL), links...),
+		}
+		if summary != nil {
+			email.Countries = summary.countries
+			for _, asset := range summary.top {
+				asset.Name = sink.CleanText(asset.Name)
+				email.Top = append(email.Top, asset)
+			}
+		}
+		mailer.Send(email)
+	}
+
+	// outputPath returns where to write an output normally found at name, and
+	// publish records, seals and (for timestamped runs) links the written file,
+	// then prunes old runs with --keep-runs; run directories are pruned when
+	// the run is published
+	runTime := time.Now()
+	outputPath := func(name string) string {
+		if runDir != nil {
+			return runDir.Path(name)
+		}
+		if *timestamped {
+			return output.Timestamped(name, runTime)
+		}
+		output.Detach(name)
+		return name
+	}
+	publish := func(name, path string) {
+		logger.Info("data saved", "file", path)
+		report.Error(report.AddOutput(path))
+		report.Error(integrity.Seal(path, signingKey))
+		if *timestamped {
+			report.Error(output.Latest(name, path))
+		}
+		if *keepRuns > 0 && runDir == nil {
+			deleted, err := output.Prune(name, *keepRuns)
+			report.Error(err)
+			if len(deleted) > 0 {
+				logger.Info("old runs deleted", "output", name, "deleted", len(deleted), "kept", *keepRuns)
+			}
+		}
+	}
+
+	client := NewFMPClient(keys)
+	client.Logger = logger
+	if base := os.Getenv("FMP_BASE_URL"); base != "" {
+		client.BaseURL = strings.TrimSuffix(base, "/") + "/api"
+	}
+	client.Report = report
+	client.FairSchedule = *fairSchedule
+	client.Identifiers = *identifiers
+	client.SharesFloat = *sharesFloat
+	client.Enrichments = enrichments
+	client.AssetClass = *assetClass
+	client.Sectors = sectorFilter
+	client.SPACs = spacPolicy
+	client.AggregateShareClasses = *aggregateShareClasses
+	client.Countries = markets
+	client.Symbols = symbols
+	client.MinMarketCap = *minMarketCap
+	client.CountryMinMarketCaps = countryMinMarketCaps
+	client.Limit = *limit
+	client.Cutoff = Cutoff{Top: *top, TopPercentile: *topPercentile, TopPerCountry: *topPerCountry}
+	client.CapTiers = capTiers
+	client.SnapshotTime = snapshot
+	// A snapshot of a past day converts at that day's closing rates. A replay
+	// compares with the day it was recorded, so it asks for what it recorded.
+	fetchedAt := time.Now()
+	if player != nil {
+		fetchedAt = player.RecordedAt
+	}
+	if snapshot.UTC().Format(time.DateOnly) < fetchedAt.UTC().Format(time.DateOnly) {
+		client.FXDate = snapshot
+		logger.Info("converting at historical exchange rates", "date", snapshot.UTC().Format(time.DateOnly))
+	}
+	client.RecentIPODays = *recentIPODays
+
+	if player != nil {
+		client.HTTPClient.Transport = player
+		logger.Info("replaying recorded responses", "dir", player.Dir, "recorded_at", player.RecordedAt)
+	} else if *cacheDir != "" {
+		ttls, err := httpcache.ParseTTLs(*cacheTTLs)
+		if err != nil {
+			logger.Error("invalid cache TTLs", "error", err)
+			os.Exit(2)
+		}
+		transport, err := httpcache.New(*cacheDir, ttls, client.HTTPClient.Transport)
+		if err != nil {
+			logger.Error("failed to set up response cache", "dir", *cacheDir, "error", err)
+			os.Exit(1)
+		}
+		client.HTTPClient.Transport = transport
+		logger.Info("response cache enabled", "dir", *cacheDir, "ttls", *cacheTTLs)
+	}
+
+	var images *enrich.Images
+	if *imageMinMarketCap >= 0 {
+		images = &enrich.Images{MinMarketCap: *imageMinMarketCap, Lookup: client.LookupImages, Logger: logger}
+		if *cacheDir != "" {
+			if images.Cache, err = enrich.LoadImageCache(filepath.Join(*cacheDir, enrich.ImageCacheFile), *imageCacheTTL); err != nil {
+				logger.Warn("ignoring logo cache", "error", err)
+			}
+		}
+	}
+
+	providers := map[string]marketdata.Provider{"fmp": client}
+	if providersUsed["polygon"] {
+		polygon := marketdata.NewPolygon(polygonKey)
+		polygon.Logger = logger
+		polygon.HTTPClient = client.HTTPClient
+		if base := os.Getenv("POLYGON_BASE_URL"); base != "" {
+			polygon.BaseURL = strings.TrimSuffix(base, "/")
+		}
+		providers["polygon"] = polygon
+	}
+	if providersUsed["eodhd"] {
+		eodhd := marketdata.NewEODHD(eodhdKey)
+		eodhd.HTTPClient = client.HTTPClient
+		if base := os.Getenv("EODHD_BASE_URL"); base != "" {
+			eodhd.BaseURL = strings.TrimSuffix(base, "/")
+		}
+		providers["eodhd"] = eodhd
+	}
+	if *providerName != "fmp" {
+		client.Data = providers[*providerName]
+	}
+	routes := make(map[string]marketdata.Provider)
+	for _, cfg := range markets {
+		if name := providerFor(cfg.Code); name != *providerName {
+			routes[cfg.Code] = providers[name]
+		}
+	}
+	if len(routes) > 0 {
+		client.Data = marketdata.NewRouter(client.data(), routes)
+	}
+	if *fallbackName == "yahoo" || providersUsed["yahoo"] {
+		yahoo := marketdata.NewYahoo()
+		if base := os.Getenv("YAHOO_BASE_URL"); base != "" {
+			yahoo.BaseURL = strings.TrimSuffix(base, "/")
+			yahoo.CookieURL = yahoo.BaseURL
+		}
+		providers["yahoo"] = yahoo
+	}
+	if *fallbackName == "yahoo" {
+		client.Fallback = providers["yahoo"]
+	}
+
+	if *reconcileName != "" {
+		term.Stop()
+		os.Exit(runReconcile(logger, client, providers[*reconcileName], *reconcileTolerance, *reconcilePath))
+	}
+
+	strategy := "country screeners -> 50M+ companies -> USD conversion -> global ranking"
+	if len(symbols) > 0 {
+		strategy = "symbols file -> profiles -> USD conversion -> ranking"
+	}
+	logger.Info("starting global stock collection", "provider", client.data().Name(), "fallback", *fallbackName,
+		"strategy", strategy, "symbols", len(symbols), "countries", len(markets), "country_workers", countryWorkers, "stock_workers", symbolWorkers, "fair_schedule", client.FairSchedule)
+
+	startTime := time.Now()
+
+	// finishProgress announces the end of the run and gives stream clients a
+	// moment to receive it before the process exits
+	progress.Publish(progress.Event{Type: progress.RunStarted, Message: "get_companies"})
+	finishProgress := func(count int, err error) {
+		e := progress.Event{Type: progress.RunFinished, Count: count}
+		if err != nil {
+			e.Message = err.Error()
+		}
+		progress.Publish(e)
+		term.Stop()
+		if *progressAddr != "" {
+			time.Sleep(time.Second)
+		}
+	}
+
+	if *runsRoot != "" {
+		dir, err := output.CreateRunDir(*runsRoot, runTime)
+		if err != nil {
+			logger.Error("failed to create run directory", "error", err)
+			os.Exit(1)
+		}
+		runDir = &dir
+		logger.Info("writing run directory", "dir", dir.Dir())
+	}
+	var recorder *replay.Recorder
+	if *record {
+		dir := *recordDir
+		if runDir != nil {
+			dir = runDir.Path(replay.ResponsesDir)
+		}
+		if recorder, err = replay.NewRecorder(dir, snapshot, client.HTTPClient.Transport); err != nil {
+			logger.Error("failed to start recording", "error", err)
+			os.Exit(1)
+		}
+		client.HTTPClient.Transport = recorder
+		logger.Info("recording API responses", "dir", dir)
+	}
+
+	ranked, err := client.StreamGlobalStocks()
+	if err != nil {
+		logger.Error("failed to fetch global stocks", "error", err)
+		report.Error(err)
+		writeReport()
+		finishProgress(0, err)
+		notifyRun(0, err, nil, nil)
+		os.Exit(1)
+	}
+
+	for _, failure := range ranked.Failed {
+		logger.Warn("country incomplete", "country", failure.Country, "partial", failure.Partial,
+			"kind", marketdata.Classify(failure.Err))
+	}
+	if missing := ranked.Missing(); len(missing) > 0 && *failOnMissingCountry {
+		err := fmt.Errorf("no listings for %s", strings.Join(missing, ", "))
+		logger.Error("countries missing from the run, not writing outputs", "countries", missing)
+		report.Error(err)
+		writeReport()
+		finishProgress(0, err)
+		notifyRun(0, err, nil, nil)
+		ranked.Close()
+		os.Exit(1)
+	}
+
+	if len(rowGates) > 0 {
+		counts, err := countRows(ranked.Spool)
+		short := rowGates.Short(counts)
+		if err == nil && len(short) > 0 {
+			for _, country := range short {
+				logger.Warn("country below its row gate", "country", country, "rows", counts[country], "expected", rowGates[country])
+			}
+			if *minRowsPolicy == GatePolicyPrevious {
+				err = fallBackToPrevious(ranked, short, counts, rowGates, *previousSnapshot, report, logger)
+			} else {
+				err = fmt.Errorf("countries below their row gates: %s", strings.Join(short, ", "))
+			}
+		}
+		if err != nil {
+			logger.Error("row gates failed, not writing outputs", "error", err)
+			report.Error(err)
+			writeReport()
+			finishProgress(0, err)
+			notifyRun(0, err, nil, nil)
+			ranked.Close()
+			os.Exit(1)
+		}
+	}
+
+	if ranked.Len() == 0 {
+		logger.Error("no stocks fetched successfully")
+		err := errors.New("no stocks fetched")
+		report.Error(err)
+		writeReport()
+		finishProgress(0, err)
+		notifyRun(0, err, nil, nil)
+		ranked.Close()
+		os.Exit(1)
+	}
+
+	// One pass over the ranking gathers the summary, the per-country counts
+	// and the aggregates; the outputs read it again row by row
+	summary := newRunSummary()
+	var derived *aggregate.Accumulator
+	if *aggregatesPrefix != "" {
+		derived = aggregate.NewAccumulator(*topMovers)
+	}
+	countries := make([]string, 0, ranked.Len())
+	var wantImages []string
+	logoURLs := make(map[string]string)
+	report.Error(ranked.Each(func(asset domain.Asset) error {
+		summary.add(asset)
+		if derived != nil {
+			derived.Add(asset)
+		}
+		if images != nil && images.Wants(asset) {
+			wantImages = append(wantImages, asset.Ticker)
+		}
+		if mirror != nil && len(countries) < *mirrorLogos {
+			logoURLs[asset.Ticker] = asset.Image
+		}
+		countries = append(countries, asset.Country)
+		return nil
+	}))
+	report.CountSymbols(countries)
+
+	logger.Info("retrieved stocks", "stocks", ranked.Len(), "countries", len(summary.countries))
+
+	// Logos are looked up once the ranking is known, then optionally mirrored;
+	// either replaces the row's image in every output
+	found := make(map[string]string)
+	if images != nil {
+		var result enrich.ImageResult
+		found, result = images.Resolve(wantImages)
+		logger.Info("looked up logos", "wanted", result.Wanted, "cached", result.Cached, "fetched", result.Fetched,
+			"missing", result.Missing, "deferred", len(result.Deferred))
+		client.DeferProfiles(result.Deferred)
+		if err := images.Cache.Save(); err != nil {
+			logger.Warn("failed to save logo cache", "error", err)
+		}
+	}
+	for symbol := range logoURLs {
+		if image, ok := found[symbol]; ok {
+			logoURLs[symbol] = image
+		}
+	}
+	if mirror != nil {
+		located, result := mirror.Mirror(logoURLs)
+		logger.Info("mirrored logos", "downloaded", result.Downloaded, "reused", result.Reused, "failed", result.Failed)
+		maps.Copy(found, located)
+	}
+	var rows sink.Rows = ranked
+	if len(found) > 0 {
+		rows = sink.Map(ranked, func(asset domain.Asset) domain.Asset {
+			if image, ok := found[asset.Ticker]; ok {
+				asset.Image = image
+			}
+			return asset
+		})
 	}
 
-	printSummary(allAssets)
+	// Deferred profile lookups are patched into the first json sink's files
+	jsonFiles := make(map[string]string)
+	outputs := &sink.Multi{
+		Sinks:  sinks,
+		Logger: logger,
+		Path:   outputPath,
+		Written: func(configured string, written sink.FileSink) {
+			publish(configured, written.Path())
+			if written.Format() == "json" {
+				jsonFiles[configured] = written.Path()
+			}
+		},
+		Linked: func(written sink.OutputSink, link string) {
+			links = append(links, notify.Artifact{Name: written.String(), URL: link})
+		},
+	}
+	report.Error(outputs.WriteRows(rows))
+	queueDeferred(client, *queuePath, jsonSinkPath(sinks), jsonFiles, ranked, logger)
+	var movers *aggregate.Movers
+	if derived != nil {
+		result := derived.Result(snapshot)
+		report.Error(result.Write(*aggregatesPrefix, outputPath, publish))
+		movers = &result.Movers
+	}
+	report.Error(ranked.Close())
+
+	// The summary goes to stdout, so the bars must stop redrawing first
+	term.Stop()
+	printSummary(summary)
+	writeReport()
+	if recorder != nil {
+		if err := recorder.Close(); err != nil {
+			logger.Warn("failed to finish recording", "dir", recorder.Dir, "error", err)
+		}
+	}
+	if runDir != nil {
+		manifest := output.ManifestFor(report.Build(), client.data().Name(), ranked.Len(), flag.CommandLine)
+		if recorder != nil {
+			manifest.Responses = recorder.Dir
+		}
+		if player != nil {
+			manifest.ReplayOf = player.Dir
+		}
+		if err := runDir.Finish(manifest); err != nil {
+			logger.Error("failed to publish run directory", "dir", runDir.Dir(), "error", err)
+		} else {
+			logger.Info("run directory published", "dir", runDir.Dir(), "latest", filepath.Join(*runsRoot, output.LatestRun))
+			deleted, err := output.PruneRunDirs(*runsRoot, *keepRuns)
+			if err != nil {
+				logger.Warn("failed to delete old run directories", "error", err)
+			} else if len(deleted) > 0 {
+				logger.Info("old run directories deleted", "deleted", len(deleted), "kept", *keepRuns)
+			}
+		}
+	}
 
-	duration := time.Since(startTime)
-	fmt.Printf("\n🎉 Total processing time: %v\n", duration)
-	fmt.Printf("🌟 Retrieved stock data from worldwide markets using ENHANCED PARALLEL PROCESSING!\n")
-	fmt.Printf("🌍 Comprehensive global stock coverage including Saudi Arabia - NO HARDCODING!\n")
-	fmt.Printf("🚀 Optimized performance with 12 country workers + 10 stock processors (rate-limit friendly)!\n")
+	keys.LogUsage(logger)
+	logger.Info("collection complete", "duration", time.Since(startTime).String())
+	finishProgress(ranked.Len(), nil)
+	notifyRun(ranked.Len(), nil, movers, summary)
 }
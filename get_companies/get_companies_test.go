@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"algotradar/apikeys"
+	"algotradar/domain"
+	"algotradar/instrument"
+	"algotradar/marketdata"
+	"algotradar/mockfmp"
+	"algotradar/runreport"
+)
+
+// selftestSnapshot is the snapshot time datacollect selftest records the
+// golden files at
+var selftestSnapshot = time.Date(2025, 7, 2, 14, 0, 0, 0, time.UTC)
+
+// newTestClient returns a client of the server at url that logs nowhere
+func newTestClient(url string) *FMPClient {
+	client := NewFMPClient(apikeys.Parse("test"))
+	client.BaseURL = url + "/api"
+	client.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	client.Report = runreport.New("get_companies")
+	return client
+}
+
+func readGolden(t *testing.T, name string, v any) {
+	t.Helper()
+	golden, err := mockfmp.Golden(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(golden, v); err != nil {
+		t.Fatalf("invalid golden file %s: %v", name, err)
+	}
+}
+
+func TestGetGlobalStocksGolden(t *testing.T) {
+	mock, err := mockfmp.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(mock)
+	defer srv.Close()
+
+	// Configured as datacollect selftest runs the collector, defaults and all;
+	// the revoked key is rotated out on its first 401
+	client := newTestClient(srv.URL)
+	client.Keys = apikeys.Parse(mockfmp.RevokedKey + ",selftest")
+	client.FairSchedule = true
+	client.Identifiers = true
+	client.SharesFloat = true
+	client.SPACs = instrument.SPACTag
+	client.Countries = DefaultCountries
+	client.MinMarketCap = marketdata.MinMarketCap
+	client.RecentIPODays = domain.RecentIPODays
+	client.SnapshotTime = selftestSnapshot
+	client.FXDate = selftestSnapshot
+
+	got, err := client.GetGlobalStocks()
+	if err != nil {
+		t.Fatalf("GetGlobalStocks = %v", err)
+	}
+	var want []domain.Asset
+	readGolden(t, "global_stocks_fmp.json", &want)
+	// Logos are looked up by main once the ranking is known
+	for i := range want {
+		want[i].Image = ""
+	}
+	if len(got) != len(want) {
+		t.Errorf("GetGlobalStocks returned %d assets, want %d", len(got), len(want))
+	}
+	for i := 0; i < min(len(want), len(got)); i++ {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			g, _ := json.Marshal(got[i])
+			w, _ := json.Marshal(want[i])
+			t.Errorf("rank %d:\n  got  %s\n  want %s", i+1, g, w)
+		}
+	}
+
+	var wantDropped []domain.DroppedSymbol
+	readGolden(t, "global_stocks_fmp_dropped.json", &wantDropped)
+	if dropped := client.Report.Build().Dropped; !reflect.DeepEqual(dropped, wantDropped) {
+		t.Errorf("dropped %v, want %v", dropped, wantDropped)
+	}
+}
+
+func TestLocalFloor(t *testing.T) {
+	// GBP has a historical and a current rate, EUR only a current one, JPY
+	// neither; NZD has no fallback rate either
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/historical-price-full/GBPUSD":
+			io.WriteString(w, `{"symbol":"GBPUSD","historical":[{"date":"2025-07-01","close":1.25}]}`)
+		case "/api/v3/fx/GBPUSD":
+			io.WriteString(w, `[{"ticker":"GBP/USD","price":1.6}]`)
+		case "/api/v3/fx/EURUSD":
+			io.WriteString(w, `[{"ticker":"EUR/USD","price":1.25}]`)
+		case "/api/v3/fx/JPYUSD":
+			io.WriteString(w, `[]`)
+		default:
+			io.WriteString(w, `{}`)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		country string
+		fxDate  time.Time
+		want    float64
+	}{
+		{"US dollars", "US", selftestSnapshot, 1000},
+		{"historical rate", "GB", selftestSnapshot, 800},
+		{"current rate without a snapshot date", "GB", time.Time{}, 625},
+		{"current rate without a historical one", "DE", selftestSnapshot, 800},
+		{"fallback rate", "JP", selftestSnapshot, 1000 / 0.0067},
+		{"no rate", "NZ", selftestSnapshot, 1000},
+	}
+	for _, tt := range tests {
+		client := newTestClient(srv.URL)
+		client.FXDate = tt.fxDate
+		if got := client.localFloor(tt.country, 1000); got != tt.want {
+			t.Errorf("%s: localFloor(%q, 1000) = %v, want %v", tt.name, tt.country, got, tt.want)
+		}
+	}
+}
+
+func TestDedupeListings(t *testing.T) {
+	listing := func(symbol, name, exchange, country string, marketCap float64) FMPStockScreener {
+		return FMPStockScreener{Symbol: symbol, CompanyName: name, ExchangeShortName: exchange, Country: country, MarketCap: marketCap}
+	}
+	shell := listing("SHEL.L", "Shell plc", "LSE", "GB", 160e9)
+	shellOTC := listing("SHLLF", "Shell plc", "OTC", "US", 190e9)
+	tencent := listing("0700.HK", "Tencent Holdings Limited", "HKSE", "HK", 3.6e12)
+	tencentADR := listing("TCEHY", "Tencent Holdings Limited", "PNK", "US", 460e9)
+	googl := listing("GOOGL", "Alphabet Inc.", "NASDAQ", "US", 2.1e12)
+	goog := listing("GOOG", "Alphabet Inc.", "NASDAQ", "US", 2.1e12)
+
+	tests := []struct {
+		name        string
+		aggregate   bool
+		stocks      []FMPStockScreener
+		wantKept    []string
+		wantDropped []string
+	}{
+		{"distinct issuers", false, []FMPStockScreener{shell, tencent}, []string{"0700.HK", "SHEL.L"}, nil},
+		{"main board over a larger cross-listing", false, []FMPStockScreener{shellOTC, shell}, []string{"SHEL.L"}, []string{"SHLLF"}},
+		{"ordinary share over an ADR", false, []FMPStockScreener{tencentADR, tencent}, []string{"0700.HK"}, []string{"TCEHY"}},
+		{"share classes dropped", false, []FMPStockScreener{googl, goog}, []string{"GOOG"}, []string{"GOOGL"}},
+		{"share classes aggregated", true, []FMPStockScreener{googl, goog}, []string{"GOOG", "GOOGL"}, nil},
+	}
+	for _, tt := range tests {
+		client := newTestClient("http://127.0.0.1:0")
+		client.AggregateShareClasses = tt.aggregate
+		var kept, dropped []string
+		for _, stock := range client.dedupeListings(tt.stocks) {
+			kept = append(kept, stock.Symbol)
+		}
+		for _, d := range client.Report.Build().Dropped {
+			if d.Reason != "secondary_listing" {
+				t.Errorf("%s: %s dropped as %s, want secondary_listing", tt.name, d.Symbol, d.Reason)
+			}
+			dropped = append(dropped, d.Symbol)
+		}
+		if !reflect.DeepEqual(kept, tt.wantKept) {
+			t.Errorf("%s: kept %v, want %v", tt.name, kept, tt.wantKept)
+		}
+		if !reflect.DeepEqual(dropped, tt.wantDropped) {
+			t.Errorf("%s: dropped %v, want %v", tt.name, dropped, tt.wantDropped)
+		}
+	}
+}
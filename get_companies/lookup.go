@@ -0,0 +1,77 @@
+package main
+
+import (
+	"golang.org/x/sync/singleflight"
+
+	"algotradar/domain"
+	"algotradar/lru"
+	"algotradar/marketdata"
+	"algotradar/metrics"
+)
+
+// lookupCacheSize bounds the quotes and profiles remembered per run, enough
+// for every symbol of a full global collection
+const lookupCacheSize = 100_000
+
+// lookupResult is a remembered quote or profile lookup; failures are
+// remembered too, so a symbol is asked for at most once per run
+type lookupResult struct {
+	value any
+	err   error
+}
+
+// lookupGroup deduplicates quote and profile requests. A symbol can appear in
+// several countries' screens before deduplication; concurrent lookups of it
+// share one request and later ones are answered from the cache.
+type lookupGroup struct {
+	flight singleflight.Group
+	cache  *lru.Cache[string, lookupResult]
+}
+
+func newLookupGroup() *lookupGroup {
+	return &lookupGroup{cache: lru.New[string, lookupResult](lookupCacheSize)}
+}
+
+// do returns the result of fetch for key, calling it only if no earlier or
+// concurrent lookup of key did
+func (l *lookupGroup) do(kind, key string, fetch func() (any, error)) (any, error) {
+	if r, ok := l.cache.Get(key); ok {
+		metrics.LookupCache.Inc(kind, "hit")
+		return r.value, r.err
+	}
+	v, err, shared := l.flight.Do(key, func() (any, error) {
+		v, err := fetch()
+		l.cache.Add(key, lookupResult{v, err})
+		return v, err
+	})
+	if shared {
+		metrics.LookupCache.Inc(kind, "shared")
+	} else {
+		metrics.LookupCache.Inc(kind, "miss")
+	}
+	return v, err
+}
+
+// quote returns symbol's quote from provider, through the lookup cache
+func (c *FMPClient) quote(provider marketdata.Provider, symbol string) (*domain.Quote, error) {
+	v, err := c.lookupGroup().do("quote", "quote/"+provider.Name()+"/"+symbol, func() (any, error) {
+		return provider.Quote(symbol)
+	})
+	quote, _ := v.(*domain.Quote)
+	return quote, err
+}
+
+// profile returns symbol's profile from provider, through the lookup cache
+func (c *FMPClient) profile(provider marketdata.Provider, symbol string) (*domain.Profile, error) {
+	v, err := c.lookupGroup().do("profile", "profile/"+provider.Name()+"/"+symbol, func() (any, error) {
+		return provider.Profile(symbol)
+	})
+	profile, _ := v.(*domain.Profile)
+	return profile, err
+}
+
+// lookupGroup returns the client's lookup cache, creating it on first use
+func (c *FMPClient) lookupGroup() *lookupGroup {
+	c.lookupsOnce.Do(func() { c.lookups = newLookupGroup() })
+	return c.lookups
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http/httptest"
+	"time"
+
+	"algotradar/apikeys"
+	"algotradar/canonical"
+	"algotradar/domain"
+	"algotradar/enrich"
+	"algotradar/mockfmp"
+	"algotradar/progress"
+	"algotradar/runreport"
+)
+
+// mockColumns are the JSON keys every output row must carry
+var mockColumns = []string{
+	"ticker", "name", "market_cap", "current_price", "previous_close", "percentage_change",
+	"volume", "primary_exchange", "country", "sector", "industry", "asset_type", "image",
+	"is_adr", "primary_symbol", "instrument_type",
+}
+
+// mockListings are the fixture listings that reach the symbol stage, largest
+// screener market cap in USD first. Merged listings are folded into their
+// issuer's primary listing when identifiers are fetched; dropped ones are
+// left out by the symbol stage.
+var mockListings = []struct {
+	symbol, country string
+	merged, dropped bool
+}{
+	{symbol: "AAPL", country: "US"},
+	{symbol: "MSFT", country: "US"},
+	{symbol: "2222.SR", country: "SA"},
+	{symbol: "JPM", country: "US"},
+	{symbol: "0700.HK", country: "HK"},
+	{symbol: "KO", country: "US"},
+	{symbol: "7203.T", country: "JP"},
+	{symbol: "AZN.L", country: "GB"},
+	{symbol: "SHEL.L", country: "GB"},
+	{symbol: "SHEL.AS", country: "NL", merged: true},
+	{symbol: "6758.T", country: "JP"},
+	{symbol: "O", country: "US"},
+	{symbol: "OTCX", country: "US", dropped: true},
+	{symbol: "SMLL", country: "US"},
+}
+
+// mockRows returns how many rows a mock run over markets produces for each
+// of them: the rows of its first limit listings the symbol stage keeps
+func mockRows(markets []CountryConfig, limit int, identifiers bool) map[string]int {
+	rows := make(map[string]int, len(markets))
+	for _, cfg := range markets {
+		rows[cfg.Code] = 0
+	}
+	listed := 0
+	for _, l := range mockListings {
+		if _, ok := rows[l.country]; !ok || (l.merged && identifiers) {
+			continue
+		}
+		if limit > 0 && listed == limit {
+			break
+		}
+		listed++
+		if !l.dropped {
+			rows[l.country]++
+		}
+	}
+	return rows
+}
+
+// runMock collects markets from an in-process mock of the FMP API and checks
+// the rows' count and schema instead of writing outputs. It returns the exit
+// status: 0 when every check passes, 1 otherwise.
+func runMock(logger *slog.Logger, term *progress.Terminal, markets []CountryConfig, limit int, fairSchedule, identifiers, sharesFloat bool, enrichments enrich.Set) int {
+	mock, err := mockfmp.New()
+	if err != nil {
+		logger.Error("failed to load mock fixtures", "error", err)
+		return 1
+	}
+	srv := httptest.NewServer(mock)
+	defer srv.Close()
+
+	client := NewFMPClient(apikeys.Parse("mock"))
+	client.BaseURL = srv.URL + "/api"
+	client.Logger = logger
+	client.Report = runreport.New("get_companies")
+	client.Countries = markets
+	client.Limit = limit
+	client.FairSchedule = fairSchedule
+	client.Identifiers = identifiers
+	client.SharesFloat = sharesFloat
+	client.Enrichments = enrichments
+	client.SnapshotTime = time.Now()
+
+	logger.Info("starting mock collection", "countries", len(markets), "limit", limit)
+	assets, err := client.GetGlobalStocks()
+	term.Stop()
+	if err != nil {
+		fmt.Printf("FAIL mock run: %v\n", err)
+		return 1
+	}
+
+	problems := checkMockAssets(assets, markets, limit, identifiers)
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Printf("FAIL %s\n", problem)
+		}
+		return 1
+	}
+	run := client.Report.Build()
+	fmt.Printf("PASS mock run: %d assets from %d markets, %d symbols dropped\n", len(assets), len(markets), len(run.Dropped))
+	return 0
+}
+
+// checkMockAssets returns what is wrong with a mock run's rows: their count
+// per country, ranking, serialized columns and the values the sinks rely on
+func checkMockAssets(assets []domain.Asset, markets []CountryConfig, limit int, identifiers bool) []string {
+	var problems []string
+	if len(assets) == 0 {
+		return []string{"collector returned no assets"}
+	}
+	if limit > 0 && len(assets) > limit {
+		problems = append(problems, fmt.Sprintf("%d assets, want at most %d", len(assets), limit))
+	}
+	counts := make(map[string]int)
+	for _, asset := range assets {
+		counts[asset.Country]++
+	}
+	want := mockRows(markets, limit, identifiers)
+	for _, cfg := range markets {
+		if counts[cfg.Code] != want[cfg.Code] {
+			problems = append(problems, fmt.Sprintf("%s: %d assets, want %d", cfg.Code, counts[cfg.Code], want[cfg.Code]))
+		}
+	}
+
+	allowed := make(map[string]bool, len(markets))
+	for _, cfg := range markets {
+		allowed[cfg.Code] = true
+	}
+
+	data, err := canonical.JSON(assets)
+	if err != nil {
+		return append(problems, fmt.Sprintf("assets do not serialize: %v", err))
+	}
+	var rows []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return append(problems, fmt.Sprintf("serialized assets do not parse: %v", err))
+	}
+
+	seen := make(map[string]bool, len(assets))
+	for i, asset := range assets {
+		row := fmt.Sprintf("rank %d (%s)", i+1, asset.Ticker)
+		for _, column := range mockColumns {
+			if _, ok := rows[i][column]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing column %s", row, column))
+			}
+		}
+
+		switch {
+		case asset.Ticker == "" || asset.Name == "":
+			problems = append(problems, row+": empty ticker or name")
+		case seen[asset.Ticker]:
+			problems = append(problems, row+": duplicate ticker")
+		case asset.MarketCap <= 0 || math.IsInf(asset.MarketCap, 0):
+			problems = append(problems, fmt.Sprintf("%s: market cap %v", row, asset.MarketCap))
+		case asset.CurrentPrice <= 0:
+			problems = append(problems, fmt.Sprintf("%s: price %v", row, asset.CurrentPrice))
+		case !allowed[asset.Country]:
+			problems = append(problems, fmt.Sprintf("%s: country %q was not requested", row, asset.Country))
+		case asset.PrimaryExchange == "" || asset.PrimarySymbol == "":
+			problems = append(problems, row+": empty exchange or primary symbol")
+		case asset.AssetType != "stock" && asset.AssetType != "reit":
+			problems = append(problems, fmt.Sprintf("%s: asset type %q", row, asset.AssetType))
+		case i > 0 && asset.MarketCap > assets[i-1].MarketCap:
+			problems = append(problems, row+": ranked below a smaller market cap")
+		}
+		seen[asset.Ticker] = true
+	}
+	return problems
+}
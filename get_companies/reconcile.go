@@ -0,0 +1,293 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"algotradar/canonical"
+	"algotradar/domain"
+	"algotradar/marketdata"
+	"algotradar/workpool"
+)
+
+// Reconciliation issues: how two providers can disagree about one symbol
+const (
+	issuePrice            = "price"
+	issueMarketCap        = "market_cap"
+	issueNoPrimaryQuote   = "no_primary_quote"
+	issueNoSecondaryQuote = "no_secondary_quote"
+	issueNotInSecondary   = "not_in_secondary_screen"
+	issueOnlyInSecondary  = "only_in_secondary_screen"
+)
+
+// reconcileShown is how many discrepancies the text summary lists
+const reconcileShown = 20
+
+// Discrepancy is one symbol the providers disagree about. Prices and market
+// caps are in listing currency; diffs are relative to the larger value.
+type Discrepancy struct {
+	Symbol             string   `json:"symbol"`
+	Country            string   `json:"country"`
+	Name               string   `json:"name,omitempty"`
+	Issues             []string `json:"issues"`
+	PrimaryPrice       float64  `json:"primary_price,omitempty"`
+	SecondaryPrice     float64  `json:"secondary_price,omitempty"`
+	PriceDiff          float64  `json:"price_diff,omitempty"`
+	PrimaryMarketCap   float64  `json:"primary_market_cap,omitempty"`
+	SecondaryMarketCap float64  `json:"secondary_market_cap,omitempty"`
+	MarketCapDiff      float64  `json:"market_cap_diff,omitempty"`
+}
+
+// ReconcileReport compares the universe and quotes of two providers
+type ReconcileReport struct {
+	Primary   string    `json:"primary"`
+	Secondary string    `json:"secondary"`
+	Tolerance float64   `json:"tolerance"`
+	Generated time.Time `json:"generated_at"`
+
+	PrimaryListings   int `json:"primary_listings"`
+	SecondaryListings int `json:"secondary_listings"`
+	// Compared is how many primary listings both providers quoted
+	Compared      int           `json:"compared"`
+	Discrepancies []Discrepancy `json:"discrepancies"`
+}
+
+// Reconcile screens the configured markets with both the client's provider
+// and secondary, quotes every primary listing (the largest c.Limit when set)
+// on both, and reports the symbols whose price or market cap differ by more
+// than tolerance, or that only one side lists or quotes. A market secondary
+// cannot screen is compared on quotes only.
+func (c *FMPClient) Reconcile(secondary marketdata.Provider, tolerance float64) (*ReconcileReport, error) {
+	primary := c.data()
+	countries := c.Countries
+	if len(countries) == 0 {
+		countries = DefaultCountries
+	}
+	report := &ReconcileReport{Primary: primary.Name(), Secondary: secondary.Name(), Tolerance: tolerance, Generated: time.Now().UTC()}
+
+	var mu sync.Mutex
+	var primaryListings, secondaryListings []FMPStockScreener
+	screened := make(map[string]bool) // countries secondary could screen
+	screenerPool := c.startPool("reconcile_screeners", countryWorkers)
+	workpool.Each(screenerPool, countryWorkers, countries, func(_ int, cfg CountryConfig) {
+		logger := c.Logger.With("country", cfg.Code)
+		ours, err := c.CollectCountry(cfg, logger)
+		theirs, _, theirErr := secondary.Screen(cfg.Code, cfg.PageSize, c.minMarketCap(cfg.Code))
+		switch {
+		case errors.Is(theirErr, marketdata.ErrUnsupported):
+			logger.Info("secondary provider cannot screen market, comparing quotes only", "provider", secondary.Name())
+		case theirErr != nil:
+			logger.Warn("secondary screen failed, comparing quotes only", "provider", secondary.Name(), "error", theirErr)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if err == nil {
+			primaryListings = append(primaryListings, ours...)
+		}
+		if theirErr == nil {
+			screened[cfg.Code] = true
+			secondaryListings = append(secondaryListings, theirs...)
+		}
+	})
+	c.logPool(screenerPool)
+	if len(primaryListings) == 0 {
+		return nil, fmt.Errorf("%s listed no companies", primary.Name())
+	}
+	report.PrimaryListings = len(primaryListings)
+	report.SecondaryListings = len(secondaryListings)
+
+	sort.Slice(primaryListings, func(i, j int) bool {
+		if primaryListings[i].MarketCap != primaryListings[j].MarketCap {
+			return primaryListings[i].MarketCap > primaryListings[j].MarketCap
+		}
+		return primaryListings[i].Symbol < primaryListings[j].Symbol
+	})
+	if c.Limit > 0 && len(primaryListings) > c.Limit {
+		primaryListings = c.limitListings(primaryListings, make(map[string]domain.FXRate))
+	}
+
+	// Membership is compared by symbol, so providers with different symbol
+	// conventions show up here rather than as bad data
+	ourSymbols := make(map[string]bool, len(primaryListings))
+	for _, l := range primaryListings {
+		ourSymbols[l.Symbol] = true
+	}
+	theirCaps := make(map[string]float64, len(secondaryListings))
+	for _, l := range secondaryListings {
+		theirCaps[l.Symbol] = l.MarketCap
+		if !ourSymbols[l.Symbol] && c.Limit == 0 {
+			report.Discrepancies = append(report.Discrepancies, Discrepancy{
+				Symbol: l.Symbol, Country: l.Country, Name: l.CompanyName,
+				Issues: []string{issueOnlyInSecondary}, SecondaryMarketCap: l.MarketCap,
+			})
+		}
+	}
+
+	quotePool := c.startPool("reconcile_quotes", symbolWorkers)
+	workpool.Each(quotePool, symbolWorkers, primaryListings, func(_ int, l FMPStockScreener) {
+		d := Discrepancy{Symbol: l.Symbol, Country: l.Country, Name: l.CompanyName}
+		theirCap, listed := theirCaps[l.Symbol]
+		if screened[l.Country] && !listed {
+			d.Issues = append(d.Issues, issueNotInSecondary)
+		}
+		d.PrimaryPrice, d.PrimaryMarketCap = quoteFigures(primary, l.Symbol)
+		d.SecondaryPrice, d.SecondaryMarketCap = quoteFigures(secondary, l.Symbol)
+		// Providers without a market cap in quotes or profiles still screen by it
+		if d.PrimaryMarketCap <= 0 {
+			d.PrimaryMarketCap = l.MarketCap
+		}
+		if d.SecondaryMarketCap <= 0 {
+			d.SecondaryMarketCap = theirCap
+		}
+		compared := d.PrimaryPrice > 0 && d.SecondaryPrice > 0
+
+		switch {
+		case d.PrimaryPrice <= 0:
+			d.Issues = append(d.Issues, issueNoPrimaryQuote)
+		case d.SecondaryPrice <= 0:
+			d.Issues = append(d.Issues, issueNoSecondaryQuote)
+		default:
+			if d.PriceDiff = relativeDiff(d.PrimaryPrice, d.SecondaryPrice); d.PriceDiff > tolerance {
+				d.Issues = append(d.Issues, issuePrice)
+			}
+		}
+		if d.PrimaryMarketCap > 0 && d.SecondaryMarketCap > 0 {
+			if d.MarketCapDiff = relativeDiff(d.PrimaryMarketCap, d.SecondaryMarketCap); d.MarketCapDiff > tolerance {
+				d.Issues = append(d.Issues, issueMarketCap)
+			}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if compared {
+			report.Compared++
+		}
+		if len(d.Issues) > 0 {
+			report.Discrepancies = append(report.Discrepancies, d)
+		}
+	})
+	c.logPool(quotePool)
+
+	// Worst first: the larger relative diff, then symbol for a stable order
+	sort.Slice(report.Discrepancies, func(i, j int) bool {
+		a, b := report.Discrepancies[i], report.Discrepancies[j]
+		if da, db := max(a.PriceDiff, a.MarketCapDiff), max(b.PriceDiff, b.MarketCapDiff); da != db {
+			return da > db
+		}
+		if a.Country != b.Country {
+			return a.Country < b.Country
+		}
+		return a.Symbol < b.Symbol
+	})
+	return report, nil
+}
+
+// quoteFigures returns symbol's price and market cap from provider, or zeros
+// when it has none. The market cap comes from the quote, or price times shares
+// outstanding, or else the profile.
+func quoteFigures(provider marketdata.Provider, symbol string) (price, marketCap float64) {
+	quote, err := provider.Quote(symbol)
+	if err != nil || quote == nil {
+		return 0, 0
+	}
+	marketCap = quote.MarketCap
+	if marketCap <= 0 && quote.SharesOutstanding > 0 {
+		marketCap = quote.Price * quote.SharesOutstanding
+	}
+	if marketCap <= 0 {
+		if profile, err := provider.Profile(symbol); err == nil {
+			marketCap = profile.MktCap
+		}
+	}
+	return quote.Price, marketCap
+}
+
+// relativeDiff is |a-b| as a fraction of the larger of the two
+func relativeDiff(a, b float64) float64 {
+	larger := math.Max(math.Abs(a), math.Abs(b))
+	if larger == 0 {
+		return 0
+	}
+	return math.Abs(a-b) / larger
+}
+
+// Write saves the report as canonical JSON
+func (r *ReconcileReport) Write(path string) error {
+	data, err := canonical.JSON(r)
+	if err != nil {
+		return fmt.Errorf("failed to marshal reconciliation report: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write reconciliation report: %w", err)
+	}
+	return nil
+}
+
+// printReconcile summarizes a report: totals by issue and the worst rows
+func printReconcile(w io.Writer, r *ReconcileReport) {
+	counts := make(map[string]int)
+	for _, d := range r.Discrepancies {
+		for _, issue := range d.Issues {
+			counts[issue]++
+		}
+	}
+
+	fmt.Fprintf(w, "Reconciliation: %s vs %s (tolerance %.1f%%)\n", r.Primary, r.Secondary, r.Tolerance*100)
+	fmt.Fprintf(w, "Listings: %d from %s, %d from %s; %d quoted by both\n\n",
+		r.PrimaryListings, r.Primary, r.SecondaryListings, r.Secondary, r.Compared)
+	for _, issue := range []string{issuePrice, issueMarketCap, issueNoPrimaryQuote, issueNoSecondaryQuote, issueNotInSecondary, issueOnlyInSecondary} {
+		fmt.Fprintf(w, "  %-26s %d\n", issue, counts[issue])
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "\nSYMBOL\tCOUNTRY\tPRICE %s\tPRICE %s\tMCAP %s\tMCAP %s\tISSUES\n",
+		strings.ToUpper(r.Primary), strings.ToUpper(r.Secondary), strings.ToUpper(r.Primary), strings.ToUpper(r.Secondary))
+	for i, d := range r.Discrepancies {
+		if i == reconcileShown {
+			fmt.Fprintf(tw, "... %d more in the report\n", len(r.Discrepancies)-reconcileShown)
+			break
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.2f\t%.2f\t%s\t%s\t%s\n", d.Symbol, d.Country, d.PrimaryPrice, d.SecondaryPrice,
+			localAmount(d.PrimaryMarketCap), localAmount(d.SecondaryMarketCap), strings.Join(d.Issues, ","))
+	}
+	tw.Flush()
+}
+
+// localAmount formats a listing-currency amount like formatLargeNumber, less
+// the dollar sign; a dash when unknown
+func localAmount(num float64) string {
+	if num <= 0 {
+		return "-"
+	}
+	return strings.TrimPrefix(formatLargeNumber(num), "$")
+}
+
+// runReconcile reconciles the client's provider against secondary and writes
+// the report to path; it returns the process exit status
+func runReconcile(logger *slog.Logger, client *FMPClient, secondary marketdata.Provider, tolerance float64, path string) int {
+	start := time.Now()
+	logger.Info("starting provider reconciliation", "primary", client.data().Name(), "secondary", secondary.Name(),
+		"countries", len(client.Countries), "tolerance", tolerance)
+	report, err := client.Reconcile(secondary, tolerance)
+	if err != nil {
+		logger.Error("reconciliation failed", "error", err)
+		return 1
+	}
+	if err := report.Write(path); err != nil {
+		logger.Error("failed to save reconciliation report", "error", err)
+		return 1
+	}
+	printReconcile(os.Stdout, report)
+	logger.Info("reconciliation report saved", "file", path, "discrepancies", len(report.Discrepancies),
+		"compared", report.Compared, "duration", time.Since(start).String())
+	return 0
+}
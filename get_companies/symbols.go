@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadSymbols reads the tickers of a symbols file: a CSV with one ticker per
+// row in its first column, or in the column headed symbol or ticker. Blank
+// rows and rows starting with # are skipped, tickers are upper-cased and
+// repeats dropped, so a broker's holdings export can be used as it is.
+func LoadSymbols(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	var symbols []string
+	seen := make(map[string]bool)
+	column := 0
+	for row := 0; ; row++ {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid symbols file %s: %w", path, err)
+		}
+		if row == 0 {
+			if header := symbolColumn(record); header >= 0 {
+				column = header
+				continue
+			}
+		}
+		if column >= len(record) {
+			continue
+		}
+		symbol := strings.ToUpper(strings.TrimSpace(record[column]))
+		if symbol == "" || seen[symbol] {
+			continue
+		}
+		seen[symbol] = true
+		symbols = append(symbols, symbol)
+	}
+	if len(symbols) == 0 {
+		return nil, fmt.Errorf("no symbols in %s", path)
+	}
+	return symbols, nil
+}
+
+// symbolColumn returns the index of a header row's symbol or ticker column,
+// or -1 when the row is not a header
+func symbolColumn(record []string) int {
+	for i, cell := range record {
+		switch strings.ToLower(strings.TrimSpace(cell)) {
+		case "symbol", "ticker":
+			return i
+		}
+	}
+	return -1
+}
+
+// symbolsOutputBase names the outputs of a run over the symbols file at path
+// after the file, e.g. holdings_fmp for holdings.csv, so it does not replace
+// the global snapshot
+func symbolsOutputBase(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return name + "_fmp"
+}
+
+// symbolListings looks up the profiles of the Symbols and turns them into
+// listings for the symbol stage, in place of the country screens. Every
+// symbol with a profile is kept, whatever its market cap, instrument type or
+// cross-listings, so the output holds exactly the symbols asked for; the rest
+// are dropped as not_found.
+func (c *FMPClient) symbolListings() []FMPStockScreener {
+	c.Logger.Info("looking up symbols", "symbols", len(c.Symbols))
+	c.fetchProfiles(c.Symbols)
+
+	listings := make([]FMPStockScreener, 0, len(c.Symbols))
+	c.adrs = make(map[string]bool)
+	c.classOf = make(map[string]string)
+	for _, symbol := range c.Symbols {
+		profile, ok := c.cachedProfile(symbol)
+		if !ok {
+			c.Logger.Warn("no profile found, skipping symbol", "symbol", symbol)
+			c.Report.Drop(symbol, "", "not_found")
+			continue
+		}
+		exchange := profile.ExchangeShortName
+		if exchange == "" {
+			exchange = profile.Exchange
+		}
+		listings = append(listings, FMPStockScreener{
+			Symbol:            symbol,
+			CompanyName:       profile.CompanyName,
+			MarketCap:         profile.MktCap,
+			Sector:            profile.Sector,
+			Industry:          profile.Industry,
+			Beta:              profile.Beta,
+			Price:             profile.Price,
+			Volume:            profile.VolAvg,
+			Exchange:          profile.Exchange,
+			ExchangeShortName: exchange,
+			Country:           profile.Country,
+			IsEtf:             profile.IsEtf,
+			IsFund:            profile.IsFund,
+			IsActivelyTrading: true,
+		})
+		if profile.IsADR {
+			c.adrs[symbol] = true
+		}
+	}
+	c.Logger.Info("found symbols", "found", len(listings), "missing", len(c.Symbols)-len(listings))
+	return listings
+}
@@ -0,0 +1,172 @@
+// Package httpcache is a disk-backed HTTP response cache with per-endpoint TTLs.
+// It wraps an http.RoundTripper so collectors can reuse profile and FX responses
+// across runs instead of spending API quota on data that rarely changes intra-day.
+package httpcache
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"algotradar/metrics"
+)
+
+// HeaderCache is set to "HIT" on responses served from disk
+const HeaderCache = "X-Cache"
+
+// DefaultTTLs cache the endpoints whose data changes slowly
+var DefaultTTLs = map[string]time.Duration{
+	"/v3/profile":      24 * time.Hour,
+	"/v3/fx":           1 * time.Hour,
+	"/v4/shares_float": 24 * time.Hour,
+	// Only asked for days that have closed, whose rates never change
+	"/v3/historical-price-full": 7 * 24 * time.Hour,
+}
+
+// Transport serves cached GET responses from Dir while they are younger than the
+// TTL configured for their endpoint, and stores fresh 200 responses
+type Transport struct {
+	Dir  string
+	TTLs map[string]time.Duration // keyed by metrics.EndpointLabel
+	Base http.RoundTripper
+}
+
+// New returns a caching transport; a nil base uses http.DefaultTransport
+func New(dir string, ttls map[string]time.Duration, base http.RoundTripper) (*Transport, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache dir: %w", err)
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if ttls == nil {
+		ttls = DefaultTTLs
+	}
+	return &Transport{Dir: dir, TTLs: ttls, Base: base}, nil
+}
+
+// IsHit reports whether resp was served from the cache
+func IsHit(resp *http.Response) bool {
+	return resp != nil && resp.Header.Get(HeaderCache) == "HIT"
+}
+
+// ParseTTLs parses "endpoint=duration" pairs separated by commas,
+// e.g. "/v3/profile=24h,/v3/fx=30m"
+func ParseTTLs(spec string) (map[string]time.Duration, error) {
+	ttls := make(map[string]time.Duration)
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		endpoint, raw, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid cache TTL %q, want endpoint=duration", pair)
+		}
+		ttl, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cache TTL for %s: %w", endpoint, err)
+		}
+		ttls[endpoint] = ttl
+	}
+	return ttls, nil
+}
+
+// FormatTTLs renders ttls in the form accepted by ParseTTLs
+func FormatTTLs(ttls map[string]time.Duration) string {
+	pairs := make([]string, 0, len(ttls))
+	for endpoint, ttl := range ttls {
+		pairs = append(pairs, endpoint+"="+ttl.String())
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := metrics.EndpointLabel(req.URL.Path)
+	ttl := t.TTLs[endpoint]
+	if req.Method != http.MethodGet || ttl <= 0 {
+		return t.Base.RoundTrip(req)
+	}
+
+	path := filepath.Join(t.Dir, cacheKey(req.URL)+".http")
+	if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) < ttl {
+		if resp, err := readResponse(path, req); err == nil {
+			metrics.HTTPCacheLookups.Inc(endpoint, "hit")
+			return resp, nil
+		}
+	}
+	metrics.HTTPCacheLookups.Inc(endpoint, "miss")
+
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+
+	// Buffer the body so it can be both stored and returned
+	dump, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	// A cache write failure must not fail the request
+	_ = writeAtomic(path, dump)
+	return readDump(dump, req)
+}
+
+// cacheKey hashes the URL without the API key so rotating keys share entries
+func cacheKey(u *url.URL) string {
+	clean := *u
+	query := clean.Query()
+	query.Del("apikey")
+	clean.RawQuery = query.Encode()
+
+	sum := sha256.Sum256([]byte(clean.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+func readResponse(path string, req *http.Request) (*http.Response, error) {
+	dump, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := readDump(dump, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Header.Set(HeaderCache, "HIT")
+	return resp, nil
+}
+
+func readDump(dump []byte, req *http.Request) (*http.Response, error) {
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(dump)), req)
+}
+
+func writeAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
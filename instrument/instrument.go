@@ -0,0 +1,128 @@
+// Package instrument classifies listings by security type from the
+// provider's own metadata, the screener and profile flags, the stock list's
+// type, the industry and the symbol's share class suffix read under its
+// venue's conventions, rather than from words in the company name. SPACs
+// are the exception: FMP files many under ordinary industries, so their
+// names are checked too.
+package instrument
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Instrument types, the values of domain.Asset.InstrumentType
+const (
+	Stock     = "stock"
+	ETF       = "etf"
+	Fund      = "fund"
+	Trust     = "trust"
+	REIT      = "reit"
+	SPAC      = "spac"
+	Preferred = "preferred"
+)
+
+// spacIndustries are the industries blank-check companies are filed under:
+// FMP's, and the SIC description Polygon reports
+var spacIndustries = map[string]bool{"shell companies": true, "blank checks": true}
+
+// spacNames are the name phrases of blank-check companies, matched as whole
+// words, e.g. "Churchill Capital Acquisition Corp. II"
+var spacNames = [][2]string{
+	{"ACQUISITION", "CORP"},
+	{"ACQUISITION", "CORPORATION"},
+	{"ACQUISITION", "CO"},
+	{"ACQUISITION", "COMPANY"},
+	{"ACQUISITION", "INC"},
+	{"ACQUISITION", "LTD"},
+	{"ACQUISITION", "LIMITED"},
+	{"ACQUISITION", "HOLDINGS"},
+	{"BLANK", "CHECK"},
+}
+
+// Facts is what the providers say about a listing. Any of them may be
+// unknown: the screener has no list type and the stock list no industry.
+type Facts struct {
+	Symbol   string
+	Exchange string // FMP exchange short name, for the symbol's conventions
+	Name     string
+	ListType string // type of FMP's stock list: stock, etf, fund or trust
+	IsEtf    bool
+	IsFund   bool
+	Industry string
+}
+
+// Classify returns the listing's instrument type. The flags and list type
+// win over the industry, since FMP files funds under their holdings'
+// industry. Providers report no flag for preferred shares and derivative
+// listings, so those are told by the symbol's share class suffix. A listing
+// nothing marks is a Stock.
+func Classify(f Facts) string {
+	listType := strings.ToLower(strings.TrimSpace(f.ListType))
+	industry := strings.ToLower(strings.TrimSpace(f.Industry))
+	listing := ParseSymbol(f.Symbol, f.Exchange)
+	switch {
+	case f.IsEtf || listType == ETF:
+		return ETF
+	case f.IsFund || listType == Fund:
+		return Fund
+	case listing.Type != Stock:
+		return listing.Type
+	case spacIndustries[industry] || IsSPACName(f.Name):
+		return SPAC
+	case strings.HasPrefix(industry, "reit") || strings.Contains(industry, "real estate investment trust"):
+		return REIT
+	case listType == Trust:
+		return Trust
+	}
+	return Stock
+}
+
+// IsSPACName reports whether name reads like a blank-check company's, e.g.
+// "Ares Acquisition Corporation" or "Jaws Mustang Acquisition Corp"
+func IsSPACName(name string) bool {
+	words := strings.FieldsFunc(strings.ToUpper(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i := 0; i+1 < len(words); i++ {
+		for _, phrase := range spacNames {
+			if words[i] == phrase[0] && words[i+1] == phrase[1] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SPACPolicy is how a collection treats SPACs, set by the --spacs flag
+type SPACPolicy string
+
+// SPAC policies; the zero policy is SPACTag
+const (
+	SPACTag     SPACPolicy = "tag"     // keep SPACs, with instrument type spac
+	SPACExclude SPACPolicy = "exclude" // drop SPACs with the reason "spac"
+	SPACInclude SPACPolicy = "include" // keep SPACs as ordinary stocks
+)
+
+// ParseSPACPolicy parses a --spacs value
+func ParseSPACPolicy(s string) (SPACPolicy, error) {
+	switch p := SPACPolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case SPACTag, SPACExclude, SPACInclude:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown SPAC policy %q, want tag, exclude or include", s)
+}
+
+// Type returns the instrument type reported for a listing classified as t
+func (p SPACPolicy) Type(t string) string {
+	if p == SPACInclude && t == SPAC {
+		return Stock
+	}
+	return t
+}
+
+// Excludes reports whether a listing classified as t is dropped
+func (p SPACPolicy) Excludes(t string) bool {
+	return p == SPACExclude && t == SPAC
+}
@@ -0,0 +1,135 @@
+package instrument
+
+import (
+	"strings"
+
+	"algotradar/refdata"
+)
+
+// Instrument types of derivative listings, told by their symbol's suffix
+const (
+	Warrant = "warrant"
+	Unit    = "unit"
+	Right   = "right"
+)
+
+// nordic are the countries whose venues mark share classes and derivative
+// listings the Nasdaq Nordic way, e.g. VOLV-B.ST and ABC-TO1.ST
+var nordic = map[string]bool{"SE": true, "FI": true, "DK": true, "NO": true, "IS": true}
+
+// ShareClass is what a listing's symbol says about it under its venue's
+// conventions
+type ShareClass struct {
+	// Class is the share class or preferred series, e.g. "B" for BRK-B and
+	// BAC-PB; empty for a company's only class
+	Class string
+
+	// Type is Stock, Preferred, Warrant, Unit or Right
+	Type string
+}
+
+// ParseSymbol reads the share class suffix of an FMP symbol. The venue comes
+// from the exchange short name or the symbol's venue suffix, and a symbol
+// with neither is taken for a US one, as FMP writes US symbols bare. The
+// same suffix means different things on different venues: -UN is a unit of
+// a US SPAC but a trust unit, the common equity of a Canadian REIT, on the
+// TSX, and -U is a TSX line quoted in US dollars.
+func ParseSymbol(symbol, exchange string) ShareClass {
+	code := strings.ToUpper(strings.TrimSpace(symbol))
+	country := ""
+	if venue, ok := refdata.ForListing(code, exchange); ok {
+		country = venue.Country
+		for _, suffix := range venue.Suffixes {
+			if suffix = strings.ToUpper(suffix); strings.HasSuffix(code, suffix) {
+				code = strings.TrimSuffix(code, suffix)
+				break
+			}
+		}
+	} else if !strings.Contains(code, ".") {
+		country = "US"
+	}
+
+	_, tail, ok := strings.Cut(code, "-")
+	if !ok {
+		return ShareClass{Type: Stock}
+	}
+	switch {
+	case country == "US":
+		return usSuffix(tail)
+	case country == "CA":
+		return canadianSuffix(tail)
+	case nordic[country]:
+		return nordicSuffix(tail)
+	}
+	return commonClass(tail)
+}
+
+// usSuffix reads a suffix in the convention of US venues as FMP writes it:
+// -PB for preferred series B, -WT, -WS and -W for warrants, -U and -UN for
+// units, -RT and -R for rights, and a letter for a share class
+func usSuffix(tail string) ShareClass {
+	switch tail {
+	case "WT", "WS", "W":
+		return ShareClass{Type: Warrant}
+	case "U", "UN":
+		return ShareClass{Type: Unit}
+	case "RT", "R":
+		return ShareClass{Type: Right}
+	case "P":
+		return ShareClass{Type: Preferred}
+	}
+	if series, ok := strings.CutPrefix(tail, "P"); ok && isClassLetter(series) {
+		return ShareClass{Class: series, Type: Preferred}
+	}
+	return commonClass(tail)
+}
+
+// canadianSuffix reads a TSX or TSX Venture suffix: -PR-A and -PA for
+// preferred series A, -WT, -WS and -W for warrants and -RT and -R for rights.
+// Trust units (-UN) and US dollar lines (-U) are common equity.
+func canadianSuffix(tail string) ShareClass {
+	switch tail {
+	case "UN", "U":
+		return ShareClass{Type: Stock}
+	case "WT", "WS", "W":
+		return ShareClass{Type: Warrant}
+	case "RT", "R":
+		return ShareClass{Type: Right}
+	}
+	if series, ok := strings.CutPrefix(tail, "PR-"); ok && isClassLetter(series) {
+		return ShareClass{Class: series, Type: Preferred}
+	}
+	if series, ok := strings.CutPrefix(tail, "P"); ok && isClassLetter(series) {
+		return ShareClass{Class: series, Type: Preferred}
+	}
+	return commonClass(tail)
+}
+
+// nordicSuffix reads a Nasdaq Nordic suffix: -PREF for preference shares,
+// -TO and a number for subscription warrants (teckningsoptioner) and -UNIT
+// for units
+func nordicSuffix(tail string) ShareClass {
+	switch {
+	case tail == "PREF":
+		return ShareClass{Type: Preferred}
+	case tail == "UNIT":
+		return ShareClass{Type: Unit}
+	case strings.HasPrefix(tail, "TO") && strings.Trim(tail[2:], "0123456789") == "":
+		return ShareClass{Type: Warrant}
+	}
+	return commonClass(tail)
+}
+
+// commonClass reads a suffix that is not a known instrument marker: a single
+// letter is a share class, anything else, such as interim share lines, is a
+// common share of the only class
+func commonClass(tail string) ShareClass {
+	if isClassLetter(tail) {
+		return ShareClass{Class: tail, Type: Stock}
+	}
+	return ShareClass{Type: Stock}
+}
+
+func isClassLetter(s string) bool {
+	return len(s) == 1 && s[0] >= 'A' && s[0] <= 'Z'
+}
@@ -0,0 +1,176 @@
+// Package integrity writes and verifies SHA-256 checksums and optional Ed25519
+// signatures for published snapshot artifacts.
+//
+// Each artifact gets a "<file>.sha256" sidecar in sha256sum format and, when a
+// signing key is configured, a "<file>.sig" sidecar holding a base64 signature
+// over the checksum line.
+package integrity
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Sidecar suffixes written next to each artifact
+const (
+	ChecksumSuffix  = ".sha256"
+	SignatureSuffix = ".sig"
+)
+
+// Environment variables naming the key files used by the collectors and loaders
+const (
+	SigningKeyEnv = "SNAPSHOT_SIGNING_KEY"
+	VerifyKeyEnv  = "SNAPSHOT_VERIFY_KEY"
+)
+
+// ErrNoChecksum is returned by Verify when an artifact has no checksum sidecar
+var ErrNoChecksum = errors.New("no checksum file")
+
+// FileSHA256 returns the hex SHA-256 digest and size of a file
+func FileSHA256(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), n, nil
+}
+
+// checksumLine formats a digest the way sha256sum does, so files verify with `sha256sum -c`
+func checksumLine(digest, path string) string {
+	return fmt.Sprintf("%s  %s\n", digest, filepath.Base(path))
+}
+
+// Seal writes the checksum sidecar for path and, if key is non-nil, its signature
+func Seal(path string, key ed25519.PrivateKey) error {
+	digest, _, err := FileSHA256(path)
+	if err != nil {
+		return fmt.Errorf("failed to checksum %s: %w", path, err)
+	}
+
+	line := checksumLine(digest, path)
+	if err := os.WriteFile(path+ChecksumSuffix, []byte(line), 0644); err != nil {
+		return fmt.Errorf("failed to write checksum for %s: %w", path, err)
+	}
+
+	if key != nil {
+		sig := base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(line)))
+		if err := os.WriteFile(path+SignatureSuffix, []byte(sig+"\n"), 0644); err != nil {
+			return fmt.Errorf("failed to write signature for %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// Verify checks path against its checksum sidecar. When pub is non-nil the
+// signature sidecar must also be present and valid.
+func Verify(path string, pub ed25519.PublicKey) error {
+	raw, err := os.ReadFile(path + ChecksumSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s: %w", path, ErrNoChecksum)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read checksum for %s: %w", path, err)
+	}
+
+	line := string(raw)
+	fields := strings.Fields(line)
+	if len(fields) < 1 {
+		return fmt.Errorf("malformed checksum file for %s", path)
+	}
+
+	digest, _, err := FileSHA256(path)
+	if err != nil {
+		return fmt.Errorf("failed to checksum %s: %w", path, err)
+	}
+	if !strings.EqualFold(fields[0], digest) {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", path, fields[0], digest)
+	}
+
+	if pub == nil {
+		return nil
+	}
+
+	sigRaw, err := os.ReadFile(path + SignatureSuffix)
+	if err != nil {
+		return fmt.Errorf("failed to read signature for %s: %w", path, err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sigRaw)))
+	if err != nil {
+		return fmt.Errorf("malformed signature for %s: %w", path, err)
+	}
+	if !ed25519.Verify(pub, raw, sig) {
+		return fmt.Errorf("invalid signature for %s", path)
+	}
+	return nil
+}
+
+// GenerateKey creates a new signing key pair
+func GenerateKey() (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	return ed25519.GenerateKey(rand.Reader)
+}
+
+// EncodeKey renders a key as base64 for storage in a key file
+func EncodeKey(key []byte) string {
+	return base64.StdEncoding.EncodeToString(key) + "\n"
+}
+
+func readKey(path string, size int) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key %s: %w", path, err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil {
+		return nil, fmt.Errorf("malformed key %s: %w", path, err)
+	}
+	if len(key) != size {
+		return nil, fmt.Errorf("key %s has %d bytes, want %d", path, len(key), size)
+	}
+	return key, nil
+}
+
+// LoadPrivateKey reads a base64 Ed25519 private key file
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	key, err := readKey(path, ed25519.PrivateKeySize)
+	return ed25519.PrivateKey(key), err
+}
+
+// LoadPublicKey reads a base64 Ed25519 public key file
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	key, err := readKey(path, ed25519.PublicKeySize)
+	return ed25519.PublicKey(key), err
+}
+
+// SigningKeyFromEnv loads the private key named by SNAPSHOT_SIGNING_KEY, or nil if unset
+func SigningKeyFromEnv() (ed25519.PrivateKey, error) {
+	path := os.Getenv(SigningKeyEnv)
+	if path == "" {
+		return nil, nil
+	}
+	return LoadPrivateKey(path)
+}
+
+// VerifyKeyFromEnv loads the public key named by SNAPSHOT_VERIFY_KEY, or nil if unset
+func VerifyKeyFromEnv() (ed25519.PublicKey, error) {
+	path := os.Getenv(VerifyKeyEnv)
+	if path == "" {
+		return nil, nil
+	}
+	return LoadPublicKey(path)
+}
@@ -0,0 +1,315 @@
+package intraday
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"algotradar/awssig"
+	"algotradar/domain"
+	"algotradar/gauth"
+)
+
+// Emitter delivers the ticks of one poll
+type Emitter interface {
+	Emit(ctx context.Context, ticks []domain.IntradayTick) error
+	Close() error
+	String() string
+}
+
+// Specs collects the repeatable --emit flag
+type Specs []string
+
+func (s *Specs) String() string { return strings.Join(*s, ",") }
+
+// Set records a spec
+func (s *Specs) Set(spec string) error {
+	if strings.TrimSpace(spec) == "" {
+		return errors.New("empty emit spec")
+	}
+	*s = append(*s, spec)
+	return nil
+}
+
+// Parse builds the emitter of a spec: file:PATH appends JSON Lines to PATH,
+// sqs:QUEUE_URL sends to an Amazon SQS queue and pubsub:projects/P/topics/T
+// publishes to a Google Cloud Pub/Sub topic
+func Parse(spec string) (Emitter, error) {
+	kind, target, ok := strings.Cut(spec, ":")
+	if !ok || target == "" {
+		return nil, fmt.Errorf("invalid emit spec %q: want kind:target", spec)
+	}
+	switch kind {
+	case "file":
+		return OpenFile(target)
+	case "sqs":
+		return NewSQS(target)
+	case "pubsub":
+		return NewPubSub(target)
+	default:
+		return nil, fmt.Errorf("unknown emit kind %q (want file, sqs or pubsub)", kind)
+	}
+}
+
+// File appends each tick to a file as one line of JSON
+type File struct {
+	path string
+	f    *os.File
+}
+
+// OpenFile opens path for appending, creating it when missing
+func OpenFile(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &File{path: path, f: f}, nil
+}
+
+func (f *File) String() string { return "file:" + f.path }
+
+// Emit appends the ticks in one write, so a reader never sees half a poll
+func (f *File) Emit(_ context.Context, ticks []domain.IntradayTick) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, t := range ticks {
+		if err := enc.Encode(t); err != nil {
+			return err
+		}
+	}
+	_, err := f.f.Write(buf.Bytes())
+	return err
+}
+
+func (f *File) Close() error { return f.f.Close() }
+
+// sqsBatchSize is the most messages SendMessageBatch accepts
+const sqsBatchSize = 10
+
+// SQS sends each tick as one message to an Amazon SQS queue, signed with the
+// credentials in AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY. The region
+// comes from the queue URL or AWS_REGION; SQS_ENDPOINT overrides the
+// endpoint (e.g. for LocalStack).
+type SQS struct {
+	QueueURL string
+	Region   string
+	Endpoint string
+	Client   *http.Client
+
+	creds awssig.Credentials
+}
+
+// NewSQS returns an emitter for the queue at queueURL, e.g.
+// https://sqs.us-east-1.amazonaws.com/123456789012/ticks
+func NewSQS(queueURL string) (*SQS, error) {
+	u, err := url.Parse(queueURL)
+	if err != nil || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return nil, fmt.Errorf("invalid SQS queue URL %q", queueURL)
+	}
+	creds, err := awssig.CredentialsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	region := awssig.RegionFromEnv()
+	if parts := strings.Split(u.Host, "."); len(parts) > 2 && parts[0] == "sqs" {
+		region = parts[1]
+	}
+	endpoint := os.Getenv("SQS_ENDPOINT")
+	if endpoint == "" {
+		endpoint = u.Scheme + "://" + u.Host
+	}
+	return &SQS{
+		QueueURL: queueURL,
+		Region:   region,
+		Endpoint: strings.TrimSuffix(endpoint, "/"),
+		Client:   &http.Client{Timeout: 30 * time.Second},
+		creds:    creds,
+	}, nil
+}
+
+func (s *SQS) String() string { return "sqs:" + s.QueueURL }
+
+type sqsEntry struct {
+	ID          string `json:"Id"`
+	MessageBody string `json:"MessageBody"`
+}
+
+// Emit sends the ticks in batches of ten with SendMessageBatch
+func (s *SQS) Emit(ctx context.Context, ticks []domain.IntradayTick) error {
+	for start := 0; start < len(ticks); start += sqsBatchSize {
+		batch := ticks[start:min(start+sqsBatchSize, len(ticks))]
+		entries := make([]sqsEntry, len(batch))
+		for i, t := range batch {
+			body, err := json.Marshal(t)
+			if err != nil {
+				return err
+			}
+			entries[i] = sqsEntry{ID: strconv.Itoa(i), MessageBody: string(body)}
+		}
+		if err := s.send(ctx, entries); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQS) send(ctx context.Context, entries []sqsEntry) error {
+	body, err := json.Marshal(map[string]any{"QueueUrl": s.QueueURL, "Entries": entries})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.0")
+	req.Header.Set("X-Amz-Target", "AmazonSQS.SendMessageBatch")
+	awssig.NewSigner(s.creds, s.Region, "sqs").Sign(req, awssig.HashPayload(body))
+
+	var out struct {
+		Failed []struct {
+			ID      string `json:"Id"`
+			Code    string `json:"Code"`
+			Message string `json:"Message"`
+		} `json:"Failed"`
+	}
+	if err := doJSON(s.Client, req, &out); err != nil {
+		return fmt.Errorf("SQS send to %s failed: %w", s.QueueURL, err)
+	}
+	if len(out.Failed) > 0 {
+		f := out.Failed[0]
+		return fmt.Errorf("SQS rejected %d of %d messages, e.g. %s: %s", len(out.Failed), len(entries), f.Code, f.Message)
+	}
+	return nil
+}
+
+func (s *SQS) Close() error { return nil }
+
+// pubsubScope is the OAuth scope of Pub/Sub publishing
+const pubsubScope = "https://www.googleapis.com/auth/pubsub"
+
+// pubsubBatchSize is the most messages one publish request carries
+const pubsubBatchSize = 1000
+
+// PubSub publishes each tick as one message, with its symbol as the
+// "symbol" attribute, to a Google Cloud Pub/Sub topic. The service account
+// key is read from GOOGLE_APPLICATION_CREDENTIALS; PUBSUB_ENDPOINT overrides
+// the endpoint (e.g. for the emulator).
+type PubSub struct {
+	Topic    string
+	Endpoint string
+	Client   *http.Client
+
+	tokens tokenSource
+}
+
+// tokenSource hands out OAuth access tokens
+type tokenSource interface {
+	Token() (string, error)
+}
+
+// NewPubSub returns an emitter for topic, a resource name like
+// projects/my-project/topics/ticks
+func NewPubSub(topic string) (*PubSub, error) {
+	parts := strings.Split(topic, "/")
+	if len(parts) != 4 || parts[0] != "projects" || parts[2] != "topics" || parts[1] == "" || parts[3] == "" {
+		return nil, fmt.Errorf("invalid Pub/Sub topic %q: want projects/PROJECT/topics/TOPIC", topic)
+	}
+	account, err := gauth.ServiceAccountFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	endpoint := os.Getenv("PUBSUB_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "https://pubsub.googleapis.com"
+	}
+	return &PubSub{
+		Topic:    topic,
+		Endpoint: strings.TrimSuffix(endpoint, "/"),
+		Client:   client,
+		tokens:   account.TokenSource(client, pubsubScope),
+	}, nil
+}
+
+func (p *PubSub) String() string { return "pubsub:" + p.Topic }
+
+type pubsubMessage struct {
+	Data       string            `json:"data"` // base64
+	Attributes map[string]string `json:"attributes"`
+}
+
+// Emit publishes the ticks in batches of up to a thousand
+func (p *PubSub) Emit(ctx context.Context, ticks []domain.IntradayTick) error {
+	for start := 0; start < len(ticks); start += pubsubBatchSize {
+		batch := ticks[start:min(start+pubsubBatchSize, len(ticks))]
+		messages := make([]pubsubMessage, len(batch))
+		for i, t := range batch {
+			data, err := json.Marshal(t)
+			if err != nil {
+				return err
+			}
+			messages[i] = pubsubMessage{
+				Data:       base64.StdEncoding.EncodeToString(data),
+				Attributes: map[string]string{"symbol": t.Symbol},
+			}
+		}
+		if err := p.publish(ctx, messages); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *PubSub) publish(ctx context.Context, messages []pubsubMessage) error {
+	token, err := p.tokens.Token()
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]any{"messages": messages})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.Endpoint+"/v1/"+p.Topic+":publish", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	var out struct {
+		MessageIDs []string `json:"messageIds"`
+	}
+	if err := doJSON(p.Client, req, &out); err != nil {
+		return fmt.Errorf("Pub/Sub publish to %s failed: %w", p.Topic, err)
+	}
+	return nil
+}
+
+func (p *PubSub) Close() error { return nil }
+
+// doJSON sends req and decodes a 200 response into out
+func doJSON(client *http.Client, req *http.Request, out any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, out)
+}
@@ -0,0 +1,99 @@
+// Package intraday polls the quotes of a watchlist while its markets trade
+// and emits only the quotes whose price changed since the previous poll, to
+// an append-only JSON Lines file or a message queue.
+package intraday
+
+import (
+	"time"
+
+	"algotradar/domain"
+	"algotradar/refdata"
+)
+
+// Poller remembers the last price of each watched symbol, so a poll yields
+// only the changes. The first poll of a symbol always counts as a change, so
+// consumers start from a full set of prices.
+type Poller struct {
+	Symbols []string
+
+	// Fetch returns the current quotes of symbols
+	Fetch func(symbols []string) ([]domain.Quote, error)
+
+	last   map[string]float64
+	venues map[string]refdata.Exchange
+	// unknownHours marks symbols whose venue has no known trading hours
+	unknownHours map[string]bool
+}
+
+// NewPoller watches symbols, reading their quotes with fetch
+func NewPoller(symbols []string, fetch func(symbols []string) ([]domain.Quote, error)) *Poller {
+	return &Poller{
+		Symbols:      symbols,
+		Fetch:        fetch,
+		last:         make(map[string]float64, len(symbols)),
+		venues:       make(map[string]refdata.Exchange, len(symbols)),
+		unknownHours: make(map[string]bool),
+	}
+}
+
+// Open reports whether any watched venue is in its regular session at t.
+// Venues are learnt from the quotes, so before the first poll, or while a
+// symbol's venue has no known hours, Open is true.
+func (p *Poller) Open(t time.Time) bool {
+	if len(p.venues) == 0 || len(p.unknownHours) > 0 {
+		return true
+	}
+	for _, venue := range p.venues {
+		if open, _ := venue.IsOpen(t); open {
+			return true
+		}
+	}
+	return false
+}
+
+// Poll fetches the watchlist's quotes and returns those whose price changed,
+// stamped with the quote's time or, when it has none, with now
+func (p *Poller) Poll(now time.Time) ([]domain.IntradayTick, error) {
+	quotes, err := p.Fetch(p.Symbols)
+	var ticks []domain.IntradayTick
+	for _, q := range quotes {
+		if _, known := p.venues[q.Symbol]; !known && !p.unknownHours[q.Symbol] {
+			venue, ok := refdata.ForListing(q.Symbol, q.Exchange)
+			if ok {
+				_, ok = venue.IsOpen(now)
+			}
+			if ok {
+				p.venues[q.Symbol] = venue
+			} else {
+				p.unknownHours[q.Symbol] = true
+			}
+		}
+		if q.Price <= 0 {
+			continue
+		}
+		if last, seen := p.last[q.Symbol]; seen && last == q.Price {
+			continue
+		}
+		p.last[q.Symbol] = q.Price
+		taken := now.UTC()
+		if q.Timestamp > 0 {
+			taken = time.Unix(q.Timestamp, 0).UTC()
+		}
+		ticks = append(ticks, domain.IntradayTick{
+			Symbol: q.Symbol,
+			Time:   taken.Format(time.RFC3339),
+			Price:  q.Price,
+			Change: q.ChangesPercentage,
+			Volume: q.Volume,
+		})
+	}
+	return ticks, err
+}
+
+// Forget drops the remembered prices of ticks that could not be delivered,
+// so the next poll emits those symbols again
+func (p *Poller) Forget(ticks []domain.IntradayTick) {
+	for _, t := range ticks {
+		delete(p.last, t.Symbol)
+	}
+}
@@ -0,0 +1,71 @@
+// Package logging configures the structured logger shared by the collectors.
+package logging
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Options selects the verbosity and encoding of log output
+type Options struct {
+	Level  string // debug, info, warn or error
+	Format string // text or json
+}
+
+// RegisterFlags binds --log-level and --log-format to opts on the given flag set
+func RegisterFlags(fs *flag.FlagSet, opts *Options) {
+	fs.StringVar(&opts.Level, "log-level", "info", "log verbosity: debug, info, warn or error")
+	fs.StringVar(&opts.Format, "log-format", "text", "log encoding: text or json")
+}
+
+// ParseLevel converts a level name into a slog.Level
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level %q", level)
+}
+
+// New builds a logger writing to w according to opts
+func New(w io.Writer, opts Options) (*slog.Logger, error) {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(opts.Format) {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, handlerOpts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, handlerOpts)), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q", opts.Format)
+}
+
+// Setup builds a stderr logger from opts and installs it as the slog default
+func Setup(opts Options) (*slog.Logger, error) {
+	return SetupWriter(os.Stderr, opts)
+}
+
+// SetupWriter is Setup for a logger writing to w, such as a progress display
+// that keeps its bars below the log lines
+func SetupWriter(w io.Writer, opts Options) (*slog.Logger, error) {
+	logger, err := New(w, opts)
+	if err != nil {
+		return nil, err
+	}
+	slog.SetDefault(logger)
+	return logger, nil
+}
@@ -0,0 +1,174 @@
+// Package logos mirrors company logos off the provider's CDN, which
+// rate-limits hotlinking, into a local directory and optionally an object
+// store, so snapshots can point their image URLs at the copies.
+package logos
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"algotradar/sink"
+	"algotradar/workpool"
+)
+
+// DefaultDir is where logos are saved unless configured otherwise
+const DefaultDir = "assets/logos"
+
+// maxLogoBytes bounds one download; logos are a few kilobytes
+const maxLogoBytes = 5 << 20
+
+// Mirror copies logos to Dir as {symbol}.png. With a Store each copy is also
+// uploaded under the store's key prefix. Logos already in Dir are reused
+// rather than fetched again unless Refresh is set.
+type Mirror struct {
+	Dir string
+	// Store, when set, receives every logo; its key is the prefix, e.g.
+	// s3://bucket/logos
+	Store *sink.S3
+	// BaseURL replaces Dir, or the Store's own URL, in the locations written
+	// into the snapshot, e.g. the CDN in front of the bucket
+	BaseURL string
+	Refresh bool
+	Workers int
+	Client  *http.Client
+	Logger  *slog.Logger
+}
+
+// Result counts what one Mirror call did
+type Result struct {
+	Downloaded, Reused, Failed int
+}
+
+// Mirror copies the logo of each symbol in images (symbol to image URL) and
+// returns the new location of every logo it copied. Symbols whose logo could
+// not be fetched or stored are left out, so they keep their original URL.
+func (m *Mirror) Mirror(images map[string]string) (map[string]string, Result) {
+	logger := m.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	client := m.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	dir := m.Dir
+	if dir == "" {
+		dir = DefaultDir
+	}
+
+	var result Result
+	located := make(map[string]string, len(images))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		logger.Warn("failed to create logo directory", "dir", dir, "error", err)
+		result.Failed = len(images)
+		return located, result
+	}
+
+	symbols := make([]string, 0, len(images))
+	for symbol, image := range images {
+		if image != "" {
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	var mu sync.Mutex
+	workers := max(m.Workers, 1)
+	workpool.Each(workpool.New("logos", 1, workers), workers, symbols, func(_ int, symbol string) {
+		name := fileName(symbol)
+		local := filepath.Join(dir, name)
+		reused := false
+		data, err := os.ReadFile(local)
+		if err == nil && !m.Refresh {
+			reused = true
+		} else {
+			data, err = download(client, images[symbol])
+			if err == nil {
+				err = os.WriteFile(local, data, 0644)
+			}
+		}
+
+		location := filepath.ToSlash(local)
+		if err == nil && m.Store != nil {
+			key := path.Join(m.Store.Key, name)
+			if err = m.Store.Upload(key, data, contentType(name, data)); err == nil {
+				location = m.Store.URL(key)
+			}
+		}
+		if err == nil && m.BaseURL != "" {
+			location = strings.TrimSuffix(m.BaseURL, "/") + "/" + url.PathEscape(name)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case err != nil:
+			result.Failed++
+			logger.Debug("failed to mirror logo", "symbol", symbol, "url", images[symbol], "error", err)
+		case reused:
+			result.Reused++
+			located[symbol] = location
+		default:
+			result.Downloaded++
+			located[symbol] = location
+		}
+	})
+	return located, result
+}
+
+// fileName is the logo file of symbol; characters that are not safe in file
+// names or object keys become underscores
+func fileName(symbol string) string {
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, symbol)
+	return safe + ".png"
+}
+
+func download(client *http.Client, rawURL string) ([]byte, error) {
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxLogoBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty logo")
+	}
+	if len(data) > maxLogoBytes {
+		return nil, fmt.Errorf("logo larger than %d bytes", maxLogoBytes)
+	}
+	return data, nil
+}
+
+// contentType is the logo's media type, sniffed from its bytes and
+// otherwise taken from the extension
+func contentType(name string, data []byte) string {
+	if t := http.DetectContentType(data); strings.HasPrefix(t, "image/") {
+		return t
+	}
+	if t := mime.TypeByExtension(filepath.Ext(name)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
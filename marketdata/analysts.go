@@ -0,0 +1,129 @@
+package marketdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"algotradar/domain"
+)
+
+// PriceTargetConsensusPath is the path, below the FMP API root, of the
+// consensus price target for symbol
+func PriceTargetConsensusPath(symbol string) string {
+	return "/v4/price-target-consensus?symbol=" + url.QueryEscape(symbol)
+}
+
+// RatingConsensusPath is the path of the count of analysts at each rating
+func RatingConsensusPath(symbol string) string {
+	return "/v4/upgrades-downgrades-consensus?symbol=" + url.QueryEscape(symbol)
+}
+
+// GradeChangesPath is the path of the upgrades and downgrades published for
+// symbol
+func GradeChangesPath(symbol string) string {
+	return "/v4/upgrades-downgrades?symbol=" + url.QueryEscape(symbol)
+}
+
+type fmpPriceTarget struct {
+	TargetHigh      float64 `json:"targetHigh"`
+	TargetLow       float64 `json:"targetLow"`
+	TargetConsensus float64 `json:"targetConsensus"`
+	TargetMedian    float64 `json:"targetMedian"`
+}
+
+type fmpRatingCounts struct {
+	StrongBuy  int    `json:"strongBuy"`
+	Buy        int    `json:"buy"`
+	Hold       int    `json:"hold"`
+	Sell       int    `json:"sell"`
+	StrongSell int    `json:"strongSell"`
+	Consensus  string `json:"consensus"`
+}
+
+type fmpGradeChange struct {
+	PublishedDate   string  `json:"publishedDate"`
+	GradingCompany  string  `json:"gradingCompany"`
+	Action          string  `json:"action"`
+	PreviousGrade   string  `json:"previousGrade"`
+	NewGrade        string  `json:"newGrade"`
+	PriceWhenPosted float64 `json:"priceWhenPosted"`
+}
+
+// FetchAnalystRating reads the three analyst endpoints for symbol through
+// get, which fetches one of the paths above, and combines them into the
+// rating for date. Grade changes published in the days before date are
+// kept, newest first. A symbol no analyst covers comes back with nothing
+// set, see domain.AnalystRating.Covered.
+func FetchAnalystRating(get func(path string) ([]byte, error), symbol string, date time.Time, days int) (domain.AnalystRating, error) {
+	rating := domain.AnalystRating{Symbol: symbol, Date: date.Format("2006-01-02")}
+
+	var targets []fmpPriceTarget
+	if err := fetchAnalyst(get, PriceTargetConsensusPath(symbol), "price target consensus for "+symbol, &targets); err != nil {
+		return rating, err
+	}
+	if len(targets) > 0 {
+		t := targets[0]
+		rating.TargetHigh, rating.TargetLow, rating.TargetConsensus, rating.TargetMedian = t.TargetHigh, t.TargetLow, t.TargetConsensus, t.TargetMedian
+	}
+
+	var counts []fmpRatingCounts
+	if err := fetchAnalyst(get, RatingConsensusPath(symbol), "rating consensus for "+symbol, &counts); err != nil {
+		return rating, err
+	}
+	if len(counts) > 0 {
+		c := counts[0]
+		rating.StrongBuy, rating.Buy, rating.Hold, rating.Sell, rating.StrongSell = c.StrongBuy, c.Buy, c.Hold, c.Sell, c.StrongSell
+		rating.Consensus = c.Consensus
+	}
+
+	var changes []fmpGradeChange
+	if err := fetchAnalyst(get, GradeChangesPath(symbol), "grade changes for "+symbol, &changes); err != nil {
+		return rating, err
+	}
+	from := date.AddDate(0, 0, -days).Format("2006-01-02")
+	to := rating.Date
+	for _, change := range changes {
+		// publishedDate is an RFC 3339 timestamp; its date part is enough
+		day, _, _ := strings.Cut(change.PublishedDate, "T")
+		if day < from || day > to {
+			continue
+		}
+		action := strings.ToLower(change.Action)
+		switch action {
+		case "upgrade":
+			rating.Upgrades++
+		case "downgrade":
+			rating.Downgrades++
+		}
+		rating.Actions = append(rating.Actions, domain.AnalystAction{
+			Date:          day,
+			Firm:          change.GradingCompany,
+			Action:        action,
+			PreviousGrade: change.PreviousGrade,
+			NewGrade:      change.NewGrade,
+			Price:         change.PriceWhenPosted,
+		})
+	}
+	sort.SliceStable(rating.Actions, func(i, j int) bool {
+		if rating.Actions[i].Date != rating.Actions[j].Date {
+			return rating.Actions[i].Date > rating.Actions[j].Date
+		}
+		return rating.Actions[i].Firm < rating.Actions[j].Firm
+	})
+	return rating, nil
+}
+
+func fetchAnalyst(get func(path string) ([]byte, error), path, what string, out any) error {
+	body, err := get(path)
+	if err != nil {
+		return fmt.Errorf("fetch %s: %w", what, err)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return &ParseError{What: what, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,328 @@
+package marketdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"algotradar/domain"
+	"algotradar/ratelimit"
+)
+
+const (
+	// eodhdPageSize is the most rows one screener request returns
+	eodhdPageSize = 100
+	// eodhdMaxOffset is the largest offset the screener accepts
+	eodhdMaxOffset = 999
+)
+
+// EODHDExchanges are the EODHD exchange codes screened for each country.
+// Markets missing here fail with ErrUnsupported; add them to the map to
+// screen more.
+var EODHDExchanges = map[string][]string{
+	"US": {"NYSE", "NASDAQ"},
+	"GB": {"LSE"},
+	"DE": {"XETRA"},
+	"FR": {"PA"},
+	"NL": {"AS"},
+	"CH": {"SW"},
+	"JP": {"TSE"},
+	"HK": {"HK"},
+	"CN": {"SHG", "SHE"},
+	"KR": {"KO", "KQ"},
+	"IN": {"NSE"},
+	"AU": {"AU"},
+	"CA": {"TO"},
+	"BR": {"SA"},
+	"MX": {"MX"},
+	"SA": {"SR"},
+	"ZA": {"JSE"},
+	"EG": {"EGX"},
+	"VN": {"VN"},
+	"PE": {"LIM"},
+	"CL": {"SN"},
+	"CO": {"BVC"},
+	"AR": {"BA"},
+	"NG": {"XNSA"},
+	"KE": {"XNAI"},
+	"MA": {"BC"},
+	"PK": {"KAR"},
+	"BD": {"DSE"},
+	"LK": {"CSE"},
+	"PH": {"PSE"},
+	"ID": {"JK"},
+	"TH": {"BK"},
+	"MY": {"KLSE"},
+	"TW": {"TW"},
+	"TR": {"IS"},
+	"IT": {"MI"},
+	"ES": {"MC"},
+	"SG": {"SG"},
+	"SE": {"ST"},
+	"NO": {"OL"},
+	"DK": {"CO"},
+	"FI": {"HE"},
+	"IL": {"TA"},
+}
+
+// EODHD reads market data from EOD Historical Data, whose exchange coverage
+// reaches frontier markets FMP lists thinly. Symbols are EODHD's
+// CODE.EXCHANGE form, e.g. COMI.EGX.
+type EODHD struct {
+	APIKey     string
+	BaseURL    string
+	HTTPClient *http.Client
+
+	ratesMu sync.Mutex
+	rates   map[string]float64
+}
+
+// NewEODHD returns an EODHD client for apiKey
+func NewEODHD(apiKey string) *EODHD {
+	return &EODHD{
+		APIKey:     apiKey,
+		BaseURL:    "https://eodhd.com/api",
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		rates:      make(map[string]float64),
+	}
+}
+
+func (e *EODHD) Name() string { return "eodhd" }
+
+// eodhdNumber is a number EODHD may send as a string or as "NA"
+type eodhdNumber float64
+
+func (n *eodhdNumber) UnmarshalJSON(data []byte) error {
+	text := strings.Trim(string(data), `"`)
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		value = 0 // "NA", null and other placeholders
+	}
+	*n = eodhdNumber(value)
+	return nil
+}
+
+type eodhdScreenerRow struct {
+	Code          string      `json:"code"`
+	Name          string      `json:"name"`
+	Exchange      string      `json:"exchange"`
+	Currency      string      `json:"currency_symbol"`
+	MarketCap     eodhdNumber `json:"market_capitalization"`
+	Sector        string      `json:"sector"`
+	Industry      string      `json:"industry"`
+	AdjustedClose eodhdNumber `json:"adjusted_close"`
+	AvgVolume     eodhdNumber `json:"avgvol_1d"`
+}
+
+type eodhdRealTime struct {
+	Code          string      `json:"code"`
+	Timestamp     eodhdNumber `json:"timestamp"`
+	Open          eodhdNumber `json:"open"`
+	Close         eodhdNumber `json:"close"`
+	Volume        eodhdNumber `json:"volume"`
+	PreviousClose eodhdNumber `json:"previousClose"`
+	Change        eodhdNumber `json:"change"`
+	ChangePercent eodhdNumber `json:"change_p"`
+}
+
+// Screen pages through the screener for each of the country's exchanges. The
+// screener filters and reports market caps in USD; they are converted back to
+// the listing currency so the collector's own conversion applies to every
+// provider alike.
+func (e *EODHD) Screen(country string, pageSize int, minMarketCap float64) ([]Listing, int, error) {
+	exchanges, ok := EODHDExchanges[country]
+	if !ok {
+		return nil, 0, fmt.Errorf("screen %s: %w: no EODHD exchange configured", country, ErrUnsupported)
+	}
+
+	var listings []Listing
+	pages := 0
+	for _, exchange := range exchanges {
+		for offset := 0; offset <= eodhdMaxOffset; offset += eodhdPageSize {
+			filters := fmt.Sprintf(`[["market_capitalization",">=",%.0f],["exchange","=",%q]]`, minMarketCap, exchange)
+			path := fmt.Sprintf("/screener?sort=market_capitalization.desc&limit=%d&offset=%d&filters=%s",
+				eodhdPageSize, offset, url.QueryEscape(filters))
+			var page struct {
+				Data []eodhdScreenerRow `json:"data"`
+			}
+			if err := e.get(path, &page); err != nil {
+				return listings, pages, fmt.Errorf("screen %s: %w", exchange, err)
+			}
+			pages++
+			for _, row := range page.Data {
+				listings = append(listings, e.listing(row, country))
+			}
+			if len(page.Data) < eodhdPageSize {
+				break
+			}
+		}
+	}
+	return listings, pages, nil
+}
+
+func (e *EODHD) listing(row eodhdScreenerRow, country string) Listing {
+	marketCap := float64(row.MarketCap)
+	if currency := strings.ToUpper(row.Currency); currency != "" && currency != "USD" {
+		if rate, err := e.FX(currency); err == nil && rate > 0 {
+			marketCap /= rate
+		}
+	}
+	return Listing{
+		Symbol:            row.Code + "." + row.Exchange,
+		CompanyName:       row.Name,
+		MarketCap:         marketCap,
+		Sector:            row.Sector,
+		Industry:          row.Industry,
+		Price:             float64(row.AdjustedClose),
+		Volume:            float64(row.AvgVolume),
+		Exchange:          row.Exchange,
+		ExchangeShortName: row.Exchange,
+		Country:           country,
+		IsActivelyTrading: true,
+	}
+}
+
+// Quote returns the delayed real-time quote of symbol
+func (e *EODHD) Quote(symbol string) (*domain.Quote, error) {
+	q, err := e.realTime(symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quote for %s: %w", symbol, err)
+	}
+	price := float64(q.Close)
+	if price <= 0 {
+		price = float64(q.PreviousClose)
+	}
+	if price <= 0 {
+		return nil, fmt.Errorf("no quote data found for %s", symbol)
+	}
+	return &domain.Quote{
+		Symbol:            symbol,
+		Price:             price,
+		Change:            float64(q.Change),
+		ChangesPercentage: float64(q.ChangePercent),
+		Open:              float64(q.Open),
+		PreviousClose:     float64(q.PreviousClose),
+		Volume:            float64(q.Volume),
+		Timestamp:         int64(q.Timestamp),
+	}, nil
+}
+
+// Profile returns the General section of symbol's fundamentals
+func (e *EODHD) Profile(symbol string) (*domain.Profile, error) {
+	var g struct {
+		Name         string `json:"Name"`
+		Exchange     string `json:"Exchange"`
+		CurrencyCode string `json:"CurrencyCode"`
+		CountryISO   string `json:"CountryISO"`
+		Sector       string `json:"Sector"`
+		Industry     string `json:"Industry"`
+		LogoURL      string `json:"LogoURL"`
+		WebURL       string `json:"WebURL"`
+		Description  string `json:"Description"`
+		ISIN         string `json:"ISIN"`
+		CIK          string `json:"CIK"`
+		CUSIP        string `json:"CUSIP"`
+	}
+	if err := e.get("/fundamentals/"+url.PathEscape(symbol)+"?filter=General", &g); err != nil {
+		return nil, fmt.Errorf("failed to get company profile for %s: %w", symbol, err)
+	}
+	if g.Name == "" {
+		return nil, fmt.Errorf("no company profile data found for %s", symbol)
+	}
+	image := g.LogoURL
+	if strings.HasPrefix(image, "/") {
+		image = "https://eodhd.com" + image
+	}
+	return &domain.Profile{
+		Symbol:      symbol,
+		CompanyName: g.Name,
+		Currency:    g.CurrencyCode,
+		Country:     g.CountryISO,
+		Sector:      g.Sector,
+		Industry:    g.Industry,
+		Exchange:    g.Exchange,
+		Image:       image,
+		Website:     g.WebURL,
+		Description: g.Description,
+		ISIN:        g.ISIN,
+		CIK:         g.CIK,
+		CUSIP:       g.CUSIP,
+	}, nil
+}
+
+// FX returns the latest CURUSD.FOREX close, cached for the client's lifetime
+func (e *EODHD) FX(currency string) (float64, error) {
+	if currency == "USD" {
+		return 1, nil
+	}
+	e.ratesMu.Lock()
+	rate, ok := e.rates[currency]
+	e.ratesMu.Unlock()
+	if ok {
+		return rate, nil
+	}
+
+	q, err := e.realTime(currency + "USD.FOREX")
+	if err != nil {
+		return 0, err
+	}
+	if q.Close <= 0 {
+		return 0, fmt.Errorf("no %sUSD rate", currency)
+	}
+	e.ratesMu.Lock()
+	e.rates[currency] = float64(q.Close)
+	e.ratesMu.Unlock()
+	return float64(q.Close), nil
+}
+
+func (e *EODHD) realTime(symbol string) (eodhdRealTime, error) {
+	var q eodhdRealTime
+	err := e.get("/real-time/"+url.PathEscape(symbol), &q)
+	return q, err
+}
+
+// get fetches path as JSON and decodes it into out, retrying 429s as
+// ratelimit advises
+func (e *EODHD) get(path string, out any) error {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	target := e.BaseURL + path + separator + "fmt=json&api_token=" + url.QueryEscape(e.APIKey)
+
+	for attempt := 0; ; attempt++ {
+		resp, err := e.HTTPClient.Get(target)
+		if err != nil {
+			return fmt.Errorf("failed to make request: %w", err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests:
+			limit := ratelimit.FromResponse(resp.Header, body, time.Now())
+			if limit.Daily || attempt == ratelimit.MaxRetries {
+				return limit.Error(attempt)
+			}
+			time.Sleep(limit.Wait(attempt))
+			continue
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+			return fmt.Errorf("EODHD request failed with status %d: %w", resp.StatusCode, ErrAuthFailed)
+		case resp.StatusCode != http.StatusOK:
+			return fmt.Errorf("EODHD request failed with status %d", resp.StatusCode)
+		}
+		if err := json.Unmarshal(body, out); err != nil {
+			return &ParseError{What: "EODHD response", Err: err}
+		}
+		return nil
+	}
+}
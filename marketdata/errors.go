@@ -0,0 +1,79 @@
+package marketdata
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"algotradar/ratelimit"
+)
+
+// Kinds of failure a collection run reports, see Classify
+const (
+	KindRateLimited = "rate_limited"
+	KindAuthFailed  = "auth_failed"
+	KindParse       = "parse_error"
+	KindOther       = "other"
+)
+
+// ErrAuthFailed marks 401/403 responses: the key is invalid, revoked or not
+// entitled to the endpoint
+var ErrAuthFailed = errors.New("API key rejected or not entitled")
+
+// ErrNotEntitled marks a 403 for an endpoint the API plan does not include.
+// Unlike ErrAuthFailed the key itself is fine and stays in rotation.
+var ErrNotEntitled = errors.New("endpoint not included in the API plan")
+
+// NotEntitled reports whether the body of a 403 response is FMP refusing an
+// endpoint outside the key's plan rather than the key itself
+func NotEntitled(body []byte) bool {
+	text := strings.ToLower(string(body))
+	return strings.Contains(text, "subscription") || strings.Contains(text, "exclusive endpoint") ||
+		strings.Contains(text, "premium")
+}
+
+// ErrRateLimited marks requests the provider kept refusing with 429
+var ErrRateLimited = ratelimit.ErrRateLimited
+
+// ParseError is a response that could not be decoded
+type ParseError struct {
+	// What names the response, e.g. "quote data for AAPL"
+	What string
+	Err  error
+}
+
+func (e *ParseError) Error() string { return fmt.Sprintf("failed to parse %s: %v", e.What, e.Err) }
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// CountryError is a country screen that failed. Partial counts the listings
+// fetched before the failure, which the run still ranks; with none the
+// country is missing from the output.
+type CountryError struct {
+	Country string
+	Partial int
+	Err     error
+}
+
+func (e *CountryError) Error() string { return fmt.Sprintf("fetch %s screener: %v", e.Country, e.Err) }
+
+func (e *CountryError) Unwrap() error { return e.Err }
+
+// Missing reports whether the country has no rows at all
+func (e *CountryError) Missing() bool { return e.Partial == 0 }
+
+// Classify returns the kind of an error: rate limiting (including an
+// exhausted daily quota), rejected credentials or plan, an undecodable
+// response, or other
+func Classify(err error) string {
+	var parseErr *ParseError
+	switch {
+	case errors.Is(err, ErrRateLimited), errors.Is(err, ratelimit.ErrDailyLimit):
+		return KindRateLimited
+	case errors.Is(err, ErrAuthFailed), errors.Is(err, ErrNotEntitled):
+		return KindAuthFailed
+	case errors.As(err, &parseErr):
+		return KindParse
+	}
+	return KindOther
+}
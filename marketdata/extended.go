@@ -0,0 +1,44 @@
+package marketdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"algotradar/domain"
+)
+
+// ExtendedHoursBatchSize is how many symbols one extended-hours request asks
+// for
+const ExtendedHoursBatchSize = 50
+
+// ExtendedHoursTradesPath is the path, below the FMP API root, of the last
+// pre- or post-market trade of each of symbols
+func ExtendedHoursTradesPath(symbols []string) string {
+	return "/v4/batch-pre-post-market-trade/" + strings.Join(symbols, ",")
+}
+
+// FetchExtendedHoursTrades reads the last extended-hours trade of each of
+// symbols through get, which fetches an ExtendedHoursTradesPath, in batches
+// of ExtendedHoursBatchSize. Symbols without a trade are left out. What was
+// read before a failed batch is returned with its error.
+func FetchExtendedHoursTrades(get func(path string) ([]byte, error), symbols []string) (map[string]domain.ExtendedHoursTrade, error) {
+	trades := make(map[string]domain.ExtendedHoursTrade, len(symbols))
+	for start := 0; start < len(symbols); start += ExtendedHoursBatchSize {
+		batch := symbols[start:min(start+ExtendedHoursBatchSize, len(symbols))]
+		body, err := get(ExtendedHoursTradesPath(batch))
+		if err != nil {
+			return trades, fmt.Errorf("fetch extended-hours trades: %w", err)
+		}
+		var found []domain.ExtendedHoursTrade
+		if err := json.Unmarshal(body, &found); err != nil {
+			return trades, &ParseError{What: "extended-hours trades", Err: err}
+		}
+		for _, t := range found {
+			if t.Price > 0 {
+				trades[t.Symbol] = t
+			}
+		}
+	}
+	return trades, nil
+}
@@ -0,0 +1,33 @@
+package marketdata
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"algotradar/domain"
+)
+
+// FFOQuarters is how many quarterly statements make up trailing FFO
+const FFOQuarters = 4
+
+// CashFlowStatementsPath is the path, below the FMP API root, of symbol's
+// latest FFOQuarters quarterly cash flow statements
+func CashFlowStatementsPath(symbol string) string {
+	return fmt.Sprintf("/v3/cash-flow-statement/%s?period=quarter&limit=%d", symbol, FFOQuarters)
+}
+
+// FetchFFO reads symbol's funds from operations over the trailing four
+// quarters through get, which fetches a CashFlowStatementsPath. The result
+// is zero when fewer than four quarters are reported, or they are reported
+// in more than one currency.
+func FetchFFO(get func(path string) ([]byte, error), symbol string) (domain.FFO, error) {
+	body, err := get(CashFlowStatementsPath(symbol))
+	if err != nil {
+		return domain.FFO{}, fmt.Errorf("fetch cash flow statements of %s: %w", symbol, err)
+	}
+	var statements []domain.CashFlowStatement
+	if err := json.Unmarshal(body, &statements); err != nil {
+		return domain.FFO{}, &ParseError{What: "cash flow statements of " + symbol, Err: err}
+	}
+	return domain.TrailingFFO(symbol, statements, FFOQuarters), nil
+}
@@ -0,0 +1,87 @@
+package marketdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"algotradar/domain"
+)
+
+// FilingTypes are the SEC forms FetchFilings keeps: annual and quarterly
+// reports and current reports of material events
+var FilingTypes = map[string]bool{"10-K": true, "10-Q": true, "8-K": true}
+
+// MaxFilingPages bounds how far back FetchFilings pages for one symbol
+const MaxFilingPages = 5
+
+// SECFilingsPath is the path, below the FMP API root, of one page of the SEC
+// filings of symbol, newest first
+func SECFilingsPath(symbol string, page int) string {
+	return fmt.Sprintf("/v3/sec_filings/%s?page=%d", symbol, page)
+}
+
+type fmpFiling struct {
+	CIK          string `json:"cik"`
+	Type         string `json:"type"`
+	FillingDate  string `json:"fillingDate"` // sic
+	AcceptedDate string `json:"acceptedDate"`
+	Link         string `json:"link"`
+	FinalLink    string `json:"finalLink"`
+}
+
+// FetchFilings reads the 10-K, 10-Q and 8-K filings of symbol made on or
+// after since, a YYYY-MM-DD date, through get, which fetches a
+// SECFilingsPath. Pages are read until one reaches past since or comes back
+// empty. Filings are returned newest first.
+func FetchFilings(get func(path string) ([]byte, error), symbol, since string) ([]domain.Filing, error) {
+	var filings []domain.Filing
+	for page := 0; page < MaxFilingPages; page++ {
+		body, err := get(SECFilingsPath(symbol, page))
+		if err != nil {
+			return filings, fmt.Errorf("fetch SEC filings of %s: %w", symbol, err)
+		}
+		var entries []fmpFiling
+		if err := json.Unmarshal(body, &entries); err != nil {
+			return filings, &ParseError{What: fmt.Sprintf("SEC filings of %s page %d", symbol, page), Err: err}
+		}
+		if len(entries) == 0 {
+			break
+		}
+		reachedSince := false
+		for _, entry := range entries {
+			filed, _, _ := strings.Cut(entry.FillingDate, " ")
+			if filed < since {
+				reachedSince = true
+				continue
+			}
+			if !FilingTypes[entry.Type] {
+				continue
+			}
+			filings = append(filings, domain.Filing{
+				Symbol:   symbol,
+				CIK:      entry.CIK,
+				Type:     entry.Type,
+				Filed:    filed,
+				Accepted: acceptedTime(entry.AcceptedDate),
+				Link:     entry.Link,
+				Document: entry.FinalLink,
+			})
+		}
+		if reachedSince {
+			break
+		}
+	}
+	return filings, nil
+}
+
+// acceptedTime converts FMP's acceptance time, US Eastern like EDGAR's, to
+// RFC 3339 UTC; it is empty when FMP has none
+func acceptedTime(value string) string {
+	t, err := time.ParseInLocation(fmpTimeLayout, strings.TrimSpace(value), eastern)
+	if err != nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
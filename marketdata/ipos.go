@@ -0,0 +1,77 @@
+package marketdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"algotradar/domain"
+)
+
+// MaxIPOCalendarDays is the longest span the IPO calendar returns in one
+// request; FetchIPOCalendar splits longer ones
+const MaxIPOCalendarDays = 90
+
+// IPOCalendarPath is the path, below the FMP API root, of the IPOs listing
+// between from and to, both YYYY-MM-DD and inclusive
+func IPOCalendarPath(from, to string) string {
+	return fmt.Sprintf("/v3/ipo_calendar?from=%s&to=%s", from, to)
+}
+
+type fmpIPO struct {
+	Date       string  `json:"date"`
+	Company    string  `json:"company"`
+	Symbol     string  `json:"symbol"`
+	Exchange   string  `json:"exchange"`
+	Actions    string  `json:"actions"`
+	Shares     float64 `json:"shares"`
+	PriceRange string  `json:"priceRange"`
+	MarketCap  float64 `json:"marketCap"`
+}
+
+// FetchIPOCalendar reads the IPOs listing between from and to through get,
+// which fetches an IPOCalendarPath, in spans of MaxIPOCalendarDays. Entries
+// without a symbol are dropped. IPOs are returned by date, then symbol.
+func FetchIPOCalendar(get func(path string) ([]byte, error), from, to time.Time) ([]domain.IPO, error) {
+	var ipos []domain.IPO
+	for start := from; !start.After(to); start = start.AddDate(0, 0, MaxIPOCalendarDays) {
+		end := start.AddDate(0, 0, MaxIPOCalendarDays-1)
+		if end.After(to) {
+			end = to
+		}
+		first, last := start.Format("2006-01-02"), end.Format("2006-01-02")
+		body, err := get(IPOCalendarPath(first, last))
+		if err != nil {
+			return ipos, fmt.Errorf("fetch IPO calendar %s to %s: %w", first, last, err)
+		}
+		var entries []fmpIPO
+		if err := json.Unmarshal(body, &entries); err != nil {
+			return ipos, &ParseError{What: fmt.Sprintf("IPO calendar %s to %s", first, last), Err: err}
+		}
+		for _, entry := range entries {
+			symbol := strings.TrimSpace(entry.Symbol)
+			if symbol == "" {
+				continue
+			}
+			ipos = append(ipos, domain.IPO{
+				Symbol:     symbol,
+				Company:    strings.TrimSpace(entry.Company),
+				Exchange:   entry.Exchange,
+				Date:       entry.Date,
+				Status:     entry.Actions,
+				Shares:     entry.Shares,
+				PriceRange: entry.PriceRange,
+				MarketCap:  entry.MarketCap,
+			})
+		}
+	}
+	sort.Slice(ipos, func(i, j int) bool {
+		if ipos[i].Date != ipos[j].Date {
+			return ipos[i].Date < ipos[j].Date
+		}
+		return ipos[i].Symbol < ipos[j].Symbol
+	})
+	return ipos, nil
+}
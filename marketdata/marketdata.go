@@ -0,0 +1,91 @@
+// Package marketdata abstracts the market data vendor behind the collectors.
+// FMP is the default; Polygon can stand in for it where a user's subscription
+// is with Polygon instead, and a Router mixes providers by country, e.g. EODHD
+// for frontier markets.
+package marketdata
+
+import (
+	"errors"
+	"time"
+
+	"algotradar/domain"
+)
+
+// MinMarketCap is the default screen floor, in US dollars
+const MinMarketCap = 50e6
+
+// ErrUnsupported marks requests a provider cannot serve, such as a market it
+// has no coverage for
+var ErrUnsupported = errors.New("not supported by this provider")
+
+// Listing is one screener row, in the shape of FMP's stock screener
+type Listing struct {
+	Symbol            string  `json:"symbol"`
+	CompanyName       string  `json:"companyName"`
+	MarketCap         float64 `json:"marketCap"`
+	Sector            string  `json:"sector"`
+	Industry          string  `json:"industry"`
+	Beta              float64 `json:"beta"`
+	Price             float64 `json:"price"`
+	Volume            float64 `json:"volume"`
+	Exchange          string  `json:"exchange"`
+	ExchangeShortName string  `json:"exchangeShortName"`
+	Country           string  `json:"country"`
+	IsEtf             bool    `json:"isEtf"`
+	IsFund            bool    `json:"isFund"`
+	IsActivelyTrading bool    `json:"isActivelyTrading"`
+}
+
+// Provider is a market data vendor
+type Provider interface {
+	// Name identifies the provider in logs and flags, e.g. "fmp"
+	Name() string
+
+	// Screen returns every actively trading company worth at least
+	// minMarketCap US dollars listed in country (ISO 3166 alpha-2), largest
+	// first, and how many pages it fetched. Providers whose screener compares
+	// market caps in the listing currency convert the floor first. pageSize
+	// is a hint for providers that paginate. On error the listings fetched so
+	// far are returned with it.
+	Screen(country string, pageSize int, minMarketCap float64) ([]Listing, int, error)
+
+	// Quote returns the latest price of symbol
+	Quote(symbol string) (*domain.Quote, error)
+
+	// Profile returns the reference data of symbol
+	Profile(symbol string) (*domain.Profile, error)
+
+	// FX returns how many US dollars one unit of currency buys
+	FX(currency string) (float64, error)
+}
+
+// FXQuoter is implemented by providers whose exchange rates carry the time
+// they were quoted
+type FXQuoter interface {
+	FXQuote(currency string) (domain.FXRate, error)
+}
+
+// QuoteFX returns p's USD rate for currency, timed when p quoted it if p
+// says, else when it was fetched
+func QuoteFX(p Provider, currency string) (domain.FXRate, error) {
+	if quoter, ok := p.(FXQuoter); ok {
+		return quoter.FXQuote(currency)
+	}
+	rate, err := p.FX(currency)
+	if err != nil {
+		return domain.FXRate{}, err
+	}
+	return domain.FXRate{Currency: currency, Rate: rate, Time: time.Now()}, nil
+}
+
+// HistoricalFXer is implemented by providers that look up the USD rate of a
+// currency on a past date
+type HistoricalFXer interface {
+	HistoricalFX(currency string, date time.Time) (domain.FXRate, error)
+}
+
+// BatchProfiler is implemented by providers that look up many profiles in one
+// request
+type BatchProfiler interface {
+	Profiles(symbols []string) ([]domain.Profile, error)
+}
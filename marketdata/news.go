@@ -0,0 +1,134 @@
+package marketdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	_ "time/tzdata" // FMP's times are US Eastern whatever the host's zoneinfo
+
+	"algotradar/domain"
+)
+
+// summaryLength caps, in characters, the text kept with each news item
+const summaryLength = 300
+
+// fmpTimeLayout is how FMP writes the times of news, press releases and SEC
+// filings, in US Eastern time
+const fmpTimeLayout = "2006-01-02 15:04:05"
+
+var eastern = func() *time.Location {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}()
+
+// StockNewsPath is the path, below the FMP API root, of the latest limit
+// headlines about symbol
+func StockNewsPath(symbol string, limit int) string {
+	return fmt.Sprintf("/v3/stock_news?tickers=%s&limit=%d", url.QueryEscape(symbol), limit)
+}
+
+// PressReleasesPath is the path of the latest page of press releases by
+// the issuer of symbol
+func PressReleasesPath(symbol string) string {
+	return "/v3/press-releases/" + symbol + "?page=0"
+}
+
+type fmpNews struct {
+	PublishedDate string `json:"publishedDate"`
+	Title         string `json:"title"`
+	Site          string `json:"site"`
+	URL           string `json:"url"`
+	Text          string `json:"text"`
+}
+
+type fmpPressRelease struct {
+	Date  string `json:"date"`
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+// FetchNews reads the latest headlines about symbol through get, which
+// fetches a StockNewsPath or PressReleasesPath, and with pressReleases the
+// issuer's own releases too. Items keep the start of their text and have no
+// ID yet.
+func FetchNews(get func(path string) ([]byte, error), symbol string, limit int, pressReleases bool) ([]domain.NewsItem, error) {
+	body, err := get(StockNewsPath(symbol, limit))
+	if err != nil {
+		return nil, fmt.Errorf("fetch news for %s: %w", symbol, err)
+	}
+	var headlines []fmpNews
+	if err := json.Unmarshal(body, &headlines); err != nil {
+		return nil, &ParseError{What: "news for " + symbol, Err: err}
+	}
+	var items []domain.NewsItem
+	for _, h := range headlines {
+		published, ok := newsTime(h.PublishedDate)
+		if !ok || strings.TrimSpace(h.Title) == "" {
+			continue
+		}
+		items = append(items, domain.NewsItem{
+			Symbol:    symbol,
+			Kind:      domain.NewsHeadline,
+			Published: published,
+			Title:     strings.TrimSpace(h.Title),
+			Site:      h.Site,
+			URL:       h.URL,
+			Summary:   summarize(h.Text),
+		})
+	}
+	if !pressReleases {
+		return items, nil
+	}
+
+	body, err = get(PressReleasesPath(symbol))
+	if err != nil {
+		return items, fmt.Errorf("fetch press releases for %s: %w", symbol, err)
+	}
+	var releases []fmpPressRelease
+	if err := json.Unmarshal(body, &releases); err != nil {
+		return items, &ParseError{What: "press releases for " + symbol, Err: err}
+	}
+	for _, r := range releases {
+		published, ok := newsTime(r.Date)
+		if !ok || strings.TrimSpace(r.Title) == "" {
+			continue
+		}
+		items = append(items, domain.NewsItem{
+			Symbol:    symbol,
+			Kind:      domain.NewsPressRelease,
+			Published: published,
+			Title:     strings.TrimSpace(r.Title),
+			Summary:   summarize(r.Text),
+		})
+	}
+	return items, nil
+}
+
+// newsTime converts an FMP news time to RFC 3339 UTC
+func newsTime(value string) (string, bool) {
+	t, err := time.ParseInLocation(fmpTimeLayout, strings.TrimSpace(value), eastern)
+	if err != nil {
+		return "", false
+	}
+	return t.UTC().Format(time.RFC3339), true
+}
+
+// summarize collapses whitespace in text and cuts it to summaryLength
+// characters at a word boundary
+func summarize(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= summaryLength {
+		return text
+	}
+	cut := string(runes[:summaryLength])
+	if i := strings.LastIndexByte(cut, ' '); i > 0 {
+		cut = cut[:i]
+	}
+	return cut + "…"
+}
@@ -0,0 +1,29 @@
+package marketdata
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"algotradar/domain"
+)
+
+// InstitutionalHoldersPath is the path, below the FMP API root, of the 13F
+// holders of symbol
+func InstitutionalHoldersPath(symbol string) string {
+	return "/v3/institutional-holder/" + symbol
+}
+
+// FetchInstitutionalHolders reads the institutions holding symbol through
+// get, which fetches an InstitutionalHoldersPath. A symbol no institution
+// reports comes back with no holders and no error.
+func FetchInstitutionalHolders(get func(path string) ([]byte, error), symbol string) ([]domain.InstitutionalHolder, error) {
+	body, err := get(InstitutionalHoldersPath(symbol))
+	if err != nil {
+		return nil, fmt.Errorf("fetch institutional holders of %s: %w", symbol, err)
+	}
+	var holders []domain.InstitutionalHolder
+	if err := json.Unmarshal(body, &holders); err != nil {
+		return nil, &ParseError{What: "institutional holders of " + symbol, Err: err}
+	}
+	return holders, nil
+}
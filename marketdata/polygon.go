@@ -0,0 +1,310 @@
+package marketdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"algotradar/domain"
+	"algotradar/ratelimit"
+	"algotradar/refdata"
+	"algotradar/workpool"
+)
+
+// polygonTypes are the Polygon ticker types a screen keeps: common stock and
+// ADRs of common stock
+var polygonTypes = map[string]bool{"CS": true, "ADRC": true}
+
+// Polygon reads market data from Polygon.io. Polygon's stock data covers US
+// venues only, so screens of other countries fail with ErrUnsupported. It has
+// no market cap screener either: a screen lists the active tickers and looks
+// up each one's details, which costs one request per ticker.
+type Polygon struct {
+	APIKey     string
+	BaseURL    string
+	HTTPClient *http.Client
+	Logger     *slog.Logger
+
+	// Workers is how many ticker details a screen fetches at once
+	Workers int
+}
+
+// NewPolygon returns a Polygon client for apiKey
+func NewPolygon(apiKey string) *Polygon {
+	return &Polygon{
+		APIKey:     apiKey,
+		BaseURL:    "https://api.polygon.io",
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		Logger:     slog.Default(),
+		Workers:    16,
+	}
+}
+
+func (p *Polygon) Name() string { return "polygon" }
+
+type polygonTicker struct {
+	Ticker          string  `json:"ticker"`
+	Name            string  `json:"name"`
+	Type            string  `json:"type"`
+	Active          bool    `json:"active"`
+	Locale          string  `json:"locale"`
+	PrimaryExchange string  `json:"primary_exchange"`
+	CurrencyName    string  `json:"currency_name"`
+	MarketCap       float64 `json:"market_cap"`
+	SICDescription  string  `json:"sic_description"`
+	CIK             string  `json:"cik"`
+	CompositeFIGI   string  `json:"composite_figi"`
+	HomepageURL     string  `json:"homepage_url"`
+	Description     string  `json:"description"`
+}
+
+type polygonBar struct {
+	Close  float64 `json:"c"`
+	Open   float64 `json:"o"`
+	Volume float64 `json:"v"`
+}
+
+type polygonSnapshot struct {
+	Ticker           string     `json:"ticker"`
+	TodaysChange     float64    `json:"todaysChange"`
+	TodaysChangePerc float64    `json:"todaysChangePerc"`
+	Day              polygonBar `json:"day"`
+	PrevDay          polygonBar `json:"prevDay"`
+	LastTrade        struct {
+		Price float64 `json:"p"`
+	} `json:"lastTrade"`
+	Updated int64 `json:"updated"` // Unix nanoseconds
+}
+
+// Screen lists US common stocks and ADRs worth at least minMarketCap
+func (p *Polygon) Screen(country string, pageSize int, minMarketCap float64) ([]Listing, int, error) {
+	if country != "US" {
+		return nil, 0, fmt.Errorf("screen %s: %w: Polygon covers US stocks only", country, ErrUnsupported)
+	}
+
+	var tickers []polygonTicker
+	pages := 0
+	next := fmt.Sprintf("/v3/reference/tickers?market=stocks&locale=us&active=true&limit=%d", min(max(pageSize, 100), 1000))
+	for next != "" {
+		var page struct {
+			Results []polygonTicker `json:"results"`
+			NextURL string          `json:"next_url"`
+		}
+		if err := p.get(next, &page); err != nil {
+			return nil, pages, fmt.Errorf("list tickers: %w", err)
+		}
+		pages++
+		for _, t := range page.Results {
+			if t.Active && polygonTypes[t.Type] {
+				tickers = append(tickers, t)
+			}
+		}
+		next = strings.TrimPrefix(page.NextURL, p.BaseURL)
+	}
+	p.Logger.Info("listed Polygon tickers", "tickers", len(tickers), "pages", pages)
+
+	snapshots, err := p.snapshots()
+	if err != nil {
+		p.Logger.Warn("failed to fetch market snapshot, prices come from quotes only", "error", err)
+	}
+
+	var mu sync.Mutex
+	var listings []Listing
+	var failed int
+	workpool.Each(workpool.New("polygon_details", 1, p.Workers), p.Workers, tickers, func(_ int, t polygonTicker) {
+		details, err := p.details(t.Ticker)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			failed++
+			p.Logger.Debug("failed to fetch ticker details", "symbol", t.Ticker, "error", err)
+			return
+		}
+		if details.MarketCap < minMarketCap {
+			return
+		}
+		snapshot := snapshots[t.Ticker]
+		listings = append(listings, Listing{
+			Symbol:            details.Ticker,
+			CompanyName:       details.Name,
+			MarketCap:         details.MarketCap,
+			Industry:          details.SICDescription,
+			Price:             snapshotPrice(snapshot),
+			Volume:            snapshot.Day.Volume,
+			Exchange:          details.PrimaryExchange,
+			ExchangeShortName: exchangeCode(details.PrimaryExchange),
+			Country:           "US",
+			IsActivelyTrading: true,
+		})
+	})
+	if failed > 0 {
+		p.Logger.Warn("ticker details failed", "failed", failed, "tickers", len(tickers))
+	}
+
+	sort.Slice(listings, func(i, j int) bool {
+		if listings[i].MarketCap != listings[j].MarketCap {
+			return listings[i].MarketCap > listings[j].MarketCap
+		}
+		return listings[i].Symbol < listings[j].Symbol
+	})
+	return listings, pages, nil
+}
+
+// Quote returns the latest trade, or the day's close, of symbol
+func (p *Polygon) Quote(symbol string) (*domain.Quote, error) {
+	var out struct {
+		Ticker polygonSnapshot `json:"ticker"`
+	}
+	if err := p.get("/v2/snapshot/locale/us/markets/stocks/tickers/"+url.PathEscape(symbol), &out); err != nil {
+		return nil, fmt.Errorf("failed to get quote for %s: %w", symbol, err)
+	}
+	s := out.Ticker
+	price := snapshotPrice(s)
+	if price <= 0 {
+		return nil, fmt.Errorf("no quote data found for %s", symbol)
+	}
+	volume := s.Day.Volume
+	if volume == 0 {
+		volume = s.PrevDay.Volume
+	}
+	return &domain.Quote{
+		Symbol:            symbol,
+		Price:             price,
+		Change:            s.TodaysChange,
+		ChangesPercentage: s.TodaysChangePerc,
+		Open:              s.Day.Open,
+		PreviousClose:     s.PrevDay.Close,
+		Volume:            volume,
+		Timestamp:         s.Updated / int64(time.Second),
+	}, nil
+}
+
+// Profile returns symbol's ticker details. Image is left empty because
+// Polygon's logo URLs only load with the API key attached.
+func (p *Polygon) Profile(symbol string) (*domain.Profile, error) {
+	t, err := p.details(symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get company profile for %s: %w", symbol, err)
+	}
+	return &domain.Profile{
+		Symbol:      t.Ticker,
+		CompanyName: t.Name,
+		Currency:    strings.ToUpper(t.CurrencyName),
+		Country:     strings.ToUpper(t.Locale),
+		Industry:    t.SICDescription,
+		Exchange:    exchangeCode(t.PrimaryExchange),
+		MktCap:      t.MarketCap,
+		Website:     t.HomepageURL,
+		Description: t.Description,
+		IsADR:       t.Type == "ADRC",
+		CIK:         t.CIK,
+		FIGI:        t.CompositeFIGI,
+	}, nil
+}
+
+// FX returns the previous close of currency against USD
+func (p *Polygon) FX(currency string) (float64, error) {
+	if currency == "USD" {
+		return 1, nil
+	}
+	var out struct {
+		Results []polygonBar `json:"results"`
+	}
+	if err := p.get("/v2/aggs/ticker/C:"+currency+"USD/prev", &out); err != nil {
+		return 0, err
+	}
+	if len(out.Results) == 0 || out.Results[0].Close <= 0 {
+		return 0, fmt.Errorf("no %sUSD rate", currency)
+	}
+	return out.Results[0].Close, nil
+}
+
+func (p *Polygon) details(symbol string) (polygonTicker, error) {
+	var out struct {
+		Results polygonTicker `json:"results"`
+	}
+	err := p.get("/v3/reference/tickers/"+url.PathEscape(symbol), &out)
+	return out.Results, err
+}
+
+// snapshots returns the current snapshot of every US ticker in one request
+func (p *Polygon) snapshots() (map[string]polygonSnapshot, error) {
+	var out struct {
+		Tickers []polygonSnapshot `json:"tickers"`
+	}
+	if err := p.get("/v2/snapshot/locale/us/markets/stocks/tickers", &out); err != nil {
+		return nil, err
+	}
+	snapshots := make(map[string]polygonSnapshot, len(out.Tickers))
+	for _, s := range out.Tickers {
+		snapshots[s.Ticker] = s
+	}
+	return snapshots, nil
+}
+
+// snapshotPrice is the last trade, falling back to the day's and then the
+// previous day's close outside trading hours
+func snapshotPrice(s polygonSnapshot) float64 {
+	for _, price := range []float64{s.LastTrade.Price, s.Day.Close, s.PrevDay.Close} {
+		if price > 0 {
+			return price
+		}
+	}
+	return 0
+}
+
+// exchangeCode maps a Polygon MIC such as XNAS to the FMP exchange short name
+// the collectors use; unknown MICs are returned as they are
+func exchangeCode(mic string) string {
+	if e, ok := refdata.ForMIC(mic); ok {
+		return e.Code
+	}
+	return mic
+}
+
+// get fetches path and decodes the JSON response into out, retrying 429s as
+// ratelimit advises
+func (p *Polygon) get(path string, out any) error {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	target := p.BaseURL + path + separator + "apiKey=" + url.QueryEscape(p.APIKey)
+
+	for attempt := 0; ; attempt++ {
+		resp, err := p.HTTPClient.Get(target)
+		if err != nil {
+			return fmt.Errorf("failed to make request: %w", err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests:
+			limit := ratelimit.FromResponse(resp.Header, body, time.Now())
+			if limit.Daily || attempt == ratelimit.MaxRetries {
+				return limit.Error(attempt)
+			}
+			time.Sleep(limit.Wait(attempt))
+			continue
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+			return fmt.Errorf("Polygon request failed with status %d: %w", resp.StatusCode, ErrAuthFailed)
+		case resp.StatusCode != http.StatusOK:
+			return fmt.Errorf("Polygon request failed with status %d", resp.StatusCode)
+		}
+		if err := json.Unmarshal(body, out); err != nil {
+			return &ParseError{What: "Polygon response", Err: err}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,37 @@
+package marketdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"algotradar/domain"
+)
+
+// QuoteBatchSize is how many symbols one batch quote request asks for
+const QuoteBatchSize = 100
+
+// QuotesPath is the path, below the FMP API root, of the quotes of symbols
+func QuotesPath(symbols []string) string {
+	return "/v3/quote/" + strings.Join(symbols, ",")
+}
+
+// FetchQuotes reads the quotes of symbols through get, which fetches a
+// QuotesPath, in batches of QuoteBatchSize. Symbols FMP has no quote for are
+// left out. What was read before a failed batch is returned with its error.
+func FetchQuotes(get func(path string) ([]byte, error), symbols []string) ([]domain.Quote, error) {
+	var quotes []domain.Quote
+	for start := 0; start < len(symbols); start += QuoteBatchSize {
+		batch := symbols[start:min(start+QuoteBatchSize, len(symbols))]
+		body, err := get(QuotesPath(batch))
+		if err != nil {
+			return quotes, fmt.Errorf("fetch quotes: %w", err)
+		}
+		var found []domain.Quote
+		if err := json.Unmarshal(body, &found); err != nil {
+			return quotes, &ParseError{What: "quote batch", Err: err}
+		}
+		quotes = append(quotes, found...)
+	}
+	return quotes, nil
+}
@@ -0,0 +1,163 @@
+package marketdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"algotradar/domain"
+)
+
+// Router mixes providers by country: each country is screened by the
+// provider configured for it, and the symbols a screen returns are quoted and
+// profiled by that same provider. Everything else goes to Default.
+type Router struct {
+	Default   Provider
+	Countries map[string]Provider
+
+	mu     sync.RWMutex
+	owners map[string]Provider // symbol -> provider that screened it
+}
+
+// NewRouter returns a router sending countries to their providers and the
+// rest to def
+func NewRouter(def Provider, countries map[string]Provider) *Router {
+	return &Router{Default: def, Countries: countries, owners: make(map[string]Provider)}
+}
+
+// Name lists the providers in use, default first, e.g. "fmp+eodhd"
+func (r *Router) Name() string {
+	names := []string{r.Default.Name()}
+	seen := map[string]bool{names[0]: true}
+	var others []string
+	for _, p := range r.Countries {
+		if !seen[p.Name()] {
+			seen[p.Name()] = true
+			others = append(others, p.Name())
+		}
+	}
+	sort.Strings(others)
+	return strings.Join(append(names, others...), "+")
+}
+
+// For returns the provider that screens country
+func (r *Router) For(country string) Provider {
+	if p, ok := r.Countries[country]; ok {
+		return p
+	}
+	return r.Default
+}
+
+// Owner returns the provider that screened symbol, or Default
+func (r *Router) Owner(symbol string) Provider {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if p, ok := r.owners[symbol]; ok {
+		return p
+	}
+	return r.Default
+}
+
+func (r *Router) Screen(country string, pageSize int, minMarketCap float64) ([]Listing, int, error) {
+	p := r.For(country)
+	listings, pages, err := p.Screen(country, pageSize, minMarketCap)
+	r.mu.Lock()
+	for _, l := range listings {
+		r.owners[l.Symbol] = p
+	}
+	r.mu.Unlock()
+	return listings, pages, err
+}
+
+func (r *Router) Quote(symbol string) (*domain.Quote, error) {
+	return r.Owner(symbol).Quote(symbol)
+}
+
+func (r *Router) Profile(symbol string) (*domain.Profile, error) {
+	return r.Owner(symbol).Profile(symbol)
+}
+
+// FX always uses Default so every row converts at the same rates
+func (r *Router) FX(currency string) (float64, error) {
+	return r.Default.FX(currency)
+}
+
+// FXQuote is FX with the time Default quoted the rate
+func (r *Router) FXQuote(currency string) (domain.FXRate, error) {
+	return QuoteFX(r.Default, currency)
+}
+
+// HistoricalFX looks up past rates from Default too
+func (r *Router) HistoricalFX(currency string, date time.Time) (domain.FXRate, error) {
+	historical, ok := r.Default.(HistoricalFXer)
+	if !ok {
+		return domain.FXRate{}, fmt.Errorf("historical exchange rates: %w", ErrUnsupported)
+	}
+	return historical.HistoricalFX(currency, date)
+}
+
+// Profiles splits symbols by owner, batching for owners that support it.
+// Profiles found are returned even when some owner fails; the error is
+// returned only when none is found.
+func (r *Router) Profiles(symbols []string) ([]domain.Profile, error) {
+	var order []Provider
+	groups := make(map[Provider][]string)
+	for _, symbol := range symbols {
+		p := r.Owner(symbol)
+		if _, ok := groups[p]; !ok {
+			order = append(order, p)
+		}
+		groups[p] = append(groups[p], symbol)
+	}
+
+	var profiles []domain.Profile
+	var lastErr error
+	for _, p := range order {
+		if batcher, ok := p.(BatchProfiler); ok {
+			batch, err := batcher.Profiles(groups[p])
+			if err != nil {
+				lastErr = err
+			}
+			profiles = append(profiles, batch...)
+			continue
+		}
+		for _, symbol := range groups[p] {
+			profile, err := p.Profile(symbol)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			profiles = append(profiles, *profile)
+		}
+	}
+	if len(profiles) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return profiles, nil
+}
+
+// LoadProviderMap reads a JSON object mapping ISO country codes to provider
+// names, e.g. {"EG": "eodhd", "VN": "eodhd"}. Codes are upper-cased.
+func LoadProviderMap(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read provider map: %w", err)
+	}
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse provider map: %w", err)
+	}
+	providers := make(map[string]string, len(raw))
+	for country, name := range raw {
+		country = strings.ToUpper(strings.TrimSpace(country))
+		if len(country) != 2 {
+			return nil, fmt.Errorf("provider map: invalid country code %q", country)
+		}
+		providers[country] = strings.ToLower(strings.TrimSpace(name))
+	}
+	return providers, nil
+}
@@ -0,0 +1,45 @@
+package marketdata
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"algotradar/domain"
+)
+
+// MaxSharesFloatPages bounds the pagination of FMP's bulk shares-float
+// endpoint, which lists every company it covers
+const MaxSharesFloatPages = 100
+
+// SharesFloatPath is the path, below the FMP API root, of one page of the
+// bulk shares-float endpoint
+func SharesFloatPath(page int) string {
+	return fmt.Sprintf("/v4/shares_float/all?page=%d", page)
+}
+
+// FetchSharesFloat reads the bulk shares-float endpoint page by page through
+// get, which fetches a SharesFloatPath, until a page comes back empty. Only
+// the symbols in want are kept. On error the share counts read so far are
+// returned with it.
+func FetchSharesFloat(get func(path string) ([]byte, error), want map[string]bool) (map[string]domain.SharesFloat, int, error) {
+	floats := make(map[string]domain.SharesFloat, len(want))
+	for page := 0; page < MaxSharesFloatPages; page++ {
+		body, err := get(SharesFloatPath(page))
+		if err != nil {
+			return floats, page, fmt.Errorf("fetch shares float page %d: %w", page, err)
+		}
+		var entries []domain.SharesFloat
+		if err := json.Unmarshal(body, &entries); err != nil {
+			return floats, page, &ParseError{What: fmt.Sprintf("shares float page %d", page), Err: err}
+		}
+		if len(entries) == 0 {
+			return floats, page + 1, nil
+		}
+		for _, entry := range entries {
+			if want[entry.Symbol] {
+				floats[entry.Symbol] = entry
+			}
+		}
+	}
+	return floats, MaxSharesFloatPages, nil
+}
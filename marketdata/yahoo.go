@@ -0,0 +1,269 @@
+package marketdata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"algotradar/domain"
+	"algotradar/ratelimit"
+)
+
+// yahooUserAgent is sent with every request; Yahoo refuses Go's default
+const yahooUserAgent = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Safari/537.36"
+
+// errYahooCrumb marks a request Yahoo refused for a stale session crumb
+var errYahooCrumb = errors.New("Yahoo session expired")
+
+// Yahoo reads quotes from Yahoo Finance's unofficial quote API. It is meant
+// as a fallback for symbols the primary provider has no data for, mostly
+// smaller non-US listings, and cannot screen markets.
+type Yahoo struct {
+	BaseURL    string // quote API host
+	CookieURL  string // page that sets the session cookie
+	HTTPClient *http.Client
+
+	mu    sync.Mutex
+	crumb string
+}
+
+// NewYahoo returns a Yahoo Finance client with its own cookie jar
+func NewYahoo() *Yahoo {
+	jar, _ := cookiejar.New(nil)
+	return &Yahoo{
+		BaseURL:    "https://query2.finance.yahoo.com",
+		CookieURL:  "https://fc.yahoo.com",
+		HTTPClient: &http.Client{Timeout: 30 * time.Second, Jar: jar},
+	}
+}
+
+func (y *Yahoo) Name() string { return "yahoo" }
+
+type yahooQuote struct {
+	Symbol            string  `json:"symbol"`
+	LongName          string  `json:"longName"`
+	ShortName         string  `json:"shortName"`
+	Currency          string  `json:"currency"`
+	Exchange          string  `json:"exchange"`
+	Price             float64 `json:"regularMarketPrice"`
+	Change            float64 `json:"regularMarketChange"`
+	ChangePercent     float64 `json:"regularMarketChangePercent"`
+	Open              float64 `json:"regularMarketOpen"`
+	PreviousClose     float64 `json:"regularMarketPreviousClose"`
+	Volume            float64 `json:"regularMarketVolume"`
+	Time              int64   `json:"regularMarketTime"`
+	AvgVolume         float64 `json:"averageDailyVolume3Month"`
+	MarketCap         float64 `json:"marketCap"`
+	SharesOutstanding float64 `json:"sharesOutstanding"`
+	PE                float64 `json:"trailingPE"`
+	EPS               float64 `json:"epsTrailingTwelveMonths"`
+}
+
+func (yq yahooQuote) name() string {
+	if yq.LongName != "" {
+		return yq.LongName
+	}
+	return yq.ShortName
+}
+
+func (yq yahooQuote) profile() domain.Profile {
+	return domain.Profile{
+		Symbol:      yq.Symbol,
+		CompanyName: yq.name(),
+		Currency:    yq.Currency,
+		Exchange:    yq.Exchange,
+		Price:       yq.Price,
+		MktCap:      yq.MarketCap,
+	}
+}
+
+// Screen is not supported: Yahoo has no market screener API
+func (y *Yahoo) Screen(country string, pageSize int, minMarketCap float64) ([]Listing, int, error) {
+	return nil, 0, fmt.Errorf("screen %s: %w", country, ErrUnsupported)
+}
+
+// Quote returns symbol's regular-market quote, with its currency as Yahoo
+// quotes it (e.g. "GBp" for London listings priced in pence)
+func (y *Yahoo) Quote(symbol string) (*domain.Quote, error) {
+	q, err := y.quote(symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quote for %s: %w", symbol, err)
+	}
+	if q.Price <= 0 {
+		return nil, fmt.Errorf("no quote data found for %s", symbol)
+	}
+	return &domain.Quote{
+		Symbol:            symbol,
+		Name:              q.name(),
+		Price:             q.Price,
+		Change:            q.Change,
+		ChangesPercentage: q.ChangePercent,
+		Open:              q.Open,
+		PreviousClose:     q.PreviousClose,
+		MarketCap:         q.MarketCap,
+		Volume:            q.Volume,
+		AvgVolume:         q.AvgVolume,
+		SharesOutstanding: q.SharesOutstanding,
+		PE:                q.PE,
+		EPS:               q.EPS,
+		Exchange:          q.Exchange,
+		Currency:          q.Currency,
+		Timestamp:         q.Time,
+	}, nil
+}
+
+// Profile returns the reference data the quote API carries: name, currency,
+// exchange and market cap
+func (y *Yahoo) Profile(symbol string) (*domain.Profile, error) {
+	q, err := y.quote(symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get company profile for %s: %w", symbol, err)
+	}
+	profile := q.profile()
+	return &profile, nil
+}
+
+// Profiles looks up several symbols in one quote request; symbols Yahoo does
+// not know are left out
+func (y *Yahoo) Profiles(symbols []string) ([]domain.Profile, error) {
+	quotes, err := y.quotes(symbols)
+	if err != nil {
+		return nil, err
+	}
+	profiles := make([]domain.Profile, len(quotes))
+	for i, q := range quotes {
+		profiles[i] = q.profile()
+	}
+	return profiles, nil
+}
+
+// FX returns the USD rate of currency from Yahoo's CURUSD=X pair
+func (y *Yahoo) FX(currency string) (float64, error) {
+	if currency == "USD" {
+		return 1, nil
+	}
+	q, err := y.quote(currency + "USD=X")
+	if err != nil {
+		return 0, err
+	}
+	if q.Price <= 0 {
+		return 0, fmt.Errorf("no %sUSD rate", currency)
+	}
+	return q.Price, nil
+}
+
+func (y *Yahoo) quote(symbol string) (yahooQuote, error) {
+	quotes, err := y.quotes([]string{symbol})
+	if err != nil {
+		return yahooQuote{}, err
+	}
+	if len(quotes) == 0 {
+		return yahooQuote{}, fmt.Errorf("no Yahoo data for %s", symbol)
+	}
+	return quotes[0], nil
+}
+
+// quotes fetches the quotes of symbols in one request, renewing the session
+// once if Yahoo rejects its crumb
+func (y *Yahoo) quotes(symbols []string) ([]yahooQuote, error) {
+	var out struct {
+		QuoteResponse struct {
+			Result []yahooQuote `json:"result"`
+		} `json:"quoteResponse"`
+	}
+	path := "/v7/finance/quote?symbols=" + url.QueryEscape(strings.Join(symbols, ","))
+	err := y.get(path, &out)
+	if errors.Is(err, errYahooCrumb) {
+		y.mu.Lock()
+		y.crumb = ""
+		y.mu.Unlock()
+		err = y.get(path, &out)
+	}
+	return out.QuoteResponse.Result, err
+}
+
+// session returns the crumb Yahoo requires alongside its session cookie,
+// fetching both on first use
+func (y *Yahoo) session() (string, error) {
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	if y.crumb != "" {
+		return y.crumb, nil
+	}
+
+	// The cookie page answers 404 but still sets the session cookie
+	if resp, err := y.send(y.CookieURL); err == nil {
+		resp.Body.Close()
+	}
+	resp, err := y.send(y.BaseURL + "/v1/test/getcrumb")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", err
+	}
+	crumb := strings.TrimSpace(string(body))
+	if resp.StatusCode != http.StatusOK || crumb == "" {
+		return "", fmt.Errorf("Yahoo crumb request failed with status %d", resp.StatusCode)
+	}
+	y.crumb = crumb
+	return crumb, nil
+}
+
+func (y *Yahoo) send(target string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", yahooUserAgent)
+	return y.HTTPClient.Do(req)
+}
+
+// get fetches path with the session crumb and decodes the JSON response into
+// out, retrying 429s as ratelimit advises
+func (y *Yahoo) get(path string, out any) error {
+	crumb, err := y.session()
+	if err != nil {
+		return err
+	}
+	target := y.BaseURL + path + "&crumb=" + url.QueryEscape(crumb)
+
+	for attempt := 0; ; attempt++ {
+		resp, err := y.send(target)
+		if err != nil {
+			return fmt.Errorf("failed to make request: %w", err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests:
+			limit := ratelimit.FromResponse(resp.Header, body, time.Now())
+			if limit.Daily || attempt == ratelimit.MaxRetries {
+				return limit.Error(attempt)
+			}
+			time.Sleep(limit.Wait(attempt))
+			continue
+		case resp.StatusCode == http.StatusUnauthorized:
+			return errYahooCrumb
+		case resp.StatusCode != http.StatusOK:
+			return fmt.Errorf("Yahoo request failed with status %d", resp.StatusCode)
+		}
+		if err := json.Unmarshal(body, out); err != nil {
+			return &ParseError{What: "Yahoo response", Err: err}
+		}
+		return nil
+	}
+}
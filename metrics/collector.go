@@ -0,0 +1,52 @@
+package metrics
+
+import "strings"
+
+// Collector metrics shared by every FMP collector binary
+var (
+	APICalls = NewCounter("fmp_api_calls_total",
+		"FMP API requests by endpoint and HTTP status.", "endpoint", "status")
+	APIRateLimited = NewCounter("fmp_api_rate_limited_total",
+		"FMP API responses with status 429 by endpoint.", "endpoint")
+	APIRetries = NewCounter("fmp_api_retries_total",
+		"FMP API requests retried by endpoint.", "endpoint")
+	APIDuration = NewHistogram("fmp_api_request_duration_seconds",
+		"FMP API request latency by endpoint.", nil, "endpoint")
+	SymbolsProcessed = NewCounter("collector_symbols_processed_total",
+		"Symbols processed by country and outcome (kept or skipped).", "country", "outcome")
+	CountryDuration = NewHistogram("collector_country_duration_seconds",
+		"Time spent fetching one country's screener results.", nil, "country")
+	FXCacheLookups = NewCounter("collector_fx_cache_lookups_total",
+		"Exchange rate cache lookups by result (hit or miss).", "result")
+	WorkerPoolLimit = NewGauge("collector_worker_pool_limit",
+		"Current concurrency limit of an adaptive worker pool.", "pool")
+	WorkerPoolTasks = NewCounter("collector_worker_pool_tasks_total",
+		"Tasks completed by an adaptive worker pool.", "pool")
+	WorkerPoolThrottles = NewCounter("collector_worker_pool_throttles_total",
+		"Times an adaptive worker pool cut its concurrency after a rate limit.", "pool")
+	APIKeyCalls = NewCounter("fmp_api_key_calls_total",
+		"FMP API requests by masked API key.", "key")
+	APIKeysRetired = NewCounter("fmp_api_keys_retired_total",
+		"API keys taken out of rotation by reason (disabled or exhausted).", "state")
+	HTTPCacheLookups = NewCounter("http_cache_lookups_total",
+		"On-disk HTTP cache lookups by endpoint and result (hit or miss).", "endpoint", "result")
+	FallbackLookups = NewCounter("collector_fallback_lookups_total",
+		"Quotes, profiles and exchange rates filled by a fallback provider, by provider and kind (quote, profile or fx).", "provider", "kind")
+	LookupCache = NewCounter("collector_lookup_cache_total",
+		"Quote and profile lookups by kind and result (hit, shared or miss).", "kind", "result")
+)
+
+// EndpointLabel reduces a request path to a low-cardinality label by dropping the
+// query string and any symbol path segment, e.g. "/api/v3/quote/AAPL?x=1" -> "/v3/quote"
+func EndpointLabel(path string) string {
+	if i := strings.IndexAny(path, "?"); i >= 0 {
+		path = path[:i]
+	}
+	path = strings.TrimPrefix(path, "/api")
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) > 2 {
+		segments = segments[:2]
+	}
+	return "/" + strings.Join(segments, "/")
+}
@@ -0,0 +1,329 @@
+// Package mockfmp serves a small, fixed slice of the FMP API from bundled
+// fixtures, so the collectors can run end to end without spending quota. The
+// golden directory holds the outputs a correct build produces from them.
+package mockfmp
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"algotradar/domain"
+)
+
+//go:embed fixtures/*.json
+var fixtureFS embed.FS
+
+//go:embed golden/*.json
+var goldenFS embed.FS
+
+// GoldenDir is the source directory of the golden files, for regenerating them
+const GoldenDir = "mockfmp/golden"
+
+// fxDate is when the fixture exchange rates were quoted, the time of the
+// fixture quotes
+const fxDate = "2025-07-02 14:00:00"
+
+// screenerRow is one stock-screener result
+type screenerRow struct {
+	Symbol            string  `json:"symbol"`
+	CompanyName       string  `json:"companyName"`
+	MarketCap         float64 `json:"marketCap"`
+	Sector            string  `json:"sector"`
+	Industry          string  `json:"industry"`
+	Beta              float64 `json:"beta"`
+	Price             float64 `json:"price"`
+	Volume            float64 `json:"volume"`
+	Exchange          string  `json:"exchange"`
+	ExchangeShortName string  `json:"exchangeShortName"`
+	Country           string  `json:"country"`
+	IsEtf             bool    `json:"isEtf"`
+	IsFund            bool    `json:"isFund"`
+	IsActivelyTrading bool    `json:"isActivelyTrading"`
+}
+
+// Server answers FMP requests from the fixtures
+type Server struct {
+	screener  []screenerRow
+	quotes    map[string]domain.Quote
+	profiles  map[string]domain.Profile
+	stockList json.RawMessage
+	fx        map[string]float64
+	delisted  []domain.DelistedCompany
+	floats    []domain.SharesFloat
+	changes   map[string]json.RawMessage
+	analysts  map[string]map[string][]json.RawMessage // by endpoint, then symbol
+	holders   map[string][]domain.InstitutionalHolder
+	news      map[string]map[string][]json.RawMessage // by endpoint, then symbol
+	filings   map[string][]json.RawMessage
+	ipos      []ipoRow
+	extended  map[string]domain.ExtendedHoursTrade
+	cashFlows map[string][]json.RawMessage
+}
+
+// ipoRow is an entry of the IPO calendar in FMP's layout
+type ipoRow struct {
+	Date       string   `json:"date"`
+	Company    string   `json:"company"`
+	Symbol     string   `json:"symbol"`
+	Exchange   string   `json:"exchange"`
+	Actions    string   `json:"actions"`
+	Shares     *float64 `json:"shares"`
+	PriceRange *string  `json:"priceRange"`
+	MarketCap  *float64 `json:"marketCap"`
+}
+
+// New loads the bundled fixtures
+func New() (*Server, error) {
+	s := &Server{}
+	var quotes []domain.Quote
+	var profiles []domain.Profile
+	var changes []json.RawMessage
+	var analysts map[string][]json.RawMessage
+	var news map[string][]json.RawMessage
+	var extended []domain.ExtendedHoursTrade
+	for name, target := range map[string]any{
+		"screener.json":              &s.screener,
+		"quotes.json":                &quotes,
+		"profiles.json":              &profiles,
+		"stock_list.json":            &s.stockList,
+		"fx.json":                    &s.fx,
+		"delisted.json":              &s.delisted,
+		"shares_float.json":          &s.floats,
+		"price_change.json":          &changes,
+		"analysts.json":              &analysts,
+		"institutional_holders.json": &s.holders,
+		"news.json":                  &news,
+		"sec_filings.json":           &s.filings,
+		"ipo_calendar.json":          &s.ipos,
+		"extended_hours.json":        &extended,
+		"cash_flow.json":             &s.cashFlows,
+	} {
+		data, err := fixtureFS.ReadFile("fixtures/" + name)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, target); err != nil {
+			return nil, fmt.Errorf("invalid fixture %s: %w", name, err)
+		}
+	}
+
+	sort.Slice(s.screener, func(i, j int) bool {
+		if s.screener[i].MarketCap != s.screener[j].MarketCap {
+			return s.screener[i].MarketCap > s.screener[j].MarketCap
+		}
+		return s.screener[i].Symbol < s.screener[j].Symbol
+	})
+	s.quotes = make(map[string]domain.Quote, len(quotes))
+	for _, q := range quotes {
+		s.quotes[q.Symbol] = q
+	}
+	s.changes = make(map[string]json.RawMessage, len(changes))
+	for _, change := range changes {
+		var row struct{ Symbol string }
+		if err := json.Unmarshal(change, &row); err != nil {
+			return nil, fmt.Errorf("invalid fixture price_change.json: %w", err)
+		}
+		s.changes[row.Symbol] = change
+	}
+	var err error
+	if s.analysts, err = groupBySymbol("analysts.json", analysts); err != nil {
+		return nil, err
+	}
+	if s.news, err = groupBySymbol("news.json", news); err != nil {
+		return nil, err
+	}
+	s.extended = make(map[string]domain.ExtendedHoursTrade, len(extended))
+	for _, t := range extended {
+		s.extended[t.Symbol] = t
+	}
+	s.profiles = make(map[string]domain.Profile, len(profiles))
+	for _, p := range profiles {
+		s.profiles[p.Symbol] = p
+	}
+	return s, nil
+}
+
+// groupBySymbol indexes the rows of each endpoint of fixture name by their
+// symbol field
+func groupBySymbol(name string, endpoints map[string][]json.RawMessage) (map[string]map[string][]json.RawMessage, error) {
+	grouped := make(map[string]map[string][]json.RawMessage, len(endpoints))
+	for endpoint, rows := range endpoints {
+		grouped[endpoint] = make(map[string][]json.RawMessage)
+		for _, raw := range rows {
+			var row struct{ Symbol string }
+			if err := json.Unmarshal(raw, &row); err != nil {
+				return nil, fmt.Errorf("invalid fixture %s: %w", name, err)
+			}
+			grouped[endpoint][row.Symbol] = append(grouped[endpoint][row.Symbol], raw)
+		}
+	}
+	return grouped, nil
+}
+
+// rowsOf returns the rows of symbol, or an empty list
+func rowsOf(bySymbol map[string][]json.RawMessage, symbol string) []json.RawMessage {
+	if rows := bySymbol[symbol]; rows != nil {
+		return rows
+	}
+	return []json.RawMessage{}
+}
+
+// Golden returns the bundled golden output with the given file name
+func Golden(name string) ([]byte, error) {
+	return fs.ReadFile(goldenFS, "golden/"+name)
+}
+
+// RevokedKey is an API key the mock rejects, for exercising key rotation
+const RevokedKey = "revoked"
+
+// ServeHTTP routes /api/v3 requests, and the bulk shares float and
+// per-symbol analyst endpoints of /api/v4; every request must carry an
+// apikey other than RevokedKey
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if key := r.URL.Query().Get("apikey"); key == "" || key == RevokedKey {
+		http.Error(w, `{"Error Message":"Invalid API KEY."}`, http.StatusUnauthorized)
+		return
+	}
+	if r.URL.Path == "/api/v4/shares_float/all" {
+		// Like delisted companies, one page holds every fixture
+		if page, _ := strconv.Atoi(r.URL.Query().Get("page")); page > 0 {
+			writeJSON(w, []domain.SharesFloat{})
+			return
+		}
+		writeJSON(w, s.floats)
+		return
+	}
+	if symbols, ok := strings.CutPrefix(r.URL.Path, "/api/v4/batch-pre-post-market-trade/"); ok {
+		writeJSON(w, pick(s.extended, symbols))
+		return
+	}
+	if bySymbol, ok := s.analysts[strings.TrimPrefix(r.URL.Path, "/api/v4/")]; ok {
+		writeJSON(w, rowsOf(bySymbol, r.URL.Query().Get("symbol")))
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/v3/")
+	route, arg, _ := strings.Cut(path, "/")
+	switch route {
+	case "stock-screener":
+		s.serveScreener(w, r)
+	case "quote":
+		writeJSON(w, pick(s.quotes, arg))
+	case "profile":
+		writeJSON(w, pick(s.profiles, arg))
+	case "stock-price-change":
+		writeJSON(w, pick(s.changes, arg))
+	case "stock_news":
+		// Only single-ticker requests are served, as the news collector makes
+		writeJSON(w, rowsOf(s.news["stock_news"], r.URL.Query().Get("tickers")))
+	case "press-releases":
+		writeJSON(w, rowsOf(s.news["press_releases"], arg))
+	case "sec_filings":
+		// One page holds every filing of a symbol
+		if page, _ := strconv.Atoi(r.URL.Query().Get("page")); page > 0 {
+			writeJSON(w, []json.RawMessage{})
+			return
+		}
+		writeJSON(w, rowsOf(s.filings, arg))
+	case "ipo_calendar":
+		from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+		ipos := []ipoRow{}
+		for _, ipo := range s.ipos {
+			if ipo.Date >= from && ipo.Date <= to {
+				ipos = append(ipos, ipo)
+			}
+		}
+		writeJSON(w, ipos)
+	case "cash-flow-statement":
+		writeJSON(w, rowsOf(s.cashFlows, arg))
+	case "institutional-holder":
+		holders := s.holders[arg]
+		if holders == nil {
+			holders = []domain.InstitutionalHolder{}
+		}
+		writeJSON(w, holders)
+	case "stock":
+		if arg != "list" {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, s.stockList)
+	case "delisted-companies":
+		// One page holds every fixture; later pages are empty, as past the
+		// end of the real endpoint
+		if page, _ := strconv.Atoi(r.URL.Query().Get("page")); page > 0 {
+			writeJSON(w, []domain.DelistedCompany{})
+			return
+		}
+		writeJSON(w, s.delisted)
+	case "fx":
+		rate, ok := s.fx[arg]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, []map[string]any{{"ticker": arg[:3] + "/" + arg[3:], "price": rate, "date": fxDate}})
+	case "historical-price-full":
+		// The fixture rates are the day's close; other days have no bar
+		rate, ok := s.fx[arg]
+		day, _, _ := strings.Cut(fxDate, " ")
+		from, to := r.URL.Query().Get("from"), r.URL.Query().Get("to")
+		if !ok || day < from || day > to {
+			writeJSON(w, map[string]any{})
+			return
+		}
+		writeJSON(w, map[string]any{"symbol": arg, "historical": []map[string]any{{"date": day, "close": rate}}})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveScreener filters by country and market cap and honors limit, like the
+// real screener sorted by market cap descending
+func (s *Server) serveScreener(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = len(s.screener)
+	}
+	lower, _ := strconv.ParseFloat(query.Get("marketCapMoreThan"), 64)
+	upper, _ := strconv.ParseFloat(query.Get("marketCapLowerThan"), 64)
+	country := query.Get("country")
+
+	rows := []screenerRow{}
+	for _, row := range s.screener {
+		switch {
+		case country != "" && row.Country != country:
+		case row.MarketCap <= lower:
+		case upper > 0 && row.MarketCap >= upper:
+		default:
+			rows = append(rows, row)
+		}
+		if len(rows) == limit {
+			break
+		}
+	}
+	writeJSON(w, rows)
+}
+
+// pick returns the entries for a comma-separated symbol list, skipping unknown symbols
+func pick[T any](entries map[string]T, symbols string) []T {
+	out := []T{}
+	for _, symbol := range strings.Split(symbols, ",") {
+		if entry, ok := entries[symbol]; ok {
+			out = append(out, entry)
+		}
+	}
+	return out
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
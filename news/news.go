@@ -0,0 +1,101 @@
+// Package news keeps a deduplicated history of the headlines and press
+// releases published about each symbol, and scores their tone with a simple
+// keyword count.
+package news
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"strings"
+
+	"algotradar/domain"
+)
+
+// trackingParams are query parameters that vary between links to the same
+// article and are dropped before hashing
+var trackingParams = map[string]bool{
+	"utm_source": true, "utm_medium": true, "utm_campaign": true, "utm_term": true,
+	"utm_content": true, "guccounter": true, "ncid": true, "cmpid": true,
+}
+
+// ID identifies an item of symbol. Items with a link are the same item
+// whenever their links match once scheme, letter case of the host, trailing
+// slash, fragment and tracking parameters are ignored; press releases,
+// which come without one, are the same when kind, title and publication
+// time match.
+func ID(symbol, kind, link, title, published string) string {
+	key := symbol + "|" + kind + "|" + strings.Join(strings.Fields(strings.ToLower(title)), " ") + "|" + published
+	if normalized := normalizeURL(link); normalized != "" {
+		key = symbol + "|" + normalized
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:12])
+}
+
+func normalizeURL(link string) string {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	query := u.Query()
+	for param := range query {
+		if trackingParams[strings.ToLower(param)] {
+			query.Del(param)
+		}
+	}
+	normalized := strings.ToLower(u.Host) + strings.TrimSuffix(u.EscapedPath(), "/")
+	if encoded := query.Encode(); encoded != "" {
+		normalized += "?" + encoded
+	}
+	return normalized
+}
+
+// Merge adds items to history, skipping those whose ID history already
+// holds, and returns the result ordered by symbol and then newest first,
+// with how many items were new. Items without an ID get one.
+func Merge(history, items []domain.NewsItem) ([]domain.NewsItem, int) {
+	seen := make(map[string]bool, len(history)+len(items))
+	merged := make([]domain.NewsItem, 0, len(history)+len(items))
+	for _, item := range history {
+		if !seen[item.ID] {
+			seen[item.ID] = true
+			merged = append(merged, item)
+		}
+	}
+	added := 0
+	for _, item := range items {
+		if item.ID == "" {
+			item.ID = ID(item.Symbol, item.Kind, item.URL, item.Title, item.Published)
+		}
+		if seen[item.ID] {
+			continue
+		}
+		seen[item.ID] = true
+		merged = append(merged, item)
+		added++
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		a, b := merged[i], merged[j]
+		if a.Symbol != b.Symbol {
+			return a.Symbol < b.Symbol
+		}
+		if a.Published != b.Published {
+			return a.Published > b.Published
+		}
+		return a.ID < b.ID
+	})
+	return merged, added
+}
+
+// Prune drops the items published before cutoff, an RFC 3339 time
+func Prune(items []domain.NewsItem, cutoff string) []domain.NewsItem {
+	kept := items[:0]
+	for _, item := range items {
+		if item.Published >= cutoff {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,55 @@
+package news
+
+import (
+	"strings"
+	"unicode"
+)
+
+// positiveWords and negativeWords are the keywords the sentiment score
+// counts. A word matches a keyword it equals, or one of five letters or more
+// it starts with, so "upgrade" also counts "upgraded".
+var positiveWords = []string{
+	"beat", "beats", "surge", "soar", "soars", "soared", "jump", "jumps", "jumped", "rally", "rallies",
+	"gain", "gains", "gained", "record", "upgrade", "outperform", "raise", "strong", "stronger", "growth",
+	"profit", "bullish", "approv", "win", "wins", "boost", "expand", "exceed", "optimis", "rebound",
+	"tops", "buyback",
+}
+
+var negativeWords = []string{
+	"miss", "misses", "missed", "plunge", "slump", "tumble", "fall", "falls", "fell", "drop", "drops",
+	"dropped", "downgrade", "underperform", "cut", "cuts", "weak", "weaker", "weakness", "loss", "losses",
+	"bearish", "lawsuit", "probe", "probes", "investigat", "recall", "fraud", "layoff", "warn", "warns",
+	"warning", "decline", "slowdown", "bankrupt", "default", "delay", "fined",
+}
+
+// Sentiment scores the tone of text from -1 (all negative keywords) to 1
+// (all positive), as (positive - negative) / (positive + negative). It
+// reports false when text holds none of the keywords, which is not the same
+// as a neutral 0.
+func Sentiment(text string) (float64, bool) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	positive, negative := 0, 0
+	for _, word := range words {
+		switch {
+		case matches(word, positiveWords):
+			positive++
+		case matches(word, negativeWords):
+			negative++
+		}
+	}
+	if positive+negative == 0 {
+		return 0, false
+	}
+	return float64(positive-negative) / float64(positive+negative), true
+}
+
+func matches(word string, stems []string) bool {
+	for _, stem := range stems {
+		if word == stem || (len(stem) > 4 && strings.HasPrefix(word, stem)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,296 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"algotradar/domain"
+)
+
+const (
+	// DefaultSMTPPort is the submission port used when SMTP_PORT is not set
+	DefaultSMTPPort = "587"
+	// emailErrors is how many run errors an email report quotes
+	emailErrors = 10
+)
+
+// Artifact is an output of the run linked from the email report
+type Artifact struct {
+	Name string
+	// URL is where the artifact can be opened, empty when it is only a local file
+	URL string
+}
+
+// FileArtifacts lists the files a run wrote, linked under baseURL when it is
+// set, e.g. the web root the output directory is published at
+func FileArtifacts(outputs []domain.OutputFile, baseURL string) []Artifact {
+	artifacts := make([]Artifact, len(outputs))
+	for i, output := range outputs {
+		artifacts[i] = Artifact{Name: output.Path}
+		if baseURL != "" {
+			artifacts[i].URL = strings.TrimSuffix(baseURL, "/") + "/" + strings.TrimPrefix(filepath.ToSlash(output.Path), "/")
+		}
+	}
+	return artifacts
+}
+
+// EmailReport is what the email report shows: the run summary, the top of
+// the ranking, the company count of each country and the outputs written
+type EmailReport struct {
+	Summary
+	Top       []domain.Asset
+	Countries map[string]int
+	Artifacts []Artifact
+}
+
+// Email sends the run report as an HTML email through an SMTP server
+// configured by SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD and
+// SMTP_FROM. Servers on port 465 are reached over TLS; others are asked to
+// STARTTLS when they offer it.
+type Email struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+	To       []string
+	Logger   *slog.Logger
+}
+
+// NewEmail configures an email report to recipients from the environment.
+// No recipients yield nil, which sends nothing.
+func NewEmail(to []string, logger *slog.Logger) (*Email, error) {
+	if len(to) == 0 {
+		return nil, nil
+	}
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return nil, errors.New("SMTP_HOST must be set to send email reports")
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = DefaultSMTPPort
+	}
+	e := &Email{
+		Addr:     net.JoinHostPort(host, port),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+		To:       to,
+		Logger:   logger,
+	}
+	if e.From == "" {
+		e.From = e.Username
+	}
+	if !strings.Contains(e.From, "@") {
+		return nil, errors.New("SMTP_FROM (or SMTP_USERNAME) must be the sender's address")
+	}
+	return e, nil
+}
+
+// Send emails the report; like Notify it logs a failure rather than failing the run
+func (e *Email) Send(r EmailReport) {
+	if e == nil {
+		return
+	}
+	logger := e.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	subject, body, err := RenderEmail(r)
+	if err == nil {
+		err = e.send(subject, body)
+	}
+	if err != nil {
+		logger.Warn("failed to send email report", "to", strings.Join(e.To, ","), "error", err)
+		return
+	}
+	logger.Info("email report sent", "to", strings.Join(e.To, ","))
+}
+
+func (e *Email) send(subject, body string) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/html; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	host, port, _ := net.SplitHostPort(e.Addr)
+	var auth smtp.Auth
+	if e.Username != "" {
+		auth = smtp.PlainAuth("", e.Username, e.Password, host)
+	}
+	if port != "465" {
+		return smtp.SendMail(e.Addr, auth, e.From, e.To, msg.Bytes())
+	}
+	return sendTLS(e.Addr, host, auth, e.From, e.To, msg.Bytes())
+}
+
+// sendTLS delivers msg over implicit TLS, which smtp.SendMail does not support
+func sendTLS(addr, host string, auth smtp.Auth, from string, to []string, msg []byte) error {
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", addr, &tls.Config{ServerName: host})
+	if err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if auth != nil {
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// emailTemplate lays the report out with inline styles, which mail clients keep
+var emailTemplate = template.Must(template.New("email").Funcs(template.FuncMap{
+	"marketCap": formatMarketCap,
+	"inc":       func(i int) int { return i + 1 },
+}).Parse(`<!DOCTYPE html>
+<html><body style="font-family:Arial,Helvetica,sans-serif;font-size:14px;color:#222">
+<h2 style="margin:0 0 8px">{{.Heading}}</h2>
+<p style="margin:0 0 16px">{{.Rows}} companies from {{len .Countries}} countries. {{.Run.APICallsTotal}} API calls, {{.Run.APIErrors}} failed, {{.Run.RateLimited}} rate limited.</p>
+{{- if .Failure}}
+<p style="color:#b00020">{{.FailureText}}</p>
+{{- end}}
+{{- if .Run.FailedCountries}}
+<p style="color:#b00020">Incomplete countries:{{range $i, $f := .Run.FailedCountries}}{{if $i}},{{end}} {{$f.Country}} ({{$f.Kind}}, {{$f.Partial}} rows){{end}}</p>
+{{- end}}
+{{- if .Top}}
+<h3>Top {{len .Top}} by market cap</h3>
+<table cellpadding="6" cellspacing="0" style="border-collapse:collapse">
+<tr style="background:#ddebf7;text-align:left"><th>Rank</th><th>Ticker</th><th>Company</th><th>Country</th><th>Exchange</th><th style="text-align:right">Market Cap</th></tr>
+{{- range $i, $a := .Top}}
+<tr style="border-top:1px solid #ddd"><td>{{inc $i}}</td><td>{{$a.Ticker}}</td><td>{{$a.Name}}</td><td>{{$a.Country}}</td><td>{{$a.PrimaryExchange}}</td><td style="text-align:right">{{marketCap $a.MarketCap}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Movers}}
+<p>{{.Movers}}</p>
+{{- end}}
+{{- if .CountryRows}}
+<h3>Companies by country</h3>
+<table cellpadding="4" cellspacing="0" style="border-collapse:collapse">
+{{- range .CountryRows}}
+<tr><td>{{.Country}}</td><td style="text-align:right">{{.Count}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Artifacts}}
+<h3>Outputs</h3>
+<ul>
+{{- range .Artifacts}}
+<li>{{if .URL}}<a href="{{.URL}}">{{.Name}}</a>{{else}}{{.Name}}{{end}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if .Errors}}
+<h3>Errors ({{.Run.ErrorCount}})</h3>
+<ul>
+{{- range .Errors}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+{{- end}}
+</body></html>
+`))
+
+// RenderEmail returns the subject and HTML body of the report
+func RenderEmail(r EmailReport) (string, string, error) {
+	type countryRow struct {
+		Country string
+		Count   int
+	}
+	countries := make([]countryRow, 0, len(r.Countries))
+	for country, count := range r.Countries {
+		countries = append(countries, countryRow{country, count})
+	}
+	sort.Slice(countries, func(i, j int) bool {
+		if countries[i].Count != countries[j].Count {
+			return countries[i].Count > countries[j].Count
+		}
+		return countries[i].Country < countries[j].Country
+	})
+
+	var failure string
+	if r.Failure != nil {
+		failure = redact(r.Failure.Error())
+	}
+	var errs []string
+	for _, e := range r.Run.Errors[:min(emailErrors, len(r.Run.Errors))] {
+		errs = append(errs, redact(e))
+	}
+	var movers []string
+	if len(r.Gainers) > 0 {
+		movers = append(movers, "Top gainers: "+formatMovers(r.Gainers))
+	}
+	if len(r.Losers) > 0 {
+		movers = append(movers, "Top losers: "+formatMovers(r.Losers))
+	}
+
+	heading := fmt.Sprintf("%s finished", r.Run.Collector)
+	if r.Failure != nil {
+		heading = fmt.Sprintf("%s failed", r.Run.Collector)
+	}
+	subject := fmt.Sprintf("%s: %d companies, %s", heading, r.Rows, r.Run.FinishedAt.UTC().Format("2006-01-02 15:04 MST"))
+
+	var body bytes.Buffer
+	err := emailTemplate.Execute(&body, struct {
+		EmailReport
+		Heading     string
+		FailureText string
+		Movers      string
+		CountryRows []countryRow
+		Errors      []string
+	}{r, heading, failure, strings.Join(movers, ". "), countries, errs})
+	return subject, body.String(), err
+}
+
+func formatMarketCap(v float64) string {
+	switch {
+	case v >= 1e12:
+		return fmt.Sprintf("$%.1fT", v/1e12)
+	case v >= 1e9:
+		return fmt.Sprintf("$%.1fB", v/1e9)
+	case v >= 1e6:
+		return fmt.Sprintf("$%.1fM", v/1e6)
+	}
+	return fmt.Sprintf("$%.0f", v)
+}
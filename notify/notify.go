@@ -0,0 +1,263 @@
+// Package notify posts a summary of each collection run to chat webhooks,
+// Slack, Discord or Telegram, when the run finishes or fails, and turns the
+// summary into an alert when too many API calls failed.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"algotradar/aggregate"
+	"algotradar/domain"
+)
+
+const (
+	// DefaultErrorRate is the share of failed API calls above which the
+	// summary becomes an alert
+	DefaultErrorRate = 0.05
+	// DefaultMovers is how many gainers and losers a summary lists
+	DefaultMovers = 3
+	// maxErrors is how many run errors a summary quotes
+	maxErrors = 3
+)
+
+// telegramAPI is the Bot API host; TELEGRAM_API_ENDPOINT overrides it
+const telegramAPI = "https://api.telegram.org"
+
+// apiKeyParam matches API keys in request URLs quoted by run errors
+var apiKeyParam = regexp.MustCompile(`(?i)(apikey|api_key|token)=[^&\s"]+`)
+
+// Message length limits of each service
+var maxLength = map[string]int{
+	"slack":    40000,
+	"discord":  2000,
+	"telegram": 4096,
+}
+
+// Webhook is one destination, configured with a spec of the form
+// "slack:WEBHOOK_URL", "discord:WEBHOOK_URL" or "telegram:CHAT_ID". Telegram
+// messages are sent by the bot whose token is in TELEGRAM_BOT_TOKEN.
+type Webhook struct {
+	Kind   string
+	Target string
+}
+
+// Parse builds a webhook from its spec
+func Parse(spec string) (*Webhook, error) {
+	kind, target, ok := strings.Cut(spec, ":")
+	if !ok || target == "" {
+		return nil, fmt.Errorf("invalid notification target %q: want kind:target", spec)
+	}
+	switch kind {
+	case "slack", "discord":
+		if !strings.HasPrefix(target, "https://") && !strings.HasPrefix(target, "http://") {
+			return nil, fmt.Errorf("invalid %s webhook %q: want a URL", kind, target)
+		}
+	case "telegram":
+	default:
+		return nil, fmt.Errorf("unknown notification kind %q: want slack, discord or telegram", kind)
+	}
+	return &Webhook{Kind: kind, Target: target}, nil
+}
+
+// String describes the webhook for logs without its secret URL path
+func (w *Webhook) String() string {
+	if w.Kind == "telegram" {
+		return "telegram:" + w.Target
+	}
+	return w.Kind
+}
+
+// Specs is a repeatable command-line flag collecting webhook specs
+type Specs []string
+
+func (s *Specs) String() string { return strings.Join(*s, ",") }
+
+func (s *Specs) Set(spec string) error {
+	if _, err := Parse(spec); err != nil {
+		return err
+	}
+	*s = append(*s, spec)
+	return nil
+}
+
+// Summary is what a collector reports when it finishes
+type Summary struct {
+	Run  domain.Run
+	Rows int
+	// Failure is the error that stopped the run, nil when it completed
+	Failure error
+	// Gainers and Losers are the day's largest moves across all countries
+	Gainers, Losers []aggregate.Mover
+}
+
+// Notifier posts summaries to every webhook
+type Notifier struct {
+	Webhooks []*Webhook
+	// ErrorRate is the share of failed API calls that raises an alert
+	ErrorRate float64
+	Client    *http.Client
+	Logger    *slog.Logger
+}
+
+// New parses specs, plus the comma-separated ones in NOTIFY_WEBHOOKS, into a
+// notifier. Webhook URLs are secrets, so the environment variable keeps them
+// off the command line. No specs yield nil, which notifies nobody.
+func New(specs []string, errorRate float64, logger *slog.Logger) (*Notifier, error) {
+	for _, spec := range strings.Split(os.Getenv("NOTIFY_WEBHOOKS"), ",") {
+		if spec = strings.TrimSpace(spec); spec != "" {
+			specs = append(specs, spec)
+		}
+	}
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	n := &Notifier{ErrorRate: errorRate, Client: &http.Client{Timeout: 15 * time.Second}, Logger: logger}
+	for _, spec := range specs {
+		w, err := Parse(spec)
+		if err != nil {
+			return nil, err
+		}
+		n.Webhooks = append(n.Webhooks, w)
+	}
+	return n, nil
+}
+
+// Notify posts the summary to every webhook. A failing webhook does not stop
+// the others, and failures are logged rather than failing the run.
+func (n *Notifier) Notify(s Summary) {
+	if n == nil {
+		return
+	}
+	logger := n.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	text := n.Format(s)
+	for _, w := range n.Webhooks {
+		if err := n.post(w, text); err != nil {
+			logger.Warn("failed to send notification", "webhook", w.String(), "error", err)
+			continue
+		}
+		logger.Info("notification sent", "webhook", w.String())
+	}
+}
+
+// Format renders the summary as plain text, which every service shows as is
+func (n *Notifier) Format(s Summary) string {
+	run := s.Run
+	duration := time.Duration(run.DurationSeconds * float64(time.Second)).Round(time.Second)
+	errorRate := 0.0
+	if run.APICallsTotal > 0 {
+		errorRate = float64(run.APIErrors) / float64(run.APICallsTotal)
+	}
+
+	var b strings.Builder
+	switch {
+	case s.Failure != nil:
+		fmt.Fprintf(&b, "❌ %s failed after %s: %s\n", run.Collector, duration,
+			redact(s.Failure.Error()))
+	case n.ErrorRate > 0 && errorRate > n.ErrorRate:
+		fmt.Fprintf(&b, "⚠️ %s finished with a high API error rate of %.1f%% (threshold %.1f%%) in %s\n",
+			run.Collector, errorRate*100, n.ErrorRate*100, duration)
+	default:
+		fmt.Fprintf(&b, "✅ %s finished in %s\n", run.Collector, duration)
+	}
+
+	fmt.Fprintf(&b, "Rows: %d from %d countries, %d dropped\n", s.Rows, len(run.SymbolsPerCountry), len(run.Dropped))
+	fmt.Fprintf(&b, "API calls: %d, %d failed, %d rate limited\n", run.APICallsTotal, run.APIErrors, run.RateLimited)
+	if len(run.FailedCountries) > 0 {
+		parts := make([]string, len(run.FailedCountries))
+		for i, f := range run.FailedCountries {
+			parts[i] = fmt.Sprintf("%s (%s, %d rows)", f.Country, f.Kind, f.Partial)
+		}
+		fmt.Fprintf(&b, "Incomplete countries: %s\n", strings.Join(parts, ", "))
+	}
+	if len(s.Gainers) > 0 {
+		fmt.Fprintf(&b, "Top gainers: %s\n", formatMovers(s.Gainers))
+	}
+	if len(s.Losers) > 0 {
+		fmt.Fprintf(&b, "Top losers: %s\n", formatMovers(s.Losers))
+	}
+	if run.ErrorCount > 0 {
+		fmt.Fprintf(&b, "Errors (%d):\n", run.ErrorCount)
+		for _, e := range run.Errors[:min(maxErrors, len(run.Errors))] {
+			fmt.Fprintf(&b, "• %s\n", redact(e))
+		}
+		if run.ErrorCount > maxErrors {
+			fmt.Fprintf(&b, "• and %d more in the run report\n", run.ErrorCount-maxErrors)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// redact masks API keys in an error message; chat channels are read more
+// widely than the run report
+func redact(msg string) string {
+	return apiKeyParam.ReplaceAllString(msg, "${1}=REDACTED")
+}
+
+func formatMovers(movers []aggregate.Mover) string {
+	parts := make([]string, len(movers))
+	for i, m := range movers {
+		parts[i] = fmt.Sprintf("%s %+.1f%%", m.Ticker, m.PercentageChange)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// post sends text to one webhook
+func (n *Notifier) post(w *Webhook, text string) error {
+	if limit := maxLength[w.Kind]; len(text) > limit {
+		text = strings.ToValidUTF8(text[:limit-3], "") + "..."
+	}
+
+	endpoint := w.Target
+	var payload map[string]any
+	switch w.Kind {
+	case "slack":
+		payload = map[string]any{"text": text}
+	case "discord":
+		payload = map[string]any{"content": text}
+	case "telegram":
+		token := os.Getenv("TELEGRAM_BOT_TOKEN")
+		if token == "" {
+			return errors.New("TELEGRAM_BOT_TOKEN is not set")
+		}
+		base := telegramAPI
+		if override := os.Getenv("TELEGRAM_API_ENDPOINT"); override != "" {
+			base = strings.TrimSuffix(override, "/")
+		}
+		endpoint = base + "/bot" + token + "/sendMessage"
+		payload = map[string]any{"chat_id": w.Target, "text": text}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := n.Client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		// The URL holds the webhook secret or bot token, so report only the cause
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
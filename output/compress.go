@@ -0,0 +1,90 @@
+package output
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression extensions: an output path ending in one of them is written
+// compressed, e.g. global_stocks_fmp.json.gz or global_stocks_fmp.csv.zst
+const (
+	GzipExt = ".gz"
+	ZstdExt = ".zst"
+)
+
+// CompressionExt returns the compression extension path ends with, or ""
+func CompressionExt(path string) string {
+	for _, ext := range []string{GzipExt, ZstdExt} {
+		if strings.HasSuffix(path, ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+// Uncompressed returns path without its compression extension, which names
+// the format inside, e.g. global_stocks_fmp.json for global_stocks_fmp.json.gz
+func Uncompressed(path string) string {
+	return strings.TrimSuffix(path, CompressionExt(path))
+}
+
+// NewWriter returns a writer compressing to w with the codec path's
+// extension selects, or w itself when it selects none. Closing it completes
+// the compressed stream but does not close w.
+func NewWriter(w io.Writer, path string) (io.WriteCloser, error) {
+	switch CompressionExt(path) {
+	case GzipExt:
+		return gzip.NewWriter(w), nil
+	case ZstdExt:
+		return zstd.NewWriter(w)
+	}
+	return nopCloser{w}, nil
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// ReadFile reads an output file, decompressing it when its extension says
+// it is compressed
+func ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Decompress(data, path)
+}
+
+// Decompress decodes data read from path with the codec its extension selects
+func Decompress(data []byte, path string) ([]byte, error) {
+	var reader io.Reader
+	switch CompressionExt(path) {
+	case GzipExt:
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %w", path, err)
+		}
+		defer gz.Close()
+		reader = gz
+	case ZstdExt:
+		zr, err := zstd.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %w", path, err)
+		}
+		defer zr.Close()
+		reader = zr
+	default:
+		return data, nil
+	}
+	plain, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s: %w", path, err)
+	}
+	return plain, nil
+}
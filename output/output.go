@@ -0,0 +1,96 @@
+// Package output names collector output files so several runs per day can
+// coexist, and keeps a stable "latest" path pointing at the newest run.
+package output
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"algotradar/integrity"
+)
+
+// TimestampFormat is the UTC run stamp inserted into timestamped file names
+const TimestampFormat = "2006-01-02T1504Z"
+
+// PointerSuffix is appended to the stable path when symlinks are unavailable;
+// the pointer file holds the name of the newest run's file
+const PointerSuffix = ".latest"
+
+// Timestamped inserts the run time into path, e.g. global_stocks_fmp.json
+// becomes global_stocks_fmp_2024-06-01T2200Z.json and global_stocks_fmp.csv.gz
+// becomes global_stocks_fmp_2024-06-01T2200Z.csv.gz
+func Timestamped(path string, t time.Time) string {
+	ext := splitExt(path)
+	return fmt.Sprintf("%s_%s%s", strings.TrimSuffix(path, ext), t.UTC().Format(TimestampFormat), ext)
+}
+
+// Latest points the stable path at target, which must live in the same
+// directory. Checksum and signature sidecars of target are linked as well.
+// Where symlinks are not supported a pointer file is written instead.
+func Latest(stable, target string) error {
+	if filepath.Dir(stable) != filepath.Dir(target) {
+		return fmt.Errorf("latest link %s and target %s must share a directory", stable, target)
+	}
+
+	err := link(stable, target)
+	if err != nil {
+		pointer := stable + PointerSuffix
+		if werr := os.WriteFile(pointer, []byte(filepath.Base(target)+"\n"), 0644); werr != nil {
+			return fmt.Errorf("failed to link %s (%v) or write pointer %s: %w", stable, err, pointer, werr)
+		}
+		return nil
+	}
+	os.Remove(stable + PointerSuffix)
+
+	for _, suffix := range []string{integrity.ChecksumSuffix, integrity.SignatureSuffix} {
+		if _, err := os.Stat(target + suffix); err != nil {
+			continue
+		}
+		if err := link(stable+suffix, target+suffix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// link atomically replaces stable with a relative symlink to target
+func link(stable, target string) error {
+	tmp := stable + ".tmp-link"
+	os.Remove(tmp)
+	if err := os.Symlink(filepath.Base(target), tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, stable); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace %s: %w", stable, err)
+	}
+	return nil
+}
+
+// Detach removes a latest link or pointer at the stable path so that writing
+// the file in place does not overwrite an earlier timestamped run through the link
+func Detach(stable string) {
+	for _, path := range []string{stable, stable + integrity.ChecksumSuffix, stable + integrity.SignatureSuffix} {
+		if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			os.Remove(path)
+		}
+	}
+	os.Remove(stable + PointerSuffix)
+}
+
+// Resolve returns the file the stable path currently refers to, following a
+// pointer file if one exists
+func Resolve(stable string) string {
+	raw, err := os.ReadFile(stable + PointerSuffix)
+	if err != nil {
+		return stable
+	}
+	name := strings.TrimSpace(string(raw))
+	if name == "" {
+		return stable
+	}
+	return filepath.Join(filepath.Dir(stable), name)
+}
@@ -0,0 +1,64 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"algotradar/integrity"
+)
+
+// Prune deletes the oldest dated runs of the output at stable so that the
+// newest keep remain, with their checksum and signature sidecars. Dated runs
+// are the files next to stable named like it with a date, or a timestamp
+// from Timestamped, before the extension, e.g. global_stocks_fmp_2024-06-01.json
+// or global_stocks_fmp_2024-06-01T2200Z.json.gz for global_stocks_fmp.json.gz.
+// It returns the runs deleted.
+func Prune(stable string, keep int) ([]string, error) {
+	if keep < 1 {
+		return nil, nil
+	}
+	ext := splitExt(stable)
+	base := filepath.Base(stable[:len(stable)-len(ext)])
+	pattern := regexp.MustCompile(`^` + regexp.QuoteMeta(base) + `_(\d{4}-\d{2}-\d{2}(?:T\d{4}Z)?)` + regexp.QuoteMeta(ext) + `$`)
+
+	entries, err := os.ReadDir(filepath.Dir(stable))
+	if err != nil {
+		return nil, err
+	}
+	type run struct{ stamp, path string }
+	var runs []run
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if match := pattern.FindStringSubmatch(entry.Name()); match != nil {
+			runs = append(runs, run{match[1], filepath.Join(filepath.Dir(stable), entry.Name())})
+		}
+	}
+	if len(runs) <= keep {
+		return nil, nil
+	}
+	// A date alone sorts before the timestamped runs of the same day
+	sort.Slice(runs, func(i, j int) bool { return runs[i].stamp < runs[j].stamp })
+
+	var deleted []string
+	for _, r := range runs[:len(runs)-keep] {
+		if err := os.Remove(r.path); err != nil {
+			return deleted, err
+		}
+		for _, suffix := range []string{integrity.ChecksumSuffix, integrity.SignatureSuffix} {
+			os.Remove(r.path + suffix)
+		}
+		deleted = append(deleted, r.path)
+	}
+	return deleted, nil
+}
+
+// splitExt returns the extension of path including any compression
+// extension, e.g. .json.gz
+func splitExt(path string) string {
+	compression := CompressionExt(path)
+	return filepath.Ext(path[:len(path)-len(compression)]) + compression
+}
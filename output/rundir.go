@@ -0,0 +1,201 @@
+package output
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"algotradar/domain"
+)
+
+// LatestRun is the link in a runs root to the newest complete run directory
+const LatestRun = "latest"
+
+// ManifestFile is the name of the manifest in each run directory
+const ManifestFile = "manifest.json"
+
+// runDirPattern matches the names of run directories
+var runDirPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{4}Z$`)
+
+// Manifest describes a run directory well enough to reproduce the run and
+// check what it wrote: the code and settings that produced it, the provider
+// it read and the rows and files it wrote
+type Manifest struct {
+	RunID      string    `json:"run_id"`
+	Collector  string    `json:"collector"`
+	Provider   string    `json:"provider"`
+	GitCommit  string    `json:"git_commit,omitempty"`
+	ConfigHash string    `json:"config_hash"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+
+	Rows          int            `json:"rows"`
+	RowsByCountry map[string]int `json:"rows_by_country,omitempty"`
+	Partial       bool           `json:"partial"`
+
+	// Files are the run's outputs, with paths relative to the run directory
+	Files []domain.OutputFile `json:"files"`
+
+	// Responses is where the run recorded its API responses with --record,
+	// and ReplayOf the recording it replayed with --replay
+	Responses string `json:"responses,omitempty"`
+	ReplayOf  string `json:"replay_of,omitempty"`
+}
+
+// ManifestFor describes a run from its report: the collector, timing, row
+// counts and outputs, with the build's commit and the hash of flags
+func ManifestFor(run domain.Run, provider string, rows int, flags *flag.FlagSet) Manifest {
+	return Manifest{
+		Collector:     run.Collector,
+		Provider:      provider,
+		GitCommit:     GitCommit(),
+		ConfigHash:    ConfigHash(flags),
+		StartedAt:     run.StartedAt,
+		FinishedAt:    run.FinishedAt,
+		Rows:          rows,
+		RowsByCountry: run.SymbolsPerCountry,
+		Partial:       run.Partial,
+		Files:         run.Outputs,
+	}
+}
+
+// RunDir is the directory of one run under a runs root, named by its UTC
+// start time in TimestampFormat, e.g. runs/2024-06-01T2200Z. Outputs keep
+// their plain names inside it, and a run never writes into another run's
+// directory, so a published run stays as it was.
+type RunDir struct {
+	Root string
+	ID   string
+}
+
+// CreateRunDir makes the directory of a run started at t under root. It
+// fails when the directory exists, which a second run started in the same
+// minute would otherwise overwrite.
+func CreateRunDir(root string, t time.Time) (RunDir, error) {
+	r := RunDir{Root: root, ID: t.UTC().Format(TimestampFormat)}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return r, fmt.Errorf("failed to create runs directory: %w", err)
+	}
+	if err := os.Mkdir(r.Dir(), 0755); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return r, fmt.Errorf("run directory %s already exists", r.Dir())
+		}
+		return r, fmt.Errorf("failed to create run directory: %w", err)
+	}
+	return r, nil
+}
+
+// Dir returns the run's directory
+func (r RunDir) Dir() string {
+	return filepath.Join(r.Root, r.ID)
+}
+
+// Path returns where the run writes an output configured as name
+func (r RunDir) Path(name string) string {
+	return filepath.Join(r.Dir(), filepath.Base(name))
+}
+
+// Finish writes the manifest and points the root's latest link at the run.
+// Only complete runs are finished, so latest never names a failed one.
+func (r RunDir) Finish(m Manifest) error {
+	m.RunID = r.ID
+	files := make([]domain.OutputFile, 0, len(m.Files))
+	for _, f := range m.Files {
+		if rel, err := filepath.Rel(r.Dir(), f.Path); err == nil && !strings.HasPrefix(rel, "..") {
+			f.Path = filepath.ToSlash(rel)
+		}
+		files = append(files, f)
+	}
+	m.Files = files
+	if rel, err := filepath.Rel(r.Dir(), m.Responses); m.Responses != "" && err == nil && !strings.HasPrefix(rel, "..") {
+		m.Responses = filepath.ToSlash(rel)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(r.Dir(), ManifestFile), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write run manifest: %w", err)
+	}
+	return Latest(filepath.Join(r.Root, LatestRun), r.Dir())
+}
+
+// PruneRunDirs deletes the oldest run directories under root so that the
+// newest keep remain, and returns those deleted
+func PruneRunDirs(root string, keep int) ([]string, error) {
+	if keep < 1 {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	var runs []string
+	for _, entry := range entries {
+		if entry.IsDir() && runDirPattern.MatchString(entry.Name()) {
+			runs = append(runs, entry.Name())
+		}
+	}
+	if len(runs) <= keep {
+		return nil, nil
+	}
+	sort.Strings(runs)
+
+	var deleted []string
+	for _, name := range runs[:len(runs)-keep] {
+		path := filepath.Join(root, name)
+		if err := os.RemoveAll(path); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, path)
+	}
+	return deleted, nil
+}
+
+// ConfigHash fingerprints the effective configuration of a run: every flag
+// of flags with the value it had, defaults included. Only the hash is
+// recorded, as flag values can hold webhook URLs and other secrets.
+func ConfigHash(flags *flag.FlagSet) string {
+	var lines []string
+	flags.VisitAll(func(f *flag.Flag) {
+		lines = append(lines, f.Name+"="+f.Value.String())
+	})
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// GitCommit returns the commit the binary was built from, marked -dirty
+// when the tree had changes, or the GIT_COMMIT environment variable for
+// builds without version control stamps such as go run
+func GitCommit() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		var revision, modified string
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				revision = s.Value
+			case "vcs.modified":
+				modified = s.Value
+			}
+		}
+		if revision != "" {
+			if modified == "true" {
+				revision += "-dirty"
+			}
+			return revision
+		}
+	}
+	return os.Getenv("GIT_COMMIT")
+}
@@ -0,0 +1,81 @@
+package progress
+
+import "net/http"
+
+// dashboardPage polls /status and renders budget, stage progress, error rate and ETA
+const dashboardPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Collection progress</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em; max-width: 48em; color: #222; }
+h1 { font-size: 1.3em; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1.5em; }
+td, th { text-align: left; padding: 0.3em 0.6em; border-bottom: 1px solid #ddd; }
+.bar { background: #eee; height: 0.8em; width: 100%; }
+.fill { background: #3a7; height: 100%; }
+.warn { color: #b50; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>Collection progress <span id="state"></span></h1>
+<table id="summary"></table>
+<table>
+<thead><tr><th>Stage</th><th>Progress</th><th>Done</th><th>ETA</th></tr></thead>
+<tbody id="stages"></tbody>
+</table>
+<script>
+function dur(s) {
+  if (!s) return "-";
+  s = Math.round(s);
+  var h = Math.floor(s / 3600), m = Math.floor(s % 3600 / 60);
+  return (h ? h + "h " : "") + (h || m ? m + "m " : "") + s % 60 + "s";
+}
+function row(k, v, warn) {
+  return "<tr><th>" + k + "</th><td" + (warn ? ' class="warn"' : "") + ">" + v + "</td></tr>";
+}
+function render(s) {
+  document.getElementById("state").textContent = s.finished ? "(finished)" : "(running)";
+  var html = row("Elapsed", dur(s.elapsed_seconds)) + row("API calls", Math.round(s.calls));
+  if (s.budget) {
+    var over = s.projected_calls > s.budget;
+    html += row("Remaining budget", Math.round(s.remaining) + " of " + s.budget, s.remaining < s.budget * 0.1);
+    if (s.projected_calls) html += row("Projected calls", Math.round(s.projected_calls) + (over ? " (exceeds budget)" : ""), over);
+  } else if (s.projected_calls) {
+    html += row("Projected calls", Math.round(s.projected_calls));
+  }
+  html += row("Calls / minute", s.calls_per_minute.toFixed(1));
+  html += row("Error rate", (s.error_rate * 100).toFixed(1) + "%", s.error_rate > 0.05);
+  html += row("ETA (current stage)", dur(s.eta_seconds));
+  if (s.last_error) html += row("Last error", s.last_error.replace(/</g, "&lt;"));
+  document.getElementById("summary").innerHTML = html;
+
+  var stages = "";
+  (s.stages || []).forEach(function (st) {
+    var pct = st.total ? Math.min(100, 100 * st.done / st.total) : 0;
+    stages += "<tr><td>" + st.name + "</td><td><div class=\"bar\"><div class=\"fill\" style=\"width:" + pct + "%\"></div></div></td>" +
+      "<td>" + st.done + " / " + st.total + "</td><td>" + dur(st.eta_seconds) + "</td></tr>";
+  });
+  document.getElementById("stages").innerHTML = stages;
+}
+function poll() {
+  fetch("status").then(function (r) { return r.json(); }).then(render).catch(function () {
+    document.getElementById("state").textContent = "(disconnected)";
+  });
+}
+poll();
+setInterval(poll, 2000);
+</script>
+</body>
+</html>
+`
+
+func dashboardHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(dashboardPage))
+}
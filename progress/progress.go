@@ -0,0 +1,179 @@
+// Package progress publishes collection progress events, streams them over
+// Server-Sent Events and serves a live dashboard of quota, progress and ETA.
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Event types published by the collectors
+const (
+	RunStarted       = "run_started"
+	StageStarted     = "stage_started"
+	CountryCompleted = "country_completed"
+	SymbolsProcessed = "symbols_processed"
+	CountryProgress  = "country_progress"
+	Error            = "error"
+	RunFinished      = "run_finished"
+)
+
+// DefaultHistory is how many past events a new subscriber is replayed
+const DefaultHistory = 500
+
+// heartbeatInterval keeps idle SSE connections open through proxies
+const heartbeatInterval = 15 * time.Second
+
+// Event is one progress update. Done and Total describe progress through the
+// current stage; Count is the number of items the event itself refers to.
+type Event struct {
+	ID      int64     `json:"id"`
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	Stage   string    `json:"stage,omitempty"`
+	Country string    `json:"country,omitempty"`
+	Count   int       `json:"count,omitempty"`
+	Done    int       `json:"done,omitempty"`
+	Total   int       `json:"total,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+// Broker fans events out to subscribers and keeps a short history for late joiners
+type Broker struct {
+	mu      sync.Mutex
+	nextID  int64
+	history []Event
+	limit   int
+	subs    map[chan Event]struct{}
+}
+
+// Default is the broker the collectors publish to
+var Default = NewBroker(DefaultHistory)
+
+// NewBroker returns a broker that remembers the last history events
+func NewBroker(history int) *Broker {
+	return &Broker{limit: history, subs: make(map[chan Event]struct{})}
+}
+
+// Publish stamps e and delivers it to every subscriber. Slow subscribers miss
+// events rather than blocking the collector.
+func (b *Broker) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	e.ID = b.nextID
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	b.history = append(b.history, e)
+	if len(b.history) > b.limit {
+		b.history = b.history[len(b.history)-b.limit:]
+	}
+
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe returns the retained events after afterID and a channel of new
+// ones; call cancel to unsubscribe
+func (b *Broker) Subscribe(afterID int64) (past []Event, events <-chan Event, cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, e := range b.history {
+		if e.ID > afterID {
+			past = append(past, e)
+		}
+	}
+
+	ch := make(chan Event, 64)
+	b.subs[ch] = struct{}{}
+	return past, ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs, ch)
+	}
+}
+
+// History returns the retained events, oldest first
+func (b *Broker) History() []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Event(nil), b.history...)
+}
+
+// Handler streams events as text/event-stream. Reconnecting clients that send
+// Last-Event-ID only receive what they missed.
+func (b *Broker) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		lastID, _ := strconv.ParseInt(r.Header.Get("Last-Event-ID"), 10, 64)
+		past, events, cancel := b.Subscribe(lastID)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+
+		for _, e := range past {
+			writeEvent(w, e)
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case e := <-events:
+				writeEvent(w, e)
+				flusher.Flush()
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+				flusher.Flush()
+			}
+		}
+	})
+}
+
+func writeEvent(w http.ResponseWriter, e Event) {
+	data, _ := json.Marshal(e)
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+}
+
+// Publish sends an event to the Default broker
+func Publish(e Event) {
+	Default.Publish(e)
+}
+
+// Serve exposes the Default broker on addr in the background: a live dashboard
+// at /, its JSON summary at /status and the raw event stream at /events.
+// budget is the API request allowance shown on the dashboard (0 = unknown).
+func Serve(addr string, budget int) <-chan error {
+	errs := make(chan error, 1)
+	tracker := NewTracker(Default, budget)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", dashboardHandler)
+	mux.Handle("/status", tracker.Handler())
+	mux.Handle("/events", Default.Handler())
+	go func() {
+		errs <- http.ListenAndServe(addr, mux)
+	}()
+	return errs
+}
@@ -0,0 +1,305 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// throughputWindow is the span the rolling rate behind the ETA is measured over
+	throughputWindow = 30 * time.Second
+	// maxCountryBars bounds the per-country lines drawn below the overall bar
+	maxCountryBars = 8
+	barWidth       = 24
+
+	ttyRefresh   = 250 * time.Millisecond
+	plainRefresh = 10 * time.Second
+)
+
+// countryBar is progress through one country's symbols
+type countryBar struct {
+	name        string
+	done, total int
+}
+
+// doneSample is a stage's completed count at one point in time
+type doneSample struct {
+	at   time.Time
+	done int
+}
+
+// Terminal draws a broker's events as progress bars: the current stage with
+// its rolling throughput and ETA, plus one bar per country still in progress.
+// On a terminal the bars are redrawn in place and log lines written through
+// Terminal appear above them; otherwise a plain status line is printed every
+// few seconds so CI logs stay readable.
+type Terminal struct {
+	out io.Writer
+	tty bool
+
+	mu        sync.Mutex
+	stage     string
+	done      int
+	total     int
+	started   time.Time
+	finished  time.Time
+	samples   []doneSample
+	countries map[string]*countryBar
+	lines     int // lines drawn by the last frame, cleared before the next
+	lastPlain string
+
+	cancel   func()
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewTerminal starts drawing b's events to out until Stop is called
+func NewTerminal(out *os.File, b *Broker) *Terminal {
+	t := &Terminal{
+		out:       out,
+		tty:       isTerminal(out),
+		countries: make(map[string]*countryBar),
+		stop:      make(chan struct{}),
+	}
+	past, events, cancel := b.Subscribe(0)
+	t.cancel = cancel
+	for _, e := range past {
+		t.apply(e)
+	}
+
+	refresh := plainRefresh
+	if t.tty {
+		refresh = ttyRefresh
+	}
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(refresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case e := <-events:
+				t.apply(e)
+			case <-ticker.C:
+				t.draw()
+			case <-t.stop:
+				for {
+					select {
+					case e := <-events:
+						t.apply(e)
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+	return t
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0 && os.Getenv("TERM") != "dumb"
+}
+
+// Write passes log output through, keeping the bars below it on a terminal
+func (t *Terminal) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.tty {
+		return t.out.Write(p)
+	}
+	t.clear()
+	n, err := t.out.Write(p)
+	t.render()
+	return n, err
+}
+
+// Stop draws the final state and stops following the broker. It is safe to
+// call more than once and on a nil Terminal.
+func (t *Terminal) Stop() {
+	if t == nil {
+		return
+	}
+	stopped := false
+	t.stopOnce.Do(func() {
+		close(t.stop)
+		t.wg.Wait()
+		t.cancel()
+		stopped = true
+	})
+	if !stopped {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tty {
+		t.clear()
+		t.render()
+		t.lines = 0 // leave the last frame on screen
+	} else {
+		t.printPlain()
+	}
+}
+
+func (t *Terminal) apply(e Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch e.Type {
+	case StageStarted:
+		t.stage, t.done, t.total = e.Stage, 0, e.Total
+		t.started, t.finished = e.Time, time.Time{}
+		t.samples = []doneSample{{at: e.Time}}
+		t.countries = make(map[string]*countryBar)
+	case CountryCompleted, SymbolsProcessed:
+		if e.Stage != t.stage {
+			return
+		}
+		t.done = e.Done
+		if e.Total > 0 {
+			t.total = e.Total
+		}
+		if t.total > 0 && t.done >= t.total && t.finished.IsZero() {
+			t.finished = e.Time
+		}
+		t.samples = append(t.samples, doneSample{at: e.Time, done: e.Done})
+		for len(t.samples) > 2 && e.Time.Sub(t.samples[1].at) >= throughputWindow {
+			t.samples = t.samples[1:]
+		}
+	case CountryProgress:
+		if e.Stage != t.stage || e.Country == "" {
+			return
+		}
+		bar := t.countries[e.Country]
+		if bar == nil {
+			bar = &countryBar{name: e.Country}
+			t.countries[e.Country] = bar
+		}
+		bar.done = e.Done
+		if e.Total > 0 {
+			bar.total = e.Total
+		}
+	}
+}
+
+// rate returns items per second over the rolling window
+func (t *Terminal) rate(now time.Time) float64 {
+	if len(t.samples) == 0 {
+		return 0
+	}
+	oldest := t.samples[0]
+	elapsed := now.Sub(oldest.at).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(t.done-oldest.done) / elapsed
+}
+
+func (t *Terminal) draw() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tty {
+		t.clear()
+		t.render()
+	} else {
+		t.printPlain()
+	}
+}
+
+// clear erases the last frame; callers hold mu
+func (t *Terminal) clear() {
+	if t.lines > 0 {
+		fmt.Fprintf(t.out, "\x1b[%dA\r\x1b[J", t.lines)
+		t.lines = 0
+	}
+}
+
+// render draws a frame below the cursor; callers hold mu
+func (t *Terminal) render() {
+	if t.stage == "" {
+		return
+	}
+	frame := []string{t.summary(time.Now(), true)}
+
+	var active []*countryBar
+	finished := 0
+	for _, bar := range t.countries {
+		if bar.total > 0 && bar.done >= bar.total {
+			finished++
+		} else {
+			active = append(active, bar)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool {
+		ri, rj := active[i].total-active[i].done, active[j].total-active[j].done
+		if ri != rj {
+			return ri > rj
+		}
+		return active[i].name < active[j].name
+	})
+	for i, bar := range active {
+		if i == maxCountryBars {
+			frame = append(frame, fmt.Sprintf("  … %d more countries", len(active)-maxCountryBars))
+			break
+		}
+		frame = append(frame, fmt.Sprintf("  %-4s %s %5d/%-5d", bar.name, drawBar(bar.done, bar.total, barWidth/2), bar.done, bar.total))
+	}
+	if finished > 0 {
+		frame = append(frame, fmt.Sprintf("  %d countries done", finished))
+	}
+
+	fmt.Fprint(t.out, strings.Join(frame, "\n")+"\n")
+	t.lines = len(frame)
+}
+
+// printPlain prints the summary line if it changed; callers hold mu
+func (t *Terminal) printPlain() {
+	if t.stage == "" {
+		return
+	}
+	line := t.summary(time.Now(), false)
+	if line != t.lastPlain {
+		fmt.Fprintln(t.out, line)
+		t.lastPlain = line
+	}
+}
+
+// summary is the overall line: stage, bar, counts, rate and ETA
+func (t *Terminal) summary(now time.Time, bar bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-9s", t.stage)
+	if bar {
+		b.WriteString(" " + drawBar(t.done, t.total, barWidth))
+	}
+	fmt.Fprintf(&b, " %d/%d", t.done, t.total)
+	if t.total > 0 {
+		fmt.Fprintf(&b, " %5.1f%%", float64(t.done)/float64(t.total)*100)
+	}
+	if rate := t.rate(now); rate > 0 && t.finished.IsZero() {
+		fmt.Fprintf(&b, "  %.1f/s", rate)
+		if remaining := t.total - t.done; remaining > 0 {
+			eta := time.Duration(float64(remaining) / rate * float64(time.Second))
+			fmt.Fprintf(&b, "  ETA %s", eta.Round(time.Second))
+		}
+	}
+	if !t.finished.IsZero() {
+		fmt.Fprintf(&b, "  done in %s", t.finished.Sub(t.started).Round(time.Second))
+	}
+	return b.String()
+}
+
+func drawBar(done, total, width int) string {
+	filled := 0
+	if total > 0 {
+		filled = min(done*width/total, width)
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", width-filled) + "]"
+}
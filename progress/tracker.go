@@ -0,0 +1,192 @@
+package progress
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"algotradar/metrics"
+)
+
+// errorRateWindow is the span over which the current error rate is measured
+const errorRateWindow = time.Minute
+
+// StageStatus is progress through one stage of the run
+type StageStatus struct {
+	Name       string    `json:"name"`
+	Done       int       `json:"done"`
+	Total      int       `json:"total"`
+	StartedAt  time.Time `json:"started_at"`
+	ETASeconds float64   `json:"eta_seconds,omitempty"`
+
+	callsAtStart float64
+}
+
+// Status is the dashboard's view of a run
+type Status struct {
+	StartedAt time.Time     `json:"started_at,omitempty"`
+	Elapsed   float64       `json:"elapsed_seconds"`
+	Finished  bool          `json:"finished"`
+	Stages    []StageStatus `json:"stages"`
+
+	Budget         int     `json:"budget,omitempty"`
+	Calls          float64 `json:"calls"`
+	Remaining      float64 `json:"remaining"`
+	ProjectedCalls float64 `json:"projected_calls,omitempty"`
+	CallsPerMinute float64 `json:"calls_per_minute"`
+	ErrorRate      float64 `json:"error_rate"`
+	ETASeconds     float64 `json:"eta_seconds,omitempty"`
+	LastError      string  `json:"last_error,omitempty"`
+}
+
+// callSample is the API call and error totals at one point in time
+type callSample struct {
+	at            time.Time
+	calls, errors float64
+}
+
+// Tracker follows a broker's events and the API call metrics to summarize a run
+type Tracker struct {
+	// Budget is the number of API requests the run may spend (0 = unknown)
+	Budget int
+	// Calls reports total and failed API requests; defaults to metrics.APICalls
+	Calls func() (total, failed float64)
+
+	mu        sync.Mutex
+	startedAt time.Time
+	finished  bool
+	stages    []*StageStatus
+	lastError string
+	samples   []callSample
+}
+
+// NewTracker returns a tracker that consumes events from b until the process exits
+func NewTracker(b *Broker, budget int) *Tracker {
+	t := &Tracker{Budget: budget, Calls: apiCalls}
+	past, events, _ := b.Subscribe(0)
+	for _, e := range past {
+		t.apply(e)
+	}
+	go func() {
+		for e := range events {
+			t.apply(e)
+		}
+	}()
+	return t
+}
+
+// apiCalls counts collector requests from the metrics registry; any status
+// other than 200 counts as failed
+func apiCalls() (total, failed float64) {
+	for _, s := range metrics.APICalls.Samples() {
+		total += s.Value
+		if len(s.Labels) > 1 && s.Labels[1] != "200" {
+			failed += s.Value
+		}
+	}
+	return total, failed
+}
+
+func (t *Tracker) apply(e Event) {
+	calls, _ := t.Calls()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch e.Type {
+	case RunStarted:
+		t.startedAt = e.Time
+	case StageStarted:
+		t.stages = append(t.stages, &StageStatus{Name: e.Stage, Total: e.Total, StartedAt: e.Time, callsAtStart: calls})
+	case CountryCompleted, SymbolsProcessed:
+		if stage := t.stage(e.Stage); stage != nil {
+			stage.Done = e.Done
+			if e.Total > 0 {
+				stage.Total = e.Total
+			}
+		}
+	case Error:
+		t.lastError = e.Message
+	case RunFinished:
+		t.finished = true
+		if e.Message != "" {
+			t.lastError = e.Message
+		}
+	}
+}
+
+func (t *Tracker) stage(name string) *StageStatus {
+	for i := len(t.stages) - 1; i >= 0; i-- {
+		if t.stages[i].Name == name {
+			return t.stages[i]
+		}
+	}
+	return nil
+}
+
+// Status summarizes the run as of now
+func (t *Tracker) Status() Status {
+	calls, failed := t.Calls()
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Keep a minute of samples to measure the current rates
+	t.samples = append(t.samples, callSample{at: now, calls: calls, errors: failed})
+	for len(t.samples) > 1 && now.Sub(t.samples[1].at) >= errorRateWindow {
+		t.samples = t.samples[1:]
+	}
+	oldest := t.samples[0]
+
+	s := Status{
+		StartedAt: t.startedAt,
+		Finished:  t.finished,
+		Budget:    t.Budget,
+		Calls:     calls,
+		LastError: t.lastError,
+	}
+	if !t.startedAt.IsZero() {
+		s.Elapsed = now.Sub(t.startedAt).Seconds()
+	}
+	if window := now.Sub(oldest.at).Minutes(); window > 0 {
+		s.CallsPerMinute = (calls - oldest.calls) / window
+	}
+	if recent := calls - oldest.calls; recent > 0 {
+		s.ErrorRate = (failed - oldest.errors) / recent
+	} else if calls > 0 {
+		s.ErrorRate = failed / calls
+	}
+
+	for _, stage := range t.stages {
+		status := *stage
+		if !t.finished && status.Done > 0 && status.Done < status.Total {
+			perItem := now.Sub(status.StartedAt).Seconds() / float64(status.Done)
+			status.ETASeconds = perItem * float64(status.Total-status.Done)
+		}
+		s.Stages = append(s.Stages, status)
+	}
+
+	// The current stage's pace drives the ETA and the projected request count
+	if n := len(t.stages); n > 0 && !t.finished {
+		current := t.stages[n-1]
+		s.ETASeconds = s.Stages[n-1].ETASeconds
+		if current.Done > 0 && current.Total > current.Done {
+			perItem := (calls - current.callsAtStart) / float64(current.Done)
+			s.ProjectedCalls = calls + perItem*float64(current.Total-current.Done)
+		}
+	}
+	if t.Budget > 0 {
+		s.Remaining = max(float64(t.Budget)-calls, 0)
+	}
+	return s
+}
+
+// Handler serves Status as JSON
+func (t *Tracker) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(t.Status())
+	})
+}
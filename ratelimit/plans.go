@@ -0,0 +1,43 @@
+package ratelimit
+
+import "time"
+
+// Plan is an FMP subscription tier and the request allowance it grants
+type Plan struct {
+	Name      string
+	PerMinute int // 0 means no per-minute limit
+	PerDay    int // 0 means no daily cap
+}
+
+// Plans are FMP's tiers, cheapest first
+var Plans = []Plan{
+	{Name: "Basic", PerDay: 250},
+	{Name: "Starter", PerMinute: 300},
+	{Name: "Premium", PerMinute: 750},
+	{Name: "Ultimate", PerMinute: 3000},
+}
+
+// Fits reports whether a run of calls requests stays within the plan's daily cap
+func (p Plan) Fits(calls int) bool {
+	return p.PerDay == 0 || calls <= p.PerDay
+}
+
+// Duration is how long calls requests take when paced at the plan's
+// per-minute limit; zero when the plan has none
+func (p Plan) Duration(calls int) time.Duration {
+	if p.PerMinute == 0 {
+		return 0
+	}
+	return time.Duration(float64(calls) / float64(p.PerMinute) * float64(time.Minute))
+}
+
+// RequiredPlan returns the cheapest plan whose daily cap allows calls
+// requests, and false if none does
+func RequiredPlan(calls int) (Plan, bool) {
+	for _, plan := range Plans {
+		if plan.Fits(calls) {
+			return plan, true
+		}
+	}
+	return Plan{}, false
+}
@@ -0,0 +1,89 @@
+// Package ratelimit interprets HTTP 429 responses from the FMP API: how long a
+// per-minute limit asks the client to wait, and whether the daily quota is gone
+// for good, in which case retrying only burns time.
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxRetries is how often a request is retried after per-minute 429s
+	MaxRetries = 5
+	// MaxWait is the longest Retry-After still treated as a per-minute limit;
+	// longer waits mean the daily quota is exhausted
+	MaxWait = 2 * time.Minute
+)
+
+var (
+	// ErrRateLimited marks requests still refused with 429 after MaxRetries
+	ErrRateLimited = errors.New("rate limited")
+	// ErrDailyLimit marks requests refused because the daily quota is exhausted
+	ErrDailyLimit = errors.New("daily API limit exhausted")
+)
+
+// dailyPhrases appear in FMP 429 bodies for daily, not per-minute, limits
+var dailyPhrases = []string{"daily", "per day"}
+
+// Limit describes one 429 response
+type Limit struct {
+	// Daily is set when the quota will not recover within MaxWait
+	Daily bool
+	// RetryAfter is the server's requested delay, zero when it sent none
+	RetryAfter time.Duration
+}
+
+// FromResponse classifies a 429 response from its headers and body
+func FromResponse(header http.Header, body []byte, now time.Time) Limit {
+	var limit Limit
+	if wait, ok := ParseRetryAfter(header.Get("Retry-After"), now); ok {
+		limit.RetryAfter = wait
+		limit.Daily = wait > MaxWait
+	}
+
+	text := strings.ToLower(string(body))
+	for _, phrase := range dailyPhrases {
+		if strings.Contains(text, phrase) {
+			limit.Daily = true
+		}
+	}
+	return limit
+}
+
+// ParseRetryAfter reads a Retry-After value given either as seconds or as an HTTP date
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// Wait returns how long to sleep before retry number attempt (starting at 0):
+// the server's Retry-After when given, otherwise exponential backoff from one
+// second capped at MaxWait
+func (l Limit) Wait(attempt int) time.Duration {
+	if l.RetryAfter > 0 {
+		return l.RetryAfter
+	}
+	return min(time.Second<<min(attempt, 7), MaxWait)
+}
+
+// Error returns the error for a request that was refused with this limit
+func (l Limit) Error(retries int) error {
+	if l.Daily {
+		return ErrDailyLimit
+	}
+	return fmt.Errorf("%w after %d retries", ErrRateLimited, retries)
+}
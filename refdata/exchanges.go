@@ -0,0 +1,284 @@
+// Package refdata holds static reference data about the exchanges the collectors cover.
+package refdata
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // session checks must not depend on the host's zoneinfo
+
+	"algotradar/domain"
+)
+
+// Exchange describes a trading venue as reported in FMP's exchangeShortName
+type Exchange struct {
+	Code     string `json:"code"` // FMP exchange short name
+	Name     string `json:"name"`
+	Country  string `json:"country"`  // ISO country code
+	Currency string `json:"currency"` // ISO currency of quoted prices
+	MIC      string `json:"mic"`      // ISO 10383 market identifier code
+
+	// PriceDivisor is how many quoted sub-units make one currency unit, e.g.
+	// 100 where prices are in pence, cents or agorot; 0 means major units
+	PriceDivisor float64 `json:"price_divisor,omitempty"`
+
+	// Suffixes are the FMP symbol suffixes of the venue's listings, e.g. ".L"
+	Suffixes []string `json:"suffixes,omitempty"`
+
+	// Regular session in local time, as HH:MM in the IANA Timezone
+	Timezone string `json:"timezone,omitempty"`
+	Open     string `json:"open,omitempty"`
+	Close    string `json:"close,omitempty"`
+
+	// TradingDays are the weekdays the session runs, as three-letter lower
+	// case names; empty means Monday to Friday
+	TradingDays []string `json:"trading_days,omitempty"`
+}
+
+// Divisor returns PriceDivisor, or 1 for venues quoting in major units
+func (e Exchange) Divisor() float64 {
+	if e.PriceDivisor <= 0 {
+		return 1
+	}
+	return e.PriceDivisor
+}
+
+// MajorUnits converts a quoted price or market cap into currency units
+func (e Exchange) MajorUnits(quoted float64) float64 {
+	return quoted / e.Divisor()
+}
+
+// Sessions place a moment in a venue's trading day
+const (
+	SessionRegular    = "regular"     // during the regular session
+	SessionPreMarket  = "pre_market"  // a trading day, before the open
+	SessionAfterHours = "after_hours" // a trading day, after the close
+	SessionClosed     = "closed"      // a day the venue does not trade
+)
+
+// Session returns where t falls in the venue's trading day. ok is false when
+// the venue's timezone or hours are unknown. Holidays and midday breaks are
+// not modelled, so a holiday counts as a trading day and a break as regular
+// session.
+func (e Exchange) Session(t time.Time) (session string, ok bool) {
+	if e.Timezone == "" || e.Open == "" || e.Close == "" {
+		return "", false
+	}
+	loc, err := time.LoadLocation(e.Timezone)
+	if err != nil {
+		return "", false
+	}
+	from, err1 := minuteOfDay(e.Open)
+	to, err2 := minuteOfDay(e.Close)
+	if err1 != nil || err2 != nil {
+		return "", false
+	}
+
+	local := t.In(loc)
+	day := strings.ToLower(local.Weekday().String()[:3])
+	if len(e.TradingDays) == 0 {
+		if local.Weekday() == time.Saturday || local.Weekday() == time.Sunday {
+			return SessionClosed, true
+		}
+	} else if !containsFold(e.TradingDays, day) {
+		return SessionClosed, true
+	}
+	switch now := local.Hour()*60 + local.Minute(); {
+	case now < from:
+		return SessionPreMarket, true
+	case now >= to:
+		return SessionAfterHours, true
+	default:
+		return SessionRegular, true
+	}
+}
+
+// IsOpen reports whether t falls in the venue's regular session; ok is false
+// when its hours are unknown
+func (e Exchange) IsOpen(t time.Time) (open, ok bool) {
+	session, ok := e.Session(t)
+	return session == SessionRegular, ok
+}
+
+// Enrich sets the asset's MIC, exchange timezone and whether its venue was
+// open at snapshot time, looking the venue up by ticker and primary exchange.
+// Assets on unknown venues are left as they are.
+func Enrich(asset *domain.Asset, at time.Time) {
+	venue, ok := ForListing(asset.Ticker, asset.PrimaryExchange)
+	if !ok {
+		return
+	}
+	asset.MIC = venue.MIC
+	asset.ExchangeTimezone = venue.Timezone
+	if open, ok := venue.IsOpen(at); ok {
+		asset.IsMarketOpen = &open
+	}
+}
+
+// LabelQuote records when the asset's quote was taken and in which session
+// of its venue. Quotes without a timestamp leave the asset as it is.
+func LabelQuote(asset *domain.Asset, quote *domain.Quote) {
+	if quote == nil || quote.Timestamp <= 0 {
+		return
+	}
+	taken := time.Unix(quote.Timestamp, 0).UTC()
+	asset.QuoteTime = &taken
+	if venue, ok := ForListing(asset.Ticker, asset.PrimaryExchange); ok {
+		asset.QuoteSession, _ = venue.Session(taken)
+	}
+}
+
+// Date sets the asset's snapshot date and collection time. The date is the
+// day at the asset's venue when its quote was taken, or when it was collected
+// if the quote has no timestamp, so an Asian listing collected during the US
+// evening is dated by its own market's day. Venues of unknown timezone are
+// dated in UTC.
+func Date(asset *domain.Asset, collected time.Time) {
+	at := collected
+	if asset.QuoteTime != nil {
+		at = *asset.QuoteTime
+	}
+	loc := time.UTC
+	if asset.ExchangeTimezone != "" {
+		if venueLoc, err := time.LoadLocation(asset.ExchangeTimezone); err == nil {
+			loc = venueLoc
+		}
+	}
+	asset.SnapshotDate = at.In(loc).Format(time.DateOnly)
+	collectedAt := collected.UTC()
+	asset.CollectedAt = &collectedAt
+}
+
+// minuteOfDay parses an HH:MM session time
+func minuteOfDay(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid session time %q: %w", clock, err)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(strings.TrimSpace(item), s) {
+			return true
+		}
+	}
+	return false
+}
+
+//go:embed exchanges.json
+var defaultExchanges []byte
+
+var (
+	mu        sync.RWMutex
+	exchanges map[string]Exchange
+	suffixes  []string // longest first
+	bySuffix  map[string]string
+)
+
+func init() {
+	var table []Exchange
+	if err := json.Unmarshal(defaultExchanges, &table); err != nil {
+		panic(fmt.Sprintf("refdata: invalid exchanges.json: %v", err))
+	}
+	exchanges = make(map[string]Exchange, len(table))
+	merge(table)
+}
+
+// LoadExchanges reads a JSON array of exchanges from path and merges it over
+// the bundled table: entries replace the venue with the same code and new
+// codes are added
+func LoadExchanges(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read exchange table: %w", err)
+	}
+	var table []Exchange
+	if err := json.Unmarshal(data, &table); err != nil {
+		return fmt.Errorf("failed to parse exchange table: %w", err)
+	}
+	for i, e := range table {
+		if strings.TrimSpace(e.Code) == "" {
+			return fmt.Errorf("exchange table entry %d has no code", i)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	merge(table)
+	return nil
+}
+
+// merge adds table to the exchanges and rebuilds the suffix index; callers
+// hold mu unless running from init
+func merge(table []Exchange) {
+	for _, e := range table {
+		e.Code = strings.ToUpper(strings.TrimSpace(e.Code))
+		exchanges[e.Code] = e
+	}
+	bySuffix = make(map[string]string)
+	suffixes = suffixes[:0]
+	for code, e := range exchanges {
+		for _, suffix := range e.Suffixes {
+			suffix = strings.ToUpper(suffix)
+			if _, dup := bySuffix[suffix]; !dup {
+				suffixes = append(suffixes, suffix)
+			}
+			bySuffix[suffix] = code
+		}
+	}
+	sort.Slice(suffixes, func(i, j int) bool {
+		if len(suffixes[i]) != len(suffixes[j]) {
+			return len(suffixes[i]) > len(suffixes[j])
+		}
+		return suffixes[i] < suffixes[j]
+	})
+}
+
+// LookupExchange returns reference data for an FMP exchange short name
+func LookupExchange(code string) (Exchange, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	exchange, ok := exchanges[strings.ToUpper(strings.TrimSpace(code))]
+	return exchange, ok
+}
+
+// ForMIC returns the venue with an ISO 10383 market identifier code; when
+// several codes share a MIC the first in alphabetical order wins
+func ForMIC(mic string) (Exchange, bool) {
+	mic = strings.ToUpper(strings.TrimSpace(mic))
+	if mic == "" {
+		return Exchange{}, false
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	var found Exchange
+	for _, e := range exchanges {
+		if e.MIC == mic && (found.Code == "" || e.Code < found.Code) {
+			found = e
+		}
+	}
+	return found, found.Code != ""
+}
+
+// ForListing returns the venue of a listing by its exchange short name,
+// falling back to the symbol suffix when the exchange is unknown or empty
+func ForListing(symbol, exchange string) (Exchange, bool) {
+	if e, ok := LookupExchange(exchange); ok {
+		return e, true
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	upper := strings.ToUpper(symbol)
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(upper, suffix) {
+			return exchanges[bySuffix[suffix]], true
+		}
+	}
+	return Exchange{}, false
+}
@@ -0,0 +1,82 @@
+package registry
+
+import (
+	"strings"
+	"unicode"
+)
+
+// mainBoards are the venues an issuer's primary listing normally trades on,
+// by FMP exchange short name
+var mainBoards = map[string]bool{
+	"NYSE": true, "NASDAQ": true, "TSX": true, "BMV": true, "SAO": true,
+	"LSE": true, "XETRA": true, "FRA": true, "EURONEXT": true, "AMS": true, "PAR": true, "BRU": true,
+	"SIX": true, "MIL": true, "BME": true, "STO": true, "CPH": true, "HEL": true, "OSL": true,
+	"HKSE": true, "SHH": true, "SHZ": true, "SSE": true, "SZSE": true, "JPX": true, "TSE": true,
+	"KSC": true, "TAI": true, "NSE": true, "BSE": true, "ASX": true, "SES": true, "SET": true,
+	"SAU": true, "JNB": true, "TLV": true,
+}
+
+// exchangeTier ranks a venue for primary-listing selection: 1 for main boards,
+// 3 for OTC and pink-sheet markets, 2 for anything else. Both FMP short names
+// ("NASDAQ") and full names ("NASDAQ Global Select") are understood.
+func exchangeTier(exchange string) int {
+	upper := strings.ToUpper(strings.TrimSpace(exchange))
+	switch {
+	case strings.Contains(upper, "OTC"), strings.Contains(upper, "PNK"),
+		strings.Contains(upper, "PINK"), strings.Contains(upper, "GREY"):
+		return 3
+	case mainBoards[upper],
+		strings.HasPrefix(upper, "NASDAQ"),
+		upper == "NEW YORK STOCK EXCHANGE":
+		return 1
+	}
+	return 2
+}
+
+// home reports whether l trades in the country its ISIN was issued in
+func (l Listing) home() bool {
+	isin := strings.ToUpper(strings.TrimSpace(l.IDs.ISIN))
+	return len(isin) >= 2 && l.Country != "" && isin[:2] == strings.ToUpper(l.Country)
+}
+
+// PreferPrimary picks an issuer's primary listing from FMP's ADR and
+// cross-listing data, in order: an ordinary share over a depositary receipt,
+// a listing in the ISIN's home country, a main board over secondary and OTC
+// venues, the larger market cap, then the shorter symbol.
+func PreferPrimary(a, b Listing) bool {
+	if a.ADR != b.ADR {
+		return !a.ADR
+	}
+	if ha, hb := a.home(), b.home(); ha != hb {
+		return ha
+	}
+	if ta, tb := exchangeTier(a.Exchange), exchangeTier(b.Exchange); ta != tb {
+		return ta < tb
+	}
+	if a.MarketCap != b.MarketCap {
+		return a.MarketCap > b.MarketCap
+	}
+	if len(a.Symbol) != len(b.Symbol) {
+		return len(a.Symbol) < len(b.Symbol)
+	}
+	return a.Symbol < b.Symbol
+}
+
+// IsLikelyADR guesses whether a listing is a depositary receipt when FMP's
+// profile does not say: the name mentions ADR/ADS or depositary shares, or it
+// is a five-letter US OTC symbol ending in Y, the suffix OTC markets reserve
+// for ADRs.
+func IsLikelyADR(symbol, name, exchange string) bool {
+	upper := strings.ToUpper(name)
+	for _, word := range strings.FieldsFunc(upper, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if word == "ADR" || word == "ADS" || word == "ADRS" {
+			return true
+		}
+	}
+	if strings.Contains(upper, "DEPOSITARY") || strings.Contains(upper, "DEPOSITORY") {
+		return true
+	}
+	return exchangeTier(exchange) == 3 && len(symbol) == 5 && strings.HasSuffix(strings.ToUpper(symbol), "Y")
+}
@@ -0,0 +1,230 @@
+// Package registry groups the listings of one issuer across exchanges. Listings
+// that share an ISIN, CIK or FIGI, or whose normalized company names match,
+// belong to the same issuer, and one of them is kept as its primary record.
+package registry
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Identifiers are the canonical IDs reported for a listing; any may be empty
+type Identifiers struct {
+	ISIN string `json:"isin,omitempty"`
+	CIK  string `json:"cik,omitempty"`
+	FIGI string `json:"figi,omitempty"`
+}
+
+// keys returns the identity keys a listing is matched on
+func (ids Identifiers) keys() []string {
+	var keys []string
+	if isin := strings.ToUpper(strings.TrimSpace(ids.ISIN)); isin != "" {
+		keys = append(keys, "isin:"+isin)
+	}
+	if cik := strings.TrimLeft(strings.TrimSpace(ids.CIK), "0"); cik != "" {
+		keys = append(keys, "cik:"+cik)
+	}
+	if figi := strings.ToUpper(strings.TrimSpace(ids.FIGI)); figi != "" {
+		keys = append(keys, "figi:"+figi)
+	}
+	return keys
+}
+
+// Listing is one tradable symbol of an issuer
+type Listing struct {
+	Symbol    string
+	Name      string
+	Exchange  string
+	Country   string
+	MarketCap float64
+	IDs       Identifiers
+
+	// ADR marks a depositary receipt, which is never primary while an
+	// ordinary listing of the issuer is known
+	ADR bool
+}
+
+// Issuer is every known listing of one company and the one chosen as primary
+type Issuer struct {
+	// ID is the canonical identifier: the primary's ISIN, else CIK or FIGI,
+	// else its normalized name
+	ID       string
+	Primary  Listing
+	Listings []Listing
+}
+
+// ShareClasses returns the issuer's other share classes: its ordinary
+// listings in the primary's country besides the primary, such as GOOG next
+// to GOOGL. Listings abroad and depositary receipts are cross-listings of
+// the same shares rather than classes.
+func (i Issuer) ShareClasses() []Listing {
+	var classes []Listing
+	for _, l := range i.Listings {
+		if l.Symbol != i.Primary.Symbol && !l.ADR && !i.Primary.ADR && l.Country == i.Primary.Country {
+			classes = append(classes, l)
+		}
+	}
+	return classes
+}
+
+// Prefer reports whether a should be the primary listing rather than b
+type Prefer func(a, b Listing) bool
+
+// Registry is a concurrent-safe union of listings keyed by identifier. It is
+// a union-find over symbols: adding a listing that shares a key with earlier
+// ones merges their issuers.
+type Registry struct {
+	prefer Prefer
+
+	mu       sync.Mutex
+	listings map[string]Listing // by symbol, first seen wins
+	order    []string
+	parent   map[string]string // union-find parent, by symbol
+	byKey    map[string]string // identity key -> a symbol holding it
+}
+
+// New returns an empty registry that picks primaries with prefer; a nil prefer
+// uses PreferPrimary
+func New(prefer Prefer) *Registry {
+	if prefer == nil {
+		prefer = PreferPrimary
+	}
+	return &Registry{
+		prefer:   prefer,
+		listings: make(map[string]Listing),
+		parent:   make(map[string]string),
+		byKey:    make(map[string]string),
+	}
+}
+
+// Add records a listing. A symbol added twice keeps its first listing but
+// merges any new identifiers.
+func (r *Registry) Add(l Listing) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.listings[l.Symbol]; !ok {
+		r.listings[l.Symbol] = l
+		r.order = append(r.order, l.Symbol)
+		r.parent[l.Symbol] = l.Symbol
+	}
+
+	keys := l.IDs.keys()
+	if name := NormalizeName(l.Name); name != "" {
+		keys = append(keys, "name:"+name)
+	}
+	for _, key := range keys {
+		if other, ok := r.byKey[key]; ok {
+			r.union(l.Symbol, other)
+		} else {
+			r.byKey[key] = l.Symbol
+		}
+	}
+}
+
+func (r *Registry) find(symbol string) string {
+	for r.parent[symbol] != symbol {
+		r.parent[symbol] = r.parent[r.parent[symbol]]
+		symbol = r.parent[symbol]
+	}
+	return symbol
+}
+
+func (r *Registry) union(a, b string) {
+	ra, rb := r.find(a), r.find(b)
+	if ra != rb {
+		r.parent[ra] = rb
+	}
+}
+
+// Issuers returns every issuer, ordered by the primary listing's market cap
+// descending, then symbol; listings within an issuer keep the order they were
+// added in
+func (r *Registry) Issuers() []Issuer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	groups := make(map[string][]Listing)
+	var roots []string
+	for _, symbol := range r.order {
+		root := r.find(symbol)
+		if _, ok := groups[root]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], r.listings[symbol])
+	}
+
+	issuers := make([]Issuer, 0, len(roots))
+	for _, root := range roots {
+		listings := groups[root]
+		primary := listings[0]
+		for _, l := range listings[1:] {
+			if r.prefer(l, primary) {
+				primary = l
+			}
+		}
+		issuers = append(issuers, Issuer{ID: canonicalID(primary, listings), Primary: primary, Listings: listings})
+	}
+
+	sort.Slice(issuers, func(i, j int) bool {
+		if issuers[i].Primary.MarketCap != issuers[j].Primary.MarketCap {
+			return issuers[i].Primary.MarketCap > issuers[j].Primary.MarketCap
+		}
+		return issuers[i].Primary.Symbol < issuers[j].Primary.Symbol
+	})
+	return issuers
+}
+
+// Lookup returns the issuer a symbol belongs to. It groups the whole registry,
+// so callers resolving many symbols should use Issuers instead.
+func (r *Registry) Lookup(symbol string) (Issuer, bool) {
+	for _, issuer := range r.Issuers() {
+		for _, l := range issuer.Listings {
+			if l.Symbol == symbol {
+				return issuer, true
+			}
+		}
+	}
+	return Issuer{}, false
+}
+
+// canonicalID prefers the primary's own identifiers, then any listing's
+func canonicalID(primary Listing, listings []Listing) string {
+	if keys := primary.IDs.keys(); len(keys) > 0 {
+		return keys[0]
+	}
+	for _, l := range listings {
+		if keys := l.IDs.keys(); len(keys) > 0 {
+			return keys[0]
+		}
+	}
+	return "name:" + NormalizeName(primary.Name)
+}
+
+// legalSuffixes are dropped from the end of company names before matching
+var legalSuffixes = map[string]bool{
+	"inc": true, "incorporated": true, "corp": true, "corporation": true, "co": true, "company": true,
+	"ltd": true, "limited": true, "plc": true, "llc": true, "lp": true, "holdings": true, "holding": true,
+	"group": true, "sa": true, "ag": true, "nv": true, "se": true, "spa": true, "ab": true, "asa": true,
+	"as": true, "oyj": true, "bhd": true, "tbk": true, "pt": true, "kk": true, "the": true,
+	"adr": true, "ads": true, "sponsored": true, "unsponsored": true, "class": true,
+	"a": true, "b": true, "c": true, "h": true, "ord": true, "shares": true, "&": true, "and": true,
+}
+
+// NormalizeName reduces a company name to a matching key, so that "Tencent
+// Holdings Ltd." and "TENCENT HOLDINGS LIMITED" (and plain "Tencent") agree.
+// Punctuation is removed and trailing legal-form words are dropped.
+func NormalizeName(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '&'
+	})
+	for len(words) > 1 && legalSuffixes[words[len(words)-1]] {
+		words = words[:len(words)-1]
+	}
+	if len(words) > 1 && words[0] == "the" {
+		words = words[1:]
+	}
+	return strings.Join(words, " ")
+}
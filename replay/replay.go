@@ -0,0 +1,228 @@
+// Package replay archives the raw HTTP responses a collector run reads and
+// serves them back to a later run in place of the network, so a change to
+// filtering or conversion can be checked against exactly the input an earlier
+// run saw.
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"algotradar/httpcache"
+)
+
+// MetaFile describes a recording and marks its directory as one
+const MetaFile = "recording.json"
+
+// IndexFile lists the recorded requests, one JSON object per line
+const IndexFile = "index.jsonl"
+
+// ResponsesDir is where a run records its responses inside a run directory
+const ResponsesDir = "responses"
+
+// PlaceholderKey stands in for API keys when replaying, as no request that
+// carries it leaves the process
+const PlaceholderKey = "replay"
+
+// HeaderReplay is set to "REPLAY" on responses served from a recording
+const HeaderReplay = "X-Replay"
+
+// ErrNotRecorded is returned for a request the recording has no response for
+var ErrNotRecorded = errors.New("no recorded response")
+
+// secretParams are the query parameters that carry API keys. They are left
+// out of keys and the index, so a recording can be replayed with other keys
+// and shared without leaking them.
+var secretParams = []string{"apikey", "apiKey", "api_token", "token"}
+
+// Meta is the content of MetaFile: when the responses were recorded and the
+// snapshot time of the run that recorded them, which differ for a run of a
+// past day
+type Meta struct {
+	RecordedAt   time.Time `json:"recorded_at"`
+	SnapshotTime time.Time `json:"snapshot_time"`
+}
+
+// Entry is one line of IndexFile
+type Entry struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+	File   string `json:"file"`
+}
+
+// Recorder passes requests to Base and archives each response to Dir. A
+// request made more than once keeps its last response. Rate limit (429) and
+// rejected key (401) responses are not archived, as they are about the keys
+// and the moment rather than the data, and the retry that follows them is.
+type Recorder struct {
+	Dir  string
+	Base http.RoundTripper
+
+	mu    sync.Mutex
+	index *os.File
+}
+
+// NewRecorder starts a recording in dir for a run with snapshot time t,
+// replacing any earlier recording there; a nil base uses
+// http.DefaultTransport
+func NewRecorder(dir string, t time.Time, base http.RoundTripper) (*Recorder, error) {
+	if _, err := os.Stat(filepath.Join(dir, MetaFile)); err == nil {
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, fmt.Errorf("failed to replace recording: %w", err)
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create recording dir: %w", err)
+	}
+	meta, err := json.MarshalIndent(Meta{RecordedAt: time.Now().UTC(), SnapshotTime: t.UTC()}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, MetaFile), append(meta, '\n'), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write recording metadata: %w", err)
+	}
+	index, err := os.Create(filepath.Join(dir, IndexFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording index: %w", err)
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Recorder{Dir: dir, Base: base, index: index}, nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.Base.RoundTrip(req)
+	if err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusUnauthorized {
+		return resp, err
+	}
+
+	// Responses the cache served are recorded as the API sent them
+	hit := httpcache.IsHit(resp)
+	resp.Header.Del(httpcache.HeaderCache)
+	dump, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record response: %w", err)
+	}
+	resp.Body.Close()
+
+	file := Key(req) + ".http"
+	if err := os.WriteFile(filepath.Join(r.Dir, file), dump, 0644); err != nil {
+		return nil, fmt.Errorf("failed to record response: %w", err)
+	}
+	line, err := json.Marshal(Entry{Method: req.Method, URL: Redact(req.URL), Status: resp.StatusCode, File: file})
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	_, err = r.index.Write(append(line, '\n'))
+	r.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to record response: %w", err)
+	}
+
+	recorded, err := readDump(dump, req)
+	if err != nil {
+		return nil, err
+	}
+	if hit {
+		recorded.Header.Set(httpcache.HeaderCache, "HIT")
+	}
+	return recorded, nil
+}
+
+// Close finishes the recording
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.index.Close()
+}
+
+// Player serves the responses of a recording and fails every request the
+// recording has none for, so a replay never reaches the network
+type Player struct {
+	Dir string
+	Meta
+}
+
+// Open loads the recording in dir, or in the responses directory of the run
+// directory dir
+func Open(dir string) (*Player, error) {
+	if _, err := os.Stat(filepath.Join(dir, MetaFile)); errors.Is(err, os.ErrNotExist) {
+		if _, err := os.Stat(filepath.Join(dir, ResponsesDir, MetaFile)); err == nil {
+			dir = filepath.Join(dir, ResponsesDir)
+		}
+	}
+	// A run directory reached through latest is recorded by its own name
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = real
+	}
+	data, err := os.ReadFile(filepath.Join(dir, MetaFile))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a recording: %w", dir, err)
+	}
+	p := &Player{Dir: dir}
+	if err := json.Unmarshal(data, &p.Meta); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", MetaFile, err)
+	}
+	return p, nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (p *Player) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	dump, err := os.ReadFile(filepath.Join(p.Dir, Key(req)+".http"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w for %s %s", ErrNotRecorded, req.Method, Redact(req.URL))
+	}
+	if err != nil {
+		return nil, err
+	}
+	resp, err := readDump(dump, req)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recorded response for %s: %w", Redact(req.URL), err)
+	}
+	resp.Header.Set(HeaderReplay, "REPLAY")
+	return resp, nil
+}
+
+// Key names the recorded response of req: a hash of its method, path and
+// query without API keys. The host is left out, so a recording made against
+// a mirror or a mock base URL replays under the default one.
+func Key(req *http.Request) string {
+	path := *req.URL
+	path.Scheme, path.Host, path.User = "", "", nil
+	sum := sha256.Sum256([]byte(req.Method + " " + Redact(&path)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Redact returns u without the query parameters that carry API keys
+func Redact(u *url.URL) string {
+	clean := *u
+	query := clean.Query()
+	for _, param := range secretParams {
+		query.Del(param)
+	}
+	clean.RawQuery = query.Encode()
+	return clean.String()
+}
+
+func readDump(dump []byte, req *http.Request) (*http.Response, error) {
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(dump)), req)
+}
@@ -0,0 +1,187 @@
+// Package runreport writes the machine-readable run_report.json produced after each collection run.
+package runreport
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"algotradar/canonical"
+	"algotradar/domain"
+	"algotradar/integrity"
+	"algotradar/marketdata"
+	"algotradar/metrics"
+)
+
+// DefaultFilename is where collectors write the report unless told otherwise
+const DefaultFilename = "run_report.json"
+
+// Recorder accumulates report data from concurrent workers
+type Recorder struct {
+	mu                sync.Mutex
+	collector         string
+	startedAt         time.Time
+	errors            []string
+	errorsByKind      map[string]int
+	failedCountries   []domain.CountryFailure
+	fallbacks         []domain.CountryFallback
+	symbolsPerCountry map[string]int
+	dropped           []domain.DroppedSymbol
+	outputs           []domain.OutputFile
+}
+
+// New starts a recorder for the named collector
+func New(collector string) *Recorder {
+	return &Recorder{
+		collector:         collector,
+		startedAt:         time.Now().UTC(),
+		symbolsPerCountry: make(map[string]int),
+		errorsByKind:      make(map[string]int),
+	}
+}
+
+// Drop records a symbol that was excluded from the output. Safe on a nil recorder.
+func (r *Recorder) Drop(symbol, country, reason string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dropped = append(r.dropped, domain.DroppedSymbol{Symbol: symbol, Country: country, Reason: reason})
+}
+
+// Error records a non-fatal error, counted by its marketdata kind; a
+// *marketdata.CountryError also marks the run partial. Safe on a nil recorder.
+func (r *Recorder) Error(err error) {
+	if r == nil || err == nil {
+		return
+	}
+	kind := marketdata.Classify(err)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors = append(r.errors, err.Error())
+	r.errorsByKind[kind]++
+	var countryErr *marketdata.CountryError
+	if errors.As(err, &countryErr) {
+		r.failedCountries = append(r.failedCountries, domain.CountryFailure{
+			Country: countryErr.Country,
+			Kind:    kind,
+			Partial: countryErr.Partial,
+			Error:   countryErr.Err.Error(),
+		})
+	}
+}
+
+// Fallback records a country published from a previous snapshot. Safe on a
+// nil recorder.
+func (r *Recorder) Fallback(f domain.CountryFallback) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fallbacks = append(r.fallbacks, f)
+}
+
+// CountSymbols tallies output symbols by country
+func (r *Recorder) CountSymbols(countries []string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, country := range countries {
+		r.symbolsPerCountry[country]++
+	}
+}
+
+// AddOutput checksums a written artifact and records it in the report
+func (r *Recorder) AddOutput(path string) error {
+	if r == nil {
+		return nil
+	}
+
+	digest, n, err := integrity.FileSHA256(path)
+	if err != nil {
+		return fmt.Errorf("failed to checksum output %s: %w", path, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.outputs = append(r.outputs, domain.OutputFile{Path: path, Bytes: n, SHA256: digest})
+	return nil
+}
+
+// Build finalizes the report, pulling API call counts from the collector metrics
+func (r *Recorder) Build() domain.Run {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	finished := time.Now().UTC()
+	report := domain.Run{
+		Collector:         r.collector,
+		StartedAt:         r.startedAt,
+		FinishedAt:        finished,
+		DurationSeconds:   finished.Sub(r.startedAt).Seconds(),
+		APICalls:          make(map[string]int64),
+		Errors:            append([]string{}, r.errors...),
+		ErrorCount:        len(r.errors),
+		ErrorsByKind:      make(map[string]int, len(r.errorsByKind)),
+		FailedCountries:   append([]domain.CountryFailure{}, r.failedCountries...),
+		FallbackCountries: append([]domain.CountryFallback{}, r.fallbacks...),
+		Partial:           len(r.failedCountries) > 0 || len(r.fallbacks) > 0,
+		SymbolsPerCountry: make(map[string]int, len(r.symbolsPerCountry)),
+		Dropped:           append([]domain.DroppedSymbol{}, r.dropped...),
+		DroppedByReason:   make(map[string]int),
+		Outputs:           append([]domain.OutputFile{}, r.outputs...),
+	}
+
+	for _, sample := range metrics.APICalls.Samples() {
+		endpoint, status := sample.Labels[0], sample.Labels[1]
+		report.APICalls[endpoint] += int64(sample.Value)
+		report.APICallsTotal += int64(sample.Value)
+		if status != "200" {
+			report.APIErrors += int64(sample.Value)
+		}
+	}
+	report.RateLimited = int64(metrics.APIRateLimited.Total())
+
+	for kind, n := range r.errorsByKind {
+		report.ErrorsByKind[kind] = n
+	}
+	sort.Slice(report.FailedCountries, func(i, j int) bool {
+		return report.FailedCountries[i].Country < report.FailedCountries[j].Country
+	})
+	for country, n := range r.symbolsPerCountry {
+		report.SymbolsPerCountry[country] = n
+	}
+	for _, d := range report.Dropped {
+		report.DroppedByReason[d.Reason]++
+	}
+	sort.Slice(report.Dropped, func(i, j int) bool {
+		if report.Dropped[i].Reason != report.Dropped[j].Reason {
+			return report.Dropped[i].Reason < report.Dropped[j].Reason
+		}
+		if report.Dropped[i].Symbol != report.Dropped[j].Symbol {
+			return report.Dropped[i].Symbol < report.Dropped[j].Symbol
+		}
+		return report.Dropped[i].Country < report.Dropped[j].Country
+	})
+
+	return report
+}
+
+// Write builds the report and saves it as indented JSON
+func (r *Recorder) Write(path string) error {
+	data, err := canonical.JSON(r.Build())
+	if err != nil {
+		return fmt.Errorf("failed to marshal run report: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write run report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// stuckGrace is how long a job may outlive its timeout, e.g. while its
+// process is being killed, before it counts as stuck
+const stuckGrace = time.Minute
+
+// Health is what /healthz and /readyz report about the daemon
+type Health struct {
+	// Live is false when the scheduling loop has stopped or a job is stuck,
+	// and the daemon should be restarted
+	Live bool `json:"live"`
+	// Ready is true while the scheduling loop runs
+	Ready       bool      `json:"ready"`
+	LastSuccess time.Time `json:"last_success,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+	// QueueDepth is the number of deferred enrichment tasks, -1 when unknown
+	QueueDepth int      `json:"queue_depth"`
+	Problems   []string `json:"problems,omitempty"`
+	Jobs       []Status `json:"jobs"`
+}
+
+// Health checks the daemon at now. A job counts as stuck once it has run
+// past its timeout, or past StuckAfter when it has none.
+func (s *Scheduler) Health(now time.Time) Health {
+	h := Health{Jobs: s.Statuses(), QueueDepth: -1}
+
+	s.mu.Lock()
+	h.Ready = s.running
+	for _, e := range s.entries {
+		if !e.status.Running {
+			continue
+		}
+		limit := s.StuckAfter
+		if e.timeout > 0 {
+			limit = e.timeout + stuckGrace
+		}
+		if running := now.Sub(e.status.LastStart); limit > 0 && running > limit {
+			h.Problems = append(h.Problems, fmt.Sprintf("job %s has been running for %s", e.job.Name, running.Round(time.Second)))
+		}
+	}
+	s.mu.Unlock()
+	if !h.Ready {
+		h.Problems = append(h.Problems, "scheduler is not running")
+	}
+	h.Live = len(h.Problems) == 0
+
+	var lastFailure time.Time
+	for _, status := range h.Jobs {
+		if status.LastSuccess.After(h.LastSuccess) {
+			h.LastSuccess = status.LastSuccess
+		}
+		if status.LastError != "" && status.LastEnd.After(lastFailure) {
+			lastFailure = status.LastEnd
+			h.LastError = fmt.Sprintf("%s: %s", status.Name, status.LastError)
+		}
+	}
+
+	if s.QueueDepth != nil {
+		depth, err := s.QueueDepth()
+		if err != nil {
+			s.Logger.Warn("failed to read enrichment queue depth", "error", err)
+		} else {
+			h.QueueDepth = depth
+		}
+	}
+	return h
+}
+
+// HealthHandler serves /healthz, answering 503 when the daemon is not live,
+// and /readyz, answering 503 when it is not ready; both return the Health as JSON
+func (s *Scheduler) HealthHandler() http.Handler {
+	mux := http.NewServeMux()
+	probe := func(ok func(Health) bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, _ *http.Request) {
+			h := s.Health(time.Now())
+			w.Header().Set("Content-Type", "application/json")
+			if !ok(h) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			json.NewEncoder(w).Encode(h)
+		}
+	}
+	mux.Handle("/healthz", probe(func(h Health) bool { return h.Live }))
+	mux.Handle("/readyz", probe(func(h Health) bool { return h.Ready }))
+	return mux
+}
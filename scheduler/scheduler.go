@@ -0,0 +1,275 @@
+// Package scheduler runs collection jobs on cron schedules with overlap prevention
+// and per-job status tracking.
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sort"
+	"sync"
+	"time"
+
+	"algotradar/cron"
+)
+
+// Job is one scheduled command
+type Job struct {
+	Name     string   `json:"name"`
+	Schedule string   `json:"schedule"`
+	Command  []string `json:"command"`
+	Dir      string   `json:"dir,omitempty"`
+	Timeout  string   `json:"timeout,omitempty"` // Go duration, e.g. "2h"
+}
+
+// Status is the observable state of a job
+type Status struct {
+	Name            string    `json:"name"`
+	Schedule        string    `json:"schedule"`
+	Running         bool      `json:"running"`
+	Runs            int       `json:"runs"`
+	Failures        int       `json:"failures"`
+	SkippedOverlaps int       `json:"skipped_overlaps"`
+	LastStart       time.Time `json:"last_start,omitempty"`
+	LastEnd         time.Time `json:"last_end,omitempty"`
+	LastSuccess     time.Time `json:"last_success,omitempty"`
+	LastDuration    string    `json:"last_duration,omitempty"`
+	LastError       string    `json:"last_error,omitempty"`
+	NextRun         time.Time `json:"next_run,omitempty"`
+}
+
+// LoadJobs reads a JSON array of jobs
+func LoadJobs(path string) ([]Job, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job config: %w", err)
+	}
+	var jobs []Job
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return nil, fmt.Errorf("failed to parse job config: %w", err)
+	}
+	return jobs, nil
+}
+
+type entry struct {
+	job      Job
+	schedule *cron.Schedule
+	timeout  time.Duration
+	status   Status
+}
+
+// Scheduler runs jobs whenever their cron schedule fires
+type Scheduler struct {
+	Logger     *slog.Logger
+	StatusFile string // optional path rewritten after every state change
+	Location   *time.Location
+
+	// Runner executes a job; the default runs Job.Command as a subprocess
+	Runner func(ctx context.Context, job Job) error
+	// StuckAfter is how long a job without a timeout may run before health
+	// checks report it stuck; 0 never does
+	StuckAfter time.Duration
+	// QueueDepth, when set, reports the deferred enrichment backlog in health checks
+	QueueDepth func() (int, error)
+
+	mu      sync.Mutex
+	entries []*entry
+	wg      sync.WaitGroup
+	running bool
+}
+
+// New validates the jobs and returns a scheduler for them
+func New(jobs []Job) (*Scheduler, error) {
+	if len(jobs) == 0 {
+		return nil, errors.New("no jobs configured")
+	}
+
+	s := &Scheduler{Logger: slog.Default(), Location: time.Local, Runner: runCommand}
+	seen := make(map[string]bool)
+	for _, job := range jobs {
+		if job.Name == "" {
+			return nil, errors.New("job without a name")
+		}
+		if seen[job.Name] {
+			return nil, fmt.Errorf("duplicate job name %q", job.Name)
+		}
+		seen[job.Name] = true
+
+		schedule, err := cron.Parse(job.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("job %s: %w", job.Name, err)
+		}
+		if len(job.Command) == 0 {
+			return nil, fmt.Errorf("job %s: empty command", job.Name)
+		}
+		var timeout time.Duration
+		if job.Timeout != "" {
+			if timeout, err = time.ParseDuration(job.Timeout); err != nil {
+				return nil, fmt.Errorf("job %s: invalid timeout: %w", job.Name, err)
+			}
+		}
+		s.entries = append(s.entries, &entry{
+			job:      job,
+			schedule: schedule,
+			timeout:  timeout,
+			status:   Status{Name: job.Name, Schedule: job.Schedule},
+		})
+	}
+	return s, nil
+}
+
+// Statuses returns a snapshot of every job's status ordered by name
+func (s *Scheduler) Statuses() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]Status, len(s.entries))
+	for i, e := range s.entries {
+		statuses[i] = e.status
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// Run blocks, firing jobs on schedule until ctx is cancelled, then waits for
+// running jobs to finish
+func (s *Scheduler) Run(ctx context.Context) error {
+	defer s.wg.Wait()
+	s.setRunning(true)
+	defer s.setRunning(false)
+
+	for {
+		now := time.Now().In(s.Location)
+		next := s.planNext(now)
+		s.writeStatus()
+
+		// No job has a run ahead: wait for cancellation rather than firing now
+		if next.IsZero() {
+			s.Logger.Warn("no job has a next run")
+			<-ctx.Done()
+			return ctx.Err()
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		s.mu.Lock()
+		var due []*entry
+		for _, e := range s.entries {
+			if !e.status.NextRun.IsZero() && !e.status.NextRun.After(next) {
+				due = append(due, e)
+			}
+		}
+		s.mu.Unlock()
+
+		for _, e := range due {
+			s.start(ctx, e)
+		}
+	}
+}
+
+func (s *Scheduler) setRunning(running bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = running
+}
+
+// planNext computes each job's next run after now and returns the earliest,
+// or the zero time when no job has one
+func (s *Scheduler) planNext(now time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var earliest time.Time
+	for _, e := range s.entries {
+		e.status.NextRun = e.schedule.Next(now)
+		if e.status.NextRun.IsZero() {
+			continue
+		}
+		if earliest.IsZero() || e.status.NextRun.Before(earliest) {
+			earliest = e.status.NextRun
+		}
+	}
+	return earliest
+}
+
+// start launches a job unless its previous run is still going
+func (s *Scheduler) start(ctx context.Context, e *entry) {
+	s.mu.Lock()
+	if e.status.Running {
+		e.status.SkippedOverlaps++
+		s.mu.Unlock()
+		s.Logger.Warn("skipping job, previous run still in progress", "job", e.job.Name)
+		return
+	}
+	e.status.Running = true
+	e.status.LastStart = time.Now()
+	s.mu.Unlock()
+	s.writeStatus()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		logger := s.Logger.With("job", e.job.Name)
+		logger.Info("job started")
+
+		jobCtx := ctx
+		if e.timeout > 0 {
+			var cancel context.CancelFunc
+			jobCtx, cancel = context.WithTimeout(ctx, e.timeout)
+			defer cancel()
+		}
+		err := s.Runner(jobCtx, e.job)
+
+		s.mu.Lock()
+		e.status.Running = false
+		e.status.Runs++
+		e.status.LastEnd = time.Now()
+		e.status.LastDuration = e.status.LastEnd.Sub(e.status.LastStart).Round(time.Millisecond).String()
+		e.status.LastError = ""
+		if err != nil {
+			e.status.Failures++
+			e.status.LastError = err.Error()
+		} else {
+			e.status.LastSuccess = e.status.LastEnd
+		}
+		duration := e.status.LastDuration
+		s.mu.Unlock()
+		s.writeStatus()
+
+		if err != nil {
+			logger.Error("job failed", "duration", duration, "error", err)
+		} else {
+			logger.Info("job finished", "duration", duration)
+		}
+	}()
+}
+
+func (s *Scheduler) writeStatus() {
+	if s.StatusFile == "" {
+		return
+	}
+	data, err := json.MarshalIndent(s.Statuses(), "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(s.StatusFile, data, 0644); err != nil {
+		s.Logger.Warn("failed to write status file", "file", s.StatusFile, "error", err)
+	}
+}
+
+func runCommand(ctx context.Context, job Job) error {
+	cmd := exec.CommandContext(ctx, job.Command[0], job.Command[1:]...)
+	cmd.Dir = job.Dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,61 @@
+package scheduler
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewErrors(t *testing.T) {
+	command := []string{"true"}
+	tests := []struct {
+		name string
+		jobs []Job
+		want string
+	}{
+		{"no jobs", nil, "no jobs configured"},
+		{"no name", []Job{{Schedule: "* * * * *", Command: command}}, "job without a name"},
+		{"duplicate", []Job{
+			{Name: "a", Schedule: "* * * * *", Command: command},
+			{Name: "a", Schedule: "0 * * * *", Command: command},
+		}, "duplicate job name"},
+		{"bad schedule", []Job{{Name: "a", Schedule: "61 * * * *", Command: command}}, "job a"},
+		{"never matches", []Job{{Name: "a", Schedule: "0 0 30 2 *", Command: command}}, "never matches"},
+		{"empty command", []Job{{Name: "a", Schedule: "* * * * *"}}, "empty command"},
+		{"bad timeout", []Job{{Name: "a", Schedule: "* * * * *", Command: command, Timeout: "soon"}}, "invalid timeout"},
+	}
+	for _, tt := range tests {
+		_, err := New(tt.jobs)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: New = %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestPlanNext(t *testing.T) {
+	from := time.Date(2096, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		schedules []string
+		want      time.Time
+	}{
+		{"earliest job", []string{"0 22 * * *", "30 21 * * *"}, time.Date(2096, 3, 1, 21, 30, 0, 0, time.UTC)},
+		// No Feb 29 falls within five years of 2096-03-01, as 2100 is not a
+		// leap year, so the leap-day job has no next run
+		{"skips jobs without a next run", []string{"0 0 29 2 *", "0 6 * * *"}, time.Date(2096, 3, 1, 6, 0, 0, 0, time.UTC)},
+		{"no job has a next run", []string{"0 0 29 2 *"}, time.Time{}},
+	}
+	for _, tt := range tests {
+		var jobs []Job
+		for i, schedule := range tt.schedules {
+			jobs = append(jobs, Job{Name: string(rune('a' + i)), Schedule: schedule, Command: []string{"true"}})
+		}
+		s, err := New(jobs)
+		if err != nil {
+			t.Fatalf("%s: New = %v", tt.name, err)
+		}
+		if got := s.planNext(from); !got.Equal(tt.want) {
+			t.Errorf("%s: planNext = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
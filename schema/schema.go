@@ -0,0 +1,176 @@
+// Package schema versions the row layouts of the snapshot files and publishes
+// them as JSON Schema, so downstream loaders can tell which layout a file was
+// written with and check a file before loading it.
+package schema
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+
+	"algotradar/domain"
+)
+
+// Version is the schema_version written into every output file. Bump it, and
+// the const in each *.schema.json, whenever a field is renamed, removed or
+// changes type; adding an optional field does not need a new version.
+const Version = 1
+
+// Names of the published schemas
+const (
+	AssetSchema    = "asset"
+	SupabaseSchema = "supabase"
+)
+
+// maxRowErrors is how many invalid rows Validate reports
+const maxRowErrors = 10
+
+//go:embed *.schema.json
+var files embed.FS
+
+// Asset is a snapshot row as written to JSON: the asset followed by the
+// version of its layout
+type Asset struct {
+	domain.Asset
+	SchemaVersion int `json:"schema_version"`
+}
+
+// Assets tags each asset with the current Version
+func Assets(assets []domain.Asset) []Asset {
+	rows := make([]Asset, len(assets))
+	for i, asset := range assets {
+		rows[i] = Asset{asset, Version}
+	}
+	return rows
+}
+
+// Names lists the published schemas
+func Names() []string {
+	entries, _ := files.ReadDir(".")
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".schema.json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Document returns the JSON Schema of one row of the named layout
+func Document(name string) ([]byte, error) {
+	data, err := files.ReadFile(name + ".schema.json")
+	if err != nil {
+		return nil, fmt.Errorf("unknown schema %q, want one of %s", name, strings.Join(Names(), ", "))
+	}
+	return data, nil
+}
+
+// Detect names the layout of a JSON output file from its first row: Supabase
+// rows carry a symbol, asset rows do not. An empty file is an asset file.
+func Detect(data []byte) (string, error) {
+	var rows []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return "", fmt.Errorf("not a JSON array of rows: %w", err)
+	}
+	if len(rows) > 0 {
+		if _, ok := rows[0]["symbol"]; ok {
+			return SupabaseSchema, nil
+		}
+	}
+	return AssetSchema, nil
+}
+
+// Validate checks every row of a JSON output file against the named schema.
+// The error lists the first invalid rows and how many there are in all.
+func Validate(name string, data []byte) error {
+	doc, err := Document(name)
+	if err != nil {
+		return err
+	}
+	compiled, err := compile(name, doc)
+	if err != nil {
+		return err
+	}
+
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("not valid JSON: %w", err)
+	}
+	rows, ok := instance.([]any)
+	if !ok {
+		return errors.New("not a JSON array of rows")
+	}
+
+	var problems []string
+	invalid := 0
+	for i, row := range rows {
+		err := compiled.Validate(row)
+		if err == nil {
+			continue
+		}
+		invalid++
+		if len(problems) < maxRowErrors {
+			problems = append(problems, fmt.Sprintf("row %d%s: %s", i+1, rowTicker(row), describe(err)))
+		}
+	}
+	if invalid == 0 {
+		return nil
+	}
+	if invalid > len(problems) {
+		problems = append(problems, fmt.Sprintf("... and %d more", invalid-len(problems)))
+	}
+	return fmt.Errorf("%d of %d rows do not match the %s schema:\n  %s", invalid, len(rows), name, strings.Join(problems, "\n  "))
+}
+
+func compile(name string, doc []byte) (*jsonschema.Schema, error) {
+	parsed, err := jsonschema.UnmarshalJSON(bytes.NewReader(doc))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s schema: %w", name, err)
+	}
+	url := name + ".schema.json"
+	compiler := jsonschema.NewCompiler()
+	compiler.AssertFormat()
+	if err := compiler.AddResource(url, parsed); err != nil {
+		return nil, err
+	}
+	return compiler.Compile(url)
+}
+
+// rowTicker names the row's ticker, if it has one, for error messages
+func rowTicker(row any) string {
+	if fields, ok := row.(map[string]any); ok {
+		if ticker, ok := fields["ticker"].(string); ok && ticker != "" {
+			return " (" + ticker + ")"
+		}
+	}
+	return ""
+}
+
+// describe flattens a validation error to its failing fields, e.g.
+// "/market_cap: got string, want number; missing property schema_version"
+func describe(err error) string {
+	var invalid *jsonschema.ValidationError
+	if !errors.As(err, &invalid) {
+		return err.Error()
+	}
+	var causes []string
+	for _, unit := range invalid.BasicOutput().Errors {
+		if unit.Error == nil {
+			continue
+		}
+		cause := unit.Error.String()
+		if unit.InstanceLocation != "" {
+			cause = unit.InstanceLocation + ": " + cause
+		}
+		causes = append(causes, cause)
+	}
+	if len(causes) == 0 {
+		return err.Error()
+	}
+	return strings.Join(causes, "; ")
+}
@@ -0,0 +1,170 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"algotradar/awssig"
+)
+
+// AWSSecretsManager reads secrets by name or ARN with GetSecretValue, signed
+// with the credentials in AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY. The
+// region comes from an ARN or from AWS_REGION; SECRETSMANAGER_ENDPOINT
+// overrides the endpoint (e.g. for LocalStack).
+type AWSSecretsManager struct{}
+
+func (AWSSecretsManager) Fetch(ctx context.Context, client *http.Client, name string) (string, error) {
+	creds, err := awssig.CredentialsFromEnv()
+	if err != nil {
+		return "", err
+	}
+	region := awssig.RegionFromEnv()
+	if parts := strings.Split(name, ":"); len(parts) > 3 && parts[0] == "arn" {
+		region = parts[3]
+	}
+	endpoint := os.Getenv("SECRETSMANAGER_ENDPOINT")
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com", region)
+	}
+
+	body, err := json.Marshal(map[string]string{"SecretId": name})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	awssig.NewSigner(creds, region, "secretsmanager").Sign(req, awssig.HashPayload(body))
+
+	var out struct {
+		SecretString string `json:"SecretString"`
+		SecretBinary []byte `json:"SecretBinary"`
+	}
+	if err := doJSON(client, req, &out); err != nil {
+		return "", err
+	}
+	if out.SecretString != "" {
+		return out.SecretString, nil
+	}
+	return string(out.SecretBinary), nil
+}
+
+// GCPSecretManager reads secret versions by resource name, e.g.
+// projects/my-project/secrets/fmp/versions/latest ("versions/latest" is added
+// when missing). The access token comes from GOOGLE_OAUTH_ACCESS_TOKEN or,
+// on Google Cloud, from the metadata server.
+type GCPSecretManager struct{}
+
+func (GCPSecretManager) Fetch(ctx context.Context, client *http.Client, name string) (string, error) {
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+	token, err := gcpAccessToken(ctx, client)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://secretmanager.googleapis.com/v1/"+name+":access", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	var out struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := doJSON(client, req, &out); err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(out.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("secret payload is not base64: %w", err)
+	}
+	return string(data), nil
+}
+
+func gcpAccessToken(ctx context.Context, client *http.Client) (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		"http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	var out struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := doJSON(client, req, &out); err != nil {
+		return "", fmt.Errorf("no GOOGLE_OAUTH_ACCESS_TOKEN and no metadata server: %w", err)
+	}
+	return out.AccessToken, nil
+}
+
+// Vault reads a KV secret by API path, e.g. secret/data/algotradar for the
+// KV v2 engine mounted at secret/. The server and token come from VAULT_ADDR
+// and VAULT_TOKEN, with VAULT_NAMESPACE for Vault Enterprise.
+type Vault struct{}
+
+func (Vault) Fetch(ctx context.Context, client *http.Client, path string) (string, error) {
+	addr := os.Getenv("VAULT_ADDR")
+	token := os.Getenv("VAULT_TOKEN")
+	if addr == "" || token == "" {
+		return "", errors.New("VAULT_ADDR and VAULT_TOKEN must be set")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(addr, "/")+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if namespace := os.Getenv("VAULT_NAMESPACE"); namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+
+	var out struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := doJSON(client, req, &out); err != nil {
+		return "", err
+	}
+	// KV v2 nests the fields under data.data alongside the version metadata
+	fields := out.Data
+	if nested, ok := fields["data"]; ok && fields["metadata"] != nil {
+		fields = nil
+		if err := json.Unmarshal(nested, &fields); err != nil {
+			return "", fmt.Errorf("unexpected KV v2 response: %w", err)
+		}
+	}
+	data, err := json.Marshal(fields)
+	return string(data), err
+}
+
+// doJSON sends req and decodes a 200 response into out
+func doJSON(client *http.Client, req *http.Request, out any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, out)
+}
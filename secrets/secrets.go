@@ -0,0 +1,210 @@
+// Package secrets resolves credentials held in a secret manager, so production
+// schedulers need not keep FMP keys or database passwords in a .env file. An
+// environment variable whose value is a secret reference, such as
+//
+//	FMP_API_KEY=aws-sm://prod/algotradar#fmp_api_key
+//
+// is replaced by the secret's value, and SECRETS_SOURCE can name whole secrets
+// whose JSON fields are loaded as environment variables.
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SourceEnv lists secret references, separated by commas, whose JSON objects
+// are loaded into the environment
+const SourceEnv = "SECRETS_SOURCE"
+
+// Provider fetches the raw value of one secret from a secret manager
+type Provider interface {
+	Fetch(ctx context.Context, client *http.Client, name string) (string, error)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]Provider{
+		"aws-sm": AWSSecretsManager{},
+		"gcp-sm": GCPSecretManager{},
+		"vault":  Vault{},
+	}
+)
+
+// Register makes a provider available under scheme, replacing any existing one
+func Register(scheme string, p Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[scheme] = p
+}
+
+func provider(scheme string) (Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	p, ok := providers[scheme]
+	return p, ok
+}
+
+// Ref is a parsed secret reference: scheme://name#field. Without a field the
+// whole secret is the value.
+type Ref struct {
+	Scheme string
+	Name   string
+	Field  string
+}
+
+func (r Ref) String() string {
+	s := r.Scheme + "://" + r.Name
+	if r.Field != "" {
+		s += "#" + r.Field
+	}
+	return s
+}
+
+// ParseRef parses value as a reference to a registered provider. ok is false
+// for ordinary values, which are left alone.
+func ParseRef(value string) (ref Ref, ok bool) {
+	scheme, rest, found := strings.Cut(value, "://")
+	if !found {
+		return Ref{}, false
+	}
+	if _, registered := provider(scheme); !registered {
+		return Ref{}, false
+	}
+	name, field, _ := strings.Cut(rest, "#")
+	return Ref{Scheme: scheme, Name: name, Field: field}, name != ""
+}
+
+// Resolver fetches secrets, asking each secret manager once per secret
+type Resolver struct {
+	Client *http.Client
+
+	mu    sync.Mutex
+	cache map[string]string
+}
+
+// NewResolver returns a resolver with a 30s HTTP timeout
+func NewResolver() *Resolver {
+	return &Resolver{Client: &http.Client{Timeout: 30 * time.Second}, cache: make(map[string]string)}
+}
+
+// Resolve returns the value ref points at
+func (r *Resolver) Resolve(ctx context.Context, ref Ref) (string, error) {
+	raw, err := r.fetch(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	if ref.Field == "" {
+		return strings.TrimSpace(raw), nil
+	}
+	fields, err := parseFields(raw)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", ref, err)
+	}
+	value, ok := fields[ref.Field]
+	if !ok {
+		return "", fmt.Errorf("%s: secret has no field %q", ref, ref.Field)
+	}
+	return value, nil
+}
+
+func (r *Resolver) fetch(ctx context.Context, ref Ref) (string, error) {
+	key := ref.Scheme + "://" + ref.Name
+	r.mu.Lock()
+	raw, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok {
+		return raw, nil
+	}
+
+	p, ok := provider(ref.Scheme)
+	if !ok {
+		return "", fmt.Errorf("unknown secret provider %q", ref.Scheme)
+	}
+	raw, err := p.Fetch(ctx, r.Client, ref.Name)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", key, err)
+	}
+	r.mu.Lock()
+	r.cache[key] = raw
+	r.mu.Unlock()
+	return raw, nil
+}
+
+// parseFields reads a secret holding a JSON object; non-string values keep
+// their JSON text
+func parseFields(raw string) (map[string]string, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &object); err != nil {
+		return nil, fmt.Errorf("secret is not a JSON object: %w", err)
+	}
+	fields := make(map[string]string, len(object))
+	for name, value := range object {
+		var s string
+		if json.Unmarshal(value, &s) == nil {
+			fields[name] = s
+		} else {
+			fields[name] = string(value)
+		}
+	}
+	return fields, nil
+}
+
+// LoadEnv loads the secrets listed in SECRETS_SOURCE into the environment,
+// without overriding variables that are already set, then replaces every
+// variable holding a secret reference with the secret's value. Values are
+// never logged, only the names of the variables set.
+func LoadEnv(ctx context.Context, logger *slog.Logger) error {
+	r := NewResolver()
+
+	for _, source := range strings.Split(os.Getenv(SourceEnv), ",") {
+		source = strings.TrimSpace(source)
+		if source == "" {
+			continue
+		}
+		ref, ok := ParseRef(source)
+		if !ok || ref.Field != "" {
+			return fmt.Errorf("%s: %q is not a secret reference without a #field", SourceEnv, source)
+		}
+		raw, err := r.fetch(ctx, ref)
+		if err != nil {
+			return err
+		}
+		fields, err := parseFields(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", ref, err)
+		}
+		var loaded []string
+		for name, value := range fields {
+			if _, set := os.LookupEnv(name); !set {
+				os.Setenv(name, value)
+				loaded = append(loaded, name)
+			}
+		}
+		sort.Strings(loaded)
+		logger.Info("loaded secrets", "source", ref.String(), "vars", loaded)
+	}
+
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		ref, ok := ParseRef(value)
+		if !ok || name == SourceEnv {
+			continue
+		}
+		secret, err := r.Resolve(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		os.Setenv(name, secret)
+		logger.Info("resolved secret", "var", name, "provider", ref.Scheme)
+	}
+	return nil
+}
@@ -0,0 +1,479 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"algotradar/domain"
+	"algotradar/gauth"
+	"algotradar/schema"
+)
+
+const (
+	bigQueryEndpoint = "https://bigquery.googleapis.com"
+	bigQueryScope    = "https://www.googleapis.com/auth/bigquery"
+
+	// bigQueryStreamBatch is how many rows one insertAll request carries
+	bigQueryStreamBatch = 500
+)
+
+// bigQueryTypes fixes the column type of fields whose JSON value alone does
+// not tell it; other fields are STRING, FLOAT or BOOLEAN by their value
+var bigQueryTypes = map[string]string{
+	"snapshot_date":         "DATE",
+	"collected_at":          "TIMESTAMP",
+	"rank":                  "INTEGER",
+	"schema_version":        "INTEGER",
+	"quote_timestamp_utc":   "TIMESTAMP",
+	"listing_date":          "DATE",
+	"institutional_holders": "INTEGER",
+}
+
+// BigQuery loads each run into a BigQuery table partitioned by day on
+// snapshot_date, through the REST API. The columns are the JSON row's fields
+// plus snapshot_date, rank and collected_at. The table is created on first
+// use, and columns that new enrichments add are appended to its schema as
+// NULLABLE before the rows are written, so the table follows the snapshots
+// without manual migrations. The dataset must exist. The sink signs in as the
+// service account in GOOGLE_APPLICATION_CREDENTIALS; BIGQUERY_ENDPOINT
+// overrides the API host.
+//
+// mode=load (the default) replaces the day's partition with one load job, so
+// a rerun on the same UTC day leaves one row per symbol. mode=stream sends
+// the rows with streaming inserts instead, which are queryable within
+// seconds but cost more and add a rerun's rows next to the earlier ones.
+//
+// Spec: bigquery:PROJECT.DATASET.TABLE?mode=load|stream&location=EU
+type BigQuery struct {
+	Project  string
+	Dataset  string
+	Table    string
+	Mode     string
+	Location string
+	Endpoint string
+	Attempts int
+	Client   *http.Client
+
+	tokens *gauth.TokenSource
+}
+
+// NewBigQuery parses the target of a bigquery: spec
+func NewBigQuery(target string) (*BigQuery, error) {
+	name, rawQuery, _ := strings.Cut(target, "?")
+	query, err := url.ParseQuery(rawQuery)
+	parts := strings.Split(name, ".")
+	if err != nil || len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return nil, fmt.Errorf("invalid BigQuery sink %q: want bigquery:PROJECT.DATASET.TABLE", target)
+	}
+	b := &BigQuery{
+		Project:  parts[0],
+		Dataset:  parts[1],
+		Table:    parts[2],
+		Mode:     "load",
+		Location: query.Get("location"),
+		Endpoint: bigQueryEndpoint,
+		Attempts: DefaultUploadAttempts,
+		Client:   &http.Client{Timeout: 5 * time.Minute},
+	}
+	switch mode := query.Get("mode"); mode {
+	case "", "load":
+	case "stream":
+		b.Mode = mode
+	default:
+		return nil, fmt.Errorf("invalid mode %q in %s: want load or stream", mode, target)
+	}
+	if endpoint := os.Getenv("BIGQUERY_ENDPOINT"); endpoint != "" {
+		b.Endpoint = strings.TrimSuffix(endpoint, "/")
+	}
+	return b, nil
+}
+
+func (b *BigQuery) String() string {
+	return fmt.Sprintf("bigquery:%s.%s.%s (%s)", b.Project, b.Dataset, b.Table, b.Mode)
+}
+
+// Link returns the table in the BigQuery console
+func (b *BigQuery) Link() string {
+	return fmt.Sprintf("https://console.cloud.google.com/bigquery?p=%s&d=%s&t=%s&page=table",
+		url.QueryEscape(b.Project), url.QueryEscape(b.Dataset), url.QueryEscape(b.Table))
+}
+
+// Write loads assets
+func (b *BigQuery) Write(assets []domain.Asset) error {
+	return b.WriteRows(Assets(assets))
+}
+
+// bigQueryField is a column of a table schema
+type bigQueryField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Mode string `json:"mode,omitempty"`
+}
+
+// WriteRows encodes rows as newline-delimited JSON while collecting the
+// columns they use, brings the table's schema up to date and writes them
+func (b *BigQuery) WriteRows(rows Rows) error {
+	if b.tokens == nil {
+		account, err := gauth.ServiceAccountFromEnv()
+		if err != nil {
+			return err
+		}
+		b.tokens = account.TokenSource(b.Client, bigQueryScope)
+	}
+
+	now := time.Now().UTC()
+	date := now.Format("2006-01-02")
+	var data bytes.Buffer
+	var records []map[string]json.RawMessage
+	columns := map[string]string{
+		"snapshot_date": "DATE",
+		"rank":          "INTEGER",
+		"collected_at":  "TIMESTAMP",
+	}
+	rank := 0
+	if err := rows.Each(func(asset domain.Asset) error {
+		rank++
+		encoded, err := json.Marshal(schema.Asset{Asset: asset, SchemaVersion: schema.Version})
+		if err != nil {
+			return err
+		}
+		var record map[string]json.RawMessage
+		if err := json.Unmarshal(encoded, &record); err != nil {
+			return err
+		}
+		for name, value := range record {
+			if string(value) == "null" {
+				delete(record, name)
+				continue
+			}
+			if _, known := columns[name]; !known {
+				columns[name] = bigQueryType(name, value)
+			}
+		}
+		record["snapshot_date"], _ = json.Marshal(date)
+		record["rank"], _ = json.Marshal(rank)
+		record["collected_at"], _ = json.Marshal(now.Format(time.RFC3339))
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		data.Write(line)
+		data.WriteByte('\n')
+		if b.Mode == "stream" {
+			records = append(records, record)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if rank == 0 {
+		return errors.New("no rows to load")
+	}
+
+	if err := b.migrate(columns); err != nil {
+		return err
+	}
+	if b.Mode == "stream" {
+		return b.stream(records, date)
+	}
+	return b.load(data.Bytes(), now)
+}
+
+// bigQueryType is the column type for a field with the given JSON value
+func bigQueryType(name string, value json.RawMessage) string {
+	if kind, ok := bigQueryTypes[name]; ok {
+		return kind
+	}
+	switch value[0] {
+	case '"':
+		return "STRING"
+	case 't', 'f':
+		return "BOOLEAN"
+	case '{', '[':
+		return "JSON"
+	default:
+		return "FLOAT"
+	}
+}
+
+// migrate creates the table, or adds the columns it lacks
+func (b *BigQuery) migrate(columns map[string]string) error {
+	names := make([]string, 0, len(columns))
+	for name := range columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var table struct {
+		Schema struct {
+			Fields []json.RawMessage `json:"fields"`
+		} `json:"schema"`
+	}
+	err := b.call(http.MethodGet, b.tablePath(), nil, &table)
+	var status *uploadStatusError
+	if errors.As(err, &status) && status.code == http.StatusNotFound {
+		fields := make([]bigQueryField, 0, len(names))
+		for _, name := range []string{"snapshot_date", "rank", "ticker"} {
+			fields = append(fields, bigQueryField{Name: name, Type: columns[name], Mode: "NULLABLE"})
+		}
+		for _, name := range names {
+			if name != "snapshot_date" && name != "rank" && name != "ticker" {
+				fields = append(fields, bigQueryField{Name: name, Type: columns[name], Mode: "NULLABLE"})
+			}
+		}
+		create := map[string]any{
+			"tableReference":   map[string]string{"projectId": b.Project, "datasetId": b.Dataset, "tableId": b.Table},
+			"schema":           map[string]any{"fields": fields},
+			"timePartitioning": map[string]string{"type": "DAY", "field": "snapshot_date"},
+			"clustering":       map[string][]string{"fields": {"ticker"}},
+		}
+		path := fmt.Sprintf("/bigquery/v2/projects/%s/datasets/%s/tables", url.PathEscape(b.Project), url.PathEscape(b.Dataset))
+		if err := b.call(http.MethodPost, path, create, nil); err != nil {
+			return fmt.Errorf("create table: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read table: %w", err)
+	}
+
+	// Existing fields are sent back as they were, so their descriptions and
+	// policy tags survive the patch
+	have := make(map[string]bool, len(table.Schema.Fields))
+	for _, raw := range table.Schema.Fields {
+		var field bigQueryField
+		if err := json.Unmarshal(raw, &field); err != nil {
+			return err
+		}
+		have[field.Name] = true
+	}
+	fields := table.Schema.Fields
+	var added []string
+	for _, name := range names {
+		if have[name] {
+			continue
+		}
+		raw, err := json.Marshal(bigQueryField{Name: name, Type: columns[name], Mode: "NULLABLE"})
+		if err != nil {
+			return err
+		}
+		fields = append(fields, raw)
+		added = append(added, name)
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	if err := b.call(http.MethodPatch, b.tablePath(), map[string]any{"schema": map[string]any{"fields": fields}}, nil); err != nil {
+		return fmt.Errorf("add columns %s: %w", strings.Join(added, ", "), err)
+	}
+	return nil
+}
+
+// load replaces the day's partition with data in one load job and waits
+// for it to finish
+func (b *BigQuery) load(data []byte, now time.Time) error {
+	jobID := fmt.Sprintf("algotradar_%s_%d", b.Table, now.UnixNano())
+	reference := map[string]string{"projectId": b.Project, "jobId": jobID}
+	if b.Location != "" {
+		reference["location"] = b.Location
+	}
+	config := map[string]any{
+		"jobReference": reference,
+		"configuration": map[string]any{
+			"load": map[string]any{
+				"destinationTable": map[string]string{
+					"projectId": b.Project,
+					"datasetId": b.Dataset,
+					"tableId":   b.Table + "$" + now.Format("20060102"),
+				},
+				"sourceFormat":      "NEWLINE_DELIMITED_JSON",
+				"writeDisposition":  "WRITE_TRUNCATE",
+				"createDisposition": "CREATE_NEVER",
+			},
+		},
+	}
+	metadata, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"application/json; charset=UTF-8", metadata},
+		{"application/octet-stream", data},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return err
+		}
+		w.Write(part.content)
+	}
+	if err := parts.Close(); err != nil {
+		return err
+	}
+
+	var job bigQueryJob
+	path := fmt.Sprintf("/upload/bigquery/v2/projects/%s/jobs?uploadType=multipart", url.PathEscape(b.Project))
+	err = b.send(http.MethodPost, path, "multipart/related; boundary="+parts.Boundary(), body.Bytes(), &job)
+	var status *uploadStatusError
+	// A retried insert that already created the job is answered with 409
+	if errors.As(err, &status) && status.code == http.StatusConflict {
+		err = nil
+		job.JobReference.JobID, job.JobReference.Location = jobID, b.Location
+	}
+	if err != nil {
+		return fmt.Errorf("start load job: %w", err)
+	}
+
+	for job.Status.State != "DONE" {
+		time.Sleep(2 * time.Second)
+		query := url.Values{}
+		if job.JobReference.Location != "" {
+			query.Set("location", job.JobReference.Location)
+		}
+		path := fmt.Sprintf("/bigquery/v2/projects/%s/jobs/%s?%s", url.PathEscape(b.Project), url.PathEscape(job.JobReference.JobID), query.Encode())
+		if err := b.call(http.MethodGet, path, nil, &job); err != nil {
+			return fmt.Errorf("check load job %s: %w", job.JobReference.JobID, err)
+		}
+	}
+	if job.Status.ErrorResult != nil {
+		message := job.Status.ErrorResult.Message
+		if len(job.Status.Errors) > 0 && job.Status.Errors[0].Message != message {
+			message += ": " + job.Status.Errors[0].Message
+		}
+		return fmt.Errorf("load job %s failed: %s", job.JobReference.JobID, message)
+	}
+	return nil
+}
+
+// bigQueryJob is the part of a job resource the sink reads
+type bigQueryJob struct {
+	JobReference struct {
+		JobID    string `json:"jobId"`
+		Location string `json:"location"`
+	} `json:"jobReference"`
+	Status struct {
+		State       string           `json:"state"`
+		ErrorResult *bigQueryError   `json:"errorResult"`
+		Errors      []*bigQueryError `json:"errors"`
+	} `json:"status"`
+}
+
+type bigQueryError struct {
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// stream sends records with streaming inserts. Each row's insert ID is its
+// date and ticker, so a retried request is not stored twice.
+func (b *BigQuery) stream(records []map[string]json.RawMessage, date string) error {
+	for start := 0; start < len(records); start += bigQueryStreamBatch {
+		batch := records[start:min(start+bigQueryStreamBatch, len(records))]
+		rows := make([]map[string]any, len(batch))
+		for i, record := range batch {
+			var ticker string
+			json.Unmarshal(record["ticker"], &ticker)
+			rows[i] = map[string]any{"insertId": date + "|" + ticker, "json": record}
+		}
+		var out struct {
+			InsertErrors []struct {
+				Index  int              `json:"index"`
+				Errors []*bigQueryError `json:"errors"`
+			} `json:"insertErrors"`
+		}
+		if err := b.call(http.MethodPost, b.tablePath()+"/insertAll", map[string]any{"rows": rows}, &out); err != nil {
+			return fmt.Errorf("stream rows: %w", err)
+		}
+		if len(out.InsertErrors) > 0 {
+			first := out.InsertErrors[0]
+			reason := "rejected"
+			if len(first.Errors) > 0 {
+				reason = first.Errors[0].Message
+			}
+			return fmt.Errorf("BigQuery rejected %d rows, e.g. row %d: %s", len(out.InsertErrors), start+first.Index+1, reason)
+		}
+	}
+	return nil
+}
+
+func (b *BigQuery) tablePath() string {
+	return fmt.Sprintf("/bigquery/v2/projects/%s/datasets/%s/tables/%s",
+		url.PathEscape(b.Project), url.PathEscape(b.Dataset), url.PathEscape(b.Table))
+}
+
+// call sends one JSON request, retrying like the other Google sinks
+func (b *BigQuery) call(method, path string, in, out any) error {
+	var payload []byte
+	if in != nil {
+		var err error
+		if payload, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+	return b.send(method, path, "application/json", payload, out)
+}
+
+// send sends one request, retrying with exponential backoff on network
+// errors, 429 and 5xx responses, and decodes the response into out when it
+// is not nil
+func (b *BigQuery) send(method, path, contentType string, payload []byte, out any) error {
+	attempts := max(b.Attempts, 1)
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = b.sendOnce(method, b.Endpoint+path, contentType, payload, out)
+		var status *uploadStatusError
+		retryable := !errors.As(err, &status) || status.retryable()
+		if err == nil || !retryable || attempt == attempts {
+			return err
+		}
+		time.Sleep(time.Duration(1<<(attempt-1)) * time.Second)
+	}
+}
+
+func (b *BigQuery) sendOnce(method, endpoint, contentType string, payload []byte, out any) error {
+	token, err := b.tokens.Token()
+	if err != nil {
+		return err
+	}
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if payload != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := b.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &uploadStatusError{code: resp.StatusCode, msg: strings.TrimSpace(string(msg))}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,95 @@
+package sink
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"algotradar/assetsrpc"
+	"algotradar/domain"
+	"algotradar/schema"
+)
+
+const (
+	// DefaultBrokerBatch is how many rows a message broker sink sends at once
+	DefaultBrokerBatch = 500
+	// DefaultBrokerTimeout bounds one broker sink write
+	DefaultBrokerTimeout = 2 * time.Minute
+)
+
+// brokerOptions are the query options shared by the message broker sinks:
+// format=json|protobuf selects the payload and batch sets how many rows are
+// sent at once
+type brokerOptions struct {
+	Format string
+	Batch  int
+}
+
+func parseBrokerOptions(query url.Values, spec string) (brokerOptions, error) {
+	opts := brokerOptions{Format: "json", Batch: DefaultBrokerBatch}
+	switch format := query.Get("format"); format {
+	case "", "json":
+	case "protobuf":
+		opts.Format = format
+	default:
+		return opts, fmt.Errorf("invalid format %q in %s: want json or protobuf", format, spec)
+	}
+	if raw := query.Get("batch"); raw != "" {
+		var err error
+		if opts.Batch, err = strconv.Atoi(raw); err != nil || opts.Batch < 1 {
+			return opts, fmt.Errorf("invalid batch %q in %s", raw, spec)
+		}
+	}
+	return opts, nil
+}
+
+// contentType is the MIME type of the payloads in format
+func (o brokerOptions) contentType() string {
+	if o.Format == "protobuf" {
+		return "application/x-protobuf"
+	}
+	return "application/json"
+}
+
+// encode returns one row's message payload: the row as the JSON sink writes
+// it, or the algotradar.assets.v1.Asset message of the gRPC API
+func (o brokerOptions) encode(asset domain.Asset) ([]byte, error) {
+	if o.Format == "protobuf" {
+		return proto.Marshal(assetsrpc.ToProto(asset))
+	}
+	return json.Marshal(schema.Asset{Asset: asset, SchemaVersion: schema.Version})
+}
+
+// eachBatch reads rows in batches of size, calling send with each batch's
+// encoded payloads and the rows they came from
+func (o brokerOptions) eachBatch(rows Rows, send func(assets []domain.Asset, payloads [][]byte) error) error {
+	assets := make([]domain.Asset, 0, o.Batch)
+	payloads := make([][]byte, 0, o.Batch)
+	flush := func() error {
+		if len(assets) == 0 {
+			return nil
+		}
+		err := send(assets, payloads)
+		assets, payloads = assets[:0], payloads[:0]
+		return err
+	}
+	if err := rows.Each(func(asset domain.Asset) error {
+		payload, err := o.encode(asset)
+		if err != nil {
+			return fmt.Errorf("encode %s: %w", asset.Ticker, err)
+		}
+		assets = append(assets, asset)
+		payloads = append(payloads, payload)
+		if len(assets) == o.Batch {
+			return flush()
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return flush()
+}
@@ -0,0 +1,302 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"algotradar/domain"
+	"algotradar/schema"
+)
+
+const (
+	// DefaultClickHouseDatabase and DefaultClickHouseTable locate the
+	// ClickHouse history table
+	DefaultClickHouseDatabase = "algotradar"
+	DefaultClickHouseTable    = "asset_snapshots"
+
+	clickhousePort = "8123"
+)
+
+// Migrator is implemented by sinks whose schema is created by
+// `datacollect migrate` rather than on first write
+type Migrator interface {
+	OutputSink
+	// MigrationSQL returns the statements that create or update the schema;
+	// each can be run again safely
+	MigrationSQL() []string
+	Migrate(ctx context.Context) error
+}
+
+// clickhouseColumn is a column of the history table and how a row fills it
+type clickhouseColumn struct {
+	name  string
+	kind  string
+	value func(row clickhouseRow) any
+}
+
+// clickhouseRow is one asset with its place in the snapshot
+type clickhouseRow struct {
+	asset     domain.Asset
+	rank      int
+	date      string
+	collected string
+}
+
+// clickhouseColumns is the history table. Columns added later go at the end
+// and are listed in clickhouseAddedColumns, which MigrationSQL turns into
+// ALTER TABLE ... ADD COLUMN IF NOT EXISTS so `datacollect migrate` brings
+// existing tables up to date.
+var clickhouseColumns = []clickhouseColumn{
+	{"snapshot_date", "Date", func(r clickhouseRow) any { return r.date }},
+	{"symbol", "String", func(r clickhouseRow) any { return r.asset.Ticker }},
+	{"rank_position", "UInt32", func(r clickhouseRow) any { return r.rank }},
+	{"name", "String", func(r clickhouseRow) any { return r.asset.Name }},
+	{"market_cap", "Float64", func(r clickhouseRow) any { return r.asset.MarketCap }},
+	{"current_price", "Float64", func(r clickhouseRow) any { return r.asset.CurrentPrice }},
+	{"previous_close", "Float64", func(r clickhouseRow) any { return r.asset.PreviousClose }},
+	{"percentage_change", "Float64", func(r clickhouseRow) any { return r.asset.PercentageChange }},
+	{"volume", "Float64", func(r clickhouseRow) any { return r.asset.Volume }},
+	{"primary_exchange", "LowCardinality(String)", func(r clickhouseRow) any { return r.asset.PrimaryExchange }},
+	{"country", "LowCardinality(String)", func(r clickhouseRow) any { return r.asset.Country }},
+	{"sector", "LowCardinality(String)", func(r clickhouseRow) any { return r.asset.Sector }},
+	{"industry", "LowCardinality(String)", func(r clickhouseRow) any { return r.asset.Industry }},
+	{"asset_type", "LowCardinality(String)", func(r clickhouseRow) any { return r.asset.AssetType }},
+	{"currency", "LowCardinality(String)", func(r clickhouseRow) any { return r.asset.Currency }},
+	{"dividend_yield", "Nullable(Float64)", func(r clickhouseRow) any { return r.asset.DividendYield }},
+	{"sector_normalized", "LowCardinality(String)", func(r clickhouseRow) any { return r.asset.SectorNormalized }},
+	{"industry_normalized", "LowCardinality(String)", func(r clickhouseRow) any { return r.asset.IndustryNormalized }},
+	{"mic", "LowCardinality(String)", func(r clickhouseRow) any { return r.asset.MIC }},
+	{"is_market_open", "Nullable(Bool)", func(r clickhouseRow) any { return r.asset.IsMarketOpen }},
+	{"quote_session", "LowCardinality(String)", func(r clickhouseRow) any { return r.asset.QuoteSession }},
+	{"quote_timestamp_utc", "Nullable(DateTime('UTC'))", func(r clickhouseRow) any { return clickhouseTime(r.asset.QuoteTime) }},
+	{"shares_outstanding", "Nullable(Float64)", func(r clickhouseRow) any { return nullableNumber(r.asset.SharesOutstanding) }},
+	{"float_shares", "Nullable(Float64)", func(r clickhouseRow) any { return nullableNumber(r.asset.FloatShares) }},
+	{"free_float_market_cap", "Nullable(Float64)", func(r clickhouseRow) any { return nullableNumber(r.asset.FreeFloatMarketCap) }},
+	{"listing_date", "Nullable(Date)", func(r clickhouseRow) any { return nullableString(r.asset.ListingDate) }},
+	{"collected_at", "DateTime('UTC')", func(r clickhouseRow) any { return r.collected }},
+	{"schema_version", "UInt16", func(r clickhouseRow) any { return schema.Version }},
+	{"instrument_type", "LowCardinality(String)", func(r clickhouseRow) any { return r.asset.InstrumentType }},
+	{"share_class", "LowCardinality(String)", func(r clickhouseRow) any { return r.asset.ShareClass }},
+	{"local_currency", "LowCardinality(String)", func(r clickhouseRow) any { return r.asset.LocalCurrency }},
+	{"fx_rate_used", "Nullable(Float64)", func(r clickhouseRow) any { return nullableNumber(r.asset.FXRateUsed) }},
+	{"fx_rate_source", "LowCardinality(String)", func(r clickhouseRow) any { return r.asset.FXRateSource }},
+	{"fx_rate_timestamp", "Nullable(DateTime('UTC'))", func(r clickhouseRow) any { return clickhouseTime(r.asset.FXRateTime) }},
+	{"cap_tier", "LowCardinality(String)", func(r clickhouseRow) any { return r.asset.CapTier }},
+}
+
+// clickhouseAddedColumns are the columns appended to clickhouseColumns after
+// its first release
+var clickhouseAddedColumns = []string{"instrument_type", "share_class", "local_currency", "fx_rate_used", "fx_rate_source", "fx_rate_timestamp", "cap_tier"}
+
+// ClickHouse appends each run to a history table over the ClickHouse HTTP
+// interface. The table is a ReplacingMergeTree partitioned by the month of
+// snapshot_date and ordered by (snapshot_date, symbol): a rerun on the same
+// UTC day adds rows that replace that day's earlier ones when parts merge,
+// so queries that must not see both use FINAL. The table is created by
+// `datacollect migrate`, not by the sink. Credentials come from
+// CLICKHOUSE_USER and CLICKHOUSE_PASSWORD.
+//
+// Spec: clickhouse://HOST[:PORT][/DATABASE]?table=asset_snapshots&secure=true
+type ClickHouse struct {
+	Endpoint string
+	Database string
+	Table    string
+	Client   *http.Client
+
+	user     string
+	password string
+}
+
+// NewClickHouse parses a clickhouse:// URL
+func NewClickHouse(rawURL string) (*ClickHouse, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "clickhouse" || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid ClickHouse sink %q: want clickhouse://HOST[:PORT][/DATABASE]", rawURL)
+	}
+	query := u.Query()
+	scheme := "http"
+	switch raw := query.Get("secure"); raw {
+	case "", "false":
+	case "true":
+		scheme = "https"
+	default:
+		return nil, fmt.Errorf("invalid secure %q in %s: want true or false", raw, rawURL)
+	}
+	port := u.Port()
+	if port == "" {
+		port = clickhousePort
+	}
+	c := &ClickHouse{
+		Endpoint: scheme + "://" + u.Hostname() + ":" + port,
+		Database: DefaultClickHouseDatabase,
+		Table:    DefaultClickHouseTable,
+		Client:   &http.Client{Timeout: dbTimeout},
+		user:     os.Getenv("CLICKHOUSE_USER"),
+		password: os.Getenv("CLICKHOUSE_PASSWORD"),
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		c.Database = db
+	}
+	if table := query.Get("table"); table != "" {
+		c.Table = table
+	}
+	for _, name := range []string{c.Database, c.Table} {
+		if !clickhouseIdentifier(name) {
+			return nil, fmt.Errorf("invalid ClickHouse name %q in %s: use letters, digits and underscores", name, rawURL)
+		}
+	}
+	return c, nil
+}
+
+func (c *ClickHouse) String() string {
+	return fmt.Sprintf("clickhouse:%s (%s.%s)", c.Endpoint, c.Database, c.Table)
+}
+
+// MigrationSQL creates the database and table
+func (c *ClickHouse) MigrationSQL() []string {
+	table := c.Database + "." + c.Table
+	columns := make([]string, len(clickhouseColumns))
+	kinds := make(map[string]string, len(clickhouseColumns))
+	for i, column := range clickhouseColumns {
+		columns[i] = "    " + column.name + " " + column.kind
+		kinds[column.name] = column.kind
+	}
+	statements := []string{
+		"CREATE DATABASE IF NOT EXISTS " + c.Database,
+		"CREATE TABLE IF NOT EXISTS " + table + " (\n" + strings.Join(columns, ",\n") + "\n)\n" +
+			"ENGINE = ReplacingMergeTree(collected_at)\n" +
+			"PARTITION BY toYYYYMM(snapshot_date)\n" +
+			"ORDER BY (snapshot_date, symbol)",
+	}
+	for _, name := range clickhouseAddedColumns {
+		statements = append(statements, "ALTER TABLE "+table+" ADD COLUMN IF NOT EXISTS "+name+" "+kinds[name])
+	}
+	return statements
+}
+
+// Migrate runs the MigrationSQL
+func (c *ClickHouse) Migrate(ctx context.Context) error {
+	for _, statement := range c.MigrationSQL() {
+		if err := c.exec(ctx, statement, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write appends assets
+func (c *ClickHouse) Write(assets []domain.Asset) error {
+	return c.WriteRows(Assets(assets))
+}
+
+// WriteRows streams rows to the table in one INSERT, so a run lands whole
+// or not at all
+func (c *ClickHouse) WriteRows(rows Rows) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	names := make([]string, len(clickhouseColumns))
+	for i, column := range clickhouseColumns {
+		names[i] = column.name
+	}
+	insert := fmt.Sprintf("INSERT INTO %s.%s (%s) FORMAT JSONEachRow", c.Database, c.Table, strings.Join(names, ", "))
+
+	now := time.Now().UTC()
+	date, collected := now.Format("2006-01-02"), now.Format("2006-01-02 15:04:05")
+	reader, writer := io.Pipe()
+	go func() {
+		buffered := bufio.NewWriter(writer)
+		encoder := json.NewEncoder(buffered)
+		rank := 0
+		err := rows.Each(func(asset domain.Asset) error {
+			rank++
+			row := clickhouseRow{asset: asset, rank: rank, date: date, collected: collected}
+			values := make(map[string]any, len(clickhouseColumns))
+			for _, column := range clickhouseColumns {
+				values[column.name] = column.value(row)
+			}
+			return encoder.Encode(values)
+		})
+		if err == nil {
+			err = buffered.Flush()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	err := c.exec(ctx, insert, reader)
+	reader.Close()
+	if err != nil && strings.Contains(err.Error(), "UNKNOWN_TABLE") {
+		return fmt.Errorf("%w (create the table with `datacollect migrate --sink clickhouse://...`)", err)
+	}
+	return err
+}
+
+// exec runs one statement, with body as its input data when not nil
+func (c *ClickHouse) exec(ctx context.Context, statement string, body io.Reader) error {
+	params := url.Values{"query": {statement}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint+"/?"+params.Encode(), body)
+	if err != nil {
+		return err
+	}
+	if c.user != "" {
+		req.Header.Set("X-ClickHouse-User", c.user)
+	}
+	if c.password != "" {
+		req.Header.Set("X-ClickHouse-Key", c.password)
+	}
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ClickHouse answered %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// clickhouseIdentifier reports whether name can be used unquoted
+func clickhouseIdentifier(name string) bool {
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		return false
+	}
+	for _, r := range name {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// clickhouseTime formats a time as a ClickHouse DateTime, or null
+func clickhouseTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// nullableNumber stores an unknown (zero) count or amount as null
+func nullableNumber(v float64) any {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
+
+// nullableString stores an empty string as null
+func nullableString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,449 @@
+package sink
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"algotradar/canonical"
+	"algotradar/domain"
+	"algotradar/schema"
+)
+
+// encoder serializes a snapshot in one file format
+type encoder func(w io.Writer, assets []domain.Asset) error
+
+var encoders = map[string]encoder{
+	"json":     EncodeJSON,
+	"csv":      EncodeCSV,
+	"parquet":  EncodeParquet,
+	"supabase": EncodeSupabase,
+	"xlsx":     EncodeXLSX,
+}
+
+// encoderForPath picks a format from a file extension, defaulting to JSON
+func encoderForPath(path string) (string, encoder) {
+	switch {
+	case strings.HasSuffix(path, ".csv"):
+		return "csv", EncodeCSV
+	case strings.HasSuffix(path, ".parquet"):
+		return "parquet", EncodeParquet
+	case strings.HasSuffix(path, ".xlsx"):
+		return "xlsx", EncodeXLSX
+	}
+	return "json", EncodeJSON
+}
+
+// EncodeJSON writes the snapshot rows as a canonical, indented JSON array,
+// each tagged with the schema version
+func EncodeJSON(w io.Writer, assets []domain.Asset) error {
+	return canonical.Encode(w, schema.Assets(assets))
+}
+
+// EncodeCSV writes a ranked CSV with a UTF-8 BOM so spreadsheets pick the right encoding
+func EncodeCSV(w io.Writer, assets []domain.Asset) error {
+	writer, err := newCSVWriter(w)
+	if err != nil {
+		return err
+	}
+	for i, asset := range assets {
+		if err := writer.Write(csvRecord(i+1, asset)); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// newCSVWriter writes the BOM and header row of a snapshot CSV
+func newCSVWriter(w io.Writer) (*csv.Writer, error) {
+	if _, err := io.WriteString(w, "\xEF\xBB\xBF"); err != nil {
+		return nil, err
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+// csvHeader names the columns of csvRecord
+var csvHeader = []string{
+	"Rank", "Ticker", "Name", "Country", "Sector", "Industry",
+	"Market_Cap_USD", "Current_Price", "Previous_Close", "Percentage_Change",
+	"Volume", "Exchange", "Asset_Type", "Sector_Normalized", "Industry_Normalized", "Cap_Tier",
+	"MIC", "Exchange_Timezone", "Is_Market_Open", "Quote_Session", "Quote_Timestamp_UTC",
+	"Snapshot_Date", "Collected_At_UTC",
+	"Shares_Outstanding", "Float_Shares", "Free_Float_Market_Cap_USD",
+	"Year_High", "Year_Low", "Price_Avg_50", "Price_Avg_200", "YTD_Return",
+	"Institutional_Ownership_Pct", "Top10_Holder_Pct", "Institutional_Holders",
+	"Listing_Date", "Is_Recent_IPO", "Instrument_Type", "FFO_TTM", "FFO_Currency", "Price_To_FFO",
+	"Share_Class", "Share_Classes", "Local_Currency", "FX_Rate_Used", "FX_Rate_Source", "FX_Rate_Timestamp",
+	"Schema_Version",
+}
+
+// csvRecord is the CSV row of the asset ranked rank
+func csvRecord(rank int, asset domain.Asset) []string {
+	return []string{
+		fmt.Sprintf("%d", rank),
+		asset.Ticker,
+		CleanText(asset.Name),
+		asset.Country,
+		CleanText(asset.Sector),
+		CleanText(asset.Industry),
+		fmt.Sprintf("%.0f", asset.MarketCap),
+		fmt.Sprintf("%.2f", asset.CurrentPrice),
+		fmt.Sprintf("%.2f", asset.PreviousClose),
+		fmt.Sprintf("%.2f", asset.PercentageChange),
+		fmt.Sprintf("%.0f", asset.Volume),
+		asset.PrimaryExchange,
+		asset.AssetType,
+		asset.SectorNormalized,
+		asset.IndustryNormalized,
+		asset.CapTier,
+		asset.MIC,
+		asset.ExchangeTimezone,
+		formatOptionalBool(asset.IsMarketOpen),
+		asset.QuoteSession,
+		formatOptionalTime(asset.QuoteTime),
+		asset.SnapshotDate,
+		formatOptionalTime(asset.CollectedAt),
+		formatOptionalCount(asset.SharesOutstanding),
+		formatOptionalCount(asset.FloatShares),
+		formatOptionalCount(asset.FreeFloatMarketCap),
+		formatOptionalPrice(asset.YearHigh),
+		formatOptionalPrice(asset.YearLow),
+		formatOptionalPrice(asset.PriceAvg50),
+		formatOptionalPrice(asset.PriceAvg200),
+		formatOptionalPercent(asset.YTDReturn),
+		formatOptionalPrice(asset.InstitutionalOwnership),
+		formatOptionalPrice(asset.Top10HolderPct),
+		formatOptionalCount(float64(asset.InstitutionalHolders)),
+		asset.ListingDate,
+		formatRecentIPO(asset),
+		asset.InstrumentType,
+		formatOptionalCount(asset.FFO),
+		asset.FFOCurrency,
+		formatOptionalPrice(asset.PriceToFFO),
+		asset.ShareClass,
+		formatShareClasses(asset),
+		asset.LocalCurrency,
+		formatOptionalRate(asset.FXRateUsed),
+		asset.FXRateSource,
+		formatOptionalTime(asset.FXRateTime),
+		strconv.Itoa(schema.Version),
+	}
+}
+
+// spreadsheetRecord is csvRecord for spreadsheets: numbers stay numbers and
+// an unknown market-open flag is an empty cell
+func spreadsheetRecord(rank int, asset domain.Asset) []any {
+	var open any = ""
+	if asset.IsMarketOpen != nil {
+		open = *asset.IsMarketOpen
+	}
+	var recentIPO any = ""
+	if asset.ListingDate != "" {
+		recentIPO = asset.IsRecentIPO
+	}
+	return []any{
+		rank,
+		asset.Ticker,
+		CleanText(asset.Name),
+		asset.Country,
+		CleanText(asset.Sector),
+		CleanText(asset.Industry),
+		asset.MarketCap,
+		asset.CurrentPrice,
+		asset.PreviousClose,
+		asset.PercentageChange,
+		asset.Volume,
+		asset.PrimaryExchange,
+		asset.AssetType,
+		asset.SectorNormalized,
+		asset.IndustryNormalized,
+		asset.CapTier,
+		asset.MIC,
+		asset.ExchangeTimezone,
+		open,
+		asset.QuoteSession,
+		formatOptionalTime(asset.QuoteTime),
+		asset.SnapshotDate,
+		formatOptionalTime(asset.CollectedAt),
+		optionalCount(asset.SharesOutstanding),
+		optionalCount(asset.FloatShares),
+		optionalCount(asset.FreeFloatMarketCap),
+		optionalCount(asset.YearHigh),
+		optionalCount(asset.YearLow),
+		optionalCount(asset.PriceAvg50),
+		optionalCount(asset.PriceAvg200),
+		optionalPercent(asset.YTDReturn),
+		optionalCount(asset.InstitutionalOwnership),
+		optionalCount(asset.Top10HolderPct),
+		optionalCount(float64(asset.InstitutionalHolders)),
+		asset.ListingDate,
+		recentIPO,
+		asset.InstrumentType,
+		optionalCount(asset.FFO),
+		asset.FFOCurrency,
+		optionalCount(asset.PriceToFFO),
+		asset.ShareClass,
+		formatShareClasses(asset),
+		asset.LocalCurrency,
+		optionalCount(asset.FXRateUsed),
+		asset.FXRateSource,
+		formatOptionalTime(asset.FXRateTime),
+		schema.Version,
+	}
+}
+
+// formatOptionalBool renders a known flag as true or false and an unknown one
+// as an empty cell
+func formatOptionalBool(b *bool) string {
+	if b == nil {
+		return ""
+	}
+	return strconv.FormatBool(*b)
+}
+
+// formatRecentIPO renders the recent-IPO flag of a row with a listing date,
+// and an empty cell when the date, and with it the flag, is unknown
+func formatRecentIPO(asset domain.Asset) string {
+	if asset.ListingDate == "" {
+		return ""
+	}
+	return strconv.FormatBool(asset.IsRecentIPO)
+}
+
+// formatShareClasses renders the tickers an aggregated issuer row folds,
+// e.g. "GOOGL;GOOG", and an empty cell for a row of one class
+func formatShareClasses(asset domain.Asset) string {
+	tickers := make([]string, len(asset.ShareClasses))
+	for i, class := range asset.ShareClasses {
+		tickers[i] = class.Ticker
+	}
+	return strings.Join(tickers, ";")
+}
+
+// formatOptionalCount renders a known share count or amount as a whole
+// number and an unknown (zero) one as an empty cell
+func formatOptionalCount(v float64) string {
+	if v == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.0f", v)
+}
+
+// optionalCount is formatOptionalCount for spreadsheets, keeping numbers
+// numeric; it serves prices too, whose number format the sheet applies
+func optionalCount(v float64) any {
+	if v == 0 {
+		return ""
+	}
+	return v
+}
+
+// formatOptionalPrice renders a known price, or a percentage that is unknown
+// when zero, with two decimals and an unknown one as an empty cell
+func formatOptionalPrice(v float64) string {
+	if v == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%.2f", v)
+}
+
+// formatOptionalRate renders a known exchange rate at full precision, as
+// rates of currencies such as IDR are tiny, and an unknown one as an empty
+// cell
+func formatOptionalRate(v float64) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// formatOptionalPercent renders a known percentage with two decimals and an
+// unknown one as an empty cell
+func formatOptionalPercent(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%.2f", *v)
+}
+
+// optionalPercent is formatOptionalPercent for spreadsheets
+func optionalPercent(v *float64) any {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+// formatOptionalTime renders a known time as RFC 3339 UTC and an unknown one
+// as an empty cell
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// SupabaseRow is the row layout loaded into the Supabase assets table
+type SupabaseRow struct {
+	Symbol             string  `json:"symbol"`
+	Ticker             string  `json:"ticker"`
+	Name               string  `json:"name"`
+	CurrentPrice       float64 `json:"current_price"`
+	PreviousClose      float64 `json:"previous_close,omitempty"`
+	PercentageChange   float64 `json:"percentage_change,omitempty"`
+	MarketCap          int64   `json:"market_cap"`
+	Volume             int64   `json:"volume"`
+	PrimaryExchange    string  `json:"primary_exchange"`
+	Country            string  `json:"country"`
+	Sector             string  `json:"sector"`
+	Industry           string  `json:"industry"`
+	SectorNormalized   string  `json:"sector_normalized,omitempty"`
+	IndustryNormalized string  `json:"industry_normalized,omitempty"`
+	CapTier            string  `json:"cap_tier,omitempty"`
+	MIC                string  `json:"mic,omitempty"`
+	ExchangeTimezone   string  `json:"exchange_timezone,omitempty"`
+	IsMarketOpen       *bool   `json:"is_market_open,omitempty"`
+	QuoteSession       string  `json:"quote_session,omitempty"`
+	QuoteTimestampUTC  string  `json:"quote_timestamp_utc,omitempty"`
+	AssetType          string  `json:"asset_type"`
+	InstrumentType     string  `json:"instrument_type,omitempty"`
+	ShareClass         string  `json:"share_class,omitempty"`
+	Rank               int     `json:"rank"`
+	SnapshotDate       string  `json:"snapshot_date"`
+	CollectedAt        string  `json:"collected_at,omitempty"`
+	DataSource         string  `json:"data_source"`
+	PriceRaw           float64 `json:"price_raw,omitempty"`
+	MarketCapRaw       int64   `json:"market_cap_raw,omitempty"`
+	Category           string  `json:"category,omitempty"`
+	Image              string  `json:"image,omitempty"`
+	SharesOutstanding  int64   `json:"shares_outstanding,omitempty"`
+	FloatShares        int64   `json:"float_shares,omitempty"`
+	FreeFloatMarketCap int64   `json:"free_float_market_cap,omitempty"`
+	ExtendedHoursPrice float64 `json:"extended_hours_price,omitempty"`
+	ExtendedHoursChg   float64 `json:"extended_hours_change,omitempty"`
+	SchemaVersion      int     `json:"schema_version"`
+}
+
+// SupabaseRows converts ranked assets to Supabase rows, truncating text to the column limits
+func SupabaseRows(assets []domain.Asset) []SupabaseRow {
+	today := time.Now().UTC().Format(time.DateOnly)
+	rows := make([]SupabaseRow, len(assets))
+	for i, asset := range assets {
+		rows[i] = supabaseRow(i+1, asset, today)
+	}
+	return rows
+}
+
+// supabaseRow converts the asset ranked rank. Its snapshot date is the one
+// the collector assigned in the venue's timezone, or today (UTC) for assets
+// that have none.
+func supabaseRow(rank int, asset domain.Asset, today string) SupabaseRow {
+	snapshotDate := today
+	if asset.SnapshotDate != "" {
+		snapshotDate = asset.SnapshotDate
+	}
+	symbol := truncate(asset.Ticker, 50)
+	assetType := asset.AssetType
+	if assetType == "" {
+		assetType = "stock"
+	}
+
+	dataSource := "FMP"
+	if asset.DataSource != "" {
+		dataSource = strings.ToUpper(asset.DataSource)
+	}
+
+	return SupabaseRow{
+		Symbol:             symbol,
+		Ticker:             symbol,
+		Name:               truncate(asset.Name, 200),
+		CurrentPrice:       asset.CurrentPrice,
+		PreviousClose:      asset.PreviousClose,
+		PercentageChange:   asset.PercentageChange,
+		MarketCap:          int64(asset.MarketCap),
+		Volume:             int64(asset.Volume),
+		PrimaryExchange:    truncate(asset.PrimaryExchange, 50),
+		Country:            truncate(asset.Country, 50),
+		Sector:             truncate(asset.Sector, 100),
+		Industry:           truncate(asset.Industry, 100),
+		SectorNormalized:   truncate(asset.SectorNormalized, 100),
+		IndustryNormalized: truncate(asset.IndustryNormalized, 100),
+		CapTier:            asset.CapTier,
+		MIC:                truncate(asset.MIC, 10),
+		ExchangeTimezone:   truncate(asset.ExchangeTimezone, 50),
+		IsMarketOpen:       asset.IsMarketOpen,
+		QuoteSession:       asset.QuoteSession,
+		QuoteTimestampUTC:  formatOptionalTime(asset.QuoteTime),
+		AssetType:          assetType,
+		InstrumentType:     asset.InstrumentType,
+		ShareClass:         asset.ShareClass,
+		Rank:               rank,
+		SnapshotDate:       snapshotDate,
+		CollectedAt:        formatOptionalTime(asset.CollectedAt),
+		DataSource:         dataSource,
+		PriceRaw:           asset.CurrentPrice,
+		MarketCapRaw:       int64(asset.MarketCap),
+		Category:           category(assetType),
+		Image:              asset.Image,
+		SharesOutstanding:  int64(asset.SharesOutstanding),
+		FloatShares:        int64(asset.FloatShares),
+		FreeFloatMarketCap: int64(asset.FreeFloatMarketCap),
+		ExtendedHoursPrice: asset.ExtendedHoursPrice,
+		ExtendedHoursChg:   asset.ExtendedHoursChange,
+		SchemaVersion:      schema.Version,
+	}
+}
+
+// EncodeSupabase writes the snapshot as Supabase rows
+func EncodeSupabase(w io.Writer, assets []domain.Asset) error {
+	return canonical.Encode(w, SupabaseRows(assets))
+}
+
+func category(assetType string) string {
+	switch assetType {
+	case "crypto":
+		return "crypto"
+	case "commodity":
+		return "commodities"
+	}
+	return "stocks"
+}
+
+func truncate(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	return s[:maxLen]
+}
+
+// CleanText strips control characters and repairs common mis-decoded characters in names
+func CleanText(text string) string {
+	// Remove any null bytes
+	text = strings.ReplaceAll(text, "\x00", "")
+
+	// Fix common encoding issues where German characters appear as Chinese characters
+	text = strings.ReplaceAll(text, "羹", "ü")
+	text = strings.ReplaceAll(text, "脛", "ä")
+	text = strings.ReplaceAll(text, "枚", "ö")
+	text = strings.ReplaceAll(text, "脽", "ß")
+
+	// Remove only ASCII control characters, keep all international characters
+	var result strings.Builder
+	for _, r := range text {
+		if r < 32 || r == 127 {
+			continue
+		}
+		result.WriteRune(r)
+	}
+
+	return result.String()
+}
@@ -0,0 +1,244 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"algotradar/domain"
+	"algotradar/gauth"
+)
+
+const (
+	// DefaultSheetsTop is how many companies a Google Sheets sink publishes
+	DefaultSheetsTop = 100
+	// DefaultSheetName is the tab a Google Sheets sink writes to
+	DefaultSheetName = "Ranking"
+
+	sheetsEndpoint = "https://sheets.googleapis.com"
+	sheetsScope    = "https://www.googleapis.com/auth/spreadsheets"
+)
+
+// GoogleSheets replaces the contents of one tab of a spreadsheet with the top
+// of the ranking, using the CSV columns, through the Sheets API. It signs in
+// as the service account in GOOGLE_APPLICATION_CREDENTIALS, which the
+// spreadsheet must be shared with as an editor. The tab is created when
+// missing. Set SHEETS_ENDPOINT to use a different API host.
+//
+// Spec: gsheets:SPREADSHEET_ID?sheet=TAB&top=N (top=0 publishes every row).
+type GoogleSheets struct {
+	SpreadsheetID string
+	Sheet         string
+	Top           int
+	Endpoint      string
+	Attempts      int
+	Client        *http.Client
+
+	tokens *gauth.TokenSource
+}
+
+// NewGoogleSheets parses the target of a gsheets: spec
+func NewGoogleSheets(target string) (*GoogleSheets, error) {
+	id, rawQuery, _ := strings.Cut(target, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil || id == "" || strings.Contains(id, "/") {
+		return nil, fmt.Errorf("invalid Google Sheets sink %q: want gsheets:SPREADSHEET_ID?sheet=TAB&top=N", target)
+	}
+
+	s := &GoogleSheets{
+		SpreadsheetID: id,
+		Sheet:         DefaultSheetName,
+		Top:           DefaultSheetsTop,
+		Endpoint:      sheetsEndpoint,
+		Attempts:      DefaultUploadAttempts,
+		Client:        &http.Client{Timeout: 2 * time.Minute},
+	}
+	if sheet := query.Get("sheet"); sheet != "" {
+		s.Sheet = sheet
+	}
+	if raw := query.Get("top"); raw != "" {
+		if s.Top, err = strconv.Atoi(raw); err != nil || s.Top < 0 {
+			return nil, fmt.Errorf("invalid top %q in %s", raw, target)
+		}
+	}
+	if endpoint := os.Getenv("SHEETS_ENDPOINT"); endpoint != "" {
+		s.Endpoint = endpoint
+	}
+	return s, nil
+}
+
+func (s *GoogleSheets) String() string {
+	return fmt.Sprintf("gsheets:%s (%s)", s.SpreadsheetID, s.Sheet)
+}
+
+// Link returns the spreadsheet's address in the Sheets web app
+func (s *GoogleSheets) Link() string {
+	return "https://docs.google.com/spreadsheets/d/" + url.PathEscape(s.SpreadsheetID)
+}
+
+func (s *GoogleSheets) Write(assets []domain.Asset) error {
+	if s.tokens == nil {
+		account, err := gauth.ServiceAccountFromEnv()
+		if err != nil {
+			return err
+		}
+		s.tokens = account.TokenSource(s.Client, sheetsScope)
+	}
+
+	if s.Top > 0 && len(assets) > s.Top {
+		assets = assets[:s.Top]
+	}
+	values := make([][]any, 0, len(assets)+1)
+	header := make([]any, len(csvHeader))
+	for i, name := range csvHeader {
+		header[i] = name
+	}
+	values = append(values, header)
+	for i, asset := range assets {
+		values = append(values, spreadsheetRecord(i+1, asset))
+	}
+
+	if err := s.ensureSheet(); err != nil {
+		return err
+	}
+	// Clearing first drops the rows of a longer previous ranking
+	tab := quoteSheetName(s.Sheet)
+	if err := s.call(http.MethodPost, "/values/"+url.PathEscape(tab)+":clear", nil, struct{}{}, nil); err != nil {
+		return fmt.Errorf("failed to clear %s: %w", s.Sheet, err)
+	}
+	update := url.Values{"valueInputOption": {"RAW"}}
+	body := map[string]any{"range": tab + "!A1", "majorDimension": "ROWS", "values": values}
+	if err := s.call(http.MethodPut, "/values/"+url.PathEscape(tab+"!A1"), update, body, nil); err != nil {
+		return fmt.Errorf("failed to update %s: %w", s.Sheet, err)
+	}
+	return nil
+}
+
+// errEnoughRows stops reading rows once the top of the ranking is in
+var errEnoughRows = errors.New("enough rows")
+
+// WriteRows reads only the rows it publishes
+func (s *GoogleSheets) WriteRows(rows Rows) error {
+	limit := rows.Len()
+	if s.Top > 0 {
+		limit = min(s.Top, limit)
+	}
+	assets := make([]domain.Asset, 0, limit)
+	err := rows.Each(func(asset domain.Asset) error {
+		if len(assets) == limit {
+			return errEnoughRows
+		}
+		assets = append(assets, asset)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errEnoughRows) {
+		return err
+	}
+	return s.Write(assets)
+}
+
+// ensureSheet adds the tab unless the spreadsheet already has it
+func (s *GoogleSheets) ensureSheet() error {
+	var spreadsheet struct {
+		Sheets []struct {
+			Properties struct {
+				Title string `json:"title"`
+			} `json:"properties"`
+		} `json:"sheets"`
+	}
+	query := url.Values{"fields": {"sheets.properties.title"}}
+	if err := s.call(http.MethodGet, "", query, nil, &spreadsheet); err != nil {
+		return fmt.Errorf("failed to read spreadsheet %s: %w", s.SpreadsheetID, err)
+	}
+	for _, sheet := range spreadsheet.Sheets {
+		if sheet.Properties.Title == s.Sheet {
+			return nil
+		}
+	}
+
+	add := map[string]any{"requests": []any{
+		map[string]any{"addSheet": map[string]any{"properties": map[string]any{
+			"title":          s.Sheet,
+			"gridProperties": map[string]any{"frozenRowCount": 1},
+		}}},
+	}}
+	if err := s.call(http.MethodPost, ":batchUpdate", nil, add, nil); err != nil {
+		return fmt.Errorf("failed to add sheet %s: %w", s.Sheet, err)
+	}
+	return nil
+}
+
+// call sends one Sheets API request for the spreadsheet, retrying with
+// exponential backoff on network errors, 429 and 5xx responses, and decodes
+// the response into out when it is not nil
+func (s *GoogleSheets) call(method, path string, query url.Values, in, out any) error {
+	endpoint := fmt.Sprintf("%s/v4/spreadsheets/%s%s", strings.TrimSuffix(s.Endpoint, "/"), url.PathEscape(s.SpreadsheetID), path)
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	var payload []byte
+	if in != nil {
+		var err error
+		if payload, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	attempts := max(s.Attempts, 1)
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = s.send(method, endpoint, payload, out)
+		var status *uploadStatusError
+		retryable := !errors.As(err, &status) || status.retryable()
+		if err == nil || !retryable || attempt == attempts {
+			return err
+		}
+		time.Sleep(time.Duration(1<<(attempt-1)) * time.Second)
+	}
+}
+
+func (s *GoogleSheets) send(method, endpoint string, payload []byte, out any) error {
+	token, err := s.tokens.Token()
+	if err != nil {
+		return err
+	}
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &uploadStatusError{code: resp.StatusCode, msg: strings.TrimSpace(string(msg))}
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// quoteSheetName quotes a tab name for A1 notation, e.g. 'Top 100'
+func quoteSheetName(name string) string {
+	return "'" + strings.ReplaceAll(name, "'", "''") + "'"
+}
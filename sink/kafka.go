@@ -0,0 +1,127 @@
+package sink
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+
+	"algotradar/domain"
+)
+
+// Kafka publishes each row as one message to a Kafka topic, keyed by ticker
+// so every update of a company lands on the same partition in order. The
+// payload is the row as JSON or as the gRPC API's Asset protobuf, named by a
+// content-type header. Set KAFKA_USERNAME and KAFKA_PASSWORD to sign in with
+// SASL (KAFKA_SASL_MECHANISM picks PLAIN, the default, SCRAM-SHA-256 or
+// SCRAM-SHA-512).
+//
+// Spec: kafka://BROKER[,BROKER...]/TOPIC?format=json|protobuf&batch=N&tls=true
+type Kafka struct {
+	Brokers []string
+	Topic   string
+	TLS     bool
+	Timeout time.Duration
+
+	opts brokerOptions
+}
+
+// NewKafka parses a kafka:// URL
+func NewKafka(rawURL string) (*Kafka, error) {
+	u, err := url.Parse(rawURL)
+	topic := ""
+	if err == nil {
+		topic = strings.Trim(u.Path, "/")
+	}
+	if err != nil || u.Host == "" || topic == "" || strings.Contains(topic, "/") {
+		return nil, fmt.Errorf("invalid Kafka sink %q: want kafka://BROKER[,BROKER]/TOPIC", rawURL)
+	}
+	opts, err := parseBrokerOptions(u.Query(), rawURL)
+	if err != nil {
+		return nil, err
+	}
+	k := &Kafka{Topic: topic, Timeout: DefaultBrokerTimeout, opts: opts}
+	for _, broker := range strings.Split(u.Host, ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			k.Brokers = append(k.Brokers, broker)
+		}
+	}
+	switch raw := u.Query().Get("tls"); raw {
+	case "", "false":
+	case "true":
+		k.TLS = true
+	default:
+		return nil, fmt.Errorf("invalid tls %q in %s: want true or false", raw, rawURL)
+	}
+	return k, nil
+}
+
+func (k *Kafka) String() string {
+	return fmt.Sprintf("kafka://%s/%s (%s)", strings.Join(k.Brokers, ","), k.Topic, k.opts.Format)
+}
+
+// Write publishes assets
+func (k *Kafka) Write(assets []domain.Asset) error {
+	return k.WriteRows(Assets(assets))
+}
+
+// WriteRows publishes rows a batch at a time as they are read
+func (k *Kafka) WriteRows(rows Rows) error {
+	transport := &kafka.Transport{ClientID: "algotradar"}
+	if k.TLS {
+		transport.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if user := os.Getenv("KAFKA_USERNAME"); user != "" {
+		mechanism, err := kafkaSASL(os.Getenv("KAFKA_SASL_MECHANISM"), user, os.Getenv("KAFKA_PASSWORD"))
+		if err != nil {
+			return err
+		}
+		transport.SASL = mechanism
+	}
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(k.Brokers...),
+		Topic:        k.Topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchSize:    k.opts.Batch,
+		BatchTimeout: 10 * time.Millisecond,
+		Transport:    transport,
+	}
+	defer writer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), k.Timeout)
+	defer cancel()
+	headers := []kafka.Header{{Key: "content-type", Value: []byte(k.opts.contentType())}}
+	return k.opts.eachBatch(rows, func(assets []domain.Asset, payloads [][]byte) error {
+		messages := make([]kafka.Message, len(assets))
+		for i, asset := range assets {
+			messages[i] = kafka.Message{Key: []byte(asset.Ticker), Value: payloads[i], Headers: headers}
+		}
+		if err := writer.WriteMessages(ctx, messages...); err != nil {
+			return fmt.Errorf("publish to %s: %w", k.Topic, err)
+		}
+		return nil
+	})
+}
+
+// kafkaSASL returns the SASL mechanism named by KAFKA_SASL_MECHANISM
+func kafkaSASL(name, user, password string) (sasl.Mechanism, error) {
+	switch strings.ToUpper(name) {
+	case "", "PLAIN":
+		return plain.Mechanism{Username: user, Password: password}, nil
+	case "SCRAM-SHA-256":
+		return scram.Mechanism(scram.SHA256, user, password)
+	case "SCRAM-SHA-512":
+		return scram.Mechanism(scram.SHA512, user, password)
+	default:
+		return nil, fmt.Errorf("unknown KAFKA_SASL_MECHANISM %q: want PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512", name)
+	}
+}
@@ -0,0 +1,183 @@
+package sink
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"algotradar/domain"
+)
+
+// natsPort is the NATS client port
+const natsPort = "4222"
+
+// NATS publishes each row as one message to a NATS subject over the plain
+// client protocol. "{ticker}" in the subject expands to the row's ticker, so
+// consumers can subscribe to a wildcard like assets.*. Every batch is
+// confirmed with a round trip to the server, which fails the write if the
+// server rejected anything. Credentials come from NATS_TOKEN, or NATS_USER
+// and NATS_PASSWORD; TLS is used when the server requires it.
+//
+// Spec: nats://HOST[:PORT]/SUBJECT?format=json|protobuf&batch=N
+type NATS struct {
+	Addr    string
+	Subject string
+	Timeout time.Duration
+
+	opts brokerOptions
+}
+
+// NewNATS parses a nats:// URL
+func NewNATS(rawURL string) (*NATS, error) {
+	u, err := url.Parse(rawURL)
+	subject := ""
+	if err == nil {
+		subject = strings.Trim(u.Path, "/")
+	}
+	if err != nil || u.Hostname() == "" || subject == "" || strings.ContainsAny(subject, " \t/") {
+		return nil, fmt.Errorf("invalid NATS sink %q: want nats://HOST[:PORT]/SUBJECT", rawURL)
+	}
+	opts, err := parseBrokerOptions(u.Query(), rawURL)
+	if err != nil {
+		return nil, err
+	}
+	port := u.Port()
+	if port == "" {
+		port = natsPort
+	}
+	return &NATS{
+		Addr:    net.JoinHostPort(u.Hostname(), port),
+		Subject: subject,
+		Timeout: DefaultBrokerTimeout,
+		opts:    opts,
+	}, nil
+}
+
+func (n *NATS) String() string {
+	return fmt.Sprintf("nats://%s/%s (%s)", n.Addr, n.Subject, n.opts.Format)
+}
+
+// Write publishes assets
+func (n *NATS) Write(assets []domain.Asset) error {
+	return n.WriteRows(Assets(assets))
+}
+
+// natsInfo is the part of the server's INFO message the sink uses
+type natsInfo struct {
+	TLSRequired bool  `json:"tls_required"`
+	MaxPayload  int64 `json:"max_payload"`
+}
+
+// natsConnect is the client's CONNECT message
+type natsConnect struct {
+	Verbose   bool   `json:"verbose"`
+	Pedantic  bool   `json:"pedantic"`
+	Lang      string `json:"lang"`
+	Name      string `json:"name"`
+	AuthToken string `json:"auth_token,omitempty"`
+	User      string `json:"user,omitempty"`
+	Pass      string `json:"pass,omitempty"`
+}
+
+// WriteRows publishes rows a batch at a time as they are read
+func (n *NATS) WriteRows(rows Rows) error {
+	conn, err := net.DialTimeout("tcp", n.Addr, 30*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(n.Timeout))
+
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("read NATS server info: %w", err)
+	}
+	var info natsInfo
+	payload, ok := strings.CutPrefix(strings.TrimSpace(line), "INFO ")
+	if !ok || json.Unmarshal([]byte(payload), &info) != nil {
+		return fmt.Errorf("unexpected NATS greeting %q", strings.TrimSpace(line))
+	}
+	if info.TLSRequired {
+		host, _, _ := net.SplitHostPort(n.Addr)
+		secure := tls.Client(conn, &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12})
+		if err := secure.Handshake(); err != nil {
+			return fmt.Errorf("NATS TLS handshake: %w", err)
+		}
+		conn = secure
+		reader = bufio.NewReader(conn)
+	}
+	writer := bufio.NewWriter(conn)
+
+	connect, err := json.Marshal(natsConnect{
+		Lang:      "go",
+		Name:      "algotradar",
+		AuthToken: os.Getenv("NATS_TOKEN"),
+		User:      os.Getenv("NATS_USER"),
+		Pass:      os.Getenv("NATS_PASSWORD"),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(writer, "CONNECT %s\r\n", connect)
+	if err := natsFlush(writer, reader); err != nil {
+		return fmt.Errorf("connect to NATS at %s: %w", n.Addr, err)
+	}
+
+	return n.opts.eachBatch(rows, func(assets []domain.Asset, payloads [][]byte) error {
+		for i, asset := range assets {
+			if info.MaxPayload > 0 && int64(len(payloads[i])) > info.MaxPayload {
+				return fmt.Errorf("%s: message of %d bytes exceeds the server's limit of %d", asset.Ticker, len(payloads[i]), info.MaxPayload)
+			}
+			subject := strings.ReplaceAll(n.Subject, "{ticker}", natsToken(asset.Ticker))
+			fmt.Fprintf(writer, "PUB %s %d\r\n", subject, len(payloads[i]))
+			writer.Write(payloads[i])
+			writer.WriteString("\r\n")
+		}
+		if err := natsFlush(writer, reader); err != nil {
+			return fmt.Errorf("publish to %s: %w", n.Subject, err)
+		}
+		return nil
+	})
+}
+
+// natsFlush sends what is buffered followed by a PING and waits for the
+// PONG. The server handles a connection's messages in order, so the PONG
+// confirms everything before it; an -ERR on the way fails the flush.
+func natsFlush(writer *bufio.Writer, reader *bufio.Reader) error {
+	writer.WriteString("PING\r\n")
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			writer.WriteString("PONG\r\n")
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New(strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")), "'"))
+		}
+	}
+}
+
+// natsToken makes a ticker usable as one token of a subject, where dots
+// separate tokens: BRK.B becomes BRK_B
+func natsToken(ticker string) string {
+	return strings.NewReplacer(".", "_", " ", "_", "*", "_", ">", "_").Replace(ticker)
+}
@@ -0,0 +1,128 @@
+package sink
+
+import (
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"algotradar/domain"
+	"algotradar/schema"
+)
+
+// parquetRow is the Parquet schema of a snapshot row
+type parquetRow struct {
+	Rank               int32      `parquet:"rank"`
+	Ticker             string     `parquet:"ticker,dict"`
+	Name               string     `parquet:"name"`
+	MarketCap          float64    `parquet:"market_cap"`
+	CurrentPrice       float64    `parquet:"current_price"`
+	PreviousClose      float64    `parquet:"previous_close"`
+	PercentageChange   float64    `parquet:"percentage_change"`
+	Volume             float64    `parquet:"volume"`
+	PrimaryExchange    string     `parquet:"primary_exchange,dict"`
+	Country            string     `parquet:"country,dict"`
+	Sector             string     `parquet:"sector,dict"`
+	Industry           string     `parquet:"industry,dict"`
+	SectorNormalized   string     `parquet:"sector_normalized,dict"`
+	IndustryNormalized string     `parquet:"industry_normalized,dict"`
+	CapTier            string     `parquet:"cap_tier,dict"`
+	MIC                string     `parquet:"mic,dict"`
+	ExchangeTimezone   string     `parquet:"exchange_timezone,dict"`
+	IsMarketOpen       *bool      `parquet:"is_market_open,optional"`
+	QuoteSession       string     `parquet:"quote_session,dict"`
+	QuoteTime          *time.Time `parquet:"quote_timestamp_utc,optional"`
+	SnapshotDate       string     `parquet:"snapshot_date,dict"`
+	CollectedAt        *time.Time `parquet:"collected_at,optional"`
+	AssetType          string     `parquet:"asset_type,dict"`
+	Image              string     `parquet:"image"`
+	DividendYield      *float64   `parquet:"dividend_yield,optional"`
+	DataSource         string     `parquet:"data_source,dict"`
+	SharesOutstanding  float64    `parquet:"shares_outstanding"`
+	FloatShares        float64    `parquet:"float_shares"`
+	FreeFloatMarketCap float64    `parquet:"free_float_market_cap"`
+	YearHigh           float64    `parquet:"year_high"`
+	YearLow            float64    `parquet:"year_low"`
+	PriceAvg50         float64    `parquet:"price_avg_50"`
+	PriceAvg200        float64    `parquet:"price_avg_200"`
+	YTDReturn          *float64   `parquet:"ytd_return,optional"`
+	InstitutionalOwn   float64    `parquet:"institutional_ownership_pct"`
+	Top10HolderPct     float64    `parquet:"top10_holder_pct"`
+	InstHolders        int32      `parquet:"institutional_holders"`
+	ListingDate        string     `parquet:"listing_date"`
+	IsRecentIPO        bool       `parquet:"is_recent_ipo"`
+	InstrumentType     string     `parquet:"instrument_type,dict"`
+	FFO                float64    `parquet:"ffo_ttm"`
+	FFOCurrency        string     `parquet:"ffo_currency,dict"`
+	PriceToFFO         float64    `parquet:"price_to_ffo"`
+	ShareClass         string     `parquet:"share_class,dict"`
+	LocalCurrency      string     `parquet:"local_currency,dict"`
+	FXRateUsed         float64    `parquet:"fx_rate_used"`
+	FXRateSource       string     `parquet:"fx_rate_source,dict"`
+	FXRateTime         *time.Time `parquet:"fx_rate_timestamp,optional"`
+}
+
+// EncodeParquet writes the snapshot as a single Parquet file, recording the
+// schema version in the file's key-value metadata
+func EncodeParquet(w io.Writer, assets []domain.Asset) error {
+	rows := make([]parquetRow, len(assets))
+	for i, a := range assets {
+		rows[i] = parquetRow{
+			Rank:               int32(i + 1),
+			Ticker:             a.Ticker,
+			Name:               a.Name,
+			MarketCap:          a.MarketCap,
+			CurrentPrice:       a.CurrentPrice,
+			PreviousClose:      a.PreviousClose,
+			PercentageChange:   a.PercentageChange,
+			Volume:             a.Volume,
+			PrimaryExchange:    a.PrimaryExchange,
+			Country:            a.Country,
+			Sector:             a.Sector,
+			Industry:           a.Industry,
+			SectorNormalized:   a.SectorNormalized,
+			IndustryNormalized: a.IndustryNormalized,
+			CapTier:            a.CapTier,
+			MIC:                a.MIC,
+			ExchangeTimezone:   a.ExchangeTimezone,
+			IsMarketOpen:       a.IsMarketOpen,
+			QuoteSession:       a.QuoteSession,
+			QuoteTime:          a.QuoteTime,
+			SnapshotDate:       a.SnapshotDate,
+			CollectedAt:        a.CollectedAt,
+			AssetType:          a.AssetType,
+			Image:              a.Image,
+			DividendYield:      a.DividendYield,
+			DataSource:         a.DataSource,
+			SharesOutstanding:  a.SharesOutstanding,
+			FloatShares:        a.FloatShares,
+			FreeFloatMarketCap: a.FreeFloatMarketCap,
+			YearHigh:           a.YearHigh,
+			YearLow:            a.YearLow,
+			PriceAvg50:         a.PriceAvg50,
+			PriceAvg200:        a.PriceAvg200,
+			YTDReturn:          a.YTDReturn,
+			InstitutionalOwn:   a.InstitutionalOwnership,
+			Top10HolderPct:     a.Top10HolderPct,
+			InstHolders:        int32(a.InstitutionalHolders),
+			ListingDate:        a.ListingDate,
+			IsRecentIPO:        a.IsRecentIPO,
+			InstrumentType:     a.InstrumentType,
+			FFO:                a.FFO,
+			FFOCurrency:        a.FFOCurrency,
+			PriceToFFO:         a.PriceToFFO,
+			ShareClass:         a.ShareClass,
+			LocalCurrency:      a.LocalCurrency,
+			FXRateUsed:         a.FXRateUsed,
+			FXRateSource:       a.FXRateSource,
+			FXRateTime:         a.FXRateTime,
+		}
+	}
+
+	writer := parquet.NewGenericWriter[parquetRow](w, parquet.KeyValueMetadata("schema_version", strconv.Itoa(schema.Version)))
+	if _, err := writer.Write(rows); err != nil {
+		return err
+	}
+	return writer.Close()
+}
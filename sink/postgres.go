@@ -0,0 +1,89 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"algotradar/domain"
+)
+
+// DefaultTable is the assets table described in the README
+const DefaultTable = "public.assets"
+
+// dbTimeout bounds one snapshot load into a database
+const dbTimeout = 2 * time.Minute
+
+// Postgres loads the snapshot into the assets table for today's date. Rows for
+// the same tickers and date are replaced, so a rerun does not duplicate them.
+type Postgres struct {
+	DSN   string
+	Table string
+}
+
+func (p *Postgres) String() string {
+	// Never log credentials embedded in the DSN
+	if cfg, err := pgx.ParseConfig(p.DSN); err == nil {
+		return fmt.Sprintf("postgres:%s/%s (%s)", cfg.Host, cfg.Database, p.Table)
+	}
+	return "postgres:" + p.Table
+}
+
+func (p *Postgres) Write(assets []domain.Asset) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	conn, err := pgx.Connect(ctx, p.DSN)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	table := pgx.Identifier(strings.Split(p.Table, "."))
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	tickers := make([]string, len(assets))
+	rows := make([][]any, len(assets))
+	for i, a := range assets {
+		tickers[i] = a.Ticker
+		rows[i] = []any{
+			truncate(a.Ticker, 20), truncate(a.Name, 255), int64(a.MarketCap), a.CurrentPrice,
+			a.PreviousClose, a.PercentageChange, int64(a.Volume), truncate(a.PrimaryExchange, 100),
+			truncate(a.Country, 100), truncate(a.Sector, 100), truncate(a.Industry, 100),
+			truncate(a.SectorNormalized, 100), truncate(a.IndustryNormalized, 100),
+			truncate(a.MIC, 10), truncate(a.ExchangeTimezone, 50), a.IsMarketOpen,
+			a.QuoteSession, a.QuoteTime,
+			a.AssetType, i + 1, today,
+			int64(a.SharesOutstanding), int64(a.FloatShares), int64(a.FreeFloatMarketCap),
+		}
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	deleteSQL := fmt.Sprintf("DELETE FROM %s WHERE date_updated = $1 AND ticker = ANY($2)", table.Sanitize())
+	if _, err := tx.Exec(ctx, deleteSQL, today, tickers); err != nil {
+		return fmt.Errorf("failed to clear existing rows: %w", err)
+	}
+
+	columns := []string{
+		"ticker", "name", "market_cap", "current_price",
+		"previous_close", "percentage_change", "volume", "primary_exchange",
+		"country", "sector", "industry",
+		"sector_normalized", "industry_normalized",
+		"mic", "exchange_timezone", "is_market_open",
+		"quote_session", "quote_timestamp_utc",
+		"asset_type", "rank_position", "date_updated",
+		"shares_outstanding", "float_shares", "free_float_market_cap",
+	}
+	if _, err := tx.CopyFrom(ctx, table, columns, pgx.CopyFromRows(rows)); err != nil {
+		return fmt.Errorf("failed to copy rows: %w", err)
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,329 @@
+package sink
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"algotradar/domain"
+	"algotradar/schema"
+)
+
+const (
+	// DefaultRedisTTL is how long a Redis sink's keys live after each write,
+	// so a collector that stops running does not leave stale rankings behind
+	DefaultRedisTTL = 48 * time.Hour
+	// DefaultRedisPrefix starts the keys a Redis sink writes
+	DefaultRedisPrefix = "asset:"
+
+	redisPort = "6379"
+)
+
+// Redis writes the latest snapshot to Redis for fast lookups: each row goes
+// to a hash {prefix}{symbol} with the JSON row's fields and its rank, and
+// the symbols in rank order go to the sorted set {prefix}ranking, scored by
+// rank. Every key expires after the TTL. A hash is replaced whole, and the
+// ranking is built under a temporary key and renamed into place, so readers
+// never see two runs mixed. The password comes from the URL or
+// REDIS_PASSWORD; rediss:// connects over TLS.
+//
+// Spec: redis://[:PASSWORD@]HOST[:PORT][/DB]?ttl=48h&prefix=asset:&batch=N
+// (ttl=0 keeps the keys until the next write replaces them).
+type Redis struct {
+	Addr    string
+	TLS     bool
+	DB      int
+	Prefix  string
+	TTL     time.Duration
+	Batch   int
+	Timeout time.Duration
+
+	username string
+	password string
+}
+
+// NewRedis parses a redis:// or rediss:// URL
+func NewRedis(rawURL string) (*Redis, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid Redis sink %q: want redis://HOST[:PORT][/DB]", rawURL)
+	}
+	port := u.Port()
+	if port == "" {
+		port = redisPort
+	}
+	query := u.Query()
+	r := &Redis{
+		Addr:     net.JoinHostPort(u.Hostname(), port),
+		TLS:      u.Scheme == "rediss",
+		Prefix:   DefaultRedisPrefix,
+		TTL:      DefaultRedisTTL,
+		Batch:    DefaultBrokerBatch,
+		Timeout:  DefaultBrokerTimeout,
+		password: os.Getenv("REDIS_PASSWORD"),
+	}
+	if u.User != nil {
+		r.username = u.User.Username()
+		if password, ok := u.User.Password(); ok {
+			r.password = password
+		}
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if r.DB, err = strconv.Atoi(db); err != nil || r.DB < 0 {
+			return nil, fmt.Errorf("invalid database %q in Redis sink: want a number", db)
+		}
+	}
+	if prefix, ok := query["prefix"]; ok {
+		r.Prefix = prefix[0]
+	}
+	if raw := query.Get("ttl"); raw != "" {
+		if r.TTL, err = time.ParseDuration(raw); err != nil || r.TTL < 0 || (r.TTL > 0 && r.TTL < time.Second) {
+			return nil, fmt.Errorf("invalid ttl %q in Redis sink: want a duration of at least 1s, or 0", raw)
+		}
+	}
+	if raw := query.Get("batch"); raw != "" {
+		if r.Batch, err = strconv.Atoi(raw); err != nil || r.Batch < 1 {
+			return nil, fmt.Errorf("invalid batch %q in Redis sink", raw)
+		}
+	}
+	return r, nil
+}
+
+// String describes the destination without its password
+func (r *Redis) String() string {
+	scheme := "redis"
+	if r.TLS {
+		scheme = "rediss"
+	}
+	return fmt.Sprintf("%s://%s/%d (%s*)", scheme, r.Addr, r.DB, r.Prefix)
+}
+
+// RankingKey is the sorted set listing the symbols by rank
+func (r *Redis) RankingKey() string { return r.Prefix + "ranking" }
+
+// Write stores assets
+func (r *Redis) Write(assets []domain.Asset) error {
+	return r.WriteRows(Assets(assets))
+}
+
+// WriteRows stores rows a batch at a time as they are read, pipelining each
+// batch's commands in one round trip
+func (r *Redis) WriteRows(rows Rows) error {
+	conn, err := r.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	staging := fmt.Sprintf("%s:staging:%d", r.RankingKey(), time.Now().UnixNano())
+	if err := conn.do([]string{"DEL", staging}); err != nil {
+		return err
+	}
+	var batch [][]string
+	rank := 0
+	if err := rows.Each(func(asset domain.Asset) error {
+		rank++
+		fields, err := redisFields(rank, asset)
+		if err != nil {
+			return fmt.Errorf("encode %s: %w", asset.Ticker, err)
+		}
+		key := r.Prefix + asset.Ticker
+		batch = append(batch,
+			[]string{"MULTI"},
+			[]string{"DEL", key},
+			append([]string{"HSET", key}, fields...),
+		)
+		if r.TTL > 0 {
+			batch = append(batch, []string{"EXPIRE", key, strconv.FormatInt(int64(r.TTL/time.Second), 10)})
+		}
+		batch = append(batch, []string{"EXEC"}, []string{"ZADD", staging, strconv.Itoa(rank), asset.Ticker})
+		if rank%r.Batch == 0 {
+			err := conn.do(batch...)
+			batch = batch[:0]
+			return err
+		}
+		return nil
+	}); err != nil {
+		conn.do([]string{"DEL", staging})
+		return err
+	}
+	if len(batch) > 0 {
+		if err := conn.do(batch...); err != nil {
+			conn.do([]string{"DEL", staging})
+			return err
+		}
+	}
+	if rank == 0 {
+		return conn.do([]string{"DEL", r.RankingKey()})
+	}
+
+	finish := [][]string{{"RENAME", staging, r.RankingKey()}}
+	if r.TTL > 0 {
+		finish = append(finish, []string{"EXPIRE", r.RankingKey(), strconv.FormatInt(int64(r.TTL/time.Second), 10)})
+	}
+	return conn.do(finish...)
+}
+
+// redisFields flattens a row to hash field/value pairs: the fields of its
+// JSON row, with strings as they are and numbers, booleans and nested
+// values as JSON, plus its rank. Null fields are left out.
+func redisFields(rank int, asset domain.Asset) ([]string, error) {
+	data, err := json.Marshal(schema.Asset{Asset: asset, SchemaVersion: schema.Version})
+	if err != nil {
+		return nil, err
+	}
+	var row map[string]json.RawMessage
+	if err := json.Unmarshal(data, &row); err != nil {
+		return nil, err
+	}
+	fields := []string{"rank", strconv.Itoa(rank)}
+	for name, raw := range row {
+		switch {
+		case string(raw) == "null":
+			continue
+		case len(raw) > 0 && raw[0] == '"':
+			var value string
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return nil, err
+			}
+			fields = append(fields, name, value)
+		default:
+			fields = append(fields, name, string(raw))
+		}
+	}
+	return fields, nil
+}
+
+// redisConn is a connection speaking the Redis protocol (RESP)
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+func (r *Redis) dial() (*redisConn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	var conn net.Conn
+	var err error
+	if r.TLS {
+		host, _, _ := net.SplitHostPort(r.Addr)
+		conn, err = tls.DialWithDialer(dialer, "tcp", r.Addr, &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12})
+	} else {
+		conn, err = dialer.Dial("tcp", r.Addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(r.Timeout))
+	c := &redisConn{conn: conn, reader: bufio.NewReader(conn), writer: bufio.NewWriter(conn)}
+
+	var setup [][]string
+	if r.password != "" {
+		if r.username != "" {
+			setup = append(setup, []string{"AUTH", r.username, r.password})
+		} else {
+			setup = append(setup, []string{"AUTH", r.password})
+		}
+	}
+	if r.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(r.DB)})
+	}
+	if len(setup) > 0 {
+		if err := c.do(setup...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("connect to Redis at %s: %w", r.Addr, err)
+		}
+	}
+	return c, nil
+}
+
+func (c *redisConn) Close() error { return c.conn.Close() }
+
+// do sends commands in one pipeline and reads all their replies, returning
+// the first error reply, including errors inside a transaction's EXEC
+func (c *redisConn) do(commands ...[]string) error {
+	for _, args := range commands {
+		fmt.Fprintf(c.writer, "*%d\r\n", len(args))
+		for _, arg := range args {
+			fmt.Fprintf(c.writer, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	if err := c.writer.Flush(); err != nil {
+		return err
+	}
+	var first error
+	for range commands {
+		if err := c.readReply(); err != nil {
+			var reply redisError
+			if !errors.As(err, &reply) {
+				return err
+			}
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// redisError is an error reply from the server
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+// readReply reads one reply, returning a redisError for an error reply or
+// an array holding one
+func (c *redisConn) readReply() error {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return errors.New("empty reply from Redis")
+	}
+	switch line[0] {
+	case '+', ':':
+		return nil
+	case '-':
+		return redisError(line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return fmt.Errorf("malformed Redis reply %q", line)
+		}
+		if n >= 0 {
+			_, err = io.CopyN(io.Discard, c.reader, int64(n)+2)
+		}
+		return err
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return fmt.Errorf("malformed Redis reply %q", line)
+		}
+		var first error
+		for i := 0; i < n; i++ {
+			if err := c.readReply(); err != nil {
+				var reply redisError
+				if !errors.As(err, &reply) {
+					return err
+				}
+				if first == nil {
+					first = err
+				}
+			}
+		}
+		return first
+	default:
+		return fmt.Errorf("unexpected Redis reply %q", line)
+	}
+}
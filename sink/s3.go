@@ -0,0 +1,257 @@
+package sink
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"algotradar/awssig"
+	"algotradar/domain"
+	"algotradar/output"
+)
+
+// DefaultUploadAttempts is how often an object upload is tried before giving up
+const DefaultUploadAttempts = 4
+
+// GCS interoperability defaults: the XML API accepts SigV4 requests signed with
+// an HMAC key in the "auto" region
+const (
+	gcsEndpoint = "https://storage.googleapis.com"
+	gcsRegion   = "auto"
+)
+
+// S3 uploads the snapshot as one object to S3 or, for gs:// URLs, to Google
+// Cloud Storage through its S3-compatible XML API. The format follows the key's
+// extension (.json, .csv, .parquet or .xlsx; .json and .csv may be followed by
+// .gz or .zst to compress the object), and "{date}" in the key expands to the
+// UTC upload date so a key like market=global/date={date}/part.parquet lands
+// in a new partition each day. Set S3_ENDPOINT to use an S3-compatible store
+// such as MinIO with path-style addressing.
+//
+// URL query options: sse=AES256|aws:kms|none selects S3 server-side
+// encryption (default AES256 on AWS, none on a custom endpoint), kms_key names
+// the KMS key (a CMEK resource name on GCS) and attempts sets the retry budget.
+type S3 struct {
+	Scheme     string // s3 or gs
+	Bucket     string
+	Key        string
+	Region     string
+	Endpoint   string
+	Encryption string
+	KMSKey     string
+	Attempts   int
+	Client     *http.Client
+
+	kind   string
+	encode encoder
+}
+
+// NewS3 parses an s3://bucket/key URL; credentials come from the AWS_* environment
+func NewS3(rawURL string) (*S3, error) {
+	s, err := parseObjectURL(rawURL, "s3")
+	if err != nil {
+		return nil, err
+	}
+	s.Region = awssig.RegionFromEnv()
+	s.Endpoint = os.Getenv("S3_ENDPOINT")
+	if s.Encryption == "" && s.Endpoint == "" {
+		s.Encryption = "AES256"
+	}
+	if s.KMSKey != "" && s.Encryption == "" {
+		s.Encryption = "aws:kms"
+	}
+	return s, nil
+}
+
+// NewGCS parses a gs://bucket/key URL; credentials are an HMAC key from
+// GCS_HMAC_ACCESS_KEY_ID and GCS_HMAC_SECRET. GCS always encrypts at rest, so
+// only kms_key changes the encryption.
+func NewGCS(rawURL string) (*S3, error) {
+	s, err := parseObjectURL(rawURL, "gs")
+	if err != nil {
+		return nil, err
+	}
+	s.Region = gcsRegion
+	s.Endpoint = gcsEndpoint
+	if endpoint := os.Getenv("GCS_ENDPOINT"); endpoint != "" {
+		s.Endpoint = endpoint
+	}
+	return s, nil
+}
+
+func parseObjectURL(rawURL, scheme string) (*S3, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != scheme || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return nil, fmt.Errorf("invalid object sink %q: want %s://bucket/key", rawURL, scheme)
+	}
+
+	query := u.Query()
+	s := &S3{
+		Scheme:     scheme,
+		Bucket:     u.Host,
+		Key:        strings.TrimPrefix(u.Path, "/"),
+		Encryption: query.Get("sse"),
+		KMSKey:     query.Get("kms_key"),
+		Attempts:   DefaultUploadAttempts,
+		Client:     &http.Client{Timeout: 5 * time.Minute},
+	}
+	switch s.Encryption {
+	case "", "AES256", "aws:kms":
+	case "none":
+		s.Encryption = ""
+	default:
+		return nil, fmt.Errorf("invalid sse %q in %s: want AES256, aws:kms or none", s.Encryption, rawURL)
+	}
+	if raw := query.Get("attempts"); raw != "" {
+		if s.Attempts, err = strconv.Atoi(raw); err != nil || s.Attempts < 1 {
+			return nil, fmt.Errorf("invalid attempts %q in %s", raw, rawURL)
+		}
+	}
+
+	s.kind, s.encode = encoderForPath(output.Uncompressed(s.Key))
+	if err := checkCompression(s.kind, s.Key); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *S3) String() string { return fmt.Sprintf("%s://%s/%s", s.Scheme, s.Bucket, s.Key) }
+
+// ObjectKey returns the key an upload at t is written to
+func (s *S3) ObjectKey(t time.Time) string {
+	return strings.ReplaceAll(s.Key, "{date}", t.UTC().Format("2006-01-02"))
+}
+
+func (s *S3) objectURL(key string) string {
+	escaped := (&url.URL{Path: "/" + key}).EscapedPath()
+	if s.Endpoint != "" {
+		return strings.TrimSuffix(s.Endpoint, "/") + "/" + s.Bucket + escaped
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com%s", s.Bucket, s.Region, escaped)
+}
+
+func (s *S3) credentials() (awssig.Credentials, error) {
+	if s.Scheme != "gs" {
+		return awssig.CredentialsFromEnv()
+	}
+	creds := awssig.Credentials{
+		AccessKeyID:     os.Getenv("GCS_HMAC_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("GCS_HMAC_SECRET"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return creds, errors.New("GCS_HMAC_ACCESS_KEY_ID and GCS_HMAC_SECRET must be set")
+	}
+	return creds, nil
+}
+
+func (s *S3) Write(assets []domain.Asset) error {
+	var body bytes.Buffer
+	w, err := output.NewWriter(&body, s.Key)
+	if err != nil {
+		return err
+	}
+	if err := s.encode(w, assets); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	contentType := contentTypes[s.kind]
+	switch output.CompressionExt(s.Key) {
+	case output.GzipExt:
+		contentType = "application/gzip"
+	case output.ZstdExt:
+		contentType = "application/zstd"
+	}
+	return s.Upload(s.ObjectKey(time.Now()), body.Bytes(), contentType)
+}
+
+// URL returns where the object key is read from
+func (s *S3) URL(key string) string { return s.objectURL(key) }
+
+// Link returns the URL of today's object
+func (s *S3) Link() string { return s.objectURL(s.ObjectKey(time.Now())) }
+
+// Upload stores body under key, retrying with exponential backoff on network
+// errors, 429 and 5xx responses
+func (s *S3) Upload(key string, body []byte, contentType string) error {
+	creds, err := s.credentials()
+	if err != nil {
+		return err
+	}
+
+	attempts := max(s.Attempts, 1)
+	for attempt := 1; ; attempt++ {
+		err = s.put(creds, key, body, contentType)
+		var status *uploadStatusError
+		retryable := !errors.As(err, &status) || status.retryable()
+		if err == nil || !retryable || attempt == attempts {
+			break
+		}
+		time.Sleep(time.Duration(1<<(attempt-1)) * time.Second)
+	}
+	if err != nil {
+		return fmt.Errorf("upload of %s failed: %w", key, err)
+	}
+	return nil
+}
+
+// put sends one signed PUT of the object
+func (s *S3) put(creds awssig.Credentials, key string, body []byte, contentType string) error {
+	req, err := http.NewRequest(http.MethodPut, s.objectURL(key), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if s.Scheme == "gs" {
+		if s.KMSKey != "" {
+			req.Header.Set("X-Goog-Encryption-Kms-Key-Name", s.KMSKey)
+		}
+	} else if s.Encryption != "" {
+		req.Header.Set("X-Amz-Server-Side-Encryption", s.Encryption)
+		if s.KMSKey != "" {
+			req.Header.Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", s.KMSKey)
+		}
+	}
+	awssig.NewSigner(creds, s.Region, "s3").Sign(req, awssig.HashPayload(body))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &uploadStatusError{code: resp.StatusCode, msg: strings.TrimSpace(string(msg))}
+	}
+	return nil
+}
+
+// uploadStatusError is a non-200 upload response
+type uploadStatusError struct {
+	code int
+	msg  string
+}
+
+func (e *uploadStatusError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.code, e.msg)
+}
+
+// retryable reports whether the store may accept the same request later
+func (e *uploadStatusError) retryable() bool {
+	return e.code == http.StatusTooManyRequests || e.code >= 500
+}
+
+var contentTypes = map[string]string{
+	"json":    "application/json",
+	"csv":     "text/csv; charset=utf-8",
+	"parquet": "application/vnd.apache.parquet",
+	"xlsx":    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
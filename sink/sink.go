@@ -0,0 +1,365 @@
+// Package sink writes a collected snapshot to one or more destinations: local
+// JSON/CSV/Parquet/Excel files, a Postgres table, an embedded SQLite history,
+// S3/GCS object storage, a Google Sheet, a Kafka or NATS message stream or a
+// Redis cache of the latest snapshot or a ClickHouse or BigQuery history table.
+//
+// Sinks are configured with spec strings such as "json:global_stocks_fmp.json",
+// "csv:out.csv", "parquet:out.parquet", "supabase:us_supabase.json",
+// "postgres:postgres://user@host/db", "s3://bucket/key.json",
+// "gs://bucket/date={date}/part.parquet", "csv:out/{country}.csv",
+// "sqlite:data.db" and
+// "gsheets:SPREADSHEET_ID?sheet=Ranking&top=100",
+// "kafka://broker:9092/assets?format=protobuf", "nats://host:4222/assets.{ticker}"
+// "redis://localhost:6379/0?ttl=24h", "clickhouse://localhost:8123/algotradar" and
+// "bigquery:my-project.markets.asset_snapshots".
+package sink
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"algotradar/domain"
+	"algotradar/output"
+)
+
+// OutputSink is a destination for a ranked snapshot
+type OutputSink interface {
+	Write(assets []domain.Asset) error
+	// String describes the destination for logs
+	String() string
+}
+
+// CountryPlaceholder in a file sink's path splits the snapshot into one file
+// per country, e.g. csv:out/{country}.csv writes out/US.csv, out/JP.csv...
+const CountryPlaceholder = "{country}"
+
+// FileSink is implemented by sinks that write a local file
+type FileSink interface {
+	OutputSink
+	Path() string
+	// Format is the encoding written: json, csv, parquet, supabase or xlsx
+	Format() string
+	// WithPath returns a copy of the sink that writes to path instead
+	WithPath(path string) FileSink
+}
+
+// Linker is implemented by remote sinks whose output can be opened in a browser
+type Linker interface {
+	// Link returns where the latest write is read from
+	Link() string
+}
+
+// Parse builds a sink from a spec of the form "kind:target" or an s3://,
+// gs://, kafka://, nats://, redis://, rediss:// or clickhouse:// URL
+func Parse(spec string) (OutputSink, error) {
+	if strings.HasPrefix(spec, "s3://") {
+		return NewS3(spec)
+	}
+	if strings.HasPrefix(spec, "gs://") {
+		return NewGCS(spec)
+	}
+	if strings.HasPrefix(spec, "kafka://") {
+		return NewKafka(spec)
+	}
+	if strings.HasPrefix(spec, "nats://") {
+		return NewNATS(spec)
+	}
+	if strings.HasPrefix(spec, "redis://") || strings.HasPrefix(spec, "rediss://") {
+		return NewRedis(spec)
+	}
+	if strings.HasPrefix(spec, "clickhouse://") {
+		return NewClickHouse(spec)
+	}
+
+	kind, target, ok := strings.Cut(spec, ":")
+	if !ok || target == "" && kind != "postgres" {
+		return nil, fmt.Errorf("invalid sink %q: want kind:target", spec)
+	}
+
+	switch kind {
+	case "postgres":
+		if target == "" {
+			target = os.Getenv("DATABASE_URL")
+		}
+		if target == "" {
+			return nil, errors.New("postgres sink needs a DSN or DATABASE_URL")
+		}
+		return &Postgres{DSN: target, Table: DefaultTable}, nil
+	case "sqlite":
+		return &SQLite{Path: target}, nil
+	case "gsheets":
+		return NewGoogleSheets(target)
+	case "bigquery":
+		return NewBigQuery(target)
+	default:
+		if _, ok := encoders[kind]; !ok {
+			return nil, fmt.Errorf("unknown sink kind %q", kind)
+		}
+		return NewFile(kind, target)
+	}
+}
+
+// ParseStore parses a --store spec. Stores keep every run as queryable
+// history; only "sqlite:PATH" is supported.
+func ParseStore(spec string) (OutputSink, error) {
+	if !strings.HasPrefix(spec, "sqlite:") {
+		return nil, fmt.Errorf("invalid store %q: want sqlite:PATH", spec)
+	}
+	return Parse(spec)
+}
+
+// ParseAll parses several sink specs
+func ParseAll(specs []string) ([]OutputSink, error) {
+	sinks := make([]OutputSink, 0, len(specs))
+	for _, spec := range specs {
+		s, err := Parse(spec)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	return sinks, nil
+}
+
+// Multi fans a snapshot out to several sinks. A failing sink does not stop the
+// others; all failures are returned together.
+type Multi struct {
+	Sinks  []OutputSink
+	Logger *slog.Logger
+
+	// Path maps a file sink's configured path to the one actually written,
+	// e.g. a timestamped name; nil keeps the configured path
+	Path func(configured string) string
+	// Written is called after each file is written successfully with the
+	// configured path and the sink as written
+	Written func(configured string, written FileSink)
+	// Linked is called after each remote sink that is a Linker is written
+	Linked func(written OutputSink, link string)
+}
+
+// Write sends assets to every sink
+func (m *Multi) Write(assets []domain.Asset) error {
+	return m.WriteRows(Assets(assets))
+}
+
+// WriteRows sends rows to every sink, streaming them to those that can take
+// them a row at a time
+func (m *Multi) WriteRows(rows Rows) error {
+	logger := m.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	var errs []error
+	for _, s := range m.Sinks {
+		fileSink, isFile := s.(FileSink)
+		if isFile && strings.Contains(fileSink.Path(), CountryPlaceholder) {
+			errs = append(errs, m.writeByCountry(fileSink, rows, logger))
+			continue
+		}
+		configured := ""
+		if isFile {
+			configured = fileSink.Path()
+			if m.Path != nil {
+				s = fileSink.WithPath(m.Path(configured))
+			}
+		}
+
+		if err := WriteRows(s, rows); err != nil {
+			logger.Error("failed to write sink", "sink", s.String(), "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", s, err))
+			continue
+		}
+		logger.Info("sink written", "sink", s.String(), "assets", rows.Len())
+
+		if isFile && m.Written != nil {
+			m.Written(configured, s.(FileSink))
+		}
+		if linker, ok := s.(Linker); ok && m.Linked != nil {
+			m.Linked(s, linker.Link())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// writeByCountry writes each country's rows, ranked among themselves, to the
+// sink's path with its code in place of CountryPlaceholder. Each file is
+// mapped by Path and reported to Written on its own.
+func (m *Multi) writeByCountry(s FileSink, rows Rows, logger *slog.Logger) error {
+	counts := make(map[string]int)
+	if err := rows.Each(func(asset domain.Asset) error {
+		counts[splitCountry(asset)]++
+		return nil
+	}); err != nil {
+		return fmt.Errorf("%s: %w", s, err)
+	}
+	countries := make([]string, 0, len(counts))
+	for country := range counts {
+		countries = append(countries, country)
+	}
+	sort.Strings(countries)
+
+	var errs []error
+	for _, country := range countries {
+		configured := strings.ReplaceAll(s.Path(), CountryPlaceholder, country)
+		path := configured
+		if m.Path != nil {
+			path = m.Path(configured)
+		}
+		written := s.WithPath(path)
+		if err := WriteRows(written, countryRows{rows, country, counts[country]}); err != nil {
+			logger.Error("failed to write sink", "sink", written.String(), "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", written, err))
+			continue
+		}
+		if m.Written != nil {
+			m.Written(configured, written)
+		}
+	}
+	logger.Info("sink written", "sink", s.String(), "assets", rows.Len(), "files", len(countries))
+	return errors.Join(errs...)
+}
+
+// CountryPath is the configured path of the file a sink split by country
+// at path writes asset to
+func CountryPath(path string, asset domain.Asset) string {
+	return strings.ReplaceAll(path, CountryPlaceholder, splitCountry(asset))
+}
+
+// splitCountry is the code a row is filed under when splitting by country,
+// Unknown for rows without one as on the workbook's sheets
+func splitCountry(asset domain.Asset) string {
+	if asset.Country == "" {
+		return unknownCountry
+	}
+	return asset.Country
+}
+
+// countryRows are the rows of one country, n of them
+type countryRows struct {
+	rows    Rows
+	country string
+	n       int
+}
+
+func (c countryRows) Len() int { return c.n }
+
+func (c countryRows) Each(fn func(domain.Asset) error) error {
+	return c.rows.Each(func(asset domain.Asset) error {
+		if splitCountry(asset) != c.country {
+			return nil
+		}
+		return fn(asset)
+	})
+}
+
+func (m *Multi) String() string {
+	names := make([]string, len(m.Sinks))
+	for i, s := range m.Sinks {
+		names[i] = s.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// File writes the snapshot to a local file in one of the encoder formats
+type File struct {
+	path   string
+	kind   string
+	encode encoder
+}
+
+// NewFile returns a file sink of the given kind (json, csv, parquet or
+// supabase). JSON, CSV and Supabase files whose path ends in .gz or .zst are
+// compressed with gzip or zstd.
+func NewFile(kind, path string) (*File, error) {
+	encoder, ok := encoders[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown file format %q", kind)
+	}
+	if err := checkCompression(kind, path); err != nil {
+		return nil, err
+	}
+	if strings.Contains(path, CountryPlaceholder) && !strings.Contains(filepath.Base(path), CountryPlaceholder) {
+		return nil, fmt.Errorf("%s must be in the file name of %s, not a directory", CountryPlaceholder, path)
+	}
+	return &File{path: path, kind: kind, encode: encoder}, nil
+}
+
+func (f *File) Path() string { return f.path }
+
+func (f *File) Format() string { return f.kind }
+
+func (f *File) WithPath(path string) FileSink {
+	clone := *f
+	clone.path = path
+	return &clone
+}
+
+func (f *File) String() string { return f.kind + ":" + f.path }
+
+// Write encodes assets to a temporary file and renames it into place
+func (f *File) Write(assets []domain.Asset) error {
+	return f.writeFile(func(w io.Writer) error { return f.encode(w, assets) })
+}
+
+// writeFile writes the file with write through a temporary file, renamed into
+// place once complete so readers never see a partial snapshot. A path ending
+// in .gz or .zst is compressed on the way.
+func (f *File) writeFile(write func(w io.Writer) error) error {
+	if dir := filepath.Dir(f.path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	tmp := f.path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w, err := output.NewWriter(file, f.path)
+	if err == nil {
+		if err = write(w); err == nil {
+			err = w.Close()
+		}
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
+
+// checkCompression rejects compressing formats that compress internally
+func checkCompression(kind, path string) error {
+	if ext := output.CompressionExt(path); ext != "" && (kind == "parquet" || kind == "xlsx") {
+		return fmt.Errorf("%s files are compressed internally; drop the %s from %s", kind, ext, path)
+	}
+	return nil
+}
+
+// Specs is a repeatable command-line flag collecting sink specs
+type Specs []string
+
+func (s *Specs) String() string { return strings.Join(*s, ",") }
+
+// Set records a spec; specs are parsed later with ParseAll so that settings
+// such as DATABASE_URL can still come from a .env file
+func (s *Specs) Set(spec string) error {
+	if strings.TrimSpace(spec) == "" {
+		return errors.New("empty sink spec")
+	}
+	*s = append(*s, spec)
+	return nil
+}
@@ -0,0 +1,263 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"algotradar/domain"
+)
+
+// SnapshotTable is the SQLite history table, keyed by (symbol, snapshot_date)
+const SnapshotTable = "asset_snapshots"
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS asset_snapshots (
+	symbol            TEXT    NOT NULL,
+	snapshot_date     TEXT    NOT NULL,
+	rank_position     INTEGER NOT NULL,
+	name              TEXT,
+	market_cap        REAL,
+	current_price     REAL,
+	previous_close    REAL,
+	percentage_change REAL,
+	volume            REAL,
+	primary_exchange  TEXT,
+	country           TEXT,
+	sector            TEXT,
+	industry          TEXT,
+	asset_type        TEXT,
+	image             TEXT,
+	dividend_yield    REAL,
+	currency          TEXT,
+	collected_at      TEXT    NOT NULL,
+	sector_normalized   TEXT,
+	industry_normalized TEXT,
+	mic                 TEXT,
+	exchange_timezone   TEXT,
+	is_market_open      INTEGER,
+	quote_session       TEXT,
+	quote_timestamp_utc TEXT,
+	shares_outstanding    REAL,
+	float_shares          REAL,
+	free_float_market_cap REAL,
+	instrument_type       TEXT,
+	share_class           TEXT,
+	local_currency        TEXT,
+	fx_rate_used          REAL,
+	fx_rate_source        TEXT,
+	fx_rate_timestamp     TEXT,
+	cap_tier              TEXT,
+	PRIMARY KEY (symbol, snapshot_date)
+);
+CREATE INDEX IF NOT EXISTS asset_snapshots_date ON asset_snapshots (snapshot_date);
+`
+
+const sqliteUpsert = `
+INSERT INTO asset_snapshots (
+	symbol, snapshot_date, rank_position, name, market_cap, current_price,
+	previous_close, percentage_change, volume, primary_exchange, country,
+	sector, industry, asset_type, image, dividend_yield, currency, collected_at,
+	sector_normalized, industry_normalized, mic, exchange_timezone, is_market_open,
+	quote_session, quote_timestamp_utc, shares_outstanding, float_shares, free_float_market_cap,
+	instrument_type, share_class, local_currency, fx_rate_used, fx_rate_source, fx_rate_timestamp,
+	cap_tier
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (symbol, snapshot_date) DO UPDATE SET
+	rank_position = excluded.rank_position,
+	name = excluded.name,
+	market_cap = excluded.market_cap,
+	current_price = excluded.current_price,
+	previous_close = excluded.previous_close,
+	percentage_change = excluded.percentage_change,
+	volume = excluded.volume,
+	primary_exchange = excluded.primary_exchange,
+	country = excluded.country,
+	sector = excluded.sector,
+	industry = excluded.industry,
+	asset_type = excluded.asset_type,
+	image = excluded.image,
+	dividend_yield = excluded.dividend_yield,
+	currency = excluded.currency,
+	collected_at = excluded.collected_at,
+	sector_normalized = excluded.sector_normalized,
+	industry_normalized = excluded.industry_normalized,
+	mic = excluded.mic,
+	exchange_timezone = excluded.exchange_timezone,
+	is_market_open = excluded.is_market_open,
+	quote_session = excluded.quote_session,
+	quote_timestamp_utc = excluded.quote_timestamp_utc,
+	shares_outstanding = excluded.shares_outstanding,
+	float_shares = excluded.float_shares,
+	free_float_market_cap = excluded.free_float_market_cap,
+	instrument_type = excluded.instrument_type,
+	share_class = excluded.share_class,
+	local_currency = excluded.local_currency,
+	fx_rate_used = excluded.fx_rate_used,
+	fx_rate_source = excluded.fx_rate_source,
+	fx_rate_timestamp = excluded.fx_rate_timestamp,
+	cap_tier = excluded.cap_tier`
+
+// sqliteAddedColumns are columns added to asset_snapshots after its first
+// release; databases created before them are migrated on open
+var sqliteAddedColumns = []struct{ name, kind string }{
+	{"sector_normalized", "TEXT"},
+	{"industry_normalized", "TEXT"},
+	{"mic", "TEXT"},
+	{"exchange_timezone", "TEXT"},
+	{"is_market_open", "INTEGER"},
+	{"quote_session", "TEXT"},
+	{"quote_timestamp_utc", "TEXT"},
+	{"shares_outstanding", "REAL"},
+	{"float_shares", "REAL"},
+	{"free_float_market_cap", "REAL"},
+	{"instrument_type", "TEXT"},
+	{"share_class", "TEXT"},
+	{"local_currency", "TEXT"},
+	{"fx_rate_used", "REAL"},
+	{"fx_rate_source", "TEXT"},
+	{"fx_rate_timestamp", "TEXT"},
+	{"cap_tier", "TEXT"},
+}
+
+// SQLite appends each run to the asset_snapshots table of an embedded
+// database file, creating it on first use. A rerun on the same UTC day
+// replaces that day's rows, so the table holds one row per symbol and date.
+type SQLite struct {
+	Path string
+}
+
+func (s *SQLite) String() string { return "sqlite:" + s.Path }
+
+func (s *SQLite) Write(assets []domain.Asset) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	db, err := sql.Open("sqlite", s.Path)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, sqliteSchema); err != nil {
+		return fmt.Errorf("failed to create schema: %w", err)
+	}
+	if err := migrateSQLite(ctx, db); err != nil {
+		return fmt.Errorf("failed to migrate schema: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, sqliteUpsert)
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC()
+	date := now.Format("2006-01-02")
+	collected := now.Format(time.RFC3339)
+	for i, a := range assets {
+		if _, err := stmt.ExecContext(ctx,
+			a.Ticker, date, i+1, a.Name, a.MarketCap, a.CurrentPrice,
+			a.PreviousClose, a.PercentageChange, a.Volume, a.PrimaryExchange, a.Country,
+			a.Sector, a.Industry, a.AssetType, a.Image, a.DividendYield, a.Currency, collected,
+			a.SectorNormalized, a.IndustryNormalized, a.MIC, a.ExchangeTimezone, a.IsMarketOpen,
+			a.QuoteSession, sql.NullString{String: formatOptionalTime(a.QuoteTime), Valid: a.QuoteTime != nil},
+			nullableCount(a.SharesOutstanding), nullableCount(a.FloatShares), nullableCount(a.FreeFloatMarketCap),
+			a.InstrumentType, a.ShareClass, a.LocalCurrency, nullableCount(a.FXRateUsed), a.FXRateSource,
+			sql.NullString{String: formatOptionalTime(a.FXRateTime), Valid: a.FXRateTime != nil},
+			a.CapTier,
+		); err != nil {
+			return fmt.Errorf("failed to insert %s: %w", a.Ticker, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// nullableCount stores an unknown (zero) share count or amount as NULL
+func nullableCount(v float64) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: v, Valid: v != 0}
+}
+
+// migrateSQLite adds the sqliteAddedColumns a database created by an older
+// build lacks
+func migrateSQLite(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, "SELECT name FROM pragma_table_info('"+SnapshotTable+"')")
+	if err != nil {
+		return err
+	}
+	have := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		have[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, column := range sqliteAddedColumns {
+		if have[column.name] {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, "ALTER TABLE "+SnapshotTable+" ADD COLUMN "+column.name+" "+column.kind); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadSQLite returns the snapshot a SQLite history holds for date
+// (YYYY-MM-DD), in rank order
+func ReadSQLite(path, date string) ([]domain.Asset, error) {
+	// Opening a missing file would create an empty database
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `SELECT symbol, name, market_cap, current_price, previous_close,
+	percentage_change, volume, primary_exchange, country, sector, industry, asset_type
+FROM `+SnapshotTable+` WHERE snapshot_date = ? ORDER BY rank_position`, date)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", date, err)
+	}
+	defer rows.Close()
+
+	var assets []domain.Asset
+	for rows.Next() {
+		var a domain.Asset
+		var name, exchange, country, sector, industry, assetType sql.NullString
+		var marketCap, price, previousClose, change, volume sql.NullFloat64
+		if err := rows.Scan(&a.Ticker, &name, &marketCap, &price, &previousClose,
+			&change, &volume, &exchange, &country, &sector, &industry, &assetType); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", date, err)
+		}
+		a.Name, a.PrimaryExchange, a.Country = name.String, exchange.String, country.String
+		a.Sector, a.Industry, a.AssetType = sector.String, industry.String, assetType.String
+		a.MarketCap, a.CurrentPrice, a.PreviousClose = marketCap.Float64, price.Float64, previousClose.Float64
+		a.PercentageChange, a.Volume = change.Float64, volume.Float64
+		assets = append(assets, a)
+	}
+	return assets, rows.Err()
+}
@@ -0,0 +1,201 @@
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"time"
+
+	"algotradar/canonical"
+	"algotradar/domain"
+	"algotradar/schema"
+)
+
+// Rows is a ranked snapshot that can be read more than once, one row at a
+// time, so it need not be held in memory whole
+type Rows interface {
+	Len() int
+	// Each calls fn for every row in rank order, stopping at the first error
+	Each(fn func(domain.Asset) error) error
+}
+
+// Assets is an in-memory snapshot read as Rows
+type Assets []domain.Asset
+
+func (a Assets) Len() int { return len(a) }
+
+func (a Assets) Each(fn func(domain.Asset) error) error {
+	for _, asset := range a {
+		if err := fn(asset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Map returns rows with fn applied to each as it is read
+func Map(rows Rows, fn func(domain.Asset) domain.Asset) Rows {
+	return mapped{rows, fn}
+}
+
+type mapped struct {
+	rows Rows
+	fn   func(domain.Asset) domain.Asset
+}
+
+func (m mapped) Len() int { return m.rows.Len() }
+
+func (m mapped) Each(fn func(domain.Asset) error) error {
+	return m.rows.Each(func(asset domain.Asset) error { return fn(m.fn(asset)) })
+}
+
+// RowSink is implemented by sinks that can write a snapshot as its rows are
+// read rather than needing all of them at once
+type RowSink interface {
+	OutputSink
+	WriteRows(rows Rows) error
+}
+
+// WriteRows writes rows to s, streaming them when s is a RowSink and reading
+// them into memory for it otherwise
+func WriteRows(s OutputSink, rows Rows) error {
+	if streaming, ok := s.(RowSink); ok {
+		return streaming.WriteRows(rows)
+	}
+	if assets, ok := rows.(Assets); ok {
+		return s.Write(assets)
+	}
+	assets := make([]domain.Asset, 0, rows.Len())
+	if err := rows.Each(func(asset domain.Asset) error {
+		assets = append(assets, asset)
+		return nil
+	}); err != nil {
+		return err
+	}
+	return s.Write(assets)
+}
+
+// WriteRows streams rows to the file. Formats written whole, like Parquet,
+// read the rows into memory first, as does an in-memory snapshot.
+func (f *File) WriteRows(rows Rows) error {
+	newEncoder, ok := rowEncoders[f.kind]
+	if assets, inMemory := rows.(Assets); inMemory || !ok {
+		if !inMemory {
+			assets = make(Assets, 0, rows.Len())
+			if err := rows.Each(func(asset domain.Asset) error {
+				assets = append(assets, asset)
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		return f.Write(assets)
+	}
+
+	return f.writeFile(func(w io.Writer) error {
+		buffered := bufio.NewWriter(w)
+		encoder, err := newEncoder(buffered)
+		if err != nil {
+			return err
+		}
+		if err := rows.Each(encoder.Row); err != nil {
+			return err
+		}
+		if err := encoder.Close(); err != nil {
+			return err
+		}
+		return buffered.Flush()
+	})
+}
+
+// rowEncoder writes a snapshot one row at a time, producing the same bytes
+// as the format's encoder; Close completes the file
+type rowEncoder interface {
+	Row(asset domain.Asset) error
+	Close() error
+}
+
+var rowEncoders = map[string]func(w io.Writer) (rowEncoder, error){
+	"json": func(w io.Writer) (rowEncoder, error) {
+		return &jsonRows{w: w, row: func(_ int, asset domain.Asset) any { return schema.Asset{Asset: asset, SchemaVersion: schema.Version} }}, nil
+	},
+	"supabase": func(w io.Writer) (rowEncoder, error) {
+		today := time.Now().UTC().Format(time.DateOnly)
+		return &jsonRows{w: w, row: func(rank int, asset domain.Asset) any { return supabaseRow(rank, asset, today) }}, nil
+	},
+	"csv": func(w io.Writer) (rowEncoder, error) {
+		writer, err := newCSVWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		return &csvRows{writer: writer}, nil
+	},
+}
+
+// jsonRows writes a canonical JSON array element by element. Each element is
+// encoded canonically on its own and indented one level, which is exactly
+// how it appears inside the canonical encoding of the whole array.
+type jsonRows struct {
+	w     io.Writer
+	row   func(rank int, asset domain.Asset) any
+	count int
+}
+
+func (j *jsonRows) Row(asset domain.Asset) error {
+	j.count++
+	data, err := canonical.JSON(j.row(j.count, asset))
+	if err != nil {
+		return err
+	}
+	separator := ",\n"
+	if j.count == 1 {
+		separator = "[\n"
+	}
+	if _, err := io.WriteString(j.w, separator); err != nil {
+		return err
+	}
+	for i, line := range bytes.Split(bytes.TrimRight(data, "\n"), []byte("\n")) {
+		if i > 0 {
+			if _, err := io.WriteString(j.w, "\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(j.w, canonical.Indent); err != nil {
+			return err
+		}
+		if _, err := j.w.Write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j *jsonRows) Close() error {
+	// The encoding of an empty array supplies the trailing newlines
+	empty, err := canonical.JSON([]struct{}{})
+	if err != nil {
+		return err
+	}
+	if j.count > 0 {
+		empty = append([]byte("\n]"), empty[len("[]"):]...)
+	}
+	_, err = j.w.Write(empty)
+	return err
+}
+
+// csvRows writes ranked CSV records as they arrive
+type csvRows struct {
+	writer *csv.Writer
+	count  int
+}
+
+func (c *csvRows) Row(asset domain.Asset) error {
+	c.count++
+	return c.writer.Write(csvRecord(c.count, asset))
+}
+
+func (c *csvRows) Close() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
@@ -0,0 +1,278 @@
+package sink
+
+import (
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+
+	"algotradar/domain"
+)
+
+// Built-in Excel number formats
+const (
+	numFmtPercent  = 2  // 0.00, for values already in percent
+	numFmtInteger  = 3  // #,##0
+	numFmtDecimal  = 4  // #,##0.00
+	numFmtFraction = 10 // 0.00%
+)
+
+const (
+	summarySheet   = "Summary"
+	unknownCountry = "Unknown"
+	// maxSheetNameLen is Excel's limit on sheet names
+	maxSheetNameLen = 31
+)
+
+// xlsxStyles are the cell styles a snapshot workbook uses
+type xlsxStyles struct {
+	header, total, integer, decimal, percent, fraction int
+}
+
+// countrySheet is the rows of one country, ranked across the whole snapshot
+type countrySheet struct {
+	name      string
+	ranks     []int
+	assets    []domain.Asset
+	marketCap float64
+}
+
+// EncodeXLSX writes the snapshot as an Excel workbook: a summary sheet with
+// each country's company count and market cap, then one sheet per country,
+// largest market first, holding the CSV columns as typed cells. Header rows
+// are frozen and market caps, prices and volumes carry number formats.
+func EncodeXLSX(w io.Writer, assets []domain.Asset) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	styles, err := newXLSXStyles(f)
+	if err != nil {
+		return err
+	}
+
+	sheets := groupByCountry(assets)
+	if err := f.SetSheetName("Sheet1", summarySheet); err != nil {
+		return err
+	}
+	if err := writeSummarySheet(f, styles, sheets); err != nil {
+		return err
+	}
+	for _, sheet := range sheets {
+		if _, err := f.NewSheet(sheet.name); err != nil {
+			return err
+		}
+		if err := writeCountrySheet(f, styles, sheet); err != nil {
+			return err
+		}
+	}
+
+	_, err = f.WriteTo(w)
+	return err
+}
+
+func newXLSXStyles(f *excelize.File) (xlsxStyles, error) {
+	var s xlsxStyles
+	for _, style := range []struct {
+		id    *int
+		style excelize.Style
+	}{
+		{&s.header, excelize.Style{Font: &excelize.Font{Bold: true}, Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"DDEBF7"}}}},
+		{&s.total, excelize.Style{Font: &excelize.Font{Bold: true}, NumFmt: numFmtInteger}},
+		{&s.integer, excelize.Style{NumFmt: numFmtInteger}},
+		{&s.decimal, excelize.Style{NumFmt: numFmtDecimal}},
+		{&s.percent, excelize.Style{NumFmt: numFmtPercent}},
+		{&s.fraction, excelize.Style{NumFmt: numFmtFraction}},
+	} {
+		id, err := f.NewStyle(&style.style)
+		if err != nil {
+			return s, err
+		}
+		*style.id = id
+	}
+	return s, nil
+}
+
+// groupByCountry splits ranked assets into country sheets, largest total
+// market cap first
+func groupByCountry(assets []domain.Asset) []*countrySheet {
+	byName := make(map[string]*countrySheet)
+	var sheets []*countrySheet
+	for i, asset := range assets {
+		name := sheetName(asset.Country)
+		sheet, ok := byName[name]
+		if !ok {
+			sheet = &countrySheet{name: name}
+			byName[name] = sheet
+			sheets = append(sheets, sheet)
+		}
+		sheet.ranks = append(sheet.ranks, i+1)
+		sheet.assets = append(sheet.assets, asset)
+		sheet.marketCap += asset.MarketCap
+	}
+	sort.SliceStable(sheets, func(i, j int) bool {
+		if sheets[i].marketCap != sheets[j].marketCap {
+			return sheets[i].marketCap > sheets[j].marketCap
+		}
+		return sheets[i].name < sheets[j].name
+	})
+	return sheets
+}
+
+// sheetName turns a country code into a valid, case-insensitively unique
+// sheet name
+func sheetName(country string) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(country)))
+	name = strings.Trim(name, "'")
+	if name == "" || strings.EqualFold(name, summarySheet) {
+		return unknownCountry
+	}
+	if len(name) > maxSheetNameLen {
+		name = name[:maxSheetNameLen]
+	}
+	return name
+}
+
+func writeSummarySheet(f *excelize.File, styles xlsxStyles, sheets []*countrySheet) error {
+	sw, err := f.NewStreamWriter(summarySheet)
+	if err != nil {
+		return err
+	}
+	if err := freezeHeader(sw); err != nil {
+		return err
+	}
+	for _, width := range []struct {
+		col   int
+		width float64
+	}{{1, 12}, {2, 12}, {3, 22}, {4, 10}, {5, 14}, {6, 36}} {
+		if err := sw.SetColWidth(width.col, width.col, width.width); err != nil {
+			return err
+		}
+	}
+
+	header := []string{"Country", "Companies", "Market_Cap_USD", "Share", "Largest_Ticker", "Largest_Name"}
+	if err := sw.SetRow("A1", headerRow(header, styles.header)); err != nil {
+		return err
+	}
+
+	total, companies := 0.0, 0
+	for _, sheet := range sheets {
+		total += sheet.marketCap
+		companies += len(sheet.assets)
+	}
+	for i, sheet := range sheets {
+		share := 0.0
+		if total > 0 {
+			share = sheet.marketCap / total
+		}
+		// Rows are ranked, so the first is the country's largest company
+		largest := sheet.assets[0]
+		row := []any{
+			sheet.name,
+			len(sheet.assets),
+			excelize.Cell{StyleID: styles.integer, Value: sheet.marketCap},
+			excelize.Cell{StyleID: styles.fraction, Value: share},
+			largest.Ticker,
+			CleanText(largest.Name),
+		}
+		if err := sw.SetRow(cellName(1, i+2), row); err != nil {
+			return err
+		}
+	}
+	totals := []any{
+		excelize.Cell{StyleID: styles.total, Value: "Total"},
+		excelize.Cell{StyleID: styles.total, Value: companies},
+		excelize.Cell{StyleID: styles.total, Value: total},
+	}
+	if err := sw.SetRow(cellName(1, len(sheets)+2), totals); err != nil {
+		return err
+	}
+	return sw.Flush()
+}
+
+func writeCountrySheet(f *excelize.File, styles xlsxStyles, sheet *countrySheet) error {
+	sw, err := f.NewStreamWriter(sheet.name)
+	if err != nil {
+		return err
+	}
+	if err := freezeHeader(sw); err != nil {
+		return err
+	}
+	if err := sw.SetColWidth(1, len(csvHeader), 14); err != nil {
+		return err
+	}
+	if err := sw.SetColWidth(3, 3, 36); err != nil {
+		return err
+	}
+	if err := sw.SetColWidth(7, 7, 20); err != nil {
+		return err
+	}
+
+	if err := sw.SetRow("A1", headerRow(csvHeader, styles.header)); err != nil {
+		return err
+	}
+	for i, asset := range sheet.assets {
+		if err := sw.SetRow(cellName(1, i+2), xlsxRecord(sheet.ranks[i], asset, styles)); err != nil {
+			return err
+		}
+	}
+	return sw.Flush()
+}
+
+// xlsxRecord is the worksheet row of the asset ranked rank, with number
+// formats on the numeric columns
+func xlsxRecord(rank int, asset domain.Asset, styles xlsxStyles) []any {
+	row := spreadsheetRecord(rank, asset)
+	for col, style := range map[int]int{
+		6:  styles.integer, // Market_Cap_USD
+		7:  styles.decimal, // Current_Price
+		8:  styles.decimal, // Previous_Close
+		9:  styles.percent, // Percentage_Change
+		10: styles.integer, // Volume
+		20: styles.integer, // Shares_Outstanding
+		21: styles.integer, // Float_Shares
+		22: styles.integer, // Free_Float_Market_Cap_USD
+		23: styles.decimal, // Year_High
+		24: styles.decimal, // Year_Low
+		25: styles.decimal, // Price_Avg_50
+		26: styles.decimal, // Price_Avg_200
+		27: styles.percent, // YTD_Return
+		28: styles.percent, // Institutional_Ownership_Pct
+		29: styles.percent, // Top10_Holder_Pct
+		30: styles.integer, // Institutional_Holders
+	} {
+		if row[col] == "" {
+			continue
+		}
+		row[col] = excelize.Cell{StyleID: style, Value: row[col]}
+	}
+	return row
+}
+
+func headerRow(names []string, style int) []any {
+	row := make([]any, len(names))
+	for i, name := range names {
+		row[i] = excelize.Cell{StyleID: style, Value: name}
+	}
+	return row
+}
+
+// freezeHeader keeps the first row in view while scrolling
+func freezeHeader(sw *excelize.StreamWriter) error {
+	return sw.SetPanes(&excelize.Panes{
+		Freeze:      true,
+		YSplit:      1,
+		TopLeftCell: "A2",
+		ActivePane:  "bottomLeft",
+	})
+}
+
+func cellName(col, row int) string {
+	name, _ := excelize.CoordinatesToCellName(col, row)
+	return name
+}
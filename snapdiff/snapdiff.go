@@ -0,0 +1,135 @@
+// Package snapdiff compares two ranked snapshots: which companies entered or
+// left the universe, how ranks moved and how market caps changed. Comparing
+// only the top N of each tracks index membership, e.g. a top-100 index.
+package snapdiff
+
+import (
+	"sort"
+
+	"algotradar/domain"
+)
+
+// Member is a company present in only one of the snapshots
+type Member struct {
+	Ticker    string  `json:"ticker"`
+	Name      string  `json:"name"`
+	Country   string  `json:"country"`
+	Rank      int     `json:"rank"`
+	MarketCap float64 `json:"market_cap"` // USD
+}
+
+// Change is a company present in both snapshots
+type Change struct {
+	Ticker       string  `json:"ticker"`
+	Name         string  `json:"name"`
+	Country      string  `json:"country"`
+	OldRank      int     `json:"old_rank"`
+	NewRank      int     `json:"new_rank"`
+	RankChange   int     `json:"rank_change"` // positive when it climbed
+	OldMarketCap float64 `json:"old_market_cap"`
+	NewMarketCap float64 `json:"new_market_cap"`
+	// MarketCapChange is the USD delta and MarketCapChangePct the relative
+	// one, 0 when the old market cap was not positive
+	MarketCapChange    float64 `json:"market_cap_change"`
+	MarketCapChangePct float64 `json:"market_cap_change_pct"`
+}
+
+// Report is the difference between an old and a new snapshot
+type Report struct {
+	Old          string  `json:"old"`
+	New          string  `json:"new"`
+	Top          int     `json:"top,omitempty"`
+	OldCount     int     `json:"old_count"`
+	NewCount     int     `json:"new_count"`
+	OldMarketCap float64 `json:"old_market_cap"`
+	NewMarketCap float64 `json:"new_market_cap"`
+	// Entrants are in the new snapshot only and Dropouts in the old one
+	// only, both largest first
+	Entrants []Member `json:"entrants"`
+	Dropouts []Member `json:"dropouts"`
+	// Changes are the companies in both, largest rank move first
+	Changes []Change `json:"changes"`
+}
+
+// Compare diffs two snapshots labelled oldName and newName. Each is ranked by
+// market cap and, when top is positive, cut to its largest top companies, so
+// a company falling out of the top N is a dropout even if still listed.
+func Compare(oldName string, oldAssets []domain.Asset, newName string, newAssets []domain.Asset, top int) Report {
+	before, after := rank(oldAssets, top), rank(newAssets, top)
+	r := Report{
+		Old: oldName, New: newName, Top: max(top, 0),
+		OldCount: len(before), NewCount: len(after),
+		Entrants: []Member{}, Dropouts: []Member{}, Changes: []Change{},
+	}
+
+	oldRanks := make(map[string]int, len(before))
+	for i, a := range before {
+		oldRanks[a.Ticker] = i
+		r.OldMarketCap += a.MarketCap
+	}
+	newRanks := make(map[string]bool, len(after))
+	for i, a := range after {
+		newRanks[a.Ticker] = true
+		r.NewMarketCap += a.MarketCap
+		j, ok := oldRanks[a.Ticker]
+		if !ok {
+			r.Entrants = append(r.Entrants, member(a, i+1))
+			continue
+		}
+		was := before[j]
+		c := Change{
+			Ticker: a.Ticker, Name: a.Name, Country: a.Country,
+			OldRank: j + 1, NewRank: i + 1, RankChange: j - i,
+			OldMarketCap: was.MarketCap, NewMarketCap: a.MarketCap,
+			MarketCapChange: a.MarketCap - was.MarketCap,
+		}
+		if was.MarketCap > 0 {
+			c.MarketCapChangePct = c.MarketCapChange / was.MarketCap * 100
+		}
+		r.Changes = append(r.Changes, c)
+	}
+	for i, a := range before {
+		if !newRanks[a.Ticker] {
+			r.Dropouts = append(r.Dropouts, member(a, i+1))
+		}
+	}
+
+	sort.SliceStable(r.Changes, func(i, j int) bool {
+		a, b := abs(r.Changes[i].RankChange), abs(r.Changes[j].RankChange)
+		if a != b {
+			return a > b
+		}
+		return r.Changes[i].NewRank < r.Changes[j].NewRank
+	})
+	return r
+}
+
+// rank returns the assets in market cap order, one row per ticker, cut to
+// top when it is positive
+func rank(assets []domain.Asset, top int) []domain.Asset {
+	ranked := append([]domain.Asset(nil), assets...)
+	domain.SortAssets(ranked)
+	seen := make(map[string]bool, len(ranked))
+	unique := ranked[:0]
+	for _, a := range ranked {
+		if !seen[a.Ticker] {
+			seen[a.Ticker] = true
+			unique = append(unique, a)
+		}
+	}
+	if top > 0 && len(unique) > top {
+		unique = unique[:top]
+	}
+	return unique
+}
+
+func member(a domain.Asset, rank int) Member {
+	return Member{Ticker: a.Ticker, Name: a.Name, Country: a.Country, Rank: rank, MarketCap: a.MarketCap}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,168 @@
+// Package spool ranks a stream of assets by market cap while holding only a
+// bounded number of them in memory. Rows past the bound are sorted and
+// spilled to a temporary file as runs, which are merged back on every read.
+package spool
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"algotradar/domain"
+)
+
+// Spool collects assets and replays them in the order of domain.SortAssets.
+// It is not safe for concurrent use. The zero value is not usable; call New.
+type Spool struct {
+	limit int
+	buf   []domain.Asset
+	file  *os.File
+	size  int64 // bytes spilled to file
+	runs  []run
+	count int
+}
+
+// run is one sorted section of the spill file
+type run struct {
+	offset, length int64
+}
+
+// New returns a spool holding up to limit rows in memory; limit below 1
+// holds one
+func New(limit int) *Spool {
+	return &Spool{limit: max(limit, 1)}
+}
+
+// Add appends a row, spilling the buffered rows to disk once it is full
+func (s *Spool) Add(asset domain.Asset) error {
+	s.buf = append(s.buf, asset)
+	s.count++
+	if len(s.buf) < s.limit {
+		return nil
+	}
+	return s.spill()
+}
+
+// Len returns the number of rows added
+func (s *Spool) Len() int { return s.count }
+
+// Spilled returns the number of runs written to disk
+func (s *Spool) Spilled() int { return len(s.runs) }
+
+// spill writes the buffered rows to the spill file as one sorted run
+func (s *Spool) spill() error {
+	if s.file == nil {
+		file, err := os.CreateTemp("", "spool-*.jsonl")
+		if err != nil {
+			return fmt.Errorf("failed to create spool file: %w", err)
+		}
+		s.file = file
+	}
+	domain.SortAssets(s.buf)
+
+	counter := &countingWriter{w: s.file}
+	writer := bufio.NewWriter(counter)
+	encoder := json.NewEncoder(writer)
+	for _, asset := range s.buf {
+		if err := encoder.Encode(asset); err != nil {
+			return fmt.Errorf("failed to spill row %s: %w", asset.Ticker, err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write spool file: %w", err)
+	}
+	s.runs = append(s.runs, run{offset: s.size, length: counter.n})
+	s.size += counter.n
+	// Clear the rows so the backing array does not keep their strings alive
+	clear(s.buf)
+	s.buf = s.buf[:0]
+	return nil
+}
+
+// Each calls fn for every row in rank order, stopping at the first error.
+// It may be called more than once; every call replays all rows.
+func (s *Spool) Each(fn func(domain.Asset) error) error {
+	domain.SortAssets(s.buf)
+
+	// Each source is a sorted run; the one with the highest ranked head row
+	// yields next. There are few runs, so a linear scan beats a heap.
+	type source struct {
+		head    domain.Asset
+		ok      bool
+		next    func() (domain.Asset, bool, error)
+		pending int // index into buf for the in-memory run
+	}
+	sources := make([]*source, 0, len(s.runs)+1)
+	for _, r := range s.runs {
+		decoder := json.NewDecoder(bufio.NewReader(io.NewSectionReader(s.file, r.offset, r.length)))
+		sources = append(sources, &source{next: func() (domain.Asset, bool, error) {
+			var asset domain.Asset
+			if err := decoder.Decode(&asset); err != nil {
+				if errors.Is(err, io.EOF) {
+					return asset, false, nil
+				}
+				return asset, false, fmt.Errorf("failed to read spool file: %w", err)
+			}
+			return asset, true, nil
+		}})
+	}
+	memory := &source{}
+	memory.next = func() (domain.Asset, bool, error) {
+		if memory.pending == len(s.buf) {
+			return domain.Asset{}, false, nil
+		}
+		memory.pending++
+		return s.buf[memory.pending-1], true, nil
+	}
+	sources = append(sources, memory)
+
+	var err error
+	for _, src := range sources {
+		if src.head, src.ok, err = src.next(); err != nil {
+			return err
+		}
+	}
+	for {
+		var best *source
+		for _, src := range sources {
+			if src.ok && (best == nil || domain.RanksBefore(src.head, best.head)) {
+				best = src
+			}
+		}
+		if best == nil {
+			return nil
+		}
+		if err := fn(best.head); err != nil {
+			return err
+		}
+		if best.head, best.ok, err = best.next(); err != nil {
+			return err
+		}
+	}
+}
+
+// Close removes the spill file
+func (s *Spool) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	name := s.file.Name()
+	s.file.Close()
+	s.file = nil
+	return os.Remove(name)
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,103 @@
+package taxonomy
+
+import "strings"
+
+// Reasons a Filter rejects a company, as recorded in run reports
+const (
+	DropSector   = "sector_filter"
+	DropIndustry = "industry_filter"
+)
+
+// Filter keeps companies by sector and industry. A name matches either the
+// provider's label or its normalized one, spelled in any case, dash style or
+// with "and" for "&", so "Technology" and "Information Technology" both
+// select FMP's technology companies.
+type Filter struct {
+	// Sectors and Industries, when set, are the only ones kept
+	Sectors    []string
+	Industries []string
+
+	// ExcludeIndustries are dropped even when their sector is kept
+	ExcludeIndustries []string
+}
+
+// Empty reports whether the filter keeps every company
+func (f Filter) Empty() bool {
+	return len(f.Sectors) == 0 && len(f.Industries) == 0 && len(f.ExcludeIndustries) == 0
+}
+
+// Match returns "" when the filter keeps a company with the provider's
+// sector and industry, and otherwise why it is dropped: DropSector or
+// DropIndustry
+func (f Filter) Match(sector, industry string) string {
+	if f.Empty() {
+		return ""
+	}
+	normalSector, normalIndustry := Normalize(sector, industry)
+	if len(f.Sectors) > 0 && !matchAny(f.Sectors, sector, normalSector) {
+		return DropSector
+	}
+	if len(f.Industries) > 0 && !matchAny(f.Industries, industry, normalIndustry) {
+		return DropIndustry
+	}
+	if matchAny(f.ExcludeIndustries, industry, normalIndustry) {
+		return DropIndustry
+	}
+	return ""
+}
+
+// matchAny reports whether any of names is one of labels
+func matchAny(names []string, labels ...string) bool {
+	for _, name := range names {
+		for _, label := range labels {
+			if label != "" && key(name) == key(label) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ParseNames splits a comma-separated list of sector or industry names.
+// Names with commas of their own, such as "Oil, Gas & Consumable Fuels", are
+// kept whole when the mapping knows them.
+func ParseNames(list string) []string {
+	var pieces []string
+	for _, piece := range strings.Split(list, ",") {
+		if piece = strings.TrimSpace(piece); piece != "" {
+			pieces = append(pieces, piece)
+		}
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	var names []string
+	for start := 0; start < len(pieces); {
+		end := start + 1
+		for longest := len(pieces); longest > end; longest-- {
+			if known(strings.Join(pieces[start:longest], ", ")) {
+				end = longest
+				break
+			}
+		}
+		names = append(names, strings.Join(pieces[start:end], ", "))
+		start = end
+	}
+	return names
+}
+
+// known reports whether the mapping has name as a sector or industry;
+// callers hold mu
+func known(name string) bool {
+	_, sector := sectors[key(name)]
+	_, industry := industries[key(name)]
+	return sector || industry
+}
+
+// Known reports whether the mapping has name as a sector or industry, either
+// a provider's label or a normalized one
+func Known(name string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return known(name)
+}
@@ -0,0 +1,108 @@
+// Package taxonomy maps the free-text sector and industry names the data
+// providers report onto one GICS-like taxonomy, so that e.g. "Consumer
+// Cyclical", "Banks—Regional" and "Banks - Regional" rank and filter alike
+// across countries.
+package taxonomy
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Class is a normalized classification: a GICS sector and, when known, a
+// GICS industry within it
+type Class struct {
+	Sector   string `json:"sector"`
+	Industry string `json:"industry,omitempty"`
+}
+
+// Mapping translates provider names to the taxonomy. Industries are
+// consulted first since an industry pins down its sector; Sectors cover rows
+// whose industry is missing or unknown.
+type Mapping struct {
+	Sectors    map[string]string `json:"sectors"`
+	Industries map[string]Class  `json:"industries"`
+}
+
+//go:embed gics.json
+var defaultMapping []byte
+
+var (
+	mu         sync.RWMutex
+	sectors    map[string]string
+	industries map[string]Class
+)
+
+func init() {
+	var m Mapping
+	if err := json.Unmarshal(defaultMapping, &m); err != nil {
+		panic(fmt.Sprintf("taxonomy: invalid gics.json: %v", err))
+	}
+	sectors = make(map[string]string)
+	industries = make(map[string]Class)
+	merge(m)
+}
+
+// Load reads a mapping from path and merges it over the bundled one: names
+// it lists replace the bundled translation and new names are added
+func Load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read taxonomy mapping: %w", err)
+	}
+	var m Mapping
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("failed to parse taxonomy mapping: %w", err)
+	}
+	for name, class := range m.Industries {
+		if strings.TrimSpace(class.Sector) == "" {
+			return fmt.Errorf("taxonomy mapping: industry %q has no sector", name)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	merge(m)
+	return nil
+}
+
+// merge adds m to the lookup tables, along with the taxonomy's own names so
+// already normalized values map to themselves; callers hold mu unless
+// running from init
+func merge(m Mapping) {
+	for name, sector := range m.Sectors {
+		sectors[key(name)] = sector
+		sectors[key(sector)] = sector
+	}
+	for name, class := range m.Industries {
+		industries[key(name)] = class
+		sectors[key(class.Sector)] = class.Sector
+		if class.Industry != "" {
+			industries[key(class.Industry)] = class
+		}
+	}
+}
+
+// Normalize returns the taxonomy's sector and industry for a provider's
+// sector and industry names. Either is empty when the mapping does not know
+// it.
+func Normalize(sector, industry string) (string, string) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if class, ok := industries[key(industry)]; ok {
+		return class.Sector, class.Industry
+	}
+	return sectors[key(sector)], ""
+}
+
+// key folds the spellings providers use for the same name: case, dash
+// style, "&" versus "and" and spacing
+func key(name string) string {
+	name = strings.ToLower(name)
+	name = strings.NewReplacer("—", "-", "–", "-", "&", " and ").Replace(name)
+	name = strings.Join(strings.Fields(name), " ")
+	return strings.NewReplacer(" - ", "-", " -", "-", "- ", "-").Replace(name)
+}
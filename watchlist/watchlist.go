@@ -0,0 +1,320 @@
+// Package watchlist persists named lists of symbols, such as a portfolio's
+// holdings, that the collectors and the intraday poller can target by name.
+package watchlist
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// DefaultPath is the JSON file watchlists are kept in by default
+const DefaultPath = "watchlists.json"
+
+// Table is the SQLite table of a database store, one row per symbol
+const Table = "watchlists"
+
+// ErrNotFound is returned for a watchlist that does not exist
+var ErrNotFound = errors.New("no such watchlist")
+
+// dbTimeout bounds each operation on a database store
+const dbTimeout = 30 * time.Second
+
+// validName is what watchlist names may contain; they name output files too
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// Store keeps watchlists by name
+type Store interface {
+	// Get returns the symbols of the named watchlist in the order they were
+	// added, or an error wrapping ErrNotFound
+	Get(name string) ([]string, error)
+
+	// Put replaces the named watchlist, creating it if needed; no symbols
+	// deletes it
+	Put(name string, symbols []string) error
+
+	// All returns every watchlist by name
+	All() (map[string][]string, error)
+
+	String() string
+}
+
+// Open returns the store named by spec: sqlite:PATH for a table in an
+// SQLite database, or json:PATH or a plain path for a JSON file; empty
+// means DefaultPath
+func Open(spec string) (Store, error) {
+	if path, ok := strings.CutPrefix(spec, "sqlite:"); ok {
+		if path == "" {
+			return nil, errors.New("sqlite watchlist store needs a path")
+		}
+		return &SQLite{Path: path}, nil
+	}
+	path := strings.TrimPrefix(spec, "json:")
+	if path == "" {
+		path = DefaultPath
+	}
+	return &File{Path: path}, nil
+}
+
+// Load returns the symbols of the watchlist name in the store spec
+func Load(spec, name string) ([]string, error) {
+	store, err := Open(spec)
+	if err != nil {
+		return nil, err
+	}
+	return store.Get(name)
+}
+
+// CheckName returns an error unless name can name a watchlist: letters,
+// digits, dots, dashes and underscores, starting with a letter or digit
+func CheckName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid watchlist name %q, want letters, digits, '.', '-' or '_'", name)
+	}
+	return nil
+}
+
+// Normalize upper-cases and trims symbols, dropping empty ones and repeats
+func Normalize(symbols []string) []string {
+	seen := make(map[string]bool, len(symbols))
+	var normal []string
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(strings.TrimSpace(symbol))
+		if symbol == "" || seen[symbol] {
+			continue
+		}
+		seen[symbol] = true
+		normal = append(normal, symbol)
+	}
+	return normal
+}
+
+// Add appends symbols not yet on the named watchlist, creating it if needed,
+// and returns the updated list
+func Add(s Store, name string, symbols []string) ([]string, error) {
+	if err := CheckName(name); err != nil {
+		return nil, err
+	}
+	current, err := s.Get(name)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	updated := Normalize(append(current, symbols...))
+	if len(updated) == 0 {
+		return nil, fmt.Errorf("no symbols to add to %s", name)
+	}
+	return updated, s.Put(name, updated)
+}
+
+// Remove takes symbols off the named watchlist and returns what is left; no
+// symbols deletes the whole watchlist, as does removing its last symbol
+func Remove(s Store, name string, symbols []string) ([]string, error) {
+	current, err := s.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	drop := make(map[string]bool)
+	for _, symbol := range Normalize(symbols) {
+		drop[symbol] = true
+	}
+	var kept []string
+	if len(drop) > 0 {
+		for _, symbol := range current {
+			if !drop[symbol] {
+				kept = append(kept, symbol)
+			}
+		}
+	}
+	return kept, s.Put(name, kept)
+}
+
+// Names returns the names of a store's watchlists, sorted
+func Names(lists map[string][]string) []string {
+	names := make([]string, 0, len(lists))
+	for name := range lists {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// File keeps watchlists in a JSON object of symbol arrays by name. Each
+// change rewrites the file atomically.
+type File struct {
+	Path string
+}
+
+func (f *File) String() string { return "json:" + f.Path }
+
+func (f *File) Get(name string) ([]string, error) {
+	lists, err := f.All()
+	if err != nil {
+		return nil, err
+	}
+	symbols, ok := lists[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q in %s", ErrNotFound, name, f.Path)
+	}
+	return symbols, nil
+}
+
+func (f *File) Put(name string, symbols []string) error {
+	lists, err := f.All()
+	if err != nil {
+		return err
+	}
+	if len(symbols) == 0 {
+		delete(lists, name)
+	} else {
+		lists[name] = symbols
+	}
+	data, err := json.MarshalIndent(lists, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(f.Path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create watchlist directory: %w", err)
+		}
+	}
+	tmp := f.Path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write watchlists %s: %w", f.Path, err)
+	}
+	return os.Rename(tmp, f.Path)
+}
+
+// All returns every watchlist in the file; a missing file holds none
+func (f *File) All() (map[string][]string, error) {
+	lists := make(map[string][]string)
+	data, err := os.ReadFile(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return lists, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &lists); err != nil {
+		return nil, fmt.Errorf("invalid watchlists %s: %w", f.Path, err)
+	}
+	return lists, nil
+}
+
+// SQLite keeps watchlists in the Table of an embedded database file, which
+// may be the one --store keeps the snapshot history in
+type SQLite struct {
+	Path string
+}
+
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS ` + Table + ` (
+	name     TEXT    NOT NULL,
+	position INTEGER NOT NULL,
+	symbol   TEXT    NOT NULL,
+	PRIMARY KEY (name, symbol)
+)`
+
+func (s *SQLite) String() string { return "sqlite:" + s.Path }
+
+// open opens the database and creates the table on first use
+func (s *SQLite) open(ctx context.Context) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create %s table: %w", Table, err)
+	}
+	return db, nil
+}
+
+func (s *SQLite) Get(name string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+	db, err := s.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `SELECT symbol FROM `+Table+` WHERE name = ? ORDER BY position`, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query watchlist %s: %w", name, err)
+	}
+	defer rows.Close()
+	var symbols []string
+	for rows.Next() {
+		var symbol string
+		if err := rows.Scan(&symbol); err != nil {
+			return nil, err
+		}
+		symbols = append(symbols, symbol)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(symbols) == 0 {
+		return nil, fmt.Errorf("%w %q in %s", ErrNotFound, name, s.Path)
+	}
+	return symbols, nil
+}
+
+func (s *SQLite) Put(name string, symbols []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+	db, err := s.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM `+Table+` WHERE name = ?`, name); err != nil {
+		return fmt.Errorf("failed to replace watchlist %s: %w", name, err)
+	}
+	for i, symbol := range symbols {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO `+Table+` (name, position, symbol) VALUES (?, ?, ?)`, name, i, symbol); err != nil {
+			return fmt.Errorf("failed to save watchlist %s: %w", name, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite) All() (map[string][]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+	db, err := s.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `SELECT name, symbol FROM `+Table+` ORDER BY name, position`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query watchlists: %w", err)
+	}
+	defer rows.Close()
+	lists := make(map[string][]string)
+	for rows.Next() {
+		var name, symbol string
+		if err := rows.Scan(&name, &symbol); err != nil {
+			return nil, err
+		}
+		lists[name] = append(lists[name], symbol)
+	}
+	return lists, rows.Err()
+}
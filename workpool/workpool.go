@@ -0,0 +1,179 @@
+// Package workpool runs collector work on a bounded set of goroutines whose
+// effective concurrency adapts to the API's rate-limit feedback: it halves
+// when a 429 comes back and grows by one after a run of healthy responses.
+package workpool
+
+import (
+	"sync"
+	"time"
+
+	"algotradar/metrics"
+)
+
+const (
+	// DefaultCooldown is the minimum time between two concurrency cuts, so a
+	// burst of 429s from requests already in flight counts as one signal
+	DefaultCooldown = 5 * time.Second
+)
+
+// Pool is an adaptive concurrency limit shared by every Run on it. Workers
+// report each API response through Succeeded or Throttled; the limit is
+// multiplicatively decreased on throttling and additively increased once
+// as many successes as the current limit arrive in a row. The zero value is
+// not usable; call New.
+type Pool struct {
+	Name     string
+	Min, Max int
+	Cooldown time.Duration
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	limit     int
+	active    int
+	successes int
+	lastCut   time.Time
+
+	started   time.Time
+	completed int64
+	throttles int64
+	peak      int
+}
+
+// Stats is a snapshot of a pool's state and effective throughput
+type Stats struct {
+	Limit      int     `json:"limit"`
+	Active     int     `json:"active"`
+	Peak       int     `json:"peak"`
+	Completed  int64   `json:"completed"`
+	Throttles  int64   `json:"throttles"`
+	PerSecond  float64 `json:"per_second"`
+	ElapsedSec float64 `json:"elapsed_seconds"`
+}
+
+// New returns a pool that starts at max concurrency and never goes below min
+func New(name string, minWorkers, maxWorkers int) *Pool {
+	minWorkers = max1(minWorkers)
+	if maxWorkers < minWorkers {
+		maxWorkers = minWorkers
+	}
+	p := &Pool{Name: name, Min: minWorkers, Max: maxWorkers, Cooldown: DefaultCooldown, limit: maxWorkers, started: time.Now()}
+	p.cond = sync.NewCond(&p.mu)
+	metrics.WorkerPoolLimit.Set(float64(maxWorkers), name)
+	return p
+}
+
+func max1(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// Run calls fn for every item received from items on up to workers
+// goroutines, of which no more than the pool's current limit run at once. It
+// returns when items is closed and drained. Worker IDs run from 0 to
+// workers-1.
+func Run[T any](p *Pool, workers int, items <-chan T, fn func(worker int, item T)) {
+	var wg sync.WaitGroup
+	for w := 0; w < max1(workers); w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for item := range items {
+				p.acquire()
+				fn(worker, item)
+				p.release()
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+// Each is Run over the items of a slice
+func Each[T any](p *Pool, workers int, items []T, fn func(worker int, item T)) {
+	ch := make(chan T)
+	go func() {
+		defer close(ch)
+		for _, item := range items {
+			ch <- item
+		}
+	}()
+	Run(p, workers, ch, fn)
+}
+
+func (p *Pool) acquire() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.active >= p.limit {
+		p.cond.Wait()
+	}
+	p.active++
+	p.peak = max(p.peak, p.active)
+}
+
+func (p *Pool) release() {
+	p.mu.Lock()
+	p.active--
+	p.completed++
+	p.mu.Unlock()
+	p.cond.Signal()
+	metrics.WorkerPoolTasks.Inc(p.Name)
+}
+
+// Succeeded records a healthy API response. After limit successes in a row
+// the limit grows by one, up to Max. Nil-safe.
+func (p *Pool) Succeeded() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.successes++
+	if p.limit < p.Max && p.successes >= p.limit {
+		p.limit++
+		p.successes = 0
+		metrics.WorkerPoolLimit.Set(float64(p.limit), p.Name)
+		p.cond.Broadcast()
+	}
+}
+
+// Throttled records a rate-limited response and halves the limit, at most once
+// per Cooldown and not below Min. It reports whether the limit was cut.
+// Nil-safe.
+func (p *Pool) Throttled() bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.successes = 0
+	now := time.Now()
+	if now.Sub(p.lastCut) < p.Cooldown || p.limit <= p.Min {
+		return false
+	}
+	p.limit = max(p.limit/2, p.Min)
+	p.lastCut = now
+	p.throttles++
+	metrics.WorkerPoolLimit.Set(float64(p.limit), p.Name)
+	metrics.WorkerPoolThrottles.Inc(p.Name)
+	return true
+}
+
+// Stats returns the pool's current limit and its throughput since New
+func (p *Pool) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	elapsed := time.Since(p.started).Seconds()
+	s := Stats{
+		Limit:      p.limit,
+		Active:     p.active,
+		Peak:       p.peak,
+		Completed:  p.completed,
+		Throttles:  p.throttles,
+		ElapsedSec: elapsed,
+	}
+	if elapsed > 0 {
+		s.PerSecond = float64(p.completed) / elapsed
+	}
+	return s
+}